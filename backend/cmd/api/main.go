@@ -10,6 +10,7 @@ import (
 	"drcrwell/backend/internal/helpers"
 	"drcrwell/backend/internal/metrics"
 	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/pix"
 	"drcrwell/backend/internal/scheduler"
 	"log"
 	"net/http"
@@ -55,6 +56,13 @@ func main() {
 	}
 	defer helpers.CloseSentry()
 
+	// PIX payment service providers available in the clinic settings. The fake provider confirms
+	// charges in memory and is meant for development and tests only
+	if os.Getenv("PIX_FAKE_PROVIDER") == "true" {
+		pix.Register(pix.NewFakeProvider())
+		log.Println("WARNING: fake PIX provider enabled")
+	}
+
 	// Run migrations for all existing tenant schemas
 	// This ensures new tables are created in all tenants on startup
 	if err := database.RunAllMigrations(); err != nil {
@@ -258,6 +266,14 @@ func main() {
 		// Used for patient login from clinic subdomains (e.g., clinicadrsouza.odowell.pro)
 		public.GET("/portal/clinic-info", handlers.PatientPortalPublicClinicInfo)
 		public.POST("/portal/login", middleware.RedisLoginRateLimiter.RateLimitMiddleware(), handlers.PatientPortalLogin)
+
+		// Waiting list slot offers (claim link sent to the patient - token identifies tenant and offer)
+		public.GET("/waiting-list/offers/:token", handlers.GetPublicWaitingListOffer)
+		public.POST("/waiting-list/offers/:token/claim", handlers.ClaimWaitingListOffer)
+		public.POST("/waiting-list/offers/:token/decline", handlers.DeclineWaitingListOffer)
+
+		// Calendar feeds (ICS subscription used by calendar apps - token identifies tenant and agenda)
+		public.GET("/calendar/:token", handlers.GetCalendarFeedICS)
 	}
 
 	// Static file serving for uploads
@@ -323,6 +339,7 @@ func main() {
 			patients.GET("/:id", middleware.PermissionMiddleware("patients", "view"), handlers.GetPatient)
 			patients.PUT("/:id", middleware.PermissionMiddleware("patients", "edit"), handlers.UpdatePatient)
 			patients.DELETE("/:id", middleware.PermissionMiddleware("patients", "delete"), handlers.DeletePatient)
+			patients.GET("/:id/attendance", middleware.PermissionMiddleware("patients", "view"), handlers.GetPatientAttendance)
 			// Export/Import
 			patients.GET("/export/csv", middleware.PermissionMiddleware("patients", "view"), handlers.ExportPatientsCSV)
 			patients.POST("/import/csv", middleware.PermissionMiddleware("patients", "create"), handlers.ImportPatientsCSV)
@@ -334,10 +351,15 @@ func main() {
 		{
 			appointments.POST("", middleware.PermissionMiddleware("appointments", "create"), handlers.CreateAppointment)
 			appointments.GET("", middleware.PermissionMiddleware("appointments", "view"), handlers.GetAppointments)
+			appointments.GET("/available-slots", middleware.PermissionMiddleware("appointments", "view"), handlers.GetAvailableSlots)
+			appointments.GET("/timings", middleware.PermissionMiddleware("appointments", "view"), handlers.GetAppointmentTimings)
+			appointments.GET("/overlaps", middleware.RoleMiddleware("admin"), handlers.GetAppointmentOverlaps)
 			appointments.GET("/:id", middleware.PermissionMiddleware("appointments", "view"), handlers.GetAppointment)
 			appointments.PUT("/:id", middleware.PermissionMiddleware("appointments", "edit"), handlers.UpdateAppointment)
 			appointments.DELETE("/:id", middleware.PermissionMiddleware("appointments", "delete"), handlers.DeleteAppointment)
 			appointments.PATCH("/:id/status", middleware.PermissionMiddleware("appointments", "edit"), handlers.UpdateAppointmentStatus)
+			appointments.GET("/:id/reminders", middleware.PermissionMiddleware("appointments", "view"), handlers.GetAppointmentReminders)
+			appointments.GET("/:id/status-history", middleware.PermissionMiddleware("appointments", "view"), handlers.GetAppointmentStatusHistory)
 			// Export
 			appointments.GET("/export/csv", middleware.PermissionMiddleware("appointments", "view"), handlers.ExportAppointmentsCSV)
 			appointments.GET("/export/pdf", middleware.PermissionMiddleware("appointments", "view"), handlers.GenerateAppointmentsListPDF)
@@ -354,6 +376,45 @@ func main() {
 			medicalRecords.GET("/:id/pdf", middleware.PermissionMiddleware("medical_records", "view"), handlers.GenerateMedicalRecordPDF)
 			// Digital Signature
 			medicalRecords.POST("/:id/sign", middleware.PermissionMiddleware("medical_records", "edit"), handlers.SignMedicalRecord)
+			// Revisions (unsigned records) and addenda (signed records)
+			medicalRecords.GET("/:id/revisions", middleware.PermissionMiddleware("medical_records", "view"), handlers.GetMedicalRecordRevisions)
+			medicalRecords.GET("/:id/addenda", middleware.PermissionMiddleware("medical_records", "view"), handlers.GetMedicalRecordAddenda)
+			medicalRecords.POST("/:id/addenda", middleware.PermissionMiddleware("medical_records", "edit"), handlers.CreateMedicalRecordAddendum)
+			medicalRecords.POST("/:id/addenda/:addendum_id/sign", middleware.PermissionMiddleware("medical_records", "edit"), handlers.SignMedicalRecordAddendum)
+		}
+
+		// Clinical note templates (texts with placeholders expanded into medical records)
+		noteTemplates := tenanted.Group("/clinical-note-templates")
+		{
+			noteTemplates.POST("", middleware.PermissionMiddleware("medical_records", "create"), handlers.CreateClinicalNoteTemplate)
+			noteTemplates.GET("", middleware.PermissionMiddleware("medical_records", "view"), handlers.GetClinicalNoteTemplates)
+			noteTemplates.GET("/search", middleware.PermissionMiddleware("medical_records", "view"), handlers.SearchClinicalNoteTemplates)
+			noteTemplates.GET("/placeholders", middleware.PermissionMiddleware("medical_records", "view"), handlers.GetClinicalNotePlaceholders)
+			noteTemplates.GET("/:id", middleware.PermissionMiddleware("medical_records", "view"), handlers.GetClinicalNoteTemplate)
+			noteTemplates.PUT("/:id", middleware.PermissionMiddleware("medical_records", "edit"), handlers.UpdateClinicalNoteTemplate)
+			noteTemplates.DELETE("/:id", middleware.PermissionMiddleware("medical_records", "delete"), handlers.DeleteClinicalNoteTemplate)
+			noteTemplates.POST("/:id/expand", middleware.PermissionMiddleware("medical_records", "view"), handlers.ExpandClinicalNoteTemplate)
+		}
+
+		// Odontogram (per-tooth events; the chart is the union of all records)
+		odontogram := tenanted.Group("/odontogram")
+		{
+			odontogram.GET("/patients/:patient_id", middleware.PermissionMiddleware("medical_records", "view"), handlers.GetPatientOdontogram)
+			odontogram.GET("/patients/:patient_id/history", middleware.PermissionMiddleware("medical_records", "view"), handlers.GetToothHistory)
+			odontogram.POST("/patients/:patient_id/events", middleware.PermissionMiddleware("medical_records", "edit"), handlers.CreateToothEvents)
+			odontogram.DELETE("/events/:id", middleware.PermissionMiddleware("medical_records", "delete"), handlers.DeleteToothEvent)
+		}
+
+		// Periodontal charts (periodontogram)
+		periodontalExams := tenanted.Group("/periodontal-exams")
+		{
+			periodontalExams.POST("", middleware.PermissionMiddleware("medical_records", "create"), handlers.CreatePeriodontalExam)
+			periodontalExams.GET("", middleware.PermissionMiddleware("medical_records", "view"), handlers.GetPeriodontalExams)
+			periodontalExams.GET("/compare", middleware.PermissionMiddleware("medical_records", "view"), handlers.ComparePeriodontalExams)
+			periodontalExams.GET("/:id", middleware.PermissionMiddleware("medical_records", "view"), handlers.GetPeriodontalExam)
+			periodontalExams.PUT("/:id", middleware.PermissionMiddleware("medical_records", "edit"), handlers.UpdatePeriodontalExam)
+			periodontalExams.DELETE("/:id", middleware.PermissionMiddleware("medical_records", "delete"), handlers.DeletePeriodontalExam)
+			periodontalExams.GET("/:id/pdf", middleware.PermissionMiddleware("medical_records", "view"), handlers.GeneratePeriodontalExamPDF)
 		}
 
 		// Prescriptions CRUD (Receituário)
@@ -361,6 +422,10 @@ func main() {
 		{
 			prescriptions.POST("", middleware.PermissionMiddleware("prescriptions", "create"), handlers.CreatePrescription)
 			prescriptions.GET("", middleware.PermissionMiddleware("prescriptions", "view"), handlers.GetPrescriptions)
+			prescriptions.POST("/check", middleware.PermissionMiddleware("prescriptions", "view"), handlers.CheckPrescription)
+			prescriptions.GET("/controlled-books", middleware.PermissionMiddleware("prescriptions", "view"), handlers.GetControlledPrescriptionBooks)
+			prescriptions.GET("/controlled-books/:prescriber_id", middleware.PermissionMiddleware("prescriptions", "view"), handlers.GetControlledPrescriptionBook)
+			prescriptions.PUT("/controlled-books/:prescriber_id", middleware.PermissionMiddleware("prescriptions", "edit"), handlers.UpdateControlledPrescriptionBook)
 			prescriptions.GET("/:id", middleware.PermissionMiddleware("prescriptions", "view"), handlers.GetPrescription)
 			prescriptions.PUT("/:id", middleware.PermissionMiddleware("prescriptions", "edit"), handlers.UpdatePrescription)
 			prescriptions.DELETE("/:id", middleware.PermissionMiddleware("prescriptions", "delete"), handlers.DeletePrescription)
@@ -372,6 +437,41 @@ func main() {
 			prescriptions.GET("/:id/pdf/signed", middleware.PermissionMiddleware("prescriptions", "view"), handlers.GenerateSignedPrescriptionPDF)
 		}
 
+		// Medication catalog (structured prescription items, allergy and interaction checks)
+		medications := tenanted.Group("/medications")
+		{
+			medications.POST("", middleware.PermissionMiddleware("prescriptions", "create"), handlers.CreateMedication)
+			medications.GET("", middleware.PermissionMiddleware("prescriptions", "view"), handlers.GetMedications)
+			medications.GET("/classes", middleware.PermissionMiddleware("prescriptions", "view"), handlers.GetMedicationClasses)
+			medications.POST("/import/csv", middleware.PermissionMiddleware("prescriptions", "create"), handlers.ImportMedicationsCSV)
+			medications.GET("/:id", middleware.PermissionMiddleware("prescriptions", "view"), handlers.GetMedication)
+			medications.PUT("/:id", middleware.PermissionMiddleware("prescriptions", "edit"), handlers.UpdateMedication)
+			medications.DELETE("/:id", middleware.PermissionMiddleware("prescriptions", "delete"), handlers.DeleteMedication)
+		}
+
+		// Referrals to external specialists
+		externalSpecialists := tenanted.Group("/external-specialists")
+		{
+			externalSpecialists.POST("", middleware.PermissionMiddleware("prescriptions", "create"), handlers.CreateExternalSpecialist)
+			externalSpecialists.GET("", middleware.PermissionMiddleware("prescriptions", "view"), handlers.GetExternalSpecialists)
+			externalSpecialists.GET("/specialties", middleware.PermissionMiddleware("prescriptions", "view"), handlers.GetExternalSpecialtyNames)
+			externalSpecialists.GET("/:id", middleware.PermissionMiddleware("prescriptions", "view"), handlers.GetExternalSpecialist)
+			externalSpecialists.PUT("/:id", middleware.PermissionMiddleware("prescriptions", "edit"), handlers.UpdateExternalSpecialist)
+			externalSpecialists.DELETE("/:id", middleware.PermissionMiddleware("prescriptions", "delete"), handlers.DeleteExternalSpecialist)
+		}
+		referrals := tenanted.Group("/referrals")
+		{
+			referrals.POST("", middleware.PermissionMiddleware("prescriptions", "create"), handlers.CreateReferral)
+			referrals.GET("", middleware.PermissionMiddleware("prescriptions", "view"), handlers.GetReferrals)
+			referrals.GET("/overdue", middleware.PermissionMiddleware("prescriptions", "view"), handlers.GetOverdueReferrals)
+			referrals.GET("/:id", middleware.PermissionMiddleware("prescriptions", "view"), handlers.GetReferral)
+			referrals.PUT("/:id", middleware.PermissionMiddleware("prescriptions", "edit"), handlers.UpdateReferral)
+			referrals.PUT("/:id/status", middleware.PermissionMiddleware("prescriptions", "edit"), handlers.UpdateReferralStatus)
+			referrals.POST("/:id/report", middleware.PermissionMiddleware("prescriptions", "edit"), handlers.AttachReferralReport)
+			referrals.GET("/:id/pdf", middleware.PermissionMiddleware("prescriptions", "view"), handlers.GenerateReferralPDF)
+			referrals.DELETE("/:id", middleware.PermissionMiddleware("prescriptions", "delete"), handlers.DeleteReferral)
+		}
+
 		// Budgets CRUD
 		budgets := tenanted.Group("/budgets")
 		{
@@ -407,6 +507,70 @@ func main() {
 			payments.POST("/import/csv", middleware.PermissionMiddleware("payments", "create"), handlers.ImportPaymentsCSV)
 		}
 
+		// Professional commissions - rules, statements and payouts
+		commissions := tenanted.Group("/commissions")
+		{
+			commissions.GET("", middleware.PermissionMiddleware("payments", "view"), handlers.GetCommissions)
+			commissions.POST("/rules", middleware.PermissionMiddleware("payments", "create"), handlers.CreateCommissionRule)
+			commissions.GET("/rules", middleware.PermissionMiddleware("payments", "view"), handlers.GetCommissionRules)
+			commissions.PUT("/rules/:id", middleware.PermissionMiddleware("payments", "edit"), handlers.UpdateCommissionRule)
+			commissions.DELETE("/rules/:id", middleware.PermissionMiddleware("payments", "delete"), handlers.DeleteCommissionRule)
+			commissions.GET("/fees", middleware.PermissionMiddleware("payments", "view"), handlers.GetPaymentMethodFees)
+			commissions.PUT("/fees", middleware.PermissionMiddleware("payments", "edit"), handlers.UpdatePaymentMethodFees)
+			commissions.GET("/statement", middleware.PermissionMiddleware("payments", "view"), handlers.GetCommissionStatement)
+			commissions.GET("/statement/pdf", middleware.PermissionMiddleware("payments", "view"), handlers.GenerateCommissionStatementPDF)
+			commissions.GET("/statement/excel", middleware.PermissionMiddleware("payments", "view"), handlers.GenerateCommissionStatementExcel)
+			commissions.POST("/payouts", middleware.PermissionMiddleware("payments", "create"), handlers.CreateCommissionPayout)
+			commissions.GET("/payouts", middleware.PermissionMiddleware("payments", "view"), handlers.GetCommissionPayouts)
+			commissions.GET("/payouts/:id", middleware.PermissionMiddleware("payments", "view"), handlers.GetCommissionPayout)
+			commissions.DELETE("/payouts/:id", middleware.PermissionMiddleware("payments", "delete"), handlers.DeleteCommissionPayout)
+		}
+
+		// Insurance billing - insurers, price tables, TISS guides and lots
+		insurances := tenanted.Group("/insurances")
+		{
+			insurances.POST("", middleware.PermissionMiddleware("payments", "create"), handlers.CreateInsuranceCompany)
+			insurances.GET("", middleware.PermissionMiddleware("payments", "view"), handlers.GetInsuranceCompanies)
+			insurances.GET("/:id", middleware.PermissionMiddleware("payments", "view"), handlers.GetInsuranceCompany)
+			insurances.PUT("/:id", middleware.PermissionMiddleware("payments", "edit"), handlers.UpdateInsuranceCompany)
+			insurances.DELETE("/:id", middleware.PermissionMiddleware("payments", "delete"), handlers.DeleteInsuranceCompany)
+			insurances.GET("/:id/prices", middleware.PermissionMiddleware("payments", "view"), handlers.GetInsurancePrices)
+			insurances.PUT("/:id/prices", middleware.PermissionMiddleware("payments", "edit"), handlers.UpdateInsurancePrices)
+			insurances.DELETE("/:id/prices/:price_id", middleware.PermissionMiddleware("payments", "edit"), handlers.DeleteInsurancePrice)
+		}
+
+		insuranceGuides := tenanted.Group("/insurance-guides")
+		{
+			insuranceGuides.POST("", middleware.PermissionMiddleware("payments", "create"), handlers.CreateInsuranceGuide)
+			insuranceGuides.GET("", middleware.PermissionMiddleware("payments", "view"), handlers.GetInsuranceGuides)
+			insuranceGuides.GET("/:id", middleware.PermissionMiddleware("payments", "view"), handlers.GetInsuranceGuide)
+			insuranceGuides.PUT("/:id", middleware.PermissionMiddleware("payments", "edit"), handlers.UpdateInsuranceGuide)
+			insuranceGuides.DELETE("/:id", middleware.PermissionMiddleware("payments", "delete"), handlers.DeleteInsuranceGuide)
+		}
+
+		insuranceLots := tenanted.Group("/insurance-lots")
+		{
+			insuranceLots.POST("", middleware.PermissionMiddleware("payments", "create"), handlers.CreateInsuranceLot)
+			insuranceLots.GET("", middleware.PermissionMiddleware("payments", "view"), handlers.GetInsuranceLots)
+			insuranceLots.GET("/:id", middleware.PermissionMiddleware("payments", "view"), handlers.GetInsuranceLot)
+			insuranceLots.GET("/:id/xml", middleware.PermissionMiddleware("payments", "view"), handlers.DownloadInsuranceLotXML)
+			insuranceLots.GET("/:id/validate", middleware.PermissionMiddleware("payments", "view"), handlers.ValidateInsuranceLot)
+			insuranceLots.POST("/:id/send", middleware.PermissionMiddleware("payments", "edit"), handlers.MarkInsuranceLotSent)
+			insuranceLots.POST("/:id/return", middleware.PermissionMiddleware("payments", "edit"), handlers.ReconcileInsuranceLot)
+			insuranceLots.DELETE("/:id", middleware.PermissionMiddleware("payments", "delete"), handlers.DeleteInsuranceLot)
+		}
+
+		// PIX charges - BR Codes of payments, installments and budgets, confirmed by the PSP or the staff
+		pixCharges := tenanted.Group("/pix/charges")
+		{
+			pixCharges.POST("", middleware.PermissionMiddleware("payments", "create"), handlers.CreatePixCharge)
+			pixCharges.GET("", middleware.PermissionMiddleware("payments", "view"), handlers.GetPixCharges)
+			pixCharges.GET("/:id", middleware.PermissionMiddleware("payments", "view"), handlers.GetPixCharge)
+			pixCharges.GET("/:id/qrcode.png", middleware.PermissionMiddleware("payments", "view"), handlers.GetPixChargeQRCode)
+			pixCharges.POST("/:id/confirm", middleware.PermissionMiddleware("payments", "edit"), handlers.ConfirmPixCharge)
+			pixCharges.POST("/:id/cancel", middleware.PermissionMiddleware("payments", "edit"), handlers.CancelPixCharge)
+		}
+
 		// Products CRUD
 		products := tenanted.Group("/products")
 		{
@@ -495,6 +659,10 @@ func main() {
 			exams.PUT("/:id", middleware.PermissionMiddleware("exams", "edit"), handlers.UpdateExam)
 			exams.DELETE("/:id", middleware.PermissionMiddleware("exams", "delete"), handlers.DeleteExam)
 			exams.GET("/:id/download", middleware.PermissionMiddleware("exams", "view"), handlers.GetExamDownloadURL)
+			exams.GET("/:id/preview", middleware.PermissionMiddleware("exams", "view"), handlers.GetExamPreviewURL)
+			exams.POST("/:id/previews", middleware.PermissionMiddleware("exams", "edit"), handlers.RegenerateExamPreviews)
+			exams.GET("/:id/instances", middleware.PermissionMiddleware("exams", "view"), handlers.GetExamInstances)
+			exams.GET("/:id/dicom", middleware.PermissionMiddleware("exams", "view"), handlers.DownloadExamDICOM)
 		}
 
 		// Tasks CRUD
@@ -514,6 +682,7 @@ func main() {
 			waitingList.POST("", middleware.PermissionMiddleware("appointments", "create"), handlers.CreateWaitingListEntry)
 			waitingList.GET("", middleware.PermissionMiddleware("appointments", "view"), handlers.GetWaitingList)
 			waitingList.GET("/stats", middleware.PermissionMiddleware("appointments", "view"), handlers.GetWaitingListStats)
+			waitingList.GET("/offers", middleware.PermissionMiddleware("appointments", "view"), handlers.GetWaitingListOffers)
 			waitingList.GET("/:id", middleware.PermissionMiddleware("appointments", "view"), handlers.GetWaitingListEntry)
 			waitingList.PUT("/:id", middleware.PermissionMiddleware("appointments", "edit"), handlers.UpdateWaitingListEntry)
 			waitingList.POST("/:id/contact", middleware.PermissionMiddleware("appointments", "edit"), handlers.ContactWaitingListEntry)
@@ -521,6 +690,49 @@ func main() {
 			waitingList.DELETE("/:id", middleware.PermissionMiddleware("appointments", "delete"), handlers.DeleteWaitingListEntry)
 		}
 
+		// Professional Schedules (weekly availability, date overrides and absences - admin manages)
+		professionalSchedules := tenanted.Group("/professional-schedules")
+		{
+			professionalSchedules.GET("/:dentist_id", middleware.PermissionMiddleware("appointments", "view"), handlers.GetProfessionalSchedule)
+			professionalSchedules.GET("/:dentist_id/availability", middleware.PermissionMiddleware("appointments", "view"), handlers.GetProfessionalAvailability)
+			professionalSchedules.PUT("/:dentist_id/weekly", middleware.RoleMiddleware("admin"), handlers.UpdateProfessionalWeeklySchedule)
+			professionalSchedules.POST("/:dentist_id/overrides", middleware.RoleMiddleware("admin"), handlers.CreateProfessionalScheduleOverride)
+			professionalSchedules.DELETE("/overrides/:id", middleware.RoleMiddleware("admin"), handlers.DeleteProfessionalScheduleOverride)
+			professionalSchedules.POST("/:dentist_id/absences", middleware.RoleMiddleware("admin"), handlers.CreateProfessionalAbsence)
+			professionalSchedules.PUT("/absences/:id", middleware.RoleMiddleware("admin"), handlers.UpdateProfessionalAbsence)
+			professionalSchedules.DELETE("/absences/:id", middleware.RoleMiddleware("admin"), handlers.DeleteProfessionalAbsence)
+		}
+
+		// Rooms/chairs as bookable resources (admin manages, agenda visible to the team)
+		rooms := tenanted.Group("/rooms")
+		{
+			rooms.GET("", middleware.PermissionMiddleware("appointments", "view"), handlers.GetRooms)
+			rooms.GET("/agenda", middleware.PermissionMiddleware("appointments", "view"), handlers.GetRoomAgenda)
+			rooms.GET("/available", middleware.PermissionMiddleware("appointments", "view"), handlers.GetAvailableRooms)
+			rooms.GET("/:id", middleware.PermissionMiddleware("appointments", "view"), handlers.GetRoom)
+			rooms.POST("", middleware.RoleMiddleware("admin"), handlers.CreateRoom)
+			rooms.PUT("/:id", middleware.RoleMiddleware("admin"), handlers.UpdateRoom)
+			rooms.DELETE("/:id", middleware.RoleMiddleware("admin"), handlers.DeleteRoom)
+		}
+
+		// Clinic holidays and closures (admin manages, calendar visible to the team)
+		clinicClosures := tenanted.Group("/clinic-closures")
+		{
+			clinicClosures.GET("", middleware.PermissionMiddleware("appointments", "view"), handlers.GetClinicClosures)
+			clinicClosures.POST("", middleware.RoleMiddleware("admin"), handlers.CreateClinicClosure)
+			clinicClosures.POST("/national-holidays", middleware.RoleMiddleware("admin"), handlers.ImportNationalHolidays)
+			clinicClosures.PUT("/:id", middleware.RoleMiddleware("admin"), handlers.UpdateClinicClosure)
+			clinicClosures.DELETE("/:id", middleware.RoleMiddleware("admin"), handlers.DeleteClinicClosure)
+		}
+
+		// Calendar feeds per professional/room (admin manages, professionals see their own)
+		calendarFeeds := tenanted.Group("/calendar-feeds")
+		{
+			calendarFeeds.GET("", middleware.PermissionMiddleware("appointments", "view"), handlers.GetCalendarFeeds)
+			calendarFeeds.POST("", middleware.RoleMiddleware("admin"), handlers.CreateCalendarFeed)
+			calendarFeeds.DELETE("/:id", middleware.RoleMiddleware("admin"), handlers.RevokeCalendarFeed)
+		}
+
 		// Leads CRUD (CRM para WhatsApp e outras fontes)
 		leads := tenanted.Group("/leads")
 		{
@@ -567,6 +779,26 @@ func main() {
 			consents.DELETE("/:id", middleware.PermissionMiddleware("clinical_records", "delete"), handlers.DeleteConsent)
 		}
 
+		// Anamnesis questionnaire templates
+		anamnesisTemplates := tenanted.Group("/anamnesis-templates")
+		{
+			anamnesisTemplates.POST("", middleware.PermissionMiddleware("clinical_records", "create"), handlers.CreateAnamnesisTemplate)
+			anamnesisTemplates.GET("", middleware.PermissionMiddleware("clinical_records", "view"), handlers.GetAnamnesisTemplates)
+			anamnesisTemplates.GET("/:id", middleware.PermissionMiddleware("clinical_records", "view"), handlers.GetAnamnesisTemplate)
+			anamnesisTemplates.PUT("/:id", middleware.PermissionMiddleware("clinical_records", "edit"), handlers.UpdateAnamnesisTemplate)
+			anamnesisTemplates.DELETE("/:id", middleware.PermissionMiddleware("clinical_records", "delete"), handlers.DeleteAnamnesisTemplate)
+		}
+
+		// Filled anamnesis questionnaires
+		anamnesis := tenanted.Group("/anamnesis")
+		{
+			anamnesis.POST("", middleware.PermissionMiddleware("clinical_records", "create"), handlers.CreateAnamnesisResponse)
+			anamnesis.GET("", middleware.PermissionMiddleware("clinical_records", "view"), handlers.GetAnamnesisResponses)
+			anamnesis.GET("/:id", middleware.PermissionMiddleware("clinical_records", "view"), handlers.GetAnamnesisResponse)
+			anamnesis.GET("/:id/pdf", middleware.PermissionMiddleware("clinical_records", "view"), handlers.GenerateAnamnesisPDF)
+			anamnesis.DELETE("/:id", middleware.PermissionMiddleware("clinical_records", "delete"), handlers.DeleteAnamnesisResponse)
+		}
+
 		// Treatments CRUD (orçamentos aprovados em tratamento)
 		treatments := tenanted.Group("/treatments")
 		{
@@ -575,6 +807,9 @@ func main() {
 			treatments.GET("/:id", middleware.PermissionMiddleware("budgets", "view"), handlers.GetTreatment)
 			treatments.PUT("/:id", middleware.PermissionMiddleware("budgets", "edit"), handlers.UpdateTreatment)
 			treatments.DELETE("/:id", middleware.PermissionMiddleware("budgets", "delete"), handlers.DeleteTreatment)
+			treatments.GET("/:id/progress", middleware.PermissionMiddleware("budgets", "view"), handlers.GetTreatmentProgress)
+			treatments.GET("/:id/carne", middleware.PermissionMiddleware("payments", "view"), handlers.GenerateTreatmentCarnePDF)
+			treatments.PUT("/:id/plan-items/:item_id", middleware.PermissionMiddleware("budgets", "edit"), handlers.UpdateTreatmentPlanItem)
 		}
 
 		// Treatment Payments (pagamentos de tratamentos)
@@ -608,6 +843,7 @@ func main() {
 			whatsappBusiness.POST("/test", middleware.PermissionMiddleware("settings", "edit"), handlers.TestWhatsAppConnection)
 			whatsappBusiness.POST("/send", middleware.PermissionMiddleware("settings", "edit"), handlers.SendWhatsAppMessage)
 			whatsappBusiness.POST("/send-confirmation", middleware.PermissionMiddleware("appointments", "edit"), handlers.SendAppointmentConfirmation)
+			whatsappBusiness.GET("/reminders/preview", middleware.PermissionMiddleware("settings", "view"), handlers.GetReminderPreview)
 		}
 
 		// Embed Token Management (for Chatwell/external panels)
@@ -0,0 +1,258 @@
+// Package availability computes when a professional can be booked.
+// It is shared by the staff agenda, the patient portal and the WhatsApp API so all
+// of them offer the same slots: professional schedules, absences, clinic closures,
+// existing appointments (true interval overlap), procedure duration and buffer time.
+package availability
+
+import (
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/models"
+	"fmt"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Default values used when the clinic settings are empty
+const (
+	DefaultDurationMinutes = 30
+	defaultWorkStart       = "08:00"
+	defaultWorkEnd         = "18:00"
+)
+
+// inactiveStatuses are appointment statuses that do not occupy the agenda
+var inactiveStatuses = []string{"cancelled", "no_show"}
+
+// Location returns the clinic timezone (TZ env var, America/Sao_Paulo by default)
+func Location() *time.Location {
+	tz := os.Getenv("TZ")
+	if tz == "" {
+		tz = "America/Sao_Paulo"
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// DaySchedule is a professional's availability for a single day
+type DaySchedule struct {
+	Configured bool                // Professional has a weekly schedule or a date override
+	Working    []helpers.TimeRange // Working blocks (only meaningful when Configured)
+	Absences   []helpers.TimeRange // Absences overlapping the day
+	Closures   []Closure           // Clinic-wide holidays and closures on the day
+}
+
+// Closure is a period of the day when the whole clinic is closed
+type Closure struct {
+	helpers.TimeRange
+	Name string
+}
+
+// AvailableRanges returns the bookable ranges of the day
+// fallback (clinic-wide working hours) is used when the professional has no schedule of their own
+func (s DaySchedule) AvailableRanges(fallback []helpers.TimeRange) []helpers.TimeRange {
+	working := fallback
+	if s.Configured {
+		working = s.Working
+	}
+	blocked := append([]helpers.TimeRange{}, s.Absences...)
+	for _, closure := range s.Closures {
+		blocked = append(blocked, closure.TimeRange)
+	}
+	return helpers.SubtractTimeRanges(working, blocked)
+}
+
+// LoadDaySchedule resolves overrides, weekly template and absences for a professional on a day
+// Precedence: date overrides replace the weekly template; absences and clinic closures are always subtracted
+func LoadDaySchedule(db *gorm.DB, dentistID uint, day time.Time) (DaySchedule, error) {
+	var schedule DaySchedule
+
+	dayStart, dayEnd := dayBounds(day)
+
+	var overrides []models.ProfessionalScheduleOverride
+	if err := db.Session(&gorm.Session{NewDB: true}).Where("dentist_id = ? AND date = ?", dentistID, dayStart.Format("2006-01-02")).
+		Find(&overrides).Error; err != nil {
+		return schedule, err
+	}
+
+	if len(overrides) > 0 {
+		schedule.Configured = true
+		for _, o := range overrides {
+			if o.Closed {
+				continue
+			}
+			if r, ok := ClockRange(dayStart, o.StartTime, o.EndTime); ok {
+				schedule.Working = append(schedule.Working, r)
+			}
+		}
+	} else {
+		var blocks []models.ProfessionalSchedule
+		if err := db.Session(&gorm.Session{NewDB: true}).Where("dentist_id = ?", dentistID).
+			Find(&blocks).Error; err != nil {
+			return schedule, err
+		}
+		schedule.Configured = len(blocks) > 0
+		for _, b := range blocks {
+			if b.Weekday != int(dayStart.Weekday()) {
+				continue
+			}
+			if r, ok := ClockRange(dayStart, b.StartTime, b.EndTime); ok {
+				schedule.Working = append(schedule.Working, r)
+			}
+		}
+	}
+
+	var absences []models.ProfessionalAbsence
+	if err := db.Session(&gorm.Session{NewDB: true}).
+		Where("dentist_id = ? AND start_at < ? AND end_at > ?", dentistID, dayEnd, dayStart).
+		Find(&absences).Error; err != nil {
+		return schedule, err
+	}
+	loc := dayStart.Location()
+	for _, a := range absences {
+		schedule.Absences = append(schedule.Absences, helpers.TimeRange{Start: a.StartAt.Time.In(loc), End: a.EndAt.Time.In(loc)})
+	}
+
+	closures, err := LoadClosures(db, dayStart)
+	if err != nil {
+		return schedule, err
+	}
+	schedule.Closures = closures
+
+	return schedule, nil
+}
+
+// LoadClosures returns the clinic closures of a day as time ranges
+// Full-day closures cover the whole day; partial ones only their HH:MM period
+func LoadClosures(db *gorm.DB, day time.Time) ([]Closure, error) {
+	dayStart, dayEnd := dayBounds(day)
+
+	var entries []models.ClinicClosure
+	if err := db.Session(&gorm.Session{NewDB: true}).Where("date = ?", dayStart.Format("2006-01-02")).
+		Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	closures := make([]Closure, 0, len(entries))
+	for _, entry := range entries {
+		if entry.FullDay() {
+			closures = append(closures, Closure{TimeRange: helpers.TimeRange{Start: dayStart, End: dayEnd}, Name: entry.Name})
+			continue
+		}
+		if r, ok := ClockRange(dayStart, entry.StartTime, entry.EndTime); ok {
+			closures = append(closures, Closure{TimeRange: r, Name: entry.Name})
+		}
+	}
+	return closures, nil
+}
+
+// ClockRange builds a range from two "HH:MM" strings on the given day
+func ClockRange(day time.Time, start, end string) (helpers.TimeRange, bool) {
+	s, err := helpers.ParseClock(day, start)
+	if err != nil {
+		return helpers.TimeRange{}, false
+	}
+	e, err := helpers.ParseClock(day, end)
+	if err != nil || !e.After(s) {
+		return helpers.TimeRange{}, false
+	}
+	return helpers.TimeRange{Start: s, End: e}, true
+}
+
+// ClinicRanges returns the clinic-wide working hours of a day, minus the lunch break
+func ClinicRanges(settings models.TenantSettings, day time.Time) []helpers.TimeRange {
+	workStart := settings.WorkingHoursStart
+	workEnd := settings.WorkingHoursEnd
+	if workStart == "" {
+		workStart = defaultWorkStart
+	}
+	if workEnd == "" {
+		workEnd = defaultWorkEnd
+	}
+
+	dayStart, _ := dayBounds(day)
+	working, ok := ClockRange(dayStart, workStart, workEnd)
+	if !ok {
+		return nil
+	}
+	ranges := []helpers.TimeRange{working}
+
+	if settings.LunchBreakEnabled && settings.LunchBreakStart != "" && settings.LunchBreakEnd != "" {
+		if lunch, ok := ClockRange(dayStart, settings.LunchBreakStart, settings.LunchBreakEnd); ok {
+			ranges = helpers.SubtractTimeRanges(ranges, []helpers.TimeRange{lunch})
+		}
+	}
+	return ranges
+}
+
+// CheckProfessional verifies the clinic is open and the professional works and is not absent during [start, end)
+// Returns an empty string when available, otherwise the reason to show the user
+// Professionals without their own schedule are only restricted by absences
+func CheckProfessional(db *gorm.DB, dentistID uint, start, end time.Time) (string, error) {
+	loc := Location()
+	start = start.In(loc)
+	end = end.In(loc)
+
+	schedule, err := LoadDaySchedule(db, dentistID, start)
+	if err != nil {
+		return "", err
+	}
+
+	for _, closure := range schedule.Closures {
+		if closure.Overlaps(start, end) {
+			return fmt.Sprintf("A clínica estará fechada neste horário (%s). Por favor, escolha outro horário.", closure.Name), nil
+		}
+	}
+
+	for _, absence := range schedule.Absences {
+		if absence.Overlaps(start, end) {
+			return "O profissional estará ausente neste horário (férias ou afastamento). Por favor, escolha outro horário.", nil
+		}
+	}
+
+	if schedule.Configured && !helpers.TimeRangesContain(schedule.Working, start, end) {
+		return "Horário fora da agenda de atendimento do profissional. Por favor, escolha outro horário.", nil
+	}
+
+	return "", nil
+}
+
+// BusyRanges returns the periods already taken by the professional's appointments on a day
+// Each appointment is widened by buffer on both sides (cleanup/preparation time)
+func BusyRanges(db *gorm.DB, dentistID uint, day time.Time, buffer time.Duration, excludeIDs ...uint) ([]helpers.TimeRange, error) {
+	dayStart, dayEnd := dayBounds(day)
+
+	query := db.Session(&gorm.Session{NewDB: true}).Model(&models.Appointment{}).
+		Where("dentist_id = ?", dentistID).
+		Where("status NOT IN ?", inactiveStatuses).
+		Where("start_time < ? AND end_time > ?", models.LocalTime{Time: dayEnd.Add(buffer)}, models.LocalTime{Time: dayStart.Add(-buffer)})
+	if len(excludeIDs) > 0 {
+		query = query.Where("id NOT IN ?", excludeIDs)
+	}
+
+	var appointments []models.Appointment
+	if err := query.Find(&appointments).Error; err != nil {
+		return nil, err
+	}
+
+	loc := dayStart.Location()
+	busy := make([]helpers.TimeRange, 0, len(appointments))
+	for _, apt := range appointments {
+		busy = append(busy, helpers.TimeRange{
+			Start: apt.StartTime.Time.In(loc).Add(-buffer),
+			End:   apt.EndTime.Time.In(loc).Add(buffer),
+		})
+	}
+	return busy, nil
+}
+
+// dayBounds returns midnight of the day and of the following day in the clinic timezone
+func dayBounds(day time.Time) (time.Time, time.Time) {
+	loc := Location()
+	day = day.In(loc)
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+	return start, start.AddDate(0, 0, 1)
+}
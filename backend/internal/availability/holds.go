@@ -0,0 +1,70 @@
+package availability
+
+import (
+	"time"
+
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// heldOffers selects the waiting-list offers still holding their slot (pending and not expired)
+// The offered patient is not blocked by their own hold (patientID 0 blocks everyone)
+func heldOffers(db *gorm.DB, start, end time.Time, patientID uint) *gorm.DB {
+	query := db.Session(&gorm.Session{NewDB: true}).Model(&models.WaitingListOffer{}).
+		Where("status = ? AND expires_at > ?", models.WaitingListOfferPending, time.Now()).
+		Where("start_time < ? AND end_time > ?", models.LocalTime{Time: end}, models.LocalTime{Time: start})
+	if patientID > 0 {
+		query = query.Where("patient_id <> ?", patientID)
+	}
+	return query
+}
+
+// CheckHold verifies no other patient holds an offer for the professional or the room during [start, end)
+// Returns an empty string when the period is not held, otherwise the reason to show the user
+func CheckHold(db *gorm.DB, dentistID uint, roomID *uint, start, end time.Time, patientID uint) (string, error) {
+	query := heldOffers(db, start, end, patientID)
+	if roomID != nil {
+		query = query.Where("dentist_id = ? OR room_id = ?", dentistID, *roomID)
+	} else {
+		query = query.Where("dentist_id = ?", dentistID)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return "", err
+	}
+	if count > 0 {
+		return "Este horário está reservado para um paciente da lista de espera. Por favor, escolha outro horário.", nil
+	}
+	return "", nil
+}
+
+// HeldRanges returns the periods of a day held by waiting-list offers for the professional (or the room when roomID is set)
+// Each hold is widened by buffer on both sides, like the appointment it would become
+func HeldRanges(db *gorm.DB, dentistID, roomID uint, day time.Time, buffer time.Duration, patientID uint) ([]helpers.TimeRange, error) {
+	dayStart, dayEnd := dayBounds(day)
+
+	query := heldOffers(db, dayStart.Add(-buffer), dayEnd.Add(buffer), patientID)
+	if roomID != 0 {
+		query = query.Where("room_id = ?", roomID)
+	} else {
+		query = query.Where("dentist_id = ?", dentistID)
+	}
+
+	var offers []models.WaitingListOffer
+	if err := query.Find(&offers).Error; err != nil {
+		return nil, err
+	}
+
+	loc := dayStart.Location()
+	held := make([]helpers.TimeRange, 0, len(offers))
+	for _, offer := range offers {
+		held = append(held, helpers.TimeRange{
+			Start: offer.StartTime.Time.In(loc).Add(-buffer),
+			End:   offer.EndTime.Time.In(loc).Add(buffer),
+		})
+	}
+	return held, nil
+}
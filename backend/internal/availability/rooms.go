@@ -0,0 +1,178 @@
+package availability
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ParseEquipment decodes a JSON array of equipment names, normalized for comparison
+// Invalid or empty input yields no equipment
+func ParseEquipment(raw string) []string {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+	var names []string
+	if err := json.Unmarshal([]byte(raw), &names); err != nil {
+		return nil
+	}
+	equipment := make([]string, 0, len(names))
+	for _, name := range names {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			equipment = append(equipment, name)
+		}
+	}
+	return equipment
+}
+
+// MissingEquipment returns the required items the room does not have
+func MissingEquipment(room models.Room, required []string) []string {
+	if len(required) == 0 {
+		return nil
+	}
+	available := map[string]bool{}
+	if room.Equipment != nil {
+		for _, name := range ParseEquipment(*room.Equipment) {
+			available[name] = true
+		}
+	}
+	var missing []string
+	for _, name := range required {
+		if !available[name] {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// RequiredEquipment returns the equipment needed by a procedure, taken from its treatment protocol
+func RequiredEquipment(db *gorm.DB, procedure string) []string {
+	protocol, ok := FindProtocol(db, 0, procedure)
+	if !ok {
+		return nil
+	}
+	return ParseEquipment(protocol.RequiredEquipment)
+}
+
+// CheckRoom verifies the room exists, has the equipment the procedure needs and is free during [start, end)
+// Returns an empty string when the room can be booked, otherwise the reason to show the user
+func CheckRoom(db *gorm.DB, roomID uint, start, end time.Time, procedure string, excludeIDs ...uint) (string, error) {
+	var room models.Room
+	if err := db.Session(&gorm.Session{NewDB: true}).Where("id = ? AND active = ?", roomID, true).First(&room).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return "Sala/cadeira não encontrada ou inativa.", nil
+		}
+		return "", err
+	}
+
+	if missing := MissingEquipment(room, RequiredEquipment(db, procedure)); len(missing) > 0 {
+		return fmt.Sprintf("A sala %s não possui o equipamento necessário para o procedimento: %s.", room.Name, strings.Join(missing, ", ")), nil
+	}
+
+	busy, err := roomBusy(db, roomID, start, end, excludeIDs)
+	if err != nil {
+		return "", err
+	}
+	if busy {
+		return fmt.Sprintf("A sala %s já está ocupada neste horário. Por favor, escolha outra sala ou horário.", room.Name), nil
+	}
+	return "", nil
+}
+
+// FreeRooms returns the active rooms that are free during [start, end) and equipped for the procedure
+func FreeRooms(db *gorm.DB, start, end time.Time, procedure string, excludeIDs ...uint) ([]models.Room, error) {
+	var rooms []models.Room
+	if err := db.Session(&gorm.Session{NewDB: true}).Where("active = ?", true).Order("name ASC").Find(&rooms).Error; err != nil {
+		return nil, err
+	}
+
+	required := RequiredEquipment(db, procedure)
+	free := make([]models.Room, 0, len(rooms))
+	for _, room := range rooms {
+		if len(MissingEquipment(room, required)) > 0 {
+			continue
+		}
+		busy, err := roomBusy(db, room.ID, start, end, excludeIDs)
+		if err != nil {
+			return nil, err
+		}
+		if !busy {
+			free = append(free, room)
+		}
+	}
+	return free, nil
+}
+
+// candidateRoomsBusy returns the busy ranges on a day of the rooms a slot search can use: the
+// requested room, or every active room with the required equipment
+func candidateRoomsBusy(db *gorm.DB, day time.Time, opts SlotOptions) ([][]helpers.TimeRange, error) {
+	query := db.Session(&gorm.Session{NewDB: true}).Where("active = ?", true)
+	if opts.RoomID != 0 {
+		query = query.Where("id = ?", opts.RoomID)
+	}
+	var rooms []models.Room
+	if err := query.Find(&rooms).Error; err != nil {
+		return nil, err
+	}
+
+	roomsBusy := make([][]helpers.TimeRange, 0, len(rooms))
+	for _, room := range rooms {
+		if len(MissingEquipment(room, opts.Equipment)) > 0 {
+			continue
+		}
+		busy, err := RoomBusyRanges(db, room.ID, day)
+		if err != nil {
+			return nil, err
+		}
+		held, err := HeldRanges(db, 0, room.ID, day, 0, opts.PatientID)
+		if err != nil {
+			return nil, err
+		}
+		roomsBusy = append(roomsBusy, append(busy, held...))
+	}
+	return roomsBusy, nil
+}
+
+// RoomBusyRanges returns the periods already taken by the room's appointments on a day
+func RoomBusyRanges(db *gorm.DB, roomID uint, day time.Time) ([]helpers.TimeRange, error) {
+	dayStart, dayEnd := dayBounds(day)
+
+	var appointments []models.Appointment
+	if err := db.Session(&gorm.Session{NewDB: true}).Model(&models.Appointment{}).
+		Where("room_id = ?", roomID).
+		Where("status NOT IN ?", inactiveStatuses).
+		Where("start_time < ? AND end_time > ?", models.LocalTime{Time: dayEnd}, models.LocalTime{Time: dayStart}).
+		Find(&appointments).Error; err != nil {
+		return nil, err
+	}
+
+	loc := dayStart.Location()
+	busy := make([]helpers.TimeRange, 0, len(appointments))
+	for _, apt := range appointments {
+		busy = append(busy, helpers.TimeRange{Start: apt.StartTime.Time.In(loc), End: apt.EndTime.Time.In(loc)})
+	}
+	return busy, nil
+}
+
+// roomBusy reports whether any active appointment in the room overlaps [start, end)
+func roomBusy(db *gorm.DB, roomID uint, start, end time.Time, excludeIDs []uint) (bool, error) {
+	query := db.Session(&gorm.Session{NewDB: true}).Model(&models.Appointment{}).
+		Where("room_id = ?", roomID).
+		Where("status NOT IN ?", inactiveStatuses).
+		Where("start_time < ? AND end_time > ?", models.LocalTime{Time: end}, models.LocalTime{Time: start})
+	if len(excludeIDs) > 0 {
+		query = query.Where("id NOT IN ?", excludeIDs)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,37 @@
+package availability
+
+import (
+	"testing"
+
+	"drcrwell/backend/internal/models"
+)
+
+func TestParseEquipmentNormalizes(t *testing.T) {
+	got := ParseEquipment(`[" Raio-X ", "LASER", ""]`)
+	if len(got) != 2 || got[0] != "raio-x" || got[1] != "laser" {
+		t.Fatalf("Expected [raio-x laser], got %v", got)
+	}
+
+	if got := ParseEquipment("not json"); got != nil {
+		t.Fatalf("Expected nil for invalid input, got %v", got)
+	}
+}
+
+func TestMissingEquipment(t *testing.T) {
+	equipment := `["raio-x", "autoclave"]`
+	room := models.Room{Name: "Cadeira 1", Equipment: &equipment}
+
+	if missing := MissingEquipment(room, []string{"raio-x"}); len(missing) != 0 {
+		t.Fatalf("Expected no missing equipment, got %v", missing)
+	}
+
+	missing := MissingEquipment(room, []string{"raio-x", "laser"})
+	if len(missing) != 1 || missing[0] != "laser" {
+		t.Fatalf("Expected [laser], got %v", missing)
+	}
+
+	// A room without equipment only serves procedures with no requirements
+	if missing := MissingEquipment(models.Room{}, []string{"laser"}); len(missing) != 1 {
+		t.Fatalf("Expected [laser], got %v", missing)
+	}
+}
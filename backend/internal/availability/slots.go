@@ -0,0 +1,160 @@
+package availability
+
+import (
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SlotOptions describes which slots are being searched for
+type SlotOptions struct {
+	Duration  time.Duration // Length of the appointment being booked
+	Step      time.Duration // Grid between candidate start times
+	Buffer    time.Duration // Free time kept before and after existing appointments
+	NotBefore time.Time     // Slots starting before this instant are not offered (zero = no limit)
+	RoomID    uint          // Slots must also leave this room free (0 = no room requested)
+	Equipment []string      // Without RoomID: slots need a free room with this equipment
+	PatientID uint          // Patient booking; their own waiting-list holds do not block slots (0 = unknown)
+}
+
+// Slot is a bookable period
+type Slot struct {
+	Start time.Time
+	End   time.Time
+}
+
+// OptionsFromSettings builds the slot options for a clinic
+// duration is the procedure length (zero uses the clinic default); the grid follows the clinic default duration
+func OptionsFromSettings(settings models.TenantSettings, duration time.Duration) SlotOptions {
+	step := time.Duration(settings.DefaultAppointmentDuration) * time.Minute
+	if step <= 0 {
+		step = DefaultDurationMinutes * time.Minute
+	}
+	if duration <= 0 {
+		duration = step
+	}
+	buffer := time.Duration(settings.AppointmentBufferMinutes) * time.Minute
+	if buffer < 0 {
+		buffer = 0
+	}
+	return SlotOptions{
+		Duration:  duration,
+		Step:      step,
+		Buffer:    buffer,
+		NotBefore: time.Now(),
+	}
+}
+
+// ComputeSlots walks each free range on the step grid and returns every candidate of the
+// requested duration that does not overlap a busy range
+// Busy ranges must already include any buffer time
+func ComputeSlots(working, busy []helpers.TimeRange, opts SlotOptions) []Slot {
+	if opts.Duration <= 0 || opts.Step <= 0 {
+		return nil
+	}
+
+	var slots []Slot
+	for _, r := range helpers.MergeTimeRanges(working) {
+		for start := r.Start; !start.Add(opts.Duration).After(r.End); start = start.Add(opts.Step) {
+			end := start.Add(opts.Duration)
+			if !opts.NotBefore.IsZero() && start.Before(opts.NotBefore) {
+				continue
+			}
+			if overlapsAny(busy, start, end) {
+				continue
+			}
+			slots = append(slots, Slot{Start: start, End: end})
+		}
+	}
+	return slots
+}
+
+// FindSlots returns the free slots of a professional on a day
+// It combines the professional schedule (or clinic hours), absences, existing appointments
+// and the slots held by waiting-list offers for other patients
+func FindSlots(db *gorm.DB, settings models.TenantSettings, dentistID uint, day time.Time, opts SlotOptions) ([]Slot, error) {
+	schedule, err := LoadDaySchedule(db, dentistID, day)
+	if err != nil {
+		return nil, err
+	}
+
+	working := schedule.AvailableRanges(ClinicRanges(settings, day))
+	if len(working) == 0 {
+		return nil, nil
+	}
+
+	busy, err := BusyRanges(db, dentistID, day, opts.Buffer)
+	if err != nil {
+		return nil, err
+	}
+	held, err := HeldRanges(db, dentistID, 0, day, opts.Buffer, opts.PatientID)
+	if err != nil {
+		return nil, err
+	}
+	busy = append(busy, held...)
+
+	slots := ComputeSlots(working, busy, opts)
+	if len(slots) == 0 || (opts.RoomID == 0 && len(opts.Equipment) == 0) {
+		return slots, nil
+	}
+	roomsBusy, err := candidateRoomsBusy(db, day, opts)
+	if err != nil {
+		return nil, err
+	}
+	return FilterSlotsByRooms(slots, roomsBusy), nil
+}
+
+// FilterSlotsByRooms keeps the slots in which at least one of the candidate rooms is free
+// roomsBusy holds the busy ranges of each candidate room
+func FilterSlotsByRooms(slots []Slot, roomsBusy [][]helpers.TimeRange) []Slot {
+	free := make([]Slot, 0, len(slots))
+	for _, slot := range slots {
+		for _, busy := range roomsBusy {
+			if !overlapsAny(busy, slot.Start, slot.End) {
+				free = append(free, slot)
+				break
+			}
+		}
+	}
+	return free
+}
+
+// ResolveDuration returns how long an appointment for the given procedure takes
+// A treatment protocol (by ID, or by name matching the procedure) defines the duration;
+// otherwise the clinic default duration is used
+func ResolveDuration(db *gorm.DB, settings models.TenantSettings, protocolID uint, procedure string) time.Duration {
+	if protocol, ok := FindProtocol(db, protocolID, procedure); ok && protocol.Duration > 0 {
+		return time.Duration(protocol.Duration) * time.Minute
+	}
+	if settings.DefaultAppointmentDuration > 0 {
+		return time.Duration(settings.DefaultAppointmentDuration) * time.Minute
+	}
+	return DefaultDurationMinutes * time.Minute
+}
+
+// FindProtocol looks up the active treatment protocol by ID, or by a name matching the procedure
+func FindProtocol(db *gorm.DB, protocolID uint, procedure string) (models.TreatmentProtocol, bool) {
+	var protocol models.TreatmentProtocol
+	if protocolID > 0 {
+		err := db.Session(&gorm.Session{NewDB: true}).Where("id = ? AND active = ?", protocolID, true).First(&protocol).Error
+		return protocol, err == nil
+	}
+	if name := strings.TrimSpace(procedure); name != "" {
+		err := db.Session(&gorm.Session{NewDB: true}).Where("LOWER(name) = LOWER(?) AND active = ?", name, true).First(&protocol).Error
+		return protocol, err == nil
+	}
+	return protocol, false
+}
+
+// overlapsAny reports whether [start, end) intersects any of the ranges
+func overlapsAny(ranges []helpers.TimeRange, start, end time.Time) bool {
+	for _, r := range ranges {
+		if r.Overlaps(start, end) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package availability
+
+import (
+	"testing"
+	"time"
+
+	"drcrwell/backend/internal/helpers"
+)
+
+func at(h, m int) time.Time {
+	return time.Date(2026, 3, 2, h, m, 0, 0, time.UTC)
+}
+
+func slotStarts(slots []Slot) []string {
+	starts := make([]string, len(slots))
+	for i, s := range slots {
+		starts[i] = s.Start.Format("15:04")
+	}
+	return starts
+}
+
+func assertStarts(t *testing.T, slots []Slot, expected []string) {
+	t.Helper()
+	got := slotStarts(slots)
+	if len(got) != len(expected) {
+		t.Fatalf("Expected slots %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("Expected slots %v, got %v", expected, got)
+		}
+	}
+}
+
+func TestComputeSlotsOffGridAppointment(t *testing.T) {
+	working := []helpers.TimeRange{{Start: at(8, 0), End: at(12, 0)}}
+	// 90-minute appointment starting off the 30-minute grid
+	busy := []helpers.TimeRange{{Start: at(9, 15), End: at(10, 45)}}
+
+	slots := ComputeSlots(working, busy, SlotOptions{Duration: 30 * time.Minute, Step: 30 * time.Minute})
+	assertStarts(t, slots, []string{"08:00", "08:30", "11:00", "11:30"})
+}
+
+func TestComputeSlotsProcedureDuration(t *testing.T) {
+	working := []helpers.TimeRange{{Start: at(8, 0), End: at(12, 0)}}
+	busy := []helpers.TimeRange{{Start: at(10, 0), End: at(10, 30)}}
+
+	// A 90-minute procedure must fit entirely before or after the booked appointment
+	slots := ComputeSlots(working, busy, SlotOptions{Duration: 90 * time.Minute, Step: 30 * time.Minute})
+	assertStarts(t, slots, []string{"08:00", "08:30", "10:30"})
+}
+
+func TestComputeSlotsBuffer(t *testing.T) {
+	working := []helpers.TimeRange{{Start: at(8, 0), End: at(11, 0)}}
+	// 09:00-09:30 appointment widened by a 15-minute buffer
+	buffer := 15 * time.Minute
+	busy := []helpers.TimeRange{{Start: at(9, 0).Add(-buffer), End: at(9, 30).Add(buffer)}}
+
+	slots := ComputeSlots(working, busy, SlotOptions{Duration: 30 * time.Minute, Step: 15 * time.Minute, Buffer: buffer})
+	assertStarts(t, slots, []string{"08:00", "08:15", "09:45", "10:00", "10:15", "10:30"})
+}
+
+func TestComputeSlotsNotBefore(t *testing.T) {
+	working := []helpers.TimeRange{{Start: at(8, 0), End: at(10, 0)}}
+
+	slots := ComputeSlots(working, nil, SlotOptions{Duration: 30 * time.Minute, Step: 30 * time.Minute, NotBefore: at(8, 40)})
+	assertStarts(t, slots, []string{"09:00", "09:30"})
+}
+
+func TestComputeSlotsSplitRanges(t *testing.T) {
+	// Morning and afternoon blocks - a slot never crosses the lunch gap
+	working := []helpers.TimeRange{
+		{Start: at(8, 0), End: at(12, 0)},
+		{Start: at(13, 0), End: at(14, 0)},
+	}
+
+	slots := ComputeSlots(working, nil, SlotOptions{Duration: 60 * time.Minute, Step: 30 * time.Minute})
+	assertStarts(t, slots, []string{"08:00", "08:30", "09:00", "09:30", "10:00", "10:30", "11:00", "13:00"})
+}
+
+func TestAvailableRangesClosures(t *testing.T) {
+	clinic := []helpers.TimeRange{{Start: at(8, 0), End: at(18, 0)}}
+
+	// Partial closure (afternoon) applies even to professionals following clinic hours
+	schedule := DaySchedule{Closures: []Closure{{TimeRange: helpers.TimeRange{Start: at(13, 0), End: at(18, 0)}, Name: "Quarta-feira de Cinzas"}}}
+	ranges := schedule.AvailableRanges(clinic)
+	if len(ranges) != 1 || !ranges[0].End.Equal(at(13, 0)) {
+		t.Fatalf("Expected 08:00-13:00, got %v", ranges)
+	}
+
+	// Full-day closure removes every working block
+	schedule = DaySchedule{
+		Configured: true,
+		Working:    []helpers.TimeRange{{Start: at(8, 0), End: at(12, 0)}},
+		Closures:   []Closure{{TimeRange: helpers.TimeRange{Start: at(0, 0), End: at(23, 59)}, Name: "Natal"}},
+	}
+	if ranges := schedule.AvailableRanges(clinic); len(ranges) != 0 {
+		t.Fatalf("Expected no ranges on a closed day, got %v", ranges)
+	}
+}
+
+func TestFilterSlotsByRooms(t *testing.T) {
+	slots := []Slot{
+		{Start: at(8, 0), End: at(8, 30)},
+		{Start: at(8, 30), End: at(9, 0)},
+		{Start: at(9, 0), End: at(9, 30)},
+	}
+	roomA := []helpers.TimeRange{{Start: at(8, 0), End: at(9, 0)}}
+	roomB := []helpers.TimeRange{{Start: at(8, 30), End: at(9, 30)}}
+
+	// Any free room is enough
+	assertStarts(t, FilterSlotsByRooms(slots, [][]helpers.TimeRange{roomA, roomB}), []string{"08:00", "09:00"})
+	// Single requested room
+	assertStarts(t, FilterSlotsByRooms(slots, [][]helpers.TimeRange{roomA}), []string{"09:00"})
+	// No room with the equipment
+	assertStarts(t, FilterSlotsByRooms(slots, nil), []string{})
+}
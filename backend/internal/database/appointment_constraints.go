@@ -0,0 +1,225 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+
+	"drcrwell/backend/internal/models"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Exclusion constraints that keep the agenda free of double bookings even under concurrent requests
+// (portal + WhatsApp bot booking the same slot). The application checks stay in place to
+// produce friendly messages; these constraints are the final guarantee.
+// They are deferred to commit so a transaction can move several occurrences of a series past each other.
+const (
+	AppointmentDentistOverlapConstraint = "appointments_no_dentist_overlap"
+	AppointmentRoomOverlapConstraint    = "appointments_no_room_overlap"
+
+	// pgExclusionViolation is the SQLSTATE raised when an EXCLUDE constraint rejects a row
+	pgExclusionViolation = "23P01"
+
+	// overlapReportLimit caps the overlapping pairs listed per constraint
+	overlapReportLimit = 200
+	// overlapLogLimit caps the pairs written to the startup log per constraint
+	overlapLogLimit = 10
+)
+
+// appointmentOverlapConstraints are the exclusion constraints with the column that must not be double booked
+var appointmentOverlapConstraints = []struct {
+	name     string
+	column   string
+	roomOnly bool
+}{
+	{AppointmentDentistOverlapConstraint, "dentist_id", false},
+	{AppointmentRoomOverlapConstraint, "room_id", true},
+}
+
+// AppointmentOverlap is a pair of active appointments that double book a professional or a room
+type AppointmentOverlap struct {
+	Constraint        string           `json:"constraint"`
+	ResourceID        uint             `json:"resource_id"` // Professional (dentist constraint) or room (room constraint)
+	FirstID           uint             `json:"first_id"`
+	FirstPatientName  string           `json:"first_patient_name"`
+	FirstStart        models.LocalTime `json:"first_start"`
+	FirstEnd          models.LocalTime `json:"first_end"`
+	SecondID          uint             `json:"second_id"`
+	SecondPatientName string           `json:"second_patient_name"`
+	SecondStart       models.LocalTime `json:"second_start"`
+	SecondEnd         models.LocalTime `json:"second_end"`
+}
+
+// AppointmentConstraintStatus tells whether an exclusion constraint is in place and how many overlaps block it
+type AppointmentConstraintStatus struct {
+	Name     string `json:"name"`
+	Applied  bool   `json:"applied"`
+	Overlaps int    `json:"overlaps"`
+}
+
+// appointmentPeriod is the half-open period of an appointment as a tstzrange
+// start_time/end_time are timestamps without time zone; reading both in the same fixed zone keeps
+// the overlap semantics and makes the expression immutable (required for the index).
+// GREATEST protects against legacy rows whose end is before their start.
+const appointmentPeriod = `tstzrange(start_time AT TIME ZONE 'UTC', GREATEST(start_time, end_time) AT TIME ZONE 'UTC', '[)')`
+
+// activeAppointmentsFilter matches the appointments that occupy the agenda (same rule as checkAppointmentConflict)
+// alias qualifies the columns for self-joins; roomOnly restricts it to appointments with a room
+func activeAppointmentsFilter(alias string, roomOnly bool) string {
+	prefix := ""
+	if alias != "" {
+		prefix = alias + "."
+	}
+	filter := fmt.Sprintf("%[1]sdeleted_at IS NULL AND %[1]sstatus NOT IN ('cancelled', 'no_show')", prefix)
+	if roomOnly {
+		filter += fmt.Sprintf(" AND %sroom_id IS NOT NULL", prefix)
+	}
+	return filter
+}
+
+var btreeGistOnce sync.Once
+
+// ensureBtreeGist installs the extension that allows "dentist_id WITH =" inside a GiST exclusion constraint
+func ensureBtreeGist() {
+	btreeGistOnce.Do(func() {
+		if err := DB.Exec("CREATE EXTENSION IF NOT EXISTS btree_gist").Error; err != nil {
+			log.Printf("Warning: Could not create extension btree_gist: %v", err)
+		}
+	})
+}
+
+// validSchemaName guards the schema names interpolated into the constraint statements
+var validSchemaName = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+
+// ApplyAppointmentOverlapConstraints adds the double-booking exclusion constraints to a tenant schema
+// Existing schemas are backfilled on startup; schemas that already contain overlapping appointments
+// are reported (the pairs are logged and listed by GET /appointments/overlaps) and retried on the
+// next startup or listing, once the conflicts are resolved
+// Returns the state of each constraint
+func ApplyAppointmentOverlapConstraints(schemaName string) ([]AppointmentConstraintStatus, error) {
+	if !validSchemaName.MatchString(schemaName) {
+		return nil, fmt.Errorf("invalid schema name: %s", schemaName)
+	}
+
+	ensureBtreeGist()
+
+	statuses := make([]AppointmentConstraintStatus, 0, len(appointmentOverlapConstraints))
+	for _, constraint := range appointmentOverlapConstraints {
+		status := AppointmentConstraintStatus{Name: constraint.name}
+
+		applied, err := appointmentConstraintExists(schemaName, constraint.name)
+		if err != nil {
+			return nil, err
+		}
+		if applied {
+			status.Applied = true
+			statuses = append(statuses, status)
+			continue
+		}
+
+		// Backfill check: the constraint cannot be created while overlaps exist
+		overlaps, err := findOverlaps(schemaName, constraint.name, constraint.column, constraint.roomOnly)
+		if err != nil {
+			return nil, err
+		}
+		if len(overlaps) > 0 {
+			status.Overlaps = len(overlaps)
+			statuses = append(statuses, status)
+			log.Printf("Warning: %s has %d overlapping appointment pair(s); constraint %s not applied until they are resolved: %s",
+				schemaName, len(overlaps), constraint.name, summarizeOverlaps(overlaps))
+			continue
+		}
+
+		if err := DB.Exec(fmt.Sprintf(`
+			ALTER TABLE %s.appointments ADD CONSTRAINT %s
+			EXCLUDE USING gist (%s WITH =, %s WITH &&)
+			WHERE (%s)
+			DEFERRABLE INITIALLY DEFERRED
+		`, schemaName, constraint.name, constraint.column, appointmentPeriod, activeAppointmentsFilter("", constraint.roomOnly))).Error; err != nil {
+			log.Printf("Warning: Could not create constraint %s in %s: %v", constraint.name, schemaName, err)
+		} else {
+			status.Applied = true
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// FindAppointmentOverlaps lists the active appointments of a tenant schema that double book a professional or a room
+func FindAppointmentOverlaps(schemaName string) ([]AppointmentOverlap, error) {
+	if !validSchemaName.MatchString(schemaName) {
+		return nil, fmt.Errorf("invalid schema name: %s", schemaName)
+	}
+
+	var all []AppointmentOverlap
+	for _, constraint := range appointmentOverlapConstraints {
+		overlaps, err := findOverlaps(schemaName, constraint.name, constraint.column, constraint.roomOnly)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, overlaps...)
+	}
+	return all, nil
+}
+
+// appointmentConstraintExists reports whether the constraint is already defined in the schema
+func appointmentConstraintExists(schemaName, name string) (bool, error) {
+	var exists int64
+	if err := DB.Raw(`
+		SELECT COUNT(*) FROM pg_constraint c
+		JOIN pg_namespace n ON n.oid = c.connamespace
+		WHERE n.nspname = ? AND c.conname = ?
+	`, schemaName, name).Scan(&exists).Error; err != nil {
+		return false, err
+	}
+	return exists > 0, nil
+}
+
+// findOverlaps returns the pairs of active appointments sharing the column value with overlapping periods
+func findOverlaps(schemaName, constraint, column string, roomOnly bool) ([]AppointmentOverlap, error) {
+	var overlaps []AppointmentOverlap
+	err := DB.Raw(fmt.Sprintf(`
+		SELECT ? as "constraint", a.%[2]s as resource_id,
+			a.id as first_id, COALESCE(pa.name, '') as first_patient_name, a.start_time as first_start, a.end_time as first_end,
+			b.id as second_id, COALESCE(pb.name, '') as second_patient_name, b.start_time as second_start, b.end_time as second_end
+		FROM %[1]s.appointments a
+		JOIN %[1]s.appointments b ON a.%[2]s = b.%[2]s AND a.id < b.id
+		LEFT JOIN %[1]s.patients pa ON pa.id = a.patient_id
+		LEFT JOIN %[1]s.patients pb ON pb.id = b.patient_id
+		WHERE %[3]s AND %[4]s
+		AND a.start_time < b.end_time AND a.end_time > b.start_time
+		AND a.end_time > a.start_time AND b.end_time > b.start_time
+		ORDER BY a.start_time, a.id, b.id
+		LIMIT %[5]d
+	`, schemaName, column, activeAppointmentsFilter("a", roomOnly), activeAppointmentsFilter("b", roomOnly), overlapReportLimit),
+		constraint).Scan(&overlaps).Error
+	return overlaps, err
+}
+
+// summarizeOverlaps formats the first pairs for the startup log
+func summarizeOverlaps(overlaps []AppointmentOverlap) string {
+	pairs := make([]string, 0, overlapLogLimit)
+	for i, overlap := range overlaps {
+		if i == overlapLogLimit {
+			pairs = append(pairs, fmt.Sprintf("and %d more", len(overlaps)-overlapLogLimit))
+			break
+		}
+		pairs = append(pairs, fmt.Sprintf("#%d x #%d (%s)", overlap.FirstID, overlap.SecondID, overlap.FirstStart.Time.Format("2006-01-02 15:04")))
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// AppointmentOverlapViolation reports whether err is a double-booking rejected by the database
+// Returns the violated constraint name (dentist or room) when it is
+func AppointmentOverlapViolation(err error) (string, bool) {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != pgExclusionViolation {
+		return "", false
+	}
+	return pgErr.ConstraintName, true
+}
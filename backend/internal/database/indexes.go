@@ -40,6 +40,7 @@ func ApplyTenantIndexes(schemaName string) error {
 		"CREATE INDEX IF NOT EXISTS idx_prescriptions_dentist ON prescriptions(dentist_id)",
 		"CREATE INDEX IF NOT EXISTS idx_prescriptions_status ON prescriptions(status)",
 		"CREATE INDEX IF NOT EXISTS idx_prescriptions_signed ON prescriptions(is_signed) WHERE is_signed = true",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_prescriptions_controlled_number ON prescriptions(controlled_prescriber_id, controlled_number) WHERE controlled_number IS NOT NULL",
 
 		// Budgets
 		"CREATE INDEX IF NOT EXISTS idx_budgets_patient ON budgets(patient_id)",
@@ -130,6 +131,71 @@ func ApplyTenantIndexes(schemaName string) error {
 		"CREATE INDEX IF NOT EXISTS idx_campaign_recipients_campaign ON campaign_recipients(campaign_id)",
 		"CREATE INDEX IF NOT EXISTS idx_campaign_recipients_status ON campaign_recipients(status)",
 
+		// Appointment Reminders - dispatcher lookups and pending window
+		"CREATE INDEX IF NOT EXISTS idx_appointment_reminders_appointment ON appointment_reminders(appointment_id)",
+		"CREATE INDEX IF NOT EXISTS idx_appointments_reminder_pending ON appointments(start_time) WHERE reminder_sent = false AND deleted_at IS NULL",
+
+		// Professional schedules - availability lookups per dentist and day
+		"CREATE INDEX IF NOT EXISTS idx_professional_schedules_dentist_weekday ON professional_schedules(dentist_id, weekday) WHERE deleted_at IS NULL",
+		"CREATE INDEX IF NOT EXISTS idx_professional_schedule_overrides_dentist_date ON professional_schedule_overrides(dentist_id, date) WHERE deleted_at IS NULL",
+		"CREATE INDEX IF NOT EXISTS idx_professional_absences_dentist_period ON professional_absences(dentist_id, start_at, end_at) WHERE deleted_at IS NULL",
+
+		// Waiting list offers - live offer per slot and expiry sweep
+		"CREATE INDEX IF NOT EXISTS idx_waiting_list_offers_source ON waiting_list_offers(source_appointment_id, status) WHERE deleted_at IS NULL",
+		"CREATE INDEX IF NOT EXISTS idx_waiting_list_offers_pending ON waiting_list_offers(expires_at) WHERE status = 'pending' AND deleted_at IS NULL",
+
+		// Appointment status history - timeline per appointment
+		"CREATE INDEX IF NOT EXISTS idx_appointment_status_history_appointment ON appointment_status_history(appointment_id, changed_at)",
+
+		// Odontogram - chart replay per patient
+		"CREATE INDEX IF NOT EXISTS idx_tooth_events_patient_recorded ON tooth_events(patient_id, recorded_at) WHERE deleted_at IS NULL",
+
+		// Periodontal exams - patient history and comparison
+		"CREATE INDEX IF NOT EXISTS idx_periodontal_exams_patient_date ON periodontal_exams(patient_id, exam_date DESC) WHERE deleted_at IS NULL",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_periodontal_teeth_exam_tooth ON periodontal_teeth(exam_id, tooth)",
+
+		// Medical record revisions - one number per change
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_medical_record_revisions_number ON medical_record_revisions(medical_record_id, revision)",
+
+		// Anamnesis - questionnaires filled per patient and ordered questions
+		"CREATE INDEX IF NOT EXISTS idx_anamnesis_responses_patient_filled ON anamnesis_responses(patient_id, filled_at DESC) WHERE deleted_at IS NULL",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_anamnesis_questions_template_key ON anamnesis_questions(template_id, key)",
+
+		// Clinical note templates - quick insertion by shortcut
+		"CREATE INDEX IF NOT EXISTS idx_clinical_note_templates_shortcut ON clinical_note_templates(shortcut, owner_id) WHERE deleted_at IS NULL AND active = true",
+
+		// Medication catalog - search by name and active ingredient
+		"CREATE INDEX IF NOT EXISTS idx_medications_name ON medications(LOWER(name)) WHERE deleted_at IS NULL",
+
+		// Treatment plan items - ordered per treatment
+		"CREATE INDEX IF NOT EXISTS idx_treatment_plan_items_treatment ON treatment_plan_items(treatment_id, position) WHERE deleted_at IS NULL",
+
+		// DICOM studies - one exam per study and patient, one row per instance
+		"CREATE INDEX IF NOT EXISTS idx_exams_patient_study ON exams(patient_id, study_instance_uid) WHERE study_instance_uid <> '' AND deleted_at IS NULL",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_exam_instances_exam_sop ON exam_instances(exam_id, sop_instance_uid) WHERE deleted_at IS NULL",
+
+		// Referrals - overdue worklist and patient history
+		"CREATE INDEX IF NOT EXISTS idx_referrals_open_follow_up ON referrals(follow_up_date) WHERE status IN ('sent', 'scheduled') AND deleted_at IS NULL",
+		"CREATE INDEX IF NOT EXISTS idx_referrals_patient_sent ON referrals(patient_id, sent_at DESC) WHERE deleted_at IS NULL",
+
+		// Commissions - statements per period and reversals by origin
+		"CREATE INDEX IF NOT EXISTS idx_commissions_dentist_reference ON commissions(dentist_id, reference_date) WHERE deleted_at IS NULL",
+		"CREATE INDEX IF NOT EXISTS idx_commission_rules_active ON commission_rules(trigger) WHERE active = true AND deleted_at IS NULL",
+
+		// Insurance billing - price lookup, guides to bill and lots awaiting the payment return
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_insurance_prices_code ON insurance_prices(insurance_id, procedure_code) WHERE deleted_at IS NULL",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_insurance_guides_number ON insurance_guides(insurance_id, guide_number) WHERE deleted_at IS NULL",
+		"CREATE INDEX IF NOT EXISTS idx_insurance_guides_unbilled ON insurance_guides(insurance_id) WHERE lot_id IS NULL AND status = 'pending' AND deleted_at IS NULL",
+		"CREATE INDEX IF NOT EXISTS idx_insurance_guide_items_plan_item ON insurance_guide_items(plan_item_id) WHERE plan_item_id IS NOT NULL AND deleted_at IS NULL",
+		"CREATE INDEX IF NOT EXISTS idx_insurance_lots_insurance_status ON insurance_lots(insurance_id, status) WHERE deleted_at IS NULL",
+
+		// PIX charges - reuse of the active charge of a target and pending confirmations
+		"CREATE INDEX IF NOT EXISTS idx_pix_charges_target ON pix_charges(purpose, payment_id, treatment_id, installment_number, budget_id, appointment_id) WHERE status = 'active' AND deleted_at IS NULL",
+		"CREATE INDEX IF NOT EXISTS idx_pix_charges_patient ON pix_charges(patient_id, created_at DESC) WHERE deleted_at IS NULL",
+
+		// Rooms - resource conflict checks and agenda
+		"CREATE INDEX IF NOT EXISTS idx_appointments_room_time ON appointments(room_id, start_time, end_time) WHERE deleted_at IS NULL AND room_id IS NOT NULL",
+
 		// Leads
 		"CREATE INDEX IF NOT EXISTS idx_leads_status ON leads(status)",
 		"CREATE INDEX IF NOT EXISTS idx_leads_source ON leads(source)",
@@ -155,6 +221,11 @@ func ApplyTenantIndexes(schemaName string) error {
 		}
 	}
 
+	// Double-booking guard (exclusion constraints, backfilled for existing schemas)
+	if _, err := ApplyAppointmentOverlapConstraints(schemaName); err != nil {
+		log.Printf("Warning: Could not apply appointment overlap constraints in %s: %v", schemaName, err)
+	}
+
 	// Reset search path
 	DB.Exec("SET search_path TO public")
 
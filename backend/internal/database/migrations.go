@@ -100,6 +100,45 @@ func migrateNewTablesOnly(schema string) error {
 		&models.Task{},           // Task management
 		&models.TaskUser{},       // Task responsible users (many-to-many)
 		&models.TaskAssignment{}, // Task assignments to entities
+		&models.AppointmentReminder{}, // Automated reminder delivery log
+		&models.ProfessionalSchedule{},         // Per-dentist weekly availability
+		&models.ProfessionalScheduleOverride{}, // Per-dentist date-specific hours
+		&models.ProfessionalAbsence{},          // Per-dentist vacations and absences
+		&models.Room{},                         // Chairs and rooms as bookable resources
+		&models.TreatmentProtocol{},            // Added for required equipment
+		&models.WaitingListOffer{},             // Freed slots offered to the waiting list
+		&models.ClinicClosure{},                // Clinic holidays and closures
+		&models.AppointmentStatusHistory{},     // Appointment status changes (who, when, why)
+		&models.ToothEvent{},                   // Odontogram findings and planned procedures per tooth/surface
+		&models.PeriodontalExam{},              // Periodontal charts (periodontogram)
+		&models.PeriodontalTooth{},             // Six-site periodontal measurements per tooth
+		&models.MedicalRecordAddendum{},        // Append-only addenda of signed medical records
+		&models.MedicalRecordRevision{},        // Previous versions of unsigned medical records
+		&models.AnamnesisTemplate{},            // Tenant-defined anamnesis questionnaires
+		&models.AnamnesisQuestion{},            // Typed and conditional questions of a questionnaire
+		&models.AnamnesisResponse{},            // Questionnaires filled by patients
+		&models.AnamnesisAnswer{},              // Structured answers with a snapshot of the question
+		&models.Medication{},                   // Medication catalog for structured prescriptions
+		&models.PrescriptionItem{},             // Prescription lines referencing the catalog
+		&models.PrescriptionSafetyCheck{},      // Allergy/interaction checks and overrides
+		&models.ControlledPrescriptionBook{},   // Numbering of controlled-substance prescriptions per prescriber
+		&models.TreatmentPlanItem{},            // Procedures of budgets/treatments per tooth, ticked off in appointments
+		&models.Attachment{},                   // Added for thumbnail and preview fields
+		&models.Exam{},                         // Added for DICOM study fields
+		&models.ExamInstance{},                 // DICOM files of an exam study
+		&models.ClinicalNoteTemplate{},         // Reusable texts with placeholders for medical records
+		&models.ExternalSpecialist{},           // Directory of outside professionals for referrals
+		&models.Referral{},                     // Referrals to external specialists and their reports
+		&models.Commission{},                   // Added origin, calculation and payout fields
+		&models.CommissionRule{},               // Commission rules per professional, procedure and category
+		&models.PaymentMethodFee{},             // Card fees deducted from net commissions
+		&models.CommissionPayout{},             // Commission payments to professionals
+		&models.InsuranceCompany{},             // Insurers billed through TISS
+		&models.InsurancePrice{},               // Procedure price tables (TUSS) per insurer
+		&models.InsuranceGuide{},               // Dental treatment guides (GTO) and their payment return
+		&models.InsuranceGuideItem{},           // Procedures billed in the guides, with disallowances (glosa)
+		&models.InsuranceLot{},                 // Lots of guides sent in TISS XML
+		&models.PixCharge{},                    // PIX BR Codes issued for payments, installments, budgets and deposits
 	)
 
 	return err
@@ -112,7 +151,29 @@ func autoMigrateTenantSchema(db *gorm.DB) error {
 		// Core tables
 		&models.Patient{},
 		&models.Appointment{},
+		&models.AppointmentReminder{},
+		&models.ProfessionalSchedule{},
+		&models.ProfessionalScheduleOverride{},
+		&models.ProfessionalAbsence{},
+		&models.Room{},
+		&models.ClinicClosure{},
+		&models.AppointmentStatusHistory{},
+		&models.ToothEvent{},
+		&models.PeriodontalExam{},
+		&models.PeriodontalTooth{},
+		&models.MedicalRecordAddendum{},
+		&models.MedicalRecordRevision{},
+		&models.AnamnesisTemplate{},
+		&models.AnamnesisQuestion{},
+		&models.AnamnesisResponse{},
+		&models.AnamnesisAnswer{},
+		&models.Medication{},
+		&models.PrescriptionItem{},
+		&models.PrescriptionSafetyCheck{},
+		&models.ControlledPrescriptionBook{},
+		&models.TreatmentPlanItem{},
 		&models.MedicalRecord{},
+		&models.ClinicalNoteTemplate{},
 
 		// Financial tables
 		&models.Budget{},
@@ -120,6 +181,15 @@ func autoMigrateTenantSchema(db *gorm.DB) error {
 		&models.Commission{},
 		&models.Treatment{},
 		&models.TreatmentPayment{},
+		&models.CommissionRule{},
+		&models.PaymentMethodFee{},
+		&models.CommissionPayout{},
+		&models.InsuranceCompany{},
+		&models.InsurancePrice{},
+		&models.InsuranceGuide{},
+		&models.InsuranceGuideItem{},
+		&models.InsuranceLot{},
+		&models.PixCharge{},
 
 		// Inventory tables
 		&models.Product{},
@@ -133,7 +203,10 @@ func autoMigrateTenantSchema(db *gorm.DB) error {
 		// Document tables
 		&models.Attachment{},
 		&models.Exam{},
+		&models.ExamInstance{},
 		&models.Prescription{},
+		&models.ExternalSpecialist{},
+		&models.Referral{},
 
 		// Clinical tables
 		&models.TreatmentProtocol{},
@@ -145,6 +218,7 @@ func autoMigrateTenantSchema(db *gorm.DB) error {
 		&models.TaskUser{},       // Task responsible users (many-to-many)
 		&models.TaskAssignment{}, // Task assignments to entities
 		&models.WaitingList{},
+		&models.WaitingListOffer{},
 
 		// CRM tables
 		&models.Lead{},
@@ -193,6 +267,7 @@ func MigratePublicSchema() error {
 		&models.PasswordReset{},
 		&models.TenantSettings{},    // Settings stored in public schema per tenant
 		&models.UserCertificate{},   // Digital certificates for document signing (ICP-Brasil A1)
+		&models.CalendarFeed{},      // Read-only ICS feeds per professional/room
 	)
 
 	if err != nil {
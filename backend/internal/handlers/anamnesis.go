@@ -0,0 +1,415 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// ANAMNESIS TEMPLATES CRUD
+// ============================================
+
+// AnamnesisTemplateRequest is the payload of a questionnaire template
+type AnamnesisTemplateRequest struct {
+	Title       string                     `json:"title" binding:"required"`
+	Description string                     `json:"description"`
+	Active      *bool                      `json:"active"`
+	IsDefault   *bool                      `json:"is_default"`
+	Questions   []models.AnamnesisQuestion `json:"questions"`
+}
+
+// loadAnamnesisTemplate loads a template with its questions in order
+func loadAnamnesisTemplate(db *gorm.DB, id interface{}) (models.AnamnesisTemplate, error) {
+	var template models.AnamnesisTemplate
+	err := db.Session(&gorm.Session{NewDB: true}).
+		Preload("Questions", func(tx *gorm.DB) *gorm.DB { return tx.Order("position ASC") }).
+		First(&template, id).Error
+	return template, err
+}
+
+// CreateAnamnesisTemplate creates a questionnaire with its questions
+// POST /anamnesis-templates
+func CreateAnamnesisTemplate(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var req AnamnesisTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := helpers.NormalizeAnamnesisQuestions(req.Questions); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template := models.AnamnesisTemplate{
+		Title:       strings.TrimSpace(req.Title),
+		Description: req.Description,
+		Active:      req.Active == nil || *req.Active,
+		IsDefault:   req.IsDefault != nil && *req.IsDefault,
+		Questions:   req.Questions,
+	}
+
+	err := db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		// Only one default questionnaire
+		if template.IsDefault {
+			if err := tx.Exec("UPDATE anamnesis_templates SET is_default = false, updated_at = NOW() WHERE is_default = true AND deleted_at IS NULL").Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(&template).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar questionário de anamnese"})
+		return
+	}
+
+	helpers.AuditAction(c, "create", "anamnesis_templates", template.ID, true, map[string]interface{}{
+		"questions": len(template.Questions),
+	})
+
+	c.JSON(http.StatusCreated, gin.H{"template": template})
+}
+
+// GetAnamnesisTemplates lists the questionnaires
+// GET /anamnesis-templates?active=true
+func GetAnamnesisTemplates(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	query := db.Session(&gorm.Session{NewDB: true}).Model(&models.AnamnesisTemplate{})
+	if active := c.Query("active"); active == "true" {
+		query = query.Where("active = ?", true)
+	} else if active == "false" {
+		query = query.Where("active = ?", false)
+	}
+
+	var templates []models.AnamnesisTemplate
+	if err := query.Preload("Questions", func(tx *gorm.DB) *gorm.DB { return tx.Order("position ASC") }).
+		Order("is_default DESC, title ASC").
+		Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar questionários de anamnese"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"templates": templates})
+}
+
+// GetAnamnesisTemplate returns a questionnaire with its questions
+// GET /anamnesis-templates/:id
+func GetAnamnesisTemplate(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	template, err := loadAnamnesisTemplate(db, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Questionário não encontrado"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"template": template})
+}
+
+// UpdateAnamnesisTemplate replaces a questionnaire and its questions
+// Filled questionnaires keep a snapshot of the questions, so they are not affected
+// PUT /anamnesis-templates/:id
+func UpdateAnamnesisTemplate(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	template, err := loadAnamnesisTemplate(db, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Questionário não encontrado"})
+		return
+	}
+
+	var req AnamnesisTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := helpers.NormalizeAnamnesisQuestions(req.Questions); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template.Title = strings.TrimSpace(req.Title)
+	template.Description = req.Description
+	if req.Active != nil {
+		template.Active = *req.Active
+	}
+	if req.IsDefault != nil {
+		template.IsDefault = *req.IsDefault
+	}
+	for i := range req.Questions {
+		req.Questions[i].TemplateID = template.ID
+	}
+
+	err = db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		if template.IsDefault {
+			if err := tx.Exec("UPDATE anamnesis_templates SET is_default = false, updated_at = NOW() WHERE is_default = true AND id != ? AND deleted_at IS NULL",
+				template.ID).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("template_id = ?", template.ID).Delete(&models.AnamnesisQuestion{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("UPDATE anamnesis_templates SET title = ?, description = ?, active = ?, is_default = ?, updated_at = ? WHERE id = ?",
+			template.Title, template.Description, template.Active, template.IsDefault, time.Now(), template.ID).Error; err != nil {
+			return err
+		}
+		return tx.Create(&req.Questions).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar questionário de anamnese"})
+		return
+	}
+
+	helpers.AuditAction(c, "update", "anamnesis_templates", template.ID, true, map[string]interface{}{
+		"questions": len(req.Questions),
+	})
+
+	updated, _ := loadAnamnesisTemplate(db, template.ID)
+	c.JSON(http.StatusOK, gin.H{"template": updated})
+}
+
+// DeleteAnamnesisTemplate removes a questionnaire that was never filled
+// DELETE /anamnesis-templates/:id
+func DeleteAnamnesisTemplate(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var template models.AnamnesisTemplate
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&template, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Questionário não encontrado"})
+		return
+	}
+
+	var responseCount int64
+	db.Session(&gorm.Session{NewDB: true}).Model(&models.AnamnesisResponse{}).Where("template_id = ?", template.ID).Count(&responseCount)
+	if responseCount > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Questionário já preenchido por pacientes. Desative-o em vez de excluir.",
+			"count": responseCount,
+		})
+		return
+	}
+
+	if err := db.Session(&gorm.Session{NewDB: true}).Delete(&models.AnamnesisTemplate{}, template.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao remover questionário"})
+		return
+	}
+
+	helpers.AuditAction(c, "delete", "anamnesis_templates", template.ID, true, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Questionário removido com sucesso"})
+}
+
+// ============================================
+// FILLED QUESTIONNAIRES
+// ============================================
+
+// CreateAnamnesisResponseRequest is a questionnaire filled for a patient
+type CreateAnamnesisResponseRequest struct {
+	PatientID       uint                           `json:"patient_id" binding:"required"`
+	TemplateID      uint                           `json:"template_id" binding:"required"`
+	MedicalRecordID *uint                          `json:"medical_record_id"`
+	Notes           string                         `json:"notes"`
+	Answers         []helpers.AnamnesisAnswerInput `json:"answers"`
+	SyncPatient     *bool                          `json:"sync_patient"` // Copy clinical answers to the patient (default true)
+}
+
+// loadAnamnesisResponse loads a filled questionnaire with its answers in order
+func loadAnamnesisResponse(db *gorm.DB, id interface{}) (models.AnamnesisResponse, error) {
+	var response models.AnamnesisResponse
+	err := db.Session(&gorm.Session{NewDB: true}).
+		Preload("Answers", func(tx *gorm.DB) *gorm.DB { return tx.Order("position ASC") }).
+		Preload("Patient").
+		First(&response, id).Error
+	return response, err
+}
+
+// CreateAnamnesisResponse stores a filled questionnaire and adds the clinically relevant
+// answers (allergies, medications, anticoagulants, pregnancy...) to the patient
+// POST /anamnesis
+func CreateAnamnesisResponse(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var req CreateAnamnesisResponseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var patient models.Patient
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&patient, req.PatientID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Paciente não encontrado"})
+		return
+	}
+
+	template, err := loadAnamnesisTemplate(db, req.TemplateID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Questionário não encontrado"})
+		return
+	}
+	if !template.Active {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Questionário inativo"})
+		return
+	}
+
+	if req.MedicalRecordID != nil {
+		var record models.MedicalRecord
+		if err := db.Session(&gorm.Session{NewDB: true}).
+			Where("id = ? AND patient_id = ?", *req.MedicalRecordID, req.PatientID).
+			First(&record).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Prontuário não encontrado para este paciente"})
+			return
+		}
+	}
+
+	evaluation, err := helpers.EvaluateAnamnesis(template.Questions, req.Answers)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := models.AnamnesisResponse{
+		PatientID:       patient.ID,
+		TemplateID:      template.ID,
+		TemplateTitle:   template.Title,
+		MedicalRecordID: req.MedicalRecordID,
+		FilledByID:      c.GetUint("user_id"),
+		FilledAt:        time.Now(),
+		Notes:           req.Notes,
+		Answers:         evaluation.Answers,
+	}
+
+	// Patient columns and the entries added to each one
+	patientColumns := map[string]*string{
+		"allergies":         &patient.Allergies,
+		"medications":       &patient.Medications,
+		"systemic_diseases": &patient.SystemicDiseases,
+	}
+	patientUpdates := map[string][]string{}
+	syncPatient := req.SyncPatient == nil || *req.SyncPatient
+
+	err = db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&response).Error; err != nil {
+			return err
+		}
+		if !syncPatient {
+			return nil
+		}
+		for column, entries := range evaluation.PatientEntries {
+			current, ok := patientColumns[column]
+			if !ok {
+				continue
+			}
+			text, added := helpers.MergeClinicalText(*current, entries)
+			if len(added) == 0 {
+				continue
+			}
+			if err := tx.Exec("UPDATE patients SET "+column+" = ?, updated_at = ? WHERE id = ?", text, time.Now(), patient.ID).Error; err != nil {
+				return err
+			}
+			*current = text
+			patientUpdates[column] = added
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao salvar anamnese"})
+		return
+	}
+
+	helpers.AuditAction(c, "create", "anamnesis_responses", response.ID, true, map[string]interface{}{
+		"patient_id":      patient.ID,
+		"template_id":     template.ID,
+		"patient_updates": patientUpdates,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{
+		"response":        response,
+		"patient_updates": patientUpdates,
+	})
+}
+
+// GetAnamnesisResponses lists the questionnaires filled by a patient, newest first
+// GET /anamnesis?patient_id=
+func GetAnamnesisResponses(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	offset := (page - 1) * pageSize
+
+	query := db.Session(&gorm.Session{NewDB: true}).Model(&models.AnamnesisResponse{})
+	if patientID := c.Query("patient_id"); patientID != "" {
+		query = query.Where("patient_id = ?", patientID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var responses []models.AnamnesisResponse
+	if err := query.Preload("Answers", func(tx *gorm.DB) *gorm.DB { return tx.Order("position ASC") }).
+		Offset(offset).Limit(pageSize).Order("filled_at DESC").
+		Find(&responses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar anamneses"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"responses": responses,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// GetAnamnesisResponse returns a filled questionnaire
+// GET /anamnesis/:id
+func GetAnamnesisResponse(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	response, err := loadAnamnesisResponse(db, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Anamnese não encontrada"})
+		return
+	}
+
+	helpers.AuditAction(c, "view", "anamnesis_responses", response.ID, true, map[string]interface{}{
+		"patient_id": response.PatientID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"response": response})
+}
+
+// DeleteAnamnesisResponse removes a filled questionnaire
+// Entries already copied to the patient are kept
+// DELETE /anamnesis/:id
+func DeleteAnamnesisResponse(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var response models.AnamnesisResponse
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&response, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Anamnese não encontrada"})
+		return
+	}
+
+	if err := db.Session(&gorm.Session{NewDB: true}).Delete(&models.AnamnesisResponse{}, response.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao remover anamnese"})
+		return
+	}
+
+	helpers.AuditAction(c, "delete", "anamnesis_responses", response.ID, true, map[string]interface{}{
+		"patient_id": response.PatientID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Anamnese removida com sucesso"})
+}
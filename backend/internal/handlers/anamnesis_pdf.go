@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// GenerateAnamnesisPDF renders a filled questionnaire for printing and signature
+// GET /anamnesis/:id/pdf
+func GenerateAnamnesisPDF(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c)
+	if !ok {
+		return
+	}
+	tenantID := c.GetUint("tenant_id")
+
+	// Get tenant info for header
+	var tenant models.Tenant
+	if err := db.Table("public.tenants").Where("id = ?", tenantID).First(&tenant).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load clinic info"})
+		return
+	}
+
+	response, err := loadAnamnesisResponse(db, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Anamnese não encontrada"})
+		return
+	}
+
+	// Create PDF with proper margins for A4
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("cp1252")
+
+	// Header
+	pdf.SetFont("Arial", "B", 16)
+	pdf.Cell(0, 10, tr(tenant.Name))
+	pdf.Ln(8)
+
+	pdf.SetFont("Arial", "", 9)
+	pdf.Cell(0, 5, tr(tenant.Address+", "+tenant.City+" - "+tenant.State))
+	pdf.Ln(5)
+	pdf.Cell(0, 5, tr("Tel: "+tenant.Phone))
+	pdf.Ln(10)
+
+	// Title
+	pdf.SetFont("Arial", "B", 14)
+	pdf.Cell(0, 8, tr("Anamnese - "+response.TemplateTitle))
+	pdf.Ln(10)
+
+	// Patient info
+	pdf.SetFillColor(240, 240, 240)
+	pdf.SetFont("Arial", "B", 11)
+	pdf.CellFormat(180, 7, tr("Informacoes do Paciente"), "1", 0, "L", true, 0, "")
+	pdf.Ln(-1)
+
+	pdf.SetFont("Arial", "", 10)
+	patientName := "N/A"
+	if response.Patient != nil {
+		patientName = response.Patient.Name
+	}
+	pdf.CellFormat(60, 6, tr("Paciente:"), "1", 0, "L", false, 0, "")
+	pdf.CellFormat(120, 6, tr(patientName), "1", 0, "L", false, 0, "")
+	pdf.Ln(-1)
+
+	pdf.CellFormat(60, 6, tr("Preenchido em:"), "1", 0, "L", false, 0, "")
+	pdf.CellFormat(120, 6, response.FilledAt.Format("02/01/2006 15:04"), "1", 0, "L", false, 0, "")
+	pdf.Ln(10)
+
+	// Answers
+	pdf.SetFont("Arial", "B", 11)
+	pdf.CellFormat(180, 7, tr("Respostas"), "1", 0, "L", true, 0, "")
+	pdf.Ln(-1)
+
+	for _, answer := range response.Answers {
+		checkPageBreak(pdf, 15)
+
+		pdf.SetFont("Arial", "B", 10)
+		pdf.SetTextColor(0, 0, 0)
+		pdf.MultiCell(180, 5, tr(answer.QuestionText), "LTR", "L", false)
+
+		pdf.SetFont("Arial", "", 10)
+		if answer.Flagged {
+			pdf.SetTextColor(211, 47, 47)
+		}
+		pdf.MultiCell(180, 5, tr(anamnesisAnswerText(answer)), "LBR", "L", false)
+		pdf.SetTextColor(0, 0, 0)
+	}
+
+	pdf.Ln(2)
+	pdf.SetFont("Arial", "I", 7)
+	pdf.CellFormat(180, 4, tr("Respostas em vermelho foram registradas nas informacoes medicas do paciente."), "0", 0, "L", false, 0, "")
+	pdf.Ln(-1)
+
+	renderSection(pdf, tr, "Observacoes:", response.Notes)
+
+	// Patient signature
+	checkPageBreak(pdf, 40)
+	pdf.Ln(20)
+	pdf.SetFont("Arial", "", 10)
+	pdf.CellFormat(180, 5, "_____________________________________________", "", 0, "C", false, 0, "")
+	pdf.Ln(-1)
+	pdf.CellFormat(180, 5, tr("Paciente / Responsavel - declaro que as informacoes acima sao verdadeiras"), "", 0, "C", false, 0, "")
+	pdf.Ln(-1)
+
+	// Footer
+	pdf.Ln(10)
+	pdf.SetFont("Arial", "I", 8)
+	pdf.Cell(0, 5, fmt.Sprintf("Gerado em: %s", time.Now().Format("02/01/2006 15:04")))
+
+	helpers.AuditAction(c, "view", "anamnesis_responses", response.ID, true, map[string]interface{}{
+		"patient_id": response.PatientID,
+		"format":     "pdf",
+	})
+
+	// Output PDF
+	c.Header("Content-Type", "application/pdf")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=anamnese_%d.pdf", response.ID))
+
+	if err := pdf.Output(c.Writer); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate PDF"})
+		return
+	}
+}
+
+// anamnesisAnswerText formats an answer for the PDF
+func anamnesisAnswerText(answer models.AnamnesisAnswer) string {
+	text := answer.Value
+	switch answer.QuestionType {
+	case models.AnamnesisQuestionYesNo:
+		if answer.Value == models.AnamnesisAnswerYes {
+			text = "Sim"
+		} else {
+			text = "Nao"
+		}
+	case models.AnamnesisQuestionMultipleChoice:
+		text = strings.Join(answer.Selected, ", ")
+	case models.AnamnesisQuestionDate:
+		if date, err := time.Parse("2006-01-02", answer.Value); err == nil {
+			text = date.Format("02/01/2006")
+		}
+	}
+
+	if answer.Details != "" {
+		text += " - " + answer.Details
+	}
+	return text
+}
@@ -1,19 +1,86 @@
 package handlers
 
 import (
+	"drcrwell/backend/internal/availability"
+	"drcrwell/backend/internal/database"
 	"drcrwell/backend/internal/middleware"
 	"drcrwell/backend/internal/models"
+	"drcrwell/backend/internal/scheduler"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 
-// checkAppointmentConflict verifica se existe conflito de horário para o profissional
-// Retorna true se existe conflito, false se o horário está livre
-func checkAppointmentConflict(db *gorm.DB, dentistID uint, startTime, endTime models.LocalTime, excludeAppointmentID uint) (bool, error) {
+// appointmentConflictMessage is shown when the dentist already has an appointment in the period
+const appointmentConflictMessage = "Já existe um agendamento para este profissional neste horário. Por favor, escolha outro horário."
+
+// appointmentBufferConflictMessage is shown when the period does not overlap another appointment of the
+// dentist but falls within the cleanup time the clinic keeps between appointments
+const appointmentBufferConflictMessage = "O horário fica muito próximo de outro agendamento do profissional. A clínica mantém um intervalo de %d minutos entre as consultas."
+
+// appointmentBuffer returns the cleanup time the clinic keeps before and after each appointment
+func appointmentBuffer(db *gorm.DB, tenantID uint) time.Duration {
+	var minutes int
+	db.Session(&gorm.Session{NewDB: true}).Table("public.tenant_settings").
+		Where("tenant_id = ?", tenantID).Select("appointment_buffer_minutes").Scan(&minutes)
+	if minutes < 0 {
+		minutes = 0
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// roomConflictMessage is shown when the database rejects a booking because the room is taken
+const roomConflictMessage = "A sala/cadeira já está ocupada neste horário. Por favor, escolha outra sala ou horário."
+
+// overlapConflictMessage maps a double booking rejected by the database (a concurrent request
+// passed checkAppointmentConflict at the same time) to the message shown to the user
+func overlapConflictMessage(err error) (string, bool) {
+	constraint, ok := database.AppointmentOverlapViolation(err)
+	if !ok {
+		return "", false
+	}
+	if constraint == database.AppointmentRoomOverlapConstraint {
+		return roomConflictMessage, true
+	}
+	return appointmentConflictMessage, true
+}
+
+// respondOverlapConflict writes the 409 "Conflito de horário" response when err is a database double booking
+// Returns false (and writes nothing) for any other error
+func respondOverlapConflict(c *gin.Context, err error) bool {
+	message, ok := overlapConflictMessage(err)
+	if !ok {
+		return false
+	}
+	c.JSON(http.StatusConflict, gin.H{
+		"error":   "Conflito de horário",
+		"message": message,
+	})
+	return true
+}
+
+// checkAppointmentConflict verifica se existe conflito de horário para o profissional e a sala
+// Considera feriados/fechamentos da clínica, a agenda do profissional (expediente, exceções e ausências), os agendamentos existentes
+// as reservas pendentes de ofertas da lista de espera (exceto para o paciente que recebeu a oferta)
+// e, quando há sala/cadeira vinculada, a ocupação e os equipamentos da sala
+// O intervalo de limpeza da clínica (appointment_buffer_minutes) é mantido entre os agendamentos do profissional
+// excludeIDs ignora agendamentos (o próprio agendamento em updates, ou a série sendo movida)
+// Retorna true e a mensagem para o usuário se existe conflito, false se o horário está livre
+func checkAppointmentConflict(db *gorm.DB, tenantID uint, apt models.Appointment, excludeIDs ...uint) (bool, string, error) {
+	// Verifica se a clínica está aberta e o profissional atende neste horário
+	reason, err := availability.CheckProfessional(db, apt.DentistID, apt.StartTime.Time, apt.EndTime.Time)
+	if err != nil {
+		return false, "", err
+	}
+	if reason != "" {
+		return true, reason, nil
+	}
+
 	var count int64
 
 	// Usa uma nova sessão para evitar contaminação de condições anteriores
@@ -22,23 +89,58 @@ func checkAppointmentConflict(db *gorm.DB, dentistID uint, startTime, endTime mo
 	// Busca agendamentos que:
 	// 1. São do mesmo dentista
 	// 2. Não estão cancelados ou no_show
-	// 3. Têm sobreposição de horário (start < existingEnd AND end > existingStart)
-	// 4. Não é o próprio agendamento (para updates)
-	query := cleanDB.Model(&models.Appointment{}).
-		Where("dentist_id = ?", dentistID).
-		Where("status NOT IN ?", []string{"cancelled", "no_show"}).
-		Where("start_time < ? AND end_time > ?", endTime, startTime)
+	// 3. Têm sobreposição de horário, ampliada pelo intervalo de limpeza (start - buffer < existingEnd AND end + buffer > existingStart)
+	// 4. Não estão na lista de exclusão (para updates)
+	buffer := appointmentBuffer(db, tenantID)
+	overlapping := func(buffer time.Duration) *gorm.DB {
+		query := cleanDB.Model(&models.Appointment{}).
+			Where("dentist_id = ?", apt.DentistID).
+			Where("status NOT IN ?", []string{"cancelled", "no_show"}).
+			Where("start_time < ? AND end_time > ?", models.LocalTime{Time: apt.EndTime.Time.Add(buffer)}, models.LocalTime{Time: apt.StartTime.Time.Add(-buffer)})
+		if len(excludeIDs) > 0 {
+			query = query.Where("id NOT IN ?", excludeIDs)
+		}
+		return query
+	}
 
-	// Exclui o próprio agendamento (para caso de update)
-	if excludeAppointmentID > 0 {
-		query = query.Where("id != ?", excludeAppointmentID)
+	if err := overlapping(buffer).Count(&count).Error; err != nil {
+		return false, "", err
 	}
 
-	if err := query.Count(&count).Error; err != nil {
-		return false, err
+	if count > 0 {
+		// Sem sobreposição real, o conflito é só o intervalo entre consultas
+		if buffer > 0 {
+			var overlapCount int64
+			if err := overlapping(0).Count(&overlapCount).Error; err != nil {
+				return false, "", err
+			}
+			if overlapCount == 0 {
+				return true, fmt.Sprintf(appointmentBufferConflictMessage, int(buffer.Minutes())), nil
+			}
+		}
+		return true, appointmentConflictMessage, nil
 	}
 
-	return count > 0, nil
+	// Horários reservados por ofertas da lista de espera só podem ser ocupados pelo paciente que recebeu a oferta
+	reason, err = availability.CheckHold(db, apt.DentistID, apt.RoomID, apt.StartTime.Time, apt.EndTime.Time, apt.PatientID)
+	if err != nil {
+		return false, "", err
+	}
+	if reason != "" {
+		return true, reason, nil
+	}
+
+	// Verifica a sala/cadeira (ocupação e equipamentos exigidos pelo procedimento)
+	if apt.RoomID != nil {
+		reason, err := availability.CheckRoom(db, *apt.RoomID, apt.StartTime.Time, apt.EndTime.Time, apt.Procedure, excludeIDs...)
+		if err != nil {
+			return false, "", err
+		}
+		if reason != "" {
+			return true, reason, nil
+		}
+	}
+	return false, "", nil
 }
 
 func CreateAppointment(c *gin.Context) {
@@ -50,8 +152,20 @@ func CreateAppointment(c *gin.Context) {
 
 	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
 
-	// Validar conflito de horário para o profissional
-	hasConflict, err := checkAppointmentConflict(db, appointment.DentistID, appointment.StartTime, appointment.EndTime, 0)
+	// Series membership is assigned by the server only
+	appointment.SeriesID = nil
+	appointment.SeriesIndex = 0
+
+	applyRoomName(db, &appointment)
+
+	// Recurring appointment: generate every occurrence of the RRULE
+	if appointment.IsRecurring && appointment.RecurrenceRule != "" {
+		createAppointmentSeries(c, db, appointment)
+		return
+	}
+
+	// Validar conflito de horário para o profissional e a sala
+	hasConflict, conflictMessage, err := checkAppointmentConflict(db, c.GetUint("tenant_id"), appointment)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar disponibilidade"})
 		return
@@ -59,12 +173,15 @@ func CreateAppointment(c *gin.Context) {
 	if hasConflict {
 		c.JSON(http.StatusConflict, gin.H{
 			"error":   "Conflito de horário",
-			"message": "Já existe um agendamento para este profissional neste horário. Por favor, escolha outro horário.",
+			"message": conflictMessage,
 		})
 		return
 	}
 
 	if err := db.Create(&appointment).Error; err != nil {
+		if respondOverlapConflict(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar agendamento"})
 		return
 	}
@@ -97,6 +214,9 @@ func GetAppointments(c *gin.Context) {
 	if procedure := c.Query("procedure"); procedure != "" {
 		query = query.Where("procedure = ?", procedure)
 	}
+	if seriesID := c.Query("series_id"); seriesID != "" {
+		query = query.Where("series_id = ?", seriesID)
+	}
 	if startDate := c.Query("start_date"); startDate != "" {
 		query = query.Where("start_time >= ?", startDate)
 	}
@@ -132,27 +252,66 @@ func GetAppointment(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"appointment": appointment})
+	c.JSON(http.StatusOK, gin.H{
+		"appointment": appointment,
+		"plan_items":  loadAppointmentPlanItems(db, appointment.ID),
+	})
 }
 
+// appointmentUpdateRequest is the appointment edited by the form, with the reason when it is cancelled
+type appointmentUpdateRequest struct {
+	models.Appointment
+	ReasonCode string `json:"reason_code"` // Cancellation reason code
+	Reason     string `json:"reason"`
+}
+
+// UpdateAppointment updates an appointment
+// A status change goes through the same lifecycle as PATCH /appointments/:id/status
+// For recurring series, ?scope=this|following|all selects which occurrences are changed
 func UpdateAppointment(c *gin.Context) {
 	id := c.Param("id")
 	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
 
+	scope, ok := getSeriesScope(c)
+	if !ok {
+		return
+	}
+
 	var appointment models.Appointment
 	if err := db.First(&appointment, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Agendamento não encontrado"})
 		return
 	}
 
-	var input models.Appointment
-	if err := c.ShouldBindJSON(&input); err != nil {
+	var req appointmentUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	input := req.Appointment
+
+	applyRoomName(db, &input)
 
-	// Validar conflito de horário para o profissional (excluindo o próprio agendamento)
-	hasConflict, err := checkAppointmentConflict(db, input.DentistID, input.StartTime, input.EndTime, appointment.ID)
+	// The status follows the lifecycle; an empty status keeps the current one
+	if input.Status == "" {
+		input.Status = appointment.Status
+	}
+	if msg := statusTransitionError(appointment.Status, input.Status); msg != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": msg})
+		return
+	}
+	if !validateCancellationReason(c, input.Status, req.ReasonCode) {
+		return
+	}
+	change := staffStatusChange(c, req.ReasonCode, req.Reason)
+
+	if appointment.SeriesID != nil && scope != models.SeriesScopeThis {
+		updateAppointmentSeries(c, db, appointment, input, scope, change)
+		return
+	}
+
+	// Validar conflito de horário para o profissional e a sala (excluindo o próprio agendamento)
+	hasConflict, conflictMessage, err := checkAppointmentConflict(db, c.GetUint("tenant_id"), input, appointment.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar disponibilidade"})
 		return
@@ -160,42 +319,59 @@ func UpdateAppointment(c *gin.Context) {
 	if hasConflict {
 		c.JSON(http.StatusConflict, gin.H{
 			"error":   "Conflito de horário",
-			"message": "Já existe um agendamento para este profissional neste horário. Por favor, escolha outro horário.",
+			"message": conflictMessage,
 		})
 		return
 	}
 
 	// Update fields directly (avoid GORM FROM clause issue)
+	previousStatus := appointment.Status
 	appointment.PatientID = input.PatientID
 	appointment.DentistID = input.DentistID
 	appointment.StartTime = input.StartTime
 	appointment.EndTime = input.EndTime
-	appointment.Status = input.Status
 	appointment.Type = input.Type
 	appointment.Procedure = input.Procedure
 	appointment.Notes = input.Notes
 	appointment.Room = input.Room
+	appointment.RoomID = input.RoomID
 	appointment.Confirmed = input.Confirmed
 	appointment.IsRecurring = input.IsRecurring
 	appointment.RecurrenceRule = input.RecurrenceRule
+	applyAppointmentStatus(&appointment, input.Status)
 
 	// Use raw SQL to avoid GORM's FROM clause bug
 	sql := `UPDATE appointments
 		SET patient_id = ?, dentist_id = ?, start_time = ?, end_time = ?,
-			status = ?, type = ?, procedure = ?, notes = ?, room = ?, confirmed = ?,
+			type = ?, procedure = ?, notes = ?, room = ?, room_id = ?,
 			is_recurring = ?, recurrence_rule = ?, updated_at = NOW()
 		WHERE id = ? AND deleted_at IS NULL`
 
-	if err := db.Exec(sql,
-		appointment.PatientID, appointment.DentistID,
-		appointment.StartTime, appointment.EndTime,
-		appointment.Status, appointment.Type,
-		appointment.Procedure, appointment.Notes,
-		appointment.Room, appointment.Confirmed, appointment.IsRecurring,
-		appointment.RecurrenceRule, id).Error; err != nil {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(sql,
+			appointment.PatientID, appointment.DentistID,
+			appointment.StartTime, appointment.EndTime,
+			appointment.Type,
+			appointment.Procedure, appointment.Notes,
+			appointment.Room, appointment.RoomID, appointment.IsRecurring,
+			appointment.RecurrenceRule, id).Error; err != nil {
+			return err
+		}
+		// Status, confirmation and completed plan items follow the status lifecycle
+		var planItems []models.TreatmentPlanItem
+		if appointment.Status == models.AppointmentStatusCompleted && previousStatus != models.AppointmentStatusCompleted {
+			planItems, _ = loadPlanItemsToComplete(db, appointment, nil)
+		}
+		return saveAppointmentStatus(tx, appointment, previousStatus, change, planItems)
+	})
+	if err != nil {
+		if respondOverlapConflict(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar agendamento"})
 		return
 	}
+	offerCancelledSlot(c, previousStatus, appointment.Status, appointment.ID)
 
 	// Reload with patient and dentist relationships
 	db.Preload("Patient").Preload("Dentist").First(&appointment, id)
@@ -203,10 +379,43 @@ func UpdateAppointment(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"appointment": appointment})
 }
 
+// DeleteAppointment deletes an appointment
+// For recurring series, ?scope=following|all also deletes the pending occurrences in scope
 func DeleteAppointment(c *gin.Context) {
 	id := c.Param("id")
 	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
 
+	scope, ok := getSeriesScope(c)
+	if !ok {
+		return
+	}
+
+	if scope != models.SeriesScopeThis {
+		var appointment models.Appointment
+		if err := db.First(&appointment, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Agendamento não encontrado"})
+			return
+		}
+		if appointment.SeriesID != nil {
+			// The whole scope is removed or none of it
+			var occurrences []models.Appointment
+			err := db.Transaction(func(tx *gorm.DB) error {
+				var err error
+				occurrences, err = loadSeriesScope(tx, appointment, scope)
+				if err != nil {
+					return err
+				}
+				return tx.Where("id IN ?", appointmentIDs(occurrences)).Delete(&models.Appointment{}).Error
+			})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir agendamentos da série"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"message": "Agendamentos excluídos com sucesso", "affected": len(occurrences)})
+			return
+		}
+	}
+
 	if err := db.Delete(&models.Appointment{}, id).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir agendamento"})
 		return
@@ -215,18 +424,33 @@ func DeleteAppointment(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Agendamento excluído com sucesso"})
 }
 
+// UpdateAppointmentStatus moves an appointment through its lifecycle and records the change
+// Only the transitions allowed by the lifecycle are accepted; cancellations take a reason code
+// For recurring series, ?scope=following|all applies it (e.g. cancellation) to the pending occurrences in scope
 func UpdateAppointmentStatus(c *gin.Context) {
 	id := c.Param("id")
 	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
 
+	scope, ok := getSeriesScope(c)
+	if !ok {
+		return
+	}
+
 	var req struct {
-		Status string `json:"status" binding:"required"`
+		Status      string  `json:"status" binding:"required"`
+		ReasonCode  string  `json:"reason_code"` // Cancellation reason code
+		Reason      string  `json:"reason"`
+		PlanItemIDs *[]uint `json:"plan_item_ids"` // On completion: treatment plan items performed (default: the ones scheduled)
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !validateCancellationReason(c, req.Status, req.ReasonCode) {
+		return
+	}
+	change := staffStatusChange(c, req.ReasonCode, req.Reason)
 
 	var appointment models.Appointment
 	if err := db.First(&appointment, id).Error; err != nil {
@@ -234,11 +458,121 @@ func UpdateAppointmentStatus(c *gin.Context) {
 		return
 	}
 
-	appointment.Status = req.Status
-	if err := db.Save(&appointment).Error; err != nil {
+	tenantID := c.GetUint("tenant_id")
+
+	if appointment.SeriesID != nil && scope != models.SeriesScopeThis {
+		occurrences, err := loadSeriesScope(db, appointment, scope)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar ocorrências da série"})
+			return
+		}
+		seriesIDs := appointmentIDs(occurrences)
+		for _, occ := range occurrences {
+			if msg := statusTransitionError(occ.Status, req.Status); msg != "" {
+				c.JSON(http.StatusConflict, gin.H{"error": msg, "series_index": occ.SeriesIndex})
+				return
+			}
+			conflictMessage, err := checkStatusReopen(db, tenantID, occ, req.Status, seriesIDs...)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar disponibilidade"})
+				return
+			}
+			if conflictMessage != "" {
+				c.JSON(http.StatusConflict, gin.H{"error": "Conflito de horário", "message": conflictMessage, "series_index": occ.SeriesIndex})
+				return
+			}
+		}
+
+		// Each occurrence goes through the same change as a single appointment
+		updated := make([]models.Appointment, 0, len(occurrences))
+		err = db.Transaction(func(tx *gorm.DB) error {
+			for _, occ := range occurrences {
+				previousStatus := occ.Status
+				applyAppointmentStatus(&occ, req.Status)
+				var planItems []models.TreatmentPlanItem
+				if req.Status == models.AppointmentStatusCompleted {
+					planItems, _ = loadPlanItemsToComplete(db, occ, nil)
+				}
+				if err := saveAppointmentStatus(tx, occ, previousStatus, change, planItems); err != nil {
+					return err
+				}
+				updated = append(updated, occ)
+			}
+			return nil
+		})
+		if err != nil {
+			if respondOverlapConflict(c, err) {
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar status"})
+			return
+		}
+		for i, occ := range occurrences {
+			offerCancelledSlot(c, occ.Status, req.Status, occ.ID)
+			if occ.ID == appointment.ID {
+				appointment = updated[i]
+			}
+		}
+		appointment.Status = req.Status
+		c.JSON(http.StatusOK, gin.H{"appointment": appointment, "affected": len(occurrences)})
+		return
+	}
+
+	if msg := statusTransitionError(appointment.Status, req.Status); msg != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": msg})
+		return
+	}
+
+	// Reopening a cancelled or no-show appointment needs its slot to be free again
+	conflictMessage, err := checkStatusReopen(db, tenantID, appointment, req.Status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar disponibilidade"})
+		return
+	}
+	if conflictMessage != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Conflito de horário", "message": conflictMessage})
+		return
+	}
+
+	// Completing the appointment ticks off the treatment plan procedures performed
+	var planItems []models.TreatmentPlanItem
+	if req.Status == models.AppointmentStatusCompleted {
+		var itemIDs []uint
+		if req.PlanItemIDs != nil {
+			itemIDs = append([]uint{}, *req.PlanItemIDs...)
+		}
+		var msg string
+		if planItems, msg = loadPlanItemsToComplete(db, appointment, itemIDs); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+	}
+
+	previousStatus := appointment.Status
+	applyAppointmentStatus(&appointment, req.Status)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return saveAppointmentStatus(tx, appointment, previousStatus, change, planItems)
+	})
+	if err != nil {
+		if respondOverlapConflict(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar status"})
 		return
 	}
+	offerCancelledSlot(c, previousStatus, req.Status, appointment.ID)
 
-	c.JSON(http.StatusOK, gin.H{"appointment": appointment})
+	response := gin.H{"appointment": appointment}
+	if req.Status == models.AppointmentStatusCompleted {
+		response["completed_plan_items"] = planItems
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// offerCancelledSlot offers a slot freed by a cancellation to the waiting list (asynchronously)
+func offerCancelledSlot(c *gin.Context, previousStatus, newStatus string, appointmentID uint) {
+	if newStatus != "cancelled" || previousStatus == "cancelled" {
+		return
+	}
+	go scheduler.OfferFreedSlot(c.GetUint("tenant_id"), appointmentID)
 }
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/availability"
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetAvailableSlots returns the free slots of one or all professionals on a date for the staff agenda
+// Slots also need a free room: the one requested, or any room with the equipment of the protocol
+// Slots held by waiting-list offers stay busy, except for the offered patient (patient_id)
+// GET /appointments/available-slots?date=YYYY-MM-DD&dentist_id=X&procedure=Y&protocol_id=Z&duration=M&room_id=R&patient_id=P
+func GetAvailableSlots(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+	tenantID := c.GetUint("tenant_id")
+
+	date, err := time.ParseInLocation("2006-01-02", c.Query("date"), availability.Location())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Formato de data inválido. Use YYYY-MM-DD"})
+		return
+	}
+
+	var settings models.TenantSettings
+	db.Session(&gorm.Session{NewDB: true}).Table("public.tenant_settings").Where("tenant_id = ?", tenantID).First(&settings)
+
+	// Explicit duration wins; otherwise it comes from the procedure or the clinic default
+	protocolID, _ := strconv.ParseUint(c.Query("protocol_id"), 10, 32)
+	duration := time.Duration(0)
+	if minutes, err := strconv.Atoi(c.Query("duration")); err == nil && minutes > 0 {
+		duration = time.Duration(minutes) * time.Minute
+	} else {
+		duration = availability.ResolveDuration(db, settings, uint(protocolID), c.Query("procedure"))
+	}
+	opts := availability.OptionsFromSettings(settings, duration)
+	if roomID, err := strconv.ParseUint(c.Query("room_id"), 10, 32); err == nil {
+		opts.RoomID = uint(roomID)
+	}
+	if patientID, err := strconv.ParseUint(c.Query("patient_id"), 10, 32); err == nil {
+		opts.PatientID = uint(patientID)
+	}
+	if protocol, ok := availability.FindProtocol(db, uint(protocolID), c.Query("procedure")); ok {
+		opts.Equipment = availability.ParseEquipment(protocol.RequiredEquipment)
+	}
+
+	var dentists []models.User
+	query := db.Session(&gorm.Session{NewDB: true}).Table("public.users").
+		Where("tenant_id = ? AND role IN ? AND active = ? AND deleted_at IS NULL", tenantID, []string{"dentist", "admin"}, true)
+	if dentistID := c.Query("dentist_id"); dentistID != "" {
+		query = query.Where("id = ?", dentistID)
+	}
+	if err := query.Order("name ASC").Find(&dentists).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar profissionais"})
+		return
+	}
+
+	professionals := make([]gin.H, 0, len(dentists))
+	for _, dentist := range dentists {
+		slots, err := availability.FindSlots(db, settings, dentist.ID, date, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao calcular disponibilidade"})
+			return
+		}
+
+		result := make([]gin.H, 0, len(slots))
+		for _, slot := range slots {
+			result = append(result, gin.H{
+				"start_time": slot.Start.Format("15:04"),
+				"end_time":   slot.End.Format("15:04"),
+			})
+		}
+
+		professionals = append(professionals, gin.H{
+			"dentist_id":   dentist.ID,
+			"dentist_name": dentist.Name,
+			"slots":        result,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"date":           date.Format("2006-01-02"),
+		"duration":       int(opts.Duration.Minutes()),
+		"buffer_minutes": int(opts.Buffer.Minutes()),
+		"professionals":  professionals,
+	})
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/database"
+	"drcrwell/backend/internal/middleware"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAppointmentOverlaps lists the double bookings that keep the exclusion constraints from being added
+// Each call retries the missing constraints first, so they are added as soon as the conflicts are resolved
+// GET /appointments/overlaps (admin)
+func GetAppointmentOverlaps(c *gin.Context) {
+	_, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+	schemaName := c.GetString("schema")
+
+	constraints, err := database.ApplyAppointmentOverlapConstraints(schemaName)
+	if err != nil {
+		log.Printf("Appointment overlaps: could not apply constraints in %s: %v", schemaName, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar conflitos da agenda"})
+		return
+	}
+
+	overlaps, err := database.FindAppointmentOverlaps(schemaName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar conflitos da agenda"})
+		return
+	}
+	if overlaps == nil {
+		overlaps = []database.AppointmentOverlap{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"constraints": constraints,
+		"overlaps":    overlaps,
+	})
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"drcrwell/backend/internal/scheduler"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetReminderPreview returns the reminders the dispatcher would send right now (dry-run)
+// Nothing is sent and no appointment is changed
+func GetReminderPreview(c *gin.Context) {
+	tenantID := c.GetUint("tenant_id")
+
+	preview, err := scheduler.PreviewTenantReminders(tenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar prévia de lembretes: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"preview": preview,
+		"total":   len(preview.Appointments),
+	})
+}
+
+// GetAppointmentReminders returns the reminder delivery history of an appointment
+func GetAppointmentReminders(c *gin.Context) {
+	id := c.Param("id")
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var appointment models.Appointment
+	if err := db.First(&appointment, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agendamento não encontrado"})
+		return
+	}
+
+	var reminders []models.AppointmentReminder
+	if err := db.Where("appointment_id = ?", appointment.ID).Order("created_at DESC").Find(&reminders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar lembretes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reminders":     reminders,
+		"reminder_sent": appointment.ReminderSent,
+	})
+}
@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/models"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// seriesEditableStatuses are the statuses an occurrence must have to be changed by a
+// "following" or "all" operation - completed or cancelled visits are kept as history
+var seriesEditableStatuses = []string{"scheduled", "confirmed"}
+
+// seriesConflict describes an occurrence that could not be booked
+type seriesConflict struct {
+	SeriesIndex int              `json:"series_index"`
+	StartTime   models.LocalTime `json:"start_time"`
+	EndTime     models.LocalTime `json:"end_time"`
+	Reason      string           `json:"reason"`
+}
+
+// getSeriesScope reads and validates the ?scope= query parameter (defaults to "this")
+func getSeriesScope(c *gin.Context) (string, bool) {
+	scope := c.DefaultQuery("scope", models.SeriesScopeThis)
+	switch scope {
+	case models.SeriesScopeThis, models.SeriesScopeFollowing, models.SeriesScopeAll:
+		return scope, true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Escopo inválido. Use this, following ou all"})
+	return "", false
+}
+
+// expandAppointmentSeries builds one appointment per RRULE occurrence, copying the template fields
+func expandAppointmentSeries(template models.Appointment, rule string, seriesID string) ([]models.Appointment, error) {
+	loc := getTimezone()
+	parsed, err := helpers.ParseRecurrenceRule(rule, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	start := template.StartTime.Time.In(loc)
+	duration := template.EndTime.Time.Sub(template.StartTime.Time)
+	if duration <= 0 {
+		return nil, fmt.Errorf("o horário de término deve ser posterior ao de início")
+	}
+
+	starts, err := parsed.Expand(start)
+	if err != nil {
+		return nil, err
+	}
+
+	occurrences := make([]models.Appointment, 0, len(starts))
+	for i, s := range starts {
+		apt := template
+		apt.ID = 0
+		apt.StartTime = models.LocalTime{Time: s}
+		apt.EndTime = models.LocalTime{Time: s.Add(duration)}
+		apt.IsRecurring = true
+		apt.RecurrenceRule = rule
+		apt.SeriesID = &seriesID
+		apt.SeriesIndex = i + 1
+		apt.Patient = nil
+		apt.Dentist = nil
+		apt.Resource = nil
+		occurrences = append(occurrences, apt)
+	}
+
+	return occurrences, nil
+}
+
+// findSeriesConflicts checks every occurrence against the dentist's agenda and its room
+func findSeriesConflicts(db *gorm.DB, tenantID uint, occurrences []models.Appointment, excludeIDs []uint) ([]seriesConflict, error) {
+	var conflicts []seriesConflict
+	for _, occ := range occurrences {
+		hasConflict, reason, err := checkAppointmentConflict(db, tenantID, occ, excludeIDs...)
+		if err != nil {
+			return nil, err
+		}
+		if hasConflict {
+			conflicts = append(conflicts, seriesConflict{
+				SeriesIndex: occ.SeriesIndex,
+				StartTime:   occ.StartTime,
+				EndTime:     occ.EndTime,
+				Reason:      reason,
+			})
+		}
+	}
+	return conflicts, nil
+}
+
+// respondSeriesConflicts returns 409 listing the occurrences that clash with the dentist's agenda
+func respondSeriesConflicts(c *gin.Context, conflicts []seriesConflict) {
+	c.JSON(http.StatusConflict, gin.H{
+		"error":     "Conflito de horário",
+		"message":   fmt.Sprintf("%d ocorrência(s) da série conflitam com a agenda do profissional. Nenhum agendamento foi criado.", len(conflicts)),
+		"conflicts": conflicts,
+	})
+}
+
+// createAppointmentSeries expands the appointment's RRULE and books every occurrence atomically
+func createAppointmentSeries(c *gin.Context, db *gorm.DB, template models.Appointment) {
+	occurrences, err := expandAppointmentSeries(template, template.RecurrenceRule, uuid.New().String())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	conflicts, err := findSeriesConflicts(db, c.GetUint("tenant_id"), occurrences, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar disponibilidade"})
+		return
+	}
+	if len(conflicts) > 0 {
+		respondSeriesConflicts(c, conflicts)
+		return
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return tx.Create(&occurrences).Error
+	}); err != nil {
+		if respondOverlapConflict(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar agendamentos da série"})
+		return
+	}
+
+	var first models.Appointment
+	db.Preload("Patient").Preload("Dentist").First(&first, occurrences[0].ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"appointment":  first,
+		"appointments": occurrences,
+		"series_id":    *occurrences[0].SeriesID,
+		"occurrences":  len(occurrences),
+	})
+}
+
+// loadSeriesScope returns the occurrences affected by an operation on appointment with the given scope
+func loadSeriesScope(db *gorm.DB, appointment models.Appointment, scope string) ([]models.Appointment, error) {
+	query := db.Session(&gorm.Session{NewDB: true}).Model(&models.Appointment{}).
+		Where("series_id = ?", *appointment.SeriesID).
+		Where("(status IN ? OR id = ?)", seriesEditableStatuses, appointment.ID)
+
+	if scope == models.SeriesScopeFollowing {
+		query = query.Where("start_time >= ?", appointment.StartTime)
+	}
+
+	var occurrences []models.Appointment
+	err := query.Order("start_time ASC").Find(&occurrences).Error
+	return occurrences, err
+}
+
+// appointmentIDs collects the IDs of a list of appointments
+func appointmentIDs(appointments []models.Appointment) []uint {
+	ids := make([]uint, 0, len(appointments))
+	for _, apt := range appointments {
+		ids = append(ids, apt.ID)
+	}
+	return ids
+}
+
+// shiftOccurrence moves an occurrence the same number of days as the edited one and
+// applies the new time of day and duration, keeping wall-clock times stable
+func shiftOccurrence(occ models.Appointment, dayShift int, newStart time.Time, duration time.Duration) (models.LocalTime, models.LocalTime) {
+	loc := getTimezone()
+	base := occ.StartTime.Time.In(loc)
+	start := time.Date(base.Year(), base.Month(), base.Day()+dayShift,
+		newStart.Hour(), newStart.Minute(), newStart.Second(), 0, loc)
+	return models.LocalTime{Time: start}, models.LocalTime{Time: start.Add(duration)}
+}
+
+// calendarDaysBetween returns the number of calendar days from a to b in the clinic timezone
+func calendarDaysBetween(a, b time.Time) int {
+	loc := getTimezone()
+	a = a.In(loc)
+	b = b.In(loc)
+	dayA := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	dayB := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(dayB.Sub(dayA).Hours() / 24)
+}
+
+// updateAppointmentSeries applies an edit to "following" or "all" occurrences of a series
+// If the recurrence rule changed, the affected occurrences are replaced by a regenerated series
+func updateAppointmentSeries(c *gin.Context, db *gorm.DB, appointment models.Appointment, input models.Appointment, scope string, change appointmentStatusChange) {
+	occurrences, err := loadSeriesScope(db, appointment, scope)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar ocorrências da série"})
+		return
+	}
+	excludeIDs := appointmentIDs(occurrences)
+
+	if input.RecurrenceRule != "" && input.RecurrenceRule != appointment.RecurrenceRule {
+		regenerateAppointmentSeries(c, db, appointment, input, scope, occurrences, excludeIDs)
+		return
+	}
+
+	loc := getTimezone()
+	newStart := input.StartTime.Time.In(loc)
+	duration := input.EndTime.Time.Sub(input.StartTime.Time)
+	if duration <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "O horário de término deve ser posterior ao de início"})
+		return
+	}
+	dayShift := calendarDaysBetween(appointment.StartTime.Time, newStart)
+
+	updated := make([]models.Appointment, 0, len(occurrences))
+	for _, occ := range occurrences {
+		occ.StartTime, occ.EndTime = shiftOccurrence(occ, dayShift, newStart, duration)
+		occ.DentistID = input.DentistID
+		occ.RoomID = input.RoomID
+		occ.Procedure = input.Procedure
+		updated = append(updated, occ)
+	}
+
+	conflicts, err := findSeriesConflicts(db, c.GetUint("tenant_id"), updated, excludeIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar disponibilidade"})
+		return
+	}
+	if len(conflicts) > 0 {
+		respondSeriesConflicts(c, conflicts)
+		return
+	}
+
+	// Use raw SQL to avoid GORM's FROM clause bug (same as UpdateAppointment)
+	sql := `UPDATE appointments
+		SET patient_id = ?, dentist_id = ?, start_time = ?, end_time = ?,
+			type = ?, procedure = ?, notes = ?, room = ?, room_id = ?, updated_at = NOW()
+		WHERE id = ? AND deleted_at IS NULL`
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, occ := range updated {
+			if err := tx.Exec(sql,
+				input.PatientID, occ.DentistID,
+				occ.StartTime, occ.EndTime,
+				input.Type, input.Procedure, input.Notes, input.Room, input.RoomID,
+				occ.ID).Error; err != nil {
+				return err
+			}
+		}
+		// Status and confirmation are per-visit; only the edited occurrence takes them
+		edited := appointment
+		edited.DentistID = input.DentistID
+		edited.Confirmed = input.Confirmed
+		applyAppointmentStatus(&edited, input.Status)
+		var planItems []models.TreatmentPlanItem
+		if edited.Status == models.AppointmentStatusCompleted && appointment.Status != models.AppointmentStatusCompleted {
+			planItems, _ = loadPlanItemsToComplete(db, edited, nil)
+		}
+		return saveAppointmentStatus(tx, edited, appointment.Status, change, planItems)
+	})
+	if err != nil {
+		if respondOverlapConflict(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar agendamentos da série"})
+		return
+	}
+	offerCancelledSlot(c, appointment.Status, input.Status, appointment.ID)
+
+	db.Preload("Patient").Preload("Dentist").First(&appointment, appointment.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"appointment": appointment,
+		"affected":    len(updated),
+	})
+}
+
+// regenerateAppointmentSeries replaces the occurrences in scope with a series built from the new rule
+// "following" splits the series (new SeriesID); "all" keeps the original SeriesID
+func regenerateAppointmentSeries(c *gin.Context, db *gorm.DB, appointment models.Appointment, input models.Appointment, scope string, occurrences []models.Appointment, excludeIDs []uint) {
+	seriesID := *appointment.SeriesID
+	anchor := input
+	if scope == models.SeriesScopeFollowing {
+		seriesID = uuid.New().String()
+	} else if len(occurrences) > 0 && occurrences[0].ID != appointment.ID {
+		// "all" restarts the series from its first pending occurrence, with the edited time of day
+		loc := getTimezone()
+		newStart := input.StartTime.Time.In(loc)
+		duration := input.EndTime.Time.Sub(input.StartTime.Time)
+		anchor.StartTime, anchor.EndTime = shiftOccurrence(occurrences[0], calendarDaysBetween(appointment.StartTime.Time, newStart), newStart, duration)
+	}
+	anchor.Status = "scheduled"
+	anchor.Confirmed = false
+	anchor.ConfirmedAt = nil
+	anchor.ReminderSent = false
+
+	newOccurrences, err := expandAppointmentSeries(anchor, input.RecurrenceRule, seriesID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	conflicts, err := findSeriesConflicts(db, c.GetUint("tenant_id"), newOccurrences, excludeIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar disponibilidade"})
+		return
+	}
+	if len(conflicts) > 0 {
+		respondSeriesConflicts(c, conflicts)
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if len(excludeIDs) > 0 {
+			if err := tx.Where("id IN ?", excludeIDs).Delete(&models.Appointment{}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(&newOccurrences).Error
+	})
+	if err != nil {
+		if respondOverlapConflict(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao recriar agendamentos da série"})
+		return
+	}
+
+	var first models.Appointment
+	db.Preload("Patient").Preload("Dentist").First(&first, newOccurrences[0].ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"appointment":  first,
+		"appointments": newOccurrences,
+		"series_id":    seriesID,
+		"occurrences":  len(newOccurrences),
+		"replaced":     len(excludeIDs),
+	})
+}
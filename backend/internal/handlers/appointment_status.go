@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// appointmentStatusChange describes who is changing an appointment status and why
+type appointmentStatusChange struct {
+	ChangedBy  *uint
+	Source     string
+	ReasonCode string
+	Reason     string
+}
+
+// staffStatusChange is a change made by the logged-in user
+func staffStatusChange(c *gin.Context, reasonCode, reason string) appointmentStatusChange {
+	userID := c.GetUint("user_id")
+	return appointmentStatusChange{
+		ChangedBy:  &userID,
+		Source:     models.StatusSourceStaff,
+		ReasonCode: reasonCode,
+		Reason:     reason,
+	}
+}
+
+// recordAppointmentStatus appends a status change to the appointment history
+// Nothing is recorded when the status did not change
+func recordAppointmentStatus(db *gorm.DB, appointmentID uint, from, to string, change appointmentStatusChange) error {
+	if from == to {
+		return nil
+	}
+
+	// Reason codes classify cancellations only; other changes keep just the free-text reason
+	if to != models.AppointmentStatusCancelled {
+		change.ReasonCode = ""
+	} else if change.ReasonCode == "" {
+		change.ReasonCode = models.CancellationReasonOther
+	}
+	if change.Source == "" {
+		change.Source = models.StatusSourceStaff
+	}
+
+	entry := models.AppointmentStatusHistory{
+		AppointmentID: appointmentID,
+		FromStatus:    from,
+		ToStatus:      to,
+		ChangedAt:     time.Now(),
+		ChangedBy:     change.ChangedBy,
+		Source:        change.Source,
+		ReasonCode:    change.ReasonCode,
+		Reason:        change.Reason,
+	}
+	return db.Session(&gorm.Session{NewDB: true}).Create(&entry).Error
+}
+
+// statusTransitionError returns the message for a status change the lifecycle does not allow
+// Empty when the change is allowed
+func statusTransitionError(from, to string) string {
+	if !models.IsValidAppointmentStatus(to) {
+		return fmt.Sprintf("Status inválido: %s", to)
+	}
+	if models.CanTransitionAppointmentStatus(from, to) {
+		return ""
+	}
+	allowed := models.AllowedAppointmentStatusTransitions(from)
+	if len(allowed) == 0 {
+		return fmt.Sprintf("Não é possível alterar um agendamento com status '%s'", from)
+	}
+	return fmt.Sprintf("Não é possível alterar o status de '%s' para '%s'. Permitidos: %s", from, to, strings.Join(allowed, ", "))
+}
+
+// applyAppointmentStatus sets a new status with its side effects on the appointment row
+// Confirming records when it happened and releases bookings held by the no-show policy
+func applyAppointmentStatus(apt *models.Appointment, status string) {
+	apt.Status = status
+	if status != models.AppointmentStatusConfirmed {
+		return
+	}
+	if !apt.Confirmed || apt.ConfirmedAt == nil {
+		now := time.Now()
+		apt.Confirmed = true
+		apt.ConfirmedAt = &now
+	}
+	apt.StaffConfirmationRequired = false
+}
+
+// checkStatusReopen verifies the agenda is still free when a cancelled or no-show appointment becomes active again
+// Returns the conflict message, empty when the slot is free or the change does not reopen the appointment
+func checkStatusReopen(db *gorm.DB, tenantID uint, apt models.Appointment, to string, excludeIDs ...uint) (string, error) {
+	if models.IsActiveAppointmentStatus(apt.Status) || !models.IsActiveAppointmentStatus(to) {
+		return "", nil
+	}
+	hasConflict, message, err := checkAppointmentConflict(db, tenantID, apt, append(excludeIDs, apt.ID)...)
+	if err != nil || !hasConflict {
+		return "", err
+	}
+	return message, nil
+}
+
+// saveAppointmentStatus writes the status of an appointment changed by applyAppointmentStatus and records it in the history
+// Completing the appointment ticks off planItems, the treatment plan procedures performed
+func saveAppointmentStatus(tx *gorm.DB, apt models.Appointment, previousStatus string, change appointmentStatusChange, planItems []models.TreatmentPlanItem) error {
+	if err := tx.Exec(`UPDATE appointments
+		SET status = ?, confirmed = ?, confirmed_at = ?, staff_confirmation_required = ?, updated_at = NOW()
+		WHERE id = ? AND deleted_at IS NULL`,
+		apt.Status, apt.Confirmed, apt.ConfirmedAt, apt.StaffConfirmationRequired, apt.ID).Error; err != nil {
+		return err
+	}
+	if err := recordAppointmentStatus(tx, apt.ID, previousStatus, apt.Status, change); err != nil {
+		return err
+	}
+	if apt.Status != models.AppointmentStatusCompleted || previousStatus == models.AppointmentStatusCompleted {
+		return nil
+	}
+	return completeAppointmentPlanItems(tx, apt, planItems)
+}
+
+// validateCancellationReason checks the reason code sent with a cancellation
+func validateCancellationReason(c *gin.Context, status, reasonCode string) bool {
+	if status != models.AppointmentStatusCancelled || reasonCode == "" || models.IsValidCancellationReason(reasonCode) {
+		return true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Motivo de cancelamento inválido"})
+	return false
+}
+
+// AppointmentTimings holds the chairside times of an appointment, derived from its status history
+type AppointmentTimings struct {
+	AppointmentID uint       `json:"appointment_id"`
+	ArrivedAt     *time.Time `json:"arrived_at"`
+	StartedAt     *time.Time `json:"started_at"`
+	CompletedAt   *time.Time `json:"completed_at"`
+	WaitMinutes   *int       `json:"wait_minutes"`  // Check-in until seated in the chair
+	ChairMinutes  *int       `json:"chair_minutes"` // Seated in the chair until completed
+}
+
+// computeAppointmentTimings derives the wait and chair times from a status history
+// The latest entry of each status wins, so a reopened appointment reflects its last visit
+func computeAppointmentTimings(appointmentID uint, history []models.AppointmentStatusHistory) AppointmentTimings {
+	timings := AppointmentTimings{AppointmentID: appointmentID}
+	for i := range history {
+		entry := history[i]
+		if entry.AppointmentID != appointmentID {
+			continue
+		}
+		changedAt := entry.ChangedAt
+		switch entry.ToStatus {
+		case models.AppointmentStatusArrived:
+			if timings.ArrivedAt == nil || changedAt.After(*timings.ArrivedAt) {
+				timings.ArrivedAt = &changedAt
+			}
+		case models.AppointmentStatusInProgress:
+			if timings.StartedAt == nil || changedAt.After(*timings.StartedAt) {
+				timings.StartedAt = &changedAt
+			}
+		case models.AppointmentStatusCompleted:
+			if timings.CompletedAt == nil || changedAt.After(*timings.CompletedAt) {
+				timings.CompletedAt = &changedAt
+			}
+		}
+	}
+
+	timings.WaitMinutes = minutesBetween(timings.ArrivedAt, timings.StartedAt)
+	timings.ChairMinutes = minutesBetween(timings.StartedAt, timings.CompletedAt)
+	return timings
+}
+
+// minutesBetween returns the rounded minutes from start to end, nil when either is missing or out of order
+func minutesBetween(start, end *time.Time) *int {
+	if start == nil || end == nil || end.Before(*start) {
+		return nil
+	}
+	minutes := int(math.Round(end.Sub(*start).Minutes()))
+	return &minutes
+}
+
+// loadStatusHistory returns the status history of the given appointments, oldest first
+func loadStatusHistory(db *gorm.DB, appointmentIDs []uint) ([]models.AppointmentStatusHistory, error) {
+	var history []models.AppointmentStatusHistory
+	if len(appointmentIDs) == 0 {
+		return history, nil
+	}
+	err := db.Session(&gorm.Session{NewDB: true}).
+		Where("appointment_id IN ?", appointmentIDs).
+		Order("changed_at ASC, id ASC").
+		Find(&history).Error
+	return history, err
+}
+
+// GetAppointmentStatusHistory returns who changed the status of an appointment, when and why
+// GET /appointments/:id/status-history
+func GetAppointmentStatusHistory(c *gin.Context) {
+	id := c.Param("id")
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var appointment models.Appointment
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&appointment, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agendamento não encontrado"})
+		return
+	}
+
+	history, err := loadStatusHistory(db, []uint{appointment.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar histórico de status"})
+		return
+	}
+
+	// Resolve user names in one query
+	var userIDs []uint
+	for _, entry := range history {
+		if entry.ChangedBy != nil {
+			userIDs = append(userIDs, *entry.ChangedBy)
+		}
+	}
+	if len(userIDs) > 0 {
+		var users []struct {
+			ID   uint
+			Name string
+		}
+		db.Session(&gorm.Session{NewDB: true}).Raw("SELECT id, name FROM public.users WHERE id IN ?", userIDs).Scan(&users)
+		names := make(map[uint]string, len(users))
+		for _, user := range users {
+			names[user.ID] = user.Name
+		}
+		for i := range history {
+			if history[i].ChangedBy != nil {
+				history[i].ChangedByName = names[*history[i].ChangedBy]
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"appointment_id":      appointment.ID,
+		"status":              appointment.Status,
+		"allowed_transitions": models.AllowedAppointmentStatusTransitions(appointment.Status),
+		"history":             history,
+		"timings":             computeAppointmentTimings(appointment.ID, history),
+	})
+}
+
+// appointmentTimingsRow is an appointment listed in the timings report
+type appointmentTimingsRow struct {
+	AppointmentTimings
+	PatientName string           `json:"patient_name"`
+	DentistID   uint             `json:"dentist_id"`
+	DentistName string           `json:"dentist_name"`
+	Procedure   string           `json:"procedure"`
+	Status      string           `json:"status"`
+	StartTime   models.LocalTime `json:"start_time"`
+}
+
+// GetAppointmentTimings lists the wait time and chair time of the appointments in a period
+// GET /appointments/timings?start_date=YYYY-MM-DD&end_date=YYYY-MM-DD&dentist_id=X
+func GetAppointmentTimings(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	query := db.Session(&gorm.Session{NewDB: true}).
+		Table("appointments a").
+		Select(`a.id, a.start_time, a.status, a.procedure, a.dentist_id,
+			COALESCE(p.name, '') as patient_name, COALESCE(u.name, '') as dentist_name`).
+		Joins("LEFT JOIN patients p ON a.patient_id = p.id").
+		Joins("LEFT JOIN public.users u ON a.dentist_id = u.id").
+		Where("a.deleted_at IS NULL").
+		Where("a.status IN ?", []string{models.AppointmentStatusArrived, models.AppointmentStatusInProgress, models.AppointmentStatusCompleted})
+
+	if startDate := c.Query("start_date"); startDate != "" {
+		query = query.Where("DATE(a.start_time) >= ?", startDate)
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		query = query.Where("DATE(a.start_time) <= ?", endDate)
+	}
+	if dentistID := c.Query("dentist_id"); dentistID != "" {
+		query = query.Where("a.dentist_id = ?", dentistID)
+	}
+
+	var appointments []struct {
+		ID          uint
+		StartTime   models.LocalTime
+		Status      string
+		Procedure   string
+		DentistID   uint
+		PatientName string
+		DentistName string
+	}
+	if err := query.Order("a.start_time ASC").Scan(&appointments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar agendamentos"})
+		return
+	}
+
+	ids := make([]uint, 0, len(appointments))
+	for _, apt := range appointments {
+		ids = append(ids, apt.ID)
+	}
+	history, err := loadStatusHistory(db, ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar histórico de status"})
+		return
+	}
+	byAppointment := make(map[uint][]models.AppointmentStatusHistory, len(ids))
+	for _, entry := range history {
+		byAppointment[entry.AppointmentID] = append(byAppointment[entry.AppointmentID], entry)
+	}
+
+	rows := make([]appointmentTimingsRow, 0, len(appointments))
+	var waitTotal, waitCount, chairTotal, chairCount int
+	for _, apt := range appointments {
+		timings := computeAppointmentTimings(apt.ID, byAppointment[apt.ID])
+		if timings.WaitMinutes != nil {
+			waitTotal += *timings.WaitMinutes
+			waitCount++
+		}
+		if timings.ChairMinutes != nil {
+			chairTotal += *timings.ChairMinutes
+			chairCount++
+		}
+		rows = append(rows, appointmentTimingsRow{
+			AppointmentTimings: timings,
+			PatientName:        apt.PatientName,
+			DentistID:          apt.DentistID,
+			DentistName:        apt.DentistName,
+			Procedure:          apt.Procedure,
+			Status:             apt.Status,
+			StartTime:          apt.StartTime,
+		})
+	}
+
+	summary := gin.H{
+		"appointments":      len(rows),
+		"avg_wait_minutes":  nil,
+		"avg_chair_minutes": nil,
+	}
+	if waitCount > 0 {
+		summary["avg_wait_minutes"] = math.Round(float64(waitTotal)/float64(waitCount)*10) / 10
+	}
+	if chairCount > 0 {
+		summary["avg_chair_minutes"] = math.Round(float64(chairTotal)/float64(chairCount)*10) / 10
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"timings": rows,
+		"summary": summary,
+	})
+}
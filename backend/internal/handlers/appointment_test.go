@@ -10,6 +10,10 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"drcrwell/backend/internal/database"
+	"drcrwell/backend/internal/models"
 )
 
 func TestCreateAppointment_Success(t *testing.T) {
@@ -431,3 +435,599 @@ func TestCreateAppointment_NoDB(t *testing.T) {
 		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, w.Code)
 	}
 }
+
+func TestCreateAppointment_RecurringSeries(t *testing.T) {
+	db := setupTestDB()
+
+	patient := createTestPatient(db, "Test Patient", "11999999999")
+	user := createTestUser(db, "Dr. Test", "dr@test.com")
+
+	startTime := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	body := map[string]interface{}{
+		"patient_id":      patient.ID,
+		"dentist_id":      user.ID,
+		"start_time":      startTime.Format("2006-01-02T15:04:05"),
+		"end_time":        startTime.Add(30 * time.Minute).Format("2006-01-02T15:04:05"),
+		"procedure":       "Manutenção ortodôntica",
+		"status":          "scheduled",
+		"is_recurring":    true,
+		"recurrence_rule": "FREQ=WEEKLY;COUNT=4",
+	}
+
+	c, w := setupTestContextWithBody(db, body)
+
+	CreateAppointment(c)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	result := parseJSONResponse(w)
+	if result["occurrences"] != float64(4) {
+		t.Errorf("Expected 4 occurrences, got %v", result["occurrences"])
+	}
+
+	var count int64
+	db.Table("appointments").Where("series_id = ?", result["series_id"]).Count(&count)
+	if count != 4 {
+		t.Errorf("Expected 4 appointments in series, got %d", count)
+	}
+}
+
+func TestCreateAppointment_RecurringSeriesConflict(t *testing.T) {
+	db := setupTestDB()
+
+	patient := createTestPatient(db, "Test Patient", "11999999999")
+	user := createTestUser(db, "Dr. Test", "dr@test.com")
+
+	startTime := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+
+	// Existing appointment on the third week blocks the whole series
+	createTestAppointment(db, patient.ID, user.ID, startTime.AddDate(0, 0, 14), "scheduled")
+
+	body := map[string]interface{}{
+		"patient_id":      patient.ID,
+		"dentist_id":      user.ID,
+		"start_time":      startTime.Format("2006-01-02T15:04:05"),
+		"end_time":        startTime.Add(30 * time.Minute).Format("2006-01-02T15:04:05"),
+		"status":          "scheduled",
+		"is_recurring":    true,
+		"recurrence_rule": "FREQ=WEEKLY;COUNT=4",
+	}
+
+	c, w := setupTestContextWithBody(db, body)
+
+	CreateAppointment(c)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+
+	var count int64
+	db.Table("appointments").Where("series_id IS NOT NULL").Count(&count)
+	if count != 0 {
+		t.Errorf("Expected no series appointments to be created, got %d", count)
+	}
+}
+
+func TestUpdateAppointmentStatus_CancelFollowing(t *testing.T) {
+	db := setupTestDB()
+
+	patient := createTestPatient(db, "Test Patient", "11999999999")
+	user := createTestUser(db, "Dr. Test", "dr@test.com")
+
+	startTime := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	body := map[string]interface{}{
+		"patient_id":      patient.ID,
+		"dentist_id":      user.ID,
+		"start_time":      startTime.Format("2006-01-02T15:04:05"),
+		"end_time":        startTime.Add(30 * time.Minute).Format("2006-01-02T15:04:05"),
+		"status":          "scheduled",
+		"is_recurring":    true,
+		"recurrence_rule": "FREQ=WEEKLY;COUNT=4",
+	}
+	c, w := setupTestContextWithBody(db, body)
+	CreateAppointment(c)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	seriesID := parseJSONResponse(w)["series_id"]
+
+	var second models.Appointment
+	db.Where("series_id = ? AND series_index = ?", seriesID, 2).First(&second)
+
+	jsonBody, _ := json.Marshal(map[string]interface{}{"status": "cancelled"})
+	c, w = setupTestContext(db)
+	c.Request = httptest.NewRequest(http.MethodPatch, "/?scope=following", bytes.NewBuffer(jsonBody))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprintf("%d", second.ID)}}
+
+	UpdateAppointmentStatus(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var cancelled int64
+	db.Table("appointments").Where("series_id = ? AND status = ?", seriesID, "cancelled").Count(&cancelled)
+	if cancelled != 3 {
+		t.Errorf("Expected 3 cancelled occurrences, got %d", cancelled)
+	}
+}
+
+func TestUpdateAppointmentStatus_ConfirmFollowingRecordsEachOccurrence(t *testing.T) {
+	db := setupTestDB()
+
+	patient := createTestPatient(db, "Test Patient", "11999999999")
+	user := createTestUser(db, "Dr. Test", "dr@test.com")
+
+	startTime := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	body := map[string]interface{}{
+		"patient_id":      patient.ID,
+		"dentist_id":      user.ID,
+		"start_time":      startTime.Format("2006-01-02T15:04:05"),
+		"end_time":        startTime.Add(30 * time.Minute).Format("2006-01-02T15:04:05"),
+		"status":          "scheduled",
+		"is_recurring":    true,
+		"recurrence_rule": "FREQ=WEEKLY;COUNT=3",
+	}
+	c, w := setupTestContextWithBody(db, body)
+	CreateAppointment(c)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	seriesID := parseJSONResponse(w)["series_id"]
+
+	var first models.Appointment
+	db.Where("series_id = ? AND series_index = ?", seriesID, 1).First(&first)
+
+	jsonBody, _ := json.Marshal(map[string]interface{}{"status": "confirmed"})
+	c, w = setupTestContext(db)
+	c.Request = httptest.NewRequest(http.MethodPatch, "/?scope=all", bytes.NewBuffer(jsonBody))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprintf("%d", first.ID)}}
+
+	UpdateAppointmentStatus(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var occurrences []models.Appointment
+	db.Where("series_id = ?", seriesID).Find(&occurrences)
+	for _, occ := range occurrences {
+		if !occ.Confirmed || occ.ConfirmedAt == nil {
+			t.Errorf("Expected occurrence %d confirmed with a timestamp", occ.SeriesIndex)
+		}
+		var history int64
+		db.Model(&models.AppointmentStatusHistory{}).Where("appointment_id = ? AND to_status = ?", occ.ID, "confirmed").Count(&history)
+		if history != 1 {
+			t.Errorf("Expected 1 history entry for occurrence %d, got %d", occ.SeriesIndex, history)
+		}
+	}
+}
+
+func TestUpdateAppointmentStatus_ReopenTakenSlot(t *testing.T) {
+	db := setupTestDB()
+
+	patient := createTestPatient(db, "Test Patient", "11999999999")
+	user := createTestUser(db, "Dr. Test", "dr@test.com")
+	startTime := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	cancelled := createTestAppointment(db, patient.ID, user.ID, startTime, "cancelled")
+	createTestAppointment(db, patient.ID, user.ID, startTime, "scheduled")
+
+	jsonBody, _ := json.Marshal(map[string]interface{}{"status": "scheduled"})
+	c, w := setupTestContext(db)
+	c.Request = httptest.NewRequest(http.MethodPatch, "/", bytes.NewBuffer(jsonBody))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprintf("%d", cancelled.ID)}}
+
+	UpdateAppointmentStatus(c)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d. Body: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+}
+
+func TestUpdateAppointment_CancelRecordsReason(t *testing.T) {
+	db := setupTestDB()
+
+	patient := createTestPatient(db, "Test Patient", "11999999999")
+	user := createTestUser(db, "Dr. Test", "dr@test.com")
+	startTime := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	appointment := createTestAppointment(db, patient.ID, user.ID, startTime, "scheduled")
+
+	body := map[string]interface{}{
+		"patient_id":  patient.ID,
+		"dentist_id":  user.ID,
+		"start_time":  startTime.Format("2006-01-02T15:04:05"),
+		"end_time":    startTime.Add(1 * time.Hour).Format("2006-01-02T15:04:05"),
+		"status":      "cancelled",
+		"reason_code": "invalid",
+	}
+	jsonBody, _ := json.Marshal(body)
+	c, w := setupTestContext(db)
+	c.Request = httptest.NewRequest(http.MethodPut, "/", bytes.NewBuffer(jsonBody))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprintf("%d", appointment.ID)}}
+
+	UpdateAppointment(c)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d for an unknown reason, got %d. Body: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+
+	body["reason_code"] = models.CancellationReasonPatientRequest
+	jsonBody, _ = json.Marshal(body)
+	c, w = setupTestContext(db)
+	c.Request = httptest.NewRequest(http.MethodPut, "/", bytes.NewBuffer(jsonBody))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprintf("%d", appointment.ID)}}
+
+	UpdateAppointment(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var entry models.AppointmentStatusHistory
+	db.Where("appointment_id = ? AND to_status = ?", appointment.ID, "cancelled").First(&entry)
+	if entry.ReasonCode != models.CancellationReasonPatientRequest {
+		t.Errorf("Expected reason %q, got %q", models.CancellationReasonPatientRequest, entry.ReasonCode)
+	}
+}
+
+func TestCreateAppointment_OutsideProfessionalSchedule(t *testing.T) {
+	db := setupTestDB()
+
+	patient := createTestPatient(db, "Test Patient", "11999999999")
+	user := createTestUser(db, "Dr. Test", "dr@test.com")
+
+	loc := getTimezone()
+	day := time.Now().In(loc).AddDate(0, 0, 1)
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+
+	// Dentist only works mornings on that weekday
+	db.Create(&models.ProfessionalSchedule{DentistID: user.ID, Weekday: int(day.Weekday()), StartTime: "08:00", EndTime: "12:00"})
+
+	startTime := day.Add(15 * time.Hour)
+	body := map[string]interface{}{
+		"patient_id": patient.ID,
+		"dentist_id": user.ID,
+		"start_time": startTime.Format("2006-01-02T15:04:05"),
+		"end_time":   startTime.Add(30 * time.Minute).Format("2006-01-02T15:04:05"),
+		"status":     "scheduled",
+	}
+	c, w := setupTestContextWithBody(db, body)
+	CreateAppointment(c)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+
+	// Inside the working block it is accepted
+	startTime = day.Add(9 * time.Hour)
+	body["start_time"] = startTime.Format("2006-01-02T15:04:05")
+	body["end_time"] = startTime.Add(30 * time.Minute).Format("2006-01-02T15:04:05")
+	c, w = setupTestContextWithBody(db, body)
+	CreateAppointment(c)
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected status %d, got %d. Body: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+}
+
+func TestCreateAppointment_DuringProfessionalAbsence(t *testing.T) {
+	db := setupTestDB()
+
+	patient := createTestPatient(db, "Test Patient", "11999999999")
+	user := createTestUser(db, "Dr. Test", "dr@test.com")
+
+	loc := getTimezone()
+	startTime := time.Now().In(loc).Add(48 * time.Hour).Truncate(time.Hour)
+
+	db.Create(&models.ProfessionalAbsence{
+		DentistID: user.ID,
+		StartAt:   models.LocalTime{Time: startTime.Add(-24 * time.Hour)},
+		EndAt:     models.LocalTime{Time: startTime.Add(24 * time.Hour)},
+		Type:      models.AbsenceTypeVacation,
+	})
+
+	body := map[string]interface{}{
+		"patient_id": patient.ID,
+		"dentist_id": user.ID,
+		"start_time": startTime.Format("2006-01-02T15:04:05"),
+		"end_time":   startTime.Add(30 * time.Minute).Format("2006-01-02T15:04:05"),
+		"status":     "scheduled",
+	}
+	c, w := setupTestContextWithBody(db, body)
+	CreateAppointment(c)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d. Body: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+}
+
+func TestCreateAppointment_OnClinicClosure(t *testing.T) {
+	db := setupTestDB()
+
+	patient := createTestPatient(db, "Test Patient", "11999999999")
+	user := createTestUser(db, "Dr. Test", "dr@test.com")
+
+	loc := getTimezone()
+	startTime := time.Now().In(loc).Add(48 * time.Hour).Truncate(time.Hour)
+	day := time.Date(startTime.Year(), startTime.Month(), startTime.Day(), 0, 0, 0, 0, loc)
+
+	db.Create(&models.ClinicClosure{
+		Date: day,
+		Name: "Feriado municipal",
+		Type: models.ClosureTypeMunicipal,
+	})
+
+	body := map[string]interface{}{
+		"patient_id": patient.ID,
+		"dentist_id": user.ID,
+		"start_time": startTime.Format("2006-01-02T15:04:05"),
+		"end_time":   startTime.Add(30 * time.Minute).Format("2006-01-02T15:04:05"),
+		"status":     "scheduled",
+	}
+	c, w := setupTestContextWithBody(db, body)
+	CreateAppointment(c)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d. Body: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+}
+
+func TestCreateAppointment_RoomConflict(t *testing.T) {
+	db := setupTestDB()
+
+	patient := createTestPatient(db, "Test Patient", "11999999999")
+	dentistA := createTestUser(db, "Dr. A", "dra@test.com")
+	dentistB := createTestUser(db, "Dr. B", "drb@test.com")
+
+	room := models.Room{Name: "Cadeira 1", Type: models.RoomTypeChair, Active: true}
+	db.Create(&room)
+
+	startTime := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	existing := models.Appointment{
+		PatientID: patient.ID,
+		DentistID: dentistA.ID,
+		StartTime: models.LocalTime{Time: startTime},
+		EndTime:   models.LocalTime{Time: startTime.Add(time.Hour)},
+		Status:    "scheduled",
+		RoomID:    &room.ID,
+	}
+	db.Create(&existing)
+
+	// Another dentist, same chair, overlapping period
+	body := map[string]interface{}{
+		"patient_id": patient.ID,
+		"dentist_id": dentistB.ID,
+		"room_id":    room.ID,
+		"start_time": startTime.Add(30 * time.Minute).Format("2006-01-02T15:04:05"),
+		"end_time":   startTime.Add(90 * time.Minute).Format("2006-01-02T15:04:05"),
+		"status":     "scheduled",
+	}
+	c, w := setupTestContextWithBody(db, body)
+	CreateAppointment(c)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d. Body: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+}
+
+func TestOverlapConflictMessage(t *testing.T) {
+	roomErr := fmt.Errorf("commit: %w", &pgconn.PgError{Code: "23P01", ConstraintName: database.AppointmentRoomOverlapConstraint})
+	if message, ok := overlapConflictMessage(roomErr); !ok || message != roomConflictMessage {
+		t.Errorf("Expected room conflict message, got %q (ok=%v)", message, ok)
+	}
+
+	dentistErr := &pgconn.PgError{Code: "23P01", ConstraintName: database.AppointmentDentistOverlapConstraint}
+	if message, ok := overlapConflictMessage(dentistErr); !ok || message != appointmentConflictMessage {
+		t.Errorf("Expected dentist conflict message, got %q (ok=%v)", message, ok)
+	}
+
+	if _, ok := overlapConflictMessage(&pgconn.PgError{Code: "23505"}); ok {
+		t.Error("Unique violations must not be reported as double bookings")
+	}
+}
+
+func TestAppointmentOverlapConstraint(t *testing.T) {
+	db := setupTestDB()
+	database.ApplyAppointmentOverlapConstraints(testSchema)
+
+	var exists int64
+	db.Raw("SELECT COUNT(*) FROM pg_constraint WHERE conname = ?", database.AppointmentDentistOverlapConstraint).Scan(&exists)
+	if exists == 0 {
+		t.Skip("btree_gist not available in the test database")
+	}
+
+	patient := createTestPatient(db, "Test Patient", "11999999999")
+	user := createTestUser(db, "Dr. Test", "dr@test.com")
+	startTime := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	createTestAppointment(db, patient.ID, user.ID, startTime, "scheduled")
+
+	// Simulates a concurrent request that skipped the application check
+	overlapping := models.Appointment{
+		PatientID: patient.ID,
+		DentistID: user.ID,
+		StartTime: models.LocalTime{Time: startTime.Add(30 * time.Minute)},
+		EndTime:   models.LocalTime{Time: startTime.Add(90 * time.Minute)},
+		Status:    "scheduled",
+	}
+	err := db.Create(&overlapping).Error
+	if _, ok := overlapConflictMessage(err); !ok {
+		t.Fatalf("Expected exclusion violation, got %v", err)
+	}
+
+	// Cancelled appointments do not block the slot
+	overlapping.ID = 0
+	overlapping.Status = "cancelled"
+	if err := db.Create(&overlapping).Error; err != nil {
+		t.Fatalf("Expected cancelled appointment to be accepted, got %v", err)
+	}
+}
+
+func TestAppointmentOverlapConstraint_ReportsAndRetries(t *testing.T) {
+	db := setupTestDB()
+	database.ApplyAppointmentOverlapConstraints(testSchema)
+
+	var exists int64
+	db.Raw("SELECT COUNT(*) FROM pg_constraint WHERE conname = ?", database.AppointmentDentistOverlapConstraint).Scan(&exists)
+	if exists == 0 {
+		t.Skip("btree_gist not available in the test database")
+	}
+
+	// Legacy data: double booking recorded before the constraint existed
+	db.Exec(fmt.Sprintf("ALTER TABLE %s.appointments DROP CONSTRAINT %s", testSchema, database.AppointmentDentistOverlapConstraint))
+	patient := createTestPatient(db, "Test Patient", "11999999999")
+	user := createTestUser(db, "Dr. Test", "dr@test.com")
+	startTime := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	first := createTestAppointment(db, patient.ID, user.ID, startTime, "scheduled")
+	second := createTestAppointment(db, patient.ID, user.ID, startTime.Add(30*time.Minute), "scheduled")
+
+	statuses, err := database.ApplyAppointmentOverlapConstraints(testSchema)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, status := range statuses {
+		if status.Name == database.AppointmentDentistOverlapConstraint && (status.Applied || status.Overlaps != 1) {
+			t.Errorf("Expected dentist constraint blocked by 1 overlap, got %+v", status)
+		}
+	}
+
+	overlaps, err := database.FindAppointmentOverlaps(testSchema)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(overlaps) != 1 || overlaps[0].FirstID != first.ID || overlaps[0].SecondID != second.ID {
+		t.Fatalf("Expected the overlapping pair to be reported, got %+v", overlaps)
+	}
+
+	// Once resolved, the retry adds the constraint
+	db.Model(&models.Appointment{}).Where("id = ?", second.ID).Update("status", "cancelled")
+	statuses, err = database.ApplyAppointmentOverlapConstraints(testSchema)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, status := range statuses {
+		if status.Name == database.AppointmentDentistOverlapConstraint && !status.Applied {
+			t.Errorf("Expected dentist constraint applied after resolving the overlap, got %+v", status)
+		}
+	}
+}
+
+func TestUpdateAppointmentStatus_InvalidTransition(t *testing.T) {
+	db := setupTestDB()
+
+	patient := createTestPatient(db, "Test Patient", "11999999999")
+	user := createTestUser(db, "Dr. Test", "dr@test.com")
+	appointment := createTestAppointment(db, patient.ID, user.ID, time.Now().Add(24*time.Hour), "completed")
+
+	jsonBody, _ := json.Marshal(map[string]interface{}{"status": "scheduled"})
+	c, w := setupTestContext(db)
+	c.Request = httptest.NewRequest(http.MethodPatch, "/", bytes.NewBuffer(jsonBody))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprintf("%d", appointment.ID)}}
+
+	UpdateAppointmentStatus(c)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d. Body: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+
+	var history int64
+	db.Model(&models.AppointmentStatusHistory{}).Where("appointment_id = ?", appointment.ID).Count(&history)
+	if history != 0 {
+		t.Errorf("Expected no history for a rejected change, got %d entries", history)
+	}
+}
+
+func TestUpdateAppointmentStatus_RecordsHistory(t *testing.T) {
+	db := setupTestDB()
+
+	patient := createTestPatient(db, "Test Patient", "11999999999")
+	user := createTestUser(db, "Dr. Test", "dr@test.com")
+	appointment := createTestAppointment(db, patient.ID, user.ID, time.Now().Add(24*time.Hour), "scheduled")
+
+	jsonBody, _ := json.Marshal(map[string]interface{}{
+		"status":      "cancelled",
+		"reason_code": models.CancellationReasonPatientIllness,
+		"reason":      "Paciente com febre",
+	})
+	c, w := setupTestContext(db)
+	c.Request = httptest.NewRequest(http.MethodPatch, "/", bytes.NewBuffer(jsonBody))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprintf("%d", appointment.ID)}}
+
+	UpdateAppointmentStatus(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var history []models.AppointmentStatusHistory
+	db.Where("appointment_id = ?", appointment.ID).Find(&history)
+	if len(history) != 1 {
+		t.Fatalf("Expected 1 history entry, got %d", len(history))
+	}
+	entry := history[0]
+	if entry.FromStatus != "scheduled" || entry.ToStatus != "cancelled" {
+		t.Errorf("Expected scheduled -> cancelled, got %s -> %s", entry.FromStatus, entry.ToStatus)
+	}
+	if entry.ReasonCode != models.CancellationReasonPatientIllness || entry.Reason != "Paciente com febre" {
+		t.Errorf("Expected cancellation reason to be recorded, got %q / %q", entry.ReasonCode, entry.Reason)
+	}
+	if entry.ChangedBy == nil || entry.Source != models.StatusSourceStaff {
+		t.Errorf("Expected change by the logged user, got %v (%s)", entry.ChangedBy, entry.Source)
+	}
+}
+
+func TestComputeAppointmentTimings(t *testing.T) {
+	base := time.Date(2026, 3, 5, 9, 0, 0, 0, time.UTC)
+	history := []models.AppointmentStatusHistory{
+		{AppointmentID: 1, ToStatus: "confirmed", ChangedAt: base.Add(-24 * time.Hour)},
+		{AppointmentID: 1, ToStatus: "arrived", ChangedAt: base},
+		{AppointmentID: 1, ToStatus: "in_progress", ChangedAt: base.Add(12 * time.Minute)},
+		{AppointmentID: 1, ToStatus: "completed", ChangedAt: base.Add(57 * time.Minute)},
+		{AppointmentID: 2, ToStatus: "arrived", ChangedAt: base},
+	}
+
+	timings := computeAppointmentTimings(1, history)
+	if timings.WaitMinutes == nil || *timings.WaitMinutes != 12 {
+		t.Errorf("Expected wait time of 12 minutes, got %v", timings.WaitMinutes)
+	}
+	if timings.ChairMinutes == nil || *timings.ChairMinutes != 45 {
+		t.Errorf("Expected chair time of 45 minutes, got %v", timings.ChairMinutes)
+	}
+
+	// Without check-in there is no wait time
+	timings = computeAppointmentTimings(1, history[2:4])
+	if timings.WaitMinutes != nil {
+		t.Errorf("Expected no wait time without check-in, got %d", *timings.WaitMinutes)
+	}
+}
+
+func TestCanTransitionAppointmentStatus(t *testing.T) {
+	cases := []struct {
+		from, to string
+		allowed  bool
+	}{
+		{"scheduled", "confirmed", true},
+		{"confirmed", "arrived", true},
+		{"arrived", "in_progress", true},
+		{"in_progress", "completed", true},
+		{"scheduled", "completed", true},
+		{"cancelled", "scheduled", true},
+		{"completed", "scheduled", false},
+		{"in_progress", "cancelled", false},
+		{"arrived", "no_show", false},
+		{"scheduled", "unknown", false},
+	}
+	for _, tc := range cases {
+		if got := models.CanTransitionAppointmentStatus(tc.from, tc.to); got != tc.allowed {
+			t.Errorf("%s -> %s: expected %v, got %v", tc.from, tc.to, tc.allowed, got)
+		}
+	}
+}
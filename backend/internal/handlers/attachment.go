@@ -1,15 +1,19 @@
 package handlers
 
 import (
+	"drcrwell/backend/internal/helpers"
 	"drcrwell/backend/internal/middleware"
 	"drcrwell/backend/internal/models"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 const uploadPath = "./uploads"
@@ -48,13 +52,26 @@ func UploadAttachment(c *gin.Context) {
 		return
 	}
 
+	data, err := readMultipartFile(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+
+	// Remove the GPS location of photos before storing them (privacy)
+	if helpers.DetectFileType(data) == helpers.FileTypeImage {
+		if stripped, changed := stripImageGPS(data); changed {
+			data = stripped
+		}
+	}
+
 	// Generate unique filename
 	ext := filepath.Ext(file.Filename)
 	filename := fmt.Sprintf("%d_%s%s", time.Now().Unix(), patientIDStr, ext)
 	filePath := filepath.Join(uploadPath, filename)
 
 	// Save file
-	if err := c.SaveUploadedFile(file, filePath); err != nil {
+	if err := os.WriteFile(filePath, data, 0644); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
 		return
 	}
@@ -66,7 +83,7 @@ func UploadAttachment(c *gin.Context) {
 		FilePath:     filePath,
 		FileType:     c.PostForm("file_type"),
 		MimeType:     file.Header.Get("Content-Type"),
-		FileSize:     file.Size,
+		FileSize:     int64(len(data)),
 		Category:     c.PostForm("category"),
 		Description:  c.PostForm("description"),
 		UploadedByID: userID,
@@ -78,6 +95,10 @@ func UploadAttachment(c *gin.Context) {
 		return
 	}
 
+	// Thumbnail and preview are generated in the background
+	queueAttachmentPreviews(c, db, attachment.ID)
+	attachment.PreviewStatus = models.ExamPreviewPending
+
 	c.JSON(http.StatusCreated, gin.H{"attachment": attachment})
 }
 
@@ -100,6 +121,26 @@ func GetAttachment(c *gin.Context) {
 		return
 	}
 
+	// ?rendition=thumbnail|preview serves the generated image instead of the original
+	if rendition := c.Query("rendition"); rendition != "" {
+		renditionPath := ""
+		switch rendition {
+		case "thumbnail":
+			renditionPath = attachment.ThumbnailPath
+		case "preview":
+			renditionPath = attachment.PreviewPath
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rendition. Use thumbnail or preview"})
+			return
+		}
+		if renditionPath == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Preview not available"})
+			return
+		}
+		c.File(renditionPath)
+		return
+	}
+
 	// If download query param is present, serve file for download
 	if c.Query("download") == "true" {
 		c.FileAttachment(attachment.FilePath, attachment.FileName)
@@ -127,6 +168,12 @@ func DeleteAttachment(c *gin.Context) {
 	if err := os.Remove(attachment.FilePath); err != nil {
 		fmt.Println("Failed to delete file:", err)
 	}
+	if attachment.ThumbnailPath != "" {
+		os.Remove(attachment.ThumbnailPath)
+	}
+	if attachment.PreviewPath != "" {
+		os.Remove(attachment.PreviewPath)
+	}
 
 	// Delete from database
 	if err := db.Delete(&attachment).Error; err != nil {
@@ -137,6 +184,78 @@ func DeleteAttachment(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Attachment deleted successfully"})
 }
 
+// queueAttachmentPreviews marks the attachment as pending and generates its thumbnail and preview
+// in the background, sharing the exam preview slots
+func queueAttachmentPreviews(c *gin.Context, db *gorm.DB, attachmentID uint) {
+	schemaName := c.GetString("schema")
+	db.Exec("UPDATE attachments SET preview_status = ?, preview_error = '' WHERE id = ?", models.ExamPreviewPending, attachmentID)
+
+	go func() {
+		examPreviewSlots <- struct{}{}
+		defer func() { <-examPreviewSlots }()
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("ERROR generating previews of attachment %d: %v", attachmentID, r)
+			}
+		}()
+		generateAttachmentPreviews(schemaName, attachmentID)
+	}()
+}
+
+// generateAttachmentPreviews builds the renditions of an attachment, stores them under
+// uploads/renditions/[tenant schema]/attachments/[id] and records the result on the attachment
+func generateAttachmentPreviews(schemaName string, attachmentID uint) {
+	var attachment models.Attachment
+	if err := withTenantSchema(schemaName, func(tx *gorm.DB) error {
+		return tx.First(&attachment, attachmentID).Error
+	}); err != nil {
+		log.Printf("ERROR loading attachment %d for previews: %v", attachmentID, err)
+		return
+	}
+
+	status, message := models.ExamPreviewReady, ""
+	thumbnailPath, previewPath := attachment.ThumbnailPath, attachment.PreviewPath
+	dir := filepath.Join(uploadPath, "renditions", schemaName, "attachments", fmt.Sprint(attachment.ID))
+
+	data, err := os.ReadFile(attachment.FilePath)
+	var renditions *helpers.ImageRenditions
+	if err == nil {
+		renditions, err = buildFileRenditions(data)
+	}
+	if err == nil {
+		err = os.MkdirAll(dir, 0755)
+	}
+	if err == nil {
+		thumbnailPath = filepath.Join(dir, "thumbnail.jpg")
+		err = os.WriteFile(thumbnailPath, renditions.Thumbnail, 0644)
+	}
+	if err == nil && renditions.Preview != nil {
+		previewPath = filepath.Join(dir, "preview.jpg")
+		err = os.WriteFile(previewPath, renditions.Preview, 0644)
+	}
+
+	switch {
+	case errors.Is(err, errNoExamPreview), errors.Is(err, helpers.ErrConverterUnavailable):
+		status = models.ExamPreviewUnsupported
+	case err != nil:
+		status, message = models.ExamPreviewFailed, err.Error()
+		if len(message) > 255 {
+			message = message[:255]
+		}
+		log.Printf("ERROR generating previews of attachment %d: %v", attachmentID, err)
+	}
+
+	if err := withTenantSchema(schemaName, func(tx *gorm.DB) error {
+		return tx.Exec(`
+			UPDATE attachments
+			SET thumbnail_path = ?, preview_path = ?, preview_status = ?, preview_error = ?, updated_at = NOW()
+			WHERE id = ?
+		`, thumbnailPath, previewPath, status, message, attachmentID).Error
+	}); err != nil {
+		log.Printf("ERROR saving previews of attachment %d: %v", attachmentID, err)
+	}
+}
+
 func parseUint(s string) uint {
 	var result uint
 	fmt.Sscanf(s, "%d", &result)
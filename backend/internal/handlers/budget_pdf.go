@@ -5,6 +5,7 @@ import (
 	"drcrwell/backend/internal/middleware"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"time"
 
@@ -172,6 +173,17 @@ func GenerateBudgetPDF(c *gin.Context) {
 		pdf.Ln(5)
 	}
 
+	// PIX payment of the open amount, with a static code the clinic confirms in the statement
+	if config, ok := loadPixConfig(db, tenantID); ok {
+		if target, err := budgetPixTarget(db, budget); err == nil {
+			if charge, err := findOrIssuePixCharge(db, config, target, false, 0); err == nil {
+				pdfPixSection(pdf, tr, "Pagamento via PIX", charge)
+			} else {
+				log.Printf("ERROR issuing PIX charge of budget %d: %v", budget.ID, err)
+			}
+		}
+	}
+
 	// Footer
 	pdf.Ln(5)
 	pdf.SetFont("Arial", "I", 8)
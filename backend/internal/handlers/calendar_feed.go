@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/database"
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Calendar feed window: recent history plus the upcoming agenda
+const (
+	calendarFeedPastDays   = 30
+	calendarFeedFutureDays = 180
+)
+
+// CalendarFeedResponse is a feed with its subscription URLs (the token is only exposed through them)
+type CalendarFeedResponse struct {
+	models.CalendarFeed
+	URL       string `json:"url"`
+	WebcalURL string `json:"webcal_url"`
+}
+
+// CalendarFeedRequest is the payload to create a feed
+type CalendarFeedRequest struct {
+	OwnerType string `json:"owner_type" binding:"required"`
+	OwnerID   uint   `json:"owner_id"`
+}
+
+// calendarFeedEntry is an appointment row rendered into a feed
+type calendarFeedEntry struct {
+	ID          uint
+	StartTime   time.Time
+	EndTime     time.Time
+	Status      string
+	Procedure   string
+	Room        string
+	PatientName string
+	DentistName string
+	UpdatedAt   time.Time
+}
+
+// calendarFeedURL returns the subscription URL served by the API
+// BACKEND_URL overrides the host that received the request
+func calendarFeedURL(c *gin.Context, token string) string {
+	baseURL := os.Getenv("BACKEND_URL")
+	if baseURL == "" {
+		baseURL = "https://" + c.Request.Host
+	}
+	return fmt.Sprintf("%s/api/calendar/%s.ics", strings.TrimRight(baseURL, "/"), token)
+}
+
+// toCalendarFeedResponse adds the subscription URLs to a feed
+func toCalendarFeedResponse(c *gin.Context, feed models.CalendarFeed) CalendarFeedResponse {
+	url := calendarFeedURL(c, feed.Token)
+	return CalendarFeedResponse{
+		CalendarFeed: feed,
+		URL:          url,
+		WebcalURL:    "webcal://" + strings.TrimPrefix(strings.TrimPrefix(url, "https://"), "http://"),
+	}
+}
+
+// GetCalendarFeeds lists the active calendar feeds visible to the user
+// Admins see every feed; professionals only the feed of their own agenda
+// GET /calendar-feeds
+func GetCalendarFeeds(c *gin.Context) {
+	_, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	query := database.GetDB().Where("tenant_id = ? AND revoked_at IS NULL", c.GetUint("tenant_id"))
+	if c.GetString("user_role") != "admin" {
+		query = query.Where("owner_type = ? AND owner_id = ?", models.CalendarFeedOwnerDentist, c.GetUint("user_id"))
+	}
+
+	var feeds []models.CalendarFeed
+	if err := query.Order("created_at DESC").Find(&feeds).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar calendários"})
+		return
+	}
+
+	response := make([]CalendarFeedResponse, 0, len(feeds))
+	for _, feed := range feeds {
+		response = append(response, toCalendarFeedResponse(c, feed))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"feeds": response})
+}
+
+// CreateCalendarFeed creates a read-only ICS feed for a professional or a room
+// POST /calendar-feeds (admin)
+func CreateCalendarFeed(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+	tenantID := c.GetUint("tenant_id")
+
+	var req CalendarFeedRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Without an owner, the feed is the admin's own agenda
+	if req.OwnerType == models.CalendarFeedOwnerDentist && req.OwnerID == 0 {
+		req.OwnerID = c.GetUint("user_id")
+	}
+
+	var ownerName string
+	switch req.OwnerType {
+	case models.CalendarFeedOwnerDentist:
+		if err := db.Raw("SELECT name FROM public.users WHERE id = ? AND tenant_id = ? AND deleted_at IS NULL",
+			req.OwnerID, tenantID).Scan(&ownerName).Error; err != nil || ownerName == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Profissional não encontrado"})
+			return
+		}
+	case models.CalendarFeedOwnerRoom:
+		var room models.Room
+		if err := db.First(&room, req.OwnerID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Sala não encontrada"})
+			return
+		}
+		ownerName = room.Name
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tipo de calendário inválido. Use 'dentist' ou 'room'"})
+		return
+	}
+
+	token, err := generatePublicToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar token"})
+		return
+	}
+
+	feed := models.CalendarFeed{
+		TenantID:  tenantID,
+		OwnerType: req.OwnerType,
+		OwnerID:   req.OwnerID,
+		Name:      "Agenda - " + ownerName,
+		Token:     token,
+		CreatedBy: c.GetUint("user_id"),
+	}
+	if err := database.GetDB().Create(&feed).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar calendário"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"feed": toCalendarFeedResponse(c, feed)})
+}
+
+// RevokeCalendarFeed disables a feed; subscribed apps stop receiving updates
+// DELETE /calendar-feeds/:id (admin)
+func RevokeCalendarFeed(c *gin.Context) {
+	_, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var feed models.CalendarFeed
+	if err := database.GetDB().Where("id = ? AND tenant_id = ? AND revoked_at IS NULL", c.Param("id"), c.GetUint("tenant_id")).
+		First(&feed).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendário não encontrado"})
+		return
+	}
+
+	if err := database.GetDB().Exec("UPDATE public.calendar_feeds SET revoked_at = NOW(), updated_at = NOW() WHERE id = ?", feed.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao revogar calendário"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Calendário revogado com sucesso"})
+}
+
+// GetCalendarFeedICS serves a feed as text/calendar for calendar apps
+// GET /api/calendar/:token (public, the ".ics" suffix is optional)
+func GetCalendarFeedICS(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+
+	db := database.GetDB()
+
+	var feed models.CalendarFeed
+	if token == "" || db.Where("token = ? AND revoked_at IS NULL", token).First(&feed).Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendário não encontrado"})
+		return
+	}
+
+	var tenant models.Tenant
+	if err := db.Where("id = ? AND active = ?", feed.TenantID, true).First(&tenant).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendário não encontrado"})
+		return
+	}
+
+	var settings models.TenantSettings
+	db.Where("tenant_id = ?", feed.TenantID).First(&settings)
+
+	entries, err := loadCalendarFeedEntries(db, feed)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar calendário"})
+		return
+	}
+
+	cal := helpers.ICSCalendar{Name: feed.Name}
+	for _, entry := range entries {
+		cal.Events = append(cal.Events, calendarFeedEvent(feed, settings.CalendarFeedPrivacy, entry))
+	}
+
+	db.Exec("UPDATE public.calendar_feeds SET last_accessed_at = NOW() WHERE id = ?", feed.ID)
+
+	c.Header("Content-Disposition", `inline; filename="agenda.ics"`)
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(helpers.BuildICS(cal)))
+}
+
+// loadCalendarFeedEntries returns the feed owner's appointments inside the feed window
+// Cancelled appointments are left out, so subscribed apps drop them on the next refresh
+// Public route without tenant middleware, so tables are schema-qualified
+func loadCalendarFeedEntries(db *gorm.DB, feed models.CalendarFeed) ([]calendarFeedEntry, error) {
+	schemaName := fmt.Sprintf("tenant_%d", feed.TenantID)
+
+	ownerColumn := "a.dentist_id"
+	if feed.OwnerType == models.CalendarFeedOwnerRoom {
+		ownerColumn = "a.room_id"
+	}
+
+	now := time.Now()
+	var entries []calendarFeedEntry
+	err := db.Session(&gorm.Session{PrepareStmt: false}).Raw(fmt.Sprintf(`
+		SELECT a.id, a.start_time, a.end_time, a.status, COALESCE(a.procedure, '') as procedure,
+			COALESCE(a.room, '') as room, COALESCE(p.name, '') as patient_name,
+			COALESCE(u.name, '') as dentist_name, a.updated_at
+		FROM %s.appointments a
+		LEFT JOIN %s.patients p ON a.patient_id = p.id
+		LEFT JOIN public.users u ON a.dentist_id = u.id
+		WHERE a.deleted_at IS NULL AND a.status <> 'cancelled' AND %s = ?
+		AND a.start_time >= ? AND a.start_time <= ?
+		ORDER BY a.start_time
+	`, schemaName, schemaName, ownerColumn), feed.OwnerID,
+		now.AddDate(0, 0, -calendarFeedPastDays), now.AddDate(0, 0, calendarFeedFutureDays)).Scan(&entries).Error
+	return entries, err
+}
+
+// calendarFeedEvent renders an appointment, minimizing patient data per the tenant privacy setting
+func calendarFeedEvent(feed models.CalendarFeed, privacy string, entry calendarFeedEntry) helpers.ICSEvent {
+	summary := "Consulta"
+	var details []string
+
+	switch privacy {
+	case models.CalendarPrivacyFull:
+		if entry.PatientName != "" {
+			summary = entry.PatientName
+		}
+		if entry.Procedure != "" {
+			summary += " - " + entry.Procedure
+		}
+	case models.CalendarPrivacyMinimal:
+		// No patient name nor procedure - the event only blocks the time
+	default:
+		if initials := helpers.NameInitials(entry.PatientName); initials != "" {
+			summary = initials
+		}
+		if entry.Procedure != "" {
+			summary += " - " + entry.Procedure
+		}
+	}
+
+	// Room feeds show who is using the room; dentist feeds show where
+	if feed.OwnerType == models.CalendarFeedOwnerRoom && entry.DentistName != "" {
+		details = append(details, "Profissional: "+entry.DentistName)
+	}
+
+	return helpers.ICSEvent{
+		UID:          helpers.ICSAppointmentUID(feed.TenantID, entry.ID),
+		Start:        entry.StartTime,
+		End:          entry.EndTime,
+		Summary:      summary,
+		Description:  strings.Join(details, "\n"),
+		Location:     entry.Room,
+		Status:       helpers.ICSStatusForAppointment(entry.Status),
+		LastModified: entry.UpdatedAt,
+	}
+}
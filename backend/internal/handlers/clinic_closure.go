@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/availability"
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ClinicClosureRequest is the payload for creating or updating a closure
+type ClinicClosureRequest struct {
+	Date      string `json:"date" binding:"required"` // Format: YYYY-MM-DD
+	StartTime string `json:"start_time"`              // Format: HH:MM (empty for full-day)
+	EndTime   string `json:"end_time"`                // Format: HH:MM (empty for full-day)
+	Name      string `json:"name" binding:"required"`
+	Type      string `json:"type"`
+}
+
+var validClosureTypes = map[string]bool{
+	models.ClosureTypeNational:  true,
+	models.ClosureTypeMunicipal: true,
+	models.ClosureTypeCustom:    true,
+}
+
+// toClosure validates the request and builds the closure
+func (req ClinicClosureRequest) toClosure(c *gin.Context) (models.ClinicClosure, bool) {
+	var closure models.ClinicClosure
+
+	date, err := time.ParseInLocation("2006-01-02", req.Date, availability.Location())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Formato de data inválido. Use YYYY-MM-DD"})
+		return closure, false
+	}
+
+	if req.Type == "" {
+		req.Type = models.ClosureTypeCustom
+	}
+	if !validClosureTypes[req.Type] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tipo inválido. Use national, municipal ou custom"})
+		return closure, false
+	}
+
+	if req.StartTime != "" || req.EndTime != "" {
+		if _, ok := availability.ClockRange(date, req.StartTime, req.EndTime); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Informe start_time e end_time (HH:MM) para fechamento parcial, ou nenhum para o dia todo"})
+			return closure, false
+		}
+	}
+
+	closure = models.ClinicClosure{
+		Date:      date,
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+		Name:      req.Name,
+		Type:      req.Type,
+	}
+	return closure, true
+}
+
+// countAppointmentsOnClosure returns how many active appointments fall inside a closure
+// Used to warn the clinic which appointments must be rescheduled
+func countAppointmentsOnClosure(db *gorm.DB, closure models.ClinicClosure) int64 {
+	start := closure.Date
+	end := closure.Date.AddDate(0, 0, 1)
+	if !closure.FullDay() {
+		if r, ok := availability.ClockRange(closure.Date, closure.StartTime, closure.EndTime); ok {
+			start, end = r.Start, r.End
+		}
+	}
+
+	var count int64
+	db.Session(&gorm.Session{NewDB: true}).Model(&models.Appointment{}).
+		Where("status IN ?", []string{"scheduled", "confirmed"}).
+		Where("start_time < ? AND end_time > ?", end, start).
+		Count(&count)
+	return count
+}
+
+// closureYear parses the ?year= query parameter (current year by default)
+func closureYear(c *gin.Context) (int, bool) {
+	year := time.Now().In(availability.Location()).Year()
+	if y := c.Query("year"); y != "" {
+		parsed, err := strconv.Atoi(y)
+		if err != nil || parsed < 2000 || parsed > 2100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Ano inválido"})
+			return 0, false
+		}
+		year = parsed
+	}
+	return year, true
+}
+
+// importNationalHolidays adds the bundled national holidays of a year to the closures calendar
+// Holidays already imported are skipped - including deleted ones, so a clinic that chose to
+// work on a holiday does not get it back on the next import
+func importNationalHolidays(db *gorm.DB, year int, createdBy uint) (int, error) {
+	loc := availability.Location()
+
+	var existing []models.ClinicClosure
+	if err := db.Session(&gorm.Session{NewDB: true}).Unscoped().
+		Where("type = ? AND date >= ? AND date <= ?", models.ClosureTypeNational, strconv.Itoa(year)+"-01-01", strconv.Itoa(year)+"-12-31").
+		Find(&existing).Error; err != nil {
+		return 0, err
+	}
+	imported := make(map[string]bool, len(existing))
+	for _, closure := range existing {
+		imported[closure.Date.Format("2006-01-02")] = true
+	}
+
+	created := 0
+	for _, holiday := range helpers.BrazilianNationalHolidays(year, loc) {
+		if imported[holiday.Date.Format("2006-01-02")] {
+			continue
+		}
+		closure := models.ClinicClosure{
+			Date:      holiday.Date,
+			Name:      holiday.Name,
+			Type:      models.ClosureTypeNational,
+			CreatedBy: createdBy,
+		}
+		if err := db.Session(&gorm.Session{NewDB: true}).Create(&closure).Error; err != nil {
+			return created, err
+		}
+		created++
+	}
+	return created, nil
+}
+
+// GetClinicClosures lists the closures of a year (current year by default)
+// GET /clinic-closures?year=2026
+func GetClinicClosures(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	year, ok := closureYear(c)
+	if !ok {
+		return
+	}
+
+	var closures []models.ClinicClosure
+	if err := db.Session(&gorm.Session{NewDB: true}).
+		Where("date >= ? AND date <= ?", strconv.Itoa(year)+"-01-01", strconv.Itoa(year)+"-12-31").
+		Order("date, start_time").Find(&closures).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar feriados e fechamentos"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"year": year, "closures": closures})
+}
+
+// CreateClinicClosure registers a holiday or closure (full or partial day)
+// POST /clinic-closures
+func CreateClinicClosure(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var req ClinicClosureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	closure, ok := req.toClosure(c)
+	if !ok {
+		return
+	}
+	closure.CreatedBy = c.GetUint("user_id")
+
+	if err := db.Session(&gorm.Session{NewDB: true}).Create(&closure).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar fechamento"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"closure":               closure,
+		"affected_appointments": countAppointmentsOnClosure(db, closure),
+	})
+}
+
+// UpdateClinicClosure changes the date, period or name of a closure
+// PUT /clinic-closures/:id
+func UpdateClinicClosure(c *gin.Context) {
+	id := c.Param("id")
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var existing models.ClinicClosure
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&existing, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fechamento não encontrado"})
+		return
+	}
+
+	var req ClinicClosureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	closure, ok := req.toClosure(c)
+	if !ok {
+		return
+	}
+
+	// Update fields directly (avoid GORM FROM clause issue)
+	if err := db.Session(&gorm.Session{NewDB: true}).Exec(`
+		UPDATE clinic_closures
+		SET date = ?, start_time = ?, end_time = ?, name = ?, type = ?, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL
+	`, closure.Date.Format("2006-01-02"), closure.StartTime, closure.EndTime, closure.Name, closure.Type, time.Now(), existing.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar fechamento"})
+		return
+	}
+
+	closure.ID = existing.ID
+	closure.CreatedAt = existing.CreatedAt
+	closure.CreatedBy = existing.CreatedBy
+
+	c.JSON(http.StatusOK, gin.H{
+		"closure":               closure,
+		"affected_appointments": countAppointmentsOnClosure(db, closure),
+	})
+}
+
+// DeleteClinicClosure removes a closure (e.g. the clinic decided to open on a holiday)
+// DELETE /clinic-closures/:id
+func DeleteClinicClosure(c *gin.Context) {
+	id := c.Param("id")
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	result := db.Session(&gorm.Session{NewDB: true}).Delete(&models.ClinicClosure{}, id)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao remover fechamento"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fechamento não encontrado"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Fechamento removido com sucesso"})
+}
+
+// ImportNationalHolidays prefills the closures calendar with the national holidays of a year
+// POST /clinic-closures/national-holidays?year=2026
+func ImportNationalHolidays(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	year, ok := closureYear(c)
+	if !ok {
+		return
+	}
+
+	created, err := importNationalHolidays(db, year, c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao importar feriados nacionais"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"year":     year,
+		"imported": created,
+		"message":  "Feriados nacionais importados com sucesso",
+	})
+}
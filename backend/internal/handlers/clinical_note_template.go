@@ -0,0 +1,388 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errNoteTemplateNotFound is returned when the template does not exist or belongs to another professional
+var errNoteTemplateNotFound = errors.New("note template not found")
+
+// ClinicalNoteTemplateRequest is the payload to create or update a template
+type ClinicalNoteTemplateRequest struct {
+	models.ClinicalNoteTemplate
+	Personal bool `json:"personal"` // Only the professional who created it sees the template
+}
+
+// ExpandClinicalNoteRequest is the payload to preview a template for a patient
+type ExpandClinicalNoteRequest struct {
+	PatientID     uint              `json:"patient_id"`
+	DentistID     uint              `json:"dentist_id"`
+	AppointmentID *uint             `json:"appointment_id"`
+	Values        map[string]string `json:"values"`
+}
+
+// visibleNoteTemplates restricts the query to the clinic templates and the user's own
+func visibleNoteTemplates(db *gorm.DB, userID uint) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true}).Model(&models.ClinicalNoteTemplate{}).
+		Where("owner_id IS NULL OR owner_id = ?", userID)
+}
+
+// findNoteTemplate loads a template visible to the user
+func findNoteTemplate(db *gorm.DB, userID uint, id interface{}) (models.ClinicalNoteTemplate, error) {
+	var template models.ClinicalNoteTemplate
+	if err := visibleNoteTemplates(db, userID).Preload("TreatmentProtocol").First(&template, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return template, errNoteTemplateNotFound
+		}
+		return template, err
+	}
+	return template, nil
+}
+
+// validateClinicalNoteTemplate normalizes a template and returns an error message when it is invalid
+func validateClinicalNoteTemplate(db *gorm.DB, template *models.ClinicalNoteTemplate) string {
+	template.Name = strings.TrimSpace(template.Name)
+	template.Category = strings.TrimSpace(template.Category)
+	template.RecordType = strings.TrimSpace(template.RecordType)
+	// "/Exo Simples" is typed as "exo_simples"
+	template.Shortcut = helpers.NormalizePlaceholderKey(strings.TrimPrefix(strings.TrimSpace(template.Shortcut), "/"))
+
+	if template.Name == "" {
+		return "Nome do modelo é obrigatório"
+	}
+	if strings.TrimSpace(template.Diagnosis+template.TreatmentPlan+template.ProcedureDone+
+		template.Materials+template.Evolution+template.Notes) == "" {
+		return "O modelo deve ter ao menos um texto preenchido"
+	}
+	if template.TreatmentProtocolID != nil {
+		var count int64
+		db.Session(&gorm.Session{NewDB: true}).Model(&models.TreatmentProtocol{}).
+			Where("id = ?", *template.TreatmentProtocolID).Count(&count)
+		if count == 0 {
+			return "Protocolo de tratamento não encontrado"
+		}
+	}
+	return ""
+}
+
+// clinicalNoteValues returns the placeholder values of a record: patient, professional and date are
+// filled automatically, the values typed by the professional take precedence
+func clinicalNoteValues(db *gorm.DB, patientID, dentistID uint, appointmentID *uint, typed map[string]string) map[string]string {
+	values := map[string]string{}
+	session := db.Session(&gorm.Session{NewDB: true})
+
+	var patientName, dentistName string
+	session.Raw("SELECT name FROM patients WHERE id = ? AND deleted_at IS NULL", patientID).Scan(&patientName)
+	session.Raw("SELECT name FROM public.users WHERE id = ? AND deleted_at IS NULL", dentistID).Scan(&dentistName)
+	values[helpers.NotePlaceholderPatient] = patientName
+	values[helpers.NotePlaceholderDentist] = dentistName
+
+	date := time.Now()
+	if appointmentID != nil {
+		var startTime time.Time
+		if session.Raw("SELECT start_time FROM appointments WHERE id = ? AND deleted_at IS NULL", *appointmentID).
+			Scan(&startTime).Error == nil && !startTime.IsZero() {
+			date = startTime
+		}
+	}
+	values[helpers.NotePlaceholderDate] = date.Format("02/01/2006")
+
+	for key, value := range typed {
+		if value = strings.TrimSpace(value); value != "" {
+			values[helpers.NormalizePlaceholderKey(key)] = value
+		}
+	}
+	return values
+}
+
+// applyRecordNoteTemplate fills the empty fields of a new medical record with its note template.
+// Returns the placeholders left without a value
+func applyRecordNoteTemplate(db *gorm.DB, userID uint, record *models.MedicalRecord) ([]string, error) {
+	template, err := findNoteTemplate(db, userID, *record.NoteTemplateID)
+	if err != nil {
+		return nil, err
+	}
+	if !template.Active {
+		return nil, errNoteTemplateNotFound
+	}
+	values := clinicalNoteValues(db, record.PatientID, record.DentistID, record.AppointmentID, record.TemplateValues)
+	return helpers.ApplyClinicalNoteTemplate(record, template, values), nil
+}
+
+// countNoteTemplateUsage increments the usage counter used to rank the search
+func countNoteTemplateUsage(db *gorm.DB, templateID uint) {
+	db.Session(&gorm.Session{NewDB: true}).Exec("UPDATE clinical_note_templates SET usage_count = usage_count + 1 WHERE id = ?", templateID)
+}
+
+// noteTemplatePlaceholders lists the placeholders used by a template
+func noteTemplatePlaceholders(template models.ClinicalNoteTemplate) []string {
+	return helpers.ClinicalNotePlaceholderKeys(template.Diagnosis, template.TreatmentPlan, template.ProcedureDone,
+		template.Materials, template.Evolution, template.Notes)
+}
+
+// CreateClinicalNoteTemplate creates a clinic-wide or personal note template
+// POST /clinical-note-templates
+func CreateClinicalNoteTemplate(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+	userID := c.GetUint("user_id")
+
+	var req ClinicalNoteTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template := req.ClinicalNoteTemplate
+	template.ID = 0
+	template.Active = true
+	template.UsageCount = 0
+	template.CreatedByID = userID
+	template.TreatmentProtocol = nil
+	template.OwnerID = nil
+	if req.Personal {
+		template.OwnerID = &userID
+	}
+	if msg := validateClinicalNoteTemplate(db, &template); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := db.Session(&gorm.Session{NewDB: true}).Create(&template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar modelo de evolução"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"template":     template,
+		"placeholders": noteTemplatePlaceholders(template),
+	})
+}
+
+// GetClinicalNoteTemplates lists the templates visible to the user
+// GET /clinical-note-templates?search=&category=&record_type=&treatment_protocol_id=&personal=&active=
+func GetClinicalNoteTemplates(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+	userID := c.GetUint("user_id")
+
+	query := visibleNoteTemplates(db, userID)
+	if search := strings.TrimSpace(c.Query("search")); search != "" {
+		query = query.Where("name ILIKE ? OR shortcut ILIKE ?", "%"+search+"%", "%"+search+"%")
+	}
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category = ?", category)
+	}
+	if recordType := c.Query("record_type"); recordType != "" {
+		query = query.Where("record_type = ?", recordType)
+	}
+	if protocolID := c.Query("treatment_protocol_id"); protocolID != "" {
+		query = query.Where("treatment_protocol_id = ?", protocolID)
+	}
+	if personal := c.Query("personal"); personal == "true" {
+		query = query.Where("owner_id = ?", userID)
+	} else if personal == "false" {
+		query = query.Where("owner_id IS NULL")
+	}
+	if active := c.Query("active"); active == "true" {
+		query = query.Where("active = ?", true)
+	} else if active == "false" {
+		query = query.Where("active = ?", false)
+	}
+
+	var templates []models.ClinicalNoteTemplate
+	if err := query.Order("category ASC, name ASC").Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar modelos de evolução"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"templates": templates, "total": len(templates)})
+}
+
+// SearchClinicalNoteTemplates finds active templates for quick insertion while typing a record.
+// Exact shortcuts come first, then shortcut prefixes, then the most used templates
+// GET /clinical-note-templates/search?q=exo&record_type=&treatment_protocol_id=&limit=10
+func SearchClinicalNoteTemplates(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if limit <= 0 || limit > 50 {
+		limit = 10
+	}
+
+	query := visibleNoteTemplates(db, c.GetUint("user_id")).Where("active = ?", true)
+	if recordType := c.Query("record_type"); recordType != "" {
+		query = query.Where("record_type = ? OR record_type = ''", recordType)
+	}
+	if protocolID := c.Query("treatment_protocol_id"); protocolID != "" {
+		query = query.Where("treatment_protocol_id = ?", protocolID)
+	}
+
+	term := strings.TrimSpace(c.Query("q"))
+	shortcut := helpers.NormalizePlaceholderKey(strings.TrimPrefix(term, "/"))
+	if term != "" {
+		query = query.Where("shortcut LIKE ? OR name ILIKE ? OR category ILIKE ?", shortcut+"%", "%"+term+"%", term+"%").
+			Order(gorm.Expr("CASE WHEN shortcut = ? THEN 0 WHEN shortcut LIKE ? THEN 1 ELSE 2 END", shortcut, shortcut+"%"))
+	}
+
+	var templates []models.ClinicalNoteTemplate
+	if err := query.Order("usage_count DESC, name ASC").Limit(limit).Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar modelos de evolução"})
+		return
+	}
+
+	results := make([]gin.H, 0, len(templates))
+	for _, template := range templates {
+		results = append(results, gin.H{
+			"template":     template,
+			"placeholders": noteTemplatePlaceholders(template),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+// GetClinicalNotePlaceholders lists the placeholders known by the templates
+// GET /clinical-note-templates/placeholders
+func GetClinicalNotePlaceholders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"placeholders": helpers.ClinicalNotePlaceholders()})
+}
+
+// GetClinicalNoteTemplate returns a template with the placeholders it uses
+// GET /clinical-note-templates/:id
+func GetClinicalNoteTemplate(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	template, err := findNoteTemplate(db, c.GetUint("user_id"), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Modelo de evolução não encontrado"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"template":     template,
+		"placeholders": noteTemplatePlaceholders(template),
+	})
+}
+
+// UpdateClinicalNoteTemplate updates a template. Records already created keep their text
+// PUT /clinical-note-templates/:id
+func UpdateClinicalNoteTemplate(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+	userID := c.GetUint("user_id")
+
+	existing, err := findNoteTemplate(db, userID, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Modelo de evolução não encontrado"})
+		return
+	}
+
+	// Fields missing from the payload keep their current values
+	req := ClinicalNoteTemplateRequest{ClinicalNoteTemplate: existing, Personal: existing.OwnerID != nil}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	template := req.ClinicalNoteTemplate
+	template.OwnerID = existing.OwnerID
+	if req.Personal != (existing.OwnerID != nil) {
+		// findNoteTemplate only returns the user's own personal templates, so owners may share theirs;
+		// taking a shared template away from the clinic is left to admins
+		if existing.OwnerID == nil && c.GetString("user_role") != "admin" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Somente administradores podem tornar pessoal um modelo compartilhado da clínica"})
+			return
+		}
+		template.OwnerID = nil
+		if req.Personal {
+			// A shared template made personal belongs to the admin who changed it
+			template.OwnerID = &userID
+		}
+	}
+	if msg := validateClinicalNoteTemplate(db, &template); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := db.Session(&gorm.Session{NewDB: true}).Exec(`
+		UPDATE clinical_note_templates
+		SET name = ?, shortcut = ?, category = ?, record_type = ?, owner_id = ?, treatment_protocol_id = ?,
+		    diagnosis = ?, treatment_plan = ?, procedure_done = ?, materials = ?, evolution = ?, notes = ?,
+		    active = ?, updated_at = ?
+		WHERE id = ?
+	`, template.Name, template.Shortcut, template.Category, template.RecordType, template.OwnerID, template.TreatmentProtocolID,
+		template.Diagnosis, template.TreatmentPlan, template.ProcedureDone, template.Materials, template.Evolution, template.Notes,
+		template.Active, time.Now(), existing.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar modelo de evolução"})
+		return
+	}
+
+	updated, _ := findNoteTemplate(db, userID, existing.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"template":     updated,
+		"placeholders": noteTemplatePlaceholders(updated),
+	})
+}
+
+// DeleteClinicalNoteTemplate removes a template
+// DELETE /clinical-note-templates/:id
+func DeleteClinicalNoteTemplate(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	template, err := findNoteTemplate(db, c.GetUint("user_id"), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Modelo de evolução não encontrado"})
+		return
+	}
+
+	if err := db.Session(&gorm.Session{NewDB: true}).Delete(&models.ClinicalNoteTemplate{}, template.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao remover modelo de evolução"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Modelo de evolução removido com sucesso"})
+}
+
+// ExpandClinicalNoteTemplate previews the texts of a template for a patient, with the
+// placeholders still missing, before the medical record is saved
+// POST /clinical-note-templates/:id/expand
+func ExpandClinicalNoteTemplate(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	template, err := findNoteTemplate(db, c.GetUint("user_id"), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Modelo de evolução não encontrado"})
+		return
+	}
+
+	var req ExpandClinicalNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.DentistID == 0 {
+		req.DentistID = c.GetUint("user_id")
+	}
+
+	var record models.MedicalRecord
+	values := clinicalNoteValues(db, req.PatientID, req.DentistID, req.AppointmentID, req.Values)
+	missing := helpers.ApplyClinicalNoteTemplate(&record, template, values)
+
+	c.JSON(http.StatusOK, gin.H{
+		"type":                 record.Type,
+		"diagnosis":            record.Diagnosis,
+		"treatment_plan":       record.TreatmentPlan,
+		"procedure_done":       record.ProcedureDone,
+		"materials":            record.Materials,
+		"evolution":            record.Evolution,
+		"notes":                record.Notes,
+		"missing_placeholders": missing,
+	})
+}
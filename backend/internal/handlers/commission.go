@@ -0,0 +1,566 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/database"
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// commissionOriginColumns maps each commission source to the column identifying its origin
+var commissionOriginColumns = map[string]string{
+	models.CommissionSourcePayment:          "payment_id",
+	models.CommissionSourceTreatmentPayment: "treatment_payment_id",
+	models.CommissionSourceCompletion:       "plan_item_id",
+}
+
+// commissionReceipt is an amount received that commissions are calculated on
+type commissionReceipt struct {
+	Source             string
+	PaymentID          *uint
+	TreatmentPaymentID *uint
+	PatientID          *uint
+	DentistID          uint // Professional of the budget, for the procedures not performed yet
+	Category           string
+	PaymentMethod      string
+	Amount             float64
+	Date               time.Time
+	Description        string
+	Items              []models.TreatmentPlanItem
+}
+
+// originID returns the id of the payment the receipt comes from
+func (r commissionReceipt) originID() uint {
+	if r.PaymentID != nil {
+		return *r.PaymentID
+	}
+	if r.TreatmentPaymentID != nil {
+		return *r.TreatmentPaymentID
+	}
+	return 0
+}
+
+// activeCommissionsQuery selects the commissions of an origin that were neither cancelled nor offset
+func activeCommissionsQuery(tx *gorm.DB, source string, originID uint) *gorm.DB {
+	return tx.Model(&models.Commission{}).
+		Where("source = ? AND "+commissionOriginColumns[source]+" = ?", source, originID).
+		Where("status <> ? AND reversal_of_id IS NULL", models.CommissionStatusCancelled).
+		Where("id NOT IN (SELECT reversal_of_id FROM commissions WHERE reversal_of_id IS NOT NULL AND deleted_at IS NULL)")
+}
+
+// activeCommissionRules loads the active rules of a trigger, oldest first
+func activeCommissionRules(tx *gorm.DB, trigger string) ([]models.CommissionRule, error) {
+	var rules []models.CommissionRule
+	err := tx.Where("active = ? AND trigger = ?", true, trigger).Order("id ASC").Find(&rules).Error
+	return rules, err
+}
+
+// paymentMethodFeePercent returns the card fee configured for a payment method
+func paymentMethodFeePercent(tx *gorm.DB, method string) float64 {
+	var fee float64
+	tx.Model(&models.PaymentMethodFee{}).Where("payment_method = ?", method).Select("fee_percent").Scan(&fee)
+	return fee
+}
+
+// budgetCommissionItems loads the plan items a budget payment is split across
+func budgetCommissionItems(tx *gorm.DB, budgetID uint) ([]models.TreatmentPlanItem, error) {
+	var items []models.TreatmentPlanItem
+	err := tx.Where("budget_id = ? AND status <> ?", budgetID, models.PlanItemStatusCancelled).
+		Order("position ASC").Find(&items).Error
+	return items, err
+}
+
+// generateReceiptCommissions splits a receipt across its plan items and stores the commission of each
+// professional by the matching rule. Procedures already performed pay whoever performed them.
+// Receipts that already have commissions are skipped
+func generateReceiptCommissions(tx *gorm.DB, receipt commissionReceipt) error {
+	if receipt.Amount <= 0 {
+		return nil
+	}
+	var existing int64
+	if err := activeCommissionsQuery(tx, receipt.Source, receipt.originID()).Count(&existing).Error; err != nil {
+		return err
+	}
+	if existing > 0 {
+		return nil
+	}
+
+	rules, err := activeCommissionRules(tx, models.CommissionTriggerPayment)
+	if err != nil || len(rules) == 0 {
+		return err
+	}
+	feePercent := paymentMethodFeePercent(tx, receipt.PaymentMethod)
+
+	for _, share := range helpers.SplitCommissionShares(receipt.Amount, receipt.Items) {
+		target := helpers.CommissionTarget{
+			DentistID: receipt.DentistID,
+			Category:  receipt.Category,
+			Trigger:   models.CommissionTriggerPayment,
+		}
+		units := share.Fraction
+		description := receipt.Description
+		var planItemID *uint
+		if item := share.Item; item != nil {
+			if item.PerformedByID != nil {
+				target.DentistID = *item.PerformedByID
+			}
+			target.Procedure = item.Procedure
+			target.ProcedureCode = item.ProcedureCode
+			units = float64(item.Quantity) * share.Fraction
+			description = helpers.TreatmentPlanItemLabel(*item)
+			planItemID = &item.ID
+		}
+		if target.DentistID == 0 {
+			continue
+		}
+
+		rule := helpers.MatchCommissionRule(rules, target)
+		if rule == nil {
+			continue
+		}
+		commission := newCommission(*rule, target.DentistID, share.Gross, helpers.CardFee(share.Gross, feePercent), share.LabCost, units)
+		if commission.Amount <= 0 {
+			continue
+		}
+		commission.Source = receipt.Source
+		commission.PaymentID = receipt.PaymentID
+		commission.TreatmentPaymentID = receipt.TreatmentPaymentID
+		commission.PlanItemID = planItemID
+		commission.PatientID = receipt.PatientID
+		commission.Description = description
+		commission.ReferenceDate = receipt.Date
+		if err := tx.Create(&commission).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generateCompletionCommissions stores the commissions of plan items performed by a professional
+func generateCompletionCommissions(tx *gorm.DB, items []models.TreatmentPlanItem, performedByID uint, completedAt time.Time) error {
+	rules, err := activeCommissionRules(tx, models.CommissionTriggerCompletion)
+	if err != nil || len(rules) == 0 {
+		return err
+	}
+
+	for i := range items {
+		item := &items[i]
+		if item.Total <= 0 {
+			continue
+		}
+		var existing int64
+		if err := activeCommissionsQuery(tx, models.CommissionSourceCompletion, item.ID).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			continue
+		}
+
+		rule := helpers.MatchCommissionRule(rules, helpers.CommissionTarget{
+			DentistID:     performedByID,
+			Procedure:     item.Procedure,
+			ProcedureCode: item.ProcedureCode,
+			Trigger:       models.CommissionTriggerCompletion,
+		})
+		if rule == nil {
+			continue
+		}
+		commission := newCommission(*rule, performedByID, item.Total, 0, item.LabCost, float64(item.Quantity))
+		if commission.Amount <= 0 {
+			continue
+		}
+		patientID := item.PatientID
+		commission.Source = models.CommissionSourceCompletion
+		commission.PlanItemID = &item.ID
+		commission.PatientID = &patientID
+		commission.Description = helpers.TreatmentPlanItemLabel(*item)
+		commission.ReferenceDate = completedAt
+		if err := tx.Create(&commission).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newCommission calculates a pending commission. Deductions are only recorded on net rules
+func newCommission(rule models.CommissionRule, dentistID uint, gross, cardFee, labCost, units float64) models.Commission {
+	if rule.Base != models.CommissionBaseNet {
+		cardFee, labCost = 0, 0
+	}
+	base, amount := helpers.CalculateCommission(rule, gross, cardFee, labCost, units)
+
+	ruleID := rule.ID
+	commission := models.Commission{
+		DentistID:   dentistID,
+		RuleID:      &ruleID,
+		GrossAmount: gross,
+		CardFee:     cardFee,
+		LabCost:     labCost,
+		BaseAmount:  base,
+		Amount:      amount,
+		Status:      models.CommissionStatusPending,
+	}
+	if rule.Type == models.CommissionTypePercentage {
+		commission.Percentage = rule.Value
+	}
+	return commission
+}
+
+// generateTreatmentPaymentCommissions stores the commissions of a treatment installment
+func generateTreatmentPaymentCommissions(tx *gorm.DB, payment models.TreatmentPayment, treatment models.Treatment) error {
+	items, err := budgetCommissionItems(tx, treatment.BudgetID)
+	if err != nil {
+		return err
+	}
+	paymentID := payment.ID
+	patientID := treatment.PatientID
+	return generateReceiptCommissions(tx, commissionReceipt{
+		Source:             models.CommissionSourceTreatmentPayment,
+		TreatmentPaymentID: &paymentID,
+		PatientID:          &patientID,
+		DentistID:          treatment.DentistID,
+		Category:           "treatment",
+		PaymentMethod:      payment.PaymentMethod,
+		Amount:             payment.Amount,
+		Date:               payment.PaidDate,
+		Description:        fmt.Sprintf("Tratamento #%d - parcela %d", treatment.ID, payment.InstallmentNumber),
+		Items:              items,
+	})
+}
+
+// generatePaymentCommissions stores the commissions of an income received in the cash flow.
+// Only payments of a budget have a professional to be credited
+func generatePaymentCommissions(tx *gorm.DB, payment models.Payment) error {
+	if payment.Type != "income" || payment.Status != "paid" || payment.BudgetID == nil {
+		return nil
+	}
+	var budget models.Budget
+	if err := tx.First(&budget, *payment.BudgetID).Error; err != nil {
+		return err
+	}
+	items, err := budgetCommissionItems(tx, budget.ID)
+	if err != nil {
+		return err
+	}
+
+	paymentID := payment.ID
+	patientID := budget.PatientID
+	date := time.Now()
+	if payment.PaidDate != nil {
+		date = *payment.PaidDate
+	}
+	description := payment.Description
+	if description == "" {
+		description = fmt.Sprintf("Pagamento #%d", payment.ID)
+	}
+	return generateReceiptCommissions(tx, commissionReceipt{
+		Source:        models.CommissionSourcePayment,
+		PaymentID:     &paymentID,
+		PatientID:     &patientID,
+		DentistID:     budget.DentistID,
+		Category:      payment.Category,
+		PaymentMethod: payment.PaymentMethod,
+		Amount:        payment.Amount,
+		Date:          date,
+		Description:   description,
+		Items:         items,
+	})
+}
+
+// reverseCommissions undoes the commissions of a payment that was cancelled, refunded or deleted:
+// pending ones are cancelled and the ones already paid are offset in the next payout
+func reverseCommissions(tx *gorm.DB, source string, originID uint) error {
+	var commissions []models.Commission
+	if err := activeCommissionsQuery(tx, source, originID).Find(&commissions).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, commission := range commissions {
+		if commission.Status == models.CommissionStatusPending {
+			if err := tx.Model(&models.Commission{}).Where("id = ?", commission.ID).
+				Updates(map[string]interface{}{"status": models.CommissionStatusCancelled, "updated_at": now}).Error; err != nil {
+				return err
+			}
+			continue
+		}
+
+		reversedID := commission.ID
+		offset := commission
+		offset.ID = 0
+		offset.CreatedAt = time.Time{}
+		offset.UpdatedAt = time.Time{}
+		offset.GrossAmount = -commission.GrossAmount
+		offset.CardFee = -commission.CardFee
+		offset.LabCost = -commission.LabCost
+		offset.BaseAmount = -commission.BaseAmount
+		offset.Amount = -commission.Amount
+		offset.Status = models.CommissionStatusPending
+		offset.PaidDate = nil
+		offset.PayoutID = nil
+		offset.ReversalOfID = &reversedID
+		offset.ReferenceDate = now
+		offset.Description = "Estorno: " + commission.Description
+		if err := tx.Create(&offset).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commissionDentistScope returns the professional a dentist is limited to (their own commissions),
+// or 0 for roles that see every professional
+func commissionDentistScope(c *gin.Context) uint {
+	if c.GetString("user_role") == "dentist" {
+		return c.GetUint("user_id")
+	}
+	return 0
+}
+
+// validateCommissionRule normalizes a rule and returns an error message when it is invalid
+func validateCommissionRule(c *gin.Context, rule *models.CommissionRule) string {
+	rule.Name = strings.TrimSpace(rule.Name)
+	rule.Procedure = strings.TrimSpace(rule.Procedure)
+	rule.Category = strings.ToLower(strings.TrimSpace(rule.Category))
+	if rule.Type == "" {
+		rule.Type = models.CommissionTypePercentage
+	}
+	if rule.Base == "" {
+		rule.Base = models.CommissionBaseGross
+	}
+	if rule.Trigger == "" {
+		rule.Trigger = models.CommissionTriggerPayment
+	}
+	if rule.DentistID != nil && *rule.DentistID == 0 {
+		rule.DentistID = nil
+	}
+
+	if rule.Name == "" {
+		return "Nome da regra é obrigatório"
+	}
+	if rule.Type != models.CommissionTypePercentage && rule.Type != models.CommissionTypeFixed {
+		return "Tipo inválido. Use percentage ou fixed"
+	}
+	if rule.Base != models.CommissionBaseGross && rule.Base != models.CommissionBaseNet {
+		return "Base inválida. Use gross ou net"
+	}
+	if rule.Trigger != models.CommissionTriggerPayment && rule.Trigger != models.CommissionTriggerCompletion {
+		return "Gatilho inválido. Use payment ou completion"
+	}
+	if rule.Value < 0 {
+		return "Valor da comissão não pode ser negativo"
+	}
+	if rule.Type == models.CommissionTypePercentage && rule.Value > 100 {
+		return "Percentual deve estar entre 0 e 100"
+	}
+	if rule.Trigger == models.CommissionTriggerCompletion && rule.Category != "" {
+		return "Regras por procedimento realizado não usam categoria de pagamento"
+	}
+	if rule.DentistID != nil {
+		var count int64
+		database.DB.Table("public.users").
+			Where("id = ? AND tenant_id = ? AND deleted_at IS NULL", *rule.DentistID, c.GetUint("tenant_id")).
+			Count(&count)
+		if count == 0 {
+			return "Profissional não encontrado"
+		}
+	}
+	return ""
+}
+
+// CreateCommissionRule adds a commission rule
+// POST /commissions/rules
+func CreateCommissionRule(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var rule models.CommissionRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rule.ID = 0
+	rule.Active = true
+	if msg := validateCommissionRule(c, &rule); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := db.Session(&gorm.Session{NewDB: true}).Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar regra de comissão"})
+		return
+	}
+
+	helpers.AuditAction(c, "create", "commission_rules", rule.ID, true, map[string]interface{}{
+		"dentist_id": rule.DentistID,
+		"type":       rule.Type,
+		"value":      rule.Value,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{"rule": rule})
+}
+
+// GetCommissionRules lists the commission rules
+// GET /commissions/rules?dentist_id=&trigger=&active=true
+func GetCommissionRules(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	query := db.Session(&gorm.Session{NewDB: true}).Model(&models.CommissionRule{})
+	if dentistID := c.Query("dentist_id"); dentistID != "" {
+		// Rules of the professional and the ones that apply to everyone
+		query = query.Where("dentist_id = ? OR dentist_id IS NULL", dentistID)
+	}
+	if trigger := c.Query("trigger"); trigger != "" {
+		query = query.Where("trigger = ?", trigger)
+	}
+	if active := c.Query("active"); active == "true" {
+		query = query.Where("active = ?", true)
+	} else if active == "false" {
+		query = query.Where("active = ?", false)
+	}
+
+	var rules []models.CommissionRule
+	if err := query.Order("trigger ASC, dentist_id ASC NULLS FIRST, name ASC").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar regras de comissão"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rules": rules, "total": len(rules)})
+}
+
+// UpdateCommissionRule updates a commission rule
+// Commissions already generated keep the values calculated at the time
+// PUT /commissions/rules/:id
+func UpdateCommissionRule(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var existing models.CommissionRule
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&existing, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Regra de comissão não encontrada"})
+		return
+	}
+
+	// Fields missing from the payload keep their current values
+	rule := existing
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := validateCommissionRule(c, &rule); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := db.Session(&gorm.Session{NewDB: true}).Exec(`
+		UPDATE commission_rules
+		SET name = ?, dentist_id = ?, procedure = ?, category = ?, type = ?, value = ?, base = ?, trigger = ?,
+		    active = ?, notes = ?, updated_at = ?
+		WHERE id = ?
+	`, rule.Name, rule.DentistID, rule.Procedure, rule.Category, rule.Type, rule.Value, rule.Base, rule.Trigger,
+		rule.Active, rule.Notes, time.Now(), existing.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar regra de comissão"})
+		return
+	}
+
+	helpers.AuditAction(c, "update", "commission_rules", existing.ID, true, map[string]interface{}{
+		"type":   rule.Type,
+		"value":  rule.Value,
+		"active": rule.Active,
+	})
+
+	db.Session(&gorm.Session{NewDB: true}).First(&existing, existing.ID)
+	c.JSON(http.StatusOK, gin.H{"rule": existing})
+}
+
+// DeleteCommissionRule removes a commission rule
+// DELETE /commissions/rules/:id
+func DeleteCommissionRule(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var rule models.CommissionRule
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&rule, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Regra de comissão não encontrada"})
+		return
+	}
+
+	if err := db.Session(&gorm.Session{NewDB: true}).Delete(&models.CommissionRule{}, rule.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao remover regra de comissão"})
+		return
+	}
+
+	helpers.AuditAction(c, "delete", "commission_rules", rule.ID, true, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Regra de comissão removida com sucesso"})
+}
+
+// GetPaymentMethodFees lists the card fees deducted from net commissions
+// GET /commissions/fees
+func GetPaymentMethodFees(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var fees []models.PaymentMethodFee
+	if err := db.Session(&gorm.Session{NewDB: true}).Order("payment_method ASC").Find(&fees).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar taxas"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"fees": fees})
+}
+
+// PaymentMethodFeesRequest replaces the card fees of the payment methods listed
+type PaymentMethodFeesRequest struct {
+	Fees []models.PaymentMethodFee `json:"fees" binding:"required"`
+}
+
+// UpdatePaymentMethodFees sets the card fee of payment methods
+// PUT /commissions/fees
+func UpdatePaymentMethodFees(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var req PaymentMethodFeesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for _, fee := range req.Fees {
+		if strings.TrimSpace(fee.PaymentMethod) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Forma de pagamento é obrigatória"})
+			return
+		}
+		if fee.FeePercent < 0 || fee.FeePercent > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Taxa deve estar entre 0 e 100%"})
+			return
+		}
+	}
+
+	err := db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		for _, fee := range req.Fees {
+			method := strings.TrimSpace(fee.PaymentMethod)
+			result := tx.Exec("UPDATE payment_method_fees SET fee_percent = ?, updated_at = ? WHERE payment_method = ?",
+				fee.FeePercent, time.Now(), method)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				if err := tx.Create(&models.PaymentMethodFee{PaymentMethod: method, FeePercent: fee.FeePercent}).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao salvar taxas"})
+		return
+	}
+
+	var fees []models.PaymentMethodFee
+	db.Session(&gorm.Session{NewDB: true}).Order("payment_method ASC").Find(&fees)
+	c.JSON(http.StatusOK, gin.H{"fees": fees})
+}
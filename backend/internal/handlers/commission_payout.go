@@ -0,0 +1,429 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/database"
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errNoPendingCommissions is returned when a payout has nothing to pay
+var errNoPendingCommissions = errors.New("no pending commissions")
+
+// errCommissionBalanceNotPositive is returned when the reversals of the period exceed its commissions
+var errCommissionBalanceNotPositive = errors.New("commission balance is not positive")
+
+// CommissionStatementLine is a commission with the patient name, as listed in the statements
+type CommissionStatementLine struct {
+	models.Commission
+	PatientName string `json:"patient_name"`
+}
+
+// CommissionStatement is the commissions of a professional in a period
+type CommissionStatement struct {
+	DentistID   uint                      `json:"dentist_id"`
+	DentistName string                    `json:"dentist_name"`
+	StartDate   time.Time                 `json:"start_date"`
+	EndDate     time.Time                 `json:"end_date"`
+	Lines       []CommissionStatementLine `json:"lines"`
+	GrossTotal  float64                   `json:"gross_total"`
+	Deductions  float64                   `json:"deductions"`
+	Total       float64                   `json:"total"`
+	Pending     float64                   `json:"pending"`
+	Paid        float64                   `json:"paid"`
+}
+
+// parseCommissionPeriod reads start_date and end_date (YYYY-MM-DD), defaulting to the current month
+func parseCommissionPeriod(startDate, endDate string) (time.Time, time.Time, error) {
+	now := time.Now()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	end := start.AddDate(0, 1, -1)
+
+	var err error
+	if startDate != "" {
+		if start, err = time.ParseInLocation("2006-01-02", startDate, now.Location()); err != nil {
+			return start, end, err
+		}
+	}
+	if endDate != "" {
+		if end, err = time.ParseInLocation("2006-01-02", endDate, now.Location()); err != nil {
+			return start, end, err
+		}
+	}
+	if end.Before(start) {
+		return start, end, errors.New("end before start")
+	}
+	return start, end, nil
+}
+
+// commissionDentistName returns the name of a professional
+func commissionDentistName(dentistID uint) string {
+	var name string
+	database.DB.Table("public.users").Select("name").Where("id = ?", dentistID).Scan(&name)
+	return name
+}
+
+// commissionPeriodQuery selects the commissions of a professional with reference date in the period
+func commissionPeriodQuery(db *gorm.DB, dentistID uint, start, end time.Time) *gorm.DB {
+	return db.Model(&models.Commission{}).
+		Where("dentist_id = ? AND reference_date >= ? AND reference_date < ?", dentistID, start, end.AddDate(0, 0, 1))
+}
+
+// buildCommissionStatement loads the statement of a professional; cancelled commissions are left out
+func buildCommissionStatement(db *gorm.DB, dentistID uint, start, end time.Time) (*CommissionStatement, error) {
+	var commissions []models.Commission
+	if err := commissionPeriodQuery(db.Session(&gorm.Session{NewDB: true}), dentistID, start, end).
+		Where("status <> ?", models.CommissionStatusCancelled).
+		Order("reference_date ASC, id ASC").Find(&commissions).Error; err != nil {
+		return nil, err
+	}
+
+	patientIDs := []uint{}
+	for _, commission := range commissions {
+		if commission.PatientID != nil {
+			patientIDs = append(patientIDs, *commission.PatientID)
+		}
+	}
+	patientNames := map[uint]string{}
+	if len(patientIDs) > 0 {
+		var patients []models.Patient
+		db.Session(&gorm.Session{NewDB: true}).Select("id, name").Where("id IN ?", patientIDs).Find(&patients)
+		for _, patient := range patients {
+			patientNames[patient.ID] = patient.Name
+		}
+	}
+
+	statement := &CommissionStatement{
+		DentistID:   dentistID,
+		DentistName: commissionDentistName(dentistID),
+		StartDate:   start,
+		EndDate:     end,
+		Lines:       make([]CommissionStatementLine, 0, len(commissions)),
+	}
+	for _, commission := range commissions {
+		line := CommissionStatementLine{Commission: commission}
+		if commission.PatientID != nil {
+			line.PatientName = patientNames[*commission.PatientID]
+		}
+		statement.Lines = append(statement.Lines, line)
+
+		statement.GrossTotal += commission.GrossAmount
+		statement.Deductions += commission.CardFee + commission.LabCost
+		statement.Total += commission.Amount
+		if commission.Status == models.CommissionStatusPaid {
+			statement.Paid += commission.Amount
+		} else {
+			statement.Pending += commission.Amount
+		}
+	}
+	return statement, nil
+}
+
+// loadCommissionStatement builds the statement requested by dentist_id, start_date and end_date.
+// Dentists only get their own statement. Writes the error response and returns nil on failure
+func loadCommissionStatement(c *gin.Context, db *gorm.DB) *CommissionStatement {
+	dentistID := commissionDentistScope(c)
+	if dentistID == 0 {
+		id, err := strconv.ParseUint(c.Query("dentist_id"), 10, 32)
+		if err != nil || id == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Informe o profissional (dentist_id)"})
+			return nil
+		}
+		dentistID = uint(id)
+	}
+	start, end, err := parseCommissionPeriod(c.Query("start_date"), c.Query("end_date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Período inválido. Use o formato AAAA-MM-DD"})
+		return nil
+	}
+
+	statement, err := buildCommissionStatement(db, dentistID, start, end)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar extrato de comissões"})
+		return nil
+	}
+	return statement
+}
+
+// GetCommissions lists commissions with the pending and paid totals
+// GET /commissions?dentist_id=&status=&source=&start_date=&end_date=&page=&page_size=
+func GetCommissions(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 200 {
+		pageSize = 50
+	}
+
+	query := db.Session(&gorm.Session{NewDB: true}).Model(&models.Commission{})
+	if dentistID := commissionDentistScope(c); dentistID != 0 {
+		query = query.Where("dentist_id = ?", dentistID)
+	} else if dentistID := c.Query("dentist_id"); dentistID != "" {
+		query = query.Where("dentist_id = ?", dentistID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if source := c.Query("source"); source != "" {
+		query = query.Where("source = ?", source)
+	}
+	if startDate := c.Query("start_date"); startDate != "" {
+		query = query.Where("DATE(reference_date) >= ?", startDate)
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		query = query.Where("DATE(reference_date) <= ?", endDate)
+	}
+
+	var totals struct {
+		Pending float64 `json:"pending"`
+		Paid    float64 `json:"paid"`
+	}
+	query.Session(&gorm.Session{}).Select(`
+		COALESCE(SUM(CASE WHEN status = 'pending' THEN amount END), 0) AS pending,
+		COALESCE(SUM(CASE WHEN status = 'paid' THEN amount END), 0) AS paid
+	`).Scan(&totals)
+
+	var total int64
+	query.Session(&gorm.Session{}).Count(&total)
+
+	var commissions []models.Commission
+	if err := query.Order("reference_date DESC, id DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).Find(&commissions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar comissões"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"commissions": commissions,
+		"total":       total,
+		"page":        page,
+		"page_size":   pageSize,
+		"pending":     totals.Pending,
+		"paid":        totals.Paid,
+	})
+}
+
+// GetCommissionStatement returns the commissions of a professional in a period
+// GET /commissions/statement?dentist_id=&start_date=&end_date=
+func GetCommissionStatement(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	statement := loadCommissionStatement(c, db)
+	if statement == nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"statement": statement})
+}
+
+// CommissionPayoutRequest pays the pending commissions of a professional for a period
+type CommissionPayoutRequest struct {
+	DentistID       uint   `json:"dentist_id" binding:"required"`
+	StartDate       string `json:"start_date" binding:"required"`
+	EndDate         string `json:"end_date" binding:"required"`
+	PaymentMethod   string `json:"payment_method"`
+	RegisterExpense *bool  `json:"register_expense"` // Registers the payout in the cash flow (default true)
+	Notes           string `json:"notes"`
+}
+
+// CreateCommissionPayout marks the pending commissions of the period as paid, offsetting the
+// reversals, and registers the expense in the cash flow
+// POST /commissions/payouts
+func CreateCommissionPayout(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var req CommissionPayoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	start, end, err := parseCommissionPeriod(req.StartDate, req.EndDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Período inválido. Use o formato AAAA-MM-DD"})
+		return
+	}
+	dentistName := commissionDentistName(req.DentistID)
+	if dentistName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Profissional não encontrado"})
+		return
+	}
+
+	now := time.Now()
+	payout := models.CommissionPayout{
+		DentistID:     req.DentistID,
+		DentistName:   dentistName,
+		PeriodStart:   start,
+		PeriodEnd:     end,
+		PaidAt:        now,
+		PaymentMethod: req.PaymentMethod,
+		PaidByID:      c.GetUint("user_id"),
+		Notes:         req.Notes,
+	}
+
+	err = db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		var commissions []models.Commission
+		if err := commissionPeriodQuery(tx, req.DentistID, start, end).
+			Where("status = ?", models.CommissionStatusPending).
+			Find(&commissions).Error; err != nil {
+			return err
+		}
+		if len(commissions) == 0 {
+			return errNoPendingCommissions
+		}
+
+		ids := make([]uint, 0, len(commissions))
+		total := 0.0
+		for _, commission := range commissions {
+			ids = append(ids, commission.ID)
+			total += commission.Amount
+		}
+		payout.Total = math.Round(total*100) / 100
+		payout.CommissionCount = len(commissions)
+		if payout.Total <= 0 {
+			return errCommissionBalanceNotPositive
+		}
+
+		if req.RegisterExpense == nil || *req.RegisterExpense {
+			paidDate := now
+			expense := models.Payment{
+				Type:          "expense",
+				Category:      "commission",
+				Description:   fmt.Sprintf("Comissões de %s - %s a %s", dentistName, start.Format("02/01/2006"), end.Format("02/01/2006")),
+				Amount:        payout.Total,
+				PaymentMethod: req.PaymentMethod,
+				Status:        "paid",
+				PaidDate:      &paidDate,
+				Notes:         req.Notes,
+			}
+			if err := tx.Create(&expense).Error; err != nil {
+				return err
+			}
+			payout.ExpenseID = &expense.ID
+		}
+
+		if err := tx.Create(&payout).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Commission{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"status":     models.CommissionStatusPaid,
+			"paid_date":  now,
+			"payout_id":  payout.ID,
+			"updated_at": now,
+		}).Error
+	})
+	if errors.Is(err, errNoPendingCommissions) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nenhuma comissão pendente no período"})
+		return
+	}
+	if errors.Is(err, errCommissionBalanceNotPositive) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Os estornos do período superam as comissões pendentes"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao registrar pagamento de comissões"})
+		return
+	}
+
+	helpers.AuditAction(c, "create", "commission_payouts", payout.ID, true, map[string]interface{}{
+		"dentist_id": payout.DentistID,
+		"total":      payout.Total,
+		"count":      payout.CommissionCount,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{"payout": payout})
+}
+
+// GetCommissionPayouts lists the commission payouts
+// GET /commissions/payouts?dentist_id=
+func GetCommissionPayouts(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	query := db.Session(&gorm.Session{NewDB: true}).Model(&models.CommissionPayout{})
+	if dentistID := commissionDentistScope(c); dentistID != 0 {
+		query = query.Where("dentist_id = ?", dentistID)
+	} else if dentistID := c.Query("dentist_id"); dentistID != "" {
+		query = query.Where("dentist_id = ?", dentistID)
+	}
+
+	var payouts []models.CommissionPayout
+	if err := query.Order("paid_at DESC").Find(&payouts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar pagamentos de comissões"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"payouts": payouts, "total": len(payouts)})
+}
+
+// GetCommissionPayout returns a payout with its commissions
+// GET /commissions/payouts/:id
+func GetCommissionPayout(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var payout models.CommissionPayout
+	if err := db.Session(&gorm.Session{NewDB: true}).
+		Preload("Commissions", func(tx *gorm.DB) *gorm.DB { return tx.Order("reference_date ASC, id ASC") }).
+		First(&payout, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pagamento de comissões não encontrado"})
+		return
+	}
+	if dentistID := commissionDentistScope(c); dentistID != 0 && payout.DentistID != dentistID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pagamento de comissões não encontrado"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"payout": payout})
+}
+
+// DeleteCommissionPayout undoes a payout registered by mistake: its commissions go back to
+// pending and the expense is removed from the cash flow
+// DELETE /commissions/payouts/:id
+func DeleteCommissionPayout(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var payout models.CommissionPayout
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&payout, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pagamento de comissões não encontrado"})
+		return
+	}
+
+	err := db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Commission{}).Where("payout_id = ?", payout.ID).Updates(map[string]interface{}{
+			"status":     models.CommissionStatusPending,
+			"paid_date":  nil,
+			"payout_id":  nil,
+			"updated_at": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		if payout.ExpenseID != nil {
+			if err := tx.Delete(&models.Payment{}, *payout.ExpenseID).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&models.CommissionPayout{}, payout.ID).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao desfazer pagamento de comissões"})
+		return
+	}
+
+	helpers.AuditAction(c, "delete", "commission_payouts", payout.ID, true, map[string]interface{}{
+		"dentist_id": payout.DentistID,
+		"total":      payout.Total,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Pagamento de comissões desfeito com sucesso"})
+}
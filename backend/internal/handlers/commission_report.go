@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jung-kurt/gofpdf"
+	"github.com/xuri/excelize/v2"
+)
+
+// commissionSourceLabels are the Portuguese names of the commission origins
+var commissionSourceLabels = map[string]string{
+	models.CommissionSourcePayment:          "Pagamento",
+	models.CommissionSourceTreatmentPayment: "Parcela",
+	models.CommissionSourceCompletion:       "Realizado",
+}
+
+// commissionStatusLabels are the Portuguese names of the commission statuses
+var commissionStatusLabels = map[string]string{
+	models.CommissionStatusPending:   "Pendente",
+	models.CommissionStatusPaid:      "Pago",
+	models.CommissionStatusCancelled: "Cancelado",
+}
+
+// commissionStatementFilename names the statement files, e.g. comissoes_12_20240301_20240331
+func commissionStatementFilename(statement *CommissionStatement) string {
+	return fmt.Sprintf("comissoes_%d_%s_%s", statement.DentistID, statement.StartDate.Format("20060102"), statement.EndDate.Format("20060102"))
+}
+
+// commissionRate describes the rule applied: the percentage, or "fixo" for fixed commissions
+func commissionRate(commission models.Commission) string {
+	if commission.Percentage > 0 {
+		return fmt.Sprintf("%.1f%%", commission.Percentage)
+	}
+	return "fixo"
+}
+
+// shortenCommissionText cuts a text to fit a statement column
+func shortenCommissionText(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max-3]) + "..."
+}
+
+// GenerateCommissionStatementPDF renders the commission statement of a professional
+// GET /commissions/statement/pdf?dentist_id=&start_date=&end_date=
+func GenerateCommissionStatementPDF(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	statement := loadCommissionStatement(c, db)
+	if statement == nil {
+		return
+	}
+	clinicName, clinicAddress, clinicPhone := referralClinicInfo(c.GetUint("tenant_id"))
+
+	pdf := gofpdf.New("L", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("cp1252")
+
+	// Header
+	pdf.SetFont("Arial", "B", 16)
+	pdf.Cell(0, 10, tr(clinicName))
+	pdf.Ln(8)
+	pdf.SetFont("Arial", "", 9)
+	pdf.Cell(0, 5, tr(clinicAddress))
+	pdf.Ln(5)
+	if clinicPhone != "" {
+		pdf.Cell(0, 5, tr("Tel: "+clinicPhone))
+		pdf.Ln(5)
+	}
+	pdf.Ln(5)
+
+	// Title
+	pdf.SetFont("Arial", "B", 14)
+	pdf.Cell(0, 8, tr("Extrato de Comissões"))
+	pdf.Ln(10)
+	pdf.SetFont("Arial", "", 10)
+	pdf.Cell(0, 6, tr("Profissional: "+statement.DentistName))
+	pdf.Ln(6)
+	pdf.Cell(0, 6, tr(fmt.Sprintf("Período: %s a %s", statement.StartDate.Format("02/01/2006"), statement.EndDate.Format("02/01/2006"))))
+	pdf.Ln(10)
+
+	// Commissions
+	headers := []string{"Data", "Origem", "Paciente", "Descrição", "Bruto", "Deduções", "Base", "Regra", "Comissão", "Status"}
+	widths := []float64{20, 20, 45, 70, 22, 22, 22, 15, 22, 19}
+	pdf.SetFillColor(240, 240, 240)
+	pdf.SetFont("Arial", "B", 9)
+	for i, header := range headers {
+		pdf.CellFormat(widths[i], 7, tr(header), "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Arial", "", 8)
+	for _, line := range statement.Lines {
+		description := shortenCommissionText(line.Description, 45)
+		patient := shortenCommissionText(line.PatientName, 28)
+		pdf.CellFormat(widths[0], 6, line.ReferenceDate.Format("02/01/2006"), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(widths[1], 6, tr(commissionSourceLabels[line.Source]), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[2], 6, tr(patient), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[3], 6, tr(description), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[4], 6, fmt.Sprintf("R$ %.2f", line.GrossAmount), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[5], 6, fmt.Sprintf("R$ %.2f", line.CardFee+line.LabCost), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[6], 6, fmt.Sprintf("R$ %.2f", line.BaseAmount), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[7], 6, commissionRate(line.Commission), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(widths[8], 6, fmt.Sprintf("R$ %.2f", line.Amount), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[9], 6, tr(commissionStatusLabels[line.Status]), "1", 0, "C", false, 0, "")
+		pdf.Ln(-1)
+	}
+	if len(statement.Lines) == 0 {
+		pdf.CellFormat(0, 8, tr("Nenhuma comissão no período"), "1", 1, "C", false, 0, "")
+	}
+
+	// Totals
+	pdf.Ln(6)
+	pdf.SetFont("Arial", "B", 10)
+	totals := []struct {
+		label string
+		value float64
+	}{
+		{"Valor bruto", statement.GrossTotal},
+		{"Deduções (taxas e laboratório)", statement.Deductions},
+		{"Total de comissões", statement.Total},
+		{"Pago", statement.Paid},
+		{"A pagar", statement.Pending},
+	}
+	for _, total := range totals {
+		pdf.CellFormat(70, 7, tr(total.label), "1", 0, "L", true, 0, "")
+		pdf.CellFormat(35, 7, fmt.Sprintf("R$ %.2f", total.value), "1", 1, "R", false, 0, "")
+	}
+
+	// Footer
+	pdf.Ln(8)
+	pdf.SetFont("Arial", "I", 8)
+	pdf.Cell(0, 5, tr("Gerado em: "+time.Now().Format("02/01/2006 15:04")))
+
+	c.Header("Content-Type", "application/pdf")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.pdf", commissionStatementFilename(statement)))
+	if err := pdf.Output(c.Writer); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar PDF"})
+	}
+}
+
+// GenerateCommissionStatementExcel exports the commission statement of a professional
+// GET /commissions/statement/excel?dentist_id=&start_date=&end_date=
+func GenerateCommissionStatementExcel(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	statement := loadCommissionStatement(c, db)
+	if statement == nil {
+		return
+	}
+	clinicName, _, _ := referralClinicInfo(c.GetUint("tenant_id"))
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	sheet := "Comissões"
+	f.SetSheetName("Sheet1", sheet)
+
+	headerStyle, _ := f.NewStyle(&excelize.Style{
+		Font:      &excelize.Font{Bold: true, Size: 14},
+		Alignment: &excelize.Alignment{Horizontal: "center", Vertical: "center"},
+	})
+
+	titleStyle, _ := f.NewStyle(&excelize.Style{
+		Font:      &excelize.Font{Bold: true, Size: 12},
+		Alignment: &excelize.Alignment{Horizontal: "left", Vertical: "center"},
+	})
+
+	tableHeaderStyle, _ := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true},
+		Fill: excelize.Fill{Type: "pattern", Color: []string{"#D3D3D3"}, Pattern: 1},
+		Border: []excelize.Border{
+			{Type: "left", Color: "000000", Style: 1},
+			{Type: "top", Color: "000000", Style: 1},
+			{Type: "bottom", Color: "000000", Style: 1},
+			{Type: "right", Color: "000000", Style: 1},
+		},
+	})
+
+	cellStyle, _ := f.NewStyle(&excelize.Style{
+		Border: []excelize.Border{
+			{Type: "left", Color: "000000", Style: 1},
+			{Type: "top", Color: "000000", Style: 1},
+			{Type: "bottom", Color: "000000", Style: 1},
+			{Type: "right", Color: "000000", Style: 1},
+		},
+	})
+
+	f.SetColWidth(sheet, "A", "B", 14)
+	f.SetColWidth(sheet, "C", "C", 30)
+	f.SetColWidth(sheet, "D", "D", 40)
+	f.SetColWidth(sheet, "E", "J", 14)
+
+	row := 1
+	f.SetCellValue(sheet, fmt.Sprintf("A%d", row), clinicName)
+	f.SetCellStyle(sheet, fmt.Sprintf("A%d", row), fmt.Sprintf("J%d", row), headerStyle)
+	row += 2
+
+	f.SetCellValue(sheet, fmt.Sprintf("A%d", row), "Extrato de Comissões - "+statement.DentistName)
+	f.SetCellStyle(sheet, fmt.Sprintf("A%d", row), fmt.Sprintf("J%d", row), titleStyle)
+	row++
+	f.SetCellValue(sheet, fmt.Sprintf("A%d", row), fmt.Sprintf("Período: %s a %s", statement.StartDate.Format("02/01/2006"), statement.EndDate.Format("02/01/2006")))
+	row += 2
+
+	headers := []string{"Data", "Origem", "Paciente", "Descrição", "Bruto", "Taxa cartão", "Laboratório", "Base", "Comissão", "Status"}
+	for i, header := range headers {
+		cell, _ := excelize.CoordinatesToCellName(i+1, row)
+		f.SetCellValue(sheet, cell, header)
+	}
+	f.SetCellStyle(sheet, fmt.Sprintf("A%d", row), fmt.Sprintf("J%d", row), tableHeaderStyle)
+	row++
+
+	for _, line := range statement.Lines {
+		values := []interface{}{
+			line.ReferenceDate.Format("02/01/2006"),
+			commissionSourceLabels[line.Source],
+			line.PatientName,
+			line.Description,
+			line.GrossAmount,
+			line.CardFee,
+			line.LabCost,
+			line.BaseAmount,
+			line.Amount,
+			commissionStatusLabels[line.Status],
+		}
+		for i, value := range values {
+			cell, _ := excelize.CoordinatesToCellName(i+1, row)
+			f.SetCellValue(sheet, cell, value)
+		}
+		f.SetCellStyle(sheet, fmt.Sprintf("A%d", row), fmt.Sprintf("J%d", row), cellStyle)
+		row++
+	}
+	row++
+
+	// Totals
+	totals := []struct {
+		label string
+		value float64
+	}{
+		{"Valor bruto", statement.GrossTotal},
+		{"Deduções (taxas e laboratório)", statement.Deductions},
+		{"Total de comissões", statement.Total},
+		{"Pago", statement.Paid},
+		{"A pagar", statement.Pending},
+	}
+	for _, total := range totals {
+		f.SetCellValue(sheet, fmt.Sprintf("D%d", row), total.label)
+		f.SetCellValue(sheet, fmt.Sprintf("E%d", row), total.value)
+		f.SetCellStyle(sheet, fmt.Sprintf("D%d", row), fmt.Sprintf("E%d", row), titleStyle)
+		row++
+	}
+
+	// Footer
+	row += 2
+	f.SetCellValue(sheet, fmt.Sprintf("A%d", row), "Gerado em: "+time.Now().Format("02/01/2006 15:04"))
+
+	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.xlsx", commissionStatementFilename(statement)))
+	if err := f.Write(c.Writer); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar Excel"})
+	}
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/database"
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// controlledPrescriberID returns the professional whose book numbers a controlled prescription
+func controlledPrescriberID(prescription models.Prescription) uint {
+	if prescription.SignerID != nil && *prescription.SignerID > 0 {
+		return *prescription.SignerID
+	}
+	return prescription.DentistID
+}
+
+// assignControlledNumber takes the next number of the prescriber's book for a controlled prescription
+// being issued. It runs in the transaction that issues the prescription, so a failure does not use up a number
+func assignControlledNumber(tx *gorm.DB, prescription *models.Prescription, prescriberID uint) error {
+	if prescription.Type != models.PrescriptionTypeControlled || prescription.ControlledNumber != nil {
+		return nil
+	}
+
+	var number int
+	if err := tx.Raw(`
+		INSERT INTO controlled_prescription_books (prescriber_id, last_number, created_at, updated_at)
+		VALUES (?, 1, NOW(), NOW())
+		ON CONFLICT (prescriber_id) DO UPDATE
+		SET last_number = controlled_prescription_books.last_number + 1, updated_at = NOW()
+		RETURNING last_number
+	`, prescriberID).Row().Scan(&number); err != nil {
+		return err
+	}
+
+	if err := tx.Exec("UPDATE prescriptions SET controlled_number = ?, controlled_prescriber_id = ? WHERE id = ?",
+		number, prescriberID, prescription.ID).Error; err != nil {
+		return err
+	}
+
+	prescription.ControlledNumber = &number
+	prescription.ControlledPrescriberID = &prescriberID
+	return nil
+}
+
+// validatePrescriptionItems checks the items against the prescription type and answers 400 when invalid
+func validatePrescriptionItems(c *gin.Context, prescriptionType string, items []models.PrescriptionItem) bool {
+	if err := helpers.ValidateControlledPrescription(prescriptionType, items); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// GetControlledPrescriptionBooks lists the numbering books of controlled prescriptions
+// GET /prescriptions/controlled-books
+func GetControlledPrescriptionBooks(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var books []models.ControlledPrescriptionBook
+	if err := db.Session(&gorm.Session{NewDB: true}).Order("prescriber_id ASC").Find(&books).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar livros de receitas controladas"})
+		return
+	}
+
+	result := make([]gin.H, 0, len(books))
+	for _, book := range books {
+		var prescriber models.User
+		database.DB.Table("public.users").Select("id, name, cro").First(&prescriber, book.PrescriberID)
+		result = append(result, gin.H{
+			"prescriber_id":   book.PrescriberID,
+			"prescriber_name": prescriber.Name,
+			"prescriber_cro":  prescriber.CRO,
+			"last_number":     book.LastNumber,
+			"updated_at":      book.UpdatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"books": result})
+}
+
+// GetControlledPrescriptionBook returns the controlled prescriptions numbered for a prescriber
+// GET /prescriptions/controlled-books/:prescriber_id?start_date=&end_date=
+func GetControlledPrescriptionBook(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	prescriberID, err := strconv.ParseUint(c.Param("prescriber_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Prescritor inválido"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	offset := (page - 1) * pageSize
+
+	var book models.ControlledPrescriptionBook
+	db.Session(&gorm.Session{NewDB: true}).Where("prescriber_id = ?", prescriberID).First(&book)
+
+	query := db.Session(&gorm.Session{NewDB: true}).Model(&models.Prescription{}).
+		Where("controlled_prescriber_id = ? AND controlled_number IS NOT NULL", prescriberID)
+	if startDate := c.Query("start_date"); startDate != "" {
+		query = query.Where("DATE(issued_at) >= ?", startDate)
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		query = query.Where("DATE(issued_at) <= ?", endDate)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var prescriptions []models.Prescription
+	if err := query.Preload("Patient").
+		Preload("Items", func(tx *gorm.DB) *gorm.DB { return tx.Order("position ASC") }).
+		Offset(offset).Limit(pageSize).Order("controlled_number DESC").
+		Find(&prescriptions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar receitas controladas"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"prescriber_id": prescriberID,
+		"last_number":   book.LastNumber,
+		"prescriptions": prescriptions,
+		"total":         total,
+		"page":          page,
+		"page_size":     pageSize,
+	})
+}
+
+// UpdateControlledPrescriptionBookRequest sets the last number used, e.g. to continue a paper book
+type UpdateControlledPrescriptionBookRequest struct {
+	LastNumber int `json:"last_number" binding:"min=0"`
+}
+
+// UpdateControlledPrescriptionBook sets the last number of a prescriber's book
+// The number cannot go back below a number already used
+// PUT /prescriptions/controlled-books/:prescriber_id
+func UpdateControlledPrescriptionBook(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	prescriberID, err := strconv.ParseUint(c.Param("prescriber_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Prescritor inválido"})
+		return
+	}
+
+	var req UpdateControlledPrescriptionBookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var prescriber models.User
+	if err := database.DB.Table("public.users").First(&prescriber, prescriberID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Prescritor não encontrado"})
+		return
+	}
+
+	var used int
+	db.Session(&gorm.Session{NewDB: true}).Raw(`
+		SELECT COALESCE(MAX(controlled_number), 0) FROM prescriptions WHERE controlled_prescriber_id = ?
+	`, prescriberID).Row().Scan(&used)
+	if req.LastNumber < used {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "O número não pode ser menor que o último já utilizado", "last_used": used})
+		return
+	}
+
+	if err := db.Session(&gorm.Session{NewDB: true}).Exec(`
+		INSERT INTO controlled_prescription_books (prescriber_id, last_number, created_at, updated_at)
+		VALUES (?, ?, NOW(), NOW())
+		ON CONFLICT (prescriber_id) DO UPDATE SET last_number = EXCLUDED.last_number, updated_at = NOW()
+	`, prescriberID, req.LastNumber).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar livro de receitas controladas"})
+		return
+	}
+
+	helpers.AuditAction(c, "update_book", "prescriptions", 0, true, map[string]interface{}{
+		"prescriber_id": prescriberID,
+		"last_number":   req.LastNumber,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"prescriber_id": prescriberID,
+		"last_number":   req.LastNumber,
+	})
+}
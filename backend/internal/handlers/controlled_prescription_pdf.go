@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/models"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jung-kurt/gofpdf"
+	"gorm.io/gorm"
+)
+
+// outputControlledPrescriptionPDF renders a controlled prescription with its items and writes it to the response
+func outputControlledPrescriptionPDF(c *gin.Context, db *gorm.DB, prescription *models.Prescription, filename string) {
+	var items []models.PrescriptionItem
+	db.Session(&gorm.Session{NewDB: true}).Where("prescription_id = ?", prescription.ID).Order("position ASC").Find(&items)
+
+	pdf := renderControlledPrescriptionPDF(prescription, items)
+
+	c.Header("Content-Type", "application/pdf")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	if err := pdf.Output(c.Writer); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar PDF"})
+	}
+}
+
+// controlledPrescriptionCopies are the two copies of the Receita de Controle Especial
+var controlledPrescriptionCopies = []string{
+	"1ª via - Retenção da farmácia ou drogaria",
+	"2ª via - Orientação ao paciente",
+}
+
+// patientFullAddress joins the address fields of a patient in a single line
+func patientFullAddress(patient *models.Patient) string {
+	if patient == nil {
+		return ""
+	}
+	parts := []string{}
+	street := strings.TrimSpace(strings.Join([]string{patient.Address, patient.Number}, ", "))
+	street = strings.Trim(street, ", ")
+	for _, part := range []string{street, patient.Complement, patient.District} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	address := strings.Join(parts, " - ")
+	if patient.City != "" {
+		address += " - " + patient.City
+		if patient.State != "" {
+			address += "/" + patient.State
+		}
+	}
+	return strings.Trim(address, " -")
+}
+
+// renderControlledPrescriptionPDF renders the Receita de Controle Especial (Portaria SVS/MS 344/98):
+// two identical copies side by side on a landscape A4, each with the buyer and supplier fields
+// that are filled in by hand at the pharmacy
+func renderControlledPrescriptionPDF(prescription *models.Prescription, items []models.PrescriptionItem) *gofpdf.Fpdf {
+	pdf := gofpdf.New("L", "mm", "A4", "")
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("cp1252")
+
+	pageWidth, pageHeight := pdf.GetPageSize()
+	copyWidth := (pageWidth - 30) / 2 // 10mm margins and 10mm between the copies
+	for i, label := range controlledPrescriptionCopies {
+		x := 10 + float64(i)*(copyWidth+10)
+		renderControlledPrescriptionCopy(pdf, tr, x, copyWidth, label, prescription, items)
+	}
+
+	// Cut line between the copies
+	pdf.SetDrawColor(150, 150, 150)
+	pdf.SetDashPattern([]float64{2, 2}, 0)
+	pdf.Line(pageWidth/2, 5, pageWidth/2, pageHeight-5)
+	pdf.SetDashPattern([]float64{}, 0)
+	pdf.SetDrawColor(0, 0, 0)
+
+	return pdf
+}
+
+// renderControlledPrescriptionCopy renders one copy of the form in the column starting at x
+func renderControlledPrescriptionCopy(pdf *gofpdf.Fpdf, tr func(string) string, x, width float64, label string, prescription *models.Prescription, items []models.PrescriptionItem) {
+	pageWidth, _ := pdf.GetPageSize()
+	pdf.SetLeftMargin(x)
+	pdf.SetRightMargin(pageWidth - x - width)
+	pdf.SetXY(x, 10)
+
+	// Title and number
+	pdf.SetFont("Arial", "B", 12)
+	pdf.CellFormat(0, 6, tr("RECEITUÁRIO DE CONTROLE ESPECIAL"), "", 1, "C", false, 0, "")
+	pdf.SetFont("Arial", "", 8)
+	pdf.CellFormat(0, 4, tr(label), "", 1, "C", false, 0, "")
+	number := "Nº ________ (numerada na emissão)"
+	if prescription.ControlledNumber != nil {
+		number = fmt.Sprintf("Nº %06d", *prescription.ControlledNumber)
+	}
+	pdf.SetFont("Arial", "B", 10)
+	pdf.CellFormat(0, 6, tr(number), "", 1, "R", false, 0, "")
+
+	// Prescriber
+	signerName := prescription.SignerName
+	signerCRO := prescription.SignerCRO
+	if signerName == "" {
+		signerName = prescription.DentistName
+		signerCRO = prescription.DentistCRO
+	}
+	controlledSectionHeader(pdf, tr, "IDENTIFICAÇÃO DO EMITENTE")
+	controlledField(pdf, tr, "Nome: ", signerName)
+	controlledField(pdf, tr, "CRO: ", signerCRO)
+	controlledField(pdf, tr, "Clínica: ", prescription.ClinicName)
+	controlledField(pdf, tr, "Endereço: ", prescription.ClinicAddress)
+	controlledField(pdf, tr, "Telefone: ", prescription.ClinicPhone)
+	pdf.Ln(2)
+
+	// Patient
+	patientName := ""
+	if prescription.Patient != nil {
+		patientName = prescription.Patient.Name
+	}
+	controlledField(pdf, tr, "Paciente: ", patientName)
+	controlledField(pdf, tr, "Endereço: ", patientFullAddress(prescription.Patient))
+	pdf.Ln(2)
+
+	// Prescription
+	controlledSectionHeader(pdf, tr, "PRESCRIÇÃO")
+	medications := prescription.Medications
+	if len(items) > 0 {
+		medications = helpers.FormatPrescriptionItems(items)
+	}
+	pdf.SetFont("Arial", "", 9)
+	pdf.MultiCell(0, 4.5, tr(medications), "", "L", false)
+	if prescription.Content != "" {
+		pdf.Ln(1)
+		pdf.SetFont("Arial", "I", 8)
+		pdf.MultiCell(0, 4, tr(prescription.Content), "", "L", false)
+	}
+
+	// Date and prescriber signature, at a fixed position so both copies match
+	dateStr := time.Now().Format("02/01/2006")
+	if prescription.PrescriptionDate != nil {
+		dateStr = prescription.PrescriptionDate.Format("02/01/2006")
+	} else if prescription.IssuedAt != nil {
+		dateStr = prescription.IssuedAt.Format("02/01/2006")
+	}
+	pdf.SetXY(x, 136)
+	pdf.SetFont("Arial", "", 9)
+	pdf.CellFormat(0, 5, tr(fmt.Sprintf("Data: %s", dateStr)), "", 1, "L", false, 0, "")
+	pdf.SetXY(x+width/2-35, 148)
+	pdf.CellFormat(70, 0, "", "T", 1, "C", false, 0, "")
+	pdf.SetX(x + width/2 - 35)
+	pdf.SetFont("Arial", "", 8)
+	pdf.CellFormat(70, 4, tr("Assinatura do prescritor"), "", 1, "C", false, 0, "")
+	if prescription.IsSigned && prescription.SignedAt != nil {
+		pdf.SetFont("Arial", "I", 6)
+		pdf.MultiCell(0, 3, tr(fmt.Sprintf("Assinado digitalmente (ICP-Brasil) por %s em %s - Hash SHA-256: %s",
+			prescription.SignedByName, prescription.SignedAt.Format("02/01/2006 15:04"), prescription.SignatureHash)), "", "C", false)
+	}
+
+	// Buyer and supplier, filled in at the pharmacy
+	boxY := 160.0
+	boxWidth := (width - 4) / 2
+	controlledFillInBox(pdf, tr, x, boxY, boxWidth, "IDENTIFICAÇÃO DO COMPRADOR",
+		[]string{"Nome:", "RG:                      Órgão emissor:", "Endereço:", "Cidade:                            UF:", "Telefone:"})
+	controlledFillInBox(pdf, tr, x+boxWidth+4, boxY, boxWidth, "IDENTIFICAÇÃO DO FORNECEDOR",
+		[]string{"", "", "Assinatura do farmacêutico", "", "Data: ____/____/______"})
+}
+
+// controlledSectionHeader renders a gray section header across the copy
+func controlledSectionHeader(pdf *gofpdf.Fpdf, tr func(string) string, title string) {
+	pdf.SetFillColor(240, 240, 240)
+	pdf.SetFont("Arial", "B", 9)
+	pdf.CellFormat(0, 5, tr(title), "", 1, "L", true, 0, "")
+	pdf.Ln(1)
+}
+
+// controlledField renders a bold label followed by its value
+func controlledField(pdf *gofpdf.Fpdf, tr func(string) string, label, value string) {
+	pdf.SetFont("Arial", "B", 8)
+	pdf.CellFormat(pdf.GetStringWidth(tr(label))+1, 4.5, tr(label), "", 0, "L", false, 0, "")
+	pdf.SetFont("Arial", "", 8)
+	pdf.MultiCell(0, 4.5, tr(value), "", "L", false)
+}
+
+// controlledFillInBox renders a bordered box with a title and blank lines to be filled in by hand.
+// Lines starting with "Assinatura" are drawn as signature lines
+func controlledFillInBox(pdf *gofpdf.Fpdf, tr func(string) string, x, y, width float64, title string, lines []string) {
+	lineHeight := 6.5
+	height := 6 + float64(len(lines))*lineHeight
+	pdf.Rect(x, y, width, height, "D")
+
+	pdf.SetXY(x, y)
+	pdf.SetFillColor(240, 240, 240)
+	pdf.SetFont("Arial", "B", 8)
+	pdf.CellFormat(width, 5, tr(title), "B", 0, "C", true, 0, "")
+
+	pdf.SetFont("Arial", "", 7)
+	for i, line := range lines {
+		lineY := y + 6 + float64(i)*lineHeight
+		pdf.SetXY(x+2, lineY)
+		if strings.HasPrefix(line, "Assinatura") {
+			pdf.CellFormat(width-4, lineHeight, tr(line), "T", 0, "C", false, 0, "")
+			continue
+		}
+		pdf.CellFormat(width-4, lineHeight, tr(line), "", 0, "L", false, 0, "")
+	}
+}
@@ -103,24 +103,99 @@ func PermanentDeletePatient(c *gin.Context) {
 		return
 	}
 
-	// 4. Delete waiting list entries
+	// 4. Delete waiting list offers (hold the phone or email of the patient) and entries
+	if err := tx.Unscoped().Where("patient_id = ?", patientID).Delete(&models.WaitingListOffer{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir ofertas da lista de espera"})
+		return
+	}
 	if err := tx.Unscoped().Where("patient_id = ?", patientID).Delete(&models.WaitingList{}).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir lista de espera"})
 		return
 	}
 
-	// 5. Delete prescriptions (linked to medical records)
+	// 5. Delete prescriptions with their items and safety checks
+	var prescriptionIDs []uint
+	tx.Model(&models.Prescription{}).Unscoped().Where("patient_id = ?", patientID).Pluck("id", &prescriptionIDs)
+	if len(prescriptionIDs) > 0 {
+		if err := tx.Where("prescription_id IN ?", prescriptionIDs).Delete(&models.PrescriptionItem{}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir itens de receitas"})
+			return
+		}
+		if err := tx.Where("prescription_id IN ?", prescriptionIDs).Delete(&models.PrescriptionSafetyCheck{}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir alertas de receitas"})
+			return
+		}
+	}
+	if err := tx.Unscoped().Where("patient_id = ?", patientID).Delete(&models.Prescription{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir receitas"})
+		return
+	}
+
+	// Referrals to external specialists (the returned reports are deleted with the exams)
+	if err := tx.Unscoped().Where("patient_id = ?", patientID).Delete(&models.Referral{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir encaminhamentos"})
+		return
+	}
+
+	// Medical record addenda and revisions
 	var medicalRecordIDs []uint
 	tx.Model(&models.MedicalRecord{}).Where("patient_id = ?", patientID).Pluck("id", &medicalRecordIDs)
 	if len(medicalRecordIDs) > 0 {
-		if err := tx.Unscoped().Where("medical_record_id IN ?", medicalRecordIDs).Delete(&models.Prescription{}).Error; err != nil {
+		if err := tx.Where("medical_record_id IN ?", medicalRecordIDs).Delete(&models.MedicalRecordAddendum{}).Error; err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir receitas"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir adendos de prontuarios"})
+			return
+		}
+		if err := tx.Where("medical_record_id IN ?", medicalRecordIDs).Delete(&models.MedicalRecordRevision{}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir revisoes de prontuarios"})
 			return
 		}
 	}
 
+	// Delete odontogram events and periodontal charts
+	if err := tx.Unscoped().Where("patient_id = ?", patientID).Delete(&models.ToothEvent{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir odontograma"})
+		return
+	}
+	var periodontalExamIDs []uint
+	tx.Model(&models.PeriodontalExam{}).Unscoped().Where("patient_id = ?", patientID).Pluck("id", &periodontalExamIDs)
+	if len(periodontalExamIDs) > 0 {
+		if err := tx.Where("exam_id IN ?", periodontalExamIDs).Delete(&models.PeriodontalTooth{}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir periodontogramas"})
+			return
+		}
+	}
+	if err := tx.Unscoped().Where("patient_id = ?", patientID).Delete(&models.PeriodontalExam{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir periodontogramas"})
+		return
+	}
+
+	// Delete anamnesis questionnaires
+	var anamnesisIDs []uint
+	tx.Model(&models.AnamnesisResponse{}).Unscoped().Where("patient_id = ?", patientID).Pluck("id", &anamnesisIDs)
+	if len(anamnesisIDs) > 0 {
+		if err := tx.Where("response_id IN ?", anamnesisIDs).Delete(&models.AnamnesisAnswer{}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir anamneses"})
+			return
+		}
+	}
+	if err := tx.Unscoped().Where("patient_id = ?", patientID).Delete(&models.AnamnesisResponse{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir anamneses"})
+		return
+	}
+
 	// 6. Delete medical records
 	if err := tx.Unscoped().Where("patient_id = ?", patientID).Delete(&models.MedicalRecord{}).Error; err != nil {
 		tx.Rollback()
@@ -129,6 +204,17 @@ func PermanentDeletePatient(c *gin.Context) {
 	}
 
 	// 7. Delete treatment payments (linked to treatments via budgets)
+	// The commissions already earned by the professionals are kept, unlinked from the patient
+	if err := tx.Model(&models.Commission{}).Unscoped().Where("patient_id = ?", patientID).Updates(map[string]interface{}{
+		"patient_id":           nil,
+		"payment_id":           nil,
+		"treatment_payment_id": nil,
+		"plan_item_id":         nil,
+	}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao desvincular comissões"})
+		return
+	}
 	var budgetIDs []uint
 	tx.Model(&models.Budget{}).Where("patient_id = ?", patientID).Pluck("id", &budgetIDs)
 	if len(budgetIDs) > 0 {
@@ -149,13 +235,42 @@ func PermanentDeletePatient(c *gin.Context) {
 		}
 	}
 
-	// 8. Delete budgets
+	// Insurance guides and their procedures. The lot XML already delivered to the insurer is kept
+	// as the billing record of the other guides
+	var guideIDs []uint
+	tx.Model(&models.InsuranceGuide{}).Unscoped().Where("patient_id = ?", patientID).Pluck("id", &guideIDs)
+	if len(guideIDs) > 0 {
+		if err := tx.Unscoped().Where("guide_id IN ?", guideIDs).Delete(&models.InsuranceGuideItem{}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir procedimentos das guias de convênio"})
+			return
+		}
+		if err := tx.Unscoped().Where("id IN ?", guideIDs).Delete(&models.InsuranceGuide{}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir guias de convênio"})
+			return
+		}
+	}
+
+	// 8. Delete treatment plan items and budgets
+	if err := tx.Unscoped().Where("patient_id = ?", patientID).Delete(&models.TreatmentPlanItem{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir plano de tratamento"})
+		return
+	}
 	if err := tx.Unscoped().Where("patient_id = ?", patientID).Delete(&models.Budget{}).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir orcamentos"})
 		return
 	}
 
+	// PIX charges issued to the patient (payload and description identify the treatment)
+	if err := tx.Unscoped().Where("patient_id = ?", patientID).Delete(&models.PixCharge{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir cobranças PIX"})
+		return
+	}
+
 	// 9. Delete payments
 	if err := tx.Unscoped().Where("patient_id = ?", patientID).Delete(&models.Payment{}).Error; err != nil {
 		tx.Rollback()
@@ -163,13 +278,29 @@ func PermanentDeletePatient(c *gin.Context) {
 		return
 	}
 
-	// 10. Delete exams
+	// 10. Delete exams (and the DICOM instances of their studies)
+	var examIDs []uint
+	tx.Model(&models.Exam{}).Unscoped().Where("patient_id = ?", patientID).Pluck("id", &examIDs)
+	if len(examIDs) > 0 {
+		if err := tx.Unscoped().Where("exam_id IN ?", examIDs).Delete(&models.ExamInstance{}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir arquivos DICOM"})
+			return
+		}
+	}
 	if err := tx.Unscoped().Where("patient_id = ?", patientID).Delete(&models.Exam{}).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir exames"})
 		return
 	}
 
+	// Reminder dispatch log (holds the phone or email the reminder was sent to)
+	if err := tx.Unscoped().Where("patient_id = ?", patientID).Delete(&models.AppointmentReminder{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir lembretes de agendamento"})
+		return
+	}
+
 	// 11. Delete appointments
 	if err := tx.Unscoped().Where("patient_id = ?", patientID).Delete(&models.Appointment{}).Error; err != nil {
 		tx.Rollback()
@@ -280,12 +411,7 @@ func GetPatientDeletionPreview(c *gin.Context) {
 	db.Model(&models.WaitingList{}).Where("patient_id = ?", patientID).Count(&counts.WaitingList)
 	db.Model(&models.DataRequest{}).Where("patient_id = ?", patientID).Count(&counts.DataRequests)
 
-	// Count prescriptions through medical records
-	var medicalRecordIDs []uint
-	db.Model(&models.MedicalRecord{}).Where("patient_id = ?", patientID).Pluck("id", &medicalRecordIDs)
-	if len(medicalRecordIDs) > 0 {
-		db.Model(&models.Prescription{}).Where("medical_record_id IN ?", medicalRecordIDs).Count(&counts.Prescriptions)
-	}
+	db.Model(&models.Prescription{}).Where("patient_id = ?", patientID).Count(&counts.Prescriptions)
 
 	// Generate confirmation token
 	confirmationToken := fmt.Sprintf("DELETE-%d", patientID)
@@ -11,12 +11,14 @@ import (
 	"drcrwell/backend/internal/models"
 	"encoding/hex"
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jung-kurt/gofpdf"
+	"gorm.io/gorm"
 	pkcs12 "software.sslmate.com/src/go-pkcs12"
 )
 
@@ -34,7 +36,10 @@ func SignPrescription(c *gin.Context) {
 		return
 	}
 
-	var input SignDocumentRequest
+	var input struct {
+		SignDocumentRequest
+		PrescriptionOverride
+	}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Senha do certificado obrigatória"})
 		return
@@ -53,6 +58,16 @@ func SignPrescription(c *gin.Context) {
 		return
 	}
 
+	// Signing a draft issues it, so its items are checked like in IssuePrescription
+	var check prescriptionSafetyCheck
+	checkItems := false
+	if prescription.Status == "draft" {
+		var ok bool
+		if check, checkItems, ok = checkPrescriptionBeforeIssue(c, db, prescription, input.PrescriptionOverride, "sign"); !ok {
+			return
+		}
+	}
+
 	// Get user's active certificate
 	cert, err := GetActiveCertificate(userID)
 	if err != nil {
@@ -84,53 +99,81 @@ func SignPrescription(c *gin.Context) {
 		return
 	}
 
-	// Generate PDF content for hashing
-	pdfContent, err := generatePrescriptionPDFContent(&prescription)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar conteúdo do documento"})
-		return
-	}
-
-	// Create hash of the document
-	hash := sha256.Sum256(pdfContent)
-	hashHex := hex.EncodeToString(hash[:])
-
-	// Sign the hash
 	rsaKey, ok := privateKey.(*rsa.PrivateKey)
 	if !ok {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Tipo de chave não suportado (apenas RSA)"})
 		return
 	}
 
-	signature, err := rsa.SignPKCS1v15(nil, rsaKey, crypto.SHA256, hash[:])
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao assinar documento"})
-		return
-	}
+	// Signing a draft controlled prescription takes its number from the prescriber's book.
+	// The number is part of the signed content, so numbering and signing share a transaction
+	now := time.Now()
+	var hashHex string
+	var signErr string
+	err = db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		if prescription.Status == "draft" {
+			if err := assignControlledNumber(tx, &prescription, userID); err != nil {
+				signErr = "Erro ao numerar receita de controle especial"
+				return err
+			}
+		}
 
-	// Verify signature
-	err = rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, hash[:], signature)
+		// Generate PDF content for hashing
+		pdfContent, err := generatePrescriptionPDFContent(&prescription)
+		if err != nil {
+			signErr = "Erro ao gerar conteúdo do documento"
+			return err
+		}
+
+		// Create hash of the document
+		hash := sha256.Sum256(pdfContent)
+		hashHex = hex.EncodeToString(hash[:])
+
+		// Sign the hash
+		signature, err := rsa.SignPKCS1v15(nil, rsaKey, crypto.SHA256, hash[:])
+		if err != nil {
+			signErr = "Erro ao assinar documento"
+			return err
+		}
+
+		// Verify signature
+		if err := rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, hash[:], signature); err != nil {
+			signErr = "Erro na verificação da assinatura"
+			return err
+		}
+
+		// Update prescription with signature info using raw SQL to avoid GORM table duplication issue
+		if err := tx.Exec(`
+			UPDATE prescriptions
+			SET is_signed = true, signed_at = ?, signed_by_id = ?, signed_by_name = ?,
+			    signed_by_cro = ?, certificate_id = ?, certificate_thumbprint = ?,
+			    signature_hash = ?, status = 'issued', issued_at = ?, updated_at = NOW()
+			WHERE id = ? AND deleted_at IS NULL
+		`, now, userID, user.Name, user.CRO, cert.ID, cert.Thumbprint, hashHex, now, prescription.ID).Error; err != nil {
+			signErr = "Erro ao salvar assinatura"
+			helpers.AuditAction(c, "sign_prescription", "prescriptions", prescription.ID, false, map[string]interface{}{
+				"error": signErr,
+			})
+			return err
+		}
+		return nil
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro na verificação da assinatura"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": signErr})
 		return
 	}
 
-	// Update prescription with signature info using raw SQL to avoid GORM table duplication issue
-	now := time.Now()
-	result := db.Exec(`
-		UPDATE prescriptions
-		SET is_signed = true, signed_at = ?, signed_by_id = ?, signed_by_name = ?,
-		    signed_by_cro = ?, certificate_id = ?, certificate_thumbprint = ?,
-		    signature_hash = ?, status = 'issued', issued_at = ?, updated_at = NOW()
-		WHERE id = ? AND deleted_at IS NULL
-	`, now, userID, user.Name, user.CRO, cert.ID, cert.Thumbprint, hashHex, now, prescription.ID)
-
-	if result.Error != nil {
-		helpers.AuditAction(c, "sign_prescription", "prescriptions", prescription.ID, false, map[string]interface{}{
-			"error": "Erro ao salvar assinatura",
-		})
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao salvar assinatura"})
-		return
+	if checkItems {
+		if err := recordPrescriptionSafetyCheck(db.Session(&gorm.Session{NewDB: true}), prescription.ID, userID, check); err != nil {
+			log.Printf("Failed to record prescription safety check: %v", err)
+		}
+		if check.Overridden {
+			helpers.AuditAction(c, "override_warnings", "prescriptions", prescription.ID, true, map[string]interface{}{
+				"stage":    check.Stage,
+				"warnings": check.Warnings,
+				"reason":   check.Reason,
+			})
+		}
 	}
 
 	// Update local struct for response
@@ -310,6 +353,133 @@ func SignMedicalRecord(c *gin.Context) {
 	})
 }
 
+// SignMedicalRecordAddendum signs an addendum of a medical record with the author's digital certificate
+func SignMedicalRecordAddendum(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	db, ok := middleware.GetDBFromContextSafe(c)
+	if !ok {
+		return
+	}
+
+	var input SignDocumentRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Senha do certificado obrigatória"})
+		return
+	}
+
+	var addendum models.MedicalRecordAddendum
+	if err := db.Where("id = ? AND medical_record_id = ?", c.Param("addendum_id"), c.Param("id")).First(&addendum).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Adendo não encontrado"})
+		return
+	}
+
+	if addendum.IsSigned {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Adendo já está assinado"})
+		return
+	}
+	if addendum.AuthorID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Apenas o autor pode assinar o adendo"})
+		return
+	}
+
+	// Get user's active certificate
+	cert, err := GetActiveCertificate(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nenhum certificado ativo encontrado. Faça upload de um certificado primeiro."})
+		return
+	}
+
+	// Get user info (use database.GetDB() to access public schema directly)
+	var user models.User
+	if err := database.GetDB().Table("public.users").First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar dados do usuário"})
+		return
+	}
+
+	// Decrypt and parse certificate
+	pfxData, err := DecryptCertificate(cert, input.Password)
+	if err != nil {
+		helpers.AuditAction(c, "sign_medical_record_addendum", "medical_records", addendum.MedicalRecordID, false, map[string]interface{}{
+			"addendum_id": addendum.ID,
+			"error":       "Senha do certificado inválida",
+		})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Senha do certificado inválida"})
+		return
+	}
+
+	privateKey, x509Cert, err := pkcs12.Decode(pfxData, input.Password)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Erro ao processar certificado"})
+		return
+	}
+
+	contentToSign := fmt.Sprintf(
+		"MedicalRecordAddendum|ID:%d|MedicalRecordID:%d|AuthorID:%d|Reason:%s|Content:%s|CreatedAt:%s",
+		addendum.ID, addendum.MedicalRecordID, addendum.AuthorID,
+		addendum.Reason, addendum.Content, addendum.CreatedAt.Format(time.RFC3339),
+	)
+	hash := sha256.Sum256([]byte(contentToSign))
+	hashHex := hex.EncodeToString(hash[:])
+
+	rsaKey, ok := privateKey.(*rsa.PrivateKey)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Tipo de chave não suportado (apenas RSA)"})
+		return
+	}
+
+	signature, err := rsa.SignPKCS1v15(nil, rsaKey, crypto.SHA256, hash[:])
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao assinar documento"})
+		return
+	}
+	if err := rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, hash[:], signature); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro na verificação da assinatura"})
+		return
+	}
+
+	now := time.Now()
+	result := db.Exec(`
+		UPDATE medical_record_addenda
+		SET is_signed = true, signed_at = ?, signed_by_id = ?, signed_by_name = ?,
+		    signed_by_cro = ?, certificate_id = ?, certificate_thumbprint = ?, signature_hash = ?
+		WHERE id = ? AND is_signed = false
+	`, now, userID, user.Name, user.CRO, cert.ID, cert.Thumbprint, hashHex, addendum.ID)
+
+	if result.Error != nil || result.RowsAffected == 0 {
+		helpers.AuditAction(c, "sign_medical_record_addendum", "medical_records", addendum.MedicalRecordID, false, map[string]interface{}{
+			"addendum_id": addendum.ID,
+			"error":       "Erro ao salvar assinatura",
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao salvar assinatura"})
+		return
+	}
+
+	// Update certificate last used
+	cert.LastUsedAt = &now
+	database.GetDB().Save(cert)
+
+	helpers.AuditAction(c, "sign_medical_record_addendum", "medical_records", addendum.MedicalRecordID, true, map[string]interface{}{
+		"addendum_id":    addendum.ID,
+		"certificate_id": cert.ID,
+		"certificate_cn": x509Cert.Subject.CommonName,
+		"signature_hash": hashHex[:16] + "...",
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Adendo assinado digitalmente com sucesso",
+		"addendum": gin.H{
+			"id":                     addendum.ID,
+			"medical_record_id":      addendum.MedicalRecordID,
+			"is_signed":              true,
+			"signed_at":              now,
+			"signed_by_name":         user.Name,
+			"signed_by_cro":          user.CRO,
+			"certificate_thumbprint": cert.Thumbprint,
+			"signature_hash":         hashHex,
+		},
+	})
+}
+
 // GenerateSignedPrescriptionPDF generates a PDF with digital signature information
 func GenerateSignedPrescriptionPDF(c *gin.Context) {
 	id := c.Param("id")
@@ -325,6 +495,16 @@ func GenerateSignedPrescriptionPDF(c *gin.Context) {
 		return
 	}
 
+	// Controlled prescriptions use the official two-copy form, with the signature on each copy
+	if prescription.Type == models.PrescriptionTypeControlled {
+		filename := fmt.Sprintf("receita_controle_especial_assinada_%s.pdf", id)
+		if !prescription.IsSigned {
+			filename = fmt.Sprintf("receita_controle_especial_%s.pdf", id)
+		}
+		outputControlledPrescriptionPDF(c, db, &prescription, filename)
+		return
+	}
+
 	// Get tenant settings for clinic info
 	var settings models.TenantSettings
 	settingsFound := database.DB.Table("public.tenant_settings").Where("tenant_id = ?", tenantID).First(&settings).Error == nil
@@ -474,7 +654,7 @@ func GenerateSignedPrescriptionPDF(c *gin.Context) {
 
 // VerifyDocumentSignature verifies if a document's signature is valid
 func VerifyDocumentSignature(c *gin.Context) {
-	docType := c.Param("type") // prescription or medical_record
+	docType := c.Param("type") // prescription, medical_record or medical_record_addendum
 	docID := c.Param("id")
 	db, ok := middleware.GetDBFromContextSafe(c)
 	if !ok {
@@ -517,6 +697,19 @@ func VerifyDocumentSignature(c *gin.Context) {
 		signatureInfo.CertificateThumbprint = record.CertificateThumbprint
 		signatureInfo.SignatureHash = record.SignatureHash
 
+	case "medical_record_addendum":
+		var addendum models.MedicalRecordAddendum
+		if err := db.First(&addendum, docID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Documento não encontrado"})
+			return
+		}
+		signatureInfo.IsSigned = addendum.IsSigned
+		signatureInfo.SignedAt = addendum.SignedAt
+		signatureInfo.SignedByName = addendum.SignedByName
+		signatureInfo.SignedByCRO = addendum.SignedByCRO
+		signatureInfo.CertificateThumbprint = addendum.CertificateThumbprint
+		signatureInfo.SignatureHash = addendum.SignatureHash
+
 	default:
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Tipo de documento inválido"})
 		return
@@ -555,6 +748,10 @@ func generatePrescriptionPDFContent(prescription *models.Prescription) ([]byte,
 	pdf.Ln(10)
 	pdf.Cell(0, 10, tr(fmt.Sprintf("Type: %s", prescription.Type)))
 	pdf.Ln(10)
+	if prescription.ControlledNumber != nil {
+		pdf.Cell(0, 10, tr(fmt.Sprintf("Controlled number: %d (prescriber %d)", *prescription.ControlledNumber, *prescription.ControlledPrescriberID)))
+		pdf.Ln(10)
+	}
 	pdf.SetFont("Arial", "", 10)
 	pdf.MultiCell(0, 5, tr(prescription.Content), "", "L", false)
 	if prescription.Medications != "" {
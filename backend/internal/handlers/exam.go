@@ -29,6 +29,8 @@ type ExamResponse struct {
 	models.Exam
 	PatientName    string `json:"patient_name"`
 	UploadedByName string `json:"uploaded_by_name"`
+	ThumbnailURL   string `json:"thumbnail_url"` // Presigned, valid for an hour
+	PreviewURL     string `json:"preview_url"`   // Presigned, valid for an hour
 }
 
 // MarshalJSON implements custom JSON marshaling to include both embedded and additional fields
@@ -38,10 +40,14 @@ func (e ExamResponse) MarshalJSON() ([]byte, error) {
 		*Alias
 		PatientName    string `json:"patient_name"`
 		UploadedByName string `json:"uploaded_by_name"`
+		ThumbnailURL   string `json:"thumbnail_url"`
+		PreviewURL     string `json:"preview_url"`
 	}{
 		Alias:          (*Alias)(&e),
 		PatientName:    e.PatientName,
 		UploadedByName: e.UploadedByName,
+		ThumbnailURL:   e.ThumbnailURL,
+		PreviewURL:     e.PreviewURL,
 	})
 }
 
@@ -61,6 +67,11 @@ func enrichExamWithRelatedData(db *gorm.DB, exam *models.Exam) ExamResponse {
 		response.UploadedByName = user.Name
 	}
 
+	// Renditions, so listings do not download the full-size originals
+	s3Client := examS3Client()
+	response.ThumbnailURL = examRenditionURL(s3Client, exam.ThumbnailS3Key, exam.ThumbnailURL)
+	response.PreviewURL = examRenditionURL(s3Client, exam.PreviewS3Key, exam.PreviewURL)
+
 	return response
 }
 
@@ -180,8 +191,7 @@ func CreateExam(c *gin.Context) {
 	defer file.Close()
 
 	// Validate file size (50MB max)
-	const maxFileSize = 50 * 1024 * 1024 // 50MB
-	if header.Size > maxFileSize {
+	if header.Size > maxExamFileSize {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "File size exceeds 50MB limit"})
 		return
 	}
@@ -194,16 +204,23 @@ func CreateExam(c *gin.Context) {
 		"image/jpg":        true,
 		"image/png":        true,
 		"image/gif":        true,
+		"image/webp":       true,
+		"image/heic":       true,
+		"image/heif":       true,
 		"application/zip":  true,
 		"application/x-zip-compressed": true,
+		"application/dicom": true,
+		"application/octet-stream": true, // .dcm files usually have no registered MIME type
 	}
 	if contentType != "" && !allowedTypes[contentType] {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file type. Allowed: PDF, JPEG, PNG, GIF, ZIP"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file type. Allowed: PDF, JPEG, PNG, GIF, ZIP, DICOM"})
 		return
 	}
 
 	// Validate file magic number (security: prevents extension spoofing)
-	valid, err := helpers.ValidateMedicalFile(file)
+	fileType, valid, err := helpers.ValidateFileMagicNumber(file, []helpers.FileType{
+		helpers.FileTypeImage, helpers.FileTypePDF, helpers.FileTypeDocument, helpers.FileTypeDICOM,
+	})
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to validate file type"})
 		return
@@ -213,6 +230,19 @@ func CreateExam(c *gin.Context) {
 		return
 	}
 
+	// Remove the GPS location of photos before storing them (privacy)
+	if fileType == helpers.FileTypeImage {
+		if stripped := stripUploadGPS(file); stripped != nil {
+			file = stripped
+		}
+	}
+
+	// DICOM files (a single radiograph or a whole series) are stored as a study
+	if fileType == helpers.FileTypeDICOM {
+		createDICOMExam(c, db, userID, &patient, name)
+		return
+	}
+
 	// Prepare S3 upload
 	s3Client, err := getS3Client()
 	if err != nil {
@@ -290,6 +320,9 @@ func CreateExam(c *gin.Context) {
 		return
 	}
 
+	// Thumbnail and preview are generated in the background
+	queueExamPreviews(c, db, examID)
+
 	// Fetch the created exam
 	var exam models.Exam
 	db.First(&exam, examID)
@@ -361,6 +394,9 @@ func uploadExamLocal(c *gin.Context, db *gorm.DB, userID uint, patientID uint, n
 		return
 	}
 
+	queueExamPreviews(c, db, exam.ID)
+	exam.PreviewStatus = models.ExamPreviewPending
+
 	c.JSON(http.StatusCreated, gin.H{
 		"exam": exam,
 		"message": "File uploaded locally (AWS S3 not configured)",
@@ -435,6 +471,7 @@ func GetExam(c *gin.Context) {
 	}
 
 	// Build response manually
+	s3Client := examS3Client()
 	response := gin.H{
 		"id":               exam.ID,
 		"created_at":       exam.CreatedAt,
@@ -451,6 +488,17 @@ func GetExam(c *gin.Context) {
 		"file_size":        exam.FileSize,
 		"uploaded_by_id":   exam.UploadedByID,
 		"notes":            exam.Notes,
+		"is_dicom":           exam.IsDICOM,
+		"study_instance_uid": exam.StudyInstanceUID,
+		"modality":           exam.Modality,
+		"body_part":          exam.BodyPart,
+		"dicom_patient_name": exam.DicomPatientName,
+		"dicom_patient_id":   exam.DicomPatientID,
+		"patient_mismatch":   exam.PatientMismatch,
+		"instance_count":     exam.InstanceCount,
+		"preview_status":     exam.PreviewStatus,
+		"preview_url":        examRenditionURL(s3Client, exam.PreviewS3Key, exam.PreviewURL),
+		"thumbnail_url":      examRenditionURL(s3Client, exam.ThumbnailS3Key, exam.ThumbnailURL),
 		"patient_name":     patientName,
 		"uploaded_by_name": uploadedByName,
 	}
@@ -518,6 +566,15 @@ func DeleteExam(c *gin.Context) {
 		return
 	}
 
+	// Thumbnail, preview and the instances of DICOM studies are stored next to the file
+	deleteExamRenditions(examS3Client(), &exam)
+	if exam.IsDICOM {
+		if err := deleteExamDICOMFiles(db, examS3Client(), &exam); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete exam files"})
+			return
+		}
+	}
+
 	// Try to delete from S3
 	s3Client, err := getS3Client()
 	if err == nil && exam.S3Key != "" {
@@ -539,6 +596,9 @@ func DeleteExam(c *gin.Context) {
 		return
 	}
 
+	// Referrals whose report was this exam keep their status without the file
+	db.Session(&gorm.Session{NewDB: true}).Exec("UPDATE referrals SET report_exam_id = NULL WHERE report_exam_id = ?", exam.ID)
+
 	c.JSON(http.StatusOK, gin.H{"message": "Exam deleted successfully"})
 }
 
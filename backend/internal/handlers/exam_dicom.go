@@ -0,0 +1,473 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxExamFileSize is the size limit of each uploaded exam file
+const maxExamFileSize = 50 * 1024 * 1024 // 50MB
+
+// dicomUpload is an uploaded DICOM file with its parsed header
+type dicomUpload struct {
+	header *multipart.FileHeader
+	data   []byte
+	info   *helpers.DICOMInfo
+}
+
+// readMultipartFile reads the whole content of an uploaded file
+func readMultipartFile(header *multipart.FileHeader) ([]byte, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// putExamObject stores an exam file in S3, or under ./uploads/exams when S3 is not configured
+// (s3Client nil), and returns its URL
+func putExamObject(s3Client *s3.S3, key string, data []byte, contentType string) (string, error) {
+	if s3Client == nil {
+		filePath := filepath.Join("./uploads/exams", filepath.FromSlash(key))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(filePath, data, 0644); err != nil {
+			return "", err
+		}
+		return "/uploads/exams/" + key, nil
+	}
+
+	bucket, region, _ := getS3Config()
+	_, err := s3Client.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, region, key), nil
+}
+
+// deleteExamObject removes an exam file from S3 or from the local uploads
+func deleteExamObject(s3Client *s3.S3, key, fileURL string) {
+	if s3Client != nil && key != "" {
+		bucket, _, _ := getS3Config()
+		s3Client.DeleteObject(&s3.DeleteObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		})
+		return
+	}
+	if strings.HasPrefix(fileURL, "/uploads/") {
+		os.Remove(filepath.Join(".", fileURL))
+	}
+}
+
+// openExamObject opens an exam file stored in S3 or in the local uploads
+func openExamObject(s3Client *s3.S3, key, fileURL string) (io.ReadCloser, error) {
+	if s3Client != nil {
+		bucket, _, _ := getS3Config()
+		out, err := s3Client.GetObject(&s3.GetObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return out.Body, nil
+	}
+	if !strings.HasPrefix(fileURL, "/uploads/") {
+		return nil, fmt.Errorf("file not available locally")
+	}
+	return os.Open(filepath.Join(".", filepath.Clean(fileURL)))
+}
+
+// examS3Client returns the S3 client, or nil when S3 is not configured and files are kept locally
+func examS3Client() *s3.S3 {
+	s3Client, err := getS3Client()
+	if err != nil {
+		return nil
+	}
+	return s3Client
+}
+
+// createDICOMExam stores the DICOM files of the request ("file" and "files") as the instances of
+// a study. Files of a study already uploaded for the patient are added to its exam, so a series
+// sent in several requests ends up in a single exam
+func createDICOMExam(c *gin.Context, db *gorm.DB, userID uint, patient *models.Patient, name string) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid multipart form"})
+		return
+	}
+	headers := append(form.File["file"], form.File["files"]...)
+
+	// Parse every file before storing anything
+	uploads := make([]dicomUpload, 0, len(headers))
+	for _, header := range headers {
+		if header.Size > maxExamFileSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("File %s exceeds 50MB limit", header.Filename)})
+			return
+		}
+		data, err := readMultipartFile(header)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Failed to read file %s", header.Filename)})
+			return
+		}
+		info, err := helpers.ParseDICOM(data)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid DICOM file %s", header.Filename), "details": err.Error()})
+			return
+		}
+		if len(uploads) > 0 && info.StudyInstanceUID != uploads[0].info.StudyInstanceUID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "All DICOM files of an upload must belong to the same study"})
+			return
+		}
+		uploads = append(uploads, dicomUpload{header: header, data: data, info: info})
+	}
+	study := uploads[0].info
+
+	// The patient in the header must be the patient of the exam, unless the user confirms it
+	var mismatched *helpers.DICOMInfo
+	for _, upload := range uploads {
+		if !helpers.DICOMPatientMatches(upload.info, patient.ID, patient.Name, patient.CPF) {
+			mismatched = upload.info
+			break
+		}
+	}
+	mismatch := mismatched != nil
+	if mismatch && c.PostForm("confirm_patient") != "true" {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":                 "The patient in the DICOM file does not match the selected patient",
+			"requires_confirmation": true,
+			"patient_name":          patient.Name,
+			"dicom_patient_name":    mismatched.PatientDisplayName(),
+			"dicom_patient_id":      mismatched.PatientID,
+		})
+		return
+	}
+
+	// A study belongs to a single patient
+	var otherPatients int64
+	db.Model(&models.Exam{}).Where("study_instance_uid = ? AND patient_id <> ?", study.StudyInstanceUID, patient.ID).Count(&otherPatients)
+	if otherPatients > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "This DICOM study is already attached to another patient"})
+		return
+	}
+
+	var exam models.Exam
+	created := db.Where("patient_id = ? AND study_instance_uid = ? AND is_dicom = ?", patient.ID, study.StudyInstanceUID, true).
+		First(&exam).Error != nil
+
+	// Skip instances already stored in the study or repeated in the upload
+	seen := map[string]bool{}
+	if !created {
+		var stored []string
+		db.Model(&models.ExamInstance{}).Where("exam_id = ?", exam.ID).Pluck("sop_instance_uid", &stored)
+		for _, uid := range stored {
+			seen[uid] = true
+		}
+	}
+	newUploads := make([]dicomUpload, 0, len(uploads))
+	for _, upload := range uploads {
+		if !seen[upload.info.SOPInstanceUID] {
+			seen[upload.info.SOPInstanceUID] = true
+			newUploads = append(newUploads, upload)
+		}
+	}
+	duplicates := len(uploads) - len(newUploads)
+	if len(newUploads) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"exam":            enrichExamWithRelatedData(db, &exam),
+			"instances_added": 0,
+			"duplicates":      duplicates,
+			"message":         "All files were already uploaded to this study",
+		})
+		return
+	}
+
+	// Storage: tenant_X_subdomain/exams/[cpf]/dicom/[study uid]/[instance uid].dcm on S3,
+	// ./uploads/exams/dicom/[tenant schema]/[patient id]/[study uid]/[instance uid].dcm locally.
+	// A study belongs to a single patient of the tenant, so the prefix is the exam's own folder
+	s3Client := examS3Client()
+	keyPrefix := fmt.Sprintf("dicom/%s/%d/%s", c.GetString("schema"), patient.ID, study.StudyInstanceUID)
+	if s3Client != nil {
+		_, _, baseFolder := getS3Config()
+		keyPrefix = fmt.Sprintf("%s/%s/%s/dicom/%s", getTenantS3Prefix(c), baseFolder, sanitizeCPF(patient.CPF), study.StudyInstanceUID)
+	}
+
+	var storedKeys, storedURLs []string
+	cleanup := func() {
+		for i := range storedKeys {
+			deleteExamObject(s3Client, storedKeys[i], storedURLs[i])
+		}
+	}
+
+	instances := make([]models.ExamInstance, 0, len(newUploads))
+	var totalSize int64
+	for _, upload := range newUploads {
+		key := fmt.Sprintf("%s/%s.dcm", keyPrefix, upload.info.SOPInstanceUID)
+		fileURL, err := putExamObject(s3Client, key, upload.data, "application/dicom")
+		if err != nil {
+			cleanup()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to store file: %v", err)})
+			return
+		}
+		storedKeys = append(storedKeys, key)
+		storedURLs = append(storedURLs, fileURL)
+
+		instances = append(instances, models.ExamInstance{
+			SeriesInstanceUID: upload.info.SeriesInstanceUID,
+			SOPInstanceUID:    upload.info.SOPInstanceUID,
+			SeriesNumber:      upload.info.SeriesNumber,
+			InstanceNumber:    upload.info.InstanceNumber,
+			Modality:          upload.info.Modality,
+			NumberOfFrames:    upload.info.NumberOfFrames,
+			FileURL:           fileURL,
+			S3Key:             key,
+			FileName:          upload.header.Filename,
+			FileSize:          int64(len(upload.data)),
+		})
+		totalSize += int64(len(upload.data))
+	}
+
+	// PNG preview of the first single-frame image that can be rendered
+	previewURL, previewKey := exam.PreviewURL, exam.PreviewS3Key
+	if previewKey == "" {
+		for _, upload := range newUploads {
+			preview, err := helpers.EncodeDICOMPreviewPNG(upload.info)
+			if err != nil {
+				continue
+			}
+			key := keyPrefix + "/preview.png"
+			if url, err := putExamObject(s3Client, key, preview, "image/png"); err == nil {
+				previewURL, previewKey = url, key
+				storedKeys = append(storedKeys, key)
+				storedURLs = append(storedURLs, url)
+			} else {
+				log.Printf("WARNING: Failed to store DICOM preview: %v", err)
+			}
+			break
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if created {
+			examDate := study.StudyDate
+			if examDateStr := c.PostForm("exam_date"); examDateStr != "" {
+				if parsedDate, err := time.Parse("2006-01-02", examDateStr); err == nil {
+					examDate = &parsedDate
+				}
+			}
+			exam = models.Exam{
+				PatientID:        patient.ID,
+				Name:             name,
+				Description:      c.PostForm("description"),
+				ExamType:         c.PostForm("exam_type"),
+				ExamDate:         examDate,
+				FileURL:          instances[0].FileURL,
+				S3Key:            instances[0].S3Key,
+				FileName:         instances[0].FileName,
+				FileType:         "application/dicom",
+				UploadedByID:     userID,
+				Notes:            c.PostForm("notes"),
+				IsDICOM:          true,
+				StudyInstanceUID: study.StudyInstanceUID,
+				Modality:         study.Modality,
+				BodyPart:         study.BodyPart,
+				DicomPatientName: study.PatientDisplayName(),
+				DicomPatientID:   study.PatientID,
+			}
+			if err := tx.Create(&exam).Error; err != nil {
+				return err
+			}
+		}
+
+		for i := range instances {
+			instances[i].ExamID = exam.ID
+		}
+		if err := tx.Create(&instances).Error; err != nil {
+			return err
+		}
+
+		return tx.Exec(`
+			UPDATE exams
+			SET instance_count = instance_count + ?, file_size = file_size + ?,
+				preview_url = ?, preview_s3_key = ?, patient_mismatch = patient_mismatch OR ?, updated_at = NOW()
+			WHERE id = ?
+		`, len(instances), totalSize, previewURL, previewKey, mismatch, exam.ID).Error
+	})
+	if err != nil {
+		cleanup()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to create exam record",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	db.First(&exam, exam.ID)
+
+	// Thumbnail of the study, from its PNG rendering
+	if exam.PreviewS3Key != "" && exam.ThumbnailS3Key == "" {
+		queueExamPreviews(c, db, exam.ID)
+		exam.PreviewStatus = models.ExamPreviewPending
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, gin.H{
+		"exam":            enrichExamWithRelatedData(db, &exam),
+		"instances_added": len(instances),
+		"duplicates":      duplicates,
+	})
+}
+
+// GetExamInstances lists the DICOM files of an exam study
+func GetExamInstances(c *gin.Context) {
+	id := c.Param("id")
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var exam models.Exam
+	if err := db.First(&exam, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exam not found"})
+		return
+	}
+
+	var instances []models.ExamInstance
+	if err := db.Where("exam_id = ?", exam.ID).Order("series_number ASC, instance_number ASC, id ASC").Find(&instances).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch exam instances"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"exam_id":            exam.ID,
+		"study_instance_uid": exam.StudyInstanceUID,
+		"instances":          instances,
+		"total":              len(instances),
+	})
+}
+
+// GetExamPreviewURL returns the URLs of the thumbnail and web-sized preview of an exam
+func GetExamPreviewURL(c *gin.Context) {
+	id := c.Param("id")
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var exam models.Exam
+	if err := db.First(&exam, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exam not found"})
+		return
+	}
+	if exam.PreviewS3Key == "" && exam.ThumbnailS3Key == "" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":          "Preview not available for this exam",
+			"preview_status": exam.PreviewStatus,
+		})
+		return
+	}
+
+	s3Client := examS3Client()
+	c.JSON(http.StatusOK, gin.H{
+		"preview_url":    examRenditionURL(s3Client, exam.PreviewS3Key, exam.PreviewURL),
+		"thumbnail_url":  examRenditionURL(s3Client, exam.ThumbnailS3Key, exam.ThumbnailURL),
+		"preview_status": exam.PreviewStatus,
+		"expires_in":     3600,
+	})
+}
+
+// DownloadExamDICOM streams the original DICOM files of a study, so it can be opened in other
+// radiology software: the .dcm file for a single instance, or a ZIP with one folder per series
+func DownloadExamDICOM(c *gin.Context) {
+	id := c.Param("id")
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var exam models.Exam
+	if err := db.First(&exam, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exam not found"})
+		return
+	}
+	if !exam.IsDICOM {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Exam is not a DICOM study"})
+		return
+	}
+
+	var instances []models.ExamInstance
+	db.Where("exam_id = ?", exam.ID).Order("series_number ASC, instance_number ASC, id ASC").Find(&instances)
+	if len(instances) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exam has no DICOM files"})
+		return
+	}
+
+	s3Client := examS3Client()
+
+	if len(instances) == 1 {
+		body, err := openExamObject(s3Client, instances[0].S3Key, instances[0].FileURL)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read DICOM file"})
+			return
+		}
+		defer body.Close()
+
+		c.Header("Content-Type", "application/dicom")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.dcm", instances[0].SOPInstanceUID))
+		c.Status(http.StatusOK)
+		io.Copy(c.Writer, body)
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=exam_%d_dicom.zip", exam.ID))
+	c.Status(http.StatusOK)
+
+	zw := zip.NewWriter(c.Writer)
+	defer zw.Close()
+	for _, instance := range instances {
+		body, err := openExamObject(s3Client, instance.S3Key, instance.FileURL)
+		if err != nil {
+			log.Printf("ERROR reading DICOM instance %d of exam %d: %v", instance.ID, exam.ID, err)
+			return
+		}
+		w, err := zw.Create(fmt.Sprintf("series_%03d/%s.dcm", instance.SeriesNumber, instance.SOPInstanceUID))
+		if err == nil {
+			_, err = io.Copy(w, body)
+		}
+		body.Close()
+		if err != nil {
+			log.Printf("ERROR streaming DICOM instance %d of exam %d: %v", instance.ID, exam.ID, err)
+			return
+		}
+	}
+}
+
+// deleteExamDICOMFiles removes the instances of a DICOM exam from storage
+func deleteExamDICOMFiles(db *gorm.DB, s3Client *s3.S3, exam *models.Exam) error {
+	var instances []models.ExamInstance
+	db.Where("exam_id = ?", exam.ID).Find(&instances)
+	for _, instance := range instances {
+		deleteExamObject(s3Client, instance.S3Key, instance.FileURL)
+	}
+	return db.Where("exam_id = ?", exam.ID).Delete(&models.ExamInstance{}).Error
+}
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"bytes"
+	"drcrwell/backend/internal/database"
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errNoExamPreview is returned for file types without a preview (ZIP, office documents)
+var errNoExamPreview = errors.New("file type without preview")
+
+// examPreviewSlots limits the previews generated at the same time: decoding full-size photos
+// takes a lot of memory
+var examPreviewSlots = make(chan struct{}, 2)
+
+// memoryFile serves a file already read in memory as a multipart.File
+type memoryFile struct {
+	*bytes.Reader
+}
+
+func (memoryFile) Close() error { return nil }
+
+// stripUploadGPS removes the GPS location of a JPEG or HEIC photo before it is stored (privacy).
+// Returns nil when the file has no location, so the original upload is used
+func stripUploadGPS(file multipart.File) multipart.File {
+	data, err := io.ReadAll(file)
+	if seeker, ok := file.(io.Seeker); ok {
+		seeker.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		return nil
+	}
+	stripped, changed := stripImageGPS(data)
+	if !changed {
+		return nil
+	}
+	return memoryFile{bytes.NewReader(stripped)}
+}
+
+// stripImageGPS removes the GPS location of a JPEG or HEIC photo. Returns false when there was no
+// location to remove, or when exiftool is not installed to rewrite a HEIC photo
+func stripImageGPS(data []byte) ([]byte, bool) {
+	if helpers.IsHEIC(data) {
+		stripped, err := helpers.StripHEICGPS(data)
+		if err != nil {
+			log.Printf("WARNING: Failed to strip the GPS location of a HEIC photo: %v", err)
+			return nil, false
+		}
+		return stripped, true
+	}
+	return helpers.StripJPEGGPS(data)
+}
+
+// withTenantSchema runs fn in a transaction pinned to the tenant schema. Background jobs cannot use
+// the request session: SET LOCAL keeps the search_path on the transaction's connection only
+func withTenantSchema(schemaName string, fn func(tx *gorm.DB) error) error {
+	if schemaName == "" {
+		return fmt.Errorf("tenant schema not set")
+	}
+	return database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(fmt.Sprintf("SET LOCAL search_path TO %s", schemaName)).Error; err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+// queueExamPreviews marks the exam as pending and generates its thumbnail and preview in the background
+func queueExamPreviews(c *gin.Context, db *gorm.DB, examID uint) {
+	schemaName := c.GetString("schema")
+	db.Exec("UPDATE exams SET preview_status = ?, preview_error = '' WHERE id = ?", models.ExamPreviewPending, examID)
+
+	go func() {
+		examPreviewSlots <- struct{}{}
+		defer func() { <-examPreviewSlots }()
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("ERROR generating previews of exam %d: %v", examID, r)
+			}
+		}()
+		generateExamPreviews(schemaName, examID)
+	}()
+}
+
+// generateExamPreviews builds the renditions of an exam, stores them next to the original and
+// records the result on the exam
+func generateExamPreviews(schemaName string, examID uint) {
+	var exam models.Exam
+	if err := withTenantSchema(schemaName, func(tx *gorm.DB) error {
+		return tx.First(&exam, examID).Error
+	}); err != nil {
+		log.Printf("ERROR loading exam %d for previews: %v", examID, err)
+		return
+	}
+
+	s3Client := examS3Client()
+	status, message := models.ExamPreviewReady, ""
+	thumbnailURL, thumbnailKey := exam.ThumbnailURL, exam.ThumbnailS3Key
+	previewURL, previewKey := exam.PreviewURL, exam.PreviewS3Key
+
+	renditions, err := buildExamRenditions(s3Client, &exam)
+	if err == nil {
+		thumbnailKey = examRenditionKey(s3Client, schemaName, &exam, "thumbnail")
+		thumbnailURL, err = putExamObject(s3Client, thumbnailKey, renditions.Thumbnail, "image/jpeg")
+	}
+	if err == nil && renditions.Preview != nil {
+		previewKey = examRenditionKey(s3Client, schemaName, &exam, "preview")
+		previewURL, err = putExamObject(s3Client, previewKey, renditions.Preview, "image/jpeg")
+	}
+
+	switch {
+	case errors.Is(err, errNoExamPreview), errors.Is(err, helpers.ErrConverterUnavailable), errors.Is(err, helpers.ErrDICOMNoPreview):
+		status = models.ExamPreviewUnsupported
+	case err != nil:
+		status, message = models.ExamPreviewFailed, err.Error()
+		if len(message) > 255 {
+			message = message[:255]
+		}
+		log.Printf("ERROR generating previews of exam %d: %v", examID, err)
+	}
+
+	if err := withTenantSchema(schemaName, func(tx *gorm.DB) error {
+		return tx.Exec(`
+			UPDATE exams
+			SET thumbnail_url = ?, thumbnail_s3_key = ?, preview_url = ?, preview_s3_key = ?,
+				preview_status = ?, preview_error = ?, updated_at = NOW()
+			WHERE id = ?
+		`, thumbnailURL, thumbnailKey, previewURL, previewKey, status, message, examID).Error
+	}); err != nil {
+		log.Printf("ERROR saving previews of exam %d: %v", examID, err)
+	}
+}
+
+// buildExamRenditions generates the thumbnail and preview of an exam from its stored file.
+// DICOM studies keep their PNG rendering as preview and only get a thumbnail
+func buildExamRenditions(s3Client *s3.S3, exam *models.Exam) (*helpers.ImageRenditions, error) {
+	if exam.IsDICOM {
+		if exam.PreviewS3Key == "" {
+			return nil, helpers.ErrDICOMNoPreview
+		}
+		data, err := readExamObject(s3Client, exam.PreviewS3Key, exam.PreviewURL)
+		if err != nil {
+			return nil, err
+		}
+		renditions, err := helpers.BuildImageRenditions(data)
+		if err != nil {
+			return nil, err
+		}
+		renditions.Preview = nil
+		return renditions, nil
+	}
+
+	data, err := readExamObject(s3Client, exam.S3Key, exam.FileURL)
+	if err != nil {
+		return nil, err
+	}
+	return buildFileRenditions(data)
+}
+
+// buildFileRenditions generates the thumbnail and preview of a photo (JPEG, PNG, GIF, WebP, HEIC)
+// or of the first page of a PDF
+func buildFileRenditions(data []byte) (*helpers.ImageRenditions, error) {
+	switch {
+	case helpers.IsHEIC(data):
+		converted, err := helpers.ConvertHEICToJPEG(data)
+		if err != nil {
+			return nil, err
+		}
+		// heif-convert already applies the rotation of the HEIC file
+		img, err := helpers.DecodeExamImage(converted)
+		if err != nil {
+			return nil, err
+		}
+		return helpers.BuildRenditions(img, 1)
+	case helpers.DetectFileType(data) == helpers.FileTypeImage:
+		return helpers.BuildImageRenditions(data)
+	case helpers.DetectFileType(data) == helpers.FileTypePDF:
+		page, err := helpers.RenderPDFFirstPage(data)
+		if err != nil {
+			return nil, err
+		}
+		return helpers.BuildImageRenditions(page)
+	}
+	return nil, errNoExamPreview
+}
+
+// readExamObject reads a whole exam file from storage
+func readExamObject(s3Client *s3.S3, key, fileURL string) ([]byte, error) {
+	body, err := openExamObject(s3Client, key, fileURL)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// examRenditionKey returns the storage key of a rendition. On S3 it sits next to the original file:
+// [original].thumbnail.jpg, or thumbnail.jpg in the study folder of DICOM exams. Local files
+// are not stored per tenant, so renditions go to renditions/[tenant schema]/[exam id]/thumbnail.jpg
+func examRenditionKey(s3Client *s3.S3, schemaName string, exam *models.Exam, rendition string) string {
+	if s3Client == nil {
+		return fmt.Sprintf("renditions/%s/%d/%s.jpg", schemaName, exam.ID, rendition)
+	}
+	if exam.IsDICOM {
+		return path.Join(path.Dir(exam.S3Key), rendition+".jpg")
+	}
+	return fmt.Sprintf("%s.%s.jpg", exam.S3Key, rendition)
+}
+
+// examRenditionURL returns a URL for a rendition valid for an hour: presigned on S3, the local
+// path otherwise. Empty when the rendition does not exist
+func examRenditionURL(s3Client *s3.S3, key, fileURL string) string {
+	if key == "" {
+		return ""
+	}
+	if s3Client == nil {
+		return fileURL
+	}
+	bucket, _, _ := getS3Config()
+	req, _ := s3Client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	url, err := req.Presign(1 * time.Hour)
+	if err != nil {
+		return ""
+	}
+	return url
+}
+
+// deleteExamRenditions removes the thumbnail and preview of an exam from storage
+func deleteExamRenditions(s3Client *s3.S3, exam *models.Exam) {
+	if exam.ThumbnailS3Key != "" {
+		deleteExamObject(s3Client, exam.ThumbnailS3Key, exam.ThumbnailURL)
+	}
+	if exam.PreviewS3Key != "" {
+		deleteExamObject(s3Client, exam.PreviewS3Key, exam.PreviewURL)
+	}
+}
+
+// RegenerateExamPreviews queues the thumbnail and preview generation of an exam again
+// (exams uploaded before the previews existed, or after installing a converter)
+func RegenerateExamPreviews(c *gin.Context) {
+	id := c.Param("id")
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var exam models.Exam
+	if err := db.First(&exam, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exam not found"})
+		return
+	}
+
+	queueExamPreviews(c, db, exam.ID)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":        "Preview generation queued",
+		"preview_status": models.ExamPreviewPending,
+	})
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// validateExternalSpecialist normalizes a directory entry and returns an error message when it is invalid
+func validateExternalSpecialist(specialist *models.ExternalSpecialist) string {
+	specialist.Name = strings.TrimSpace(specialist.Name)
+	specialist.Specialty = strings.TrimSpace(specialist.Specialty)
+	specialist.CouncilType = strings.ToUpper(strings.TrimSpace(specialist.CouncilType))
+	specialist.CouncilNumber = strings.TrimSpace(specialist.CouncilNumber)
+	specialist.Email = strings.TrimSpace(specialist.Email)
+	specialist.State = strings.ToUpper(strings.TrimSpace(specialist.State))
+
+	if specialist.Name == "" {
+		return "Nome do especialista é obrigatório"
+	}
+	if specialist.Specialty == "" {
+		return "Especialidade é obrigatória"
+	}
+	if len(specialist.State) > 2 {
+		return "UF deve ter 2 letras"
+	}
+	return ""
+}
+
+// specialistCouncil formats the professional council registration, e.g. "CRO 12345-SP"
+func specialistCouncil(specialist *models.ExternalSpecialist) string {
+	return strings.TrimSpace(specialist.CouncilType + " " + specialist.CouncilNumber)
+}
+
+// CreateExternalSpecialist adds an outside professional to the referral directory
+// POST /external-specialists
+func CreateExternalSpecialist(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var specialist models.ExternalSpecialist
+	if err := c.ShouldBindJSON(&specialist); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	specialist.ID = 0
+	specialist.Active = true
+	if msg := validateExternalSpecialist(&specialist); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := db.Session(&gorm.Session{NewDB: true}).Create(&specialist).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao cadastrar especialista"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"specialist": specialist})
+}
+
+// GetExternalSpecialists searches the referral directory
+// GET /external-specialists?search=&specialty=&active=true
+func GetExternalSpecialists(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	query := db.Session(&gorm.Session{NewDB: true}).Model(&models.ExternalSpecialist{})
+	if search := strings.TrimSpace(c.Query("search")); search != "" {
+		query = query.Where("name ILIKE ? OR specialty ILIKE ? OR clinic_name ILIKE ?", "%"+search+"%", "%"+search+"%", "%"+search+"%")
+	}
+	if specialty := strings.TrimSpace(c.Query("specialty")); specialty != "" {
+		query = query.Where("specialty ILIKE ?", specialty)
+	}
+	if active := c.Query("active"); active == "true" {
+		query = query.Where("active = ?", true)
+	} else if active == "false" {
+		query = query.Where("active = ?", false)
+	}
+
+	var specialists []models.ExternalSpecialist
+	if err := query.Order("specialty ASC, name ASC").Find(&specialists).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar especialistas"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"specialists": specialists, "total": len(specialists)})
+}
+
+// GetExternalSpecialtyNames lists the specialties already in the directory (autocomplete)
+// GET /external-specialists/specialties
+func GetExternalSpecialtyNames(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	specialties := []string{}
+	db.Session(&gorm.Session{NewDB: true}).Model(&models.ExternalSpecialist{}).
+		Distinct("specialty").Where("specialty <> ''").Order("specialty ASC").Pluck("specialty", &specialties)
+
+	c.JSON(http.StatusOK, gin.H{"specialties": specialties})
+}
+
+// GetExternalSpecialist returns a directory entry
+// GET /external-specialists/:id
+func GetExternalSpecialist(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var specialist models.ExternalSpecialist
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&specialist, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Especialista não encontrado"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"specialist": specialist})
+}
+
+// UpdateExternalSpecialist updates a directory entry
+// Referrals keep a copy of the specialist data, so letters already sent are not affected
+// PUT /external-specialists/:id
+func UpdateExternalSpecialist(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var existing models.ExternalSpecialist
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&existing, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Especialista não encontrado"})
+		return
+	}
+
+	// Fields missing from the payload keep their current values
+	specialist := existing
+	if err := c.ShouldBindJSON(&specialist); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := validateExternalSpecialist(&specialist); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := db.Session(&gorm.Session{NewDB: true}).Exec(`
+		UPDATE external_specialists
+		SET name = ?, specialty = ?, council_type = ?, council_number = ?, clinic_name = ?, phone = ?, email = ?,
+		    address = ?, city = ?, state = ?, notes = ?, active = ?, updated_at = ?
+		WHERE id = ?
+	`, specialist.Name, specialist.Specialty, specialist.CouncilType, specialist.CouncilNumber, specialist.ClinicName, specialist.Phone, specialist.Email,
+		specialist.Address, specialist.City, specialist.State, specialist.Notes, specialist.Active, time.Now(), existing.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar especialista"})
+		return
+	}
+
+	db.Session(&gorm.Session{NewDB: true}).First(&existing, existing.ID)
+	c.JSON(http.StatusOK, gin.H{"specialist": existing})
+}
+
+// DeleteExternalSpecialist removes an entry from the directory
+// DELETE /external-specialists/:id
+func DeleteExternalSpecialist(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var specialist models.ExternalSpecialist
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&specialist, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Especialista não encontrado"})
+		return
+	}
+
+	if err := db.Session(&gorm.Session{NewDB: true}).Delete(&models.ExternalSpecialist{}, specialist.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao remover especialista"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Especialista removido com sucesso"})
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"drcrwell/backend/internal/helpers"
 	"drcrwell/backend/internal/models"
 	"drcrwell/backend/internal/middleware"
 	"log"
@@ -24,13 +25,31 @@ func CreateBudget(c *gin.Context) {
 	if !ok {
 		return
 	}
-	if err := db.Create(&budget).Error; err != nil {
+
+	// Structured plan items generate the items JSON and the total
+	planItems := budget.PlanItems
+	budget.PlanItems = nil
+	if len(planItems) > 0 {
+		if err := helpers.NormalizeTreatmentPlanItems(planItems); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		budget.Items, budget.TotalValue = budgetItemsFromPlan(planItems)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&budget).Error; err != nil {
+			return err
+		}
+		return replaceBudgetPlanItems(tx, budget.ID, budget.PatientID, planItems)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create budget"})
 		return
 	}
 
 	// Load relationships
-	db.Preload("Patient").Preload("Dentist").Preload("Payments").First(&budget, budget.ID)
+	db.Preload("Patient").Preload("Dentist").Preload("Payments").Preload("PlanItems", orderByPosition).First(&budget, budget.ID)
 
 	c.JSON(http.StatusCreated, gin.H{"budget": budget})
 }
@@ -81,7 +100,7 @@ func GetBudget(c *gin.Context) {
 	}
 
 	var budget models.Budget
-	if err := db.Preload("Patient").Preload("Dentist").Preload("Payments").
+	if err := db.Preload("Patient").Preload("Dentist").Preload("Payments").Preload("PlanItems", orderByPosition).
 		First(&budget, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Budget not found"})
 		return
@@ -114,12 +133,28 @@ func UpdateBudget(c *gin.Context) {
 		ValidUntil        *time.Time `json:"valid_until"`
 		Notes             string     `json:"notes"`
 		TotalInstallments int        `json:"total_installments"` // For treatment creation
+		PlanItems         *[]models.TreatmentPlanItem `json:"plan_items"` // Replaces the plan items when sent
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	// Structured plan items generate the items JSON and the total; once approved they belong to the treatment
+	if input.PlanItems != nil {
+		if currentBudget.Status == "approved" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Os procedimentos de um orçamento aprovado não podem ser alterados"})
+			return
+		}
+		if err := helpers.NormalizeTreatmentPlanItems(*input.PlanItems); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(*input.PlanItems) > 0 {
+			input.Items, input.TotalValue = budgetItemsFromPlan(*input.PlanItems)
+		}
+	}
+
 	// Start transaction to ensure atomicity of budget update and treatment creation
 	tx := db.Begin()
 	if tx.Error != nil {
@@ -142,9 +177,23 @@ func UpdateBudget(c *gin.Context) {
 		return
 	}
 
+	if input.PlanItems != nil {
+		if err := replaceBudgetPlanItems(tx, currentBudget.ID, input.PatientID, *input.PlanItems); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update budget"})
+			return
+		}
+	} else if input.PatientID != currentBudget.PatientID {
+		if err := tx.Exec("UPDATE treatment_plan_items SET patient_id = ? WHERE budget_id = ?", input.PatientID, currentBudget.ID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update budget"})
+			return
+		}
+	}
+
 	// Load the updated budget with relationships
 	var budget models.Budget
-	tx.Preload("Patient").Preload("Dentist").Preload("Payments").First(&budget, id)
+	tx.Preload("Patient").Preload("Dentist").Preload("Payments").Preload("PlanItems", orderByPosition).First(&budget, id)
 
 	// If status changed to approved, auto-create treatment
 	var treatment *models.Treatment
@@ -161,6 +210,9 @@ func UpdateBudget(c *gin.Context) {
 			}
 
 			newTreatment, createErr := CreateTreatmentFromBudgetRaw(tx, &budget, totalInstallments)
+			if createErr == nil {
+				createErr = linkPlanItemsToTreatment(tx, newTreatment)
+			}
 			if createErr != nil {
 				tx.Rollback()
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create treatment from budget"})
@@ -192,7 +244,18 @@ func DeleteBudget(c *gin.Context) {
 		return
 	}
 
-	if err := db.Delete(&models.Budget{}, id).Error; err != nil {
+	// Procedures not performed yet leave the odontogram with the budget
+	err := db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		var budget models.Budget
+		if err := tx.First(&budget, id).Error; err != nil {
+			return err
+		}
+		if err := cancelBudgetPlanItems(tx, budget.ID); err != nil {
+			return err
+		}
+		return tx.Delete(&models.Budget{}, budget.ID).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete budget"})
 		return
 	}
@@ -212,7 +275,15 @@ func CreatePayment(c *gin.Context) {
 	if !ok {
 		return
 	}
-	if err := db.Create(&payment).Error; err != nil {
+	// The payment and the commissions of the professionals are recorded together
+	err := db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&payment).Error; err != nil {
+			return err
+		}
+		return generatePaymentCommissions(tx, payment)
+	})
+	if err != nil {
+		log.Printf("ERROR creating payment: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment"})
 		return
 	}
@@ -300,22 +371,39 @@ func UpdatePayment(c *gin.Context) {
 		return
 	}
 
-	// Update using Exec to avoid the duplicate table error
-	result := db.Exec(`
-		UPDATE payments
-		SET budget_id = ?, patient_id = ?, type = ?, category = ?, description = ?,
-		    amount = ?, payment_method = ?, is_installment = ?, installment_number = ?,
-		    total_installments = ?, status = ?, due_date = ?, paid_date = ?,
-		    is_insurance = ?, insurance_name = ?, is_recurring = ?, recurrence_days = ?,
-		    notes = ?, updated_at = NOW()
-		WHERE id = ? AND deleted_at IS NULL
-	`, input.BudgetID, input.PatientID, input.Type, input.Category, input.Description,
-		input.Amount, input.PaymentMethod, input.IsInstallment, input.InstallmentNumber,
-		input.TotalInstallments, input.Status, input.DueDate, input.PaidDate,
-		input.IsInsurance, input.InsuranceName, input.IsRecurring, input.RecurrenceDays,
-		input.Notes, id)
+	// Commissions follow the payment status, in the same transaction as the payment
+	err := db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		// Update using Exec to avoid the duplicate table error
+		if err := tx.Exec(`
+			UPDATE payments
+			SET budget_id = ?, patient_id = ?, type = ?, category = ?, description = ?,
+			    amount = ?, payment_method = ?, is_installment = ?, installment_number = ?,
+			    total_installments = ?, status = ?, due_date = ?, paid_date = ?,
+			    is_insurance = ?, insurance_name = ?, is_recurring = ?, recurrence_days = ?,
+			    notes = ?, updated_at = NOW()
+			WHERE id = ? AND deleted_at IS NULL
+		`, input.BudgetID, input.PatientID, input.Type, input.Category, input.Description,
+			input.Amount, input.PaymentMethod, input.IsInstallment, input.InstallmentNumber,
+			input.TotalInstallments, input.Status, input.DueDate, input.PaidDate,
+			input.IsInsurance, input.InsuranceName, input.IsRecurring, input.RecurrenceDays,
+			input.Notes, id).Error; err != nil {
+			return err
+		}
 
-	if result.Error != nil {
+		var updated models.Payment
+		if err := tx.First(&updated, id).Error; err != nil {
+			return err
+		}
+		if currentPayment.Status != "paid" && updated.Status == "paid" {
+			return generatePaymentCommissions(tx, updated)
+		}
+		if currentPayment.Status == "paid" && updated.Status != "paid" {
+			return reverseCommissions(tx, models.CommissionSourcePayment, updated.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("ERROR updating payment %s: %v", id, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment"})
 		return
 	}
@@ -374,7 +462,21 @@ func DeletePayment(c *gin.Context) {
 		return
 	}
 
-	if err := db.Delete(&models.Payment{}, id).Error; err != nil {
+	paymentID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment ID"})
+		return
+	}
+
+	// The commissions of a deleted payment are given back with it
+	err = db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.Payment{}, uint(paymentID)).Error; err != nil {
+			return err
+		}
+		return reverseCommissions(tx, models.CommissionSourcePayment, uint(paymentID))
+	})
+	if err != nil {
+		log.Printf("ERROR deleting payment %d: %v", paymentID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete payment"})
 		return
 	}
@@ -502,9 +604,14 @@ func CancelBudget(c *gin.Context) {
 		return
 	}
 
-	// Update status to cancelled using raw SQL
-	result := db.Exec("UPDATE budgets SET status = 'cancelled', updated_at = NOW() WHERE id = ? AND deleted_at IS NULL", id)
-	if result.Error != nil {
+	// Update status to cancelled using raw SQL; procedures not performed yet are cancelled too
+	err := db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("UPDATE budgets SET status = 'cancelled', updated_at = NOW() WHERE id = ? AND deleted_at IS NULL", id).Error; err != nil {
+			return err
+		}
+		return cancelBudgetPlanItems(tx, budget.ID)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel budget"})
 		return
 	}
@@ -552,7 +659,15 @@ func RefundPayment(c *gin.Context) {
 	payment.RefundedDate = &now
 	payment.RefundReason = input.Reason
 
-	if err := db.Save(&payment).Error; err != nil {
+	// The refund gives back the commissions in the same transaction
+	err := db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&payment).Error; err != nil {
+			return err
+		}
+		return reverseCommissions(tx, models.CommissionSourcePayment, payment.ID)
+	})
+	if err != nil {
+		log.Printf("ERROR refunding payment %d: %v", payment.ID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refund payment"})
 		return
 	}
@@ -171,11 +171,101 @@ func setupTestDB() *gorm.DB {
 			status VARCHAR(20) DEFAULT 'scheduled',
 			confirmed BOOLEAN DEFAULT FALSE,
 			confirmed_at TIMESTAMP,
+			staff_confirmation_required BOOLEAN DEFAULT FALSE,
+			deposit_required BOOLEAN DEFAULT FALSE,
+			deposit_amount DECIMAL(10,2) DEFAULT 0,
+			deposit_paid_at TIMESTAMP,
 			reminder_sent BOOLEAN DEFAULT FALSE,
 			notes TEXT,
 			room VARCHAR(50),
+			room_id INTEGER,
 			is_recurring BOOLEAN DEFAULT FALSE,
-			recurrence_rule TEXT
+			recurrence_rule TEXT,
+			series_id VARCHAR(36),
+			series_index INTEGER DEFAULT 0
+		)`,
+		`CREATE TABLE IF NOT EXISTS professional_schedules (
+			id SERIAL PRIMARY KEY,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			deleted_at TIMESTAMP,
+			dentist_id INTEGER NOT NULL,
+			weekday INTEGER NOT NULL,
+			start_time VARCHAR(5) NOT NULL,
+			end_time VARCHAR(5) NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS professional_schedule_overrides (
+			id SERIAL PRIMARY KEY,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			deleted_at TIMESTAMP,
+			dentist_id INTEGER NOT NULL,
+			date DATE NOT NULL,
+			start_time VARCHAR(5),
+			end_time VARCHAR(5),
+			closed BOOLEAN DEFAULT FALSE,
+			reason TEXT
+		)`,
+		`CREATE TABLE IF NOT EXISTS professional_absences (
+			id SERIAL PRIMARY KEY,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			deleted_at TIMESTAMP,
+			dentist_id INTEGER NOT NULL,
+			start_at TIMESTAMP NOT NULL,
+			end_at TIMESTAMP NOT NULL,
+			type VARCHAR(20) DEFAULT 'absence',
+			reason TEXT,
+			created_by INTEGER
+		)`,
+		`CREATE TABLE IF NOT EXISTS clinic_closures (
+			id SERIAL PRIMARY KEY,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			deleted_at TIMESTAMP,
+			date DATE NOT NULL,
+			start_time VARCHAR(5),
+			end_time VARCHAR(5),
+			name TEXT NOT NULL,
+			type VARCHAR(20) DEFAULT 'custom',
+			created_by INTEGER
+		)`,
+		`CREATE TABLE IF NOT EXISTS appointment_status_history (
+			id SERIAL PRIMARY KEY,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			appointment_id INTEGER NOT NULL,
+			from_status VARCHAR(20),
+			to_status VARCHAR(20) NOT NULL,
+			changed_at TIMESTAMP NOT NULL,
+			changed_by INTEGER,
+			source VARCHAR(20) DEFAULT 'staff',
+			reason_code VARCHAR(30),
+			reason TEXT
+		)`,
+		`CREATE TABLE IF NOT EXISTS rooms (
+			id SERIAL PRIMARY KEY,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			deleted_at TIMESTAMP,
+			name VARCHAR(100) NOT NULL,
+			type VARCHAR(20) DEFAULT 'chair',
+			description TEXT,
+			equipment JSONB,
+			active BOOLEAN DEFAULT TRUE
+		)`,
+		`CREATE TABLE IF NOT EXISTS treatment_protocols (
+			id SERIAL PRIMARY KEY,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			deleted_at TIMESTAMP,
+			name TEXT NOT NULL,
+			description TEXT,
+			procedures JSONB,
+			required_equipment JSONB,
+			duration INTEGER,
+			cost DECIMAL(10,2),
+			active BOOLEAN DEFAULT TRUE,
+			created_by INTEGER
 		)`,
 		`CREATE TABLE IF NOT EXISTS medical_records (
 			id SERIAL PRIMARY KEY,
@@ -198,6 +288,21 @@ func setupTestDB() *gorm.DB {
 			signed_by INTEGER,
 			signature_data TEXT
 		)`,
+		`CREATE TABLE IF NOT EXISTS tooth_events (
+			id SERIAL PRIMARY KEY,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			deleted_at TIMESTAMP,
+			patient_id INTEGER NOT NULL,
+			medical_record_id INTEGER,
+			dentist_id INTEGER,
+			tooth INTEGER NOT NULL,
+			surfaces VARCHAR(5),
+			condition VARCHAR(30) NOT NULL,
+			state VARCHAR(20) DEFAULT 'existing',
+			notes TEXT,
+			recorded_at TIMESTAMP NOT NULL
+		)`,
 		`CREATE TABLE IF NOT EXISTS prescriptions (
 			id SERIAL PRIMARY KEY,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreateInsuranceCompany registers an insurer
+// POST /insurances
+func CreateInsuranceCompany(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var company models.InsuranceCompany
+	if err := c.ShouldBindJSON(&company); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	company.ID = 0
+	company.Active = true
+	company.LastLotNumber = 0
+	company.LastGuideNumber = 0
+	if company.PaymentTermDays == 0 {
+		company.PaymentTermDays = 30
+	}
+	if err := helpers.NormalizeInsuranceCompany(&company); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := db.Session(&gorm.Session{NewDB: true}).Create(&company).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao cadastrar convênio"})
+		return
+	}
+
+	helpers.AuditAction(c, "create", "insurance_companies", company.ID, true, map[string]interface{}{
+		"name":         company.Name,
+		"ans_registry": company.ANSRegistry,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{"insurance": company})
+}
+
+// GetInsuranceCompanies lists the insurers
+// GET /insurances?active=&search=
+func GetInsuranceCompanies(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	query := db.Session(&gorm.Session{NewDB: true}).Model(&models.InsuranceCompany{})
+	if active := c.Query("active"); active == "true" {
+		query = query.Where("active = ?", true)
+	} else if active == "false" {
+		query = query.Where("active = ?", false)
+	}
+	if search := strings.TrimSpace(c.Query("search")); search != "" {
+		query = query.Where("name ILIKE ? OR ans_registry = ?", "%"+search+"%", search)
+	}
+
+	var companies []models.InsuranceCompany
+	if err := query.Order("name ASC").Find(&companies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar convênios"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"insurances": companies, "total": len(companies)})
+}
+
+// GetInsuranceCompany returns an insurer
+// GET /insurances/:id
+func GetInsuranceCompany(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var company models.InsuranceCompany
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&company, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Convênio não encontrado"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"insurance": company})
+}
+
+// UpdateInsuranceCompany updates an insurer. The lot and guide numbering is not changed
+// PUT /insurances/:id
+func UpdateInsuranceCompany(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var existing models.InsuranceCompany
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&existing, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Convênio não encontrado"})
+		return
+	}
+
+	// Fields missing from the payload keep their current values
+	company := existing
+	if err := c.ShouldBindJSON(&company); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := helpers.NormalizeInsuranceCompany(&company); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := db.Session(&gorm.Session{NewDB: true}).Exec(`
+		UPDATE insurance_companies
+		SET name = ?, ans_registry = ?, cnpj = ?, provider_code = ?, payment_term_days = ?, phone = ?, email = ?,
+		    active = ?, notes = ?, updated_at = ?
+		WHERE id = ?
+	`, company.Name, company.ANSRegistry, company.CNPJ, company.ProviderCode, company.PaymentTermDays, company.Phone, company.Email,
+		company.Active, company.Notes, time.Now(), existing.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar convênio"})
+		return
+	}
+
+	helpers.AuditAction(c, "update", "insurance_companies", existing.ID, true, map[string]interface{}{
+		"name":   company.Name,
+		"active": company.Active,
+	})
+
+	db.Session(&gorm.Session{NewDB: true}).First(&existing, existing.ID)
+	c.JSON(http.StatusOK, gin.H{"insurance": existing})
+}
+
+// DeleteInsuranceCompany removes an insurer without guides. Insurers already billed are deactivated instead
+// DELETE /insurances/:id
+func DeleteInsuranceCompany(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var company models.InsuranceCompany
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&company, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Convênio não encontrado"})
+		return
+	}
+
+	var guides int64
+	db.Session(&gorm.Session{NewDB: true}).Model(&models.InsuranceGuide{}).Where("insurance_id = ?", company.ID).Count(&guides)
+	if guides > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Convênio possui guias faturadas. Desative-o em vez de excluir"})
+		return
+	}
+
+	err := db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("insurance_id = ?", company.ID).Delete(&models.InsurancePrice{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.InsuranceCompany{}, company.ID).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao remover convênio"})
+		return
+	}
+
+	helpers.AuditAction(c, "delete", "insurance_companies", company.ID, true, map[string]interface{}{
+		"name": company.Name,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Convênio removido com sucesso"})
+}
+
+// GetInsurancePrices returns the price table of an insurer
+// GET /insurances/:id/prices?search=
+func GetInsurancePrices(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var company models.InsuranceCompany
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&company, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Convênio não encontrado"})
+		return
+	}
+
+	query := db.Session(&gorm.Session{NewDB: true}).Where("insurance_id = ?", company.ID)
+	if search := strings.TrimSpace(c.Query("search")); search != "" {
+		query = query.Where("procedure_code LIKE ? OR description ILIKE ?", search+"%", "%"+search+"%")
+	}
+
+	var prices []models.InsurancePrice
+	if err := query.Order("procedure_code ASC").Find(&prices).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar tabela de preços"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"prices": prices, "total": len(prices)})
+}
+
+// InsurancePriceInput is a procedure of the price table of an insurer
+type InsurancePriceInput struct {
+	ProcedureCode string  `json:"procedure_code" binding:"required"`
+	Description   string  `json:"description"`
+	Price         float64 `json:"price"`
+	Active        *bool   `json:"active"`
+}
+
+// UpdateInsurancePricesRequest replaces or adds procedures to the price table of an insurer
+type UpdateInsurancePricesRequest struct {
+	Prices []InsurancePriceInput `json:"prices" binding:"required,dive"`
+}
+
+// UpdateInsurancePrices adds procedures to the price table of an insurer, updating the ones
+// whose TUSS code is already there
+// PUT /insurances/:id/prices
+func UpdateInsurancePrices(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var company models.InsuranceCompany
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&company, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Convênio não encontrado"})
+		return
+	}
+
+	var req UpdateInsurancePricesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	seen := make(map[string]bool, len(req.Prices))
+	for i := range req.Prices {
+		price := &req.Prices[i]
+		price.ProcedureCode = strings.TrimSpace(price.ProcedureCode)
+		price.Description = strings.TrimSpace(price.Description)
+		if !helpers.IsValidTUSSCode(price.ProcedureCode) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Código TUSS inválido: " + price.ProcedureCode + ". Use 8 dígitos"})
+			return
+		}
+		if seen[price.ProcedureCode] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Código TUSS repetido: " + price.ProcedureCode})
+			return
+		}
+		seen[price.ProcedureCode] = true
+		if price.Price < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Preço não pode ser negativo: " + price.ProcedureCode})
+			return
+		}
+	}
+
+	created, updated := 0, 0
+	err := db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		for _, input := range req.Prices {
+			active := input.Active == nil || *input.Active
+			result := tx.Exec(`
+				UPDATE insurance_prices SET description = ?, price = ?, active = ?, updated_at = ?
+				WHERE insurance_id = ? AND procedure_code = ? AND deleted_at IS NULL
+			`, input.Description, input.Price, active, time.Now(), company.ID, input.ProcedureCode)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				updated++
+				continue
+			}
+			price := models.InsurancePrice{
+				InsuranceID:   company.ID,
+				ProcedureCode: input.ProcedureCode,
+				Description:   input.Description,
+				Price:         input.Price,
+				Active:        active,
+			}
+			if err := tx.Create(&price).Error; err != nil {
+				return err
+			}
+			// Active defaults to true in the table, so a false value must be written explicitly
+			if !active {
+				if err := tx.Model(&price).Update("active", false).Error; err != nil {
+					return err
+				}
+			}
+			created++
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao salvar tabela de preços"})
+		return
+	}
+
+	helpers.AuditAction(c, "update", "insurance_prices", company.ID, true, map[string]interface{}{
+		"created": created,
+		"updated": updated,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"created": created, "updated": updated})
+}
+
+// DeleteInsurancePrice removes a procedure from the price table of an insurer
+// DELETE /insurances/:id/prices/:price_id
+func DeleteInsurancePrice(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var price models.InsurancePrice
+	if err := db.Session(&gorm.Session{NewDB: true}).
+		Where("id = ? AND insurance_id = ?", c.Param("price_id"), c.Param("id")).
+		First(&price).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Procedimento não encontrado na tabela"})
+		return
+	}
+
+	if err := db.Session(&gorm.Session{NewDB: true}).Delete(&models.InsurancePrice{}, price.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao remover procedimento da tabela"})
+		return
+	}
+
+	helpers.AuditAction(c, "delete", "insurance_prices", price.ID, true, map[string]interface{}{
+		"insurance_id":   price.InsuranceID,
+		"procedure_code": price.ProcedureCode,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Procedimento removido da tabela"})
+}
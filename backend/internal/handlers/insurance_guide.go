@@ -0,0 +1,479 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/database"
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errInsuranceGuideRequest carries a validation message (in Portuguese) out of the guide transaction
+type errInsuranceGuideRequest struct{ message string }
+
+func (e errInsuranceGuideRequest) Error() string { return e.message }
+
+// InsuranceGuideItemInput is a procedure billed in a guide without a plan item
+type InsuranceGuideItemInput struct {
+	ProcedureCode string     `json:"procedure_code" binding:"required"`
+	Description   string     `json:"description"`
+	Tooth         *int       `json:"tooth"`
+	Surfaces      string     `json:"surfaces"`
+	Quantity      int        `json:"quantity"`
+	UnitPrice     float64    `json:"unit_price"` // Used when the procedure is not in the insurer table
+	ExecutionDate *time.Time `json:"execution_date"`
+}
+
+// CreateInsuranceGuideRequest creates a guide from plan items and/or procedures entered by hand
+type CreateInsuranceGuideRequest struct {
+	InsuranceID         uint                      `json:"insurance_id" binding:"required"`
+	PatientID           uint                      `json:"patient_id" binding:"required"`
+	DentistID           uint                      `json:"dentist_id"`
+	BudgetID            *uint                     `json:"budget_id"`
+	PlanItemIDs         []uint                    `json:"plan_item_ids"`
+	Items               []InsuranceGuideItemInput `json:"items" binding:"dive"`
+	AuthorizationNumber string                    `json:"authorization_number"`
+	CardNumber          string                    `json:"card_number"` // Defaults to the patient insurance number
+	ServiceDate         *time.Time                `json:"service_date"`
+	Notes               string                    `json:"notes"`
+}
+
+// decryptInsuranceGuide decrypts the card number of a guide, and the patient fields when loaded
+func decryptInsuranceGuide(guide *models.InsuranceGuide) {
+	guide.CardNumber, _ = helpers.DecryptIfNeeded(guide.CardNumber)
+	if guide.Patient != nil {
+		decryptPatientFields(guide.Patient)
+	}
+}
+
+// loadInsuranceGuide loads a guide with its insurer, patient and items
+func loadInsuranceGuide(db *gorm.DB, id interface{}) (*models.InsuranceGuide, error) {
+	var guide models.InsuranceGuide
+	err := db.Session(&gorm.Session{NewDB: true}).
+		Preload("Insurance").
+		Preload("Patient").
+		Preload("Items", func(tx *gorm.DB) *gorm.DB { return tx.Order("id ASC") }).
+		First(&guide, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &guide, nil
+}
+
+// insuranceTablePrice returns the active price of a procedure in the insurer table
+func insuranceTablePrice(tx *gorm.DB, insuranceID uint, procedureCode string) (*models.InsurancePrice, error) {
+	var price models.InsurancePrice
+	err := tx.Where("insurance_id = ? AND procedure_code = ? AND active = ?", insuranceID, procedureCode, true).First(&price).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &price, nil
+}
+
+// priceInsuranceGuideItem fills in the price of a guide item from the insurer table, falling back to
+// the price informed for procedures the table does not have
+func priceInsuranceGuideItem(tx *gorm.DB, insuranceID uint, item *models.InsuranceGuideItem) error {
+	if !helpers.IsValidTUSSCode(item.ProcedureCode) {
+		return errInsuranceGuideRequest{fmt.Sprintf("Código TUSS inválido: %q. Use 8 dígitos", item.ProcedureCode)}
+	}
+	if item.Tooth != nil && !helpers.IsValidFDITooth(*item.Tooth) {
+		return errInsuranceGuideRequest{fmt.Sprintf("Dente inválido: %d", *item.Tooth)}
+	}
+	if item.Quantity <= 0 {
+		item.Quantity = 1
+	}
+
+	price, err := insuranceTablePrice(tx, insuranceID, item.ProcedureCode)
+	if err != nil {
+		return err
+	}
+	if price != nil {
+		item.UnitPrice = price.Price
+		if item.Description == "" {
+			item.Description = price.Description
+		}
+	}
+	if item.UnitPrice <= 0 {
+		return errInsuranceGuideRequest{"Procedimento " + item.ProcedureCode + " não consta na tabela de preços do convênio"}
+	}
+	if item.Description == "" {
+		return errInsuranceGuideRequest{"Descrição do procedimento " + item.ProcedureCode + " é obrigatória"}
+	}
+	item.UnitPrice = math.Round(item.UnitPrice*100) / 100
+	item.Total = math.Round(item.UnitPrice*float64(item.Quantity)*100) / 100
+	return nil
+}
+
+// insuranceGuidePlanItems turns plan items of the patient into guide items. Items cancelled, from
+// other patients or already billed in another guide are rejected
+func insuranceGuidePlanItems(tx *gorm.DB, patientID uint, ids []uint) ([]models.InsuranceGuideItem, *uint, error) {
+	var planItems []models.TreatmentPlanItem
+	if err := tx.Where("id IN ?", ids).Order("budget_id ASC, position ASC").Find(&planItems).Error; err != nil {
+		return nil, nil, err
+	}
+	if len(planItems) != len(ids) {
+		return nil, nil, errInsuranceGuideRequest{"Item do plano de tratamento não encontrado"}
+	}
+
+	var billed int64
+	if err := tx.Model(&models.InsuranceGuideItem{}).Where("plan_item_id IN ?", ids).Count(&billed).Error; err != nil {
+		return nil, nil, err
+	}
+	if billed > 0 {
+		return nil, nil, errInsuranceGuideRequest{"Item do plano já faturado em outra guia"}
+	}
+
+	budgetID := planItems[0].BudgetID
+	items := make([]models.InsuranceGuideItem, 0, len(planItems))
+	for _, planItem := range planItems {
+		switch {
+		case planItem.PatientID != patientID:
+			return nil, nil, errInsuranceGuideRequest{"Item do plano pertence a outro paciente"}
+		case planItem.BudgetID != budgetID:
+			return nil, nil, errInsuranceGuideRequest{"Os itens de uma guia devem ser do mesmo orçamento"}
+		case planItem.Status == models.PlanItemStatusCancelled:
+			return nil, nil, errInsuranceGuideRequest{"Item do plano cancelado: " + planItem.Procedure}
+		}
+		planItemID := planItem.ID
+		items = append(items, models.InsuranceGuideItem{
+			PlanItemID:    &planItemID,
+			ProcedureCode: strings.TrimSpace(planItem.ProcedureCode),
+			Description:   planItem.Procedure,
+			Tooth:         planItem.Tooth,
+			Surfaces:      planItem.Surfaces,
+			Quantity:      planItem.Quantity,
+			ExecutionDate: planItem.CompletedAt,
+		})
+	}
+	return items, &budgetID, nil
+}
+
+// CreateInsuranceGuide creates a dental treatment guide (GTO) to bill an insurer, priced from its table
+// POST /insurance-guides
+func CreateInsuranceGuide(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var req CreateInsuranceGuideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.PlanItemIDs) == 0 && len(req.Items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Informe ao menos um procedimento"})
+		return
+	}
+
+	var insurance models.InsuranceCompany
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&insurance, req.InsuranceID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Convênio não encontrado"})
+		return
+	}
+	if !insurance.Active {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Convênio inativo"})
+		return
+	}
+
+	var patient models.Patient
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&patient, req.PatientID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Paciente não encontrado"})
+		return
+	}
+	decryptPatientFields(&patient)
+	cardNumber := strings.TrimSpace(req.CardNumber)
+	if cardNumber == "" {
+		cardNumber = patient.InsuranceNumber
+	}
+	if cardNumber == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Número da carteira do convênio é obrigatório"})
+		return
+	}
+	encryptedCard, err := helpers.EncryptIfNeeded(cardNumber)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao proteger número da carteira"})
+		return
+	}
+
+	serviceDate := time.Now()
+	if req.ServiceDate != nil {
+		serviceDate = *req.ServiceDate
+	}
+	guide := models.InsuranceGuide{
+		InsuranceID:         insurance.ID,
+		PatientID:           patient.ID,
+		BudgetID:            req.BudgetID,
+		AuthorizationNumber: strings.TrimSpace(req.AuthorizationNumber),
+		CardNumber:          encryptedCard,
+		BeneficiaryName:     patient.Name,
+		DentistID:           req.DentistID,
+		ServiceDate:         serviceDate,
+		Status:              models.InsuranceGuideStatusPending,
+		Notes:               req.Notes,
+	}
+	if len(guide.AuthorizationNumber) > 20 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Senha de autorização deve ter até 20 caracteres"})
+		return
+	}
+
+	err = db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		var items []models.InsuranceGuideItem
+		if len(req.PlanItemIDs) > 0 {
+			planItems, budgetID, err := insuranceGuidePlanItems(tx, patient.ID, req.PlanItemIDs)
+			if err != nil {
+				return err
+			}
+			items = planItems
+			guide.BudgetID = budgetID
+		}
+		for _, input := range req.Items {
+			items = append(items, models.InsuranceGuideItem{
+				ProcedureCode: strings.TrimSpace(input.ProcedureCode),
+				Description:   strings.TrimSpace(input.Description),
+				Tooth:         input.Tooth,
+				Surfaces:      strings.ToUpper(strings.TrimSpace(input.Surfaces)),
+				Quantity:      input.Quantity,
+				UnitPrice:     input.UnitPrice,
+				ExecutionDate: input.ExecutionDate,
+			})
+		}
+
+		total := 0.0
+		for i := range items {
+			if err := priceInsuranceGuideItem(tx, insurance.ID, &items[i]); err != nil {
+				return err
+			}
+			total += items[i].Total
+		}
+		guide.TotalValue = math.Round(total*100) / 100
+
+		// The professional defaults to the one of the budget, then to the user creating the guide
+		if guide.DentistID == 0 && guide.BudgetID != nil {
+			var budget models.Budget
+			if err := tx.First(&budget, *guide.BudgetID).Error; err == nil {
+				guide.DentistID = budget.DentistID
+			}
+		}
+		if guide.DentistID == 0 {
+			guide.DentistID = c.GetUint("user_id")
+		}
+		var dentist models.User
+		if err := database.DB.Table("public.users").Select("id, name, cro").First(&dentist, guide.DentistID).Error; err != nil {
+			return errInsuranceGuideRequest{"Profissional não encontrado"}
+		}
+		guide.DentistName = dentist.Name
+		guide.DentistCRO = dentist.CRO
+
+		var number int
+		if err := tx.Raw(`
+			UPDATE insurance_companies SET last_guide_number = last_guide_number + 1, updated_at = NOW()
+			WHERE id = ?
+			RETURNING last_guide_number
+		`, insurance.ID).Row().Scan(&number); err != nil {
+			return err
+		}
+		guide.GuideNumber = fmt.Sprintf("%06d", number)
+
+		if err := tx.Create(&guide).Error; err != nil {
+			return err
+		}
+		for i := range items {
+			items[i].GuideID = guide.ID
+		}
+		return tx.Create(&items).Error
+	})
+	var requestErr errInsuranceGuideRequest
+	if errors.As(err, &requestErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": requestErr.message})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar guia"})
+		return
+	}
+
+	helpers.AuditAction(c, "create", "insurance_guides", guide.ID, true, map[string]interface{}{
+		"insurance_id": guide.InsuranceID,
+		"patient_id":   guide.PatientID,
+		"guide_number": guide.GuideNumber,
+		"total_value":  guide.TotalValue,
+	})
+
+	created, _ := loadInsuranceGuide(db, guide.ID)
+	decryptInsuranceGuide(created)
+	c.JSON(http.StatusCreated, gin.H{"guide": created})
+}
+
+// GetInsuranceGuides lists the guides
+// GET /insurance-guides?insurance_id=&patient_id=&status=&lot_id=&unbilled=true&start_date=&end_date=
+func GetInsuranceGuides(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	query := db.Session(&gorm.Session{NewDB: true}).Model(&models.InsuranceGuide{})
+	if insuranceID := c.Query("insurance_id"); insuranceID != "" {
+		query = query.Where("insurance_id = ?", insuranceID)
+	}
+	if patientID := c.Query("patient_id"); patientID != "" {
+		query = query.Where("patient_id = ?", patientID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if lotID := c.Query("lot_id"); lotID != "" {
+		query = query.Where("lot_id = ?", lotID)
+	}
+	if c.Query("unbilled") == "true" {
+		query = query.Where("lot_id IS NULL")
+	}
+	if startDate := c.Query("start_date"); startDate != "" {
+		query = query.Where("service_date >= ?", startDate)
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		query = query.Where("service_date < ?::date + 1", endDate)
+	}
+
+	var guides []models.InsuranceGuide
+	if err := query.Preload("Insurance").Preload("Patient").Order("service_date DESC, id DESC").Find(&guides).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar guias"})
+		return
+	}
+	for i := range guides {
+		decryptInsuranceGuide(&guides[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{"guides": guides, "total": len(guides)})
+}
+
+// GetInsuranceGuide returns a guide with its procedures
+// GET /insurance-guides/:id
+func GetInsuranceGuide(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	guide, err := loadInsuranceGuide(db, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Guia não encontrada"})
+		return
+	}
+	decryptInsuranceGuide(guide)
+
+	c.JSON(http.StatusOK, gin.H{"guide": guide})
+}
+
+// UpdateInsuranceGuideRequest updates the data of a guide not sent yet
+type UpdateInsuranceGuideRequest struct {
+	AuthorizationNumber *string    `json:"authorization_number"`
+	CardNumber          *string    `json:"card_number"`
+	BeneficiaryName     *string    `json:"beneficiary_name"`
+	ServiceDate         *time.Time `json:"service_date"`
+	Notes               *string    `json:"notes"`
+}
+
+// UpdateInsuranceGuide updates the data of a pending guide. The procedures are not changed: remove
+// the guide and create it again
+// PUT /insurance-guides/:id
+func UpdateInsuranceGuide(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var guide models.InsuranceGuide
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&guide, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Guia não encontrada"})
+		return
+	}
+	if guide.Status != models.InsuranceGuideStatusPending || guide.LotID != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Somente guias pendentes fora de lote podem ser alteradas"})
+		return
+	}
+
+	var req UpdateInsuranceGuideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.AuthorizationNumber != nil {
+		guide.AuthorizationNumber = strings.TrimSpace(*req.AuthorizationNumber)
+		if len(guide.AuthorizationNumber) > 20 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Senha de autorização deve ter até 20 caracteres"})
+			return
+		}
+	}
+	if req.CardNumber != nil {
+		cardNumber := strings.TrimSpace(*req.CardNumber)
+		if cardNumber == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Número da carteira do convênio é obrigatório"})
+			return
+		}
+		encrypted, err := helpers.EncryptIfNeeded(cardNumber)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao proteger número da carteira"})
+			return
+		}
+		guide.CardNumber = encrypted
+	}
+	if req.BeneficiaryName != nil && strings.TrimSpace(*req.BeneficiaryName) != "" {
+		guide.BeneficiaryName = strings.TrimSpace(*req.BeneficiaryName)
+	}
+	if req.ServiceDate != nil {
+		guide.ServiceDate = *req.ServiceDate
+	}
+	if req.Notes != nil {
+		guide.Notes = *req.Notes
+	}
+
+	if err := db.Session(&gorm.Session{NewDB: true}).Exec(`
+		UPDATE insurance_guides
+		SET authorization_number = ?, card_number = ?, beneficiary_name = ?, service_date = ?, notes = ?, updated_at = ?
+		WHERE id = ?
+	`, guide.AuthorizationNumber, guide.CardNumber, guide.BeneficiaryName, guide.ServiceDate, guide.Notes, time.Now(), guide.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar guia"})
+		return
+	}
+
+	helpers.AuditAction(c, "update", "insurance_guides", guide.ID, true, nil)
+
+	updated, _ := loadInsuranceGuide(db, guide.ID)
+	decryptInsuranceGuide(updated)
+	c.JSON(http.StatusOK, gin.H{"guide": updated})
+}
+
+// DeleteInsuranceGuide removes a pending guide, releasing its plan items to be billed again
+// DELETE /insurance-guides/:id
+func DeleteInsuranceGuide(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var guide models.InsuranceGuide
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&guide, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Guia não encontrada"})
+		return
+	}
+	if guide.Status != models.InsuranceGuideStatusPending || guide.LotID != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Somente guias pendentes fora de lote podem ser removidas"})
+		return
+	}
+
+	// Items are removed for good so that their plan items can be billed again
+	err := db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("guide_id = ?", guide.ID).Delete(&models.InsuranceGuideItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.InsuranceGuide{}, guide.ID).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao remover guia"})
+		return
+	}
+
+	helpers.AuditAction(c, "delete", "insurance_guides", guide.ID, true, map[string]interface{}{
+		"guide_number": guide.GuideNumber,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Guia removida com sucesso"})
+}
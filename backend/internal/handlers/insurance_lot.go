@@ -0,0 +1,458 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/database"
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errInsuranceLotInvalid carries the validation problems of a generated lot out of its transaction
+type errInsuranceLotInvalid struct{ problems []string }
+
+func (e errInsuranceLotInvalid) Error() string { return "invalid TISS lot" }
+
+// insuranceLotProvider identifies the clinic in the lots of an insurer: by its code at the insurer,
+// or by the CNPJ of the clinic settings
+func insuranceLotProvider(tenantID uint, insurance models.InsuranceCompany) helpers.TISSProvider {
+	name, _, _ := referralClinicInfo(tenantID)
+	provider := helpers.TISSProvider{Code: insurance.ProviderCode, Name: name}
+	if provider.Code == "" {
+		var settings models.TenantSettings
+		database.DB.Table("public.tenant_settings").Where("tenant_id = ?", tenantID).First(&settings)
+		provider.CNPJ = settings.ClinicCNPJ
+	}
+	return provider
+}
+
+// CreateInsuranceLotRequest groups pending guides of an insurer in a lot. Without guide IDs, all
+// pending guides of the insurer not in a lot are included
+type CreateInsuranceLotRequest struct {
+	InsuranceID uint   `json:"insurance_id" binding:"required"`
+	GuideIDs    []uint `json:"guide_ids"`
+}
+
+// CreateInsuranceLot generates the TISS XML of a lot of guides and validates it. Lots with problems
+// are not saved: the problems are returned for the guides to be fixed
+// POST /insurance-lots
+func CreateInsuranceLot(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var req CreateInsuranceLotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.GuideIDs) > helpers.TISSMaxGuidesPerLot {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Um lote pode ter no máximo %d guias", helpers.TISSMaxGuidesPerLot)})
+		return
+	}
+
+	var insurance models.InsuranceCompany
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&insurance, req.InsuranceID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Convênio não encontrado"})
+		return
+	}
+	provider := insuranceLotProvider(c.GetUint("tenant_id"), insurance)
+	if provider.Code == "" && provider.CNPJ == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Informe o código do prestador no convênio ou o CNPJ da clínica nas configurações"})
+		return
+	}
+
+	lot := models.InsuranceLot{
+		InsuranceID: insurance.ID,
+		Status:      models.InsuranceLotStatusGenerated,
+		TISSVersion: helpers.TISSVersion,
+		CreatedByID: c.GetUint("user_id"),
+	}
+	err := db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("insurance_id = ? AND status = ? AND lot_id IS NULL", insurance.ID, models.InsuranceGuideStatusPending)
+		if len(req.GuideIDs) > 0 {
+			query = query.Where("id IN ?", req.GuideIDs)
+		}
+		var guides []models.InsuranceGuide
+		if err := query.Preload("Items", func(tx *gorm.DB) *gorm.DB { return tx.Order("id ASC") }).
+			Order("id ASC").Limit(helpers.TISSMaxGuidesPerLot).Find(&guides).Error; err != nil {
+			return err
+		}
+		if len(guides) == 0 || (len(req.GuideIDs) > 0 && len(guides) != len(req.GuideIDs)) {
+			return errInsuranceGuideRequest{"Nenhuma guia pendente deste convênio para incluir no lote"}
+		}
+
+		var number int
+		if err := tx.Raw(`
+			UPDATE insurance_companies SET last_lot_number = last_lot_number + 1, updated_at = NOW()
+			WHERE id = ?
+			RETURNING last_lot_number
+		`, insurance.ID).Row().Scan(&number); err != nil {
+			return err
+		}
+		lot.LotNumber = strconv.Itoa(number)
+
+		ids := make([]uint, 0, len(guides))
+		total := 0.0
+		for i := range guides {
+			guides[i].CardNumber, _ = helpers.DecryptIfNeeded(guides[i].CardNumber)
+			ids = append(ids, guides[i].ID)
+			total += guides[i].TotalValue
+		}
+		data, hash, err := helpers.BuildTISSLot(insurance, provider, lot, guides, time.Now())
+		if err != nil {
+			return err
+		}
+		problems, err := helpers.ValidateTISSLot(data)
+		if err != nil {
+			return err
+		}
+		if len(problems) > 0 {
+			return errInsuranceLotInvalid{problems}
+		}
+
+		lot.GuideCount = len(guides)
+		lot.TotalValue = math.Round(total*100) / 100
+		lot.Hash = hash
+		lot.XML = string(data)
+		if err := tx.Create(&lot).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.InsuranceGuide{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"lot_id":     lot.ID,
+			"updated_at": time.Now(),
+		}).Error
+	})
+	var requestErr errInsuranceGuideRequest
+	if errors.As(err, &requestErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": requestErr.message})
+		return
+	}
+	var invalidErr errInsuranceLotInvalid
+	if errors.As(err, &invalidErr) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "O lote gerado não passou na validação TISS", "problems": invalidErr.problems})
+		return
+	}
+	if errors.Is(err, helpers.ErrTISSSchemaUnavailable) {
+		log.Printf("Insurance lot: %v (check TISS_SCHEMA_DIR and xmllint)", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Validação TISS indisponível: esquema da ANS não instalado no servidor"})
+		return
+	}
+	if err != nil {
+		log.Printf("Insurance lot: could not generate lot: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar lote"})
+		return
+	}
+
+	helpers.AuditAction(c, "create", "insurance_lots", lot.ID, true, map[string]interface{}{
+		"insurance_id": lot.InsuranceID,
+		"lot_number":   lot.LotNumber,
+		"guide_count":  lot.GuideCount,
+		"total_value":  lot.TotalValue,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{"lot": lot})
+}
+
+// GetInsuranceLots lists the lots
+// GET /insurance-lots?insurance_id=&status=
+func GetInsuranceLots(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	query := db.Session(&gorm.Session{NewDB: true}).Model(&models.InsuranceLot{})
+	if insuranceID := c.Query("insurance_id"); insuranceID != "" {
+		query = query.Where("insurance_id = ?", insuranceID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var lots []models.InsuranceLot
+	if err := query.Preload("Insurance").Order("created_at DESC").Find(&lots).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar lotes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"lots": lots, "total": len(lots)})
+}
+
+// GetInsuranceLot returns a lot with its guides
+// GET /insurance-lots/:id
+func GetInsuranceLot(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var lot models.InsuranceLot
+	if err := db.Session(&gorm.Session{NewDB: true}).
+		Preload("Insurance").
+		Preload("Guides", func(tx *gorm.DB) *gorm.DB { return tx.Order("id ASC") }).
+		Preload("Guides.Items", func(tx *gorm.DB) *gorm.DB { return tx.Order("id ASC") }).
+		First(&lot, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lote não encontrado"})
+		return
+	}
+	for i := range lot.Guides {
+		decryptInsuranceGuide(&lot.Guides[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{"lot": lot})
+}
+
+// DownloadInsuranceLotXML downloads the TISS XML of a lot, named with its number and hash
+// GET /insurance-lots/:id/xml
+func DownloadInsuranceLotXML(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var lot models.InsuranceLot
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&lot, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lote não encontrado"})
+		return
+	}
+
+	helpers.AuditAction(c, "export", "insurance_lots", lot.ID, true, nil)
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s_%s.xml", lot.LotNumber, lot.Hash))
+	c.Data(http.StatusOK, "application/xml; charset=ISO-8859-1", []byte(lot.XML))
+}
+
+// ValidateInsuranceLot validates the stored XML of a lot again, including its hash
+// GET /insurance-lots/:id/validate
+func ValidateInsuranceLot(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var lot models.InsuranceLot
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&lot, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lote não encontrado"})
+		return
+	}
+
+	problems, err := helpers.ValidateTISSLot([]byte(lot.XML))
+	if errors.Is(err, helpers.ErrTISSSchemaUnavailable) {
+		log.Printf("Insurance lot %d: %v (check TISS_SCHEMA_DIR and xmllint)", lot.ID, err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Validação TISS indisponível: esquema da ANS não instalado no servidor"})
+		return
+	}
+	if err != nil {
+		log.Printf("Insurance lot %d: schema validation failed: %v", lot.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao validar lote com o esquema TISS"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"valid":    len(problems) == 0,
+		"problems": problems,
+		"hash":     lot.Hash,
+	})
+}
+
+// MarkInsuranceLotSent records that a lot was delivered to the insurer
+// POST /insurance-lots/:id/send
+func MarkInsuranceLotSent(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var lot models.InsuranceLot
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&lot, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lote não encontrado"})
+		return
+	}
+	if lot.Status != models.InsuranceLotStatusGenerated {
+		c.JSON(http.StatusConflict, gin.H{"error": "Lote já enviado"})
+		return
+	}
+
+	now := time.Now()
+	err := db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("UPDATE insurance_lots SET status = ?, sent_at = ?, updated_at = ? WHERE id = ?",
+			models.InsuranceLotStatusSent, now, now, lot.ID).Error; err != nil {
+			return err
+		}
+		return tx.Exec("UPDATE insurance_guides SET status = ?, updated_at = ? WHERE lot_id = ? AND deleted_at IS NULL",
+			models.InsuranceGuideStatusSent, now, lot.ID).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao registrar envio do lote"})
+		return
+	}
+
+	helpers.AuditAction(c, "update", "insurance_lots", lot.ID, true, map[string]interface{}{
+		"status": models.InsuranceLotStatusSent,
+	})
+
+	lot.Status = models.InsuranceLotStatusSent
+	lot.SentAt = &now
+	c.JSON(http.StatusOK, gin.H{"lot": lot})
+}
+
+// DeleteInsuranceLot removes a lot not sent yet, releasing its guides. The lot number is not reused
+// DELETE /insurance-lots/:id
+func DeleteInsuranceLot(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var lot models.InsuranceLot
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&lot, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lote não encontrado"})
+		return
+	}
+	if lot.Status != models.InsuranceLotStatusGenerated {
+		c.JSON(http.StatusConflict, gin.H{"error": "Lotes enviados não podem ser removidos"})
+		return
+	}
+
+	err := db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("UPDATE insurance_guides SET lot_id = NULL, updated_at = ? WHERE lot_id = ?", time.Now(), lot.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.InsuranceLot{}, lot.ID).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao remover lote"})
+		return
+	}
+
+	helpers.AuditAction(c, "delete", "insurance_lots", lot.ID, true, map[string]interface{}{
+		"lot_number": lot.LotNumber,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Lote removido com sucesso"})
+}
+
+// InsuranceGuideReturn is the payment return of the insurer for a guide
+type InsuranceGuideReturn struct {
+	GuideID uint                          `json:"guide_id" binding:"required"`
+	Items   []helpers.InsuranceItemReturn `json:"items" binding:"dive"`
+}
+
+// InsuranceLotReturnRequest is the payment return (demonstrativo de pagamento) of a lot. Guides
+// may be reconciled in more than one return
+type InsuranceLotReturnRequest struct {
+	PaidDate *time.Time             `json:"paid_date"`
+	Guides   []InsuranceGuideReturn `json:"guides" binding:"required,min=1,dive"`
+}
+
+// ReconcileInsuranceLot records the payment return of the insurer: what was paid and disallowed
+// (glosa) for each procedure. The amount paid for each guide is registered as an income payment,
+// which generates the commissions of the budget. The lot is closed when all its guides are reconciled
+// POST /insurance-lots/:id/return
+func ReconcileInsuranceLot(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var lot models.InsuranceLot
+	if err := db.Session(&gorm.Session{NewDB: true}).Preload("Insurance").First(&lot, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lote não encontrado"})
+		return
+	}
+	if lot.Status != models.InsuranceLotStatusSent {
+		c.JSON(http.StatusConflict, gin.H{"error": "Somente lotes enviados e não fechados recebem retorno"})
+		return
+	}
+
+	var req InsuranceLotReturnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	paidDate := time.Now()
+	if req.PaidDate != nil {
+		paidDate = *req.PaidDate
+	}
+
+	var reconciled []models.InsuranceGuide
+	err := db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		lotPaid := 0.0
+		for _, guideReturn := range req.Guides {
+			var guide models.InsuranceGuide
+			if err := tx.Preload("Items").Where("id = ? AND lot_id = ?", guideReturn.GuideID, lot.ID).First(&guide).Error; err != nil {
+				return errInsuranceGuideRequest{fmt.Sprintf("Guia %d não pertence ao lote", guideReturn.GuideID)}
+			}
+			if guide.Status != models.InsuranceGuideStatusSent {
+				return errInsuranceGuideRequest{"Guia " + guide.GuideNumber + " já conciliada"}
+			}
+			if err := helpers.ApplyInsuranceGuideReturn(&guide, guideReturn.Items); err != nil {
+				return errInsuranceGuideRequest{err.Error()}
+			}
+
+			for _, item := range guide.Items {
+				if err := tx.Exec(`
+					UPDATE insurance_guide_items
+					SET paid_value = ?, disallowed_value = ?, disallowance_code = ?, disallowance_reason = ?, updated_at = ?
+					WHERE id = ?
+				`, item.PaidValue, item.DisallowedValue, item.DisallowanceCode, item.DisallowanceReason, time.Now(), item.ID).Error; err != nil {
+					return err
+				}
+			}
+
+			if guide.PaidValue > 0 {
+				patientID := guide.PatientID
+				payment := models.Payment{
+					BudgetID:      guide.BudgetID,
+					PatientID:     &patientID,
+					Type:          "income",
+					Category:      "insurance",
+					Description:   fmt.Sprintf("Guia %s - lote %s - %s", guide.GuideNumber, lot.LotNumber, lot.Insurance.Name),
+					Amount:        guide.PaidValue,
+					PaymentMethod: "insurance",
+					Status:        "paid",
+					PaidDate:      &paidDate,
+					IsInsurance:   true,
+					InsuranceName: lot.Insurance.Name,
+				}
+				if err := tx.Create(&payment).Error; err != nil {
+					return err
+				}
+				if err := generatePaymentCommissions(tx, payment); err != nil {
+					return err
+				}
+				guide.PaymentID = &payment.ID
+			}
+			guide.PaidDate = &paidDate
+
+			if err := tx.Exec(`
+				UPDATE insurance_guides
+				SET status = ?, paid_value = ?, disallowed_value = ?, paid_date = ?, payment_id = ?, updated_at = ?
+				WHERE id = ?
+			`, guide.Status, guide.PaidValue, guide.DisallowedValue, guide.PaidDate, guide.PaymentID, time.Now(), guide.ID).Error; err != nil {
+				return err
+			}
+			lotPaid += guide.PaidValue
+			reconciled = append(reconciled, guide)
+		}
+
+		var pending int64
+		if err := tx.Model(&models.InsuranceGuide{}).Where("lot_id = ? AND status = ?", lot.ID, models.InsuranceGuideStatusSent).Count(&pending).Error; err != nil {
+			return err
+		}
+		lot.PaidValue = math.Round((lot.PaidValue+lotPaid)*100) / 100
+		if pending == 0 {
+			now := time.Now()
+			lot.Status = models.InsuranceLotStatusClosed
+			lot.ClosedAt = &now
+		}
+		return tx.Exec("UPDATE insurance_lots SET paid_value = ?, status = ?, closed_at = ?, updated_at = ? WHERE id = ?",
+			lot.PaidValue, lot.Status, lot.ClosedAt, time.Now(), lot.ID).Error
+	})
+	var requestErr errInsuranceGuideRequest
+	if errors.As(err, &requestErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": requestErr.message})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao registrar retorno do convênio"})
+		return
+	}
+
+	helpers.AuditAction(c, "update", "insurance_lots", lot.ID, true, map[string]interface{}{
+		"guides":     len(reconciled),
+		"paid_value": lot.PaidValue,
+		"status":     lot.Status,
+	})
+
+	for i := range reconciled {
+		decryptInsuranceGuide(&reconciled[i])
+	}
+	c.JSON(http.StatusOK, gin.H{"lot": lot, "guides": reconciled})
+}
@@ -4,10 +4,12 @@ import (
 	"drcrwell/backend/internal/helpers"
 	"drcrwell/backend/internal/middleware"
 	"drcrwell/backend/internal/models"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func CreateMedicalRecord(c *gin.Context) {
@@ -17,10 +19,41 @@ func CreateMedicalRecord(c *gin.Context) {
 		return
 	}
 
+	// Signature fields are only set by SignMedicalRecord
+	record.IsSigned = false
+	record.SignedAt = nil
+	record.SignedByID = nil
+	record.SignedByName = ""
+	record.SignedByCRO = ""
+	record.CertificateID = nil
+	record.CertificateThumbprint = ""
+	record.SignatureHash = ""
+
 	db, ok := middleware.GetDBFromContextSafe(c)
 	if !ok {
 		return
 	}
+
+	// Empty fields are filled with the note template, placeholders must all have a value
+	if record.NoteTemplateID != nil {
+		missing, err := applyRecordNoteTemplate(db, c.GetUint("user_id"), &record)
+		if errors.Is(err, errNoteTemplateNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Note template not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load note template"})
+			return
+		}
+		if len(missing) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":                "Note template placeholders without a value",
+				"missing_placeholders": missing,
+			})
+			return
+		}
+	}
+
 	if err := db.Create(&record).Error; err != nil {
 		helpers.AuditAction(c, "create", "medical_records", 0, false, map[string]interface{}{
 			"error":      "Failed to create medical record",
@@ -30,6 +63,10 @@ func CreateMedicalRecord(c *gin.Context) {
 		return
 	}
 
+	if record.NoteTemplateID != nil {
+		countNoteTemplateUsage(db, *record.NoteTemplateID)
+	}
+
 	// Load relationships
 	db.Preload("Patient").Preload("Dentist").First(&record, record.ID)
 
@@ -42,6 +79,7 @@ func CreateMedicalRecord(c *gin.Context) {
 		"has_diagnosis": record.Diagnosis != "",
 		"has_treatment_plan": record.TreatmentPlan != "",
 		"has_procedure": record.ProcedureDone != "",
+		"note_template_id": record.NoteTemplateID,
 	})
 
 	c.JSON(http.StatusCreated, gin.H{"record": record})
@@ -106,7 +144,9 @@ func GetMedicalRecord(c *gin.Context) {
 		"record_type":  record.Type,
 	})
 
-	c.JSON(http.StatusOK, gin.H{"record": record})
+	addenda, _ := loadMedicalRecordAddenda(db, record.ID)
+
+	c.JSON(http.StatusOK, gin.H{"record": record, "addenda": addenda})
 }
 
 func UpdateMedicalRecord(c *gin.Context) {
@@ -124,26 +164,59 @@ func UpdateMedicalRecord(c *gin.Context) {
 		return
 	}
 
+	// Signed records are locked: corrections go through addenda
+	if oldRecord.IsSigned {
+		helpers.AuditAction(c, "update", "medical_records", uint(recordID), false, map[string]interface{}{
+			"error":      "Signed record is locked",
+			"patient_id": oldRecord.PatientID,
+		})
+		c.JSON(http.StatusConflict, gin.H{"error": medicalRecordLockedMessage})
+		return
+	}
+
 	var input models.MedicalRecord
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Update using Exec to avoid the duplicate table error
-	result := db.Exec(`
-		UPDATE medical_records
-		SET patient_id = ?, dentist_id = ?, appointment_id = ?, type = ?,
-		    odontogram = ?, diagnosis = ?, treatment_plan = ?, procedure_done = ?,
-		    materials = ?, prescription = ?, certificate = ?, evolution = ?,
-		    notes = ?, updated_at = NOW()
-		WHERE id = ? AND deleted_at IS NULL
-	`, input.PatientID, input.DentistID, input.AppointmentID, input.Type,
-		input.Odontogram, input.Diagnosis, input.TreatmentPlan, input.ProcedureDone,
-		input.Materials, input.Prescription, input.Certificate, input.Evolution,
-		input.Notes, id)
+	changed := changedMedicalRecordFields(medicalRecordContentOf(oldRecord), medicalRecordContentOf(input))
 
-	if result.Error != nil {
+	// Keep the previous content as a revision, then update using Exec to avoid the duplicate table error
+	locked := false
+	err := db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		if len(changed) > 0 {
+			if err := recordMedicalRecordRevision(tx, oldRecord, changed, c.GetUint("user_id")); err != nil {
+				return err
+			}
+		}
+		result := tx.Exec(`
+			UPDATE medical_records
+			SET patient_id = ?, dentist_id = ?, appointment_id = ?, type = ?,
+			    odontogram = ?, diagnosis = ?, treatment_plan = ?, procedure_done = ?,
+			    materials = ?, prescription = ?, certificate = ?, evolution = ?,
+			    notes = ?, updated_at = NOW()
+			WHERE id = ? AND deleted_at IS NULL AND is_signed = false
+		`, input.PatientID, input.DentistID, input.AppointmentID, input.Type,
+			input.Odontogram, input.Diagnosis, input.TreatmentPlan, input.ProcedureDone,
+			input.Materials, input.Prescription, input.Certificate, input.Evolution,
+			input.Notes, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// Signed in the meantime
+			locked = true
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+
+	if locked {
+		c.JSON(http.StatusConflict, gin.H{"error": medicalRecordLockedMessage})
+		return
+	}
+	if err != nil {
 		helpers.AuditAction(c, "update", "medical_records", uint(recordID), false, map[string]interface{}{
 			"error":      "Failed to update record",
 			"patient_id": oldRecord.PatientID,
@@ -167,6 +240,7 @@ func UpdateMedicalRecord(c *gin.Context) {
 			"procedure_done_changed":  oldRecord.ProcedureDone != record.ProcedureDone,
 			"odontogram_changed":      oldRecord.Odontogram != record.Odontogram,
 		},
+		"changed_fields": changed,
 	})
 
 	c.JSON(http.StatusOK, gin.H{"record": record})
@@ -182,7 +256,20 @@ func DeleteMedicalRecord(c *gin.Context) {
 
 	// Buscar dados do prontuário antes de deletar para log
 	var record models.MedicalRecord
-	db.Preload("Patient").First(&record, id)
+	if err := db.Preload("Patient").First(&record, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Medical record not found"})
+		return
+	}
+
+	// Signed records must be kept (CFO record-keeping rules)
+	if record.IsSigned {
+		helpers.AuditAction(c, "delete", "medical_records", uint(recordID), false, map[string]interface{}{
+			"error":      "Signed record is locked",
+			"patient_id": record.PatientID,
+		})
+		c.JSON(http.StatusConflict, gin.H{"error": "Prontuário assinado não pode ser excluído."})
+		return
+	}
 
 	if err := db.Delete(&models.MedicalRecord{}, id).Error; err != nil {
 		helpers.AuditAction(c, "delete", "medical_records", uint(recordID), false, map[string]interface{}{
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// medicalRecordLockedMessage is returned when a signed record would be changed
+const medicalRecordLockedMessage = "Prontuário assinado não pode ser alterado. Registre um adendo para corrigir ou complementar."
+
+// medicalRecordContent is the editable content of a medical record, kept in each revision
+type medicalRecordContent struct {
+	PatientID     uint    `json:"patient_id"`
+	DentistID     uint    `json:"dentist_id"`
+	AppointmentID *uint   `json:"appointment_id"`
+	Type          string  `json:"type"`
+	Odontogram    *string `json:"odontogram,omitempty"`
+	Diagnosis     string  `json:"diagnosis"`
+	TreatmentPlan string  `json:"treatment_plan"`
+	ProcedureDone string  `json:"procedure_done"`
+	Materials     string  `json:"materials"`
+	Prescription  string  `json:"prescription"`
+	Certificate   string  `json:"certificate"`
+	Evolution     string  `json:"evolution"`
+	Notes         string  `json:"notes"`
+}
+
+func medicalRecordContentOf(record models.MedicalRecord) medicalRecordContent {
+	return medicalRecordContent{
+		PatientID:     record.PatientID,
+		DentistID:     record.DentistID,
+		AppointmentID: record.AppointmentID,
+		Type:          record.Type,
+		Odontogram:    record.Odontogram,
+		Diagnosis:     record.Diagnosis,
+		TreatmentPlan: record.TreatmentPlan,
+		ProcedureDone: record.ProcedureDone,
+		Materials:     record.Materials,
+		Prescription:  record.Prescription,
+		Certificate:   record.Certificate,
+		Evolution:     record.Evolution,
+		Notes:         record.Notes,
+	}
+}
+
+// changedMedicalRecordFields lists the fields that differ between two versions of a record
+func changedMedicalRecordFields(before, after medicalRecordContent) []string {
+	optional := func(a, b *uint) bool {
+		return (a == nil) != (b == nil) || (a != nil && *a != *b)
+	}
+	optionalText := func(a, b *string) bool {
+		return (a == nil) != (b == nil) || (a != nil && *a != *b)
+	}
+
+	checks := []struct {
+		field   string
+		changed bool
+	}{
+		{"patient_id", before.PatientID != after.PatientID},
+		{"dentist_id", before.DentistID != after.DentistID},
+		{"appointment_id", optional(before.AppointmentID, after.AppointmentID)},
+		{"type", before.Type != after.Type},
+		{"odontogram", optionalText(before.Odontogram, after.Odontogram)},
+		{"diagnosis", before.Diagnosis != after.Diagnosis},
+		{"treatment_plan", before.TreatmentPlan != after.TreatmentPlan},
+		{"procedure_done", before.ProcedureDone != after.ProcedureDone},
+		{"materials", before.Materials != after.Materials},
+		{"prescription", before.Prescription != after.Prescription},
+		{"certificate", before.Certificate != after.Certificate},
+		{"evolution", before.Evolution != after.Evolution},
+		{"notes", before.Notes != after.Notes},
+	}
+
+	changed := []string{}
+	for _, check := range checks {
+		if check.changed {
+			changed = append(changed, check.field)
+		}
+	}
+	return changed
+}
+
+// recordMedicalRecordRevision stores the content the record had before a change
+func recordMedicalRecordRevision(tx *gorm.DB, before models.MedicalRecord, changed []string, userID uint) error {
+	snapshot, err := json.Marshal(medicalRecordContentOf(before))
+	if err != nil {
+		return err
+	}
+	fields, err := json.Marshal(changed)
+	if err != nil {
+		return err
+	}
+
+	var last int
+	if err := tx.Raw("SELECT COALESCE(MAX(revision), 0) FROM medical_record_revisions WHERE medical_record_id = ?", before.ID).
+		Scan(&last).Error; err != nil {
+		return err
+	}
+
+	return tx.Create(&models.MedicalRecordRevision{
+		MedicalRecordID: before.ID,
+		Revision:        last + 1,
+		EditedByID:      userID,
+		ChangedFields:   string(fields),
+		Snapshot:        string(snapshot),
+	}).Error
+}
+
+// medicalRecordRevisionResponse is a revision with its JSON columns decoded
+type medicalRecordRevisionResponse struct {
+	models.MedicalRecordRevision
+	ChangedFields []string             `json:"changed_fields"`
+	Snapshot      medicalRecordContent `json:"snapshot"`
+	EditedByName  string               `json:"edited_by_name"`
+}
+
+// GetMedicalRecordRevisions returns the previous versions of a record, newest first
+// GET /medical-records/:id/revisions
+func GetMedicalRecordRevisions(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var record models.MedicalRecord
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&record, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Prontuário não encontrado"})
+		return
+	}
+
+	var rows []struct {
+		models.MedicalRecordRevision
+		EditedByName string
+	}
+	if err := db.Session(&gorm.Session{NewDB: true}).
+		Table("medical_record_revisions r").
+		Select("r.*, COALESCE(u.name, '') as edited_by_name").
+		Joins("LEFT JOIN public.users u ON r.edited_by_id = u.id").
+		Where("r.medical_record_id = ?", record.ID).
+		Order("r.revision DESC").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar revisões do prontuário"})
+		return
+	}
+
+	revisions := make([]medicalRecordRevisionResponse, 0, len(rows))
+	for _, row := range rows {
+		revision := medicalRecordRevisionResponse{
+			MedicalRecordRevision: row.MedicalRecordRevision,
+			ChangedFields:         []string{},
+			EditedByName:          row.EditedByName,
+		}
+		json.Unmarshal([]byte(row.MedicalRecordRevision.ChangedFields), &revision.ChangedFields)
+		json.Unmarshal([]byte(row.MedicalRecordRevision.Snapshot), &revision.Snapshot)
+		revisions = append(revisions, revision)
+	}
+
+	helpers.AuditAction(c, "view_revisions", "medical_records", record.ID, true, map[string]interface{}{
+		"patient_id": record.PatientID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"record_id": record.ID,
+		"is_signed": record.IsSigned,
+		"revisions": revisions,
+	})
+}
+
+// loadMedicalRecordAddenda returns the addenda of a record, oldest first
+func loadMedicalRecordAddenda(db *gorm.DB, recordID uint) ([]models.MedicalRecordAddendum, error) {
+	addenda := []models.MedicalRecordAddendum{}
+	err := db.Session(&gorm.Session{NewDB: true}).
+		Preload("Author").
+		Where("medical_record_id = ?", recordID).
+		Order("created_at ASC, id ASC").
+		Find(&addenda).Error
+	return addenda, err
+}
+
+// CreateMedicalRecordAddendumRequest is the payload of a new addendum
+type CreateMedicalRecordAddendumRequest struct {
+	Reason  string `json:"reason" binding:"required"`
+	Content string `json:"content" binding:"required"`
+}
+
+// CreateMedicalRecordAddendum appends a correction or complement to a signed record
+// POST /medical-records/:id/addenda
+func CreateMedicalRecordAddendum(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var record models.MedicalRecord
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&record, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Prontuário não encontrado"})
+		return
+	}
+	if !record.IsSigned {
+		c.JSON(http.StatusConflict, gin.H{"error": "Prontuário ainda não assinado. Edite o prontuário diretamente."})
+		return
+	}
+
+	var req CreateMedicalRecordAddendumRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Motivo e conteúdo do adendo são obrigatórios"})
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	req.Content = strings.TrimSpace(req.Content)
+	if req.Reason == "" || req.Content == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Motivo e conteúdo do adendo são obrigatórios"})
+		return
+	}
+
+	addendum := models.MedicalRecordAddendum{
+		MedicalRecordID: record.ID,
+		AuthorID:        c.GetUint("user_id"),
+		Reason:          req.Reason,
+		Content:         req.Content,
+	}
+	if err := db.Session(&gorm.Session{NewDB: true}).Create(&addendum).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao registrar adendo"})
+		return
+	}
+
+	helpers.AuditAction(c, "create_addendum", "medical_records", record.ID, true, map[string]interface{}{
+		"patient_id":  record.PatientID,
+		"addendum_id": addendum.ID,
+		"reason":      addendum.Reason,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{"addendum": addendum})
+}
+
+// GetMedicalRecordAddenda lists the addenda of a record
+// GET /medical-records/:id/addenda
+func GetMedicalRecordAddenda(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var record models.MedicalRecord
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&record, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Prontuário não encontrado"})
+		return
+	}
+
+	addenda, err := loadMedicalRecordAddenda(db, record.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar adendos"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"addenda": addenda})
+}
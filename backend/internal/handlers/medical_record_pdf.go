@@ -1,9 +1,9 @@
 package handlers
 
 import (
+	"drcrwell/backend/internal/helpers"
 	"drcrwell/backend/internal/models"
 	"drcrwell/backend/internal/middleware"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
@@ -109,9 +109,27 @@ func GenerateMedicalRecordPDF(c *gin.Context) {
 	renderSection(pdf, tr, "Evolucao:", record.Evolution)
 	renderSection(pdf, tr, "Notas Adicionais:", record.Notes)
 
-	// Odontogram
-	if record.Odontogram != nil && *record.Odontogram != "" {
-		renderOdontogram(pdf, tr, *record.Odontogram)
+	// Odontogram: the patient's chart as it was when this record was last written,
+	// frozen at the signature for signed records
+	if events, err := loadPatientToothEvents(db, record.PatientID); err == nil {
+		asOf := record.UpdatedAt
+		if record.IsSigned && record.SignedAt != nil {
+			// RecordedAt comes from the client, so the cut uses the server-side creation time
+			asOf = *record.SignedAt
+			signed := events[:0]
+			for _, event := range events {
+				if !event.CreatedAt.After(*record.SignedAt) {
+					signed = append(signed, event)
+				}
+			}
+			events = signed
+		}
+		for _, event := range events {
+			if event.MedicalRecordID != nil && *event.MedicalRecordID == record.ID && event.RecordedAt.After(asOf) {
+				asOf = event.RecordedAt
+			}
+		}
+		renderOdontogram(pdf, tr, helpers.BuildOdontogram(events, asOf))
 	}
 
 	// Digital signature block (if signed)
@@ -143,6 +161,11 @@ func GenerateMedicalRecordPDF(c *gin.Context) {
 		pdf.MultiCell(180, 3, tr("Este documento foi assinado digitalmente com certificado ICP-Brasil. A integridade pode ser verificada atraves do hash acima."), "", "C", false)
 	}
 
+	// Addenda (corrections appended after the record was signed)
+	if addenda, err := loadMedicalRecordAddenda(db, record.ID); err == nil && len(addenda) > 0 {
+		renderMedicalRecordAddenda(pdf, tr, addenda)
+	}
+
 	// Footer
 	pdf.Ln(10)
 	pdf.SetFont("Arial", "I", 8)
@@ -162,6 +185,39 @@ func GenerateMedicalRecordPDF(c *gin.Context) {
 	}
 }
 
+// renderMedicalRecordAddenda renders the addenda of a signed record, each with its own signature
+func renderMedicalRecordAddenda(pdf *gofpdf.Fpdf, tr func(string) string, addenda []models.MedicalRecordAddendum) {
+	checkPageBreak(pdf, 40)
+
+	pdf.Ln(5)
+	pdf.SetFillColor(240, 240, 240)
+	pdf.SetFont("Arial", "B", 11)
+	pdf.CellFormat(180, 7, tr("Adendos"), "1", 0, "L", true, 0, "")
+	pdf.Ln(-1)
+
+	for i, addendum := range addenda {
+		author := "N/A"
+		if addendum.Author != nil {
+			author = addendum.Author.Name
+		}
+		title := fmt.Sprintf("Adendo %d - %s - %s", i+1, addendum.CreatedAt.Format("02/01/2006 15:04"), author)
+		renderSection(pdf, tr, title, "Motivo: "+addendum.Reason+"\n\n"+addendum.Content)
+
+		pdf.SetFont("Arial", "I", 8)
+		if addendum.IsSigned {
+			signedAt := ""
+			if addendum.SignedAt != nil {
+				signedAt = addendum.SignedAt.Format("02/01/2006 15:04:05")
+			}
+			pdf.SetFillColor(230, 255, 230)
+			pdf.MultiCell(180, 4, tr(fmt.Sprintf("Assinado digitalmente por %s (CRO: %s) em %s - Hash SHA-256: %s",
+				addendum.SignedByName, addendum.SignedByCRO, signedAt, addendum.SignatureHash)), "LRB", "L", true)
+		} else {
+			pdf.MultiCell(180, 4, tr("Adendo nao assinado digitalmente"), "LRB", "L", false)
+		}
+	}
+}
+
 // checkPageBreak checks if there's enough space for content, adds new page if needed
 func checkPageBreak(pdf *gofpdf.Fpdf, minSpace float64) {
 	_, pageHeight := pdf.GetPageSize()
@@ -213,114 +269,3 @@ func getTypeLabel(typeValue string) string {
 	}
 	return typeValue
 }
-
-type ToothData struct {
-	Status     string   `json:"status"`
-	Procedures []string `json:"procedures"`
-}
-
-func renderOdontogram(pdf *gofpdf.Fpdf, tr func(string) string, odontogramJSON string) {
-	// Parse odontogram JSON
-	var odontogram map[string]ToothData
-	if err := json.Unmarshal([]byte(odontogramJSON), &odontogram); err != nil {
-		return // Skip rendering if JSON is invalid
-	}
-
-	// Skip if odontogram is empty
-	if len(odontogram) == 0 {
-		return
-	}
-
-	// Check for page break before odontogram section
-	checkPageBreak(pdf, 50)
-
-	pdf.Ln(5)
-	pdf.SetFont("Arial", "B", 11)
-	pdf.CellFormat(180, 7, tr("Odontograma"), "1", 0, "L", true, 0, "")
-	pdf.Ln(-1)
-
-	// Status labels mapping
-	statusLabels := map[string]string{
-		"healthy":     "Saudavel",
-		"cavity":      "Carie",
-		"restoration": "Restauracao",
-		"missing":     "Ausente",
-		"root_canal":  "Canal",
-		"crown":       "Coroa",
-		"implant":     "Implante",
-	}
-
-	// Status symbols for PDF
-	statusSymbols := map[string]string{
-		"healthy":     "[OK]",
-		"cavity":      "[!!]",
-		"restoration": "[R]",
-		"missing":     "[X]",
-		"root_canal":  "[C]",
-		"crown":       "[CR]",
-		"implant":     "[I]",
-	}
-
-	// Define tooth quadrants (FDI notation)
-	quadrants := []struct {
-		name  string
-		teeth []string
-	}{
-		{"Superior Direito", []string{"18", "17", "16", "15", "14", "13", "12", "11"}},
-		{"Superior Esquerdo", []string{"21", "22", "23", "24", "25", "26", "27", "28"}},
-		{"Inferior Direito", []string{"48", "47", "46", "45", "44", "43", "42", "41"}},
-		{"Inferior Esquerdo", []string{"31", "32", "33", "34", "35", "36", "37", "38"}},
-	}
-
-	pdf.SetFont("Arial", "", 8)
-
-	for _, quadrant := range quadrants {
-		hasData := false
-		// Check if this quadrant has any data
-		for _, toothNum := range quadrant.teeth {
-			if _, exists := odontogram[toothNum]; exists {
-				hasData = true
-				break
-			}
-		}
-
-		// Skip quadrant if no data
-		if !hasData {
-			continue
-		}
-
-		// Render quadrant name
-		pdf.SetFont("Arial", "B", 9)
-		pdf.CellFormat(180, 5, tr(quadrant.name+":"), "LTR", 0, "L", false, 0, "")
-		pdf.Ln(-1)
-
-		pdf.SetFont("Arial", "", 8)
-		// Render each tooth in the quadrant
-		for _, toothNum := range quadrant.teeth {
-			if toothData, exists := odontogram[toothNum]; exists {
-				statusLabel := statusLabels[toothData.Status]
-				if statusLabel == "" {
-					statusLabel = toothData.Status
-				}
-				symbol := statusSymbols[toothData.Status]
-				if symbol == "" {
-					symbol = "[?]"
-				}
-
-				text := fmt.Sprintf("  Dente %s %s: %s", toothNum, symbol, statusLabel)
-				pdf.CellFormat(180, 4, tr(text), "LR", 0, "L", false, 0, "")
-				pdf.Ln(-1)
-			}
-		}
-	}
-
-	// Close the odontogram box
-	pdf.CellFormat(180, 0, "", "LBR", 0, "L", false, 0, "")
-	pdf.Ln(-1)
-
-	// Add legend
-	pdf.Ln(2)
-	pdf.SetFont("Arial", "I", 7)
-	pdf.CellFormat(180, 4, tr("Legenda: [OK]=Saudavel [!!]=Carie [R]=Restauracao [X]=Ausente [C]=Canal [CR]=Coroa [I]=Implante"), "0", 0, "L", false, 0, "")
-	pdf.Ln(-1)
-}
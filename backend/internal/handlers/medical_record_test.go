@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"drcrwell/backend/internal/models"
+)
+
+func TestChangedMedicalRecordFields(t *testing.T) {
+	appointmentID := uint(3)
+	odontogram := `{"16": {"status": "cavity"}}`
+	before := models.MedicalRecord{
+		PatientID:     1,
+		DentistID:     2,
+		AppointmentID: &appointmentID,
+		Type:          "treatment",
+		Odontogram:    &odontogram,
+		Diagnosis:     "Cárie no 16",
+		Notes:         "Retorno em 15 dias",
+	}
+
+	same := before
+	sameOdontogram := odontogram
+	same.Odontogram = &sameOdontogram
+	if changed := changedMedicalRecordFields(medicalRecordContentOf(before), medicalRecordContentOf(same)); len(changed) != 0 {
+		t.Errorf("Expected no changes, got %v", changed)
+	}
+
+	after := before
+	after.AppointmentID = nil
+	after.Diagnosis = "Cárie no 16 e 17"
+	after.Evolution = "Restauração realizada"
+	changed := changedMedicalRecordFields(medicalRecordContentOf(before), medicalRecordContentOf(after))
+	expected := []string{"appointment_id", "diagnosis", "evolution"}
+	if !reflect.DeepEqual(changed, expected) {
+		t.Errorf("Expected %v, got %v", expected, changed)
+	}
+}
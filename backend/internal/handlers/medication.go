@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// splitMedicationList splits a CSV cell with "|" separated values
+func splitMedicationList(value string) models.StringList {
+	list := models.StringList{}
+	for _, item := range strings.Split(value, "|") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// validateMedication normalizes a catalog entry and returns an error message when it is invalid
+func validateMedication(medication *models.Medication) string {
+	medication.Name = strings.TrimSpace(medication.Name)
+	medication.ActiveIngredient = strings.TrimSpace(medication.ActiveIngredient)
+	medication.TherapeuticClass = strings.TrimSpace(medication.TherapeuticClass)
+	medication.AllergyGroup = strings.TrimSpace(medication.AllergyGroup)
+	medication.ControlledClass = strings.ToUpper(strings.TrimSpace(medication.ControlledClass))
+	medication.Presentations = splitMedicationList(strings.Join(medication.Presentations, "|"))
+	medication.DentalDosages = splitMedicationList(strings.Join(medication.DentalDosages, "|"))
+
+	if medication.ActiveIngredient == "" {
+		return "Princípio ativo é obrigatório"
+	}
+	if medication.Name == "" {
+		medication.Name = medication.ActiveIngredient
+	}
+	if !helpers.IsKnownDrugClass(medication.TherapeuticClass) {
+		return fmt.Sprintf("Classe terapêutica inválida: %s", medication.TherapeuticClass)
+	}
+	if !models.IsValidControlledClass(medication.ControlledClass) {
+		return fmt.Sprintf("Lista de controle inválida: %s (use A1-A3, B1-B2 ou C1-C5 da Portaria 344)", medication.ControlledClass)
+	}
+	return ""
+}
+
+// CreateMedication adds an entry to the medication catalog
+// POST /medications
+func CreateMedication(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var medication models.Medication
+	if err := c.ShouldBindJSON(&medication); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	medication.ID = 0
+	medication.Active = true
+	if msg := validateMedication(&medication); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := db.Session(&gorm.Session{NewDB: true}).Create(&medication).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao cadastrar medicamento"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"medication": medication})
+}
+
+// GetMedications searches the medication catalog
+// GET /medications?search=&active=true&controlled=true
+func GetMedications(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	offset := (page - 1) * pageSize
+
+	query := db.Session(&gorm.Session{NewDB: true}).Model(&models.Medication{})
+	if search := strings.TrimSpace(c.Query("search")); search != "" {
+		query = query.Where("name ILIKE ? OR active_ingredient ILIKE ?", "%"+search+"%", "%"+search+"%")
+	}
+	if active := c.Query("active"); active == "true" {
+		query = query.Where("active = ?", true)
+	} else if active == "false" {
+		query = query.Where("active = ?", false)
+	}
+	if controlled := c.Query("controlled"); controlled == "true" {
+		query = query.Where("controlled_class <> ''")
+	} else if controlled == "false" {
+		query = query.Where("controlled_class = '' OR controlled_class IS NULL")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var medications []models.Medication
+	if err := query.Offset(offset).Limit(pageSize).Order("name ASC").Find(&medications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar medicamentos"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"medications": medications,
+		"total":       total,
+		"page":        page,
+		"page_size":   pageSize,
+	})
+}
+
+// GetMedicationClasses lists the therapeutic classes known by the interaction check
+// GET /medications/classes
+func GetMedicationClasses(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"classes": helpers.DrugClasses()})
+}
+
+// GetMedication returns a catalog entry
+// GET /medications/:id
+func GetMedication(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var medication models.Medication
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&medication, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Medicamento não encontrado"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"medication": medication})
+}
+
+// UpdateMedication updates a catalog entry
+// Prescriptions keep a copy of the medication data, so they are not affected
+// PUT /medications/:id
+func UpdateMedication(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var existing models.Medication
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&existing, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Medicamento não encontrado"})
+		return
+	}
+
+	// Fields missing from the payload keep their current values
+	medication := existing
+	if err := c.ShouldBindJSON(&medication); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := validateMedication(&medication); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := db.Session(&gorm.Session{NewDB: true}).Exec(`
+		UPDATE medications
+		SET name = ?, active_ingredient = ?, therapeutic_class = ?, allergy_group = ?, presentations = ?,
+		    dental_dosages = ?, controlled_class = ?, active = ?, updated_at = ?
+		WHERE id = ?
+	`, medication.Name, medication.ActiveIngredient, medication.TherapeuticClass, medication.AllergyGroup, medication.Presentations,
+		medication.DentalDosages, medication.ControlledClass, medication.Active, time.Now(), existing.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar medicamento"})
+		return
+	}
+
+	db.Session(&gorm.Session{NewDB: true}).First(&existing, existing.ID)
+	c.JSON(http.StatusOK, gin.H{"medication": existing})
+}
+
+// DeleteMedication removes an entry from the catalog
+// DELETE /medications/:id
+func DeleteMedication(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var medication models.Medication
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&medication, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Medicamento não encontrado"})
+		return
+	}
+
+	if err := db.Session(&gorm.Session{NewDB: true}).Delete(&models.Medication{}, medication.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao remover medicamento"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Medicamento removido com sucesso"})
+}
+
+// ImportMedicationsCSV imports the medication catalog from a CSV file
+// Columns: nome, principio_ativo, classe_terapeutica, grupo_alergia, apresentacoes, posologias, lista_controle
+// Presentations and dosages are separated by "|". Entries with the same name and active ingredient are updated
+// POST /medications/import/csv
+func ImportMedicationsCSV(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Arquivo é obrigatório"})
+		return
+	}
+	defer file.Close()
+
+	if !strings.HasSuffix(strings.ToLower(header.Filename), ".csv") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Apenas arquivos CSV são permitidos"})
+		return
+	}
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Erro ao ler arquivo CSV"})
+		return
+	}
+	if len(records) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "O CSV deve conter cabeçalho e ao menos uma linha"})
+		return
+	}
+
+	imported, updated := 0, 0
+	errors := []string{}
+	column := func(record []string, i int) string {
+		if i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	for i, record := range records[1:] {
+		lineNum := i + 2
+
+		medication := models.Medication{
+			Name:             column(record, 0),
+			ActiveIngredient: column(record, 1),
+			TherapeuticClass: column(record, 2),
+			AllergyGroup:     column(record, 3),
+			Presentations:    splitMedicationList(column(record, 4)),
+			DentalDosages:    splitMedicationList(column(record, 5)),
+			ControlledClass:  column(record, 6),
+			Active:           true,
+		}
+		if msg := validateMedication(&medication); msg != "" {
+			errors = append(errors, fmt.Sprintf("Linha %d: %s", lineNum, msg))
+			continue
+		}
+
+		var existing models.Medication
+		found := db.Session(&gorm.Session{NewDB: true}).
+			Where("LOWER(name) = LOWER(?) AND LOWER(active_ingredient) = LOWER(?)", medication.Name, medication.ActiveIngredient).
+			First(&existing).Error == nil
+
+		if found {
+			if err := db.Session(&gorm.Session{NewDB: true}).Exec(`
+				UPDATE medications
+				SET therapeutic_class = ?, allergy_group = ?, presentations = ?, dental_dosages = ?,
+				    controlled_class = ?, active = true, updated_at = ?
+				WHERE id = ?
+			`, medication.TherapeuticClass, medication.AllergyGroup, medication.Presentations, medication.DentalDosages,
+				medication.ControlledClass, time.Now(), existing.ID).Error; err != nil {
+				errors = append(errors, fmt.Sprintf("Linha %d: Erro ao atualizar medicamento - %v", lineNum, err))
+				continue
+			}
+			updated++
+			continue
+		}
+
+		if err := db.Session(&gorm.Session{NewDB: true}).Create(&medication).Error; err != nil {
+			errors = append(errors, fmt.Sprintf("Linha %d: Erro ao cadastrar medicamento - %v", lineNum, err))
+			continue
+		}
+		imported++
+	}
+
+	helpers.AuditAction(c, "import", "medications", 0, true, map[string]interface{}{
+		"imported": imported,
+		"updated":  updated,
+		"errors":   len(errors),
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  fmt.Sprintf("Importação concluída: %d medicamentos importados, %d atualizados", imported, updated),
+		"imported": imported,
+		"updated":  updated,
+		"errors":   errors,
+	})
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// noShowSelfBookingBlockedMessage is shown to patients who can no longer book by themselves
+const noShowSelfBookingBlockedMessage = "Não é possível concluir o agendamento por aqui. Por favor, entre em contato com a clínica para agendar sua consulta."
+
+// PatientAttendanceStats summarizes how a patient attends the appointments
+type PatientAttendanceStats struct {
+	PatientID      uint       `json:"patient_id"`
+	Total          int64      `json:"total"`
+	Completed      int64      `json:"completed"`
+	NoShow         int64      `json:"no_show"`
+	Cancelled      int64      `json:"cancelled"`
+	AttendanceRate float64    `json:"attendance_rate"` // completed / (completed + no_show), in %
+	LastNoShowAt   *time.Time `json:"last_no_show_at"`
+	RecentNoShows  int64      `json:"recent_no_shows"` // Inside the no-show policy window
+}
+
+// loadPatientAttendanceStats counts the patient's appointments by outcome
+// Only appointments that already happened count as no-shows within the last windowMonths months
+func loadPatientAttendanceStats(db *gorm.DB, patientID uint, windowMonths int) (PatientAttendanceStats, error) {
+	stats := PatientAttendanceStats{PatientID: patientID}
+	err := db.Session(&gorm.Session{NewDB: true}).Raw(`
+		SELECT COUNT(*) as total,
+			COUNT(*) FILTER (WHERE status = 'completed') as completed,
+			COUNT(*) FILTER (WHERE status = 'no_show') as no_show,
+			COUNT(*) FILTER (WHERE status = 'cancelled') as cancelled,
+			MAX(start_time) FILTER (WHERE status = 'no_show') as last_no_show_at,
+			COUNT(*) FILTER (WHERE status = 'no_show' AND start_time >= ?) as recent_no_shows
+		FROM appointments
+		WHERE patient_id = ? AND deleted_at IS NULL
+	`, time.Now().AddDate(0, -windowMonths, 0), patientID).Scan(&stats).Error
+	stats.PatientID = patientID
+
+	if attended := stats.Completed + stats.NoShow; attended > 0 {
+		stats.AttendanceRate = math.Round(float64(stats.Completed)/float64(attended)*1000) / 10
+	}
+	return stats, err
+}
+
+// loadNoShowSettings reads the tenant settings that hold the no-show policy
+func loadNoShowSettings(db *gorm.DB, tenantID uint) models.TenantSettings {
+	var settings models.TenantSettings
+	db.Session(&gorm.Session{NewDB: true}).Table("public.tenant_settings").Where("tenant_id = ?", tenantID).First(&settings)
+	return settings
+}
+
+// patientNoShowRestriction evaluates the tenant no-show policy for a patient
+func patientNoShowRestriction(db *gorm.DB, tenantID, patientID uint) (models.NoShowRestriction, error) {
+	settings := loadNoShowSettings(db, tenantID)
+	if !settings.NoShowPolicyEnabled {
+		return settings.EvaluateNoShowPolicy(0), nil
+	}
+	_, months := settings.NoShowPolicyWindow()
+	stats, err := loadPatientAttendanceStats(db, patientID, months)
+	if err != nil {
+		return models.NoShowRestriction{}, err
+	}
+	return settings.EvaluateNoShowPolicy(stats.RecentNoShows), nil
+}
+
+// applyNoShowRestriction flags a self-booked appointment with what the policy requires
+func applyNoShowRestriction(appointment *models.Appointment, restriction models.NoShowRestriction) {
+	if !restriction.Restricted {
+		return
+	}
+	appointment.StaffConfirmationRequired = restriction.RequireConfirmation
+	appointment.DepositRequired = restriction.RequireDeposit
+	appointment.DepositAmount = restriction.DepositAmount
+
+	var pending []string
+	if restriction.RequireConfirmation {
+		pending = append(pending, "confirmação da clínica")
+	}
+	if restriction.RequireDeposit {
+		pending = append(pending, "pagamento de sinal")
+	}
+	if len(pending) > 0 {
+		if appointment.Notes != "" {
+			appointment.Notes += "\n"
+		}
+		appointment.Notes += fmt.Sprintf("[Política de faltas: %d faltas nos últimos %d meses - aguardando %s]",
+			restriction.RecentNoShows, restriction.WindowMonths, strings.Join(pending, " e "))
+	}
+}
+
+// noShowBookingNotice tells the patient what is still needed for a flagged booking
+// Empty when the booking is not restricted
+func noShowBookingNotice(restriction models.NoShowRestriction) string {
+	var notices []string
+	if restriction.RequireConfirmation {
+		notices = append(notices, "Seu agendamento será confirmado pela clínica.")
+	}
+	if restriction.RequireDeposit {
+		if restriction.DepositAmount > 0 {
+			notices = append(notices, fmt.Sprintf("É necessário o pagamento de um sinal de R$ %.2f para garantir o horário.", restriction.DepositAmount))
+		} else {
+			notices = append(notices, "É necessário o pagamento de um sinal para garantir o horário.")
+		}
+	}
+	return strings.Join(notices, " ")
+}
+
+// GetPatientAttendance returns the attendance statistics of a patient and the no-show policy restrictions in effect
+// GET /patients/:id/attendance
+func GetPatientAttendance(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var patient models.Patient
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&patient, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Paciente não encontrado"})
+		return
+	}
+
+	settings := loadNoShowSettings(db, c.GetUint("tenant_id"))
+	_, months := settings.NoShowPolicyWindow()
+
+	stats, err := loadPatientAttendanceStats(db, patient.ID, months)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao calcular estatísticas de comparecimento"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"stats":       stats,
+		"restriction": settings.EvaluateNoShowPolicy(stats.RecentNoShows),
+	})
+}
+
+// noShowBreakdownRow is the no-show rate of a dentist, weekday or hour of the day
+type noShowBreakdownRow struct {
+	Key        int     `json:"key"`
+	Label      string  `json:"label"`
+	Completed  int64   `json:"completed"`
+	NoShow     int64   `json:"no_show"`
+	NoShowRate float64 `json:"no_show_rate"` // no_show / (completed + no_show), in %
+}
+
+// Portuguese weekday names indexed by PostgreSQL DOW (0 = Sunday)
+var noShowWeekdayLabels = []string{"Domingo", "Segunda", "Terça", "Quarta", "Quinta", "Sexta", "Sábado"}
+
+// noShowBreakdown groups completed and no-show appointments of the period by dentist, weekday or hour
+func noShowBreakdown(db *gorm.DB, groupBy, startDate, endDate string) []noShowBreakdownRow {
+	var keyExpr, labelExpr string
+	switch groupBy {
+	case "dentist":
+		keyExpr, labelExpr = "a.dentist_id", "COALESCE(MAX(u.name), '')"
+	case "weekday":
+		keyExpr, labelExpr = "EXTRACT(DOW FROM a.start_time)::int", "''"
+	default:
+		keyExpr, labelExpr = "EXTRACT(HOUR FROM a.start_time)::int", "''"
+	}
+
+	query := db.Session(&gorm.Session{NewDB: true}).
+		Table("appointments a").
+		Select(fmt.Sprintf(`%s as key, %s as label,
+			COUNT(*) FILTER (WHERE a.status = 'completed') as completed,
+			COUNT(*) FILTER (WHERE a.status = 'no_show') as no_show`, keyExpr, labelExpr)).
+		Joins("LEFT JOIN public.users u ON a.dentist_id = u.id").
+		Where("a.deleted_at IS NULL AND a.status IN ?", []string{"completed", "no_show"})
+	if startDate != "" {
+		query = query.Where("DATE(a.start_time) >= ?", startDate)
+	}
+	if endDate != "" {
+		query = query.Where("DATE(a.start_time) <= ?", endDate)
+	}
+
+	rows := []noShowBreakdownRow{}
+	query.Group("key").Order("key").Scan(&rows)
+
+	for i := range rows {
+		row := &rows[i]
+		switch groupBy {
+		case "weekday":
+			if row.Key >= 0 && row.Key < len(noShowWeekdayLabels) {
+				row.Label = noShowWeekdayLabels[row.Key]
+			}
+		case "hour":
+			row.Label = fmt.Sprintf("%02dh", row.Key)
+		}
+		if attended := row.Completed + row.NoShow; attended > 0 {
+			row.NoShowRate = math.Round(float64(row.NoShow)/float64(attended)*1000) / 10
+		}
+	}
+	return rows
+}
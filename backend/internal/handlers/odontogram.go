@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/availability"
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ToothEventRequest is one finding or planned procedure on a tooth
+type ToothEventRequest struct {
+	Tooth      int        `json:"tooth" binding:"required"`
+	Surfaces   string     `json:"surfaces"` // e.g. "MOD"; empty for the whole tooth
+	Condition  string     `json:"condition" binding:"required"`
+	State      string     `json:"state"` // existing (default) or planned
+	Notes      string     `json:"notes"`
+	RecordedAt *time.Time `json:"recorded_at"` // Defaults to now
+}
+
+// CreateToothEventsRequest is the payload to chart several teeth at once
+type CreateToothEventsRequest struct {
+	MedicalRecordID *uint               `json:"medical_record_id"`
+	Events          []ToothEventRequest `json:"events" binding:"required,min=1,dive"`
+}
+
+// toToothEvent validates the request and builds the event
+// Returns an error message (in Portuguese) when the event is invalid
+func (req ToothEventRequest) toToothEvent() (models.ToothEvent, string) {
+	var event models.ToothEvent
+
+	if !helpers.IsValidFDITooth(req.Tooth) {
+		return event, fmt.Sprintf("Dente inválido: %d (use a notação FDI, ex: 16, 21, 55)", req.Tooth)
+	}
+	surfaces, ok := helpers.NormalizeToothSurfaces(req.Surfaces)
+	if !ok {
+		return event, fmt.Sprintf("Faces inválidas no dente %d: %s (use M, D, O, V, L)", req.Tooth, req.Surfaces)
+	}
+	if !models.IsValidToothCondition(req.Condition) {
+		return event, fmt.Sprintf("Condição inválida no dente %d: %s", req.Tooth, req.Condition)
+	}
+	if surfaces != "" && models.IsWholeToothCondition(req.Condition) {
+		return event, fmt.Sprintf("A condição '%s' se aplica ao dente inteiro, sem faces", models.ToothConditionLabels[req.Condition])
+	}
+
+	state := req.State
+	if state == "" {
+		state = models.ToothStateExisting
+	}
+	if state != models.ToothStateExisting && state != models.ToothStatePlanned {
+		return event, "Estado inválido. Use existing ou planned"
+	}
+	if state == models.ToothStatePlanned && req.Condition == models.ToothConditionHealthy {
+		return event, "Não é possível planejar a condição 'Hígido'"
+	}
+
+	recordedAt := time.Now()
+	if req.RecordedAt != nil {
+		if req.RecordedAt.After(recordedAt) {
+			return event, "A data do registro não pode estar no futuro"
+		}
+		recordedAt = *req.RecordedAt
+	}
+
+	event = models.ToothEvent{
+		Tooth:      req.Tooth,
+		Surfaces:   surfaces,
+		Condition:  req.Condition,
+		State:      state,
+		Notes:      req.Notes,
+		RecordedAt: recordedAt,
+	}
+	return event, ""
+}
+
+// loadPatientToothEvents returns every tooth event of a patient, including the ones
+// read from the legacy odontogram JSON of the medical records
+func loadPatientToothEvents(db *gorm.DB, patientID uint) ([]models.ToothEvent, error) {
+	var events []models.ToothEvent
+	if err := db.Session(&gorm.Session{NewDB: true}).
+		Where("patient_id = ?", patientID).
+		Order("recorded_at ASC, id ASC").
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+
+	var records []models.MedicalRecord
+	if err := db.Session(&gorm.Session{NewDB: true}).
+		Select("id, patient_id, dentist_id, odontogram, created_at").
+		Where("patient_id = ? AND odontogram IS NOT NULL", patientID).
+		Find(&records).Error; err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		events = append(events, helpers.LegacyOdontogramEvents(record)...)
+	}
+	return events, nil
+}
+
+// odontogramPatient loads the patient of the :patient_id route parameter
+func odontogramPatient(c *gin.Context, db *gorm.DB) (models.Patient, bool) {
+	var patient models.Patient
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&patient, c.Param("patient_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Paciente não encontrado"})
+		return patient, false
+	}
+	return patient, true
+}
+
+// GetPatientOdontogram returns the patient's chart: the union of every record and tooth event
+// ?date=YYYY-MM-DD returns the chart as it was at the end of that day
+// GET /odontogram/patients/:patient_id
+func GetPatientOdontogram(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	patient, ok := odontogramPatient(c, db)
+	if !ok {
+		return
+	}
+
+	asOf := time.Now()
+	if date := c.Query("date"); date != "" {
+		day, err := time.ParseInLocation("2006-01-02", date, availability.Location())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Formato de data inválido. Use YYYY-MM-DD"})
+			return
+		}
+		asOf = day.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+
+	events, err := loadPatientToothEvents(db, patient.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar odontograma"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"patient_id": patient.ID,
+		"as_of":      asOf,
+		"teeth":      helpers.BuildOdontogram(events, asOf),
+	})
+}
+
+// GetToothHistory returns the events of the patient's teeth, oldest first
+// ?tooth=16 restricts the history to one tooth
+// GET /odontogram/patients/:patient_id/history
+func GetToothHistory(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	patient, ok := odontogramPatient(c, db)
+	if !ok {
+		return
+	}
+
+	tooth := 0
+	if t := c.Query("tooth"); t != "" {
+		parsed, err := strconv.Atoi(t)
+		if err != nil || !helpers.IsValidFDITooth(parsed) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Dente inválido (use a notação FDI, ex: 16, 21, 55)"})
+			return
+		}
+		tooth = parsed
+	}
+
+	events, err := loadPatientToothEvents(db, patient.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar histórico do odontograma"})
+		return
+	}
+
+	history := make([]models.ToothEvent, 0, len(events))
+	for _, event := range events {
+		if tooth == 0 || event.Tooth == tooth {
+			history = append(history, event)
+		}
+	}
+	// Legacy events come last from the loader; keep the history chronological
+	sortToothEvents(history)
+
+	c.JSON(http.StatusOK, gin.H{
+		"patient_id": patient.ID,
+		"tooth":      tooth,
+		"events":     history,
+	})
+}
+
+// sortToothEvents orders events by date (then by ID, for events recorded together)
+func sortToothEvents(events []models.ToothEvent) {
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].RecordedAt.Equal(events[j].RecordedAt) {
+			return events[i].RecordedAt.Before(events[j].RecordedAt)
+		}
+		return events[i].ID < events[j].ID
+	})
+}
+
+// CreateToothEvents charts findings or planned procedures on the patient's teeth
+// POST /odontogram/patients/:patient_id/events
+func CreateToothEvents(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	patient, ok := odontogramPatient(c, db)
+	if !ok {
+		return
+	}
+
+	var req CreateToothEventsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.MedicalRecordID != nil {
+		var record models.MedicalRecord
+		if err := db.Session(&gorm.Session{NewDB: true}).
+			Where("id = ? AND patient_id = ?", *req.MedicalRecordID, patient.ID).
+			First(&record).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Prontuário não encontrado para este paciente"})
+			return
+		}
+		if record.IsSigned {
+			c.JSON(http.StatusConflict, gin.H{"error": medicalRecordLockedMessage})
+			return
+		}
+	}
+
+	events := make([]models.ToothEvent, 0, len(req.Events))
+	for _, item := range req.Events {
+		event, msg := item.toToothEvent()
+		if msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		event.PatientID = patient.ID
+		event.MedicalRecordID = req.MedicalRecordID
+		event.DentistID = c.GetUint("user_id")
+		events = append(events, event)
+	}
+
+	if err := db.Session(&gorm.Session{NewDB: true}).Create(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao registrar odontograma"})
+		return
+	}
+
+	helpers.AuditAction(c, "create", "tooth_events", patient.ID, true, map[string]interface{}{
+		"patient_id":        patient.ID,
+		"medical_record_id": req.MedicalRecordID,
+		"events":            len(events),
+	})
+
+	c.JSON(http.StatusCreated, gin.H{"events": events})
+}
+
+// DeleteToothEvent removes an event charted by mistake (the chart is rebuilt without it)
+// DELETE /odontogram/events/:id
+func DeleteToothEvent(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var event models.ToothEvent
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&event, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Registro do odontograma não encontrado"})
+		return
+	}
+
+	// Events charted in a signed record are part of it
+	if event.MedicalRecordID != nil {
+		var signed int64
+		db.Session(&gorm.Session{NewDB: true}).Model(&models.MedicalRecord{}).
+			Where("id = ? AND is_signed = true", *event.MedicalRecordID).Count(&signed)
+		if signed > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": medicalRecordLockedMessage})
+			return
+		}
+	}
+
+	if err := db.Session(&gorm.Session{NewDB: true}).Delete(&models.ToothEvent{}, event.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao remover registro do odontograma"})
+		return
+	}
+
+	helpers.AuditAction(c, "delete", "tooth_events", event.ID, true, map[string]interface{}{
+		"patient_id": event.PatientID,
+		"tooth":      event.Tooth,
+		"condition":  event.Condition,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Registro do odontograma removido com sucesso"})
+}
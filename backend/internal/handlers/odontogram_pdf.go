@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/models"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Odontogram chart geometry (mm)
+const (
+	odontogramToothSize   = 9.0
+	odontogramToothGap    = 1.5
+	odontogramMidlineGap  = 3.0
+	odontogramNumberSpace = 3.5
+	odontogramRowGap      = 2.0
+)
+
+// odontogramColors are the fill colors of the conditions, the same used by the chart in the web app
+var odontogramColors = map[string][3]int{
+	models.ToothConditionCavity:      {255, 213, 79},
+	models.ToothConditionRestoration: {100, 181, 246},
+	models.ToothConditionMissing:     {229, 115, 115},
+	models.ToothConditionRootCanal:   {179, 157, 219},
+	models.ToothConditionCrown:       {128, 222, 234},
+	models.ToothConditionImplant:     {121, 134, 203},
+	models.ToothConditionExtraction:  {239, 83, 80},
+	models.ToothConditionSealant:     {165, 214, 167},
+	models.ToothConditionFracture:    {255, 138, 101},
+}
+
+// odontogramLegend is the order conditions are listed in the legend
+var odontogramLegend = []string{
+	models.ToothConditionCavity,
+	models.ToothConditionRestoration,
+	models.ToothConditionRootCanal,
+	models.ToothConditionCrown,
+	models.ToothConditionImplant,
+	models.ToothConditionSealant,
+	models.ToothConditionFracture,
+	models.ToothConditionMissing,
+}
+
+// renderOdontogram draws the patient's chart: one box per tooth split in five surfaces,
+// filled with the existing conditions and outlined in red where a procedure is planned
+func renderOdontogram(pdf *gofpdf.Fpdf, tr func(string) string, chart []helpers.OdontogramTooth) {
+	if len(chart) == 0 {
+		return
+	}
+
+	teeth := make(map[int]helpers.OdontogramTooth, len(chart))
+	hasDeciduous := false
+	for _, tooth := range chart {
+		teeth[tooth.Tooth] = tooth
+		if tooth.Dentition == helpers.DentitionDeciduous {
+			hasDeciduous = true
+		}
+	}
+
+	rows := [][]int{helpers.PermanentUpperTeeth}
+	if hasDeciduous {
+		rows = append(rows, helpers.DeciduousUpperTeeth, helpers.DeciduousLowerTeeth)
+	}
+	rows = append(rows, helpers.PermanentLowerTeeth)
+
+	// Check for page break before odontogram section
+	checkPageBreak(pdf, 80)
+
+	pdf.Ln(5)
+	pdf.SetFont("Arial", "B", 11)
+	pdf.SetFillColor(240, 240, 240)
+	pdf.CellFormat(180, 7, tr("Odontograma"), "1", 0, "L", true, 0, "")
+	pdf.Ln(-1)
+
+	left, _, _, _ := pdf.GetMargins()
+	y := pdf.GetY() + 2
+	pdf.SetFont("Arial", "", 6)
+
+	for _, row := range rows {
+		upper := helpers.IsUpperTooth(row[0])
+		width := float64(len(row))*odontogramToothSize + float64(len(row)-2)*odontogramToothGap + odontogramMidlineGap
+		x := left + (180-width)/2
+
+		boxY := y
+		if upper {
+			boxY += odontogramNumberSpace
+		}
+
+		for i, number := range row {
+			if i == len(row)/2 {
+				x += odontogramMidlineGap - odontogramToothGap
+			}
+			drawOdontogramTooth(pdf, x, boxY, number, teeth[number])
+
+			numberY := boxY + odontogramToothSize
+			if upper {
+				numberY = y
+			}
+			pdf.SetTextColor(0, 0, 0)
+			pdf.SetXY(x, numberY)
+			pdf.CellFormat(odontogramToothSize, odontogramNumberSpace, fmt.Sprintf("%d", number), "", 0, "C", false, 0, "")
+
+			x += odontogramToothSize + odontogramToothGap
+		}
+		y += odontogramToothSize + odontogramNumberSpace + odontogramRowGap
+	}
+
+	// Legend
+	pdf.SetXY(left, y)
+	x := left
+	for _, condition := range odontogramLegend {
+		color := odontogramColors[condition]
+		pdf.SetDrawColor(0, 0, 0)
+		pdf.SetFillColor(color[0], color[1], color[2])
+		pdf.Rect(x, y+0.5, 3, 3, "FD")
+		pdf.SetXY(x+3.5, y)
+		pdf.CellFormat(16.5, 4, tr(models.ToothConditionLabels[condition]), "", 0, "L", false, 0, "")
+		x += 20
+	}
+	pdf.SetDrawColor(211, 47, 47)
+	pdf.SetLineWidth(0.5)
+	pdf.Rect(x, y+0.5, 3, 3, "D")
+	pdf.SetLineWidth(0.2)
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.SetXY(x+3.5, y)
+	pdf.CellFormat(16.5, 4, tr("Planejado"), "", 0, "L", false, 0, "")
+	pdf.SetY(y + 6)
+
+	// Text summary, one line per charted tooth
+	pdf.SetFillColor(240, 240, 240)
+	pdf.SetFont("Arial", "", 8)
+	for _, tooth := range chart {
+		text := fmt.Sprintf("Dente %d: %s", tooth.Tooth, odontogramMarksSummary(tooth.Existing))
+		if len(tooth.Planned) > 0 {
+			text += " | Planejado: " + odontogramMarksSummary(tooth.Planned)
+		}
+		pdf.CellFormat(180, 4, tr(text), "LR", 0, "L", false, 0, "")
+		pdf.Ln(-1)
+	}
+
+	// Close the odontogram box
+	pdf.CellFormat(180, 0, "", "LBR", 0, "L", false, 0, "")
+	pdf.Ln(-1)
+}
+
+// drawOdontogramTooth draws one tooth box at (x, y): four trapezoids around a center square
+func drawOdontogramTooth(pdf *gofpdf.Fpdf, x, y float64, number int, tooth helpers.OdontogramTooth) {
+	s := odontogramToothSize
+	inner, outer := 0.3*s, 0.7*s
+
+	// Surfaces drawn on each side depend on the arch and on the side of the mouth
+	top, bottom := "V", "L"
+	if !helpers.IsUpperTooth(number) {
+		top, bottom = "L", "V"
+	}
+	leftSide, rightSide := "M", "D"
+	if helpers.IsPatientRightTooth(number) {
+		leftSide, rightSide = "D", "M"
+	}
+
+	polygons := map[string][]gofpdf.PointType{
+		top:       {{X: x, Y: y}, {X: x + s, Y: y}, {X: x + outer, Y: y + inner}, {X: x + inner, Y: y + inner}},
+		bottom:    {{X: x, Y: y + s}, {X: x + s, Y: y + s}, {X: x + outer, Y: y + outer}, {X: x + inner, Y: y + outer}},
+		leftSide:  {{X: x, Y: y}, {X: x + inner, Y: y + inner}, {X: x + inner, Y: y + outer}, {X: x, Y: y + s}},
+		rightSide: {{X: x + s, Y: y}, {X: x + outer, Y: y + inner}, {X: x + outer, Y: y + outer}, {X: x + s, Y: y + s}},
+		"O":       {{X: x + inner, Y: y + inner}, {X: x + outer, Y: y + inner}, {X: x + outer, Y: y + outer}, {X: x + inner, Y: y + outer}},
+	}
+
+	whole, hasWhole := tooth.Existing[helpers.OdontogramWholeTooth]
+
+	pdf.SetLineWidth(0.2)
+	pdf.SetDrawColor(0, 0, 0)
+	for _, surface := range helpers.ToothSurfaces {
+		site := string(surface)
+		pdf.SetFillColor(255, 255, 255)
+		if mark, ok := tooth.Existing[site]; ok {
+			setOdontogramFill(pdf, mark.Condition)
+		} else if hasWhole {
+			setOdontogramFill(pdf, whole.Condition)
+		}
+		pdf.Polygon(polygons[site], "FD")
+	}
+
+	if hasWhole && whole.Condition == models.ToothConditionMissing {
+		pdf.Line(x, y, x+s, y+s)
+		pdf.Line(x+s, y, x, y+s)
+	}
+
+	// Planned procedures: red outline on the planned surfaces (or around the whole tooth)
+	if len(tooth.Planned) > 0 {
+		pdf.SetDrawColor(211, 47, 47)
+		pdf.SetLineWidth(0.5)
+		for site, mark := range tooth.Planned {
+			if site == helpers.OdontogramWholeTooth {
+				pdf.Rect(x, y, s, s, "D")
+				if mark.Condition == models.ToothConditionExtraction {
+					pdf.Line(x, y, x+s, y+s)
+					pdf.Line(x+s, y, x, y+s)
+				}
+				continue
+			}
+			if points, ok := polygons[site]; ok {
+				pdf.Polygon(points, "D")
+			}
+		}
+		pdf.SetLineWidth(0.2)
+		pdf.SetDrawColor(0, 0, 0)
+	}
+}
+
+// setOdontogramFill sets the fill color of a condition (white when it has none)
+func setOdontogramFill(pdf *gofpdf.Fpdf, condition string) {
+	if color, ok := odontogramColors[condition]; ok {
+		pdf.SetFillColor(color[0], color[1], color[2])
+	}
+}
+
+// odontogramMarksSummary describes the marks of a tooth, e.g. "Cárie (MO); Coroa"
+func odontogramMarksSummary(marks map[string]helpers.OdontogramMark) string {
+	if len(marks) == 0 {
+		return "Hígido"
+	}
+
+	surfacesByCondition := make(map[string][]string)
+	for site, mark := range marks {
+		if site == helpers.OdontogramWholeTooth {
+			surfacesByCondition[mark.Condition] = append(surfacesByCondition[mark.Condition], "")
+			continue
+		}
+		surfacesByCondition[mark.Condition] = append(surfacesByCondition[mark.Condition], site)
+	}
+
+	conditions := make([]string, 0, len(surfacesByCondition))
+	for condition := range surfacesByCondition {
+		conditions = append(conditions, condition)
+	}
+	sort.Strings(conditions)
+
+	parts := make([]string, 0, len(conditions))
+	for _, condition := range conditions {
+		label := models.ToothConditionLabels[condition]
+		var surfaces strings.Builder
+		for _, surface := range helpers.ToothSurfaces {
+			for _, site := range surfacesByCondition[condition] {
+				if site == string(surface) {
+					surfaces.WriteString(site)
+				}
+			}
+		}
+		if surfaces.Len() > 0 {
+			label += " (" + surfaces.String() + ")"
+		}
+		parts = append(parts, label)
+	}
+	return strings.Join(parts, "; ")
+}
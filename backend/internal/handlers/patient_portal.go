@@ -1,16 +1,20 @@
 package handlers
 
 import (
+	"drcrwell/backend/internal/availability"
 	"drcrwell/backend/internal/database"
 	"drcrwell/backend/internal/helpers"
 	"drcrwell/backend/internal/models"
+	"drcrwell/backend/internal/scheduler"
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
 )
 
 // PatientPortalGetProfile returns the patient's own profile data
@@ -175,6 +179,17 @@ func PatientPortalCreateAppointment(c *gin.Context) {
 		return
 	}
 
+	// Patients with repeated no-shows may be restricted by the clinic policy
+	restriction, err := patientNoShowRestriction(tenantDB, tenantID.(uint), patientID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar disponibilidade"})
+		return
+	}
+	if restriction.BlockSelfBooking {
+		c.JSON(http.StatusForbidden, gin.H{"error": noShowSelfBookingBlockedMessage})
+		return
+	}
+
 	// Validate dentist exists and is active
 	var dentist models.User
 	if err := db.Where("id = ? AND tenant_id = ? AND role IN ('dentist', 'admin') AND active = true", req.DentistID, tenantID).First(&dentist).Error; err != nil {
@@ -182,18 +197,20 @@ func PatientPortalCreateAppointment(c *gin.Context) {
 		return
 	}
 
-	// Check for time conflicts with dentist's schedule
-	var conflictCount int64
-	tenantDB.Model(&models.Appointment{}).
-		Where("dentist_id = ? AND status NOT IN ('cancelled', 'no_show')", req.DentistID).
-		Where("(start_time < ? AND end_time > ?) OR (start_time < ? AND end_time > ?) OR (start_time >= ? AND end_time <= ?)",
-			req.EndTime, req.StartTime,
-			req.EndTime, req.StartTime,
-			req.StartTime, req.EndTime).
-		Count(&conflictCount)
-
-	if conflictCount > 0 {
-		c.JSON(http.StatusConflict, gin.H{"error": "Horário indisponível. Por favor, escolha outro horário."})
+	// Check clinic closures, the dentist's working schedule and absences, and conflicts
+	// with the dentist's appointments (keeping the clinic cleanup time between them)
+	hasConflict, conflictMessage, err := checkAppointmentConflict(tenantDB, tenantID.(uint), models.Appointment{
+		PatientID: patientID.(uint),
+		DentistID: req.DentistID,
+		StartTime: models.LocalTime{Time: req.StartTime},
+		EndTime:   models.LocalTime{Time: req.EndTime},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar disponibilidade"})
+		return
+	}
+	if hasConflict {
+		c.JSON(http.StatusConflict, gin.H{"error": conflictMessage})
 		return
 	}
 
@@ -208,8 +225,14 @@ func PatientPortalCreateAppointment(c *gin.Context) {
 		Status:    "scheduled",
 		Notes:     req.Notes,
 	}
+	applyNoShowRestriction(&appointment, restriction)
 
 	if err := tenantDB.Create(&appointment).Error; err != nil {
+		// Another booking took the slot after the availability check
+		if _, ok := overlapConflictMessage(err); ok {
+			c.JSON(http.StatusConflict, gin.H{"error": "Horário indisponível. Por favor, escolha outro horário."})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar agendamento"})
 		return
 	}
@@ -224,10 +247,17 @@ func PatientPortalCreateAppointment(c *gin.Context) {
 		"start_time":      req.StartTime,
 	})
 
-	c.JSON(http.StatusCreated, gin.H{
+	response := gin.H{
 		"message":     "Agendamento criado com sucesso",
+		"notice":      noShowBookingNotice(restriction),
 		"appointment": appointment,
-	})
+	}
+	// The deposit can be paid right away by PIX
+	if charge := depositPixCharge(tenantDB, tenantID.(uint), appointment); charge != nil {
+		response["pix"] = pixChargeResponse(charge)
+	}
+
+	c.JSON(http.StatusCreated, response)
 }
 
 // PatientPortalCancelAppointment cancels the patient's own appointment
@@ -254,11 +284,23 @@ func PatientPortalCancelAppointment(c *gin.Context) {
 
 	// Update status to cancelled using fresh DB connection to avoid GORM state issues
 	updateDB := database.SetSchema(db, schemaName)
-	if err := updateDB.Model(&models.Appointment{}).Where("id = ?", appointment.ID).Update("status", "cancelled").Error; err != nil {
+	err := updateDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Appointment{}).Where("id = ?", appointment.ID).Update("status", "cancelled").Error; err != nil {
+			return err
+		}
+		return recordAppointmentStatus(tx, appointment.ID, appointment.Status, models.AppointmentStatusCancelled, appointmentStatusChange{
+			Source:     models.StatusSourcePortal,
+			ReasonCode: models.CancellationReasonPatientRequest,
+		})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao cancelar agendamento"})
 		return
 	}
 
+	// Offer the freed slot to the waiting list
+	go scheduler.OfferFreedSlot(tenantID.(uint), appointment.ID)
+
 	helpers.AuditAction(c, "cancel", "appointments", appointment.ID, true, map[string]interface{}{
 		"patient_portal":   true,
 		"patient_id":       patientID,
@@ -387,7 +429,13 @@ func PatientPortalGetAvailableSlots(c *gin.Context) {
 		return
 	}
 
-	date, err := time.Parse("2006-01-02", dateStr)
+	dentistID, err := strconv.ParseUint(dentistIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "dentist_id inválido"})
+		return
+	}
+
+	date, err := time.ParseInLocation("2006-01-02", dateStr, availability.Location())
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Formato de data inválido. Use YYYY-MM-DD"})
 		return
@@ -401,98 +449,38 @@ func PatientPortalGetAvailableSlots(c *gin.Context) {
 	var settings models.TenantSettings
 	db.Where("tenant_id = ?", tenantID).First(&settings)
 
-	// Get existing appointments for the day
-	startOfDay := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
-	endOfDay := startOfDay.Add(24 * time.Hour)
-
-	var appointments []models.Appointment
-	tenantDB.Where("dentist_id = ? AND start_time >= ? AND start_time < ? AND status NOT IN ('cancelled', 'no_show')",
-		dentistIDStr, startOfDay, endOfDay).
-		Order("start_time ASC").
-		Find(&appointments)
-
-	// Generate available slots based on working hours
-	duration := settings.DefaultAppointmentDuration
-	if duration == 0 {
-		duration = 30 // default 30 minutes
-	}
+	// Duration follows the requested procedure (treatment protocol) or the clinic default
+	protocolID, _ := strconv.ParseUint(c.Query("protocol_id"), 10, 32)
+	duration := availability.ResolveDuration(tenantDB, settings, uint(protocolID), c.Query("procedure"))
 
-	// Parse working hours
-	workStart := settings.WorkingHoursStart
-	workEnd := settings.WorkingHoursEnd
-	if workStart == "" {
-		workStart = "08:00"
-	}
-	if workEnd == "" {
-		workEnd = "18:00"
+	// Free slots: dentist schedule (or clinic hours), absences, appointments, buffer time and
+	// waiting-list holds of other patients
+	opts := availability.OptionsFromSettings(settings, duration)
+	if patientID, ok := c.Get("patient_id"); ok {
+		opts.PatientID, _ = patientID.(uint)
 	}
-
-	// Build list of taken time ranges
-	takenSlots := make(map[string]bool)
-	for _, apt := range appointments {
-		takenSlots[apt.StartTime.Format("15:04")] = true
+	slots, err := availability.FindSlots(tenantDB, settings, uint(dentistID), date, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar agenda do profissional"})
+		return
 	}
 
-	// Generate available slots
-	var availableSlots []gin.H
-	startHour, startMin := parseTime(workStart)
-	endHour, endMin := parseTime(workEnd)
-	lunchStartHour, lunchStartMin := parseTime(settings.LunchBreakStart)
-	lunchEndHour, lunchEndMin := parseTime(settings.LunchBreakEnd)
-
-	current := time.Date(date.Year(), date.Month(), date.Day(), startHour, startMin, 0, 0, date.Location())
-	endTime := time.Date(date.Year(), date.Month(), date.Day(), endHour, endMin, 0, 0, date.Location())
-	lunchStart := time.Date(date.Year(), date.Month(), date.Day(), lunchStartHour, lunchStartMin, 0, 0, date.Location())
-	lunchEnd := time.Date(date.Year(), date.Month(), date.Day(), lunchEndHour, lunchEndMin, 0, 0, date.Location())
-
-	for current.Before(endTime) {
-		slotEnd := current.Add(time.Duration(duration) * time.Minute)
-		timeStr := current.Format("15:04")
-
-		// Skip if slot is taken
-		if takenSlots[timeStr] {
-			current = slotEnd
-			continue
-		}
-
-		// Skip if slot is during lunch break
-		if settings.LunchBreakEnabled && !current.Before(lunchStart) && current.Before(lunchEnd) {
-			current = slotEnd
-			continue
-		}
-
-		// Skip past times for today
-		if date.Day() == time.Now().Day() && current.Before(time.Now()) {
-			current = slotEnd
-			continue
-		}
-
+	availableSlots := make([]gin.H, 0, len(slots))
+	for _, slot := range slots {
 		availableSlots = append(availableSlots, gin.H{
-			"start_time": current.Format("15:04"),
-			"end_time":   slotEnd.Format("15:04"),
+			"start_time": slot.Start.Format("15:04"),
+			"end_time":   slot.End.Format("15:04"),
 		})
-
-		current = slotEnd
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"date":            dateStr,
 		"dentist_id":      dentistIDStr,
 		"available_slots": availableSlots,
-		"duration":        duration,
+		"duration":        int(duration.Minutes()),
 	})
 }
 
-// parseTime parses time string "HH:MM" and returns hour and minute
-func parseTime(timeStr string) (int, int) {
-	if timeStr == "" {
-		return 0, 0
-	}
-	var hour, min int
-	fmt.Sscanf(timeStr, "%d:%d", &hour, &min)
-	return hour, min
-}
-
 // ========================================
 // Admin endpoints for managing patient portal access
 // ========================================
@@ -7,8 +7,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"drcrwell/backend/internal/models"
 )
 
 func TestCreatePatient_Success(t *testing.T) {
@@ -347,3 +350,62 @@ func TestCreatePatient_NoDB(t *testing.T) {
 		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, w.Code)
 	}
 }
+
+func TestLoadPatientAttendanceStats(t *testing.T) {
+	db := setupTestDB()
+
+	patient := createTestPatient(db, "Test Patient", "11999999999")
+	user := createTestUser(db, "Dr. Test", "dr@test.com")
+
+	now := time.Now()
+	createTestAppointment(db, patient.ID, user.ID, now.AddDate(0, -1, 0), "completed")
+	createTestAppointment(db, patient.ID, user.ID, now.AddDate(0, -2, 0), "no_show")
+	createTestAppointment(db, patient.ID, user.ID, now.AddDate(0, -3, 0), "no_show")
+	createTestAppointment(db, patient.ID, user.ID, now.AddDate(-1, 0, 0), "no_show") // Outside the window
+	createTestAppointment(db, patient.ID, user.ID, now.AddDate(0, 0, 7), "cancelled")
+
+	stats, err := loadPatientAttendanceStats(db, patient.ID, 6)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if stats.Total != 5 || stats.Completed != 1 || stats.NoShow != 3 || stats.Cancelled != 1 {
+		t.Errorf("Unexpected counts: %+v", stats)
+	}
+	if stats.RecentNoShows != 2 {
+		t.Errorf("Expected 2 no-shows in the last 6 months, got %d", stats.RecentNoShows)
+	}
+	if stats.AttendanceRate != 25 {
+		t.Errorf("Expected attendance rate of 25%%, got %v", stats.AttendanceRate)
+	}
+}
+
+func TestEvaluateNoShowPolicy(t *testing.T) {
+	settings := models.TenantSettings{
+		NoShowPolicyEnabled:       true,
+		NoShowThreshold:           2,
+		NoShowWindowMonths:        6,
+		NoShowRequireConfirmation: true,
+		NoShowRequireDeposit:      true,
+		NoShowDepositAmount:       50,
+	}
+
+	if restriction := settings.EvaluateNoShowPolicy(1); restriction.Restricted {
+		t.Errorf("Expected no restriction below the threshold, got %+v", restriction)
+	}
+
+	restriction := settings.EvaluateNoShowPolicy(2)
+	if !restriction.Restricted || !restriction.RequireConfirmation || !restriction.RequireDeposit || restriction.BlockSelfBooking {
+		t.Errorf("Unexpected restriction at the threshold: %+v", restriction)
+	}
+
+	appointment := models.Appointment{Notes: "Dor no dente"}
+	applyNoShowRestriction(&appointment, restriction)
+	if !appointment.StaffConfirmationRequired || !appointment.DepositRequired || appointment.DepositAmount != 50 {
+		t.Errorf("Expected appointment to be flagged, got %+v", appointment)
+	}
+
+	settings.NoShowPolicyEnabled = false
+	if restriction := settings.EvaluateNoShowPolicy(10); restriction.Restricted {
+		t.Errorf("Expected no restriction with the policy disabled, got %+v", restriction)
+	}
+}
@@ -0,0 +1,339 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PeriodontalExamRequest is the payload to create or replace a periodontal chart
+type PeriodontalExamRequest struct {
+	PatientID       uint                      `json:"patient_id" binding:"required"`
+	MedicalRecordID *uint                     `json:"medical_record_id"`
+	ExamDate        *time.Time                `json:"exam_date"` // Defaults to now
+	Notes           string                    `json:"notes"`
+	Teeth           []models.PeriodontalTooth `json:"teeth" binding:"required,min=1"`
+}
+
+// periodontalExamResponse is an exam with its computed indices
+type periodontalExamResponse struct {
+	models.PeriodontalExam
+	Indices helpers.PeriodontalIndices `json:"indices"`
+}
+
+func newPeriodontalExamResponse(exam models.PeriodontalExam) periodontalExamResponse {
+	return periodontalExamResponse{
+		PeriodontalExam: exam,
+		Indices:         helpers.ComputePeriodontalIndices(exam.Teeth),
+	}
+}
+
+// validatePeriodontalTeeth checks the measurements of every tooth
+// Returns an error message (in Portuguese) when a value is out of range
+func validatePeriodontalTeeth(teeth []models.PeriodontalTooth) string {
+	seen := make(map[int]bool, len(teeth))
+	for _, tooth := range teeth {
+		if !helpers.IsValidFDITooth(tooth.Tooth) {
+			return fmt.Sprintf("Dente inválido: %d (use a notação FDI, ex: 16, 21)", tooth.Tooth)
+		}
+		if seen[tooth.Tooth] {
+			return fmt.Sprintf("Dente %d informado mais de uma vez", tooth.Tooth)
+		}
+		seen[tooth.Tooth] = true
+
+		for i := 0; i < models.PerioSiteCount; i++ {
+			if tooth.ProbingDepth[i] < 0 || tooth.ProbingDepth[i] > 20 {
+				return fmt.Sprintf("Profundidade de sondagem inválida no dente %d (%s): use de 0 a 20 mm", tooth.Tooth, models.PerioSiteLabels[i])
+			}
+			if tooth.Recession[i] < -10 || tooth.Recession[i] > 20 {
+				return fmt.Sprintf("Recessão inválida no dente %d (%s): use de -10 a 20 mm", tooth.Tooth, models.PerioSiteLabels[i])
+			}
+		}
+		if tooth.Mobility < 0 || tooth.Mobility > 3 {
+			return fmt.Sprintf("Mobilidade inválida no dente %d: use de 0 a 3", tooth.Tooth)
+		}
+		if tooth.Furcation < 0 || tooth.Furcation > 3 {
+			return fmt.Sprintf("Lesão de furca inválida no dente %d: use de 0 a 3", tooth.Tooth)
+		}
+	}
+	return ""
+}
+
+// periodontalExamLocked reports whether the chart belongs to a signed record
+// Charts measured in a signed record are part of it
+func periodontalExamLocked(db *gorm.DB, exam models.PeriodontalExam) bool {
+	if exam.MedicalRecordID == nil {
+		return false
+	}
+	var signed int64
+	db.Session(&gorm.Session{NewDB: true}).Model(&models.MedicalRecord{}).
+		Where("id = ? AND is_signed = true", *exam.MedicalRecordID).Count(&signed)
+	return signed > 0
+}
+
+// preparePeriodontalExam validates the request and fills the exam fields shared by create and update
+func preparePeriodontalExam(c *gin.Context, db *gorm.DB, req PeriodontalExamRequest, exam *models.PeriodontalExam) bool {
+	var patient models.Patient
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&patient, req.PatientID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Paciente não encontrado"})
+		return false
+	}
+
+	if req.MedicalRecordID != nil {
+		var record models.MedicalRecord
+		if err := db.Session(&gorm.Session{NewDB: true}).
+			Where("id = ? AND patient_id = ?", *req.MedicalRecordID, req.PatientID).
+			First(&record).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Prontuário não encontrado para este paciente"})
+			return false
+		}
+		if record.IsSigned {
+			c.JSON(http.StatusConflict, gin.H{"error": medicalRecordLockedMessage})
+			return false
+		}
+	}
+
+	if msg := validatePeriodontalTeeth(req.Teeth); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return false
+	}
+
+	examDate := time.Now()
+	if req.ExamDate != nil {
+		if req.ExamDate.After(examDate) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A data do exame não pode estar no futuro"})
+			return false
+		}
+		examDate = *req.ExamDate
+	}
+
+	exam.PatientID = req.PatientID
+	exam.MedicalRecordID = req.MedicalRecordID
+	exam.ExamDate = examDate
+	exam.Notes = req.Notes
+	exam.Teeth = make([]models.PeriodontalTooth, 0, len(req.Teeth))
+	for _, tooth := range req.Teeth {
+		tooth.ID = 0
+		tooth.ExamID = exam.ID
+		exam.Teeth = append(exam.Teeth, tooth)
+	}
+	return true
+}
+
+// loadPeriodontalExam loads an exam with its teeth in chart order
+func loadPeriodontalExam(db *gorm.DB, id interface{}) (models.PeriodontalExam, error) {
+	var exam models.PeriodontalExam
+	err := db.Session(&gorm.Session{NewDB: true}).
+		Preload("Teeth", func(tx *gorm.DB) *gorm.DB { return tx.Order("tooth ASC") }).
+		Preload("Patient").
+		Preload("Dentist").
+		First(&exam, id).Error
+	return exam, err
+}
+
+// CreatePeriodontalExam records a periodontal chart
+// POST /periodontal-exams
+func CreatePeriodontalExam(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var req PeriodontalExamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	exam := models.PeriodontalExam{DentistID: c.GetUint("user_id")}
+	if !preparePeriodontalExam(c, db, req, &exam) {
+		return
+	}
+
+	if err := db.Session(&gorm.Session{NewDB: true}).Create(&exam).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao salvar periodontograma"})
+		return
+	}
+
+	helpers.AuditAction(c, "create", "periodontal_exams", exam.ID, true, map[string]interface{}{
+		"patient_id": exam.PatientID,
+		"teeth":      len(exam.Teeth),
+	})
+
+	c.JSON(http.StatusCreated, gin.H{"exam": newPeriodontalExamResponse(exam)})
+}
+
+// GetPeriodontalExams lists the periodontal charts, newest first
+// GET /periodontal-exams?patient_id=
+func GetPeriodontalExams(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	offset := (page - 1) * pageSize
+
+	query := db.Session(&gorm.Session{NewDB: true}).Model(&models.PeriodontalExam{})
+	if patientID := c.Query("patient_id"); patientID != "" {
+		query = query.Where("patient_id = ?", patientID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var exams []models.PeriodontalExam
+	if err := query.Preload("Teeth").Preload("Dentist").
+		Offset(offset).Limit(pageSize).Order("exam_date DESC").
+		Find(&exams).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar periodontogramas"})
+		return
+	}
+
+	response := make([]periodontalExamResponse, 0, len(exams))
+	for _, exam := range exams {
+		response = append(response, newPeriodontalExamResponse(exam))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"exams":     response,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// GetPeriodontalExam returns a periodontal chart with its indices
+// GET /periodontal-exams/:id
+func GetPeriodontalExam(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	exam, err := loadPeriodontalExam(db, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Periodontograma não encontrado"})
+		return
+	}
+
+	helpers.AuditAction(c, "view", "periodontal_exams", exam.ID, true, map[string]interface{}{
+		"patient_id": exam.PatientID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"exam": newPeriodontalExamResponse(exam)})
+}
+
+// UpdatePeriodontalExam replaces the measurements of a periodontal chart
+// PUT /periodontal-exams/:id
+func UpdatePeriodontalExam(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var exam models.PeriodontalExam
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&exam, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Periodontograma não encontrado"})
+		return
+	}
+	if periodontalExamLocked(db, exam) {
+		c.JSON(http.StatusConflict, gin.H{"error": medicalRecordLockedMessage})
+		return
+	}
+
+	var req PeriodontalExamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.PatientID != exam.PatientID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Não é possível mover o periodontograma para outro paciente"})
+		return
+	}
+	if !preparePeriodontalExam(c, db, req, &exam) {
+		return
+	}
+
+	err := db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("exam_id = ?", exam.ID).Delete(&models.PeriodontalTooth{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("UPDATE periodontal_exams SET medical_record_id = ?, exam_date = ?, notes = ?, updated_at = ? WHERE id = ?",
+			exam.MedicalRecordID, exam.ExamDate, exam.Notes, time.Now(), exam.ID).Error; err != nil {
+			return err
+		}
+		return tx.Create(&exam.Teeth).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar periodontograma"})
+		return
+	}
+
+	helpers.AuditAction(c, "update", "periodontal_exams", exam.ID, true, map[string]interface{}{
+		"patient_id": exam.PatientID,
+		"teeth":      len(exam.Teeth),
+	})
+
+	updated, _ := loadPeriodontalExam(db, exam.ID)
+	c.JSON(http.StatusOK, gin.H{"exam": newPeriodontalExamResponse(updated)})
+}
+
+// DeletePeriodontalExam removes a periodontal chart
+// DELETE /periodontal-exams/:id
+func DeletePeriodontalExam(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var exam models.PeriodontalExam
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&exam, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Periodontograma não encontrado"})
+		return
+	}
+	if periodontalExamLocked(db, exam) {
+		c.JSON(http.StatusConflict, gin.H{"error": medicalRecordLockedMessage})
+		return
+	}
+
+	if err := db.Session(&gorm.Session{NewDB: true}).Delete(&models.PeriodontalExam{}, exam.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao remover periodontograma"})
+		return
+	}
+
+	helpers.AuditAction(c, "delete", "periodontal_exams", exam.ID, true, map[string]interface{}{
+		"patient_id": exam.PatientID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Periodontograma removido com sucesso"})
+}
+
+// ComparePeriodontalExams compares two charts of the same patient (the older one is the baseline)
+// GET /periodontal-exams/compare?from=ID&to=ID
+func ComparePeriodontalExams(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	if c.Query("from") == "" || c.Query("to") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Informe os exames a comparar (from e to)"})
+		return
+	}
+
+	before, err := loadPeriodontalExam(db, c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Periodontograma inicial não encontrado"})
+		return
+	}
+	after, err := loadPeriodontalExam(db, c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Periodontograma final não encontrado"})
+		return
+	}
+	if before.PatientID != after.PatientID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Os periodontogramas devem ser do mesmo paciente"})
+		return
+	}
+	if after.ExamDate.Before(before.ExamDate) {
+		before, after = after, before
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"patient_id": before.PatientID,
+		"from_exam":  gin.H{"id": before.ID, "exam_date": before.ExamDate},
+		"to_exam":    gin.H{"id": after.ID, "exam_date": after.ExamDate},
+		"comparison": helpers.ComparePeriodontalExams(before.Teeth, after.Teeth),
+	})
+}
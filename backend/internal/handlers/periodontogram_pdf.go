@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Periodontal chart geometry (mm)
+const (
+	perioLabelWidth = 24.0
+	perioRowHeight  = 4.0
+)
+
+// perioRow is one line of the periodontal chart
+type perioRow struct {
+	label   string
+	perSite bool
+	sites   [3]int // Site indexes of the arch side (vestibular or lingual), distal first
+	value   func(tooth models.PeriodontalTooth, site int) string
+	flag    func(tooth models.PeriodontalTooth, site int) bool // Drawn as a dot instead of a value
+	color   [3]int
+	deep    bool // Highlight pockets of 4mm or more
+}
+
+// GeneratePeriodontalExamPDF renders the periodontal chart of an exam
+// GET /periodontal-exams/:id/pdf
+func GeneratePeriodontalExamPDF(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c)
+	if !ok {
+		return
+	}
+	tenantID := c.GetUint("tenant_id")
+
+	// Get tenant info for header
+	var tenant models.Tenant
+	if err := db.Table("public.tenants").Where("id = ?", tenantID).First(&tenant).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load clinic info"})
+		return
+	}
+
+	exam, err := loadPeriodontalExam(db, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Periodontograma não encontrado"})
+		return
+	}
+
+	// Create PDF with proper margins for A4
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("cp1252")
+
+	// Header
+	pdf.SetFont("Arial", "B", 16)
+	pdf.Cell(0, 10, tr(tenant.Name))
+	pdf.Ln(8)
+
+	pdf.SetFont("Arial", "", 9)
+	pdf.Cell(0, 5, tr(tenant.Address+", "+tenant.City+" - "+tenant.State))
+	pdf.Ln(5)
+	pdf.Cell(0, 5, tr("Tel: "+tenant.Phone))
+	pdf.Ln(10)
+
+	// Title
+	pdf.SetFont("Arial", "B", 14)
+	pdf.Cell(0, 8, tr("Periodontograma"))
+	pdf.Ln(10)
+
+	// Patient info
+	pdf.SetFillColor(240, 240, 240)
+	pdf.SetFont("Arial", "B", 11)
+	pdf.CellFormat(180, 7, tr("Informacoes do Paciente"), "1", 0, "L", true, 0, "")
+	pdf.Ln(-1)
+
+	pdf.SetFont("Arial", "", 10)
+	patientName := "N/A"
+	if exam.Patient != nil {
+		patientName = exam.Patient.Name
+	}
+	pdf.CellFormat(60, 6, tr("Paciente:"), "1", 0, "L", false, 0, "")
+	pdf.CellFormat(120, 6, tr(patientName), "1", 0, "L", false, 0, "")
+	pdf.Ln(-1)
+
+	dentistName := "N/A"
+	if exam.Dentist != nil {
+		dentistName = exam.Dentist.Name
+	}
+	pdf.CellFormat(60, 6, tr("Profissional:"), "1", 0, "L", false, 0, "")
+	pdf.CellFormat(120, 6, tr(dentistName), "1", 0, "L", false, 0, "")
+	pdf.Ln(-1)
+
+	pdf.CellFormat(60, 6, tr("Data do exame:"), "1", 0, "L", false, 0, "")
+	pdf.CellFormat(120, 6, exam.ExamDate.Format("02/01/2006"), "1", 0, "L", false, 0, "")
+	pdf.Ln(10)
+
+	// Indices
+	indices := helpers.ComputePeriodontalIndices(exam.Teeth)
+	pdf.SetFont("Arial", "B", 11)
+	pdf.CellFormat(180, 7, tr("Indices"), "1", 0, "L", true, 0, "")
+	pdf.Ln(-1)
+
+	pdf.SetFont("Arial", "", 10)
+	indexRows := [][2]string{
+		{"Indice de placa:", fmt.Sprintf("%.1f%%", indices.PlaquePercent)},
+		{"Sangramento a sondagem:", fmt.Sprintf("%.1f%%", indices.BleedingPercent)},
+		{"Profundidade de sondagem media:", fmt.Sprintf("%.1f mm", indices.MeanProbingDepth)},
+		{"Nivel de insercao clinica medio:", fmt.Sprintf("%.1f mm", indices.MeanAttachmentLevel)},
+		{"Sitios com PS >= 4 mm:", fmt.Sprintf("%d de %d", indices.SitesPD4Plus, indices.Sites)},
+		{"Sitios com PS >= 6 mm:", fmt.Sprintf("%d de %d", indices.SitesPD6Plus, indices.Sites)},
+	}
+	for i := 0; i < len(indexRows); i += 2 {
+		pdf.CellFormat(60, 6, tr(indexRows[i][0]), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(30, 6, tr(indexRows[i][1]), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(60, 6, tr(indexRows[i+1][0]), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(30, 6, tr(indexRows[i+1][1]), "1", 0, "L", false, 0, "")
+		pdf.Ln(-1)
+	}
+
+	// Chart, one block per arch
+	teeth := make(map[int]models.PeriodontalTooth, len(exam.Teeth))
+	hasDeciduous := false
+	for _, tooth := range exam.Teeth {
+		teeth[tooth.Tooth] = tooth
+		if helpers.ToothDentition(tooth.Tooth) == helpers.DentitionDeciduous {
+			hasDeciduous = true
+		}
+	}
+
+	renderPerioArch(pdf, tr, "Arcada Superior", helpers.PermanentUpperTeeth, teeth)
+	renderPerioArch(pdf, tr, "Arcada Inferior", helpers.PermanentLowerTeeth, teeth)
+	if hasDeciduous {
+		renderPerioArch(pdf, tr, "Deciduos Superiores", helpers.DeciduousUpperTeeth, teeth)
+		renderPerioArch(pdf, tr, "Deciduos Inferiores", helpers.DeciduousLowerTeeth, teeth)
+	}
+
+	pdf.Ln(2)
+	pdf.SetFont("Arial", "I", 7)
+	pdf.SetTextColor(0, 0, 0)
+	pdf.CellFormat(180, 4, tr("Legenda: PS = profundidade de sondagem, Rec = recessao, NIC = nivel de insercao clinica (mm). PS >= 4 mm em vermelho."), "0", 0, "L", false, 0, "")
+	pdf.Ln(-1)
+
+	renderSection(pdf, tr, "Observacoes:", exam.Notes)
+
+	// Footer
+	pdf.Ln(10)
+	pdf.SetFont("Arial", "I", 8)
+	pdf.Cell(0, 5, fmt.Sprintf("Gerado em: %s", time.Now().Format("02/01/2006 15:04")))
+
+	helpers.AuditAction(c, "view", "periodontal_exams", exam.ID, true, map[string]interface{}{
+		"patient_id": exam.PatientID,
+		"format":     "pdf",
+	})
+
+	// Output PDF
+	c.Header("Content-Type", "application/pdf")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=periodontograma_%d.pdf", exam.ID))
+
+	if err := pdf.Output(c.Writer); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate PDF"})
+		return
+	}
+}
+
+// perioRows returns the chart lines of each side of an arch, ordered towards the tooth numbers
+func perioRows(upper bool) (vestibular, lingual []perioRow) {
+	v := [3]int{models.PerioSiteDV, models.PerioSiteV, models.PerioSiteMV}
+	l := [3]int{models.PerioSiteDL, models.PerioSiteL, models.PerioSiteML}
+	lingualName := "L"
+	if upper {
+		lingualName = "P"
+	}
+
+	probing := func(t models.PeriodontalTooth, s int) string { return fmt.Sprintf("%d", t.ProbingDepth[s]) }
+	recession := func(t models.PeriodontalTooth, s int) string { return fmt.Sprintf("%d", t.Recession[s]) }
+	attachment := func(t models.PeriodontalTooth, s int) string { return fmt.Sprintf("%d", t.AttachmentLevel()[s]) }
+	plaque := func(t models.PeriodontalTooth, s int) bool { return t.Plaque[s] }
+	bleeding := func(t models.PeriodontalTooth, s int) bool { return t.Bleeding[s] }
+
+	side := func(name string, sites [3]int) []perioRow {
+		return []perioRow{
+			{label: "Placa " + name, perSite: true, sites: sites, flag: plaque, color: [3]int{30, 136, 229}},
+			{label: "Sangr. " + name, perSite: true, sites: sites, flag: bleeding, color: [3]int{211, 47, 47}},
+			{label: "PS " + name, perSite: true, sites: sites, value: probing, deep: true},
+			{label: "Rec " + name, perSite: true, sites: sites, value: recession},
+			{label: "NIC " + name, perSite: true, sites: sites, value: attachment},
+		}
+	}
+
+	vestibular = append([]perioRow{
+		{label: "Mobilidade", value: func(t models.PeriodontalTooth, _ int) string { return fmt.Sprintf("%d", t.Mobility) }},
+		{label: "Furca", value: func(t models.PeriodontalTooth, _ int) string { return fmt.Sprintf("%d", t.Furcation) }},
+	}, side("V", v)...)
+	lingual = side(lingualName, l)
+	return vestibular, lingual
+}
+
+// reversePerioRows mirrors the lines drawn below the tooth numbers
+func reversePerioRows(rows []perioRow) []perioRow {
+	reversed := make([]perioRow, len(rows))
+	for i, row := range rows {
+		reversed[len(rows)-1-i] = row
+	}
+	return reversed
+}
+
+// renderPerioArch draws the measurements of one arch as a grid, one column per tooth
+func renderPerioArch(pdf *gofpdf.Fpdf, tr func(string) string, title string, numbers []int, teeth map[int]models.PeriodontalTooth) {
+	upper := helpers.IsUpperTooth(numbers[0])
+	vestibular, lingual := perioRows(upper)
+	rows := len(vestibular) + len(lingual) + 1
+
+	checkPageBreak(pdf, float64(rows)*perioRowHeight+15)
+
+	pdf.Ln(5)
+	pdf.SetFont("Arial", "B", 11)
+	pdf.SetFillColor(240, 240, 240)
+	pdf.SetTextColor(0, 0, 0)
+	pdf.CellFormat(180, 7, tr(title), "1", 0, "L", true, 0, "")
+	pdf.Ln(-1)
+
+	toothWidth := (180 - perioLabelWidth) / float64(len(helpers.PermanentUpperTeeth))
+
+	drawRows := func(rows []perioRow) {
+		for _, row := range rows {
+			renderPerioRow(pdf, tr, row, numbers, teeth, toothWidth)
+		}
+	}
+
+	// Upper arch: vestibular on top; lower arch: lingual on top (as seen facing the patient)
+	above, below := vestibular, lingual
+	if !upper {
+		above, below = lingual, vestibular
+	}
+	drawRows(above)
+
+	pdf.SetFont("Arial", "B", 7)
+	pdf.SetFillColor(240, 240, 240)
+	pdf.SetTextColor(0, 0, 0)
+	pdf.CellFormat(perioLabelWidth, perioRowHeight, tr("Dente"), "1", 0, "L", true, 0, "")
+	for _, number := range numbers {
+		pdf.CellFormat(toothWidth, perioRowHeight, fmt.Sprintf("%d", number), "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	drawRows(reversePerioRows(below))
+}
+
+// renderPerioRow draws one chart line across the teeth of an arch
+func renderPerioRow(pdf *gofpdf.Fpdf, tr func(string) string, row perioRow, numbers []int, teeth map[int]models.PeriodontalTooth, toothWidth float64) {
+	pdf.SetFont("Arial", "", 6)
+	pdf.SetTextColor(0, 0, 0)
+	pdf.CellFormat(perioLabelWidth, perioRowHeight, tr(row.label), "1", 0, "L", false, 0, "")
+
+	for _, number := range numbers {
+		tooth, charted := teeth[number]
+		x, y := pdf.GetX(), pdf.GetY()
+
+		if !charted || tooth.Missing {
+			fill := charted // Missing teeth are shaded
+			pdf.SetFillColor(200, 200, 200)
+			pdf.CellFormat(toothWidth, perioRowHeight, "", "1", 0, "C", fill, 0, "")
+			continue
+		}
+
+		if !row.perSite {
+			pdf.CellFormat(toothWidth, perioRowHeight, row.value(tooth, 0), "1", 0, "C", false, 0, "")
+			continue
+		}
+
+		// Sites left to right as drawn: distal first on the patient's right side, mesial first on the left
+		sites := row.sites
+		if !helpers.IsPatientRightTooth(number) {
+			sites[0], sites[2] = sites[2], sites[0]
+		}
+
+		siteWidth := toothWidth / 3
+		pdf.Rect(x, y, toothWidth, perioRowHeight, "D")
+		for i, site := range sites {
+			siteX := x + float64(i)*siteWidth
+			if row.flag != nil {
+				if row.flag(tooth, site) {
+					pdf.SetFillColor(row.color[0], row.color[1], row.color[2])
+					pdf.Circle(siteX+siteWidth/2, y+perioRowHeight/2, 0.8, "F")
+				}
+				continue
+			}
+			value := row.value(tooth, site)
+			if row.deep && tooth.ProbingDepth[site] >= 4 {
+				pdf.SetTextColor(211, 47, 47)
+			}
+			pdf.SetXY(siteX, y)
+			pdf.CellFormat(siteWidth, perioRowHeight, value, "", 0, "C", false, 0, "")
+			pdf.SetTextColor(0, 0, 0)
+		}
+		pdf.SetXY(x+toothWidth, y)
+	}
+	pdf.Ln(-1)
+}
@@ -0,0 +1,698 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"drcrwell/backend/internal/pix"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jung-kurt/gofpdf"
+	"gorm.io/gorm"
+)
+
+// Validity of the dynamic charges at the PSP. Static codes printed in budgets and carnês do not expire
+const (
+	pixChargeExpiration  = 7 * 24 * time.Hour
+	pixDepositExpiration = 24 * time.Hour
+)
+
+// errPixRequest is a validation error raised while issuing or settling a charge
+type errPixRequest struct{ message string }
+
+func (e errPixRequest) Error() string { return e.message }
+
+// pixConfig is the PIX account of the clinic that receives the charges
+type pixConfig struct {
+	Key          string
+	MerchantName string
+	MerchantCity string
+	Provider     string // PSP of the dynamic charges, empty for static codes only
+}
+
+// loadPixConfig reads the PIX settings of the clinic. ok is false when PIX is disabled or has no key
+// The clinic name and city are used when the merchant data is not set
+func loadPixConfig(db *gorm.DB, tenantID uint) (pixConfig, bool) {
+	var settings models.TenantSettings
+	if err := db.Session(&gorm.Session{NewDB: true}).Table("public.tenant_settings").Where("tenant_id = ?", tenantID).First(&settings).Error; err != nil {
+		return pixConfig{}, false
+	}
+	if !settings.PaymentPixEnabled || settings.PixKey == "" {
+		return pixConfig{}, false
+	}
+
+	config := pixConfig{
+		Key:          settings.PixKey,
+		MerchantName: settings.PixMerchantName,
+		MerchantCity: settings.PixMerchantCity,
+		Provider:     settings.PixProvider,
+	}
+	if config.MerchantName == "" {
+		config.MerchantName = settings.ClinicName
+	}
+	if config.MerchantCity == "" {
+		config.MerchantCity = settings.ClinicCity
+	}
+	if config.MerchantName == "" || config.MerchantCity == "" {
+		return pixConfig{}, false
+	}
+	return config, true
+}
+
+// issuePixCharge generates the BR Code of the charge and saves it. Dynamic charges are created
+// at the PSP of the clinic, which holds the amount and confirms the payment
+func issuePixCharge(db *gorm.DB, config pixConfig, charge *models.PixCharge, dynamic bool, expiration time.Duration) error {
+	charge.Status = models.PixChargeStatusActive
+	charge.Description = pix.Text(charge.Description, 255)
+
+	if dynamic {
+		provider, err := pix.GetProvider(config.Provider)
+		if err != nil {
+			return err
+		}
+		charge.Kind = models.PixChargeDynamic
+		charge.Provider = provider.Name()
+		charge.TxID = pix.NewTxID(pix.MaxDynamicTxIDLength)
+
+		result, err := provider.CreateCharge(context.Background(), pix.Charge{
+			TxID:        charge.TxID,
+			Key:         config.Key,
+			Amount:      charge.Amount,
+			Description: charge.Description,
+			Expiration:  expiration,
+		})
+		if err != nil {
+			return fmt.Errorf("create charge at %s: %w", provider.Name(), err)
+		}
+		charge.Location = result.Location
+		if !result.ExpiresAt.IsZero() {
+			charge.ExpiresAt = &result.ExpiresAt
+		}
+		charge.Payload, err = pix.Payload{
+			MerchantName: config.MerchantName,
+			MerchantCity: config.MerchantCity,
+			Location:     result.Location,
+		}.Encode()
+		if err != nil {
+			return err
+		}
+	} else {
+		var err error
+		charge.Kind = models.PixChargeStatic
+		charge.TxID = pix.NewTxID(pix.MaxStaticTxIDLength)
+		charge.Payload, err = pix.Payload{
+			Key:          config.Key,
+			Description:  charge.Description,
+			MerchantName: config.MerchantName,
+			MerchantCity: config.MerchantCity,
+			Amount:       charge.Amount,
+			TxID:         charge.TxID,
+		}.Encode()
+		if err != nil {
+			return err
+		}
+	}
+
+	return db.Session(&gorm.Session{NewDB: true}).Create(charge).Error
+}
+
+// findOrIssuePixCharge returns the active charge of the same target and amount, so a printed or
+// sent code stays valid, or issues a new one. Charges of the target with another amount are cancelled
+func findOrIssuePixCharge(db *gorm.DB, config pixConfig, target models.PixCharge, dynamic bool, expiration time.Duration) (*models.PixCharge, error) {
+	query := db.Session(&gorm.Session{NewDB: true}).Model(&models.PixCharge{}).
+		Where("purpose = ? AND status = ?", target.Purpose, models.PixChargeStatusActive).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now())
+	switch target.Purpose {
+	case models.PixPurposePayment:
+		query = query.Where("payment_id = ?", target.PaymentID)
+	case models.PixPurposeInstallment:
+		query = query.Where("treatment_id = ? AND installment_number = ?", target.TreatmentID, target.InstallmentNumber)
+	case models.PixPurposeBudget:
+		query = query.Where("budget_id = ?", target.BudgetID)
+	case models.PixPurposeDeposit:
+		query = query.Where("appointment_id = ?", target.AppointmentID)
+	default:
+		return nil, fmt.Errorf("unknown pix purpose %q", target.Purpose)
+	}
+
+	var active []models.PixCharge
+	if err := query.Order("id DESC").Find(&active).Error; err != nil {
+		return nil, err
+	}
+	for i := range active {
+		kind := models.PixChargeStatic
+		if dynamic {
+			kind = models.PixChargeDynamic
+		}
+		if active[i].Amount == target.Amount && active[i].Kind == kind {
+			return &active[i], nil
+		}
+	}
+	for _, stale := range active {
+		db.Session(&gorm.Session{NewDB: true}).Exec("UPDATE pix_charges SET status = ?, updated_at = ? WHERE id = ? AND status = ?",
+			models.PixChargeStatusCancelled, time.Now(), stale.ID, models.PixChargeStatusActive)
+	}
+
+	charge := target
+	if err := issuePixCharge(db, config, &charge, dynamic, expiration); err != nil {
+		return nil, err
+	}
+	return &charge, nil
+}
+
+// pixChargeResponse is the charge as shown to the payer: the "copia e cola" code and its QR code
+func pixChargeResponse(charge *models.PixCharge) gin.H {
+	qrCode, err := pix.QRCodeDataURI(charge.Payload)
+	if err != nil {
+		log.Printf("ERROR rendering PIX QR code of charge %d: %v", charge.ID, err)
+	}
+	return gin.H{
+		"id":         charge.ID,
+		"txid":       charge.TxID,
+		"kind":       charge.Kind,
+		"amount":     charge.Amount,
+		"payload":    charge.Payload,
+		"qr_code":    qrCode,
+		"expires_at": charge.ExpiresAt,
+		"status":     charge.Status,
+	}
+}
+
+// pdfPixQRCode draws the QR code of a BR Code at x, y with the given size in mm
+func pdfPixQRCode(pdf *gofpdf.Fpdf, name, payload string, x, y, size float64) error {
+	png, err := pix.QRCodePNG(payload, pix.DefaultQRCodeSize)
+	if err != nil {
+		return err
+	}
+	options := gofpdf.ImageOptions{ImageType: "PNG"}
+	pdf.RegisterImageOptionsReader(name, options, bytes.NewReader(png))
+	pdf.ImageOptions(name, x, y, size, size, false, options, 0, "")
+	return pdf.Error()
+}
+
+// pdfPixSection draws the QR code and the "copia e cola" code of a charge, in the A4 layout with
+// 15 mm margins used by the budget and receipt PDFs
+func pdfPixSection(pdf *gofpdf.Fpdf, tr func(string) string, title string, charge *models.PixCharge) {
+	const qrSize = 40.0
+	if _, pageHeight := pdf.GetPageSize(); pdf.GetY()+qrSize+12 > pageHeight-15 {
+		pdf.AddPage()
+	}
+
+	pdf.SetFont("Arial", "B", 11)
+	pdf.CellFormat(180, 7, tr(title), "1", 0, "L", true, 0, "")
+	pdf.Ln(-1)
+
+	top := pdf.GetY() + 2
+	if err := pdfPixQRCode(pdf, "pix_"+charge.TxID, charge.Payload, 15, top, qrSize); err != nil {
+		log.Printf("ERROR drawing PIX QR code of charge %d: %v", charge.ID, err)
+	}
+	pdf.SetXY(60, top)
+	pdf.SetFont("Arial", "B", 10)
+	pdf.Cell(0, 5, fmt.Sprintf("Valor: R$ %.2f", charge.Amount))
+	pdf.SetXY(60, top+7)
+	pdf.SetFont("Arial", "", 9)
+	pdf.MultiCell(135, 4, tr("Leia o QR code no app do seu banco ou use o PIX copia e cola:"), "", "L", false)
+	pdf.SetX(60)
+	pdf.SetFont("Courier", "", 7)
+	pdf.MultiCell(135, 3.5, charge.Payload, "", "L", false)
+	pdf.SetY(top + qrSize + 3)
+}
+
+// installmentPixTarget is the charge of an unpaid installment of a treatment, with the amount of the carnê
+func installmentPixTarget(db *gorm.DB, treatment models.Treatment, installmentNumber int) (models.PixCharge, error) {
+	if treatment.Status != models.TreatmentStatusInProgress {
+		return models.PixCharge{}, errPixRequest{"Tratamento não está em andamento"}
+	}
+	schedule := helpers.InstallmentSchedule(treatment.TotalValue, treatment.InstallmentValue, treatment.TotalInstallments, treatment.StartDate)
+	if installmentNumber < 1 || installmentNumber > len(schedule) {
+		return models.PixCharge{}, errPixRequest{fmt.Sprintf("Parcela deve estar entre 1 e %d", len(schedule))}
+	}
+
+	var paid int64
+	db.Session(&gorm.Session{NewDB: true}).Model(&models.TreatmentPayment{}).
+		Where("treatment_id = ? AND installment_number = ? AND status = ?", treatment.ID, installmentNumber, models.TreatmentPaymentStatusPaid).
+		Count(&paid)
+	if paid > 0 {
+		return models.PixCharge{}, errPixRequest{fmt.Sprintf("Parcela %d já foi paga", installmentNumber)}
+	}
+
+	treatmentID, patientID := treatment.ID, treatment.PatientID
+	return models.PixCharge{
+		Purpose:           models.PixPurposeInstallment,
+		TreatmentID:       &treatmentID,
+		InstallmentNumber: installmentNumber,
+		PatientID:         &patientID,
+		Amount:            schedule[installmentNumber-1].Amount,
+		Description:       fmt.Sprintf("Tratamento %d parcela %d/%d", treatment.ID, installmentNumber, len(schedule)),
+	}, nil
+}
+
+// budgetPixTarget is the charge of what is still open in a budget
+func budgetPixTarget(db *gorm.DB, budget models.Budget) (models.PixCharge, error) {
+	if budget.Status != "pending" && budget.Status != "approved" {
+		return models.PixCharge{}, errPixRequest{"Somente orçamentos pendentes ou aprovados podem ser cobrados"}
+	}
+
+	var paid float64
+	db.Session(&gorm.Session{NewDB: true}).Raw(`
+		SELECT COALESCE(SUM(amount), 0) FROM payments
+		WHERE budget_id = ? AND type = 'income' AND status = 'paid' AND deleted_at IS NULL
+	`, budget.ID).Scan(&paid)
+	amount := math.Round((budget.TotalValue-paid)*100) / 100
+	if amount <= 0 {
+		return models.PixCharge{}, errPixRequest{"Orçamento já está pago"}
+	}
+
+	budgetID, patientID := budget.ID, budget.PatientID
+	return models.PixCharge{
+		Purpose:     models.PixPurposeBudget,
+		BudgetID:    &budgetID,
+		PatientID:   &patientID,
+		Amount:      amount,
+		Description: fmt.Sprintf("Orcamento %d", budget.ID),
+	}, nil
+}
+
+// depositPixCharge issues the charge of the deposit required by the no-show policy for a self-booked
+// appointment. Returns nil when no deposit is due or the clinic does not receive by PIX
+func depositPixCharge(db *gorm.DB, tenantID uint, appointment models.Appointment) *models.PixCharge {
+	if !appointment.DepositRequired || appointment.DepositAmount <= 0 {
+		return nil
+	}
+	config, ok := loadPixConfig(db, tenantID)
+	if !ok {
+		return nil
+	}
+
+	appointmentID, patientID := appointment.ID, appointment.PatientID
+	charge, err := findOrIssuePixCharge(db, config, models.PixCharge{
+		Purpose:       models.PixPurposeDeposit,
+		AppointmentID: &appointmentID,
+		PatientID:     &patientID,
+		Amount:        appointment.DepositAmount,
+		Description:   fmt.Sprintf("Sinal agendamento %d", appointment.ID),
+	}, config.Provider != "", pixDepositExpiration)
+	if err != nil {
+		log.Printf("ERROR issuing PIX deposit of appointment %d: %v", appointment.ID, err)
+		return nil
+	}
+	return charge
+}
+
+// CreatePixChargeRequest selects what the charge pays: a pending payment, an installment of a treatment or a budget
+type CreatePixChargeRequest struct {
+	PaymentID         *uint `json:"payment_id"`
+	TreatmentID       *uint `json:"treatment_id"`
+	InstallmentNumber int   `json:"installment_number"`
+	BudgetID          *uint `json:"budget_id"`
+	Static            bool  `json:"static"` // Static code even when a PSP is configured
+}
+
+// CreatePixCharge issues a PIX charge with its BR Code and QR code
+// POST /pix/charges
+func CreatePixCharge(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var req CreatePixChargeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	config, ok := loadPixConfig(db, c.GetUint("tenant_id"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "PIX não configurado. Informe a chave PIX, o nome e a cidade da clínica nas configurações"})
+		return
+	}
+
+	var target models.PixCharge
+	var err error
+	switch {
+	case req.PaymentID != nil:
+		var payment models.Payment
+		if err := db.Session(&gorm.Session{NewDB: true}).First(&payment, *req.PaymentID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pagamento não encontrado"})
+			return
+		}
+		if payment.Type != "income" || (payment.Status != "pending" && payment.Status != "overdue") || payment.Amount <= 0 {
+			err = errPixRequest{"Somente recebimentos pendentes podem ser cobrados"}
+			break
+		}
+		target = models.PixCharge{
+			Purpose:     models.PixPurposePayment,
+			PaymentID:   &payment.ID,
+			PatientID:   payment.PatientID,
+			Amount:      payment.Amount,
+			Description: payment.Description,
+		}
+	case req.TreatmentID != nil:
+		var treatment models.Treatment
+		if err := db.Session(&gorm.Session{NewDB: true}).First(&treatment, *req.TreatmentID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tratamento não encontrado"})
+			return
+		}
+		target, err = installmentPixTarget(db, treatment, req.InstallmentNumber)
+	case req.BudgetID != nil:
+		var budget models.Budget
+		if err := db.Session(&gorm.Session{NewDB: true}).First(&budget, *req.BudgetID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Orçamento não encontrado"})
+			return
+		}
+		target, err = budgetPixTarget(db, budget)
+	default:
+		err = errPixRequest{"Informe o pagamento, a parcela do tratamento ou o orçamento a cobrar"}
+	}
+	var requestErr errPixRequest
+	if errors.As(err, &requestErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": requestErr.message})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	target.CreatedByID = &userID
+	dynamic := config.Provider != "" && !req.Static
+	charge, err := findOrIssuePixCharge(db, config, target, dynamic, pixChargeExpiration)
+	if err != nil {
+		log.Printf("ERROR issuing PIX charge: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar cobrança PIX"})
+		return
+	}
+
+	helpers.AuditAction(c, "create", "pix_charges", charge.ID, true, map[string]interface{}{
+		"purpose": charge.Purpose,
+		"kind":    charge.Kind,
+		"amount":  charge.Amount,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{"charge": charge, "pix": pixChargeResponse(charge)})
+}
+
+// GetPixCharges lists the PIX charges
+// GET /pix/charges
+func GetPixCharges(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	query := db.Session(&gorm.Session{NewDB: true}).Model(&models.PixCharge{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if purpose := c.Query("purpose"); purpose != "" {
+		query = query.Where("purpose = ?", purpose)
+	}
+	if patientID := c.Query("patient_id"); patientID != "" {
+		query = query.Where("patient_id = ?", patientID)
+	}
+	if treatmentID := c.Query("treatment_id"); treatmentID != "" {
+		query = query.Where("treatment_id = ?", treatmentID)
+	}
+	if budgetID := c.Query("budget_id"); budgetID != "" {
+		query = query.Where("budget_id = ?", budgetID)
+	}
+	if startDate := c.Query("start_date"); startDate != "" {
+		query = query.Where("created_at >= ?", startDate)
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		query = query.Where("created_at < ?::date + 1", endDate)
+	}
+
+	var charges []models.PixCharge
+	if err := query.Order("created_at DESC, id DESC").Find(&charges).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar cobranças PIX"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"charges": charges, "total": len(charges)})
+}
+
+// GetPixCharge returns a charge with its QR code
+// GET /pix/charges/:id
+func GetPixCharge(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var charge models.PixCharge
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&charge, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cobrança PIX não encontrada"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"charge": charge, "pix": pixChargeResponse(&charge)})
+}
+
+// GetPixChargeQRCode returns the QR code of a charge as a PNG image
+// GET /pix/charges/:id/qrcode.png
+func GetPixChargeQRCode(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var charge models.PixCharge
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&charge, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cobrança PIX não encontrada"})
+		return
+	}
+
+	png, err := pix.QRCodePNG(charge.Payload, pix.DefaultQRCodeSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar QR code"})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=pix_%s.png", charge.TxID))
+	c.Data(http.StatusOK, "image/png", png)
+}
+
+// ConfirmPixChargeRequest is the manual confirmation of a static charge, checked in the bank statement
+type ConfirmPixChargeRequest struct {
+	EndToEndID string     `json:"end_to_end_id"`
+	Amount     float64    `json:"amount"` // Defaults to the amount of the charge
+	PaidAt     *time.Time `json:"paid_at"`
+}
+
+// ConfirmPixCharge settles a paid charge: dynamic charges are checked at the PSP, static ones are
+// confirmed by the staff. The payment is registered according to the purpose of the charge
+// POST /pix/charges/:id/confirm
+func ConfirmPixCharge(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var charge models.PixCharge
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&charge, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cobrança PIX não encontrada"})
+		return
+	}
+	if charge.Status != models.PixChargeStatusActive {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cobrança PIX não está ativa", "status": charge.Status})
+		return
+	}
+
+	var req ConfirmPixChargeRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	paidAt := time.Now()
+	paidAmount := charge.Amount
+	endToEndID := strings.TrimSpace(req.EndToEndID)
+	if charge.Kind == models.PixChargeDynamic {
+		provider, err := pix.GetProvider(charge.Provider)
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Provedor PIX da cobrança não está disponível"})
+			return
+		}
+		status, err := provider.CheckPayment(c.Request.Context(), charge.TxID)
+		if err != nil {
+			log.Printf("ERROR checking PIX charge %s at %s: %v", charge.TxID, charge.Provider, err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Erro ao consultar o pagamento no provedor PIX"})
+			return
+		}
+		if !status.Paid {
+			if charge.ExpiresAt != nil && time.Now().After(*charge.ExpiresAt) {
+				db.Session(&gorm.Session{NewDB: true}).Exec("UPDATE pix_charges SET status = ?, updated_at = ? WHERE id = ? AND status = ?",
+					models.PixChargeStatusExpired, time.Now(), charge.ID, models.PixChargeStatusActive)
+				c.JSON(http.StatusConflict, gin.H{"error": "Cobrança PIX expirou sem pagamento", "status": models.PixChargeStatusExpired})
+				return
+			}
+			c.JSON(http.StatusConflict, gin.H{"error": "Pagamento ainda não identificado pelo provedor PIX", "status": charge.Status})
+			return
+		}
+		paidAt, paidAmount, endToEndID = status.PaidAt, status.Amount, status.EndToEndID
+	} else {
+		if req.Amount > 0 {
+			paidAmount = req.Amount
+		}
+		if req.PaidAt != nil {
+			paidAt = *req.PaidAt
+		}
+	}
+	if len(endToEndID) > 40 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Identificador da transação (E2E) deve ter até 40 caracteres"})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	err := db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		// Only one confirmation settles the charge
+		result := tx.Exec(`
+			UPDATE pix_charges SET status = ?, paid_at = ?, paid_amount = ?, end_to_end_id = ?, confirmed_by_id = ?, updated_at = ?
+			WHERE id = ? AND status = ?
+		`, models.PixChargeStatusPaid, paidAt, paidAmount, endToEndID, userID, time.Now(), charge.ID, models.PixChargeStatusActive)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errPixRequest{"Cobrança PIX já foi confirmada"}
+		}
+		charge.Status = models.PixChargeStatusPaid
+		charge.PaidAt = &paidAt
+		charge.PaidAmount = paidAmount
+		charge.EndToEndID = endToEndID
+		charge.ConfirmedByID = &userID
+
+		if err := settlePixCharge(tx, &charge, userID); err != nil {
+			return err
+		}
+		return tx.Exec("UPDATE pix_charges SET treatment_payment_id = ?, settled_payment_id = ? WHERE id = ?",
+			charge.TreatmentPaymentID, charge.SettledPaymentID, charge.ID).Error
+	})
+	var requestErr errPixRequest
+	if errors.As(err, &requestErr) {
+		c.JSON(http.StatusConflict, gin.H{"error": requestErr.message})
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR settling PIX charge %d: %v", charge.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao registrar pagamento PIX"})
+		return
+	}
+
+	helpers.AuditAction(c, "update", "pix_charges", charge.ID, true, map[string]interface{}{
+		"action":      "confirm",
+		"purpose":     charge.Purpose,
+		"paid_amount": paidAmount,
+		"end_to_end":  endToEndID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"charge": charge})
+}
+
+// settlePixCharge registers the payment of the charge: the pending payment is paid, an installment
+// becomes a treatment payment, and budgets and deposits become income payments
+func settlePixCharge(tx *gorm.DB, charge *models.PixCharge, userID uint) error {
+	paidAt := *charge.PaidAt
+	switch charge.Purpose {
+	case models.PixPurposePayment:
+		var payment models.Payment
+		if err := tx.First(&payment, charge.PaymentID).Error; err != nil {
+			return errPixRequest{"Pagamento da cobrança não encontrado"}
+		}
+		if payment.Status == "paid" {
+			return errPixRequest{"Pagamento da cobrança já está pago"}
+		}
+		payment.Status = "paid"
+		payment.PaymentMethod = "pix"
+		payment.PaidDate = &paidAt
+		if err := tx.Exec("UPDATE payments SET status = ?, payment_method = ?, paid_date = ?, updated_at = ? WHERE id = ?",
+			payment.Status, payment.PaymentMethod, payment.PaidDate, time.Now(), payment.ID).Error; err != nil {
+			return err
+		}
+		charge.SettledPaymentID = &payment.ID
+		return generatePaymentCommissions(tx, payment)
+
+	case models.PixPurposeInstallment:
+		var treatment models.Treatment
+		if err := tx.First(&treatment, charge.TreatmentID).Error; err != nil {
+			return errPixRequest{"Tratamento da cobrança não encontrado"}
+		}
+		payment := models.TreatmentPayment{
+			Amount:            charge.PaidAmount,
+			PaymentMethod:     "pix",
+			InstallmentNumber: charge.InstallmentNumber,
+			PaidDate:          paidAt,
+			ReceivedByID:      userID,
+			Notes:             "PIX " + charge.TxID,
+		}
+		if err := recordTreatmentPayment(tx, &treatment, &payment); err != nil {
+			return err
+		}
+		charge.TreatmentPaymentID = &payment.ID
+		return nil
+
+	case models.PixPurposeBudget:
+		payment := models.Payment{
+			BudgetID:      charge.BudgetID,
+			PatientID:     charge.PatientID,
+			Type:          "income",
+			Category:      "treatment",
+			Description:   fmt.Sprintf("Orçamento #%d - PIX", *charge.BudgetID),
+			Amount:        charge.PaidAmount,
+			PaymentMethod: "pix",
+			Status:        "paid",
+			PaidDate:      &paidAt,
+		}
+		if err := tx.Create(&payment).Error; err != nil {
+			return err
+		}
+		charge.SettledPaymentID = &payment.ID
+		return generatePaymentCommissions(tx, payment)
+
+	case models.PixPurposeDeposit:
+		if err := tx.Exec("UPDATE appointments SET deposit_paid_at = ?, updated_at = ? WHERE id = ?",
+			paidAt, time.Now(), charge.AppointmentID).Error; err != nil {
+			return err
+		}
+		payment := models.Payment{
+			PatientID:     charge.PatientID,
+			Type:          "income",
+			Category:      "deposit",
+			Description:   fmt.Sprintf("Sinal do agendamento #%d - PIX", *charge.AppointmentID),
+			Amount:        charge.PaidAmount,
+			PaymentMethod: "pix",
+			Status:        "paid",
+			PaidDate:      &paidAt,
+		}
+		if err := tx.Create(&payment).Error; err != nil {
+			return err
+		}
+		charge.SettledPaymentID = &payment.ID
+		return nil
+	}
+	return fmt.Errorf("unknown pix purpose %q", charge.Purpose)
+}
+
+// CancelPixCharge cancels an unpaid charge, so its code is no longer shown
+// POST /pix/charges/:id/cancel
+func CancelPixCharge(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var charge models.PixCharge
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&charge, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cobrança PIX não encontrada"})
+		return
+	}
+
+	result := db.Session(&gorm.Session{NewDB: true}).Exec("UPDATE pix_charges SET status = ?, updated_at = ? WHERE id = ? AND status = ?",
+		models.PixChargeStatusCancelled, time.Now(), charge.ID, models.PixChargeStatusActive)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao cancelar cobrança PIX"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Somente cobranças ativas podem ser canceladas", "status": charge.Status})
+		return
+	}
+	charge.Status = models.PixChargeStatusCancelled
+
+	helpers.AuditAction(c, "update", "pix_charges", charge.ID, true, map[string]interface{}{
+		"action": "cancel",
+	})
+
+	c.JSON(http.StatusOK, gin.H{"charge": charge})
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"drcrwell/backend/internal/database"
+	"drcrwell/backend/internal/helpers"
 	"drcrwell/backend/internal/middleware"
 	"drcrwell/backend/internal/models"
 	"fmt"
@@ -12,8 +13,15 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/jung-kurt/gofpdf"
+	"gorm.io/gorm"
 )
 
+// PrescriptionRequest is the payload of create/update, with the override of safety warnings
+type PrescriptionRequest struct {
+	models.Prescription
+	PrescriptionOverride
+}
+
 // CreatePrescription creates a new prescription/medical report
 func CreatePrescription(c *gin.Context) {
 	db, ok := middleware.GetDBFromContextSafe(c)
@@ -23,11 +31,12 @@ func CreatePrescription(c *gin.Context) {
 	userID := c.GetUint("user_id")
 	tenantID := c.GetUint("tenant_id")
 
-	var input models.Prescription
-	if err := c.ShouldBindJSON(&input); err != nil {
+	var req PrescriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	input := req.Prescription
 
 	// Get user (dentist) info - use database.DB for public schema access
 	var dentist models.User
@@ -40,9 +49,11 @@ func CreatePrescription(c *gin.Context) {
 	var settings models.TenantSettings
 	settingsFound := database.DB.Table("public.tenant_settings").Where("tenant_id = ?", tenantID).First(&settings).Error == nil
 
-	// Set default values
+	// Set default values (controlled prescriptions are numbered when issued)
 	input.DentistID = userID
 	input.Status = "draft"
+	input.ControlledNumber = nil
+	input.ControlledPrescriberID = nil
 
 	// Cache clinic info from settings (dynamic) or tenant (fallback)
 	if settingsFound && settings.ClinicName != "" {
@@ -95,21 +106,65 @@ func CreatePrescription(c *gin.Context) {
 		input.SignerCRO = dentist.CRO
 	}
 
+	// Structured items come from the medication catalog and are checked against
+	// the patient's allergies and medications in use
+	items, medications, err := resolvePrescriptionItems(db, input.Items)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validatePrescriptionItems(c, input.Type, items) {
+		return
+	}
+	check, ok := runPrescriptionSafetyCheck(c, db, input.PatientID, medications, req.PrescriptionOverride, "create")
+	if !ok {
+		return
+	}
+	if len(items) > 0 {
+		input.Medications = helpers.FormatPrescriptionItems(items)
+	}
+
 	// Clear relationship pointers to avoid GORM confusion during insert
 	input.Patient = nil
 	input.Dentist = nil
 	input.Signer = nil
+	input.Items = nil
+	input.SafetyChecks = nil
 
 	// Create prescription using explicit table to avoid GORM relationship confusion
-	if err := db.Table("prescriptions").Create(&input).Error; err != nil {
+	err = db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("prescriptions").Create(&input).Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		for i := range items {
+			items[i].PrescriptionID = input.ID
+		}
+		if err := tx.Create(&items).Error; err != nil {
+			return err
+		}
+		return recordPrescriptionSafetyCheck(tx, input.ID, userID, check)
+	})
+	if err != nil {
 		log.Printf("ERROR creating prescription: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create prescription", "details": err.Error()})
 		return
 	}
 
+	if check.Overridden {
+		helpers.AuditAction(c, "override_warnings", "prescriptions", input.ID, true, map[string]interface{}{
+			"stage":    check.Stage,
+			"warnings": check.Warnings,
+			"reason":   check.Reason,
+		})
+	}
+
 	// Load the created prescription with relationships
 	var prescription models.Prescription
-	db.Preload("Patient").Preload("Dentist").Preload("Signer").First(&prescription, input.ID)
+	db.Preload("Patient").Preload("Dentist").Preload("Signer").Preload("Items", func(tx *gorm.DB) *gorm.DB { return tx.Order("position ASC") }).
+		First(&prescription, input.ID)
 
 	c.JSON(http.StatusCreated, gin.H{"prescription": prescription})
 }
@@ -146,6 +201,7 @@ func GetPrescriptions(c *gin.Context) {
 
 	var prescriptions []models.Prescription
 	if err := query.Preload("Patient").Preload("Dentist").Preload("Signer").
+		Preload("Items", func(tx *gorm.DB) *gorm.DB { return tx.Order("position ASC") }).
 		Offset(offset).Limit(pageSize).Order("created_at DESC").
 		Find(&prescriptions).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch prescriptions"})
@@ -170,6 +226,8 @@ func GetPrescription(c *gin.Context) {
 
 	var prescription models.Prescription
 	if err := db.Preload("Patient").Preload("Dentist").Preload("Signer").
+		Preload("Items", func(tx *gorm.DB) *gorm.DB { return tx.Order("position ASC") }).
+		Preload("SafetyChecks", func(tx *gorm.DB) *gorm.DB { return tx.Order("created_at ASC") }).
 		First(&prescription, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Prescription not found"})
 		return
@@ -209,24 +267,73 @@ func UpdatePrescription(c *gin.Context) {
 		}
 	}
 
+	// Structured items are replaced when sent; they are checked again when the prescription is issued
+	var items []models.PrescriptionItem
+	if input.Items != nil {
+		if prescription.Status != "draft" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Os medicamentos de uma receita emitida não podem ser alterados"})
+			return
+		}
+		resolved, _, err := resolvePrescriptionItems(db, input.Items)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		items = resolved
+		if len(items) > 0 {
+			input.Medications = helpers.FormatPrescriptionItems(items)
+		}
+	}
+
+	// A numbered controlled prescription keeps its type
+	if prescription.ControlledNumber != nil && input.Type != prescription.Type {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "O tipo de uma receita de controle especial emitida não pode ser alterado"})
+		return
+	}
+	typeItems := items
+	if input.Items == nil {
+		db.Session(&gorm.Session{NewDB: true}).Where("prescription_id = ?", prescription.ID).Order("position ASC").Find(&typeItems)
+	}
+	if !validatePrescriptionItems(c, input.Type, typeItems) {
+		return
+	}
+
 	// Update using Exec to avoid the duplicate table error
-	result := db.Exec(`
-		UPDATE prescriptions
-		SET patient_id = ?, type = ?, title = ?, medications = ?, content = ?,
-		    diagnosis = ?, valid_until = ?, notes = ?, prescription_date = ?,
-		    signer_id = ?, signer_name = ?, signer_cro = ?, updated_at = NOW()
-		WHERE id = ? AND deleted_at IS NULL
-	`, input.PatientID, input.Type, input.Title, input.Medications, input.Content,
-		input.Diagnosis, input.ValidUntil, input.Notes, input.PrescriptionDate,
-		input.SignerID, signerName, signerCRO, id)
-
-	if result.Error != nil {
+	err := db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`
+			UPDATE prescriptions
+			SET patient_id = ?, type = ?, title = ?, medications = ?, content = ?,
+			    diagnosis = ?, valid_until = ?, notes = ?, prescription_date = ?,
+			    signer_id = ?, signer_name = ?, signer_cro = ?, updated_at = NOW()
+			WHERE id = ? AND deleted_at IS NULL
+		`, input.PatientID, input.Type, input.Title, input.Medications, input.Content,
+			input.Diagnosis, input.ValidUntil, input.Notes, input.PrescriptionDate,
+			input.SignerID, signerName, signerCRO, id).Error; err != nil {
+			return err
+		}
+		if input.Items == nil {
+			return nil
+		}
+		if err := tx.Where("prescription_id = ?", prescription.ID).Delete(&models.PrescriptionItem{}).Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		for i := range items {
+			items[i].PrescriptionID = prescription.ID
+		}
+		return tx.Create(&items).Error
+	})
+
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update prescription"})
 		return
 	}
 
 	// Load the updated prescription with relationships using raw SQL
 	db.Raw("SELECT * FROM prescriptions WHERE id = ? AND deleted_at IS NULL", id).Scan(&prescription)
+	db.Session(&gorm.Session{NewDB: true}).Where("prescription_id = ?", prescription.ID).Order("position ASC").Find(&prescription.Items)
 
 	c.JSON(http.StatusOK, gin.H{"prescription": prescription})
 }
@@ -250,18 +357,47 @@ func IssuePrescription(c *gin.Context) {
 		return
 	}
 
+	// Optional body: {"override_warnings": true, "override_reason": "..."}
+	var override PrescriptionOverride
+	c.ShouldBindJSON(&override)
+
+	check, hasItems, ok := checkPrescriptionBeforeIssue(c, db, prescription, override, "issue")
+	if !ok {
+		return
+	}
+
 	now := time.Now()
 
 	// Update using Model to avoid the duplicate table error
-	if err := db.Model(&models.Prescription{}).Where("id = ?", id).Updates(map[string]interface{}{
-		"status":     "issued",
-		"issued_at":  now,
-		"updated_at": now,
-	}).Error; err != nil {
+	err := db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		if err := assignControlledNumber(tx, &prescription, controlledPrescriberID(prescription)); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Prescription{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"status":     "issued",
+			"issued_at":  now,
+			"updated_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		if !hasItems {
+			return nil
+		}
+		return recordPrescriptionSafetyCheck(tx, prescription.ID, c.GetUint("user_id"), check)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue prescription"})
 		return
 	}
 
+	if check.Overridden {
+		helpers.AuditAction(c, "override_warnings", "prescriptions", prescription.ID, true, map[string]interface{}{
+			"stage":    check.Stage,
+			"warnings": check.Warnings,
+			"reason":   check.Reason,
+		})
+	}
+
 	// Load relationships
 	db.Preload("Patient").Preload("Dentist").First(&prescription, id)
 
@@ -327,6 +463,12 @@ func GeneratePrescriptionPDF(c *gin.Context) {
 		return
 	}
 
+	// Controlled prescriptions use the official two-copy form
+	if prescription.Type == models.PrescriptionTypeControlled {
+		outputControlledPrescriptionPDF(c, db, &prescription, fmt.Sprintf("receita_controle_especial_%s.pdf", id))
+		return
+	}
+
 	// Create PDF with UTF-8 support
 	pdf := gofpdf.New("P", "mm", "A4", "")
 	pdf.AddPage()
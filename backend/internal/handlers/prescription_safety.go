@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PrescriptionOverride lets the professional go ahead despite allergy/interaction warnings
+type PrescriptionOverride struct {
+	OverrideWarnings bool   `json:"override_warnings"`
+	OverrideReason   string `json:"override_reason"`
+}
+
+// prescriptionSafetyCheck is the outcome of a check, stored by recordPrescriptionSafetyCheck
+type prescriptionSafetyCheck struct {
+	Stage      string
+	Warnings   []helpers.PrescriptionWarning
+	Overridden bool
+	Reason     string
+}
+
+// resolvePrescriptionItems fills the items from the medication catalog, in the given order
+func resolvePrescriptionItems(db *gorm.DB, items []models.PrescriptionItem) ([]models.PrescriptionItem, []models.Medication, error) {
+	resolved := make([]models.PrescriptionItem, 0, len(items))
+	medications := make([]models.Medication, 0, len(items))
+	for i, item := range items {
+		var medication models.Medication
+		if err := db.Session(&gorm.Session{NewDB: true}).First(&medication, item.MedicationID).Error; err != nil {
+			return nil, nil, fmt.Errorf("Medicamento %d não encontrado no catálogo", item.MedicationID)
+		}
+		if !medication.Active {
+			return nil, nil, fmt.Errorf("Medicamento inativo no catálogo: %s", medication.Name)
+		}
+
+		item.ID = 0
+		item.Position = i + 1
+		item.MedicationName = medication.Name
+		item.ActiveIngredient = medication.ActiveIngredient
+		item.ControlledClass = medication.ControlledClass
+		item.Presentation = strings.TrimSpace(item.Presentation)
+		item.Route = strings.TrimSpace(item.Route)
+		item.Quantity = strings.TrimSpace(item.Quantity)
+		item.Dosage = strings.TrimSpace(item.Dosage)
+		if item.Dosage == "" && len(medication.DentalDosages) > 0 {
+			item.Dosage = medication.DentalDosages[0]
+		}
+		resolved = append(resolved, item)
+		medications = append(medications, medication)
+	}
+	return resolved, medications, nil
+}
+
+// loadItemMedications returns the catalog entries of stored items (deleted entries included)
+func loadItemMedications(db *gorm.DB, items []models.PrescriptionItem) []models.Medication {
+	medications := make([]models.Medication, 0, len(items))
+	for _, item := range items {
+		var medication models.Medication
+		if err := db.Session(&gorm.Session{NewDB: true}).Unscoped().First(&medication, item.MedicationID).Error; err != nil {
+			// Fall back to the copy kept on the item
+			medication = models.Medication{Name: item.MedicationName, ActiveIngredient: item.ActiveIngredient}
+		}
+		medications = append(medications, medication)
+	}
+	return medications
+}
+
+// runPrescriptionSafetyCheck checks the medications against the patient. When there are
+// warnings that were not overridden it answers 409 with the warnings and returns false
+func runPrescriptionSafetyCheck(c *gin.Context, db *gorm.DB, patientID uint, medications []models.Medication, override PrescriptionOverride, stage string) (prescriptionSafetyCheck, bool) {
+	check := prescriptionSafetyCheck{Stage: stage, Warnings: []helpers.PrescriptionWarning{}}
+	if len(medications) == 0 {
+		return check, true
+	}
+
+	var patient models.Patient
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&patient, patientID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Paciente não encontrado"})
+		return check, false
+	}
+
+	check.Warnings = helpers.CheckPrescriptionSafety(medications, patient.Allergies, patient.Medications)
+	if len(check.Warnings) == 0 {
+		return check, true
+	}
+
+	if !override.OverrideWarnings {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":             "A receita tem alertas de alergia ou interação medicamentosa. Revise ou confirme para prosseguir.",
+			"warnings":          check.Warnings,
+			"requires_override": true,
+		})
+		return check, false
+	}
+
+	check.Overridden = true
+	check.Reason = strings.TrimSpace(override.OverrideReason)
+	return check, true
+}
+
+// recordPrescriptionSafetyCheck stores a check of a prescription with structured items
+func recordPrescriptionSafetyCheck(tx *gorm.DB, prescriptionID, userID uint, check prescriptionSafetyCheck) error {
+	warnings, err := json.Marshal(check.Warnings)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.PrescriptionSafetyCheck{
+		PrescriptionID: prescriptionID,
+		Stage:          check.Stage,
+		Warnings:       string(warnings),
+		WarningCount:   len(check.Warnings),
+		Overridden:     check.Overridden,
+		OverrideReason: check.Reason,
+		CheckedByID:    userID,
+	}).Error
+}
+
+// checkPrescriptionBeforeIssue checks again the structured items of a draft being issued (or signed),
+// since the patient's allergies and medications may have changed since it was written.
+// hasItems is false for free-text prescriptions, which have nothing to check or record
+func checkPrescriptionBeforeIssue(c *gin.Context, db *gorm.DB, prescription models.Prescription, override PrescriptionOverride, stage string) (check prescriptionSafetyCheck, hasItems bool, ok bool) {
+	var items []models.PrescriptionItem
+	db.Session(&gorm.Session{NewDB: true}).Where("prescription_id = ?", prescription.ID).Order("position ASC").Find(&items)
+	if len(items) == 0 {
+		return prescriptionSafetyCheck{Stage: stage}, false, true
+	}
+
+	check, ok = runPrescriptionSafetyCheck(c, db, prescription.PatientID, loadItemMedications(db, items), override, stage)
+	return check, true, ok
+}
+
+// CheckPrescriptionRequest is a prescription being written, checked before it is saved
+type CheckPrescriptionRequest struct {
+	PatientID     uint   `json:"patient_id" binding:"required"`
+	MedicationIDs []uint `json:"medication_ids" binding:"required"`
+}
+
+// CheckPrescription returns the allergy and interaction warnings of a set of medications for a patient
+// POST /prescriptions/check
+func CheckPrescription(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var req CheckPrescriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var patient models.Patient
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&patient, req.PatientID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Paciente não encontrado"})
+		return
+	}
+
+	items := make([]models.PrescriptionItem, 0, len(req.MedicationIDs))
+	for _, id := range req.MedicationIDs {
+		items = append(items, models.PrescriptionItem{MedicationID: id})
+	}
+	_, medications, err := resolvePrescriptionItems(db, items)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"warnings": helpers.CheckPrescriptionSafety(medications, patient.Allergies, patient.Medications),
+	})
+}
@@ -0,0 +1,413 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/availability"
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========================================
+// Admin endpoints for managing professional schedules
+// ========================================
+
+// ScheduleBlockInput is a weekly availability block in requests
+type ScheduleBlockInput struct {
+	Weekday   int    `json:"weekday"`
+	StartTime string `json:"start_time" binding:"required"`
+	EndTime   string `json:"end_time" binding:"required"`
+}
+
+// UpdateWeeklyScheduleRequest replaces a professional's weekly template
+type UpdateWeeklyScheduleRequest struct {
+	Blocks []ScheduleBlockInput `json:"blocks"`
+}
+
+// ScheduleOverrideRequest is the payload for creating a date-specific override
+type ScheduleOverrideRequest struct {
+	Date      string `json:"date" binding:"required"` // Format: YYYY-MM-DD
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+	Closed    bool   `json:"closed"`
+	Reason    string `json:"reason"`
+}
+
+// ProfessionalAbsenceRequest is the payload for creating or updating an absence
+type ProfessionalAbsenceRequest struct {
+	StartAt models.LocalTime `json:"start_at" binding:"required"`
+	EndAt   models.LocalTime `json:"end_at" binding:"required"`
+	Type    string           `json:"type"`
+	Reason  string           `json:"reason"`
+}
+
+var validAbsenceTypes = map[string]bool{
+	models.AbsenceTypeVacation:  true,
+	models.AbsenceTypeSickLeave: true,
+	models.AbsenceTypeCourse:    true,
+	models.AbsenceTypeAbsence:   true,
+}
+
+// getScheduleDentistID parses :dentist_id and checks that the professional belongs to the tenant
+func getScheduleDentistID(c *gin.Context, db *gorm.DB) (uint, bool) {
+	dentistID, err := strconv.ParseUint(c.Param("dentist_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID do profissional inválido"})
+		return 0, false
+	}
+
+	var count int64
+	if err := db.Raw("SELECT COUNT(*) FROM public.users WHERE id = ? AND tenant_id = ? AND deleted_at IS NULL",
+		dentistID, c.GetUint("tenant_id")).Scan(&count).Error; err != nil || count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Profissional não encontrado"})
+		return 0, false
+	}
+
+	return uint(dentistID), true
+}
+
+// GetProfessionalSchedule returns the weekly template, upcoming overrides and absences of a professional
+func GetProfessionalSchedule(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	dentistID, ok := getScheduleDentistID(c, db)
+	if !ok {
+		return
+	}
+
+	loc := getTimezone()
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	var blocks []models.ProfessionalSchedule
+	if err := db.Session(&gorm.Session{NewDB: true}).Where("dentist_id = ?", dentistID).
+		Order("weekday ASC, start_time ASC").Find(&blocks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar agenda"})
+		return
+	}
+
+	var overrides []models.ProfessionalScheduleOverride
+	if err := db.Session(&gorm.Session{NewDB: true}).Where("dentist_id = ? AND date >= ?", dentistID, today.Format("2006-01-02")).
+		Order("date ASC, start_time ASC").Find(&overrides).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar exceções da agenda"})
+		return
+	}
+
+	var absences []models.ProfessionalAbsence
+	if err := db.Session(&gorm.Session{NewDB: true}).Where("dentist_id = ? AND end_at >= ?", dentistID, today).
+		Order("start_at ASC").Find(&absences).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar ausências"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"dentist_id": dentistID,
+		"weekly":     blocks,
+		"overrides":  overrides,
+		"absences":   absences,
+		// Without a weekly template the clinic-wide working hours apply
+		"uses_clinic_hours": len(blocks) == 0,
+	})
+}
+
+// UpdateProfessionalWeeklySchedule replaces the weekly template of a professional
+// Sending an empty list makes the professional follow the clinic-wide working hours again
+func UpdateProfessionalWeeklySchedule(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	dentistID, ok := getScheduleDentistID(c, db)
+	if !ok {
+		return
+	}
+
+	var req UpdateWeeklyScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Validate blocks and reject overlaps within the same weekday
+	reference := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	byWeekday := make(map[int][]helpers.TimeRange)
+	for _, b := range req.Blocks {
+		if b.Weekday < 0 || b.Weekday > 6 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Dia da semana inválido (0 = domingo ... 6 = sábado)"})
+			return
+		}
+		r, ok := availability.ClockRange(reference, b.StartTime, b.EndTime)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Horário inválido: %s - %s", b.StartTime, b.EndTime)})
+			return
+		}
+		for _, existing := range byWeekday[b.Weekday] {
+			if existing.Overlaps(r.Start, r.End) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Blocos sobrepostos no dia %d", b.Weekday)})
+				return
+			}
+		}
+		byWeekday[b.Weekday] = append(byWeekday[b.Weekday], r)
+	}
+
+	blocks := make([]models.ProfessionalSchedule, 0, len(req.Blocks))
+	err := db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("UPDATE professional_schedules SET deleted_at = ? WHERE dentist_id = ? AND deleted_at IS NULL",
+			time.Now(), dentistID).Error; err != nil {
+			return err
+		}
+		for _, b := range req.Blocks {
+			block := models.ProfessionalSchedule{
+				DentistID: dentistID,
+				Weekday:   b.Weekday,
+				StartTime: b.StartTime,
+				EndTime:   b.EndTime,
+			}
+			if err := tx.Create(&block).Error; err != nil {
+				return err
+			}
+			blocks = append(blocks, block)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao salvar agenda"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"weekly": blocks})
+}
+
+// CreateProfessionalScheduleOverride adds date-specific working hours (or a day off) for a professional
+func CreateProfessionalScheduleOverride(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	dentistID, ok := getScheduleDentistID(c, db)
+	if !ok {
+		return
+	}
+
+	var req ScheduleOverrideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	date, err := time.Parse("2006-01-02", req.Date)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Formato de data inválido. Use YYYY-MM-DD"})
+		return
+	}
+
+	override := models.ProfessionalScheduleOverride{
+		DentistID: dentistID,
+		Date:      date,
+		Closed:    req.Closed,
+		Reason:    req.Reason,
+	}
+	if !req.Closed {
+		if _, ok := availability.ClockRange(date, req.StartTime, req.EndTime); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Informe start_time e end_time (HH:MM) ou closed=true"})
+			return
+		}
+		override.StartTime = req.StartTime
+		override.EndTime = req.EndTime
+	}
+
+	if err := db.Session(&gorm.Session{NewDB: true}).Create(&override).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar exceção da agenda"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"override": override})
+}
+
+// DeleteProfessionalScheduleOverride removes a date-specific override
+func DeleteProfessionalScheduleOverride(c *gin.Context) {
+	id := c.Param("id")
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	result := db.Session(&gorm.Session{NewDB: true}).Delete(&models.ProfessionalScheduleOverride{}, id)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao remover exceção da agenda"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exceção da agenda não encontrada"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Exceção da agenda removida com sucesso"})
+}
+
+// validateAbsenceRequest checks the period and type of an absence
+func validateAbsenceRequest(c *gin.Context, req *ProfessionalAbsenceRequest) bool {
+	if !req.EndAt.Time.After(req.StartAt.Time) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "O fim da ausência deve ser posterior ao início"})
+		return false
+	}
+	if req.Type == "" {
+		req.Type = models.AbsenceTypeAbsence
+	}
+	if !validAbsenceTypes[req.Type] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tipo de ausência inválido. Use vacation, sick_leave, course ou absence"})
+		return false
+	}
+	return true
+}
+
+// countAppointmentsDuring returns how many active appointments the dentist has in a period
+// Used to warn the clinic which appointments must be rescheduled after registering an absence
+func countAppointmentsDuring(db *gorm.DB, dentistID uint, start, end models.LocalTime) int64 {
+	var count int64
+	db.Session(&gorm.Session{NewDB: true}).Model(&models.Appointment{}).
+		Where("dentist_id = ?", dentistID).
+		Where("status IN ?", []string{"scheduled", "confirmed"}).
+		Where("start_time < ? AND end_time > ?", end, start).
+		Count(&count)
+	return count
+}
+
+// CreateProfessionalAbsence registers a vacation or absence for a professional
+func CreateProfessionalAbsence(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	dentistID, ok := getScheduleDentistID(c, db)
+	if !ok {
+		return
+	}
+
+	var req ProfessionalAbsenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validateAbsenceRequest(c, &req) {
+		return
+	}
+
+	absence := models.ProfessionalAbsence{
+		DentistID: dentistID,
+		StartAt:   req.StartAt,
+		EndAt:     req.EndAt,
+		Type:      req.Type,
+		Reason:    req.Reason,
+		CreatedBy: c.GetUint("user_id"),
+	}
+
+	if err := db.Session(&gorm.Session{NewDB: true}).Create(&absence).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao registrar ausência"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"absence":               absence,
+		"affected_appointments": countAppointmentsDuring(db, dentistID, absence.StartAt, absence.EndAt),
+	})
+}
+
+// UpdateProfessionalAbsence changes the period, type or reason of an absence
+func UpdateProfessionalAbsence(c *gin.Context) {
+	id := c.Param("id")
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var absence models.ProfessionalAbsence
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&absence, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ausência não encontrada"})
+		return
+	}
+
+	var req ProfessionalAbsenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validateAbsenceRequest(c, &req) {
+		return
+	}
+
+	// Update fields directly (avoid GORM FROM clause issue)
+	if err := db.Session(&gorm.Session{NewDB: true}).Exec(`
+		UPDATE professional_absences
+		SET start_at = ?, end_at = ?, type = ?, reason = ?, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL
+	`, req.StartAt, req.EndAt, req.Type, req.Reason, time.Now(), absence.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar ausência"})
+		return
+	}
+
+	absence.StartAt = req.StartAt
+	absence.EndAt = req.EndAt
+	absence.Type = req.Type
+	absence.Reason = req.Reason
+
+	c.JSON(http.StatusOK, gin.H{
+		"absence":               absence,
+		"affected_appointments": countAppointmentsDuring(db, absence.DentistID, absence.StartAt, absence.EndAt),
+	})
+}
+
+// DeleteProfessionalAbsence removes an absence
+func DeleteProfessionalAbsence(c *gin.Context) {
+	id := c.Param("id")
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	result := db.Session(&gorm.Session{NewDB: true}).Delete(&models.ProfessionalAbsence{}, id)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao remover ausência"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ausência não encontrada"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ausência removida com sucesso"})
+}
+
+// GetProfessionalAvailability returns the bookable ranges of a professional on a date
+// (weekly template or clinic hours, overrides and absences resolved)
+func GetProfessionalAvailability(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	dentistID, ok := getScheduleDentistID(c, db)
+	if !ok {
+		return
+	}
+
+	loc := getTimezone()
+	date, err := time.ParseInLocation("2006-01-02", c.Query("date"), loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Formato de data inválido. Use YYYY-MM-DD"})
+		return
+	}
+
+	var settings models.TenantSettings
+	db.Session(&gorm.Session{NewDB: true}).Table("public.tenant_settings").Where("tenant_id = ?", c.GetUint("tenant_id")).First(&settings)
+
+	schedule, err := availability.LoadDaySchedule(db, dentistID, date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao calcular disponibilidade"})
+		return
+	}
+
+	ranges := schedule.AvailableRanges(availability.ClinicRanges(settings, date))
+	result := make([]gin.H, 0, len(ranges))
+	for _, r := range ranges {
+		result = append(result, gin.H{
+			"start_time": r.Start.Format("15:04"),
+			"end_time":   r.End.Format("15:04"),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"date":              date.Format("2006-01-02"),
+		"dentist_id":        dentistID,
+		"uses_clinic_hours": !schedule.Configured,
+		"available":         result,
+	})
+}
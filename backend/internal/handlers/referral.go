@@ -0,0 +1,543 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/database"
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ReferralRequest is the payload to create or update a referral
+type ReferralRequest struct {
+	PatientID       uint       `json:"patient_id"`
+	SpecialistID    uint       `json:"specialist_id"`
+	Reason          string     `json:"reason"`
+	ClinicalSummary string     `json:"clinical_summary"`
+	Urgency         string     `json:"urgency"`
+	Notes           string     `json:"notes"`
+	FollowUpDate    *time.Time `json:"follow_up_date"` // Replaces the deadline of the urgency
+}
+
+// ReferralStatusRequest moves a referral to scheduled, back to sent or to cancelled
+type ReferralStatusRequest struct {
+	Status       string     `json:"status" binding:"required"`
+	ScheduledFor *time.Time `json:"scheduled_for"`
+	CancelReason string     `json:"cancel_reason"`
+}
+
+// ReferralReportRequest links a report already in the patient's exams
+type ReferralReportRequest struct {
+	ExamID      uint   `json:"exam_id" binding:"required"`
+	ReportNotes string `json:"report_notes"`
+}
+
+// ReferralResponse is a referral with its follow-up situation
+type ReferralResponse struct {
+	models.Referral
+	Overdue     bool `json:"overdue"`
+	DaysOverdue int  `json:"days_overdue"`
+}
+
+// referralIsOpen reports whether the referral is still waiting for the specialist
+func referralIsOpen(status string) bool {
+	return status == models.ReferralStatusSent || status == models.ReferralStatusScheduled
+}
+
+func toReferralResponse(referral models.Referral, now time.Time) ReferralResponse {
+	response := ReferralResponse{Referral: referral}
+	if referralIsOpen(referral.Status) && referral.FollowUpDate != nil {
+		response.DaysOverdue = helpers.ReferralDaysOverdue(*referral.FollowUpDate, now)
+		response.Overdue = response.DaysOverdue > 0
+	}
+	return response
+}
+
+// referralUrgencyOrder sorts the most urgent referrals first
+const referralUrgencyOrder = "CASE urgency WHEN 'urgent' THEN 0 WHEN 'priority' THEN 1 ELSE 2 END"
+
+// loadReferral loads a referral with its patient and specialist
+func loadReferral(db *gorm.DB, id interface{}) (models.Referral, error) {
+	var referral models.Referral
+	err := db.Session(&gorm.Session{NewDB: true}).Preload("Patient").Preload("Specialist").First(&referral, id).Error
+	return referral, err
+}
+
+// validateReferralRequest normalizes the payload and returns an error message when it is invalid
+func validateReferralRequest(req *ReferralRequest) string {
+	req.Reason = strings.TrimSpace(req.Reason)
+	req.Urgency = strings.TrimSpace(req.Urgency)
+	if req.Urgency == "" {
+		req.Urgency = models.ReferralUrgencyRoutine
+	}
+	if req.Reason == "" {
+		return "Motivo do encaminhamento é obrigatório"
+	}
+	if !models.IsValidReferralUrgency(req.Urgency) {
+		return "Urgência inválida (use routine, priority ou urgent)"
+	}
+	return ""
+}
+
+// CreateReferral refers a patient to an external specialist
+// POST /referrals
+func CreateReferral(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+	userID := c.GetUint("user_id")
+
+	var req ReferralRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := validateReferralRequest(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var patient models.Patient
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&patient, req.PatientID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Paciente não encontrado"})
+		return
+	}
+	var specialist models.ExternalSpecialist
+	if err := db.Session(&gorm.Session{NewDB: true}).Where("active = ?", true).First(&specialist, req.SpecialistID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Especialista não encontrado"})
+		return
+	}
+
+	// Referring professional (public schema)
+	var dentist models.User
+	if err := database.DB.Table("public.users").First(&dentist, userID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar dados do profissional"})
+		return
+	}
+
+	now := time.Now()
+	followUp := helpers.ReferralFollowUpDate(req.Urgency, now, nil)
+	if req.FollowUpDate != nil {
+		followUp = *req.FollowUpDate
+	}
+
+	referral := models.Referral{
+		PatientID:           patient.ID,
+		DentistID:           userID,
+		DentistName:         dentist.Name,
+		DentistCRO:          dentist.CRO,
+		SpecialistID:        specialist.ID,
+		SpecialistName:      specialist.Name,
+		SpecialistSpecialty: specialist.Specialty,
+		SpecialistCouncil:   specialistCouncil(&specialist),
+		Reason:              req.Reason,
+		ClinicalSummary:     strings.TrimSpace(req.ClinicalSummary),
+		Urgency:             req.Urgency,
+		Status:              models.ReferralStatusSent,
+		SentAt:              now,
+		FollowUpDate:        &followUp,
+		Notes:               req.Notes,
+		CreatedByID:         userID,
+	}
+	if err := db.Session(&gorm.Session{NewDB: true}).Create(&referral).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar encaminhamento"})
+		return
+	}
+
+	helpers.AuditAction(c, "create", "referrals", referral.ID, true, map[string]interface{}{
+		"patient_id":    patient.ID,
+		"specialist_id": specialist.ID,
+		"urgency":       referral.Urgency,
+	})
+
+	referral, _ = loadReferral(db, referral.ID)
+	c.JSON(http.StatusCreated, gin.H{"referral": toReferralResponse(referral, now)})
+}
+
+// GetReferrals lists referrals
+// GET /referrals?patient_id=&status=&specialist_id=&urgency=&dentist_id=&page=&page_size=
+func GetReferrals(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	offset := (page - 1) * pageSize
+
+	query := db.Session(&gorm.Session{NewDB: true}).Model(&models.Referral{})
+	if patientID := c.Query("patient_id"); patientID != "" {
+		query = query.Where("patient_id = ?", patientID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if specialistID := c.Query("specialist_id"); specialistID != "" {
+		query = query.Where("specialist_id = ?", specialistID)
+	}
+	if urgency := c.Query("urgency"); urgency != "" {
+		query = query.Where("urgency = ?", urgency)
+	}
+	if dentistID := c.Query("dentist_id"); dentistID != "" {
+		query = query.Where("dentist_id = ?", dentistID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var referrals []models.Referral
+	if err := query.Preload("Patient").Offset(offset).Limit(pageSize).Order("sent_at DESC").Find(&referrals).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar encaminhamentos"})
+		return
+	}
+
+	now := time.Now()
+	response := make([]ReferralResponse, 0, len(referrals))
+	for _, referral := range referrals {
+		response = append(response, toReferralResponse(referral, now))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"referrals": response,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// GetOverdueReferrals is the worklist of open referrals past their follow-up date: patients who
+// did not see the specialist or whose report did not come back. Most urgent and oldest first
+// GET /referrals/overdue?dentist_id=&mine=true
+func GetOverdueReferrals(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	query := db.Session(&gorm.Session{NewDB: true}).Model(&models.Referral{}).
+		Where("status IN ? AND follow_up_date < ?", []string{models.ReferralStatusSent, models.ReferralStatusScheduled}, today)
+	if c.Query("mine") == "true" {
+		query = query.Where("dentist_id = ?", c.GetUint("user_id"))
+	} else if dentistID := c.Query("dentist_id"); dentistID != "" {
+		query = query.Where("dentist_id = ?", dentistID)
+	}
+
+	var referrals []models.Referral
+	if err := query.Preload("Patient").Order(referralUrgencyOrder).Order("follow_up_date ASC").Find(&referrals).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar encaminhamentos atrasados"})
+		return
+	}
+
+	byUrgency := map[string]int{
+		models.ReferralUrgencyUrgent:   0,
+		models.ReferralUrgencyPriority: 0,
+		models.ReferralUrgencyRoutine:  0,
+	}
+	response := make([]ReferralResponse, 0, len(referrals))
+	for _, referral := range referrals {
+		response = append(response, toReferralResponse(referral, now))
+		byUrgency[referral.Urgency]++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"referrals":  response,
+		"total":      len(response),
+		"by_urgency": byUrgency,
+	})
+}
+
+// GetReferral returns a referral with the returned report
+// GET /referrals/:id
+func GetReferral(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	referral, err := loadReferral(db, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Encaminhamento não encontrado"})
+		return
+	}
+
+	response := gin.H{"referral": toReferralResponse(referral, time.Now())}
+	if referral.ReportExamID != nil {
+		var exam models.Exam
+		if db.Session(&gorm.Session{NewDB: true}).First(&exam, *referral.ReportExamID).Error == nil {
+			response["report_exam"] = enrichExamWithRelatedData(db, &exam)
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// UpdateReferral updates the reason, clinical summary, urgency and notes of an open referral
+// PUT /referrals/:id
+func UpdateReferral(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	referral, err := loadReferral(db, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Encaminhamento não encontrado"})
+		return
+	}
+	if !referralIsOpen(referral.Status) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Encaminhamento encerrado não pode ser alterado"})
+		return
+	}
+
+	// Fields missing from the payload keep their current values
+	req := ReferralRequest{
+		Reason:          referral.Reason,
+		ClinicalSummary: referral.ClinicalSummary,
+		Urgency:         referral.Urgency,
+		Notes:           referral.Notes,
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := validateReferralRequest(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	// A new urgency moves the deadline of referrals still waiting for the appointment
+	followUp := referral.FollowUpDate
+	if req.FollowUpDate != nil {
+		followUp = req.FollowUpDate
+	} else if req.Urgency != referral.Urgency && referral.Status == models.ReferralStatusSent {
+		date := helpers.ReferralFollowUpDate(req.Urgency, referral.SentAt, nil)
+		followUp = &date
+	}
+
+	if err := db.Session(&gorm.Session{NewDB: true}).Exec(`
+		UPDATE referrals
+		SET reason = ?, clinical_summary = ?, urgency = ?, notes = ?, follow_up_date = ?, updated_at = ?
+		WHERE id = ?
+	`, req.Reason, strings.TrimSpace(req.ClinicalSummary), req.Urgency, req.Notes, followUp, time.Now(), referral.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar encaminhamento"})
+		return
+	}
+
+	referral, _ = loadReferral(db, referral.ID)
+	c.JSON(http.StatusOK, gin.H{"referral": toReferralResponse(referral, time.Now())})
+}
+
+// UpdateReferralStatus records the appointment with the specialist, its cancellation (back to sent)
+// or the cancellation of the referral. The report is attached with AttachReferralReport
+// PUT /referrals/:id/status
+func UpdateReferralStatus(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	referral, err := loadReferral(db, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Encaminhamento não encontrado"})
+		return
+	}
+
+	var req ReferralStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Status == models.ReferralStatusReportReceived {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Anexe o relatório do especialista para concluir o encaminhamento"})
+		return
+	}
+	if !helpers.CanChangeReferralStatus(referral.Status, req.Status) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Não é possível alterar o encaminhamento de %s para %s", referral.Status, req.Status)})
+		return
+	}
+
+	now := time.Now()
+	scheduledFor, followUp, cancelledAt := referral.ScheduledFor, referral.FollowUpDate, referral.CancelledAt
+	cancelReason := referral.CancelReason
+	switch req.Status {
+	case models.ReferralStatusScheduled:
+		if req.ScheduledFor == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Data da consulta com o especialista é obrigatória"})
+			return
+		}
+		date := helpers.ReferralFollowUpDate(referral.Urgency, referral.SentAt, req.ScheduledFor)
+		scheduledFor, followUp = req.ScheduledFor, &date
+	case models.ReferralStatusSent:
+		// Appointment cancelled: the deadline of the urgency applies again
+		date := helpers.ReferralFollowUpDate(referral.Urgency, referral.SentAt, nil)
+		scheduledFor, followUp = nil, &date
+	case models.ReferralStatusCancelled:
+		cancelledAt, cancelReason = &now, strings.TrimSpace(req.CancelReason)
+	}
+
+	if err := db.Session(&gorm.Session{NewDB: true}).Exec(`
+		UPDATE referrals
+		SET status = ?, scheduled_for = ?, follow_up_date = ?, cancelled_at = ?, cancel_reason = ?, updated_at = ?
+		WHERE id = ?
+	`, req.Status, scheduledFor, followUp, cancelledAt, cancelReason, now, referral.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar encaminhamento"})
+		return
+	}
+
+	helpers.AuditAction(c, "update_status", "referrals", referral.ID, true, map[string]interface{}{
+		"from": referral.Status,
+		"to":   req.Status,
+	})
+
+	referral, _ = loadReferral(db, referral.ID)
+	c.JSON(http.StatusOK, gin.H{"referral": toReferralResponse(referral, now)})
+}
+
+// AttachReferralReport closes the referral with the report returned by the specialist. The report
+// is uploaded as a file (multipart "file", stored with the patient's exams) or is an exam already
+// uploaded for the patient (JSON exam_id)
+// POST /referrals/:id/report
+func AttachReferralReport(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+	userID := c.GetUint("user_id")
+
+	referral, err := loadReferral(db, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Encaminhamento não encontrado"})
+		return
+	}
+	if !helpers.CanChangeReferralStatus(referral.Status, models.ReferralStatusReportReceived) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Encaminhamento já encerrado"})
+		return
+	}
+
+	var exam models.Exam
+	var reportNotes string
+	var storedKey, storedURL string
+	s3Client := examS3Client()
+
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Arquivo do relatório é obrigatório"})
+			return
+		}
+		if header.Size > maxExamFileSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Arquivo excede o limite de 50MB"})
+			return
+		}
+		data, err := readMultipartFile(header)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Erro ao ler arquivo"})
+			return
+		}
+		fileType := helpers.DetectFileType(data)
+		if fileType != helpers.FileTypePDF && fileType != helpers.FileTypeImage && fileType != helpers.FileTypeDocument {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Tipo de arquivo inválido. Permitidos: PDF, imagem ou documento"})
+			return
+		}
+		if fileType == helpers.FileTypeImage {
+			if stripped, changed := helpers.StripJPEGGPS(data); changed {
+				data = stripped
+			}
+		}
+		reportNotes = c.PostForm("report_notes")
+
+		// Stored with the patient's exams: [tenant]/[exams]/[cpf]/referrals/[id]/[timestamp]_[file]
+		fileName := filepath.Base(header.Filename)
+		storedKey = fmt.Sprintf("referrals/%d/%d_%s", referral.ID, time.Now().Unix(), fileName)
+		if s3Client != nil {
+			_, _, baseFolder := getS3Config()
+			storedKey = fmt.Sprintf("%s/%s/%s/%s", getTenantS3Prefix(c), baseFolder, sanitizeCPF(referral.Patient.CPF), storedKey)
+		}
+		contentType := header.Header.Get("Content-Type")
+		if storedURL, err = putExamObject(s3Client, storedKey, data, contentType); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Erro ao armazenar relatório: %v", err)})
+			return
+		}
+
+		name := strings.TrimSpace(c.PostForm("name"))
+		if name == "" {
+			name = "Relatório de encaminhamento - " + referral.SpecialistSpecialty
+		}
+		now := time.Now()
+		exam = models.Exam{
+			PatientID:    referral.PatientID,
+			Name:         name,
+			Description:  fmt.Sprintf("Contrarreferência de %s (%s)", referral.SpecialistName, referral.SpecialistSpecialty),
+			ExamType:     "laudo",
+			ExamDate:     &now,
+			FileURL:      storedURL,
+			S3Key:        storedKey,
+			FileName:     fileName,
+			FileType:     contentType,
+			FileSize:     int64(len(data)),
+			UploadedByID: userID,
+		}
+	} else {
+		var req ReferralReportRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := db.Session(&gorm.Session{NewDB: true}).Where("patient_id = ?", referral.PatientID).First(&exam, req.ExamID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Exame do paciente não encontrado"})
+			return
+		}
+		reportNotes = req.ReportNotes
+	}
+
+	now := time.Now()
+	err = db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		if exam.ID == 0 {
+			if err := tx.Create(&exam).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Exec(`
+			UPDATE referrals
+			SET status = ?, report_received_at = ?, report_exam_id = ?, report_notes = ?, updated_at = ?
+			WHERE id = ?
+		`, models.ReferralStatusReportReceived, now, exam.ID, strings.TrimSpace(reportNotes), now, referral.ID).Error
+	})
+	if err != nil {
+		if storedKey != "" {
+			deleteExamObject(s3Client, storedKey, storedURL)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao registrar relatório"})
+		return
+	}
+
+	// Uploaded reports get a thumbnail and preview like any other exam
+	if storedKey != "" {
+		queueExamPreviews(c, db, exam.ID)
+	}
+
+	helpers.AuditAction(c, "attach_report", "referrals", referral.ID, true, map[string]interface{}{
+		"exam_id": exam.ID,
+	})
+
+	referral, _ = loadReferral(db, referral.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"referral":    toReferralResponse(referral, now),
+		"report_exam": enrichExamWithRelatedData(db, &exam),
+	})
+}
+
+// DeleteReferral removes a referral. The returned report stays in the patient's exams
+// DELETE /referrals/:id
+func DeleteReferral(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var referral models.Referral
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&referral, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Encaminhamento não encontrado"})
+		return
+	}
+
+	if err := db.Session(&gorm.Session{NewDB: true}).Delete(&models.Referral{}, referral.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao remover encaminhamento"})
+		return
+	}
+
+	helpers.AuditAction(c, "delete", "referrals", referral.ID, true, map[string]interface{}{
+		"patient_id": referral.PatientID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Encaminhamento removido com sucesso"})
+}
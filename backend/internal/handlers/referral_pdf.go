@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/database"
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// referralUrgencyLabels are printed on the letter of non-routine referrals
+var referralUrgencyLabels = map[string]string{
+	models.ReferralUrgencyPriority: "PRIORITÁRIO",
+	models.ReferralUrgencyUrgent:   "URGENTE",
+}
+
+// referralClinicInfo returns the clinic name, address and phone from the clinic settings,
+// falling back to the tenant registration
+func referralClinicInfo(tenantID uint) (name, address, phone string) {
+	var settings models.TenantSettings
+	if database.DB.Table("public.tenant_settings").Where("tenant_id = ?", tenantID).First(&settings).Error == nil && settings.ClinicName != "" {
+		address = settings.ClinicAddress
+		if settings.ClinicCity != "" {
+			if address != "" {
+				address += ", "
+			}
+			address += settings.ClinicCity
+		}
+		if settings.ClinicState != "" {
+			address += " - " + settings.ClinicState
+		}
+		return settings.ClinicName, address, settings.ClinicPhone
+	}
+
+	var tenant models.Tenant
+	database.DB.Table("public.tenants").Where("id = ?", tenantID).First(&tenant)
+	return tenant.Name, tenant.Address + ", " + tenant.City + " - " + tenant.State, tenant.Phone
+}
+
+// GenerateReferralPDF renders the referral letter, with a counter-referral section for the
+// specialist to fill in and send back
+// GET /referrals/:id/pdf
+func GenerateReferralPDF(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	referral, err := loadReferral(db, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Encaminhamento não encontrado"})
+		return
+	}
+	clinicName, clinicAddress, clinicPhone := referralClinicInfo(c.GetUint("tenant_id"))
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(20, 15, 20)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("cp1252")
+
+	// Header - Clinic info
+	pdf.SetFont("Arial", "B", 16)
+	pdf.CellFormat(0, 10, tr(clinicName), "", 1, "C", false, 0, "")
+	pdf.SetFont("Arial", "", 10)
+	pdf.CellFormat(0, 5, tr(clinicAddress), "", 1, "C", false, 0, "")
+	if clinicPhone != "" {
+		pdf.CellFormat(0, 5, tr(fmt.Sprintf("Tel: %s", clinicPhone)), "", 1, "C", false, 0, "")
+	}
+	pdf.Ln(8)
+
+	// Title
+	pdf.SetFont("Arial", "B", 14)
+	pdf.CellFormat(100, 8, tr("Encaminhamento"), "", 0, "L", false, 0, "")
+	if label, ok := referralUrgencyLabels[referral.Urgency]; ok {
+		pdf.SetTextColor(200, 0, 0)
+		pdf.SetFont("Arial", "B", 11)
+		pdf.CellFormat(0, 8, tr(label), "", 0, "R", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	}
+	pdf.Ln(12)
+
+	// Addressee
+	pdf.SetFont("Arial", "", 11)
+	addressee := "Ao(À) Dr(a). " + referral.SpecialistName
+	if referral.SpecialistCouncil != "" {
+		addressee += " - " + referral.SpecialistCouncil
+	}
+	pdf.MultiCell(0, 6, tr(addressee), "", "L", false)
+	pdf.SetFont("Arial", "", 10)
+	pdf.MultiCell(0, 5, tr(referral.SpecialistSpecialty), "", "L", false)
+	if specialist := referral.Specialist; specialist != nil {
+		contact := specialist.ClinicName
+		if specialist.Address != "" {
+			if contact != "" {
+				contact += " - "
+			}
+			contact += specialist.Address
+			if specialist.City != "" {
+				contact += ", " + specialist.City
+				if specialist.State != "" {
+					contact += "/" + specialist.State
+				}
+			}
+		}
+		if contact != "" {
+			pdf.MultiCell(0, 5, tr(contact), "", "L", false)
+		}
+		if specialist.Phone != "" {
+			pdf.MultiCell(0, 5, tr("Tel: "+specialist.Phone), "", "L", false)
+		}
+	}
+	pdf.Ln(6)
+
+	// Patient
+	patientLine := "Encaminho o(a) paciente " + referral.Patient.Name
+	if referral.Patient.BirthDate != nil {
+		patientLine += fmt.Sprintf(", nascido(a) em %s", referral.Patient.BirthDate.Format("02/01/2006"))
+	}
+	patientLine += ", para avaliação e conduta quanto a:"
+	pdf.SetFont("Arial", "", 11)
+	pdf.MultiCell(0, 6, tr(patientLine), "", "L", false)
+	pdf.Ln(2)
+
+	pdf.SetFont("Arial", "", 10)
+	pdf.MultiCell(0, 5, tr(referral.Reason), "", "L", false)
+	pdf.Ln(4)
+
+	if referral.ClinicalSummary != "" {
+		pdf.SetFont("Arial", "B", 11)
+		pdf.Cell(0, 6, tr("Resumo clínico:"))
+		pdf.Ln(6)
+		pdf.SetFont("Arial", "", 10)
+		pdf.MultiCell(0, 5, tr(referral.ClinicalSummary), "", "L", false)
+		pdf.Ln(4)
+	}
+
+	pdf.SetFont("Arial", "", 10)
+	pdf.MultiCell(0, 5, tr("Solicito, por gentileza, a contrarreferência com os achados e a conduta adotada. "+
+		"Coloco-me à disposição para maiores esclarecimentos."), "", "L", false)
+
+	// Date and signature
+	pdf.Ln(8)
+	pdf.Cell(0, 5, referral.SentAt.Format("02/01/2006"))
+	pdf.Ln(15)
+	pdf.SetX(115)
+	pdf.CellFormat(75, 0, "", "T", 1, "C", false, 0, "")
+	pdf.SetX(115)
+	pdf.SetFont("Arial", "B", 10)
+	pdf.CellFormat(75, 5, tr(referral.DentistName), "", 1, "C", false, 0, "")
+	pdf.SetX(115)
+	pdf.SetFont("Arial", "", 9)
+	pdf.CellFormat(75, 5, tr(fmt.Sprintf("CRO: %s", referral.DentistCRO)), "", 1, "C", false, 0, "")
+
+	// Counter-referral, filled in by hand by the specialist
+	pdf.Ln(10)
+	pdf.SetFillColor(240, 240, 240)
+	pdf.SetFont("Arial", "B", 11)
+	pdf.CellFormat(0, 7, tr("Contrarreferência (preenchimento do especialista)"), "1", 1, "L", true, 0, "")
+	pdf.SetFont("Arial", "", 9)
+	for _, label := range []string{"Diagnóstico:", "Conduta / tratamento realizado:", "Recomendações:"} {
+		pdf.CellFormat(0, 6, tr(label), "LR", 1, "L", false, 0, "")
+		pdf.CellFormat(0, 7, "", "LR", 1, "L", false, 0, "")
+		pdf.CellFormat(0, 7, "", "LRB", 1, "L", false, 0, "")
+	}
+	pdf.CellFormat(0, 10, tr("Data: ____/____/______        Assinatura e carimbo: ________________________________"), "LRB", 1, "L", false, 0, "")
+
+	c.Header("Content-Type", "application/pdf")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=encaminhamento_%d_%s.pdf", referral.ID, time.Now().Format("20060102")))
+	if err := pdf.Output(c.Writer); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar PDF"})
+	}
+}
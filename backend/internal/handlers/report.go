@@ -354,6 +354,17 @@ func GetAttendanceReport(c *gin.Context) {
 	}
 	confirmedQuery.Count(&confirmed)
 
+	// Arrived appointments (aguardando atendimento)
+	var arrived int64
+	arrivedQuery := db.Session(&gorm.Session{NewDB: true}).Table("appointments").Where("status = ?", "arrived")
+	if startDate != "" {
+		arrivedQuery = arrivedQuery.Where("DATE(start_time) >= ?", startDate)
+	}
+	if endDate != "" {
+		arrivedQuery = arrivedQuery.Where("DATE(start_time) <= ?", endDate)
+	}
+	arrivedQuery.Count(&arrived)
+
 	// In progress appointments (em atendimento)
 	var inProgress int64
 	inProgressQuery := db.Session(&gorm.Session{NewDB: true}).Table("appointments").Where("status = ?", "in_progress")
@@ -379,8 +390,14 @@ func GetAttendanceReport(c *gin.Context) {
 		"no_show":         noShow,
 		"scheduled":       scheduled,
 		"confirmed":       confirmed,
+		"arrived":         arrived,
 		"in_progress":     inProgress,
 		"attendance_rate": attendanceRate,
+
+		// No-show rates to spot the dentists, days and hours with most absences
+		"no_show_by_dentist": noShowBreakdown(db, "dentist", startDate, endDate),
+		"no_show_by_weekday": noShowBreakdown(db, "weekday", startDate, endDate),
+		"no_show_by_hour":    noShowBreakdown(db, "hour", startDate, endDate),
 	})
 }
 
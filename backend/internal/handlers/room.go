@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/availability"
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RoomRequest is the payload for creating or updating a room/chair
+type RoomRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Type        string   `json:"type"` // chair, room
+	Description string   `json:"description"`
+	Equipment   []string `json:"equipment"`
+	Active      *bool    `json:"active"`
+}
+
+// toRoom validates the request and converts it to a model
+func (r RoomRequest) toRoom() (models.Room, string) {
+	room := models.Room{
+		Name:        strings.TrimSpace(r.Name),
+		Type:        r.Type,
+		Description: r.Description,
+		Active:      true,
+	}
+	if room.Name == "" {
+		return room, "Nome da sala é obrigatório"
+	}
+	if room.Type == "" {
+		room.Type = models.RoomTypeChair
+	}
+	if room.Type != models.RoomTypeChair && room.Type != models.RoomTypeRoom {
+		return room, "Tipo inválido. Use chair ou room"
+	}
+	if r.Active != nil {
+		room.Active = *r.Active
+	}
+	if len(r.Equipment) > 0 {
+		data, _ := json.Marshal(r.Equipment)
+		equipment := string(data)
+		room.Equipment = &equipment
+	}
+	return room, ""
+}
+
+// CreateRoom registers a chair or room as a bookable resource
+func CreateRoom(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var req RoomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	room, invalid := req.toRoom()
+	if invalid != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": invalid})
+		return
+	}
+
+	if err := db.Create(&room).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar sala"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"room": room})
+}
+
+// GetRooms lists the clinic's chairs and rooms
+func GetRooms(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	query := db.Model(&models.Room{})
+	if active := c.Query("active"); active != "" {
+		query = query.Where("active = ?", active == "true")
+	}
+	if roomType := c.Query("type"); roomType != "" {
+		query = query.Where("type = ?", roomType)
+	}
+
+	var rooms []models.Room
+	if err := query.Order("name ASC").Find(&rooms).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar salas"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rooms": rooms})
+}
+
+// GetRoom returns a single room/chair
+func GetRoom(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var room models.Room
+	if err := db.First(&room, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sala não encontrada"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"room": room})
+}
+
+// UpdateRoom updates a room/chair
+func UpdateRoom(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var room models.Room
+	if err := db.First(&room, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sala não encontrada"})
+		return
+	}
+
+	var req RoomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, invalid := req.toRoom()
+	if invalid != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": invalid})
+		return
+	}
+
+	// Use raw SQL to avoid GORM's FROM clause bug
+	if err := db.Exec(`UPDATE rooms SET name = ?, type = ?, description = ?, equipment = ?, active = ?, updated_at = NOW()
+		WHERE id = ? AND deleted_at IS NULL`,
+		updated.Name, updated.Type, updated.Description, updated.Equipment, updated.Active, room.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar sala"})
+		return
+	}
+
+	db.First(&room, room.ID)
+	c.JSON(http.StatusOK, gin.H{"room": room})
+}
+
+// DeleteRoom soft deletes a room/chair
+// Rooms with upcoming appointments cannot be removed; deactivate them or move the appointments first
+func DeleteRoom(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var room models.Room
+	if err := db.First(&room, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sala não encontrada"})
+		return
+	}
+
+	var upcoming int64
+	db.Session(&gorm.Session{NewDB: true}).Model(&models.Appointment{}).
+		Where("room_id = ? AND start_time >= ? AND status NOT IN ?", room.ID, time.Now(), []string{"cancelled", "no_show", "completed"}).
+		Count(&upcoming)
+	if upcoming > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":    "Sala possui agendamentos futuros",
+			"upcoming": upcoming,
+		})
+		return
+	}
+
+	if err := db.Delete(&room).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir sala"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Sala excluída com sucesso"})
+}
+
+// GetRoomAgenda returns the day's appointments grouped by room/chair
+// GET /rooms/agenda?date=YYYY-MM-DD
+func GetRoomAgenda(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	day := time.Now().In(availability.Location())
+	if date := c.Query("date"); date != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", date, availability.Location())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Formato de data inválido. Use YYYY-MM-DD"})
+			return
+		}
+		day = parsed
+	}
+	dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	dayEnd := dayStart.AddDate(0, 0, 1)
+
+	var rooms []models.Room
+	if err := db.Session(&gorm.Session{NewDB: true}).Where("active = ?", true).Order("name ASC").Find(&rooms).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar salas"})
+		return
+	}
+
+	var appointments []models.Appointment
+	if err := db.Session(&gorm.Session{NewDB: true}).Preload("Patient").Preload("Dentist").
+		Where("start_time < ? AND end_time > ?", dayEnd, dayStart).
+		Where("status NOT IN ?", []string{"cancelled", "no_show"}).
+		Order("start_time ASC").
+		Find(&appointments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar agendamentos"})
+		return
+	}
+
+	byRoom := map[uint][]models.Appointment{}
+	unassigned := []models.Appointment{}
+	for _, apt := range appointments {
+		if apt.RoomID == nil {
+			unassigned = append(unassigned, apt)
+			continue
+		}
+		byRoom[*apt.RoomID] = append(byRoom[*apt.RoomID], apt)
+	}
+
+	agenda := make([]gin.H, 0, len(rooms))
+	for _, room := range rooms {
+		roomAppointments := byRoom[room.ID]
+		if roomAppointments == nil {
+			roomAppointments = []models.Appointment{}
+		}
+		agenda = append(agenda, gin.H{
+			"room":         room,
+			"appointments": roomAppointments,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"date":       dayStart.Format("2006-01-02"),
+		"rooms":      agenda,
+		"unassigned": unassigned,
+	})
+}
+
+// GetAvailableRooms lists the rooms free in a period and equipped for the procedure
+// GET /rooms/available?date=YYYY-MM-DD&start=HH:MM&end=HH:MM&procedure=X&exclude_appointment_id=Y
+func GetAvailableRooms(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	day, err := time.ParseInLocation("2006-01-02", c.Query("date"), availability.Location())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Formato de data inválido. Use YYYY-MM-DD"})
+		return
+	}
+	period, ok := availability.ClockRange(day, c.Query("start"), c.Query("end"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Horário inválido. Use HH:MM e término após o início"})
+		return
+	}
+
+	var excludeIDs []uint
+	if id := parseUint(c.Query("exclude_appointment_id")); id > 0 {
+		excludeIDs = append(excludeIDs, id)
+	}
+
+	procedure := c.Query("procedure")
+	rooms, err := availability.FreeRooms(db, period.Start, period.End, procedure, excludeIDs...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar salas disponíveis"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"rooms":              rooms,
+		"required_equipment": availability.RequiredEquipment(db, procedure),
+	})
+}
+
+// applyRoomName keeps the display name of an appointment in sync with its linked room
+func applyRoomName(db *gorm.DB, apt *models.Appointment) {
+	if apt.RoomID == nil {
+		return
+	}
+	var room models.Room
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&room, *apt.RoomID).Error; err == nil {
+		apt.Room = room.Name
+	}
+}
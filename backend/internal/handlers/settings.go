@@ -7,6 +7,7 @@ import (
 	"drcrwell/backend/internal/helpers"
 	"drcrwell/backend/internal/models"
 	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/pix"
 	"encoding/hex"
 	"fmt"
 	"net/http"
@@ -128,18 +129,70 @@ func GetTenantSettings(c *gin.Context) {
 }
 
 func UpdateTenantSettings(c *gin.Context) {
+	// We use database.DB for public schema, but still need middleware check for auth
+	_, ok := middleware.GetDBFromContextSafe(c)
+	if !ok {
+		return
+	}
+	tenantID := c.GetUint("tenant_id")
+
+	// Each settings screen saves only its own fields: the ones missing from the payload keep their
+	// stored values. Secrets are only replaced when sent
 	var input models.TenantSettings
+	database.DB.Table("public.tenant_settings").Where("tenant_id = ?", tenantID).First(&input)
+	input.SMTPPassword = ""
+	input.WhatsAppAccessToken = ""
+	storedPrivacy := input.CalendarFeedPrivacy
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	input.TenantID = tenantID
 
-	// We use database.DB for public schema, but still need middleware check for auth
-	_, ok := middleware.GetDBFromContextSafe(c)
-	if !ok {
+	// An empty calendar privacy keeps the stored level (the default for rows saved before the setting existed)
+	if input.CalendarFeedPrivacy == "" {
+		input.CalendarFeedPrivacy = storedPrivacy
+		if input.CalendarFeedPrivacy == "" {
+			input.CalendarFeedPrivacy = models.CalendarPrivacyInitials
+		}
+	}
+	switch input.CalendarFeedPrivacy {
+	case models.CalendarPrivacyFull, models.CalendarPrivacyInitials, models.CalendarPrivacyMinimal:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid calendar_feed_privacy (use full, initials or minimal)"})
 		return
 	}
-	tenantID := c.GetUint("tenant_id")
+
+	// The no-show policy needs at least one no-show in at least one month
+	if input.NoShowThreshold < 1 {
+		input.NoShowThreshold = models.DefaultNoShowThreshold
+	}
+	if input.NoShowWindowMonths < 1 {
+		input.NoShowWindowMonths = models.DefaultNoShowWindowMonths
+	}
+	if input.NoShowDepositAmount < 0 {
+		input.NoShowDepositAmount = 0
+	}
+
+	// PIX key in the format of the DICT, merchant data as shown in the payer app
+	if input.PixKey != "" {
+		key, err := pix.NormalizeKey(input.PixKeyType, input.PixKey)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid PIX key: " + err.Error()})
+			return
+		}
+		input.PixKey = key
+	} else {
+		input.PixKeyType = ""
+	}
+	input.PixMerchantName = pix.Text(input.PixMerchantName, pix.MaxMerchantNameLength)
+	input.PixMerchantCity = pix.Text(input.PixMerchantCity, pix.MaxMerchantCityLength)
+	if input.PixProvider != "" {
+		if _, err := pix.GetProvider(input.PixProvider); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown PIX provider: " + input.PixProvider, "providers": pix.Providers()})
+			return
+		}
+	}
 
 	// Encrypt SMTP password if provided
 	smtpPassword := ""
@@ -191,31 +244,43 @@ func UpdateTenantSettings(c *gin.Context) {
 			SET
 				clinic_name = ?, clinic_cnpj = ?, clinic_address = ?, clinic_city = ?,
 				clinic_state = ?, clinic_zip = ?, clinic_phone = ?, clinic_email = ?,
-				working_hours_start = ?, working_hours_end = ?, default_appointment_duration = ?,
+				working_hours_start = ?, working_hours_end = ?, default_appointment_duration = ?, appointment_buffer_minutes = ?,
 				lunch_break_enabled = ?, lunch_break_start = ?, lunch_break_end = ?,
 				payment_cash_enabled = ?, payment_credit_card_enabled = ?, payment_debit_card_enabled = ?,
 				payment_pix_enabled = ?, payment_transfer_enabled = ?, payment_insurance_enabled = ?,
+				pix_key_type = ?, pix_key = ?, pix_merchant_name = ?, pix_merchant_city = ?, pix_provider = ?,
 				smtp_host = ?, smtp_port = ?, smtp_username = ?, smtp_password = ?,
 				smtp_from_name = ?, smtp_from_email = ?, smtp_use_tls = ?,
 				whatsapp_api_key = ?, whatsapp_number = ?, sms_api_key = ?, sms_provider = ?,
 				whatsapp_phone_number_id = ?, whatsapp_access_token = ?, whatsapp_business_account_id = ?,
 				whatsapp_webhook_verify_token = ?, whatsapp_enabled = ?,
 				whatsapp_template_confirmation = ?, whatsapp_template_reminder = ?, whatsapp_template_reminder_hours = ?,
+				whatsapp_template_waiting_list = ?, waiting_list_hold_minutes = ?,
+				calendar_feed_privacy = ?,
+				no_show_policy_enabled = ?, no_show_threshold = ?, no_show_window_months = ?,
+				no_show_require_confirmation = ?, no_show_block_self_booking = ?,
+				no_show_require_deposit = ?, no_show_deposit_amount = ?,
 				updated_at = NOW()
 			WHERE tenant_id = ?
 		`,
 			input.ClinicName, input.ClinicCNPJ, input.ClinicAddress, input.ClinicCity,
 			input.ClinicState, input.ClinicZip, input.ClinicPhone, input.ClinicEmail,
-			input.WorkingHoursStart, input.WorkingHoursEnd, input.DefaultAppointmentDuration,
+			input.WorkingHoursStart, input.WorkingHoursEnd, input.DefaultAppointmentDuration, input.AppointmentBufferMinutes,
 			input.LunchBreakEnabled, input.LunchBreakStart, input.LunchBreakEnd,
 			input.PaymentCashEnabled, input.PaymentCreditCardEnabled, input.PaymentDebitCardEnabled,
 			input.PaymentPixEnabled, input.PaymentTransferEnabled, input.PaymentInsuranceEnabled,
+			input.PixKeyType, input.PixKey, input.PixMerchantName, input.PixMerchantCity, input.PixProvider,
 			input.SMTPHost, input.SMTPPort, input.SMTPUsername, smtpPassword,
 			input.SMTPFromName, input.SMTPFromEmail, input.SMTPUseTLS,
 			input.WhatsAppAPIKey, input.WhatsAppNumber, input.SMSAPIKey, input.SMSProvider,
 			input.WhatsAppPhoneNumberID, whatsappAccessToken, input.WhatsAppBusinessAccountID,
 			input.WhatsAppWebhookVerifyToken, input.WhatsAppEnabled,
 			input.WhatsAppTemplateConfirmation, input.WhatsAppTemplateReminder, input.WhatsAppTemplateReminderHours,
+			input.WhatsAppTemplateWaitingList, input.WaitingListHoldMinutes,
+			input.CalendarFeedPrivacy,
+			input.NoShowPolicyEnabled, input.NoShowThreshold, input.NoShowWindowMonths,
+			input.NoShowRequireConfirmation, input.NoShowBlockSelfBooking,
+			input.NoShowRequireDeposit, input.NoShowDepositAmount,
 			tenantID,
 		)
 	} else if smtpPassword != "" {
@@ -225,31 +290,43 @@ func UpdateTenantSettings(c *gin.Context) {
 			SET
 				clinic_name = ?, clinic_cnpj = ?, clinic_address = ?, clinic_city = ?,
 				clinic_state = ?, clinic_zip = ?, clinic_phone = ?, clinic_email = ?,
-				working_hours_start = ?, working_hours_end = ?, default_appointment_duration = ?,
+				working_hours_start = ?, working_hours_end = ?, default_appointment_duration = ?, appointment_buffer_minutes = ?,
 				lunch_break_enabled = ?, lunch_break_start = ?, lunch_break_end = ?,
 				payment_cash_enabled = ?, payment_credit_card_enabled = ?, payment_debit_card_enabled = ?,
 				payment_pix_enabled = ?, payment_transfer_enabled = ?, payment_insurance_enabled = ?,
+				pix_key_type = ?, pix_key = ?, pix_merchant_name = ?, pix_merchant_city = ?, pix_provider = ?,
 				smtp_host = ?, smtp_port = ?, smtp_username = ?, smtp_password = ?,
 				smtp_from_name = ?, smtp_from_email = ?, smtp_use_tls = ?,
 				whatsapp_api_key = ?, whatsapp_number = ?, sms_api_key = ?, sms_provider = ?,
 				whatsapp_phone_number_id = ?, whatsapp_business_account_id = ?,
 				whatsapp_webhook_verify_token = ?, whatsapp_enabled = ?,
 				whatsapp_template_confirmation = ?, whatsapp_template_reminder = ?, whatsapp_template_reminder_hours = ?,
+				whatsapp_template_waiting_list = ?, waiting_list_hold_minutes = ?,
+				calendar_feed_privacy = ?,
+				no_show_policy_enabled = ?, no_show_threshold = ?, no_show_window_months = ?,
+				no_show_require_confirmation = ?, no_show_block_self_booking = ?,
+				no_show_require_deposit = ?, no_show_deposit_amount = ?,
 				updated_at = NOW()
 			WHERE tenant_id = ?
 		`,
 			input.ClinicName, input.ClinicCNPJ, input.ClinicAddress, input.ClinicCity,
 			input.ClinicState, input.ClinicZip, input.ClinicPhone, input.ClinicEmail,
-			input.WorkingHoursStart, input.WorkingHoursEnd, input.DefaultAppointmentDuration,
+			input.WorkingHoursStart, input.WorkingHoursEnd, input.DefaultAppointmentDuration, input.AppointmentBufferMinutes,
 			input.LunchBreakEnabled, input.LunchBreakStart, input.LunchBreakEnd,
 			input.PaymentCashEnabled, input.PaymentCreditCardEnabled, input.PaymentDebitCardEnabled,
 			input.PaymentPixEnabled, input.PaymentTransferEnabled, input.PaymentInsuranceEnabled,
+			input.PixKeyType, input.PixKey, input.PixMerchantName, input.PixMerchantCity, input.PixProvider,
 			input.SMTPHost, input.SMTPPort, input.SMTPUsername, smtpPassword,
 			input.SMTPFromName, input.SMTPFromEmail, input.SMTPUseTLS,
 			input.WhatsAppAPIKey, input.WhatsAppNumber, input.SMSAPIKey, input.SMSProvider,
 			input.WhatsAppPhoneNumberID, input.WhatsAppBusinessAccountID,
 			input.WhatsAppWebhookVerifyToken, input.WhatsAppEnabled,
 			input.WhatsAppTemplateConfirmation, input.WhatsAppTemplateReminder, input.WhatsAppTemplateReminderHours,
+			input.WhatsAppTemplateWaitingList, input.WaitingListHoldMinutes,
+			input.CalendarFeedPrivacy,
+			input.NoShowPolicyEnabled, input.NoShowThreshold, input.NoShowWindowMonths,
+			input.NoShowRequireConfirmation, input.NoShowBlockSelfBooking,
+			input.NoShowRequireDeposit, input.NoShowDepositAmount,
 			tenantID,
 		)
 	} else if whatsappAccessToken != "" {
@@ -259,31 +336,43 @@ func UpdateTenantSettings(c *gin.Context) {
 			SET
 				clinic_name = ?, clinic_cnpj = ?, clinic_address = ?, clinic_city = ?,
 				clinic_state = ?, clinic_zip = ?, clinic_phone = ?, clinic_email = ?,
-				working_hours_start = ?, working_hours_end = ?, default_appointment_duration = ?,
+				working_hours_start = ?, working_hours_end = ?, default_appointment_duration = ?, appointment_buffer_minutes = ?,
 				lunch_break_enabled = ?, lunch_break_start = ?, lunch_break_end = ?,
 				payment_cash_enabled = ?, payment_credit_card_enabled = ?, payment_debit_card_enabled = ?,
 				payment_pix_enabled = ?, payment_transfer_enabled = ?, payment_insurance_enabled = ?,
+				pix_key_type = ?, pix_key = ?, pix_merchant_name = ?, pix_merchant_city = ?, pix_provider = ?,
 				smtp_host = ?, smtp_port = ?, smtp_username = ?,
 				smtp_from_name = ?, smtp_from_email = ?, smtp_use_tls = ?,
 				whatsapp_api_key = ?, whatsapp_number = ?, sms_api_key = ?, sms_provider = ?,
 				whatsapp_phone_number_id = ?, whatsapp_access_token = ?, whatsapp_business_account_id = ?,
 				whatsapp_webhook_verify_token = ?, whatsapp_enabled = ?,
 				whatsapp_template_confirmation = ?, whatsapp_template_reminder = ?, whatsapp_template_reminder_hours = ?,
+				whatsapp_template_waiting_list = ?, waiting_list_hold_minutes = ?,
+				calendar_feed_privacy = ?,
+				no_show_policy_enabled = ?, no_show_threshold = ?, no_show_window_months = ?,
+				no_show_require_confirmation = ?, no_show_block_self_booking = ?,
+				no_show_require_deposit = ?, no_show_deposit_amount = ?,
 				updated_at = NOW()
 			WHERE tenant_id = ?
 		`,
 			input.ClinicName, input.ClinicCNPJ, input.ClinicAddress, input.ClinicCity,
 			input.ClinicState, input.ClinicZip, input.ClinicPhone, input.ClinicEmail,
-			input.WorkingHoursStart, input.WorkingHoursEnd, input.DefaultAppointmentDuration,
+			input.WorkingHoursStart, input.WorkingHoursEnd, input.DefaultAppointmentDuration, input.AppointmentBufferMinutes,
 			input.LunchBreakEnabled, input.LunchBreakStart, input.LunchBreakEnd,
 			input.PaymentCashEnabled, input.PaymentCreditCardEnabled, input.PaymentDebitCardEnabled,
 			input.PaymentPixEnabled, input.PaymentTransferEnabled, input.PaymentInsuranceEnabled,
+			input.PixKeyType, input.PixKey, input.PixMerchantName, input.PixMerchantCity, input.PixProvider,
 			input.SMTPHost, input.SMTPPort, input.SMTPUsername,
 			input.SMTPFromName, input.SMTPFromEmail, input.SMTPUseTLS,
 			input.WhatsAppAPIKey, input.WhatsAppNumber, input.SMSAPIKey, input.SMSProvider,
 			input.WhatsAppPhoneNumberID, whatsappAccessToken, input.WhatsAppBusinessAccountID,
 			input.WhatsAppWebhookVerifyToken, input.WhatsAppEnabled,
 			input.WhatsAppTemplateConfirmation, input.WhatsAppTemplateReminder, input.WhatsAppTemplateReminderHours,
+			input.WhatsAppTemplateWaitingList, input.WaitingListHoldMinutes,
+			input.CalendarFeedPrivacy,
+			input.NoShowPolicyEnabled, input.NoShowThreshold, input.NoShowWindowMonths,
+			input.NoShowRequireConfirmation, input.NoShowBlockSelfBooking,
+			input.NoShowRequireDeposit, input.NoShowDepositAmount,
 			tenantID,
 		)
 	} else {
@@ -293,31 +382,43 @@ func UpdateTenantSettings(c *gin.Context) {
 			SET
 				clinic_name = ?, clinic_cnpj = ?, clinic_address = ?, clinic_city = ?,
 				clinic_state = ?, clinic_zip = ?, clinic_phone = ?, clinic_email = ?,
-				working_hours_start = ?, working_hours_end = ?, default_appointment_duration = ?,
+				working_hours_start = ?, working_hours_end = ?, default_appointment_duration = ?, appointment_buffer_minutes = ?,
 				lunch_break_enabled = ?, lunch_break_start = ?, lunch_break_end = ?,
 				payment_cash_enabled = ?, payment_credit_card_enabled = ?, payment_debit_card_enabled = ?,
 				payment_pix_enabled = ?, payment_transfer_enabled = ?, payment_insurance_enabled = ?,
+				pix_key_type = ?, pix_key = ?, pix_merchant_name = ?, pix_merchant_city = ?, pix_provider = ?,
 				smtp_host = ?, smtp_port = ?, smtp_username = ?,
 				smtp_from_name = ?, smtp_from_email = ?, smtp_use_tls = ?,
 				whatsapp_api_key = ?, whatsapp_number = ?, sms_api_key = ?, sms_provider = ?,
 				whatsapp_phone_number_id = ?, whatsapp_business_account_id = ?,
 				whatsapp_webhook_verify_token = ?, whatsapp_enabled = ?,
 				whatsapp_template_confirmation = ?, whatsapp_template_reminder = ?, whatsapp_template_reminder_hours = ?,
+				whatsapp_template_waiting_list = ?, waiting_list_hold_minutes = ?,
+				calendar_feed_privacy = ?,
+				no_show_policy_enabled = ?, no_show_threshold = ?, no_show_window_months = ?,
+				no_show_require_confirmation = ?, no_show_block_self_booking = ?,
+				no_show_require_deposit = ?, no_show_deposit_amount = ?,
 				updated_at = NOW()
 			WHERE tenant_id = ?
 		`,
 			input.ClinicName, input.ClinicCNPJ, input.ClinicAddress, input.ClinicCity,
 			input.ClinicState, input.ClinicZip, input.ClinicPhone, input.ClinicEmail,
-			input.WorkingHoursStart, input.WorkingHoursEnd, input.DefaultAppointmentDuration,
+			input.WorkingHoursStart, input.WorkingHoursEnd, input.DefaultAppointmentDuration, input.AppointmentBufferMinutes,
 			input.LunchBreakEnabled, input.LunchBreakStart, input.LunchBreakEnd,
 			input.PaymentCashEnabled, input.PaymentCreditCardEnabled, input.PaymentDebitCardEnabled,
 			input.PaymentPixEnabled, input.PaymentTransferEnabled, input.PaymentInsuranceEnabled,
+			input.PixKeyType, input.PixKey, input.PixMerchantName, input.PixMerchantCity, input.PixProvider,
 			input.SMTPHost, input.SMTPPort, input.SMTPUsername,
 			input.SMTPFromName, input.SMTPFromEmail, input.SMTPUseTLS,
 			input.WhatsAppAPIKey, input.WhatsAppNumber, input.SMSAPIKey, input.SMSProvider,
 			input.WhatsAppPhoneNumberID, input.WhatsAppBusinessAccountID,
 			input.WhatsAppWebhookVerifyToken, input.WhatsAppEnabled,
 			input.WhatsAppTemplateConfirmation, input.WhatsAppTemplateReminder, input.WhatsAppTemplateReminderHours,
+			input.WhatsAppTemplateWaitingList, input.WaitingListHoldMinutes,
+			input.CalendarFeedPrivacy,
+			input.NoShowPolicyEnabled, input.NoShowThreshold, input.NoShowWindowMonths,
+			input.NoShowRequireConfirmation, input.NoShowBlockSelfBooking,
+			input.NoShowRequireDeposit, input.NoShowDepositAmount,
 			tenantID,
 		)
 	}
@@ -342,6 +443,15 @@ func UpdateTenantSettings(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"settings": input, "has_smtp_password": hasSMTPPassword})
 }
 
+// generatePublicToken returns a random token for unauthenticated links (embed forms, calendar feeds)
+func generatePublicToken() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
 // GetEmbedToken returns the current embed token status
 func GetEmbedToken(c *gin.Context) {
 	db, ok := middleware.GetDBFromContextSafe(c)
@@ -386,12 +496,11 @@ func GenerateEmbedToken(c *gin.Context) {
 	tenantID := c.GetUint("tenant_id")
 
 	// Generate random token
-	bytes := make([]byte, 32)
-	if _, err := rand.Read(bytes); err != nil {
+	token, err := generatePublicToken()
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
-	token := hex.EncodeToString(bytes)
 
 	// Update tenant with new token
 	if err := db.Exec("UPDATE public.tenants SET embed_token = ?, updated_at = NOW() WHERE id = ?", token, tenantID).Error; err != nil {
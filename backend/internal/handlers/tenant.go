@@ -146,9 +146,28 @@ func CreateTenant(c *gin.Context) {
 		return
 	}
 
+	// Prefill the closures calendar with this year's and next year's national holidays
+	// Runs in a savepoint so a failure here does not abort the tenant creation
+	if err := tenantDB.Transaction(func(seedTx *gorm.DB) error {
+		year := time.Now().Year()
+		for _, y := range []int{year, year + 1} {
+			if _, err := importNationalHolidays(seedTx, y, adminUser.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		log.Printf("Failed to import national holidays for tenant %d: %v", tenant.ID, err)
+	}
+
 	// Commit transaction
 	tx.Commit()
 
+	// Double-booking guard for the new schema (existing schemas get it on startup)
+	if _, err := database.ApplyAppointmentOverlapConstraints(tenant.DBSchema); err != nil {
+		log.Printf("Failed to apply appointment overlap constraints for tenant %d: %v", tenant.ID, err)
+	}
+
 	// Send verification email asynchronously
 	go func() {
 		if err := CreateAndSendVerification(tenant.ID, tenant.Email, tenant.Name); err != nil {
@@ -201,7 +220,29 @@ func autoMigrateTenantTables(db interface{}) error {
 		// Core tables
 		&models.Patient{},
 		&models.Appointment{},
+		&models.AppointmentReminder{},
+		&models.ProfessionalSchedule{},
+		&models.ProfessionalScheduleOverride{},
+		&models.ProfessionalAbsence{},
+		&models.Room{},
+		&models.ClinicClosure{},
+		&models.AppointmentStatusHistory{},
+		&models.ToothEvent{},
+		&models.PeriodontalExam{},
+		&models.PeriodontalTooth{},
+		&models.MedicalRecordAddendum{},
+		&models.MedicalRecordRevision{},
+		&models.AnamnesisTemplate{},
+		&models.AnamnesisQuestion{},
+		&models.AnamnesisResponse{},
+		&models.AnamnesisAnswer{},
+		&models.Medication{},
+		&models.PrescriptionItem{},
+		&models.PrescriptionSafetyCheck{},
+		&models.ControlledPrescriptionBook{},
+		&models.TreatmentPlanItem{},
 		&models.MedicalRecord{},
+		&models.ClinicalNoteTemplate{},
 
 		// Financial tables
 		&models.Budget{},
@@ -209,6 +250,15 @@ func autoMigrateTenantTables(db interface{}) error {
 		&models.Commission{},
 		&models.Treatment{},
 		&models.TreatmentPayment{},
+		&models.CommissionRule{},
+		&models.PaymentMethodFee{},
+		&models.CommissionPayout{},
+		&models.InsuranceCompany{},
+		&models.InsurancePrice{},
+		&models.InsuranceGuide{},
+		&models.InsuranceGuideItem{},
+		&models.InsuranceLot{},
+		&models.PixCharge{},
 
 		// Inventory tables
 		&models.Product{},
@@ -222,7 +272,10 @@ func autoMigrateTenantTables(db interface{}) error {
 		// Document tables
 		&models.Attachment{},
 		&models.Exam{},
+		&models.ExamInstance{},
 		&models.Prescription{},
+		&models.ExternalSpecialist{},
+		&models.Referral{},
 
 		// Clinical tables
 		&models.TreatmentProtocol{},
@@ -237,6 +290,7 @@ func autoMigrateTenantTables(db interface{}) error {
 		// Utility tables
 		&models.TenantSettings{},
 		&models.WaitingList{},
+		&models.WaitingListOffer{},
 
 		// CRM tables
 		&models.Lead{},
@@ -538,7 +592,7 @@ func WhatsAppAPIDocumentation(c *gin.Context) {
 				"description": "Adiciona paciente à lista de espera",
 				"body": gin.H{
 					"procedure": "string (obrigatório) - Tipo de procedimento",
-					"priority":  "string (opcional) - 'normal' ou 'urgent'",
+					"priority":  "string (opcional) - 'urgent', 'high', 'normal' ou 'low'",
 					"notes":     "string (opcional) - Observações",
 				},
 			},
@@ -87,7 +87,13 @@ func CreateTreatment(c *gin.Context) {
 		Notes:             input.Notes,
 	}
 
-	if err := db.Create(&treatment).Error; err != nil {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&treatment).Error; err != nil {
+			return err
+		}
+		return linkPlanItemsToTreatment(tx, &treatment)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar tratamento"})
 		return
 	}
@@ -165,7 +171,7 @@ func GetTreatment(c *gin.Context) {
 	var treatment models.Treatment
 	if err := db.Preload("Patient").Preload("Dentist").Preload("Budget").Preload("TreatmentPayments", func(db *gorm.DB) *gorm.DB {
 		return db.Order("paid_date DESC")
-	}).Preload("TreatmentPayments.ReceivedBy").First(&treatment, uint(id)).Error; err != nil {
+	}).Preload("TreatmentPayments.ReceivedBy").Preload("PlanItems", orderByPosition).First(&treatment, uint(id)).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Tratamento não encontrado"})
 		return
 	}
@@ -306,6 +312,63 @@ func generateReceiptNumber(db *gorm.DB) string {
 	return fmt.Sprintf("%s%04d", prefix, count+1)
 }
 
+// recordTreatmentPayment registers a paid installment of the treatment, updating its paid value
+// and generating the commissions. The installment number defaults to the next one.
+// db must be a transaction, so the installment is not kept when its commissions fail
+func recordTreatmentPayment(db *gorm.DB, treatment *models.Treatment, payment *models.TreatmentPayment) error {
+	payment.TreatmentID = treatment.ID
+	payment.Status = models.TreatmentPaymentStatusPaid
+	payment.ReceiptNumber = generateReceiptNumber(db)
+
+	// Get next installment number if not provided
+	if payment.InstallmentNumber <= 0 {
+		var maxInstallment int
+		db.Model(&models.TreatmentPayment{}).Where("treatment_id = ?", treatment.ID).Select("COALESCE(MAX(installment_number), 0)").Scan(&maxInstallment)
+		payment.InstallmentNumber = maxInstallment + 1
+	}
+
+	// Use raw SQL to avoid foreign key issues with User table in public schema
+	result := db.Exec(`
+		INSERT INTO treatment_payments
+		(created_at, updated_at, treatment_id, amount, payment_method, installment_number, receipt_number, status, paid_date, received_by_id, notes)
+		VALUES (NOW(), NOW(), ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`, payment.TreatmentID, payment.Amount, payment.PaymentMethod, payment.InstallmentNumber,
+		payment.ReceiptNumber, payment.Status, payment.PaidDate, payment.ReceivedByID, payment.Notes)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	// Get the created payment ID
+	var paymentID uint
+	db.Raw("SELECT id FROM treatment_payments WHERE receipt_number = ? AND deleted_at IS NULL", payment.ReceiptNumber).Scan(&paymentID)
+	payment.ID = paymentID
+
+	// Update treatment paid value
+	treatment.PaidValue += payment.Amount
+
+	// Check if treatment is fully paid
+	if treatment.PaidValue >= treatment.TotalValue {
+		treatment.Status = models.TreatmentStatusCompleted
+		now := time.Now()
+		treatment.CompletedDate = &now
+		// Update with completed status
+		if err := db.Exec(`UPDATE treatments SET paid_value = ?, status = ?, completed_date = ?, updated_at = NOW() WHERE id = ?`,
+			treatment.PaidValue, treatment.Status, treatment.CompletedDate, treatment.ID).Error; err != nil {
+			return err
+		}
+	} else {
+		// Update only paid value
+		if err := db.Exec(`UPDATE treatments SET paid_value = ?, updated_at = NOW() WHERE id = ?`,
+			treatment.PaidValue, treatment.ID).Error; err != nil {
+			return err
+		}
+	}
+
+	// Commissions of the professionals on the amount received
+	return generateTreatmentPaymentCommissions(db.Session(&gorm.Session{NewDB: true}), *payment, *treatment)
+}
+
 // CreateTreatmentPayment - Registrar um pagamento de tratamento
 func CreateTreatmentPayment(c *gin.Context) {
 	var input struct {
@@ -365,63 +428,23 @@ func CreateTreatmentPayment(c *gin.Context) {
 		}
 	}
 
-	// Get next installment number if not provided
-	installmentNumber := input.InstallmentNumber
-	if installmentNumber <= 0 {
-		var maxInstallment int
-		db.Model(&models.TreatmentPayment{}).Where("treatment_id = ?", input.TreatmentID).Select("COALESCE(MAX(installment_number), 0)").Scan(&maxInstallment)
-		installmentNumber = maxInstallment + 1
-	}
-
 	payment := models.TreatmentPayment{
-		TreatmentID:       input.TreatmentID,
 		Amount:            input.Amount,
 		PaymentMethod:     input.PaymentMethod,
-		InstallmentNumber: installmentNumber,
-		ReceiptNumber:     generateReceiptNumber(db),
-		Status:            models.TreatmentPaymentStatusPaid,
+		InstallmentNumber: input.InstallmentNumber,
 		PaidDate:          paidDate,
 		ReceivedByID:      userID,
 		Notes:             input.Notes,
 	}
-
-	// Use raw SQL to avoid foreign key issues with User table in public schema
-	result := db.Exec(`
-		INSERT INTO treatment_payments
-		(created_at, updated_at, treatment_id, amount, payment_method, installment_number, receipt_number, status, paid_date, received_by_id, notes)
-		VALUES (NOW(), NOW(), ?, ?, ?, ?, ?, ?, ?, ?, ?)
-		RETURNING id
-	`, payment.TreatmentID, payment.Amount, payment.PaymentMethod, payment.InstallmentNumber,
-		payment.ReceiptNumber, payment.Status, payment.PaidDate, payment.ReceivedByID, payment.Notes)
-
-	if result.Error != nil {
-		log.Printf("ERROR creating payment: %v", result.Error)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao registrar pagamento: " + result.Error.Error()})
+	err := db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		return recordTreatmentPayment(tx, &treatment, &payment)
+	})
+	if err != nil {
+		log.Printf("ERROR creating payment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao registrar pagamento: " + err.Error()})
 		return
 	}
 
-	// Get the created payment ID
-	var paymentID uint
-	db.Raw("SELECT id FROM treatment_payments WHERE receipt_number = ? AND deleted_at IS NULL", payment.ReceiptNumber).Scan(&paymentID)
-	payment.ID = paymentID
-
-	// Update treatment paid value
-	treatment.PaidValue += input.Amount
-
-	// Check if treatment is fully paid
-	if treatment.PaidValue >= treatment.TotalValue {
-		treatment.Status = models.TreatmentStatusCompleted
-		now := time.Now()
-		treatment.CompletedDate = &now
-		// Update with completed status
-		db.Exec(`UPDATE treatments SET paid_value = ?, status = ?, completed_date = ?, updated_at = NOW() WHERE id = ?`,
-			treatment.PaidValue, treatment.Status, treatment.CompletedDate, treatment.ID)
-	} else {
-		// Update only paid value
-		db.Exec(`UPDATE treatments SET paid_value = ?, updated_at = NOW() WHERE id = ?`,
-			treatment.PaidValue, treatment.ID)
-	}
-
 	// Load payment with created data
 	db.Raw("SELECT * FROM treatment_payments WHERE id = ? AND deleted_at IS NULL", payment.ID).Scan(&payment)
 
@@ -518,43 +541,64 @@ func UpdateTreatmentPayment(c *gin.Context) {
 		payment.Status = input.Status
 	}
 
-	// Use raw SQL to avoid GORM model contamination issue
-	result := db.Exec(`
-		UPDATE treatment_payments SET
-			updated_at = NOW(),
-			payment_method = ?,
-			notes = ?,
-			status = ?
-		WHERE id = ? AND deleted_at IS NULL
-	`, payment.PaymentMethod, payment.Notes, payment.Status, payment.ID)
-
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar pagamento"})
-		return
-	}
+	// The installment, the paid value of the treatment and the commissions change together
+	err = db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		// Use raw SQL to avoid GORM model contamination issue
+		if err := tx.Exec(`
+			UPDATE treatment_payments SET
+				updated_at = NOW(),
+				payment_method = ?,
+				notes = ?,
+				status = ?
+			WHERE id = ? AND deleted_at IS NULL
+		`, payment.PaymentMethod, payment.Notes, payment.Status, payment.ID).Error; err != nil {
+			return err
+		}
 
-	// Update treatment paid value if status changed
-	if oldStatus != payment.Status {
+		// Update treatment paid value if status changed
+		if oldStatus == payment.Status {
+			return nil
+		}
 		var treatment models.Treatment
-		if err := db.Raw("SELECT * FROM treatments WHERE id = ? AND deleted_at IS NULL", payment.TreatmentID).Scan(&treatment).Error; err == nil && treatment.ID != 0 {
-			if payment.Status == models.TreatmentPaymentStatusCancelled || payment.Status == models.TreatmentPaymentStatusRefunded {
-				treatment.PaidValue -= oldAmount
-				if treatment.Status == models.TreatmentStatusCompleted {
-					treatment.Status = models.TreatmentStatusInProgress
-					treatment.CompletedDate = nil
-				}
-			} else if oldStatus == models.TreatmentPaymentStatusCancelled || oldStatus == models.TreatmentPaymentStatusRefunded {
-				treatment.PaidValue += payment.Amount
-				if treatment.PaidValue >= treatment.TotalValue {
-					treatment.Status = models.TreatmentStatusCompleted
-					now := time.Now()
-					treatment.CompletedDate = &now
-				}
+		if err := tx.Raw("SELECT * FROM treatments WHERE id = ? AND deleted_at IS NULL", payment.TreatmentID).Scan(&treatment).Error; err != nil {
+			return err
+		}
+		if treatment.ID == 0 {
+			return nil
+		}
+		if payment.Status == models.TreatmentPaymentStatusCancelled || payment.Status == models.TreatmentPaymentStatusRefunded {
+			treatment.PaidValue -= oldAmount
+			if treatment.Status == models.TreatmentStatusCompleted {
+				treatment.Status = models.TreatmentStatusInProgress
+				treatment.CompletedDate = nil
 			}
-			// Use raw SQL to update treatment
-			db.Exec(`UPDATE treatments SET updated_at = NOW(), paid_value = ?, status = ?, completed_date = ? WHERE id = ? AND deleted_at IS NULL`,
-				treatment.PaidValue, treatment.Status, treatment.CompletedDate, treatment.ID)
+		} else if oldStatus == models.TreatmentPaymentStatusCancelled || oldStatus == models.TreatmentPaymentStatusRefunded {
+			treatment.PaidValue += payment.Amount
+			if treatment.PaidValue >= treatment.TotalValue {
+				treatment.Status = models.TreatmentStatusCompleted
+				now := time.Now()
+				treatment.CompletedDate = &now
+			}
+		}
+		// Use raw SQL to update treatment
+		if err := tx.Exec(`UPDATE treatments SET updated_at = NOW(), paid_value = ?, status = ?, completed_date = ? WHERE id = ? AND deleted_at IS NULL`,
+			treatment.PaidValue, treatment.Status, treatment.CompletedDate, treatment.ID).Error; err != nil {
+			return err
+		}
+
+		// Commissions follow the installment: given back when it is cancelled or refunded, generated again when reactivated
+		if oldStatus == models.TreatmentPaymentStatusPaid {
+			return reverseCommissions(tx, models.CommissionSourceTreatmentPayment, payment.ID)
+		}
+		if payment.Status == models.TreatmentPaymentStatusPaid {
+			return generateTreatmentPaymentCommissions(tx, payment, treatment)
 		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("ERROR updating treatment payment %d: %v", payment.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar pagamento"})
+		return
 	}
 
 	db.Raw("SELECT * FROM treatment_payments WHERE id = ? AND deleted_at IS NULL", payment.ID).Scan(&payment)
@@ -581,23 +625,34 @@ func DeleteTreatmentPayment(c *gin.Context) {
 		return
 	}
 
-	// Update treatment paid value
-	var treatment models.Treatment
-	if err := db.Raw("SELECT * FROM treatments WHERE id = ? AND deleted_at IS NULL", payment.TreatmentID).Scan(&treatment).Error; err == nil && treatment.ID != 0 {
-		if payment.Status == models.TreatmentPaymentStatusPaid {
+	// The paid value of the treatment, the installment and its commissions are removed together
+	err = db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		// Update treatment paid value
+		var treatment models.Treatment
+		if err := tx.Raw("SELECT * FROM treatments WHERE id = ? AND deleted_at IS NULL", payment.TreatmentID).Scan(&treatment).Error; err != nil {
+			return err
+		}
+		if treatment.ID != 0 && payment.Status == models.TreatmentPaymentStatusPaid {
 			treatment.PaidValue -= payment.Amount
 			if treatment.Status == models.TreatmentStatusCompleted {
 				treatment.Status = models.TreatmentStatusInProgress
 				treatment.CompletedDate = nil
 			}
 			// Use raw SQL to update treatment
-			db.Exec(`UPDATE treatments SET updated_at = NOW(), paid_value = ?, status = ?, completed_date = ? WHERE id = ? AND deleted_at IS NULL`,
-				treatment.PaidValue, treatment.Status, treatment.CompletedDate, treatment.ID)
+			if err := tx.Exec(`UPDATE treatments SET updated_at = NOW(), paid_value = ?, status = ?, completed_date = ? WHERE id = ? AND deleted_at IS NULL`,
+				treatment.PaidValue, treatment.Status, treatment.CompletedDate, treatment.ID).Error; err != nil {
+				return err
+			}
 		}
-	}
 
-	// Soft delete the payment
-	if err := db.Exec("UPDATE treatment_payments SET deleted_at = NOW() WHERE id = ?", payment.ID).Error; err != nil {
+		// Soft delete the payment
+		if err := tx.Exec("UPDATE treatment_payments SET deleted_at = NOW() WHERE id = ?", payment.ID).Error; err != nil {
+			return err
+		}
+		return reverseCommissions(tx, models.CommissionSourceTreatmentPayment, payment.ID)
+	})
+	if err != nil {
+		log.Printf("ERROR deleting treatment payment %d: %v", payment.ID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao deletar pagamento"})
 		return
 	}
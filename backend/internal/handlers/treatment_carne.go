@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jung-kurt/gofpdf"
+	"gorm.io/gorm"
+)
+
+// GenerateTreatmentCarnePDF - Gerar carnê com uma lâmina por parcela em aberto
+// Each slip carries the due date, the amount and, when the clinic receives by PIX, the QR code
+// of a static charge of the installment
+// GET /treatments/:id/carne
+func GenerateTreatmentCarnePDF(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+	tenantID := c.GetUint("tenant_id")
+
+	var treatment models.Treatment
+	if err := db.Session(&gorm.Session{NewDB: true}).Preload("Patient").First(&treatment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tratamento não encontrado"})
+		return
+	}
+	if treatment.Status != models.TreatmentStatusInProgress {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Somente tratamentos em andamento possuem carnê"})
+		return
+	}
+
+	var paidNumbers []int
+	db.Session(&gorm.Session{NewDB: true}).Model(&models.TreatmentPayment{}).
+		Where("treatment_id = ? AND status = ?", treatment.ID, models.TreatmentPaymentStatusPaid).
+		Pluck("installment_number", &paidNumbers)
+	paid := make(map[int]bool, len(paidNumbers))
+	for _, number := range paidNumbers {
+		paid[number] = true
+	}
+
+	schedule := helpers.InstallmentSchedule(treatment.TotalValue, treatment.InstallmentValue, treatment.TotalInstallments, treatment.StartDate)
+	var open []helpers.InstallmentDue
+	for _, due := range schedule {
+		if !paid[due.Number] {
+			open = append(open, due)
+		}
+	}
+	if len(open) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Todas as parcelas do tratamento já foram pagas"})
+		return
+	}
+
+	clinic := GetClinicInfo(db, tenantID)
+	config, pixEnabled := loadPixConfig(db, tenantID)
+	patientName := ""
+	if treatment.Patient != nil {
+		patientName = treatment.Patient.Name
+	}
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(false, 15)
+	tr := pdf.UnicodeTranslatorFromDescriptor("cp1252")
+
+	const slipHeight = 62.0
+	const slipsPerPage = 4
+	for i, due := range open {
+		if i%slipsPerPage == 0 {
+			pdf.AddPage()
+		}
+		top := 15 + float64(i%slipsPerPage)*(slipHeight+5)
+		pdf.Rect(15, top, 180, slipHeight, "D")
+
+		// Installment data
+		pdf.SetXY(18, top+3)
+		pdf.SetFont("Arial", "B", 11)
+		pdf.Cell(120, 6, tr(clinic.Name))
+		pdf.SetXY(18, top+10)
+		pdf.SetFont("Arial", "", 9)
+		pdf.Cell(120, 5, tr("Paciente: "+patientName))
+		pdf.SetXY(18, top+15)
+		pdf.Cell(120, 5, tr(fmt.Sprintf("Tratamento #%d - Parcela %d/%d", treatment.ID, due.Number, len(schedule))))
+		pdf.SetXY(18, top+22)
+		pdf.SetFont("Arial", "B", 10)
+		pdf.Cell(60, 6, tr("Vencimento: "+due.DueDate.Format("02/01/2006")))
+		pdf.Cell(60, 6, fmt.Sprintf("Valor: R$ %.2f", due.Amount))
+
+		if !pixEnabled {
+			pdf.SetXY(18, top+32)
+			pdf.SetFont("Arial", "", 9)
+			pdf.Cell(120, 5, tr("Pagamento na recepcao da clinica"))
+			continue
+		}
+
+		target, err := installmentPixTarget(db, treatment, due.Number)
+		if err != nil {
+			continue
+		}
+		charge, err := findOrIssuePixCharge(db, config, target, false, 0)
+		if err != nil {
+			log.Printf("ERROR issuing PIX charge of treatment %d installment %d: %v", treatment.ID, due.Number, err)
+			continue
+		}
+		if err := pdfPixQRCode(pdf, "pix_"+charge.TxID, charge.Payload, 148, top+5, 44); err != nil {
+			log.Printf("ERROR drawing PIX QR code of charge %d: %v", charge.ID, err)
+		}
+		pdf.SetXY(18, top+31)
+		pdf.SetFont("Arial", "", 8)
+		pdf.Cell(120, 4, tr("PIX copia e cola:"))
+		pdf.SetXY(18, top+35)
+		pdf.SetFont("Courier", "", 6.5)
+		pdf.MultiCell(125, 3, charge.Payload, "", "L", false)
+	}
+
+	// Footer on the last page
+	pdf.SetXY(15, 282)
+	pdf.SetFont("Arial", "I", 8)
+	pdf.Cell(0, 5, fmt.Sprintf("Gerado em: %s", time.Now().Format("02/01/2006 15:04")))
+
+	c.Header("Content-Type", "application/pdf")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=carne_tratamento_%d.pdf", treatment.ID))
+
+	if err := pdf.Output(c.Writer); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar PDF"})
+		return
+	}
+}
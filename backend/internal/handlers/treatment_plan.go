@@ -0,0 +1,405 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errPlanItemNotPending is returned when completing a cancelled plan item
+var errPlanItemNotPending = errors.New("plan item is not pending")
+
+// orderByPosition orders preloaded plan items
+func orderByPosition(tx *gorm.DB) *gorm.DB {
+	return tx.Order("position ASC")
+}
+
+// budgetItemsFromPlan generates the budget Items JSON (used by the budget PDFs) and total from the plan items
+func budgetItemsFromPlan(items []models.TreatmentPlanItem) (*string, float64) {
+	budgetItems := make([]BudgetItem, 0, len(items))
+	total := 0.0
+	for _, item := range items {
+		budgetItems = append(budgetItems, BudgetItem{
+			Description: helpers.TreatmentPlanItemLabel(item),
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice,
+			Total:       item.Total,
+		})
+		total += item.Total
+	}
+	data, _ := json.Marshal(budgetItems)
+	itemsJSON := string(data)
+	return &itemsJSON, total
+}
+
+// replaceBudgetPlanItems replaces the plan items of a budget that was not approved yet
+func replaceBudgetPlanItems(tx *gorm.DB, budgetID, patientID uint, items []models.TreatmentPlanItem) error {
+	if err := tx.Where("budget_id = ?", budgetID).Delete(&models.TreatmentPlanItem{}).Error; err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	for i := range items {
+		items[i].ID = 0
+		items[i].BudgetID = budgetID
+		items[i].PatientID = patientID
+		items[i].TreatmentID = nil
+		items[i].AppointmentID = nil
+		items[i].CompletedAt = nil
+		items[i].PerformedByID = nil
+		items[i].PlannedEventID = nil
+		items[i].PerformedEventID = nil
+	}
+	return tx.Create(&items).Error
+}
+
+// chartPlannedItem charts a plan item on the odontogram as planned
+func chartPlannedItem(tx *gorm.DB, item *models.TreatmentPlanItem, dentistID uint) error {
+	if item.Tooth == nil || item.Condition == "" {
+		return nil
+	}
+	event := models.ToothEvent{
+		PatientID:  item.PatientID,
+		DentistID:  dentistID,
+		Tooth:      *item.Tooth,
+		Surfaces:   item.Surfaces,
+		Condition:  item.Condition,
+		State:      models.ToothStatePlanned,
+		Notes:      fmt.Sprintf("Plano de tratamento: %s", item.Procedure),
+		RecordedAt: time.Now(),
+	}
+	if err := tx.Create(&event).Error; err != nil {
+		return err
+	}
+	item.PlannedEventID = &event.ID
+	return tx.Model(&models.TreatmentPlanItem{}).Where("id = ?", item.ID).Update("planned_event_id", event.ID).Error
+}
+
+// unchartPlannedItem removes the planned odontogram event of an item that will no longer be performed
+func unchartPlannedItem(tx *gorm.DB, item *models.TreatmentPlanItem) error {
+	if item.PlannedEventID == nil {
+		return nil
+	}
+	if err := tx.Delete(&models.ToothEvent{}, *item.PlannedEventID).Error; err != nil {
+		return err
+	}
+	item.PlannedEventID = nil
+	return tx.Model(&models.TreatmentPlanItem{}).Where("id = ?", item.ID).Update("planned_event_id", nil).Error
+}
+
+// linkPlanItemsToTreatment moves the plan items of the approved budget to its treatment and
+// charts the procedures on teeth as planned in the patient's odontogram
+func linkPlanItemsToTreatment(tx *gorm.DB, treatment *models.Treatment) error {
+	if err := tx.Exec(`
+		UPDATE treatment_plan_items SET treatment_id = ?, updated_at = NOW()
+		WHERE budget_id = ? AND deleted_at IS NULL
+	`, treatment.ID, treatment.BudgetID).Error; err != nil {
+		return err
+	}
+
+	var items []models.TreatmentPlanItem
+	if err := tx.Where("treatment_id = ? AND status = ? AND planned_event_id IS NULL", treatment.ID, models.PlanItemStatusPlanned).
+		Find(&items).Error; err != nil {
+		return err
+	}
+	for i := range items {
+		if err := chartPlannedItem(tx, &items[i], treatment.DentistID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cancelBudgetPlanItems cancels the items not performed yet of a cancelled budget
+func cancelBudgetPlanItems(tx *gorm.DB, budgetID uint) error {
+	var items []models.TreatmentPlanItem
+	if err := tx.Where("budget_id = ? AND status = ?", budgetID, models.PlanItemStatusPlanned).Find(&items).Error; err != nil {
+		return err
+	}
+	for i := range items {
+		if err := unchartPlannedItem(tx, &items[i]); err != nil {
+			return err
+		}
+	}
+	return tx.Model(&models.TreatmentPlanItem{}).
+		Where("budget_id = ? AND status = ?", budgetID, models.PlanItemStatusPlanned).
+		Updates(map[string]interface{}{"status": models.PlanItemStatusCancelled, "updated_at": time.Now()}).Error
+}
+
+// loadPlanItemsToComplete returns the plan items performed in an appointment: the given ones, or
+// every planned item scheduled for the appointment. Returns an error message when an item cannot be completed
+func loadPlanItemsToComplete(db *gorm.DB, appointment models.Appointment, itemIDs []uint) ([]models.TreatmentPlanItem, string) {
+	var items []models.TreatmentPlanItem
+	if itemIDs == nil {
+		db.Session(&gorm.Session{NewDB: true}).
+			Where("appointment_id = ? AND status = ?", appointment.ID, models.PlanItemStatusPlanned).
+			Order("position ASC").Find(&items)
+		return items, ""
+	}
+	if len(itemIDs) == 0 {
+		return items, ""
+	}
+
+	db.Session(&gorm.Session{NewDB: true}).Where("id IN ?", itemIDs).Order("position ASC").Find(&items)
+	if len(items) != len(itemIDs) {
+		return nil, "Procedimento do plano de tratamento não encontrado"
+	}
+	for _, item := range items {
+		if msg := planItemCompletionError(item, appointment.PatientID); msg != "" {
+			return nil, msg
+		}
+	}
+	return items, ""
+}
+
+// planItemCompletionError returns why an item cannot be completed (empty when it can)
+func planItemCompletionError(item models.TreatmentPlanItem, patientID uint) string {
+	switch {
+	case item.PatientID != patientID:
+		return fmt.Sprintf("O procedimento '%s' pertence a outro paciente", item.Procedure)
+	case item.TreatmentID == nil:
+		return fmt.Sprintf("O procedimento '%s' é de um orçamento ainda não aprovado", item.Procedure)
+	case item.Status != models.PlanItemStatusPlanned:
+		return fmt.Sprintf("O procedimento '%s' não está pendente", item.Procedure)
+	}
+	return ""
+}
+
+// completePlanItems ticks off performed plan items, charts the resulting conditions on the odontogram
+// and generates the commissions paid on completion
+func completePlanItems(tx *gorm.DB, items []models.TreatmentPlanItem, appointmentID *uint, performedByID uint) error {
+	now := time.Now()
+	for i := range items {
+		item := &items[i]
+
+		itemAppointmentID := appointmentID
+		if itemAppointmentID == nil {
+			itemAppointmentID = item.AppointmentID
+		}
+
+		var performedEventID *uint
+		if item.Tooth != nil && item.Condition != "" {
+			notes := fmt.Sprintf("Procedimento realizado: %s", item.Procedure)
+			if itemAppointmentID != nil {
+				notes += fmt.Sprintf(" (agendamento #%d)", *itemAppointmentID)
+			}
+			event := models.ToothEvent{
+				PatientID:  item.PatientID,
+				DentistID:  performedByID,
+				Tooth:      *item.Tooth,
+				Surfaces:   item.Surfaces,
+				Condition:  helpers.PerformedToothCondition(item.Condition),
+				State:      models.ToothStateExisting,
+				Notes:      notes,
+				RecordedAt: now,
+			}
+			if err := tx.Create(&event).Error; err != nil {
+				return err
+			}
+			performedEventID = &event.ID
+		}
+
+		if err := tx.Exec(`
+			UPDATE treatment_plan_items
+			SET status = ?, completed_at = ?, performed_by_id = ?, appointment_id = ?, performed_event_id = ?, updated_at = NOW()
+			WHERE id = ?
+		`, models.PlanItemStatusCompleted, now, performedByID, itemAppointmentID, performedEventID, item.ID).Error; err != nil {
+			return err
+		}
+		item.Status = models.PlanItemStatusCompleted
+		item.CompletedAt = &now
+		item.PerformedByID = &performedByID
+		item.AppointmentID = itemAppointmentID
+		item.PerformedEventID = performedEventID
+	}
+	return generateCompletionCommissions(tx, items, performedByID, now)
+}
+
+// completeAppointmentPlanItems completes the plan items performed in an appointment being completed.
+// Items scheduled for it but not performed go back to the plan to be scheduled again
+func completeAppointmentPlanItems(tx *gorm.DB, appointment models.Appointment, items []models.TreatmentPlanItem) error {
+	appointmentID := appointment.ID
+	if err := completePlanItems(tx, items, &appointmentID, appointment.DentistID); err != nil {
+		return err
+	}
+	return tx.Model(&models.TreatmentPlanItem{}).
+		Where("appointment_id = ? AND status = ?", appointment.ID, models.PlanItemStatusPlanned).
+		Update("appointment_id", nil).Error
+}
+
+// loadAppointmentPlanItems returns the plan items scheduled for or performed in an appointment
+func loadAppointmentPlanItems(db *gorm.DB, appointmentID uint) []models.TreatmentPlanItem {
+	items := []models.TreatmentPlanItem{}
+	db.Session(&gorm.Session{NewDB: true}).Where("appointment_id = ?", appointmentID).Order("treatment_id ASC, position ASC").Find(&items)
+	return items
+}
+
+// GetTreatmentProgress returns the plan items of a treatment with its clinical and financial progress
+// GET /treatments/:id/progress
+func GetTreatmentProgress(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var treatment models.Treatment
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&treatment, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tratamento não encontrado"})
+		return
+	}
+
+	items := []models.TreatmentPlanItem{}
+	if err := db.Session(&gorm.Session{NewDB: true}).Where("treatment_id = ?", treatment.ID).Order("position ASC").Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar plano de tratamento"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"treatment_id": treatment.ID,
+		"status":       treatment.Status,
+		"items":        items,
+		"progress":     helpers.BuildTreatmentPlanProgress(items, treatment.TotalValue, treatment.PaidValue),
+	})
+}
+
+// UpdateTreatmentPlanItemRequest schedules, cancels, reopens or completes a plan item
+type UpdateTreatmentPlanItemRequest struct {
+	AppointmentID *uint    `json:"appointment_id"` // 0 removes the item from its appointment
+	Status        string   `json:"status"`         // planned, completed or cancelled
+	Notes         *string  `json:"notes"`
+	LabCost       *float64 `json:"lab_cost"` // Deducted from net commissions
+}
+
+// UpdateTreatmentPlanItem updates a plan item of a treatment
+// Performed items are part of the odontogram and cannot be changed
+// PUT /treatments/:id/plan-items/:item_id
+func UpdateTreatmentPlanItem(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	var item models.TreatmentPlanItem
+	if err := db.Session(&gorm.Session{NewDB: true}).
+		Where("id = ? AND treatment_id = ?", c.Param("item_id"), c.Param("id")).
+		First(&item).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Procedimento não encontrado neste tratamento"})
+		return
+	}
+
+	var req UpdateTreatmentPlanItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if item.Status == models.PlanItemStatusCompleted {
+		c.JSON(http.StatusConflict, gin.H{"error": "Procedimento já realizado não pode ser alterado"})
+		return
+	}
+	if req.Status != "" && req.Status != models.PlanItemStatusPlanned &&
+		req.Status != models.PlanItemStatusCompleted && req.Status != models.PlanItemStatusCancelled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status inválido. Use planned, completed ou cancelled"})
+		return
+	}
+
+	var treatment models.Treatment
+	if err := db.Session(&gorm.Session{NewDB: true}).First(&treatment, *item.TreatmentID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tratamento não encontrado"})
+		return
+	}
+
+	if req.LabCost != nil && *req.LabCost < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Custo de laboratório não pode ser negativo"})
+		return
+	}
+
+	if req.AppointmentID != nil && *req.AppointmentID > 0 {
+		var appointment models.Appointment
+		if err := db.Session(&gorm.Session{NewDB: true}).First(&appointment, *req.AppointmentID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Agendamento não encontrado"})
+			return
+		}
+		if appointment.PatientID != item.PatientID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "O agendamento é de outro paciente"})
+			return
+		}
+		if appointment.Status == models.AppointmentStatusCompleted || appointment.Status == models.AppointmentStatusCancelled {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Não é possível agendar procedimentos em um agendamento concluído ou cancelado"})
+			return
+		}
+	}
+
+	err := db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		if req.AppointmentID != nil {
+			item.AppointmentID = req.AppointmentID
+			if *req.AppointmentID == 0 {
+				item.AppointmentID = nil
+			}
+		}
+		if req.Notes != nil {
+			item.Notes = *req.Notes
+		}
+		if req.LabCost != nil {
+			item.LabCost = math.Round(*req.LabCost*100) / 100
+		}
+		if err := tx.Exec("UPDATE treatment_plan_items SET appointment_id = ?, notes = ?, lab_cost = ?, updated_at = NOW() WHERE id = ?",
+			item.AppointmentID, item.Notes, item.LabCost, item.ID).Error; err != nil {
+			return err
+		}
+
+		switch {
+		case req.Status == models.PlanItemStatusCancelled && item.Status == models.PlanItemStatusPlanned:
+			if err := unchartPlannedItem(tx, &item); err != nil {
+				return err
+			}
+			item.Status = models.PlanItemStatusCancelled
+			return tx.Model(&models.TreatmentPlanItem{}).Where("id = ?", item.ID).Update("status", item.Status).Error
+		case req.Status == models.PlanItemStatusPlanned && item.Status == models.PlanItemStatusCancelled:
+			item.Status = models.PlanItemStatusPlanned
+			if err := tx.Model(&models.TreatmentPlanItem{}).Where("id = ?", item.ID).Update("status", item.Status).Error; err != nil {
+				return err
+			}
+			return chartPlannedItem(tx, &item, treatment.DentistID)
+		case req.Status == models.PlanItemStatusCompleted:
+			if item.Status != models.PlanItemStatusPlanned {
+				return errPlanItemNotPending
+			}
+			items := []models.TreatmentPlanItem{item}
+			if err := completePlanItems(tx, items, item.AppointmentID, c.GetUint("user_id")); err != nil {
+				return err
+			}
+			item = items[0]
+		}
+		return nil
+	})
+	if err == errPlanItemNotPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Reabra o procedimento cancelado antes de concluí-lo"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar procedimento"})
+		return
+	}
+
+	if req.Status != "" {
+		helpers.AuditAction(c, "update_status", "treatment_plan_items", item.ID, true, map[string]interface{}{
+			"treatment_id": treatment.ID,
+			"status":       item.Status,
+		})
+	}
+
+	db.Session(&gorm.Session{NewDB: true}).First(&item, item.ID)
+	c.JSON(http.StatusOK, gin.H{"item": item})
+}
@@ -44,11 +44,16 @@ func CreateTreatmentProtocol(c *gin.Context) {
 		procedures = protocol.Procedures
 	}
 
+	var requiredEquipment interface{}
+	if protocol.RequiredEquipment != "" {
+		requiredEquipment = protocol.RequiredEquipment
+	}
+
 	// Use raw SQL to create
 	createSQL := `
 		INSERT INTO treatment_protocols
-		(name, description, procedures, duration, cost, active, created_by, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		(name, description, procedures, required_equipment, duration, cost, active, created_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		RETURNING id
 	`
 
@@ -59,6 +64,7 @@ func CreateTreatmentProtocol(c *gin.Context) {
 		protocol.Name,
 		protocol.Description,
 		procedures,
+		requiredEquipment,
 		protocol.Duration,
 		protocol.Cost,
 		protocol.Active,
@@ -158,7 +164,8 @@ func UpdateTreatmentProtocol(c *gin.Context) {
 		return
 	}
 
-	var updates models.TreatmentProtocol
+	// Start from the stored protocol so fields omitted from the payload keep their values
+	updates := protocol
 	if err := c.ShouldBindJSON(&updates); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -171,6 +178,10 @@ func UpdateTreatmentProtocol(c *gin.Context) {
 	} else {
 		procedures = updates.Procedures
 	}
+	var requiredEquipment interface{}
+	if updates.RequiredEquipment != "" {
+		requiredEquipment = updates.RequiredEquipment
+	}
 
 	// Use raw SQL to update
 	updateSQL := `
@@ -181,7 +192,8 @@ func UpdateTreatmentProtocol(c *gin.Context) {
 		    duration = $4,
 		    cost = $5,
 		    active = $6,
-		    updated_at = $7
+		    updated_at = $7,
+		    required_equipment = $9
 		WHERE id = $8
 	`
 
@@ -194,6 +206,7 @@ func UpdateTreatmentProtocol(c *gin.Context) {
 		updates.Active,
 		time.Now(),
 		id,
+		requiredEquipment,
 	).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update protocol"})
 		return
@@ -227,5 +240,8 @@ func DeleteTreatmentProtocol(c *gin.Context) {
 		return
 	}
 
+	// Note templates linked to the protocol stay available without the link
+	db.Exec("UPDATE clinical_note_templates SET treatment_protocol_id = NULL WHERE treatment_protocol_id = ?", protocol.ID)
+
 	c.JSON(http.StatusOK, gin.H{"message": "Protocol deleted successfully"})
 }
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // CreateWaitingListEntry adds a patient to the waiting list
@@ -208,7 +209,7 @@ func GetWaitingList(c *gin.Context) {
 		LEFT JOIN public.users u ON u.id = wl.dentist_id
 		` + whereClause + `
 		ORDER BY
-			CASE WHEN wl.priority = 'urgent' THEN 0 ELSE 1 END,
+			` + models.WaitingListPriorityRank("wl.priority") + `,
 			wl.created_at ASC
 		LIMIT ? OFFSET ?
 	`
@@ -492,6 +493,16 @@ func ScheduleWaitingListEntry(c *gin.Context) {
 		return
 	}
 
+	if err := markWaitingListScheduled(db, id, request.AppointmentID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark as scheduled"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Marked as scheduled", "id": id, "appointment_id": request.AppointmentID})
+}
+
+// markWaitingListScheduled links a waiting list entry to the appointment that was booked for it
+func markWaitingListScheduled(db *gorm.DB, entryID interface{}, appointmentID uint) error {
 	now := time.Now()
 
 	updateSQL := `
@@ -503,12 +514,7 @@ func ScheduleWaitingListEntry(c *gin.Context) {
 		WHERE id = $4
 	`
 
-	if err := db.Exec(updateSQL, now, request.AppointmentID, now, id).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark as scheduled"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Marked as scheduled", "id": id, "appointment_id": request.AppointmentID})
+	return db.Exec(updateSQL, now, appointmentID, now, entryID).Error
 }
 
 // DeleteWaitingListEntry soft deletes a waiting list entry
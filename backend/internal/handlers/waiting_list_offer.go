@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"drcrwell/backend/internal/database"
+	"drcrwell/backend/internal/middleware"
+	"drcrwell/backend/internal/models"
+	"drcrwell/backend/internal/scheduler"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errOfferUnavailable is returned when an offer can no longer be claimed
+var errOfferUnavailable = errors.New("offer unavailable")
+
+// GetWaitingListOffers lists the slot offers sent to the waiting list (staff view)
+// GET /waiting-list/offers?waiting_list_id=X&source_appointment_id=Y&status=Z
+func GetWaitingListOffers(c *gin.Context) {
+	db, ok := middleware.GetDBFromContextSafe(c); if !ok { return }
+
+	query := db.Model(&models.WaitingListOffer{})
+	if entryID := c.Query("waiting_list_id"); entryID != "" {
+		query = query.Where("waiting_list_id = ?", entryID)
+	}
+	if sourceID := c.Query("source_appointment_id"); sourceID != "" {
+		query = query.Where("source_appointment_id = ?", sourceID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var offers []models.WaitingListOffer
+	if err := query.Order("created_at DESC").Limit(100).Find(&offers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar ofertas da lista de espera"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"offers": offers})
+}
+
+// loadOfferFromToken resolves the tenant embedded in a public offer token and loads the offer
+// Returns the tenant schema name; public routes have no tenant middleware, so queries qualify tables explicitly
+func loadOfferFromToken(c *gin.Context) (string, uint, models.WaitingListOffer, bool) {
+	var offer models.WaitingListOffer
+
+	token := c.Param("token")
+	tenantID, ok := scheduler.ParseOfferToken(token)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Oferta não encontrada"})
+		return "", 0, offer, false
+	}
+
+	var tenant models.Tenant
+	if err := database.GetDB().Where("id = ? AND active = ?", tenantID, true).First(&tenant).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Oferta não encontrada"})
+		return "", 0, offer, false
+	}
+
+	schemaName := fmt.Sprintf("tenant_%d", tenantID)
+	if err := database.GetDB().Table(schemaName+".waiting_list_offers").
+		Where("token = ? AND deleted_at IS NULL", token).First(&offer).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Oferta não encontrada"})
+		return "", 0, offer, false
+	}
+
+	return schemaName, tenantID, offer, true
+}
+
+// GetPublicWaitingListOffer shows an offered slot to the patient
+// GET /api/waiting-list/offers/:token (public)
+func GetPublicWaitingListOffer(c *gin.Context) {
+	_, tenantID, offer, ok := loadOfferFromToken(c)
+	if !ok {
+		return
+	}
+
+	var clinicName string
+	database.GetDB().Table("public.tenant_settings").Where("tenant_id = ?", tenantID).Select("clinic_name").Scan(&clinicName)
+	var dentistName string
+	database.GetDB().Table("public.users").Where("id = ?", offer.DentistID).Select("name").Scan(&dentistName)
+
+	status := offer.Status
+	if status == models.WaitingListOfferPending && time.Now().After(offer.ExpiresAt) {
+		status = models.WaitingListOfferExpired
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"clinic_name":  clinicName,
+		"dentist_name": dentistName,
+		"procedure":    offer.Procedure,
+		"start_time":   offer.StartTime,
+		"end_time":     offer.EndTime,
+		"expires_at":   offer.ExpiresAt,
+		"status":       status,
+	})
+}
+
+// ClaimWaitingListOffer books the offered slot for the patient and closes their waiting list entry
+// POST /api/waiting-list/offers/:token/claim (public)
+func ClaimWaitingListOffer(c *gin.Context) {
+	schemaName, tenantID, offer, ok := loadOfferFromToken(c)
+	if !ok {
+		return
+	}
+
+	var appointment models.Appointment
+	conflictMessage := ""
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		// SET LOCAL keeps the tenant search_path on this transaction's connection only
+		if err := tx.Exec(fmt.Sprintf("SET LOCAL search_path TO %s", schemaName)).Error; err != nil {
+			return err
+		}
+
+		// Guarded update: only one claim can win, and only while the hold is valid
+		result := tx.Exec(`UPDATE waiting_list_offers SET status = ?, responded_at = NOW(), updated_at = NOW()
+			WHERE id = ? AND status = ? AND expires_at > NOW() AND deleted_at IS NULL`,
+			models.WaitingListOfferClaimed, offer.ID, models.WaitingListOfferPending)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errOfferUnavailable
+		}
+
+		appointment = models.Appointment{
+			PatientID: offer.PatientID,
+			DentistID: offer.DentistID,
+			RoomID:    offer.RoomID,
+			StartTime: offer.StartTime,
+			EndTime:   offer.EndTime,
+			Procedure: offer.Procedure,
+			Status:    "scheduled",
+			Notes:     "Agendado pela lista de espera",
+		}
+		applyRoomName(tx, &appointment)
+
+		hasConflict, message, err := checkAppointmentConflict(tx, tenantID, appointment)
+		if err != nil {
+			return err
+		}
+		if hasConflict {
+			conflictMessage = message
+			return errOfferUnavailable
+		}
+
+		if err := tx.Create(&appointment).Error; err != nil {
+			return err
+		}
+		if err := markWaitingListScheduled(tx, offer.WaitingListID, appointment.ID); err != nil {
+			return err
+		}
+		return tx.Exec(`UPDATE waiting_list_offers SET appointment_id = ? WHERE id = ?`, appointment.ID, offer.ID).Error
+	})
+
+	if message, ok := overlapConflictMessage(err); ok {
+		// A concurrent booking took the slot after the conflict check
+		conflictMessage = message
+		err = errOfferUnavailable
+	}
+	if errors.Is(err, errOfferUnavailable) {
+		if conflictMessage != "" {
+			// The slot was taken meanwhile - close the offer so it is not retried
+			database.GetDB().Exec(fmt.Sprintf(`UPDATE %s.waiting_list_offers SET status = ?, error_message = ?, updated_at = NOW() WHERE id = ? AND status = ?`, schemaName),
+				models.WaitingListOfferFailed, conflictMessage, offer.ID, models.WaitingListOfferPending)
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": "Este horário não está mais disponível"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao confirmar agendamento"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Agendamento confirmado com sucesso",
+		"appointment_id": appointment.ID,
+		"start_time":     appointment.StartTime,
+		"end_time":       appointment.EndTime,
+	})
+}
+
+// DeclineWaitingListOffer releases the slot so it is offered to the next patient in line
+// POST /api/waiting-list/offers/:token/decline (public)
+func DeclineWaitingListOffer(c *gin.Context) {
+	schemaName, tenantID, offer, ok := loadOfferFromToken(c)
+	if !ok {
+		return
+	}
+
+	result := database.GetDB().Exec(fmt.Sprintf(`UPDATE %s.waiting_list_offers SET status = ?, responded_at = NOW(), updated_at = NOW()
+		WHERE id = ? AND status = ? AND deleted_at IS NULL`, schemaName),
+		models.WaitingListOfferDeclined, offer.ID, models.WaitingListOfferPending)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao recusar oferta"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Esta oferta não está mais disponível"})
+		return
+	}
+
+	scheduler.ReleaseWaitingListEntry(database.GetDB(), schemaName, offer.WaitingListID)
+	go scheduler.OfferFreedSlot(tenantID, offer.SourceAppointmentID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Oferta recusada. Você continua na lista de espera."})
+}
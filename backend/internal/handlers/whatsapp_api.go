@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	"drcrwell/backend/internal/availability"
 	"drcrwell/backend/internal/models"
+	"drcrwell/backend/internal/scheduler"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -119,7 +123,7 @@ type WhatsAppRescheduleRequest struct {
 type WhatsAppWaitingListRequest struct {
 	Procedure      string   `json:"procedure" binding:"required"`
 	PreferredDates []string `json:"preferred_dates,omitempty"` // Array of dates in YYYY-MM-DD format
-	Priority       string   `json:"priority,omitempty"`        // normal, urgent
+	Priority       string   `json:"priority,omitempty"`        // urgent, high, normal, low
 	Notes          string   `json:"notes,omitempty"`
 	DentistID      *uint    `json:"dentist_id,omitempty"`
 }
@@ -169,6 +173,7 @@ func getAppointmentStatusLabel(status string) string {
 	labels := map[string]string{
 		"scheduled":   "Agendado",
 		"confirmed":   "Confirmado",
+		"arrived":     "Aguardando Atendimento",
 		"in_progress": "Em Atendimento",
 		"completed":   "Concluído",
 		"cancelled":   "Cancelado",
@@ -657,10 +662,19 @@ func WhatsAppCancelAppointment(c *gin.Context) {
 	}
 
 	// Use fresh session to avoid GORM state pollution from previous queries
-	err = db.Session(&gorm.Session{}).Model(&models.Appointment{}).Where("id = ?", appointment.ID).Updates(map[string]interface{}{
-		"status": "cancelled",
-		"notes":  notes,
-	}).Error
+	err = db.Session(&gorm.Session{}).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Appointment{}).Where("id = ?", appointment.ID).Updates(map[string]interface{}{
+			"status": "cancelled",
+			"notes":  notes,
+		}).Error; err != nil {
+			return err
+		}
+		return recordAppointmentStatus(tx, appointment.ID, appointment.Status, models.AppointmentStatusCancelled, appointmentStatusChange{
+			Source:     models.StatusSourceWhatsApp,
+			ReasonCode: models.CancellationReasonPatientRequest,
+			Reason:     req.Reason,
+		})
+	})
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -670,6 +684,9 @@ func WhatsAppCancelAppointment(c *gin.Context) {
 		return
 	}
 
+	// Offer the freed slot to the waiting list
+	go scheduler.OfferFreedSlot(c.GetUint("tenant_id"), appointment.ID)
+
 	c.JSON(http.StatusOK, gin.H{
 		"error":   false,
 		"message": fmt.Sprintf("Agendamento do dia %s às %s foi cancelado com sucesso.",
@@ -679,7 +696,8 @@ func WhatsAppCancelAppointment(c *gin.Context) {
 }
 
 // WhatsAppGetAvailableSlots returns available time slots for a specific date
-// GET /api/whatsapp/slots?date=YYYY-MM-DD&dentist_id=X
+// Slots held by waiting-list offers are only offered to the patient they were sent to (patient_id)
+// GET /api/whatsapp/slots?date=YYYY-MM-DD&dentist_id=X&procedure=Y&patient_id=P
 func WhatsAppGetAvailableSlots(c *gin.Context) {
 	db, ok := getDBSafe(c)
 	if !ok {
@@ -717,20 +735,14 @@ func WhatsAppGetAvailableSlots(c *gin.Context) {
 
 	// Get clinic settings for working hours
 	var settings models.TenantSettings
-	db.Table("public.tenant_settings").First(&settings)
-
-	workingStart := "08:00"
-	workingEnd := "18:00"
-	slotDuration := 30 // minutes
+	db.Session(&gorm.Session{NewDB: true}).Table("public.tenant_settings").Where("tenant_id = ?", c.GetUint("tenant_id")).First(&settings)
 
-	if settings.WorkingHoursStart != "" {
-		workingStart = settings.WorkingHoursStart
-	}
-	if settings.WorkingHoursEnd != "" {
-		workingEnd = settings.WorkingHoursEnd
-	}
-	if settings.DefaultAppointmentDuration > 0 {
-		slotDuration = settings.DefaultAppointmentDuration
+	// Duration follows the requested procedure (treatment protocol) or the clinic default
+	protocolID, _ := strconv.ParseUint(c.Query("protocol_id"), 10, 32)
+	duration := availability.ResolveDuration(db, settings, uint(protocolID), c.Query("procedure"))
+	opts := availability.OptionsFromSettings(settings, duration)
+	if patientID, err := strconv.ParseUint(c.Query("patient_id"), 10, 32); err == nil {
+		opts.PatientID = uint(patientID)
 	}
 
 	// Get all dentists or specific one
@@ -750,78 +762,26 @@ func WhatsAppGetAvailableSlots(c *gin.Context) {
 		return
 	}
 
-	// Get existing appointments for the date
-	startOfDay := date
-	endOfDay := date.Add(24 * time.Hour)
-
-	var existingAppointments []models.Appointment
-	db.Where("start_time >= ? AND start_time < ? AND status NOT IN (?)",
-		startOfDay, endOfDay, []string{"cancelled"}).
-		Find(&existingAppointments)
-
-	// Create a map of busy slots per dentist
-	busySlots := make(map[uint]map[string]bool)
-	for _, apt := range existingAppointments {
-		if busySlots[apt.DentistID] == nil {
-			busySlots[apt.DentistID] = make(map[string]bool)
-		}
-		// Mark all slots during the appointment as busy
-		current := apt.StartTime.Time
-		for current.Before(apt.EndTime.Time) {
-			busySlots[apt.DentistID][current.Format("15:04")] = true
-			current = current.Add(time.Duration(slotDuration) * time.Minute)
-		}
-	}
-
-	// Parse working hours
-	startTime, _ := time.Parse("15:04", workingStart)
-	endTime, _ := time.Parse("15:04", workingEnd)
-
-	// Parse lunch break hours
-	var lunchStart, lunchEnd time.Time
-	hasLunchBreak := settings.LunchBreakEnabled && settings.LunchBreakStart != "" && settings.LunchBreakEnd != ""
-	if hasLunchBreak {
-		lunchStart, _ = time.Parse("15:04", settings.LunchBreakStart)
-		lunchEnd, _ = time.Parse("15:04", settings.LunchBreakEnd)
-	}
-
 	// Generate available slots
 	availableSlots := make([]WhatsAppAvailableSlot, 0)
 
-	loc := getTimezone()
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, availability.Location())
 	for _, dentist := range dentists {
-		current := time.Date(date.Year(), date.Month(), date.Day(),
-			startTime.Hour(), startTime.Minute(), 0, 0, loc)
-		endOfWork := time.Date(date.Year(), date.Month(), date.Day(),
-			endTime.Hour(), endTime.Minute(), 0, 0, loc)
-
-		for current.Before(endOfWork) {
-			slotTime := current.Format("15:04")
-
-			// Check if slot is during lunch break
-			isLunchTime := false
-			if hasLunchBreak {
-				slotHour := current.Hour()
-				slotMinute := current.Minute()
-				lunchStartMinutes := lunchStart.Hour()*60 + lunchStart.Minute()
-				lunchEndMinutes := lunchEnd.Hour()*60 + lunchEnd.Minute()
-				slotMinutes := slotHour*60 + slotMinute
-				isLunchTime = slotMinutes >= lunchStartMinutes && slotMinutes < lunchEndMinutes
-			}
-
-			// Check if slot is not busy, not during lunch, and is in the future
-			if !busySlots[dentist.ID][slotTime] && !isLunchTime && current.After(time.Now()) {
-				slotEnd := current.Add(time.Duration(slotDuration) * time.Minute)
-				availableSlots = append(availableSlots, WhatsAppAvailableSlot{
-					Date:        date.Format("02/01/2006"),
-					StartTime:   slotTime,
-					EndTime:     slotEnd.Format("15:04"),
-					DentistID:   dentist.ID,
-					DentistName: dentist.Name,
-				})
-			}
+		// Dentist schedule (or clinic hours), absences, appointments and buffer time
+		slots, err := availability.FindSlots(db, settings, dentist.ID, day, opts)
+		if err != nil {
+			log.Printf("[WhatsApp API] Error loading schedule for dentist %d: %v", dentist.ID, err)
+			continue
+		}
 
-			current = current.Add(time.Duration(slotDuration) * time.Minute)
+		for _, slot := range slots {
+			availableSlots = append(availableSlots, WhatsAppAvailableSlot{
+				Date:        date.Format("02/01/2006"),
+				StartTime:   slot.Start.Format("15:04"),
+				EndTime:     slot.End.Format("15:04"),
+				DentistID:   dentist.ID,
+				DentistName: dentist.Name,
+			})
 		}
 	}
 
@@ -905,6 +865,28 @@ func WhatsAppRescheduleAppointment(c *gin.Context) {
 		return
 	}
 
+	// Rescheduling is a self-booking too: the no-show policy applies to the new time
+	restriction, err := patientNoShowRestriction(db, c.GetUint("tenant_id"), appointment.PatientID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   true,
+			"message": "Erro ao verificar disponibilidade",
+		})
+		return
+	}
+	if restriction.BlockSelfBooking {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   true,
+			"code":    "no_show_policy",
+			"message": noShowSelfBookingBlockedMessage,
+		})
+		return
+	}
+	if appointment.DepositPaidAt != nil {
+		// The deposit already paid still holds for the new time
+		restriction.RequireDeposit = false
+	}
+
 	// Parse new date and time
 	newDate, err := time.Parse("2006-01-02", req.NewDate)
 	if err != nil {
@@ -948,18 +930,27 @@ func WhatsAppRescheduleAppointment(c *gin.Context) {
 		dentistID = *req.PreferredDentist
 	}
 
-	// Check if slot is available (use fresh session to avoid state pollution)
-	var conflictCount int64
-	db.Session(&gorm.Session{}).Model(&models.Appointment{}).
-		Where("dentist_id = ? AND id != ? AND status NOT IN (?)", dentistID, appointment.ID, []string{"cancelled"}).
-		Where("(start_time < ? AND end_time > ?) OR (start_time < ? AND end_time > ?) OR (start_time >= ? AND end_time <= ?)",
-			newEndTime, newStartTime, newEndTime, newStartTime, newStartTime, newEndTime).
-		Count(&conflictCount)
-
-	if conflictCount > 0 {
+	// Check clinic closures, the dentist's working schedule and absences, and conflicts
+	// with the dentist's other appointments (keeping the clinic cleanup time between them)
+	hasConflict, conflictMessage, err := checkAppointmentConflict(db, c.GetUint("tenant_id"), models.Appointment{
+		PatientID: appointment.PatientID,
+		DentistID: dentistID,
+		RoomID:    appointment.RoomID,
+		Procedure: appointment.Procedure,
+		StartTime: models.LocalTime{Time: newStartTime},
+		EndTime:   models.LocalTime{Time: newEndTime},
+	}, appointment.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   true,
+			"message": "Erro ao verificar disponibilidade",
+		})
+		return
+	}
+	if hasConflict {
 		c.JSON(http.StatusConflict, gin.H{
 			"error":   true,
-			"message": "O horário selecionado não está disponível. Por favor, escolha outro horário.",
+			"message": conflictMessage,
 		})
 		return
 	}
@@ -978,17 +969,50 @@ func WhatsAppRescheduleAppointment(c *gin.Context) {
 		oldDate, oldTime,
 		newStartTime.Format("02/01/2006"), newStartTime.Format("15:04"))
 
-	// Use fresh session to avoid GORM state pollution from previous queries
-	err = db.Session(&gorm.Session{}).Model(&models.Appointment{}).Where("id = ?", appointment.ID).Updates(map[string]interface{}{
+	// Flag the rescheduled appointment with what the no-show policy requires
+	rescheduled := models.Appointment{
+		ID:        appointment.ID,
+		PatientID: appointment.PatientID,
+		Notes:     notes,
+	}
+	applyNoShowRestriction(&rescheduled, restriction)
+
+	updates := map[string]interface{}{
 		"start_time": newStartTime,
 		"end_time":   newEndTime,
 		"dentist_id": dentistID,
 		"status":     "scheduled",
 		"confirmed":  false,
-		"notes":      notes,
-	}).Error
+		"notes":      rescheduled.Notes,
+	}
+	if rescheduled.StaffConfirmationRequired {
+		updates["staff_confirmation_required"] = true
+	}
+	if rescheduled.DepositRequired {
+		updates["deposit_required"] = true
+		updates["deposit_amount"] = rescheduled.DepositAmount
+	}
+
+	// Use fresh session to avoid GORM state pollution from previous queries
+	err = db.Session(&gorm.Session{}).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Appointment{}).Where("id = ?", appointment.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		// A confirmed appointment goes back to scheduled and must be confirmed again
+		return recordAppointmentStatus(tx, appointment.ID, appointment.Status, models.AppointmentStatusScheduled, appointmentStatusChange{
+			Source: models.StatusSourceWhatsApp,
+			Reason: "Remarcado via WhatsApp",
+		})
+	})
 
 	if err != nil {
+		if _, ok := overlapConflictMessage(err); ok {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   true,
+				"message": "O horário selecionado não está disponível. Por favor, escolha outro horário.",
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   true,
 			"message": "Erro ao remarcar agendamento",
@@ -996,14 +1020,20 @@ func WhatsAppRescheduleAppointment(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"error":   false,
 		"message": fmt.Sprintf("Agendamento remarcado com sucesso! Nova data: %s às %s",
 			newStartTime.Format("02/01/2006"),
 			newStartTime.Format("15:04")),
+		"notice":   noShowBookingNotice(restriction),
 		"new_date": newStartTime.Format("02/01/2006"),
 		"new_time": newStartTime.Format("15:04"),
-	})
+	}
+	// The deposit can be paid right away by PIX ("copia e cola" to send in the chat)
+	if charge := depositPixCharge(db, c.GetUint("tenant_id"), rescheduled); charge != nil {
+		response["pix"] = pixChargeResponse(charge)
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // WhatsAppAddToWaitingList adds patient to waiting list
@@ -1073,9 +1103,10 @@ func WhatsAppAddToWaitingList(c *gin.Context) {
 	}
 
 	// Set default priority
-	priority := "normal"
-	if req.Priority == "urgent" {
-		priority = "urgent"
+	priority := models.WaitingListPriorityNormal
+	switch req.Priority {
+	case models.WaitingListPriorityUrgent, models.WaitingListPriorityHigh, models.WaitingListPriorityLow:
+		priority = req.Priority
 	}
 
 	// Create waiting list entry
@@ -1208,8 +1239,13 @@ func WhatsAppGetWaitingListStatus(c *gin.Context) {
 	response := make([]WaitingListResponse, 0)
 	for _, entry := range entries {
 		priorityLabel := "Normal"
-		if entry.Priority == "urgent" {
+		switch entry.Priority {
+		case models.WaitingListPriorityUrgent:
 			priorityLabel = "Urgente"
+		case models.WaitingListPriorityHigh:
+			priorityLabel = "Alta"
+		case models.WaitingListPriorityLow:
+			priorityLabel = "Baixa"
 		}
 
 		statusLabel := "Aguardando"
@@ -1376,7 +1412,7 @@ type WhatsAppCreateAppointmentRequest struct {
 	Date      string `json:"date" binding:"required"` // Format: YYYY-MM-DD
 	Time      string `json:"time" binding:"required"` // Format: HH:MM
 	Notes     string `json:"notes"`
-	Duration  int    `json:"duration"` // Duration in minutes (optional, default 30)
+	Duration  int    `json:"duration"` // Duration in minutes (optional, defaults to the procedure protocol or clinic duration)
 }
 
 // WhatsAppCreateAppointmentResponse represents the response for appointment creation
@@ -1559,12 +1595,14 @@ func WhatsAppCreateAppointment(c *gin.Context) {
 		return
 	}
 
-	// Get duration (default 30 minutes)
-	duration := 30
-	if req.Duration > 0 {
-		duration = req.Duration
+	// Get duration: explicit value, or the procedure's protocol duration, or the clinic default
+	duration := time.Duration(req.Duration) * time.Minute
+	if duration <= 0 {
+		var settings models.TenantSettings
+		db.Session(&gorm.Session{NewDB: true}).Table("public.tenant_settings").Where("tenant_id = ?", c.GetUint("tenant_id")).First(&settings)
+		duration = availability.ResolveDuration(db, settings, 0, req.Procedure)
 	}
-	endTime := startTime.Add(time.Duration(duration) * time.Minute)
+	endTime := startTime.Add(duration)
 
 	// Use fresh sessions to avoid GORM state contamination
 	freshDB := db.Session(&gorm.Session{})
@@ -1605,6 +1643,24 @@ func WhatsAppCreateAppointment(c *gin.Context) {
 		}
 	}
 
+	// Patients with repeated no-shows may be restricted by the clinic policy
+	restriction, err := patientNoShowRestriction(db, c.GetUint("tenant_id"), patient.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   true,
+			"message": "Erro ao verificar disponibilidade",
+		})
+		return
+	}
+	if restriction.BlockSelfBooking {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   true,
+			"code":    "no_show_policy",
+			"message": noShowSelfBookingBlockedMessage,
+		})
+		return
+	}
+
 	// Verify dentist exists (using fresh session)
 	var dentist models.User
 	if err := db.Session(&gorm.Session{}).Table("public.users").Where("id = ? AND active = ?", req.DentistID, true).First(&dentist).Error; err != nil {
@@ -1615,17 +1671,25 @@ func WhatsAppCreateAppointment(c *gin.Context) {
 		return
 	}
 
-	// Check for time conflicts (using fresh session)
-	var conflictCount int64
-	db.Session(&gorm.Session{}).Model(&models.Appointment{}).
-		Where("dentist_id = ? AND status NOT IN (?)", req.DentistID, []string{"cancelled", "no_show"}).
-		Where("start_time < ? AND end_time > ?", endTime, startTime).
-		Count(&conflictCount)
-
-	if conflictCount > 0 {
+	// Check clinic closures, the dentist's working schedule and absences, and conflicts
+	// with the dentist's appointments (keeping the clinic cleanup time between them)
+	hasConflict, conflictMessage, err := checkAppointmentConflict(db, c.GetUint("tenant_id"), models.Appointment{
+		PatientID: patient.ID,
+		DentistID: req.DentistID,
+		StartTime: models.LocalTime{Time: startTime},
+		EndTime:   models.LocalTime{Time: endTime},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   true,
+			"message": "Erro ao verificar disponibilidade",
+		})
+		return
+	}
+	if hasConflict {
 		c.JSON(http.StatusConflict, gin.H{
 			"error":   true,
-			"message": "Conflito de horário. Já existe um agendamento para este profissional neste horário.",
+			"message": conflictMessage,
 		})
 		return
 	}
@@ -1653,8 +1717,17 @@ func WhatsAppCreateAppointment(c *gin.Context) {
 		Status:    "scheduled",
 		Notes:     notes,
 	}
+	applyNoShowRestriction(&appointment, restriction)
 
 	if err := db.Session(&gorm.Session{}).Create(&appointment).Error; err != nil {
+		// Another booking took the slot after the availability check
+		if _, ok := overlapConflictMessage(err); ok {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   true,
+				"message": "Conflito de horário. Já existe um agendamento para este profissional neste horário.",
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   true,
 			"message": "Erro ao criar agendamento",
@@ -1663,9 +1736,10 @@ func WhatsAppCreateAppointment(c *gin.Context) {
 	}
 
 	// Return response in expected format
-	c.JSON(http.StatusCreated, gin.H{
+	response := gin.H{
 		"error":   false,
 		"message": "Consulta agendada com sucesso",
+		"notice":  noShowBookingNotice(restriction),
 		"appointment": WhatsAppCreateAppointmentResponse{
 			ID:          appointment.ID,
 			Date:        startTime.Format("02/01/2006"),
@@ -1674,7 +1748,12 @@ func WhatsAppCreateAppointment(c *gin.Context) {
 			DentistName: dentist.Name,
 			Status:      "scheduled",
 		},
-	})
+	}
+	// The deposit can be paid right away by PIX ("copia e cola" to send in the chat)
+	if charge := depositPixCharge(db, c.GetUint("tenant_id"), appointment); charge != nil {
+		response["pix"] = pixChargeResponse(charge)
+	}
+	c.JSON(http.StatusCreated, response)
 }
 
 // WhatsAppDentistAppointmentResponse represents appointment data for dentist schedule
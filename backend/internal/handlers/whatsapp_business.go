@@ -1,14 +1,12 @@
 package handlers
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"regexp"
-	"strings"
 	"time"
 
 	"drcrwell/backend/internal/database"
@@ -19,98 +17,6 @@ import (
 	"gorm.io/gorm"
 )
 
-const (
-	MetaGraphAPIURL     = "https://graph.facebook.com/v18.0"
-	MetaGraphAPIVersion = "v18.0"
-)
-
-// WhatsApp Message Types
-type WhatsAppMessageType string
-
-const (
-	MessageTypeTemplate WhatsAppMessageType = "template"
-	MessageTypeText     WhatsAppMessageType = "text"
-)
-
-// Meta API Request/Response structures
-type MetaTemplateMessage struct {
-	MessagingProduct string                 `json:"messaging_product"`
-	RecipientType    string                 `json:"recipient_type"`
-	To               string                 `json:"to"`
-	Type             string                 `json:"type"`
-	Template         *MetaTemplateComponent `json:"template,omitempty"`
-}
-
-type MetaTemplateComponent struct {
-	Name       string                   `json:"name"`
-	Language   MetaTemplateLanguage     `json:"language"`
-	Components []MetaTemplateCompParams `json:"components,omitempty"`
-}
-
-type MetaTemplateLanguage struct {
-	Code string `json:"code"`
-}
-
-type MetaTemplateCompParams struct {
-	Type       string               `json:"type"`
-	Parameters []MetaTemplateParam  `json:"parameters,omitempty"`
-}
-
-type MetaTemplateParam struct {
-	Type          string `json:"type"`
-	Text          string `json:"text,omitempty"`
-	ParameterName string `json:"parameter_name,omitempty"`
-}
-
-type MetaSendMessageResponse struct {
-	MessagingProduct string `json:"messaging_product"`
-	Contacts         []struct {
-		Input string `json:"input"`
-		WaID  string `json:"wa_id"`
-	} `json:"contacts"`
-	Messages []struct {
-		ID string `json:"id"`
-	} `json:"messages"`
-}
-
-type MetaErrorResponse struct {
-	Error struct {
-		Message   string `json:"message"`
-		Type      string `json:"type"`
-		Code      int    `json:"code"`
-		FBTraceID string `json:"fbtrace_id"`
-	} `json:"error"`
-}
-
-// Template structures from Meta API
-type MetaTemplate struct {
-	Name       string              `json:"name"`
-	Status     string              `json:"status"`
-	Category   string              `json:"category"`
-	Language   string              `json:"language"`
-	ID         string              `json:"id"`
-	Components []MetaTemplateComp  `json:"components"`
-}
-
-type MetaTemplateComp struct {
-	Type    string `json:"type"`
-	Format  string `json:"format,omitempty"`
-	Text    string `json:"text,omitempty"`
-	Example *struct {
-		BodyText [][]string `json:"body_text,omitempty"`
-	} `json:"example,omitempty"`
-}
-
-type MetaTemplatesResponse struct {
-	Data   []MetaTemplate `json:"data"`
-	Paging struct {
-		Cursors struct {
-			Before string `json:"before"`
-			After  string `json:"after"`
-		} `json:"cursors"`
-	} `json:"paging"`
-}
-
 // Request/Response structures for our API
 type SendWhatsAppRequest struct {
 	Phone        string                 `json:"phone" binding:"required"`
@@ -159,7 +65,7 @@ func GetWhatsAppTemplates(c *gin.Context) {
 
 	// Fetch templates from Meta API
 	url := fmt.Sprintf("%s/%s/message_templates?fields=name,status,category,language,components",
-		MetaGraphAPIURL, settings.WhatsAppBusinessAccountID)
+		helpers.MetaGraphAPIURL, settings.WhatsAppBusinessAccountID)
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -180,7 +86,7 @@ func GetWhatsAppTemplates(c *gin.Context) {
 	body, _ := io.ReadAll(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		var metaErr MetaErrorResponse
+		var metaErr helpers.MetaErrorResponse
 		json.Unmarshal(body, &metaErr)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":      "Erro da Meta API",
@@ -190,7 +96,7 @@ func GetWhatsAppTemplates(c *gin.Context) {
 		return
 	}
 
-	var templatesResp MetaTemplatesResponse
+	var templatesResp helpers.MetaTemplatesResponse
 	if err := json.Unmarshal(body, &templatesResp); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao processar resposta da Meta"})
 		return
@@ -278,17 +184,17 @@ func SendWhatsAppMessage(c *gin.Context) {
 	}
 
 	// Normalize phone number (remove non-digits, add country code if needed)
-	phone := normalizePhoneNumber(req.Phone)
+	phone := helpers.NormalizeWhatsAppPhone(req.Phone)
 
 	// Build message payload
-	message := MetaTemplateMessage{
+	message := helpers.MetaTemplateMessage{
 		MessagingProduct: "whatsapp",
 		RecipientType:    "individual",
 		To:               phone,
 		Type:             "template",
-		Template: &MetaTemplateComponent{
+		Template: &helpers.MetaTemplateComponent{
 			Name: req.TemplateName,
-			Language: MetaTemplateLanguage{
+			Language: helpers.MetaTemplateLanguage{
 				Code: languageCode,
 			},
 		},
@@ -296,19 +202,19 @@ func SendWhatsAppMessage(c *gin.Context) {
 
 	// Add parameters if provided
 	if len(req.Parameters) > 0 {
-		params := make([]MetaTemplateParam, 0)
+		params := make([]helpers.MetaTemplateParam, 0)
 		// Parameters should be ordered by key (1, 2, 3, etc.)
 		for i := 1; i <= len(req.Parameters); i++ {
 			key := fmt.Sprintf("%d", i)
 			if val, ok := req.Parameters[key]; ok {
-				params = append(params, MetaTemplateParam{
+				params = append(params, helpers.MetaTemplateParam{
 					Type: "text",
 					Text: val,
 				})
 			}
 		}
 		if len(params) > 0 {
-			message.Template.Components = []MetaTemplateCompParams{
+			message.Template.Components = []helpers.MetaTemplateCompParams{
 				{
 					Type:       "body",
 					Parameters: params,
@@ -318,7 +224,7 @@ func SendWhatsAppMessage(c *gin.Context) {
 	}
 
 	// Send message
-	result, err := sendMetaMessage(settings.WhatsAppPhoneNumberID, accessToken, message)
+	result, err := helpers.SendMetaMessage(settings.WhatsAppPhoneNumberID, accessToken, message)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -409,10 +315,10 @@ func SendAppointmentConfirmation(c *gin.Context) {
 	timeStr := appointmentTime.Format("15:04")
 
 	// Normalize phone number
-	phone := normalizePhoneNumber(appointment.PatientPhone)
+	phone := helpers.NormalizeWhatsAppPhone(appointment.PatientPhone)
 
 	// Fetch template to get parameter names
-	paramNames, templateText, err := getTemplateParameterInfo(settings.WhatsAppBusinessAccountID, accessToken, settings.WhatsAppTemplateConfirmation)
+	paramNames, templateText, err := helpers.GetMetaTemplateParameters(settings.WhatsAppBusinessAccountID, accessToken, settings.WhatsAppTemplateConfirmation)
 	if err != nil {
 		log.Printf("[WhatsApp] Error fetching template params: %v, using default params", err)
 		// Default to common parameter names
@@ -421,70 +327,16 @@ func SendAppointmentConfirmation(c *gin.Context) {
 	log.Printf("[WhatsApp] Template '%s' has %d parameters: %v. Body: %s",
 		settings.WhatsAppTemplateConfirmation, len(paramNames), paramNames, templateText)
 
-	// Prepare clinic name with fallback
-	clinicName := settings.ClinicName
-	if clinicName == "" {
-		clinicName = "nossa clínica"
-	}
-
-	// Map parameter names to values
-	// Supports common variations like paciente/nome, denista/dentista, etc.
-	paramValues := map[string]string{
-		"paciente":  appointment.PatientName,
-		"nome":      appointment.PatientName,
-		"data":      dateStr,
-		"hora":      timeStr,
-		"denista":   appointment.DentistName, // Common typo in template
-		"dentista":  appointment.DentistName,
-		"clinica":   clinicName,
-		"1":         appointment.PatientName,
-		"2":         dateStr,
-		"3":         timeStr,
-		"4":         appointment.DentistName,
-		"5":         clinicName,
-	}
-
-	// Build parameters with names
-	params := make([]MetaTemplateParam, len(paramNames))
-	for i, name := range paramNames {
-		value, ok := paramValues[name]
-		if !ok {
-			value = "" // Default to empty if not found
-			log.Printf("[WhatsApp] Warning: Unknown parameter name '%s'", name)
-		}
-		params[i] = MetaTemplateParam{
-			Type:          "text",
-			Text:          value,
-			ParameterName: name,
-		}
-	}
-
-	log.Printf("[WhatsApp] Sending to: %s, Template: %s, Params count: %d", phone, settings.WhatsAppTemplateConfirmation, len(params))
-	for i, p := range params {
-		log.Printf("[WhatsApp] Param[%d] %s=%s", i, p.ParameterName, p.Text)
-	}
-
-	message := MetaTemplateMessage{
-		MessagingProduct: "whatsapp",
-		RecipientType:    "individual",
-		To:               phone,
-		Type:             "template",
-		Template: &MetaTemplateComponent{
-			Name: settings.WhatsAppTemplateConfirmation,
-			Language: MetaTemplateLanguage{
-				Code: "pt_BR",
-			},
-			Components: []MetaTemplateCompParams{
-				{
-					Type:       "body",
-					Parameters: params,
-				},
-			},
-		},
-	}
+	message := helpers.BuildAppointmentTemplateMessage(phone, settings.WhatsAppTemplateConfirmation, paramNames, helpers.AppointmentTemplateValues{
+		PatientName: appointment.PatientName,
+		Date:        dateStr,
+		Time:        timeStr,
+		DentistName: appointment.DentistName,
+		ClinicName:  settings.ClinicName,
+	})
 
 	// Send message
-	result, err := sendMetaMessage(settings.WhatsAppPhoneNumberID, accessToken, message)
+	result, err := helpers.SendMetaMessage(settings.WhatsAppPhoneNumberID, accessToken, message)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -500,58 +352,6 @@ func SendAppointmentConfirmation(c *gin.Context) {
 	})
 }
 
-// getTemplateParameterInfo fetches template from Meta API and extracts parameter names
-// Returns: parameter names slice, body text (for debugging), error
-func getTemplateParameterInfo(businessAccountID, accessToken, templateName string) ([]string, string, error) {
-	url := fmt.Sprintf("%s/%s/message_templates?name=%s&fields=components",
-		MetaGraphAPIURL, businessAccountID, templateName)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, "", err
-	}
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, "", err
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("[WhatsApp] Template API error: %s", string(body))
-		return nil, "", fmt.Errorf("meta API returned %d", resp.StatusCode)
-	}
-
-	var templatesResp MetaTemplatesResponse
-	if err := json.Unmarshal(body, &templatesResp); err != nil {
-		return nil, "", err
-	}
-
-	if len(templatesResp.Data) == 0 {
-		return nil, "", fmt.Errorf("template not found")
-	}
-
-	// Extract parameter names from body component
-	// Match both numbered {{1}} and named {{name}} placeholders
-	for _, comp := range templatesResp.Data[0].Components {
-		if comp.Type == "BODY" && comp.Text != "" {
-			re := regexp.MustCompile(`\{\{([^}]+)\}\}`)
-			matches := re.FindAllStringSubmatch(comp.Text, -1)
-			paramNames := make([]string, len(matches))
-			for i, match := range matches {
-				paramNames[i] = match[1] // Extract the name inside {{...}}
-			}
-			return paramNames, comp.Text, nil
-		}
-	}
-
-	return nil, "", nil
-}
-
 // TestWhatsAppConnection tests the WhatsApp Business API connection
 func TestWhatsAppConnection(c *gin.Context) {
 	tenantID := c.MustGet("tenant_id").(uint)
@@ -583,7 +383,7 @@ func TestWhatsAppConnection(c *gin.Context) {
 	}
 
 	// Test connection by fetching phone numbers
-	url := fmt.Sprintf("%s/%s/phone_numbers", MetaGraphAPIURL, settings.WhatsAppBusinessAccountID)
+	url := fmt.Sprintf("%s/%s/phone_numbers", helpers.MetaGraphAPIURL, settings.WhatsAppBusinessAccountID)
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -604,7 +404,7 @@ func TestWhatsAppConnection(c *gin.Context) {
 	body, _ := io.ReadAll(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		var metaErr MetaErrorResponse
+		var metaErr helpers.MetaErrorResponse
 		json.Unmarshal(body, &metaErr)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success":    false,
@@ -739,91 +539,3 @@ func processWhatsAppWebhookChange(db *gorm.DB, change map[string]interface{}) {
 		}
 	}
 }
-
-// Helper functions
-
-// sendMetaMessage sends a message via Meta Graph API
-func sendMetaMessage(phoneNumberID, accessToken string, message MetaTemplateMessage) (*MetaSendMessageResponse, error) {
-	url := fmt.Sprintf("%s/%s/messages", MetaGraphAPIURL, phoneNumberID)
-
-	jsonData, err := json.Marshal(message)
-	if err != nil {
-		return nil, fmt.Errorf("erro ao serializar mensagem: %v", err)
-	}
-
-	// Log the JSON being sent for debugging
-	log.Printf("[WhatsApp] Sending to URL: %s", url)
-	log.Printf("[WhatsApp] Request JSON: %s", string(jsonData))
-
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("erro ao criar requisição: %v", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("erro ao enviar mensagem: %v", err)
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-
-	log.Printf("[WhatsApp] Response status: %d, body: %s", resp.StatusCode, string(body))
-
-	if resp.StatusCode != http.StatusOK {
-		var metaErr MetaErrorResponse
-		json.Unmarshal(body, &metaErr)
-		return nil, fmt.Errorf("erro da Meta API (%d): %s", metaErr.Error.Code, metaErr.Error.Message)
-	}
-
-	var result MetaSendMessageResponse
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("erro ao processar resposta: %v", err)
-	}
-
-	return &result, nil
-}
-
-// normalizePhoneNumber normalizes a phone number for WhatsApp
-// Supports international numbers (starting with country code) and Brazilian numbers
-func normalizePhoneNumber(phone string) string {
-	// Remove all non-digit characters
-	re := regexp.MustCompile(`\D`)
-	phone = re.ReplaceAllString(phone, "")
-
-	// If starts with 0, remove it (Brazilian local format)
-	if strings.HasPrefix(phone, "0") {
-		phone = phone[1:]
-	}
-
-	// Check if it's already an international number (starts with common country codes)
-	// US/Canada: 1, UK: 44, Portugal: 351, etc.
-	internationalPrefixes := []string{"1", "44", "351", "34", "33", "49", "39", "81", "86", "91"}
-	isInternational := false
-	for _, prefix := range internationalPrefixes {
-		// Check if number starts with country code and has reasonable length
-		if strings.HasPrefix(phone, prefix) && len(phone) >= 10 {
-			// For US numbers (1xxx), make sure it's not a local Brazilian number
-			if prefix == "1" && len(phone) == 11 {
-				// Could be Brazilian (55 + 2-digit DDD + 9-digit number = 13 digits usually)
-				// US number would be 1 + 10 digits = 11 digits
-				isInternational = true
-			} else if prefix != "1" {
-				isInternational = true
-			}
-			break
-		}
-	}
-
-	// Only add Brazil country code if it's not an international number
-	// and doesn't already have 55 prefix
-	if !isInternational && !strings.HasPrefix(phone, "55") {
-		phone = "55" + phone
-	}
-
-	return phone
-}
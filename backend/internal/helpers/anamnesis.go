@@ -0,0 +1,275 @@
+package helpers
+
+import (
+	"drcrwell/backend/internal/models"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AnamnesisAnswerInput is an answer as filled in the questionnaire
+type AnamnesisAnswerInput struct {
+	QuestionID uint     `json:"question_id"`
+	Value      string   `json:"value"`    // yes/no, chosen option, text or YYYY-MM-DD date
+	Selected   []string `json:"selected"` // Multiple choice questions
+	Details    string   `json:"details"`
+}
+
+// AnamnesisEvaluation is a validated questionnaire: the answers to store and the clinical
+// entries to add to the patient, keyed by Patient column (allergies, medications, systemic_diseases)
+type AnamnesisEvaluation struct {
+	Answers        []models.AnamnesisAnswer
+	PatientEntries map[string][]string
+}
+
+// negativeChoices are options that never trigger a clinical flag ("Nenhuma" allergy)
+var negativeChoices = map[string]bool{"nenhum": true, "nenhuma": true, "não": true, "nao": true}
+
+// NormalizeAnamnesisQuestions validates the questions of a template and sets their positions
+// and missing keys (q1, q2, ...). Conditional questions must depend on an earlier yes/no or choice question
+func NormalizeAnamnesisQuestions(questions []models.AnamnesisQuestion) error {
+	if len(questions) == 0 {
+		return fmt.Errorf("O questionário deve ter ao menos uma pergunta")
+	}
+
+	byKey := make(map[string]models.AnamnesisQuestion, len(questions))
+	for i := range questions {
+		q := &questions[i]
+		q.ID = 0
+		q.Position = i + 1
+		q.Text = strings.TrimSpace(q.Text)
+		q.Key = strings.TrimSpace(q.Key)
+		if q.Key == "" {
+			q.Key = fmt.Sprintf("q%d", i+1)
+		}
+
+		if q.Text == "" {
+			return fmt.Errorf("A pergunta %d não tem texto", i+1)
+		}
+		if !models.IsValidAnamnesisQuestionType(q.Type) {
+			return fmt.Errorf("Tipo inválido na pergunta '%s': %s", q.Text, q.Type)
+		}
+		if _, exists := byKey[q.Key]; exists {
+			return fmt.Errorf("Chave de pergunta repetida: %s", q.Key)
+		}
+
+		switch q.Type {
+		case models.AnamnesisQuestionSingleChoice, models.AnamnesisQuestionMultipleChoice:
+			seen := make(map[string]bool, len(q.Options))
+			for j, option := range q.Options {
+				option = strings.TrimSpace(option)
+				if option == "" || seen[option] {
+					return fmt.Errorf("Opções vazias ou repetidas na pergunta '%s'", q.Text)
+				}
+				seen[option] = true
+				q.Options[j] = option
+			}
+			if len(q.Options) < 2 {
+				return fmt.Errorf("A pergunta '%s' precisa de ao menos duas opções", q.Text)
+			}
+		default:
+			q.Options = models.AnamnesisOptions{}
+		}
+
+		if q.DependsOn != "" {
+			parent, ok := byKey[q.DependsOn]
+			if !ok {
+				return fmt.Errorf("A pergunta '%s' depende de uma pergunta anterior inexistente: %s", q.Text, q.DependsOn)
+			}
+			switch parent.Type {
+			case models.AnamnesisQuestionYesNo:
+				if q.DependsValue != models.AnamnesisAnswerYes && q.DependsValue != models.AnamnesisAnswerNo {
+					return fmt.Errorf("A condição da pergunta '%s' deve ser yes ou no", q.Text)
+				}
+			case models.AnamnesisQuestionSingleChoice, models.AnamnesisQuestionMultipleChoice:
+				if !parent.Options.Contains(q.DependsValue) {
+					return fmt.Errorf("A condição da pergunta '%s' deve ser uma das opções de '%s'", q.Text, parent.Text)
+				}
+			default:
+				return fmt.Errorf("A pergunta '%s' só pode depender de perguntas sim/não ou de escolha", q.Text)
+			}
+		} else {
+			q.DependsValue = ""
+		}
+
+		if q.ClinicalFlag != "" {
+			if models.ClinicalFlagPatientField(q.ClinicalFlag) == "" {
+				return fmt.Errorf("Indicador clínico inválido na pergunta '%s': %s", q.Text, q.ClinicalFlag)
+			}
+			if q.Type == models.AnamnesisQuestionDate {
+				return fmt.Errorf("Perguntas de data não podem ter indicador clínico ('%s')", q.Text)
+			}
+		}
+
+		byKey[q.Key] = *q
+	}
+	return nil
+}
+
+// anamnesisAnswerMatches reports whether an answer reveals a question that depends on it
+func anamnesisAnswerMatches(answer models.AnamnesisAnswer, value string) bool {
+	if answer.QuestionType == models.AnamnesisQuestionMultipleChoice {
+		return answer.Selected.Contains(value)
+	}
+	return answer.Value == value
+}
+
+// EvaluateAnamnesis validates the answers against the questions of a template
+// Answers to conditional questions that were not shown are discarded
+func EvaluateAnamnesis(questions []models.AnamnesisQuestion, inputs []AnamnesisAnswerInput) (AnamnesisEvaluation, error) {
+	evaluation := AnamnesisEvaluation{PatientEntries: make(map[string][]string)}
+
+	ordered := make([]models.AnamnesisQuestion, len(questions))
+	copy(ordered, questions)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Position < ordered[j].Position })
+
+	known := make(map[uint]bool, len(ordered))
+	for _, q := range ordered {
+		known[q.ID] = true
+	}
+	byQuestion := make(map[uint]AnamnesisAnswerInput, len(inputs))
+	for _, input := range inputs {
+		if !known[input.QuestionID] {
+			return evaluation, fmt.Errorf("Pergunta %d não pertence ao questionário", input.QuestionID)
+		}
+		byQuestion[input.QuestionID] = input
+	}
+
+	visible := make(map[string]bool, len(ordered))
+	answered := make(map[string]models.AnamnesisAnswer, len(ordered))
+
+	for _, q := range ordered {
+		if q.DependsOn != "" {
+			parent, ok := answered[q.DependsOn]
+			if !visible[q.DependsOn] || !ok || !anamnesisAnswerMatches(parent, q.DependsValue) {
+				continue
+			}
+		}
+		visible[q.Key] = true
+
+		input := byQuestion[q.ID]
+		answer := models.AnamnesisAnswer{
+			QuestionID:   q.ID,
+			Position:     q.Position,
+			QuestionKey:  q.Key,
+			QuestionText: q.Text,
+			QuestionType: q.Type,
+			Value:        strings.TrimSpace(input.Value),
+			Selected:     models.AnamnesisOptions{},
+			Details:      strings.TrimSpace(input.Details),
+			ClinicalFlag: q.ClinicalFlag,
+		}
+
+		switch q.Type {
+		case models.AnamnesisQuestionYesNo:
+			if answer.Value != "" && answer.Value != models.AnamnesisAnswerYes && answer.Value != models.AnamnesisAnswerNo {
+				return evaluation, fmt.Errorf("Resposta inválida para '%s': use yes ou no", q.Text)
+			}
+		case models.AnamnesisQuestionSingleChoice:
+			if answer.Value != "" && !q.Options.Contains(answer.Value) {
+				return evaluation, fmt.Errorf("Opção inválida para '%s': %s", q.Text, answer.Value)
+			}
+		case models.AnamnesisQuestionMultipleChoice:
+			answer.Value = ""
+			for _, option := range input.Selected {
+				if !q.Options.Contains(option) {
+					return evaluation, fmt.Errorf("Opção inválida para '%s': %s", q.Text, option)
+				}
+				if !answer.Selected.Contains(option) {
+					answer.Selected = append(answer.Selected, option)
+				}
+			}
+		case models.AnamnesisQuestionDate:
+			if answer.Value != "" {
+				if _, err := time.Parse("2006-01-02", answer.Value); err != nil {
+					return evaluation, fmt.Errorf("Data inválida para '%s': use YYYY-MM-DD", q.Text)
+				}
+			}
+		}
+
+		if answer.Value == "" && len(answer.Selected) == 0 {
+			if q.Required {
+				return evaluation, fmt.Errorf("Responda a pergunta: %s", q.Text)
+			}
+			continue
+		}
+		if !q.AskDetails {
+			answer.Details = ""
+		}
+
+		if entry := anamnesisClinicalEntry(q, answer); entry != "" {
+			answer.Flagged = true
+			field := models.ClinicalFlagPatientField(q.ClinicalFlag)
+			evaluation.PatientEntries[field] = append(evaluation.PatientEntries[field], entry)
+		}
+
+		answered[q.Key] = answer
+		evaluation.Answers = append(evaluation.Answers, answer)
+	}
+
+	return evaluation, nil
+}
+
+// anamnesisClinicalEntry returns the text a flagged answer adds to the patient, or "" when
+// the answer is not clinically relevant (a "no", or only negative choices such as "Nenhuma")
+func anamnesisClinicalEntry(q models.AnamnesisQuestion, answer models.AnamnesisAnswer) string {
+	if q.ClinicalFlag == "" {
+		return ""
+	}
+
+	label := strings.TrimSpace(q.ClinicalLabel)
+	if label == "" {
+		label = strings.TrimRight(q.Text, "? ")
+	}
+
+	var found []string
+	switch q.Type {
+	case models.AnamnesisQuestionYesNo:
+		if answer.Value != models.AnamnesisAnswerYes {
+			return ""
+		}
+	case models.AnamnesisQuestionSingleChoice, models.AnamnesisQuestionText:
+		if negativeChoices[strings.ToLower(answer.Value)] {
+			return ""
+		}
+		found = append(found, answer.Value)
+	case models.AnamnesisQuestionMultipleChoice:
+		for _, option := range answer.Selected {
+			if !negativeChoices[strings.ToLower(option)] {
+				found = append(found, option)
+			}
+		}
+		if len(found) == 0 {
+			return ""
+		}
+	default:
+		return ""
+	}
+
+	if answer.Details != "" {
+		found = append(found, answer.Details)
+	}
+	if len(found) == 0 {
+		return label
+	}
+	return label + ": " + strings.Join(found, ", ")
+}
+
+// MergeClinicalText appends entries to a free-text patient field ("Penicilina; Látex"),
+// skipping the ones already written there. Returns the new text and the entries added
+func MergeClinicalText(existing string, entries []string) (string, []string) {
+	text := strings.TrimSpace(existing)
+	added := []string{}
+	for _, entry := range entries {
+		if entry == "" || strings.Contains(strings.ToLower(text), strings.ToLower(entry)) {
+			continue
+		}
+		if text != "" {
+			text += "; "
+		}
+		text += entry
+		added = append(added, entry)
+	}
+	return text, added
+}
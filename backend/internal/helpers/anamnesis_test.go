@@ -0,0 +1,135 @@
+package helpers
+
+import (
+	"reflect"
+	"testing"
+
+	"drcrwell/backend/internal/models"
+)
+
+func sampleAnamnesisQuestions() []models.AnamnesisQuestion {
+	return []models.AnamnesisQuestion{
+		{ID: 1, Position: 1, Key: "anticoag", Text: "Faz uso de anticoagulante?", Type: models.AnamnesisQuestionYesNo,
+			Required: true, AskDetails: true, ClinicalFlag: models.ClinicalFlagAnticoagulant, ClinicalLabel: "Anticoagulante"},
+		{ID: 2, Position: 2, Key: "allergy", Text: "Alergias", Type: models.AnamnesisQuestionMultipleChoice,
+			Options: models.AnamnesisOptions{"Penicilina", "Látex", "Nenhuma"}, ClinicalFlag: models.ClinicalFlagAllergy, ClinicalLabel: "Alergia"},
+		{ID: 3, Position: 3, Key: "pregnant", Text: "Está grávida?", Type: models.AnamnesisQuestionYesNo,
+			ClinicalFlag: models.ClinicalFlagPregnancy, ClinicalLabel: "Gestante"},
+		{ID: 4, Position: 4, Key: "due_date", Text: "Data provável do parto", Type: models.AnamnesisQuestionDate,
+			Required: true, DependsOn: "pregnant", DependsValue: models.AnamnesisAnswerYes},
+	}
+}
+
+func TestNormalizeAnamnesisQuestions(t *testing.T) {
+	questions := sampleAnamnesisQuestions()
+	questions[0].Key = ""
+	questions[1].DependsOn = "q1"
+	questions[1].DependsValue = models.AnamnesisAnswerNo
+	if err := NormalizeAnamnesisQuestions(questions); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if questions[0].Key != "q1" || questions[0].ID != 0 || questions[3].Position != 4 {
+		t.Errorf("Expected generated key, cleared ID and positions, got %+v", questions[0])
+	}
+
+	invalid := []struct {
+		name   string
+		change func(q []models.AnamnesisQuestion)
+	}{
+		{"unknown type", func(q []models.AnamnesisQuestion) { q[0].Type = "scale" }},
+		{"duplicated key", func(q []models.AnamnesisQuestion) { q[2].Key = "allergy" }},
+		{"choice without options", func(q []models.AnamnesisQuestion) { q[1].Options = models.AnamnesisOptions{"Penicilina"} }},
+		{"depends on later question", func(q []models.AnamnesisQuestion) { q[2].DependsOn = "due_date"; q[2].DependsValue = "yes" }},
+		{"invalid condition value", func(q []models.AnamnesisQuestion) { q[3].DependsValue = "talvez" }},
+		{"unknown option in condition", func(q []models.AnamnesisQuestion) { q[2].DependsOn = "allergy"; q[2].DependsValue = "Iodo" }},
+		{"unknown clinical flag", func(q []models.AnamnesisQuestion) { q[0].ClinicalFlag = "smoker" }},
+	}
+	for _, tc := range invalid {
+		questions := sampleAnamnesisQuestions()
+		tc.change(questions)
+		if err := NormalizeAnamnesisQuestions(questions); err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+	}
+}
+
+func TestEvaluateAnamnesis(t *testing.T) {
+	questions := sampleAnamnesisQuestions()
+
+	// Conditional question hidden: its answer is discarded
+	evaluation, err := EvaluateAnamnesis(questions, []AnamnesisAnswerInput{
+		{QuestionID: 1, Value: "yes", Details: "Varfarina"},
+		{QuestionID: 2, Selected: []string{"Penicilina", "Nenhuma"}},
+		{QuestionID: 3, Value: "no"},
+		{QuestionID: 4, Value: "2026-12-01"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(evaluation.Answers) != 3 {
+		t.Fatalf("Expected 3 answers, got %d", len(evaluation.Answers))
+	}
+	if !evaluation.Answers[0].Flagged || !evaluation.Answers[1].Flagged || evaluation.Answers[2].Flagged {
+		t.Errorf("Unexpected flags: %+v", evaluation.Answers)
+	}
+	expected := map[string][]string{
+		"medications": {"Anticoagulante: Varfarina"},
+		"allergies":   {"Alergia: Penicilina"},
+	}
+	if !reflect.DeepEqual(evaluation.PatientEntries, expected) {
+		t.Errorf("Expected %v, got %v", expected, evaluation.PatientEntries)
+	}
+
+	// Conditional question shown and required
+	if _, err := EvaluateAnamnesis(questions, []AnamnesisAnswerInput{
+		{QuestionID: 1, Value: "no"},
+		{QuestionID: 3, Value: "yes"},
+	}); err == nil {
+		t.Error("Expected an error for the unanswered conditional question")
+	}
+
+	evaluation, err = EvaluateAnamnesis(questions, []AnamnesisAnswerInput{
+		{QuestionID: 1, Value: "no"},
+		{QuestionID: 2, Selected: []string{"Nenhuma"}},
+		{QuestionID: 3, Value: "yes"},
+		{QuestionID: 4, Value: "2026-12-01"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(evaluation.Answers) != 4 || evaluation.Answers[1].Flagged {
+		t.Errorf("Expected 4 answers with 'Nenhuma' not flagged, got %+v", evaluation.Answers)
+	}
+	expected = map[string][]string{"systemic_diseases": {"Gestante"}}
+	if !reflect.DeepEqual(evaluation.PatientEntries, expected) {
+		t.Errorf("Expected %v, got %v", expected, evaluation.PatientEntries)
+	}
+
+	invalid := [][]AnamnesisAnswerInput{
+		{{QuestionID: 3, Value: "no"}},                                              // Required question missing
+		{{QuestionID: 1, Value: "talvez"}},                                          // Not yes/no
+		{{QuestionID: 1, Value: "no"}, {QuestionID: 2, Selected: []string{"Iodo"}}}, // Unknown option
+		{{QuestionID: 1, Value: "no"}, {QuestionID: 3, Value: "yes"}, {QuestionID: 4, Value: "01/12/2026"}},
+		{{QuestionID: 1, Value: "no"}, {QuestionID: 9, Value: "yes"}}, // Question of another template
+	}
+	for i, inputs := range invalid {
+		if _, err := EvaluateAnamnesis(questions, inputs); err == nil {
+			t.Errorf("Case %d: expected an error", i)
+		}
+	}
+}
+
+func TestMergeClinicalText(t *testing.T) {
+	text, added := MergeClinicalText("Dipirona", []string{"Alergia: Penicilina", "dipirona", ""})
+	if text != "Dipirona; Alergia: Penicilina" {
+		t.Errorf("Unexpected text: %q", text)
+	}
+	if !reflect.DeepEqual(added, []string{"Alergia: Penicilina"}) {
+		t.Errorf("Unexpected added entries: %v", added)
+	}
+
+	text, added = MergeClinicalText(text, []string{"Alergia: Penicilina"})
+	if text != "Dipirona; Alergia: Penicilina" || len(added) != 0 {
+		t.Errorf("Expected no change, got %q %v", text, added)
+	}
+}
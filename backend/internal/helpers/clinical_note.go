@@ -0,0 +1,124 @@
+package helpers
+
+import (
+	"drcrwell/backend/internal/models"
+	"regexp"
+	"strings"
+)
+
+// Placeholders filled automatically or by the professional when a clinical note template is used
+const (
+	NotePlaceholderPatient    = "paciente"
+	NotePlaceholderTooth      = "dente"
+	NotePlaceholderAnesthetic = "anestesico"
+	NotePlaceholderDate       = "data"
+	NotePlaceholderDentist    = "dentista"
+)
+
+// ClinicalNotePlaceholder describes a placeholder for the template editor
+type ClinicalNotePlaceholder struct {
+	Key         string `json:"key"`
+	Description string `json:"description"`
+	Automatic   bool   `json:"automatic"` // Filled from the record (patient, professional, date)
+}
+
+var clinicalNotePlaceholders = []ClinicalNotePlaceholder{
+	{NotePlaceholderPatient, "Nome do paciente", true},
+	{NotePlaceholderDentist, "Nome do profissional", true},
+	{NotePlaceholderDate, "Data do atendimento (dd/mm/aaaa)", true},
+	{NotePlaceholderTooth, "Dente(s) tratado(s)", false},
+	{NotePlaceholderAnesthetic, "Anestésico utilizado", false},
+}
+
+// ClinicalNotePlaceholders returns the known placeholders. Templates may use others, which are
+// asked to the professional like {{dente}}
+func ClinicalNotePlaceholders() []ClinicalNotePlaceholder {
+	return clinicalNotePlaceholders
+}
+
+var notePlaceholderPattern = regexp.MustCompile(`\{\{\s*([^{}]+?)\s*\}\}`)
+
+// NormalizePlaceholderKey makes {{Anestésico}}, {{ anestesico }} and {{ANESTESICO}} the same placeholder
+func NormalizePlaceholderKey(key string) string {
+	return strings.ReplaceAll(strings.TrimSpace(normalizeDrugText(key)), " ", "_")
+}
+
+// ClinicalNotePlaceholderKeys returns the normalized placeholders used in the texts, in order of appearance
+func ClinicalNotePlaceholderKeys(texts ...string) []string {
+	keys := []string{}
+	seen := map[string]bool{}
+	for _, text := range texts {
+		for _, match := range notePlaceholderPattern.FindAllStringSubmatch(text, -1) {
+			key := NormalizePlaceholderKey(match[1])
+			if key != "" && !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// ExpandClinicalNote replaces the placeholders of the text with the values (keys normalized).
+// Placeholders without a value are kept as typed and returned as missing
+func ExpandClinicalNote(text string, values map[string]string) (string, []string) {
+	normalized := make(map[string]string, len(values))
+	for key, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			normalized[NormalizePlaceholderKey(key)] = value
+		}
+	}
+
+	missing := []string{}
+	seen := map[string]bool{}
+	expanded := notePlaceholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		key := NormalizePlaceholderKey(notePlaceholderPattern.FindStringSubmatch(placeholder)[1])
+		if value, ok := normalized[key]; ok {
+			return value
+		}
+		if !seen[key] {
+			seen[key] = true
+			missing = append(missing, key)
+		}
+		return placeholder
+	})
+	return expanded, missing
+}
+
+// ApplyClinicalNoteTemplate expands the template into the empty fields of the record. Fields
+// already typed by the professional are kept. Returns the placeholders without a value, in
+// order of appearance
+func ApplyClinicalNoteTemplate(record *models.MedicalRecord, template models.ClinicalNoteTemplate, values map[string]string) []string {
+	fields := []struct {
+		text   string
+		target *string
+	}{
+		{template.Diagnosis, &record.Diagnosis},
+		{template.TreatmentPlan, &record.TreatmentPlan},
+		{template.ProcedureDone, &record.ProcedureDone},
+		{template.Materials, &record.Materials},
+		{template.Evolution, &record.Evolution},
+		{template.Notes, &record.Notes},
+	}
+
+	missing := []string{}
+	seen := map[string]bool{}
+	for _, field := range fields {
+		if field.text == "" || strings.TrimSpace(*field.target) != "" {
+			continue
+		}
+		expanded, fieldMissing := ExpandClinicalNote(field.text, values)
+		*field.target = expanded
+		for _, key := range fieldMissing {
+			if !seen[key] {
+				seen[key] = true
+				missing = append(missing, key)
+			}
+		}
+	}
+
+	if record.Type == "" {
+		record.Type = template.RecordType
+	}
+	return missing
+}
@@ -0,0 +1,63 @@
+package helpers
+
+import (
+	"drcrwell/backend/internal/models"
+	"reflect"
+	"testing"
+)
+
+func TestExpandClinicalNote(t *testing.T) {
+	text := "Exodontia do dente {{dente}} sob anestesia com {{ Anestésico }}. Paciente {{PACIENTE}} liberado em {{data}}."
+	values := map[string]string{
+		"dente":      "48",
+		"anestesico": "articaína 4%",
+		"Paciente":   "Maria Silva",
+		"data":       "  ",
+	}
+
+	expanded, missing := ExpandClinicalNote(text, values)
+	want := "Exodontia do dente 48 sob anestesia com articaína 4%. Paciente Maria Silva liberado em {{data}}."
+	if expanded != want {
+		t.Errorf("Expected %q, got %q", want, expanded)
+	}
+	if !reflect.DeepEqual(missing, []string{"data"}) {
+		t.Errorf("Expected data missing, got %v", missing)
+	}
+
+	if out, missing := ExpandClinicalNote("Sem marcadores {não é placeholder}", nil); out != "Sem marcadores {não é placeholder}" || len(missing) != 0 {
+		t.Errorf("Text without placeholders changed: %q %v", out, missing)
+	}
+}
+
+func TestClinicalNotePlaceholderKeys(t *testing.T) {
+	keys := ClinicalNotePlaceholderKeys("{{dente}} {{Anestésico}}", "{{ dente }} {{face do dente}}")
+	if !reflect.DeepEqual(keys, []string{"dente", "anestesico", "face_do_dente"}) {
+		t.Errorf("Unexpected keys %v", keys)
+	}
+}
+
+func TestApplyClinicalNoteTemplate(t *testing.T) {
+	template := models.ClinicalNoteTemplate{
+		RecordType:    "procedure",
+		ProcedureDone: "Restauração em resina no dente {{dente}}",
+		Materials:     "{{anestesico}}, resina A2",
+		Evolution:     "Paciente {{paciente}} sem queixas",
+	}
+	record := models.MedicalRecord{Evolution: "Texto digitado pelo dentista"}
+
+	missing := ApplyClinicalNoteTemplate(&record, template, map[string]string{"dente": "36", "paciente": "João"})
+
+	if record.ProcedureDone != "Restauração em resina no dente 36" {
+		t.Errorf("Unexpected procedure %q", record.ProcedureDone)
+	}
+	if record.Evolution != "Texto digitado pelo dentista" {
+		t.Error("Field typed by the professional must not be overwritten")
+	}
+	if record.Type != "procedure" {
+		t.Errorf("Expected record type from template, got %q", record.Type)
+	}
+	// {{paciente}} is only in the kept evolution, so only the anesthetic is missing
+	if !reflect.DeepEqual(missing, []string{"anestesico"}) {
+		t.Errorf("Expected anestesico missing, got %v", missing)
+	}
+}
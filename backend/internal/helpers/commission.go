@@ -0,0 +1,134 @@
+package helpers
+
+import (
+	"drcrwell/backend/internal/models"
+	"strings"
+)
+
+// CommissionTarget is what a commission is calculated for: a procedure (or a payment not tied to
+// procedures) of a professional, on payment or on completion
+type CommissionTarget struct {
+	DentistID     uint
+	Procedure     string
+	ProcedureCode string
+	Category      string
+	Trigger       string
+}
+
+// commissionRuleScore returns how specific a rule is for the target, or -1 when it does not apply.
+// A professional weighs more than a procedure, which weighs more than a category
+func commissionRuleScore(rule models.CommissionRule, target CommissionTarget) int {
+	if !rule.Active || rule.Trigger != target.Trigger {
+		return -1
+	}
+
+	score := 0
+	if rule.DentistID != nil {
+		if *rule.DentistID != target.DentistID {
+			return -1
+		}
+		score += 4
+	}
+	if procedure := strings.TrimSpace(rule.Procedure); procedure != "" {
+		byCode := target.ProcedureCode != "" && strings.EqualFold(procedure, target.ProcedureCode)
+		byName := target.Procedure != "" && normalizeDrugText(procedure) == normalizeDrugText(target.Procedure)
+		if !byCode && !byName {
+			return -1
+		}
+		score += 2
+	}
+	if category := strings.TrimSpace(rule.Category); category != "" {
+		if !strings.EqualFold(category, target.Category) {
+			return -1
+		}
+		score++
+	}
+	return score
+}
+
+// MatchCommissionRule returns the most specific rule that applies to the target, or nil.
+// On a tie the first rule of the list wins
+func MatchCommissionRule(rules []models.CommissionRule, target CommissionTarget) *models.CommissionRule {
+	var best *models.CommissionRule
+	bestScore := -1
+	for i := range rules {
+		if score := commissionRuleScore(rules[i], target); score > bestScore {
+			best = &rules[i]
+			bestScore = score
+		}
+	}
+	return best
+}
+
+// CommissionShare is the part of a received amount attributed to a plan item
+type CommissionShare struct {
+	Item     *models.TreatmentPlanItem // nil when the payment is not tied to plan items
+	Gross    float64
+	LabCost  float64 // Lab cost of the item proportional to the share
+	Fraction float64 // Part of the item value covered by the share
+}
+
+// SplitCommissionShares splits an amount received across the plan items of the budget in
+// proportion to their value. Cancelled and free items are left out; without items the whole
+// amount is a single share
+func SplitCommissionShares(amount float64, items []models.TreatmentPlanItem) []CommissionShare {
+	var billable []*models.TreatmentPlanItem
+	total := 0.0
+	for i := range items {
+		if items[i].Status == models.PlanItemStatusCancelled || items[i].Total <= 0 {
+			continue
+		}
+		billable = append(billable, &items[i])
+		total += items[i].Total
+	}
+	if len(billable) == 0 {
+		return []CommissionShare{{Gross: roundMoney(amount), Fraction: 1}}
+	}
+
+	shares := make([]CommissionShare, 0, len(billable))
+	remaining := roundMoney(amount)
+	for i, item := range billable {
+		gross := roundMoney(amount * item.Total / total)
+		if i == len(billable)-1 {
+			// The last share takes the rounding difference
+			gross = remaining
+		}
+		remaining = roundMoney(remaining - gross)
+
+		fraction := gross / item.Total
+		shares = append(shares, CommissionShare{
+			Item:     item,
+			Gross:    gross,
+			LabCost:  roundMoney(item.LabCost * fraction),
+			Fraction: fraction,
+		})
+	}
+	return shares
+}
+
+// CardFee returns the card operator fee on an amount
+func CardFee(amount, feePercent float64) float64 {
+	if feePercent <= 0 {
+		return 0
+	}
+	return roundMoney(amount * feePercent / 100)
+}
+
+// CalculateCommission returns the base and the commission of a rule. Net bases deduct the card
+// fee and the lab cost (never below zero); fixed commissions are paid per procedure, in
+// proportion to the units covered
+func CalculateCommission(rule models.CommissionRule, gross, cardFee, labCost, units float64) (base, amount float64) {
+	base = gross
+	if rule.Base == models.CommissionBaseNet {
+		base = gross - cardFee - labCost
+		if base < 0 {
+			base = 0
+		}
+	}
+	base = roundMoney(base)
+
+	if rule.Type == models.CommissionTypeFixed {
+		return base, roundMoney(rule.Value * units)
+	}
+	return base, roundMoney(base * rule.Value / 100)
+}
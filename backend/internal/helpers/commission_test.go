@@ -0,0 +1,98 @@
+package helpers
+
+import (
+	"drcrwell/backend/internal/models"
+	"testing"
+)
+
+func TestMatchCommissionRule(t *testing.T) {
+	dentist := uint(7)
+	other := uint(8)
+	rules := []models.CommissionRule{
+		{ID: 1, Name: "Padrão", Trigger: models.CommissionTriggerPayment, Active: true},
+		{ID: 2, Name: "Ortodontia", Category: "orthodontics", Trigger: models.CommissionTriggerPayment, Active: true},
+		{ID: 3, Name: "Implantes", Procedure: "Implante dentário", Trigger: models.CommissionTriggerPayment, Active: true},
+		{ID: 4, Name: "Dr. 7", DentistID: &dentist, Trigger: models.CommissionTriggerPayment, Active: true},
+		{ID: 5, Name: "Dr. 8 implantes", DentistID: &other, Procedure: "81000049", Trigger: models.CommissionTriggerPayment, Active: true},
+		{ID: 6, Name: "Inativa", Procedure: "Restauração", Trigger: models.CommissionTriggerPayment, Active: false},
+		{ID: 7, Name: "Execução", Trigger: models.CommissionTriggerCompletion, Active: true},
+	}
+
+	cases := []struct {
+		name   string
+		target CommissionTarget
+		want   uint
+	}{
+		{"fallback", CommissionTarget{DentistID: 1, Procedure: "Limpeza", Trigger: models.CommissionTriggerPayment}, 1},
+		{"category", CommissionTarget{DentistID: 1, Category: "Orthodontics", Trigger: models.CommissionTriggerPayment}, 2},
+		{"procedure name ignores accents", CommissionTarget{DentistID: 1, Procedure: "implante dentario", Trigger: models.CommissionTriggerPayment}, 3},
+		{"dentist beats procedure", CommissionTarget{DentistID: 7, Procedure: "Implante dentário", Trigger: models.CommissionTriggerPayment}, 4},
+		{"procedure code", CommissionTarget{DentistID: 8, Procedure: "Implante", ProcedureCode: "81000049", Trigger: models.CommissionTriggerPayment}, 5},
+		{"inactive rules are skipped", CommissionTarget{DentistID: 1, Procedure: "Restauração", Trigger: models.CommissionTriggerPayment}, 1},
+		{"trigger", CommissionTarget{DentistID: 7, Procedure: "Restauração", Trigger: models.CommissionTriggerCompletion}, 7},
+	}
+	for _, tc := range cases {
+		rule := MatchCommissionRule(rules, tc.target)
+		if rule == nil || rule.ID != tc.want {
+			t.Errorf("%s: expected rule %d, got %+v", tc.name, tc.want, rule)
+		}
+	}
+
+	if rule := MatchCommissionRule(rules[1:3], CommissionTarget{DentistID: 1, Trigger: models.CommissionTriggerPayment}); rule != nil {
+		t.Errorf("Expected no rule, got %d", rule.ID)
+	}
+}
+
+func TestSplitCommissionShares(t *testing.T) {
+	items := []models.TreatmentPlanItem{
+		{ID: 1, Total: 200, LabCost: 100, Status: models.PlanItemStatusPlanned},
+		{ID: 2, Total: 100, Status: models.PlanItemStatusCompleted},
+		{ID: 3, Total: 500, Status: models.PlanItemStatusCancelled},
+		{ID: 4, Total: 0, Status: models.PlanItemStatusPlanned},
+	}
+
+	shares := SplitCommissionShares(100, items)
+	if len(shares) != 2 {
+		t.Fatalf("Expected 2 shares, got %d", len(shares))
+	}
+	if shares[0].Item.ID != 1 || shares[0].Gross != 66.67 || shares[0].LabCost != 33.34 {
+		t.Errorf("Unexpected first share: %+v", shares[0])
+	}
+	// The last share takes the rounding difference
+	if shares[1].Item.ID != 2 || shares[1].Gross != 33.33 {
+		t.Errorf("Unexpected second share: %+v", shares[1])
+	}
+
+	shares = SplitCommissionShares(150, nil)
+	if len(shares) != 1 || shares[0].Item != nil || shares[0].Gross != 150 || shares[0].Fraction != 1 {
+		t.Errorf("Expected a single share without item, got %+v", shares)
+	}
+}
+
+func TestCalculateCommission(t *testing.T) {
+	cases := []struct {
+		name                   string
+		rule                   models.CommissionRule
+		gross, fee, lab, units float64
+		wantBase, wantAmount   float64
+	}{
+		{"gross percentage", models.CommissionRule{Type: models.CommissionTypePercentage, Value: 30, Base: models.CommissionBaseGross}, 1000, 35, 200, 1, 1000, 300},
+		{"net percentage", models.CommissionRule{Type: models.CommissionTypePercentage, Value: 30, Base: models.CommissionBaseNet}, 1000, 35, 200, 1, 765, 229.5},
+		{"net never negative", models.CommissionRule{Type: models.CommissionTypePercentage, Value: 30, Base: models.CommissionBaseNet}, 100, 5, 200, 1, 0, 0},
+		{"fixed per unit", models.CommissionRule{Type: models.CommissionTypeFixed, Value: 80}, 500, 0, 0, 2, 500, 160},
+		{"fixed proportional", models.CommissionRule{Type: models.CommissionTypeFixed, Value: 80}, 250, 0, 0, 0.5, 250, 40},
+	}
+	for _, tc := range cases {
+		base, amount := CalculateCommission(tc.rule, tc.gross, tc.fee, tc.lab, tc.units)
+		if base != tc.wantBase || amount != tc.wantAmount {
+			t.Errorf("%s: expected base %.2f and amount %.2f, got %.2f and %.2f", tc.name, tc.wantBase, tc.wantAmount, base, amount)
+		}
+	}
+
+	if fee := CardFee(1000, 3.5); fee != 35 {
+		t.Errorf("Expected card fee 35, got %.2f", fee)
+	}
+	if fee := CardFee(1000, 0); fee != 0 {
+		t.Errorf("Expected no card fee, got %.2f", fee)
+	}
+}
@@ -0,0 +1,52 @@
+package helpers
+
+import (
+	"drcrwell/backend/internal/models"
+	"fmt"
+)
+
+// specialControlClasses are the Portaria 344 lists prescribed on the two-copy Receita de
+// Controle Especial. The other lists (A1, A3, B1, B2, C2, C3) require a Notificação de
+// Receita supplied by the health authority, which is not printed by the system
+var specialControlClasses = map[string]bool{
+	"A2": true, // Codeine and tramadol preparations of the list A2 addendum
+	"C1": true,
+	"C4": true,
+	"C5": true,
+}
+
+// MaxControlledPrescriptionItems is the maximum of substances in a controlled prescription
+const MaxControlledPrescriptionItems = 3
+
+// ValidateControlledPrescription checks the catalog items of a prescription against its type:
+// controlled substances only go on a controlled prescription, which only takes substances
+// of the Receita de Controle Especial lists, with the quantity filled in
+func ValidateControlledPrescription(prescriptionType string, items []models.PrescriptionItem) error {
+	if prescriptionType != models.PrescriptionTypeControlled {
+		for _, item := range items {
+			if item.ControlledClass != "" {
+				return fmt.Errorf("%s (lista %s) exige receita de controle especial", item.MedicationName, item.ControlledClass)
+			}
+		}
+		return nil
+	}
+
+	if len(items) == 0 {
+		return fmt.Errorf("A receita de controle especial deve ter medicamentos do catálogo")
+	}
+	if len(items) > MaxControlledPrescriptionItems {
+		return fmt.Errorf("A receita de controle especial pode ter no máximo %d medicamentos", MaxControlledPrescriptionItems)
+	}
+	for _, item := range items {
+		if item.ControlledClass == "" {
+			return fmt.Errorf("%s não é controlado e deve ser prescrito em receita comum", item.MedicationName)
+		}
+		if !specialControlClasses[item.ControlledClass] {
+			return fmt.Errorf("%s (lista %s) exige Notificação de Receita e não pode ser prescrito em receita de controle especial", item.MedicationName, item.ControlledClass)
+		}
+		if item.Quantity == "" {
+			return fmt.Errorf("Informe a quantidade de %s", item.MedicationName)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package helpers
+
+import (
+	"testing"
+
+	"drcrwell/backend/internal/models"
+)
+
+func TestValidateControlledPrescription(t *testing.T) {
+	codeine := models.PrescriptionItem{MedicationName: "Paracetamol + Codeína", ControlledClass: "A2", Quantity: "12 comprimidos"}
+	carbamazepine := models.PrescriptionItem{MedicationName: "Carbamazepina", ControlledClass: "C1", Quantity: "30 comprimidos"}
+	amoxicillin := models.PrescriptionItem{MedicationName: "Amoxicilina", Quantity: "21 cápsulas"}
+	diazepam := models.PrescriptionItem{MedicationName: "Diazepam", ControlledClass: "B1", Quantity: "10 comprimidos"}
+
+	tests := []struct {
+		name    string
+		typ     string
+		items   []models.PrescriptionItem
+		wantErr bool
+	}{
+		{"common prescription", "prescription", []models.PrescriptionItem{amoxicillin}, false},
+		{"free-text prescription", "prescription", nil, false},
+		{"controlled item on common prescription", "prescription", []models.PrescriptionItem{amoxicillin, carbamazepine}, true},
+		{"controlled prescription", "controlled", []models.PrescriptionItem{codeine, carbamazepine}, false},
+		{"controlled without items", "controlled", nil, true},
+		{"non-controlled item on controlled prescription", "controlled", []models.PrescriptionItem{codeine, amoxicillin}, true},
+		{"list requiring notification", "controlled", []models.PrescriptionItem{diazepam}, true},
+		{"missing quantity", "controlled", []models.PrescriptionItem{{MedicationName: "Carbamazepina", ControlledClass: "C1"}}, true},
+		{"too many items", "controlled", []models.PrescriptionItem{codeine, carbamazepine, codeine, carbamazepine}, true},
+	}
+
+	for _, tt := range tests {
+		err := ValidateControlledPrescription(tt.typ, tt.items)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
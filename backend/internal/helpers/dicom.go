@@ -0,0 +1,539 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DICOM transfer syntaxes
+const (
+	DICOMImplicitVRLittleEndian = "1.2.840.10008.1.2"
+	DICOMExplicitVRLittleEndian = "1.2.840.10008.1.2.1"
+	DICOMExplicitVRBigEndian    = "1.2.840.10008.1.2.2"
+	DICOMDeflatedExplicitVR     = "1.2.840.10008.1.2.1.99"
+)
+
+// DICOMPreviewMaxSize is the largest side, in pixels, of the PNG preview of a DICOM image
+const DICOMPreviewMaxSize = 1024
+
+// ErrDICOMNoPreview is returned when the pixel data of a DICOM file cannot be rendered here
+// (compressed transfer syntaxes, multi-frame images, unsupported photometric interpretations)
+var ErrDICOMNoPreview = errors.New("preview not available for this DICOM file")
+
+// DICOM tags read from the header
+const (
+	dicomTagTransferSyntax      = 0x00020010
+	dicomTagSOPInstanceUID      = 0x00080018
+	dicomTagStudyDate           = 0x00080020
+	dicomTagModality            = 0x00080060
+	dicomTagStudyDescription    = 0x00081030
+	dicomTagSeriesDescription   = 0x0008103E
+	dicomTagPatientName         = 0x00100010
+	dicomTagPatientID           = 0x00100020
+	dicomTagPatientBirthDate    = 0x00100030
+	dicomTagBodyPartExamined    = 0x00180015
+	dicomTagStudyInstanceUID    = 0x0020000D
+	dicomTagSeriesInstanceUID   = 0x0020000E
+	dicomTagSeriesNumber        = 0x00200011
+	dicomTagInstanceNumber      = 0x00200013
+	dicomTagSamplesPerPixel     = 0x00280002
+	dicomTagPhotometric         = 0x00280004
+	dicomTagPlanarConfiguration = 0x00280006
+	dicomTagNumberOfFrames      = 0x00280008
+	dicomTagRows                = 0x00280010
+	dicomTagColumns             = 0x00280011
+	dicomTagBitsAllocated       = 0x00280100
+	dicomTagBitsStored          = 0x00280101
+	dicomTagPixelRepresentation = 0x00280103
+	dicomTagWindowCenter        = 0x00281050
+	dicomTagWindowWidth         = 0x00281051
+	dicomTagRescaleIntercept    = 0x00281052
+	dicomTagRescaleSlope        = 0x00281053
+	dicomTagPixelData           = 0x7FE00010
+
+	dicomTagItem              = 0xFFFEE000
+	dicomTagItemDelimitation  = 0xFFFEE00D
+	dicomTagSequenceDelimiter = 0xFFFEE0DD
+
+	dicomUndefinedLength = 0xFFFFFFFF
+)
+
+// dicomUIDPattern matches a DICOM UID: numeric components separated by dots, up to 64 characters
+var dicomUIDPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)*$`)
+
+// dicomLongLengthVRs are the explicit VRs encoded with 2 reserved bytes and a 32-bit length
+var dicomLongLengthVRs = map[string]bool{
+	"OB": true, "OD": true, "OF": true, "OL": true, "OV": true, "OW": true,
+	"SQ": true, "SV": true, "UC": true, "UN": true, "UR": true, "UT": true, "UV": true,
+}
+
+// DICOMInfo is the header metadata of a DICOM file and the pixel data used for the preview
+type DICOMInfo struct {
+	TransferSyntax    string
+	SOPInstanceUID    string
+	StudyInstanceUID  string
+	SeriesInstanceUID string
+	SeriesNumber      int
+	InstanceNumber    int
+
+	Modality          string // e.g. DX, IO, PX, CT
+	StudyDate         *time.Time
+	StudyDescription  string
+	SeriesDescription string
+	BodyPart          string
+	PatientName       string // Family^Given as in the header
+	PatientID         string
+	PatientBirthDate  *time.Time
+
+	Rows                int
+	Columns             int
+	NumberOfFrames      int
+	SamplesPerPixel     int
+	Photometric         string
+	PlanarConfiguration int
+	BitsAllocated       int
+	BitsStored          int
+	PixelRepresentation int
+	WindowCenter        float64
+	WindowWidth         float64
+	RescaleIntercept    float64
+	RescaleSlope        float64
+
+	pixelData    []byte
+	encapsulated bool
+}
+
+// IsDICOM reports whether data starts with the DICOM Part 10 preamble ("DICM" at offset 128)
+func IsDICOM(data []byte) bool {
+	return len(data) >= 132 && bytes.Equal(data[128:132], []byte("DICM"))
+}
+
+// PatientDisplayName converts the DICOM person name (Family^Given^Middle) to "Given Middle Family"
+func (info *DICOMInfo) PatientDisplayName() string {
+	parts := strings.Split(info.PatientName, "^")
+	names := []string{}
+	for i := 1; i < len(parts) && i < 3; i++ {
+		names = append(names, parts[i])
+	}
+	names = append(names, parts[0])
+	return strings.Join(strings.Fields(strings.Join(names, " ")), " ")
+}
+
+// dicomReader walks the data elements of a DICOM file
+type dicomReader struct {
+	data     []byte
+	pos      int
+	explicit bool
+}
+
+func (r *dicomReader) remaining() int {
+	return len(r.data) - r.pos
+}
+
+func (r *dicomReader) uint16() uint16 {
+	v := binary.LittleEndian.Uint16(r.data[r.pos:])
+	r.pos += 2
+	return v
+}
+
+func (r *dicomReader) uint32() uint32 {
+	v := binary.LittleEndian.Uint32(r.data[r.pos:])
+	r.pos += 4
+	return v
+}
+
+// peekGroup returns the group of the next element without consuming it
+func (r *dicomReader) peekGroup() uint16 {
+	if r.remaining() < 2 {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(r.data[r.pos:])
+}
+
+// readHeader reads the tag, VR and value length of the next element.
+// Item and delimitation tags never carry a VR
+func (r *dicomReader) readHeader() (tag uint32, vr string, length uint32, err error) {
+	if r.remaining() < 8 {
+		return 0, "", 0, errors.New("truncated DICOM element")
+	}
+	tag = uint32(r.uint16())<<16 | uint32(r.uint16())
+	if tag>>16 == 0xFFFE || !r.explicit {
+		return tag, "", r.uint32(), nil
+	}
+	vr = string(r.data[r.pos : r.pos+2])
+	r.pos += 2
+	if dicomLongLengthVRs[vr] {
+		if r.remaining() < 6 {
+			return 0, "", 0, errors.New("truncated DICOM element")
+		}
+		r.pos += 2
+		return tag, vr, r.uint32(), nil
+	}
+	return tag, vr, uint32(r.uint16()), nil
+}
+
+// skipUndefinedLength skips a sequence (or encapsulated pixel data) of undefined length
+// up to and including its sequence delimitation item
+func (r *dicomReader) skipUndefinedLength() error {
+	for {
+		if r.remaining() < 8 {
+			return errors.New("unterminated DICOM sequence")
+		}
+		tag := uint32(r.uint16())<<16 | uint32(r.uint16())
+		length := r.uint32()
+		switch tag {
+		case dicomTagSequenceDelimiter:
+			return nil
+		case dicomTagItem:
+			if length != dicomUndefinedLength {
+				if err := r.skip(length); err != nil {
+					return err
+				}
+				continue
+			}
+			// Item of undefined length: its elements run until the item delimitation
+			for {
+				if r.remaining() < 8 {
+					return errors.New("unterminated DICOM item")
+				}
+				next := uint32(binary.LittleEndian.Uint16(r.data[r.pos:]))<<16 | uint32(binary.LittleEndian.Uint16(r.data[r.pos+2:]))
+				if next == dicomTagItemDelimitation {
+					r.pos += 8
+					break
+				}
+				if _, _, _, err := r.next(); err != nil {
+					return err
+				}
+			}
+		default:
+			return fmt.Errorf("unexpected DICOM tag %08X inside sequence", tag)
+		}
+	}
+}
+
+func (r *dicomReader) skip(length uint32) error {
+	if uint64(length) > uint64(r.remaining()) {
+		return errors.New("DICOM element length exceeds file size")
+	}
+	r.pos += int(length)
+	return nil
+}
+
+// next reads the next element and returns its value. Elements of undefined length are
+// skipped and flagged as undefined (sequences and encapsulated pixel data)
+func (r *dicomReader) next() (tag uint32, value []byte, undefined bool, err error) {
+	tag, _, length, err := r.readHeader()
+	if err != nil {
+		return 0, nil, false, err
+	}
+	if length == dicomUndefinedLength {
+		return tag, nil, true, r.skipUndefinedLength()
+	}
+	start := r.pos
+	if err := r.skip(length); err != nil {
+		return 0, nil, false, err
+	}
+	return tag, r.data[start:r.pos], false, nil
+}
+
+// dicomString trims the padding of a string value and keeps its first value when multi-valued
+func dicomString(value []byte) string {
+	s := strings.Trim(string(value), " \x00")
+	if i := strings.Index(s, `\`); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
+
+func dicomNumber(value []byte) float64 {
+	n, _ := strconv.ParseFloat(dicomString(value), 64)
+	return n
+}
+
+func dicomUS(value []byte) int {
+	if len(value) < 2 {
+		return 0
+	}
+	return int(binary.LittleEndian.Uint16(value))
+}
+
+func dicomDate(value []byte) *time.Time {
+	date, err := time.Parse("20060102", dicomString(value))
+	if err != nil {
+		return nil
+	}
+	return &date
+}
+
+// ParseDICOM reads the header of a DICOM Part 10 file (little endian transfer syntaxes).
+// The pixel data is kept for RenderDICOMPreview
+func ParseDICOM(data []byte) (*DICOMInfo, error) {
+	if !IsDICOM(data) {
+		return nil, errors.New("not a DICOM file")
+	}
+
+	info := &DICOMInfo{SamplesPerPixel: 1, NumberOfFrames: 1, RescaleSlope: 1}
+	r := &dicomReader{data: data, pos: 132, explicit: true}
+
+	// File meta information (group 0002) is always explicit VR little endian
+	for r.remaining() > 0 && r.peekGroup() == 0x0002 {
+		tag, value, _, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if tag == dicomTagTransferSyntax {
+			info.TransferSyntax = dicomString(value)
+		}
+	}
+
+	switch info.TransferSyntax {
+	case DICOMImplicitVRLittleEndian:
+		r.explicit = false
+	case DICOMExplicitVRBigEndian, DICOMDeflatedExplicitVR:
+		return nil, fmt.Errorf("unsupported DICOM transfer syntax: %s", info.TransferSyntax)
+	}
+
+	for r.remaining() > 0 {
+		tag, value, undefined, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+
+		switch tag {
+		case dicomTagSOPInstanceUID:
+			info.SOPInstanceUID = dicomString(value)
+		case dicomTagStudyDate:
+			info.StudyDate = dicomDate(value)
+		case dicomTagModality:
+			info.Modality = strings.ToUpper(dicomString(value))
+		case dicomTagStudyDescription:
+			info.StudyDescription = dicomString(value)
+		case dicomTagSeriesDescription:
+			info.SeriesDescription = dicomString(value)
+		case dicomTagPatientName:
+			info.PatientName = dicomString(value)
+		case dicomTagPatientID:
+			info.PatientID = dicomString(value)
+		case dicomTagPatientBirthDate:
+			info.PatientBirthDate = dicomDate(value)
+		case dicomTagBodyPartExamined:
+			info.BodyPart = dicomString(value)
+		case dicomTagStudyInstanceUID:
+			info.StudyInstanceUID = dicomString(value)
+		case dicomTagSeriesInstanceUID:
+			info.SeriesInstanceUID = dicomString(value)
+		case dicomTagSeriesNumber:
+			info.SeriesNumber = int(dicomNumber(value))
+		case dicomTagInstanceNumber:
+			info.InstanceNumber = int(dicomNumber(value))
+		case dicomTagSamplesPerPixel:
+			info.SamplesPerPixel = dicomUS(value)
+		case dicomTagPhotometric:
+			info.Photometric = strings.ToUpper(dicomString(value))
+		case dicomTagPlanarConfiguration:
+			info.PlanarConfiguration = dicomUS(value)
+		case dicomTagNumberOfFrames:
+			if frames := int(dicomNumber(value)); frames > 0 {
+				info.NumberOfFrames = frames
+			}
+		case dicomTagRows:
+			info.Rows = dicomUS(value)
+		case dicomTagColumns:
+			info.Columns = dicomUS(value)
+		case dicomTagBitsAllocated:
+			info.BitsAllocated = dicomUS(value)
+		case dicomTagBitsStored:
+			info.BitsStored = dicomUS(value)
+		case dicomTagPixelRepresentation:
+			info.PixelRepresentation = dicomUS(value)
+		case dicomTagWindowCenter:
+			info.WindowCenter = dicomNumber(value)
+		case dicomTagWindowWidth:
+			info.WindowWidth = dicomNumber(value)
+		case dicomTagRescaleIntercept:
+			info.RescaleIntercept = dicomNumber(value)
+		case dicomTagRescaleSlope:
+			if slope := dicomNumber(value); slope != 0 {
+				info.RescaleSlope = slope
+			}
+		case dicomTagPixelData:
+			info.pixelData = value
+			info.encapsulated = undefined
+		}
+
+		// Nothing of interest after the pixel data (only padding)
+		if tag == dicomTagPixelData {
+			break
+		}
+	}
+
+	if info.StudyInstanceUID == "" || info.SOPInstanceUID == "" {
+		return nil, errors.New("DICOM file without study or instance UID")
+	}
+	// UIDs name the stored files, so only well-formed ones are accepted
+	for _, uid := range []string{info.StudyInstanceUID, info.SeriesInstanceUID, info.SOPInstanceUID} {
+		if uid != "" && (len(uid) > 64 || !dicomUIDPattern.MatchString(uid)) {
+			return nil, fmt.Errorf("invalid DICOM UID: %s", uid)
+		}
+	}
+	return info, nil
+}
+
+// RenderDICOMPreview renders a single-frame, uncompressed DICOM image as a grayscale (or RGB)
+// image no larger than DICOMPreviewMaxSize, applying the rescale and VOI window of the header
+func RenderDICOMPreview(info *DICOMInfo) (image.Image, error) {
+	if info.encapsulated || info.pixelData == nil || info.NumberOfFrames > 1 || info.Rows == 0 || info.Columns == 0 {
+		return nil, ErrDICOMNoPreview
+	}
+
+	step := int(math.Ceil(float64(max(info.Rows, info.Columns)) / DICOMPreviewMaxSize))
+	width, height := (info.Columns+step-1)/step, (info.Rows+step-1)/step
+	pixels := info.Rows * info.Columns
+
+	switch {
+	case info.SamplesPerPixel == 3 && info.BitsAllocated == 8 && info.Photometric == "RGB":
+		if len(info.pixelData) < pixels*3 {
+			return nil, ErrDICOMNoPreview
+		}
+		img := image.NewRGBA(image.Rect(0, 0, width, height))
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				i := y*step*info.Columns + x*step
+				var rgb [3]uint8
+				for s := 0; s < 3; s++ {
+					if info.PlanarConfiguration == 1 {
+						rgb[s] = info.pixelData[s*pixels+i]
+					} else {
+						rgb[s] = info.pixelData[i*3+s]
+					}
+				}
+				img.SetRGBA(x, y, color.RGBA{rgb[0], rgb[1], rgb[2], 255})
+			}
+		}
+		return img, nil
+
+	case info.SamplesPerPixel == 1 && (info.BitsAllocated == 8 || info.BitsAllocated == 16) &&
+		(info.Photometric == "MONOCHROME1" || info.Photometric == "MONOCHROME2"):
+		bytesPerPixel := info.BitsAllocated / 8
+		if len(info.pixelData) < pixels*bytesPerPixel {
+			return nil, ErrDICOMNoPreview
+		}
+
+		// Modality values (rescaled) of the sampled pixels
+		values := make([]float64, width*height)
+		low, high := math.Inf(1), math.Inf(-1)
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				v := dicomPixelValue(info, y*step*info.Columns+x*step)
+				values[y*width+x] = v
+				low, high = math.Min(low, v), math.Max(high, v)
+			}
+		}
+
+		// VOI window from the header, or the full range of the image
+		center, windowWidth := info.WindowCenter, info.WindowWidth
+		if windowWidth <= 1 {
+			center, windowWidth = (low+high)/2, math.Max(high-low, 1)
+		}
+
+		img := image.NewGray(image.Rect(0, 0, width, height))
+		for i, v := range values {
+			level := ((v-(center-0.5))/(windowWidth-1) + 0.5) * 255
+			level = math.Max(0, math.Min(255, level))
+			if info.Photometric == "MONOCHROME1" {
+				level = 255 - level
+			}
+			img.Pix[i] = uint8(math.Round(level))
+		}
+		return img, nil
+	}
+
+	return nil, ErrDICOMNoPreview
+}
+
+// dicomPixelValue returns the rescaled value of the pixel at index i of a monochrome image
+func dicomPixelValue(info *DICOMInfo, i int) float64 {
+	var raw float64
+	if info.BitsAllocated == 8 {
+		raw = float64(info.pixelData[i])
+	} else {
+		v := binary.LittleEndian.Uint16(info.pixelData[i*2:])
+		if bits := info.BitsStored; bits > 0 && bits < 16 {
+			v &= 1<<bits - 1
+			if info.PixelRepresentation == 1 && v&(1<<(bits-1)) != 0 {
+				raw = float64(int(v) - 1<<bits)
+			} else {
+				raw = float64(v)
+			}
+		} else if info.PixelRepresentation == 1 {
+			raw = float64(int16(v))
+		} else {
+			raw = float64(v)
+		}
+	}
+	return raw*info.RescaleSlope + info.RescaleIntercept
+}
+
+// EncodeDICOMPreviewPNG renders the preview of a DICOM image as PNG
+func EncodeDICOMPreviewPNG(info *DICOMInfo) ([]byte, error) {
+	img, err := RenderDICOMPreview(info)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DICOMPatientMatches reports whether the patient in the DICOM header is the patient the
+// exam is being attached to. Anonymized files (no name nor ID) are accepted; a patient ID
+// equal to the CPF or to the patient record ID is a match; otherwise every part of the DICOM
+// name must appear in the patient name (accents and case ignored)
+func DICOMPatientMatches(info *DICOMInfo, patientID uint, patientName, cpf string) bool {
+	if info.PatientName == "" && info.PatientID == "" {
+		return true
+	}
+
+	if id := onlyDigits(info.PatientID); id != "" {
+		if cpfDigits := onlyDigits(cpf); cpfDigits != "" && id == cpfDigits {
+			return true
+		}
+		if id == strconv.FormatUint(uint64(patientID), 10) && id == info.PatientID {
+			return true
+		}
+	}
+
+	// Same normalization as the prescription checks: lowercase, no accents, whole words
+	dicomNames := strings.Fields(normalizeDrugText(strings.ReplaceAll(info.PatientName, "^", " ")))
+	if len(dicomNames) == 0 {
+		return false
+	}
+	patientNames := normalizeDrugText(patientName)
+	for _, name := range dicomNames {
+		if !strings.Contains(patientNames, " "+name+" ") {
+			return false
+		}
+	}
+	return true
+}
+
+func onlyDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
@@ -0,0 +1,213 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"testing"
+)
+
+// dicomTestFile builds a DICOM Part 10 file with the given transfer syntax and dataset elements
+type dicomTestFile struct {
+	buf      bytes.Buffer
+	explicit bool
+}
+
+func newDICOMTestFile(transferSyntax string) *dicomTestFile {
+	f := &dicomTestFile{explicit: true}
+	f.buf.Write(make([]byte, 128))
+	f.buf.WriteString("DICM")
+	f.element(0x0002, 0x0010, "UI", []byte(transferSyntax+"\x00"))
+	f.explicit = transferSyntax != DICOMImplicitVRLittleEndian
+	return f
+}
+
+func (f *dicomTestFile) tag(group, element uint16) {
+	binary.Write(&f.buf, binary.LittleEndian, group)
+	binary.Write(&f.buf, binary.LittleEndian, element)
+}
+
+func (f *dicomTestFile) element(group, element uint16, vr string, value []byte) {
+	if len(value)%2 == 1 {
+		value = append(value, ' ')
+	}
+	f.tag(group, element)
+	if !f.explicit {
+		binary.Write(&f.buf, binary.LittleEndian, uint32(len(value)))
+	} else if dicomLongLengthVRs[vr] {
+		f.buf.WriteString(vr)
+		f.buf.Write([]byte{0, 0})
+		binary.Write(&f.buf, binary.LittleEndian, uint32(len(value)))
+	} else {
+		f.buf.WriteString(vr)
+		binary.Write(&f.buf, binary.LittleEndian, uint16(len(value)))
+	}
+	f.buf.Write(value)
+}
+
+func (f *dicomTestFile) str(group, element uint16, vr, value string) {
+	f.element(group, element, vr, []byte(value))
+}
+
+func (f *dicomTestFile) us(group, element uint16, value uint16) {
+	v := make([]byte, 2)
+	binary.LittleEndian.PutUint16(v, value)
+	f.element(group, element, "US", v)
+}
+
+// undefinedSequence writes a sequence of undefined length with one item of undefined length
+func (f *dicomTestFile) undefinedSequence(group, element uint16) {
+	f.tag(group, element)
+	if f.explicit {
+		f.buf.WriteString("SQ")
+		f.buf.Write([]byte{0, 0})
+	}
+	binary.Write(&f.buf, binary.LittleEndian, uint32(dicomUndefinedLength))
+	f.tag(0xFFFE, 0xE000)
+	binary.Write(&f.buf, binary.LittleEndian, uint32(dicomUndefinedLength))
+	f.str(0x0008, 0x0100, "SH", "T-11170") // Code value inside the item
+	f.tag(0xFFFE, 0xE00D)
+	binary.Write(&f.buf, binary.LittleEndian, uint32(0))
+	f.tag(0xFFFE, 0xE0DD)
+	binary.Write(&f.buf, binary.LittleEndian, uint32(0))
+}
+
+func (f *dicomTestFile) header(modality string) {
+	f.str(0x0008, 0x0018, "UI", "1.2.3.4.5.6")
+	f.str(0x0008, 0x0020, "DA", "20240315")
+	f.str(0x0008, 0x0060, "CS", modality)
+	f.str(0x0010, 0x0010, "PN", "SILVA^JOAO^PEREIRA")
+	f.str(0x0010, 0x0020, "LO", "123.456.789-09")
+	f.str(0x0018, 0x0015, "CS", "JAW")
+	f.undefinedSequence(0x0040, 0x0260)
+	f.str(0x0020, 0x000D, "UI", "1.2.3.4")
+	f.str(0x0020, 0x000E, "UI", "1.2.3.4.5")
+	f.str(0x0020, 0x0013, "IS", "7")
+}
+
+// monochrome16 writes a 2x2 MONOCHROME2 16-bit image
+func (f *dicomTestFile) monochrome16(pixels [4]uint16) {
+	f.us(0x0028, 0x0002, 1)
+	f.str(0x0028, 0x0004, "CS", "MONOCHROME2")
+	f.us(0x0028, 0x0010, 2)
+	f.us(0x0028, 0x0011, 2)
+	f.us(0x0028, 0x0100, 16)
+	f.us(0x0028, 0x0101, 12)
+	f.us(0x0028, 0x0103, 0)
+	data := make([]byte, 8)
+	for i, p := range pixels {
+		binary.LittleEndian.PutUint16(data[i*2:], p)
+	}
+	f.element(0x7FE0, 0x0010, "OW", data)
+}
+
+func TestParseDICOM(t *testing.T) {
+	for _, syntax := range []string{DICOMExplicitVRLittleEndian, DICOMImplicitVRLittleEndian} {
+		f := newDICOMTestFile(syntax)
+		f.header("IO")
+		f.monochrome16([4]uint16{0, 1000, 2000, 4095})
+
+		data := f.buf.Bytes()
+		if !IsDICOM(data) || detectFileType(data[:132]) != FileTypeDICOM {
+			t.Fatalf("%s: file not detected as DICOM", syntax)
+		}
+
+		info, err := ParseDICOM(data)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", syntax, err)
+		}
+		if info.StudyInstanceUID != "1.2.3.4" || info.SeriesInstanceUID != "1.2.3.4.5" || info.SOPInstanceUID != "1.2.3.4.5.6" {
+			t.Errorf("%s: wrong UIDs: %+v", syntax, info)
+		}
+		if info.Modality != "IO" || info.BodyPart != "JAW" || info.InstanceNumber != 7 {
+			t.Errorf("%s: wrong metadata: %+v", syntax, info)
+		}
+		if info.StudyDate == nil || info.StudyDate.Format("2006-01-02") != "2024-03-15" {
+			t.Errorf("%s: wrong study date: %v", syntax, info.StudyDate)
+		}
+		if info.PatientDisplayName() != "JOAO PEREIRA SILVA" {
+			t.Errorf("%s: wrong patient name: %s", syntax, info.PatientDisplayName())
+		}
+		if info.Rows != 2 || info.Columns != 2 || info.BitsStored != 12 {
+			t.Errorf("%s: wrong image attributes: %+v", syntax, info)
+		}
+	}
+
+	if _, err := ParseDICOM([]byte("%PDF-1.4")); err == nil {
+		t.Error("Expected error for non-DICOM data")
+	}
+
+	f := newDICOMTestFile(DICOMExplicitVRBigEndian)
+	f.header("DX")
+	if _, err := ParseDICOM(f.buf.Bytes()); err == nil {
+		t.Error("Expected error for big endian transfer syntax")
+	}
+
+	f = newDICOMTestFile(DICOMExplicitVRLittleEndian)
+	f.str(0x0008, 0x0018, "UI", "1.2.3")
+	f.str(0x0020, 0x000D, "UI", "../../etc")
+	if _, err := ParseDICOM(f.buf.Bytes()); err == nil {
+		t.Error("Expected error for malformed UID")
+	}
+}
+
+func TestRenderDICOMPreview(t *testing.T) {
+	f := newDICOMTestFile(DICOMExplicitVRLittleEndian)
+	f.header("IO")
+	f.monochrome16([4]uint16{0, 1000, 2000, 4095})
+	info, err := ParseDICOM(f.buf.Bytes())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	img, err := RenderDICOMPreview(info)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	gray, ok := img.(*image.Gray)
+	if !ok || gray.Bounds().Dx() != 2 || gray.Bounds().Dy() != 2 {
+		t.Fatalf("Expected 2x2 grayscale preview, got %T %v", img, img.Bounds())
+	}
+	// Without a VOI window the full range is used: darkest pixel black, brightest white
+	if gray.Pix[0] != 0 || gray.Pix[3] != 255 || gray.Pix[1] >= gray.Pix[2] {
+		t.Errorf("Unexpected preview levels: %v", gray.Pix)
+	}
+
+	if png, err := EncodeDICOMPreviewPNG(info); err != nil || !bytes.HasPrefix(png, magicPNG) {
+		t.Errorf("Expected PNG preview, got error %v", err)
+	}
+
+	info.NumberOfFrames = 2
+	if _, err := RenderDICOMPreview(info); err != ErrDICOMNoPreview {
+		t.Errorf("Multi-frame images should have no preview, got %v", err)
+	}
+}
+
+func TestDICOMPatientMatches(t *testing.T) {
+	f := newDICOMTestFile(DICOMExplicitVRLittleEndian)
+	f.header("PX")
+	info, err := ParseDICOM(f.buf.Bytes())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !DICOMPatientMatches(info, 10, "Outro Paciente", "12345678909") {
+		t.Error("Patient ID equal to the CPF should match")
+	}
+	if !DICOMPatientMatches(info, 10, "João Pereira da Silva", "") {
+		t.Error("Name with accents and extra parts should match")
+	}
+	if DICOMPatientMatches(info, 10, "Maria Souza", "98765432100") {
+		t.Error("Different patient should not match")
+	}
+
+	info.PatientID = "10"
+	if !DICOMPatientMatches(info, 10, "Maria Souza", "") {
+		t.Error("Patient ID equal to the record ID should match")
+	}
+
+	anonymized := &DICOMInfo{}
+	if !DICOMPatientMatches(anonymized, 10, "Maria Souza", "") {
+		t.Error("Anonymized files should be accepted")
+	}
+}
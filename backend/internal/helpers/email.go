@@ -3,6 +3,7 @@ package helpers
 import (
 	"crypto/rand"
 	"crypto/tls"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -359,8 +360,20 @@ type TenantEmailConfig struct {
 	UseTLS    bool
 }
 
+// EmailAttachment is a file attached to a tenant email
+type EmailAttachment struct {
+	Filename    string
+	ContentType string // e.g. "text/calendar; method=PUBLISH"
+	Data        []byte
+}
+
 // SendTenantEmail sends an email using tenant-specific SMTP configuration
 func SendTenantEmail(config TenantEmailConfig, to, subject, body string) error {
+	return SendTenantEmailWithAttachments(config, to, subject, body)
+}
+
+// SendTenantEmailWithAttachments sends a tenant email with optional file attachments
+func SendTenantEmailWithAttachments(config TenantEmailConfig, to, subject, body string, attachments ...EmailAttachment) error {
 	if config.Host == "" {
 		return fmt.Errorf("SMTP host não configurado")
 	}
@@ -388,6 +401,9 @@ func SendTenantEmail(config TenantEmailConfig, to, subject, body string) error {
 
 	// Build the message
 	msg := buildTenantMessage(from, to, subject, body)
+	if len(attachments) > 0 {
+		msg = buildTenantMessageWithAttachments(from, to, subject, body, attachments)
+	}
 
 	// Connect to SMTP server
 	addr := fmt.Sprintf("%s:%d", config.Host, port)
@@ -514,6 +530,52 @@ func buildTenantMessage(from, to, subject, body string) string {
 	return sb.String()
 }
 
+// buildTenantMessageWithAttachments builds a multipart/mixed message with the HTML body and attachments
+func buildTenantMessageWithAttachments(from, to, subject, body string, attachments []EmailAttachment) string {
+	boundaryBytes := make([]byte, 12)
+	rand.Read(boundaryBytes)
+	boundary := "odowell-" + hex.EncodeToString(boundaryBytes)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("From: %s\r\n", from))
+	sb.WriteString(fmt.Sprintf("To: %s\r\n", to))
+	sb.WriteString(fmt.Sprintf("Subject: %s\r\n", subject))
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString(fmt.Sprintf("Content-Type: multipart/mixed; boundary=\"%s\"\r\n", boundary))
+	sb.WriteString("\r\n")
+
+	sb.WriteString(fmt.Sprintf("--%s\r\n", boundary))
+	sb.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+	sb.WriteString("\r\n")
+	sb.WriteString(body)
+	sb.WriteString("\r\n")
+
+	for _, attachment := range attachments {
+		contentType := attachment.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		sb.WriteString(fmt.Sprintf("--%s\r\n", boundary))
+		sb.WriteString(fmt.Sprintf("Content-Type: %s; charset=UTF-8; name=\"%s\"\r\n", contentType, attachment.Filename))
+		sb.WriteString(fmt.Sprintf("Content-Disposition: attachment; filename=\"%s\"\r\n", attachment.Filename))
+		sb.WriteString("Content-Transfer-Encoding: base64\r\n")
+		sb.WriteString("\r\n")
+
+		// Base64 lines are limited to 76 characters (RFC 2045)
+		encoded := base64.StdEncoding.EncodeToString(attachment.Data)
+		for len(encoded) > 76 {
+			sb.WriteString(encoded[:76])
+			sb.WriteString("\r\n")
+			encoded = encoded[76:]
+		}
+		sb.WriteString(encoded)
+		sb.WriteString("\r\n")
+	}
+
+	sb.WriteString(fmt.Sprintf("--%s--\r\n", boundary))
+	return sb.String()
+}
+
 // BuildCampaignEmailBody builds a standard HTML email body for campaigns
 func BuildCampaignEmailBody(clinicName, patientName, message string) string {
 	return fmt.Sprintf(`
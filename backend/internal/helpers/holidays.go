@@ -0,0 +1,64 @@
+package helpers
+
+import "time"
+
+// Holiday is a national holiday on a given date
+type Holiday struct {
+	Date time.Time
+	Name string
+}
+
+// fixedNationalHolidays are the Brazilian national holidays with a fixed date (Lei 662/1949 and later laws)
+var fixedNationalHolidays = []struct {
+	Month time.Month
+	Day   int
+	Name  string
+}{
+	{time.January, 1, "Confraternização Universal"},
+	{time.April, 21, "Tiradentes"},
+	{time.May, 1, "Dia do Trabalho"},
+	{time.September, 7, "Independência do Brasil"},
+	{time.October, 12, "Nossa Senhora Aparecida"},
+	{time.November, 2, "Finados"},
+	{time.November, 15, "Proclamação da República"},
+	{time.November, 20, "Dia Nacional de Zumbi e da Consciência Negra"},
+	{time.December, 25, "Natal"},
+}
+
+// BrazilianNationalHolidays returns the national holidays of a year, sorted by date
+// Movable holidays are derived from Easter; Carnaval and Corpus Christi are optional
+// (ponto facultativo) and are not included
+func BrazilianNationalHolidays(year int, loc *time.Location) []Holiday {
+	holidays := make([]Holiday, 0, len(fixedNationalHolidays)+1)
+	goodFriday := EasterSunday(year, loc).AddDate(0, 0, -2)
+
+	added := false
+	for _, h := range fixedNationalHolidays {
+		date := time.Date(year, h.Month, h.Day, 0, 0, 0, 0, loc)
+		if !added && goodFriday.Before(date) {
+			holidays = append(holidays, Holiday{Date: goodFriday, Name: "Sexta-feira Santa"})
+			added = true
+		}
+		holidays = append(holidays, Holiday{Date: date, Name: h.Name})
+	}
+	return holidays
+}
+
+// EasterSunday returns the date of Easter Sunday (anonymous Gregorian algorithm)
+func EasterSunday(year int, loc *time.Location) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, loc)
+}
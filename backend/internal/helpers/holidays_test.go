@@ -0,0 +1,37 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEasterSunday(t *testing.T) {
+	cases := map[int]string{
+		2024: "2024-03-31",
+		2025: "2025-04-20",
+		2026: "2026-04-05",
+		2027: "2027-03-28",
+	}
+	for year, expected := range cases {
+		if got := EasterSunday(year, time.UTC).Format("2006-01-02"); got != expected {
+			t.Errorf("EasterSunday(%d): expected %s, got %s", year, expected, got)
+		}
+	}
+}
+
+func TestBrazilianNationalHolidays(t *testing.T) {
+	holidays := BrazilianNationalHolidays(2026, time.UTC)
+	if len(holidays) != 10 {
+		t.Fatalf("Expected 10 national holidays, got %d", len(holidays))
+	}
+
+	// Good Friday (April 3) comes before Tiradentes and the list stays sorted
+	if holidays[1].Name != "Sexta-feira Santa" || holidays[1].Date.Format("2006-01-02") != "2026-04-03" {
+		t.Errorf("Expected Sexta-feira Santa on 2026-04-03, got %s on %s", holidays[1].Name, holidays[1].Date.Format("2006-01-02"))
+	}
+	for i := 1; i < len(holidays); i++ {
+		if !holidays[i].Date.After(holidays[i-1].Date) {
+			t.Errorf("Holidays not sorted: %s before %s", holidays[i-1].Name, holidays[i].Name)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package helpers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// icsTimeFormat is the iCalendar UTC date-time format (RFC 5545 3.3.5)
+const icsTimeFormat = "20060102T150405Z"
+
+// icsMaxLineOctets is the maximum content line length before folding (RFC 5545 3.1)
+const icsMaxLineOctets = 75
+
+// ICS event statuses
+const (
+	ICSStatusConfirmed = "CONFIRMED"
+	ICSStatusCancelled = "CANCELLED"
+)
+
+// ICSEvent is a single VEVENT of an iCalendar document
+type ICSEvent struct {
+	UID          string
+	Start        time.Time
+	End          time.Time
+	Summary      string
+	Description  string
+	Location     string
+	Status       string // CONFIRMED or CANCELLED
+	LastModified time.Time
+}
+
+// ICSCalendar is an iCalendar document (a feed or an email attachment)
+type ICSCalendar struct {
+	Name   string // X-WR-CALNAME, shown by subscribing apps
+	Events []ICSEvent
+}
+
+// ICSAppointmentUID returns a stable event UID for an appointment
+// Feeds and email attachments share it so calendar apps update the same event
+func ICSAppointmentUID(tenantID, appointmentID uint) string {
+	return fmt.Sprintf("appointment-%d-%d@odowell.pro", tenantID, appointmentID)
+}
+
+// ICSStatusForAppointment maps an appointment status to an ICS event status
+func ICSStatusForAppointment(status string) string {
+	if status == "cancelled" {
+		return ICSStatusCancelled
+	}
+	return ICSStatusConfirmed
+}
+
+// BuildICS renders the calendar as an RFC 5545 document with CRLF line endings
+func BuildICS(cal ICSCalendar) string {
+	var sb strings.Builder
+	writeLine := func(line string) {
+		sb.WriteString(foldICSLine(line))
+		sb.WriteString("\r\n")
+	}
+
+	stamp := time.Now().UTC().Format(icsTimeFormat)
+
+	writeLine("BEGIN:VCALENDAR")
+	writeLine("VERSION:2.0")
+	writeLine("PRODID:-//Odowell//Agenda//PT-BR")
+	writeLine("CALSCALE:GREGORIAN")
+	writeLine("METHOD:PUBLISH")
+	if cal.Name != "" {
+		writeLine("X-WR-CALNAME:" + EscapeICSText(cal.Name))
+	}
+
+	for _, event := range cal.Events {
+		writeLine("BEGIN:VEVENT")
+		writeLine("UID:" + event.UID)
+		writeLine("DTSTAMP:" + stamp)
+		writeLine("DTSTART:" + event.Start.UTC().Format(icsTimeFormat))
+		writeLine("DTEND:" + event.End.UTC().Format(icsTimeFormat))
+		writeLine("SUMMARY:" + EscapeICSText(event.Summary))
+		if event.Description != "" {
+			writeLine("DESCRIPTION:" + EscapeICSText(event.Description))
+		}
+		if event.Location != "" {
+			writeLine("LOCATION:" + EscapeICSText(event.Location))
+		}
+		if event.Status != "" {
+			writeLine("STATUS:" + event.Status)
+		}
+		if !event.LastModified.IsZero() {
+			writeLine("LAST-MODIFIED:" + event.LastModified.UTC().Format(icsTimeFormat))
+		}
+		writeLine("END:VEVENT")
+	}
+
+	writeLine("END:VCALENDAR")
+	return sb.String()
+}
+
+// EscapeICSText escapes a TEXT property value (RFC 5545 3.3.11)
+func EscapeICSText(value string) string {
+	value = strings.ReplaceAll(value, "\r\n", "\n")
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\n", `\n`,
+		"\r", "",
+	)
+	return replacer.Replace(value)
+}
+
+// foldICSLine splits lines longer than 75 octets, continuing with a leading space
+// Folding never splits a multi-byte UTF-8 character
+func foldICSLine(line string) string {
+	if len(line) <= icsMaxLineOctets {
+		return line
+	}
+
+	var sb strings.Builder
+	limit := icsMaxLineOctets
+	count := 0
+	for _, r := range line {
+		size := utf8.RuneLen(r)
+		if count+size > limit {
+			sb.WriteString("\r\n ")
+			count = 0
+			limit = icsMaxLineOctets - 1 // The leading space counts towards the limit
+		}
+		sb.WriteRune(r)
+		count += size
+	}
+	return sb.String()
+}
+
+// NameInitials returns the initials of a person's name ("Maria da Silva" -> "M. S.")
+// Lowercase particles (da, de, do, dos, das, e) are skipped
+func NameInitials(name string) string {
+	particles := map[string]bool{"da": true, "de": true, "do": true, "das": true, "dos": true, "e": true}
+
+	var initials []string
+	for _, part := range strings.Fields(name) {
+		if particles[strings.ToLower(part)] {
+			continue
+		}
+		r, _ := utf8.DecodeRuneInString(part)
+		initials = append(initials, strings.ToUpper(string(r))+".")
+	}
+	return strings.Join(initials, " ")
+}
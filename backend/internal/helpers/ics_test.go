@@ -0,0 +1,74 @@
+package helpers
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildICS(t *testing.T) {
+	loc := time.FixedZone("BRT", -3*60*60)
+	cal := ICSCalendar{
+		Name: "Agenda - Dr. Silva",
+		Events: []ICSEvent{{
+			UID:     ICSAppointmentUID(3, 42),
+			Start:   time.Date(2026, 3, 5, 9, 0, 0, 0, loc),
+			End:     time.Date(2026, 3, 5, 9, 30, 0, 0, loc),
+			Summary: "M. S. - Limpeza, profilaxia",
+			Status:  ICSStatusCancelled,
+		}},
+	}
+
+	ics := BuildICS(cal)
+	for _, expected := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"UID:appointment-3-42@odowell.pro\r\n",
+		"DTSTART:20260305T120000Z\r\n",
+		"DTEND:20260305T123000Z\r\n",
+		`SUMMARY:M. S. - Limpeza\, profilaxia` + "\r\n",
+		"STATUS:CANCELLED\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(ics, expected) {
+			t.Errorf("Expected ICS to contain %q, got:\n%s", expected, ics)
+		}
+	}
+}
+
+func TestEscapeICSText(t *testing.T) {
+	got := EscapeICSText("Linha 1\nLinha; 2, com \\ barra")
+	expected := `Linha 1\nLinha\; 2\, com \\ barra`
+	if got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestFoldICSLine(t *testing.T) {
+	line := "DESCRIPTION:" + strings.Repeat("ção ", 40)
+	folded := foldICSLine(line)
+
+	for _, part := range strings.Split(folded, "\r\n") {
+		if len(part) > icsMaxLineOctets {
+			t.Errorf("Folded line exceeds %d octets: %d", icsMaxLineOctets, len(part))
+		}
+	}
+
+	// Unfolding must restore the original line
+	if unfolded := strings.ReplaceAll(folded, "\r\n ", ""); unfolded != line {
+		t.Errorf("Unfolded line differs from original")
+	}
+}
+
+func TestNameInitials(t *testing.T) {
+	cases := map[string]string{
+		"Maria da Silva":  "M. S.",
+		"joão DOS santos": "J. S.",
+		"Ícaro":           "Í.",
+		"":                "",
+	}
+	for name, expected := range cases {
+		if got := NameInitials(name); got != expected {
+			t.Errorf("NameInitials(%q): expected %q, got %q", name, expected, got)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif" // Registered for image.Decode
+	"image/jpeg"
+	_ "image/png" // Registered for image.Decode
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // Registered for image.Decode
+)
+
+// Sizes (largest side, in pixels) of the renditions generated for exam files
+const (
+	ExamThumbnailSize = 256
+	ExamPreviewSize   = 1280
+
+	previewJPEGQuality = 82
+	maxPreviewPixels   = 100_000_000 // Larger images are not decoded (decompression bombs)
+)
+
+// ErrImageTooLarge is returned for images with more pixels than maxPreviewPixels
+var ErrImageTooLarge = errors.New("image too large to generate a preview")
+
+// IsHEIC reports whether the header is an ISO BMFF file with a HEIF/HEIC brand (iPhone photos)
+func IsHEIC(header []byte) bool {
+	if len(header) < 12 || !bytes.Equal(header[4:8], []byte("ftyp")) {
+		return false
+	}
+	switch string(header[8:12]) {
+	case "heic", "heix", "hevc", "hevx", "heim", "heis", "mif1", "msf1":
+		return true
+	}
+	return false
+}
+
+// jpegExif returns the TIFF block of the Exif segment (APP1) of a JPEG and its offset in data
+func jpegExif(data []byte) ([]byte, int) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, 0
+	}
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return nil, 0
+		}
+		marker := data[pos+1]
+		if marker == 0xDA || marker == 0xD9 { // Start of scan / end of image
+			return nil, 0
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, 0
+		}
+		segment := data[pos+4 : end]
+		if marker == 0xE1 && len(segment) > 14 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:], pos + 10
+		}
+		pos = end
+	}
+	return nil, 0
+}
+
+// tiffBlock reads the IFDs of an Exif TIFF block
+type tiffBlock struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+func newTIFFBlock(data []byte) (*tiffBlock, bool) {
+	if len(data) < 8 {
+		return nil, false
+	}
+	switch string(data[:2]) {
+	case "II":
+		return &tiffBlock{data: data, order: binary.LittleEndian}, true
+	case "MM":
+		return &tiffBlock{data: data, order: binary.BigEndian}, true
+	}
+	return nil, false
+}
+
+// ifdEntries returns the offsets of the 12-byte entries of the IFD at offset
+func (t *tiffBlock) ifdEntries(offset uint32) []int {
+	if int(offset)+2 > len(t.data) {
+		return nil
+	}
+	count := int(t.order.Uint16(t.data[offset:]))
+	entries := make([]int, 0, count)
+	for i := 0; i < count; i++ {
+		entry := int(offset) + 2 + i*12
+		if entry+12 > len(t.data) {
+			break
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// findTag returns the offset of the entry with the tag in the IFD, or -1
+func (t *tiffBlock) findTag(ifd uint32, tag uint16) int {
+	for _, entry := range t.ifdEntries(ifd) {
+		if t.order.Uint16(t.data[entry:]) == tag {
+			return entry
+		}
+	}
+	return -1
+}
+
+// Exif tags
+const (
+	exifTagOrientation = 0x0112
+	exifTagGPSInfo     = 0x8825
+)
+
+// exifTypeSizes is the size in bytes of each TIFF field type
+var exifTypeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+// ExifOrientation returns the Exif orientation (1-8) of a JPEG photo, 1 when absent
+func ExifOrientation(data []byte) int {
+	exif, _ := jpegExif(data)
+	t, ok := newTIFFBlock(exif)
+	if !ok {
+		return 1
+	}
+	entry := t.findTag(t.order.Uint32(t.data[4:]), exifTagOrientation)
+	if entry < 0 {
+		return 1
+	}
+	orientation := int(t.order.Uint16(t.data[entry+8:]))
+	if orientation < 1 || orientation > 8 {
+		return 1
+	}
+	return orientation
+}
+
+// StripJPEGGPS removes the GPS location from the Exif data of a JPEG photo. The GPS entries and
+// their values are zeroed in place, so the file keeps its size and its other metadata (orientation).
+// Returns a copy of the data and true when there was a location to remove
+func StripJPEGGPS(data []byte) ([]byte, bool) {
+	exif, exifStart := jpegExif(data)
+	t, ok := newTIFFBlock(exif)
+	if !ok {
+		return data, false
+	}
+	pointer := t.findTag(t.order.Uint32(t.data[4:]), exifTagGPSInfo)
+	if pointer < 0 {
+		return data, false
+	}
+	gpsIFD := t.order.Uint32(t.data[pointer+8:])
+	entries := t.ifdEntries(gpsIFD)
+	if len(entries) == 0 {
+		return data, false
+	}
+
+	out := make([]byte, len(data))
+	copy(out, data)
+	tiff := out[exifStart : exifStart+len(exif)]
+	zero := func(from, to int) {
+		if from >= 0 && to <= len(tiff) && from < to {
+			for i := from; i < to; i++ {
+				tiff[i] = 0
+			}
+		}
+	}
+
+	for _, entry := range entries {
+		size := exifTypeSizes[t.order.Uint16(t.data[entry+2:])] * int(t.order.Uint32(t.data[entry+4:]))
+		if size > 4 {
+			// Value stored out of the entry (e.g. latitude/longitude rationals)
+			offset := int(t.order.Uint32(t.data[entry+8:]))
+			zero(offset, offset+size)
+		}
+		zero(entry, entry+12)
+	}
+	// Empty IFD: no entries and no next IFD
+	zero(int(gpsIFD), int(gpsIFD)+2)
+	last := entries[len(entries)-1] + 12
+	zero(last, last+4)
+
+	return out, true
+}
+
+// DecodeExamImage decodes a JPEG, PNG, GIF or WebP image
+func DecodeExamImage(data []byte) (image.Image, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width*config.Height > maxPreviewPixels {
+		return nil, ErrImageTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// ResizeToFit scales the image down to fit in a maxSize x maxSize box (never up)
+func ResizeToFit(img image.Image, maxSize int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxSize && height <= maxSize {
+		return img
+	}
+	if width >= height {
+		height = max(1, height*maxSize/width)
+		width = maxSize
+	} else {
+		width = max(1, width*maxSize/height)
+		height = maxSize
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+	return dst
+}
+
+// ApplyExifOrientation rotates/flips the image so it is displayed upright
+func ApplyExifOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	outW, outH := w, h
+	if orientation >= 5 {
+		outW, outH = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, outW, outH))
+	for y := 0; y < outH; y++ {
+		for x := 0; x < outW; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // Mirrored horizontally
+				sx, sy = w-1-x, y
+			case 3: // Rotated 180
+				sx, sy = w-1-x, h-1-y
+			case 4: // Mirrored vertically
+				sx, sy = x, h-1-y
+			case 5: // Transposed
+				sx, sy = y, x
+			case 6: // Rotated 90 clockwise
+				sx, sy = y, h-1-x
+			case 7: // Transversed
+				sx, sy = w-1-y, h-1-x
+			case 8: // Rotated 90 counter-clockwise
+				sx, sy = w-1-y, x
+			}
+			dst.Set(x, y, img.At(bounds.Min.X+sx, bounds.Min.Y+sy))
+		}
+	}
+	return dst
+}
+
+// EncodeRenditionJPEG resizes the image to fit maxSize, corrects its orientation and encodes it
+// as JPEG over a white background (transparent PNG/GIF). The output carries no metadata
+func EncodeRenditionJPEG(img image.Image, orientation, maxSize int) ([]byte, error) {
+	img = ApplyExifOrientation(ResizeToFit(img, maxSize), orientation)
+
+	flat := image.NewRGBA(img.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: previewJPEGQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ImageRenditions are the thumbnail and web-sized preview of an image
+type ImageRenditions struct {
+	Thumbnail []byte
+	Preview   []byte
+}
+
+// BuildImageRenditions decodes an image and generates its thumbnail and preview as JPEG,
+// upright according to the Exif orientation of JPEG photos
+func BuildImageRenditions(data []byte) (*ImageRenditions, error) {
+	img, err := DecodeExamImage(data)
+	if err != nil {
+		return nil, err
+	}
+	return BuildRenditions(img, ExifOrientation(data))
+}
+
+// BuildRenditions generates the thumbnail and preview of a decoded image
+func BuildRenditions(img image.Image, orientation int) (*ImageRenditions, error) {
+	// The preview is scaled first and the thumbnail comes from it, which is much cheaper
+	// than scaling the full-size photo twice
+	preview := ResizeToFit(img, ExamPreviewSize)
+	previewJPEG, err := EncodeRenditionJPEG(preview, orientation, ExamPreviewSize)
+	if err != nil {
+		return nil, err
+	}
+	thumbnailJPEG, err := EncodeRenditionJPEG(preview, orientation, ExamThumbnailSize)
+	if err != nil {
+		return nil, err
+	}
+	return &ImageRenditions{Thumbnail: thumbnailJPEG, Preview: previewJPEG}, nil
+}
@@ -0,0 +1,160 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// jpegWithExif encodes a w x h JPEG with an Exif segment holding the orientation and a GPS latitude
+func jpegWithExif(t *testing.T, w, h int, orientation uint16) []byte {
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, image.NewGray(image.Rect(0, 0, w, h)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	le := binary.LittleEndian
+	tiff := make([]byte, 80)
+	copy(tiff, "II")
+	le.PutUint16(tiff[2:], 42)
+	le.PutUint32(tiff[4:], 8)
+	// IFD0 at 8: orientation and GPS pointer
+	le.PutUint16(tiff[8:], 2)
+	le.PutUint16(tiff[10:], exifTagOrientation)
+	le.PutUint16(tiff[12:], 3)
+	le.PutUint32(tiff[14:], 1)
+	le.PutUint16(tiff[18:], orientation)
+	le.PutUint16(tiff[22:], exifTagGPSInfo)
+	le.PutUint16(tiff[24:], 4)
+	le.PutUint32(tiff[26:], 1)
+	le.PutUint32(tiff[30:], 38)
+	// GPS IFD at 38: latitude (3 rationals stored at 56)
+	le.PutUint16(tiff[38:], 1)
+	le.PutUint16(tiff[40:], 0x0002)
+	le.PutUint16(tiff[42:], 5)
+	le.PutUint32(tiff[44:], 3)
+	le.PutUint32(tiff[48:], 56)
+	for i := 56; i < 80; i++ {
+		tiff[i] = 0x17
+	}
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(segment)+2))
+
+	data := append([]byte{}, encoded.Bytes()[:2]...)
+	data = append(data, app1...)
+	data = append(data, segment...)
+	return append(data, encoded.Bytes()[2:]...)
+}
+
+func TestExifOrientationAndGPS(t *testing.T) {
+	data := jpegWithExif(t, 40, 20, 6)
+	if o := ExifOrientation(data); o != 6 {
+		t.Fatalf("Expected orientation 6, got %d", o)
+	}
+
+	stripped, changed := StripJPEGGPS(data)
+	if !changed || len(stripped) != len(data) {
+		t.Fatalf("Expected GPS stripped in place, changed=%v", changed)
+	}
+	if bytes.Contains(stripped, bytes.Repeat([]byte{0x17}, 24)) {
+		t.Error("GPS coordinates still present")
+	}
+	if ExifOrientation(stripped) != 6 {
+		t.Error("Orientation must be kept when stripping GPS")
+	}
+	if _, changed := StripJPEGGPS(stripped); changed {
+		t.Error("Second strip should find no GPS data")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(stripped)); err != nil {
+		t.Errorf("Stripped JPEG no longer decodes: %v", err)
+	}
+
+	// Rotated 90 degrees: the landscape photo becomes portrait
+	renditions, err := BuildImageRenditions(stripped)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	preview, err := jpeg.Decode(bytes.NewReader(renditions.Preview))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.Bounds().Dx() != 20 || preview.Bounds().Dy() != 40 {
+		t.Errorf("Expected 20x40 upright preview, got %v", preview.Bounds())
+	}
+}
+
+func TestApplyExifOrientation(t *testing.T) {
+	// 2x1 image: red at the left, blue at the right
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	src.Set(0, 0, red)
+	src.Set(1, 0, blue)
+
+	cases := []struct {
+		orientation int
+		width       int
+		first       color.RGBA // Top-left pixel
+	}{
+		{1, 2, red},
+		{2, 2, blue},
+		{3, 2, blue},
+		{6, 1, red},  // Rotated clockwise: left column becomes the top
+		{8, 1, blue}, // Rotated counter-clockwise: right column becomes the top
+	}
+	for _, tc := range cases {
+		out := ApplyExifOrientation(src, tc.orientation)
+		if out.Bounds().Dx() != tc.width {
+			t.Errorf("Orientation %d: expected width %d, got %d", tc.orientation, tc.width, out.Bounds().Dx())
+		}
+		if got := color.RGBAModel.Convert(out.At(0, 0)).(color.RGBA); got != tc.first {
+			t.Errorf("Orientation %d: unexpected top-left pixel %v", tc.orientation, got)
+		}
+	}
+}
+
+func TestBuildImageRenditionsSizes(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 3000, 1500))); err != nil {
+		t.Fatal(err)
+	}
+	renditions, err := BuildImageRenditions(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for name, tc := range map[string]struct {
+		data []byte
+		w, h int
+	}{
+		"thumbnail": {renditions.Thumbnail, ExamThumbnailSize, ExamThumbnailSize / 2},
+		"preview":   {renditions.Preview, ExamPreviewSize, ExamPreviewSize / 2},
+	} {
+		img, err := jpeg.Decode(bytes.NewReader(tc.data))
+		if err != nil {
+			t.Fatalf("%s: not a JPEG: %v", name, err)
+		}
+		if img.Bounds().Dx() != tc.w || img.Bounds().Dy() != tc.h {
+			t.Errorf("%s: expected %dx%d, got %v", name, tc.w, tc.h, img.Bounds())
+		}
+	}
+
+	if _, err := BuildImageRenditions([]byte("not an image")); err == nil {
+		t.Error("Expected error for invalid image")
+	}
+}
+
+func TestDetectHEIC(t *testing.T) {
+	header := append([]byte{0, 0, 0, 24}, []byte("ftypheic")...)
+	if !IsHEIC(header) || DetectFileType(header) != FileTypeImage {
+		t.Error("HEIC photo not detected as image")
+	}
+	if IsHEIC(append([]byte{0, 0, 0, 24}, []byte("ftypisom")...)) {
+		t.Error("MP4 detected as HEIC")
+	}
+}
@@ -0,0 +1,48 @@
+package helpers
+
+import (
+	"math"
+	"time"
+)
+
+// InstallmentDue is an installment of a treatment with its due date, as printed in the carnê
+type InstallmentDue struct {
+	Number  int       `json:"number"`
+	DueDate time.Time `json:"due_date"`
+	Amount  float64   `json:"amount"`
+}
+
+// InstallmentSchedule splits a total in monthly installments starting at start. Without an
+// installment value the total is divided evenly; the last installment takes the remaining
+// balance so the schedule always adds up to the total. Due dates past the end of a shorter
+// month fall on its last day
+func InstallmentSchedule(total, installmentValue float64, installments int, start time.Time) []InstallmentDue {
+	if installments < 1 {
+		installments = 1
+	}
+	if installmentValue <= 0 || installmentValue*float64(installments-1) > total {
+		installmentValue = math.Floor(total/float64(installments)*100) / 100
+	}
+
+	schedule := make([]InstallmentDue, installments)
+	remaining := total
+	for i := range schedule {
+		amount := installmentValue
+		if i == installments-1 {
+			amount = math.Round(remaining*100) / 100
+		}
+		remaining -= amount
+		schedule[i] = InstallmentDue{Number: i + 1, DueDate: addMonthsClamped(start, i), Amount: amount}
+	}
+	return schedule
+}
+
+// addMonthsClamped adds months to t keeping the day, or the last day of a shorter month
+func addMonthsClamped(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month(), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location()).AddDate(0, months, 0)
+	day := t.Day()
+	if lastDay := first.AddDate(0, 1, -1).Day(); day > lastDay {
+		day = lastDay
+	}
+	return first.AddDate(0, 0, day-1)
+}
@@ -0,0 +1,47 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInstallmentScheduleEvenSplit(t *testing.T) {
+	start := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	schedule := InstallmentSchedule(100, 0, 3, start)
+	if len(schedule) != 3 {
+		t.Fatalf("Expected 3 installments, got %d", len(schedule))
+	}
+
+	wantAmounts := []float64{33.33, 33.33, 33.34}
+	wantDates := []string{"2024-01-31", "2024-02-29", "2024-03-31"}
+	for i, due := range schedule {
+		if due.Number != i+1 || due.Amount != wantAmounts[i] || due.DueDate.Format("2006-01-02") != wantDates[i] {
+			t.Errorf("Installment %d: got %+v, expected %.2f due %s", i+1, due, wantAmounts[i], wantDates[i])
+		}
+	}
+}
+
+func TestInstallmentScheduleLastTakesBalance(t *testing.T) {
+	start := time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)
+	schedule := InstallmentSchedule(1000, 300, 4, start)
+	total := 0.0
+	for _, due := range schedule {
+		total += due.Amount
+	}
+	if schedule[0].Amount != 300 || schedule[3].Amount != 100 || total != 1000 {
+		t.Errorf("Unexpected schedule %+v", schedule)
+	}
+
+	// An installment value that does not fit the total is ignored
+	schedule = InstallmentSchedule(500, 300, 3, start)
+	if schedule[0].Amount != 166.66 || schedule[2].Amount != 166.68 {
+		t.Errorf("Expected an even split, got %+v", schedule)
+	}
+}
+
+func TestInstallmentScheduleSingle(t *testing.T) {
+	schedule := InstallmentSchedule(250, 0, 0, time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC))
+	if len(schedule) != 1 || schedule[0].Amount != 250 {
+		t.Errorf("Unexpected schedule %+v", schedule)
+	}
+}
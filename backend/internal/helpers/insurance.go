@@ -0,0 +1,100 @@
+package helpers
+
+import (
+	"drcrwell/backend/internal/models"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// IsValidTUSSCode reports whether a code has the format of the TUSS table (8 digits)
+func IsValidTUSSCode(code string) bool {
+	return tissTUSSPattern.MatchString(code)
+}
+
+// NormalizeInsuranceCompany validates an insurer and keeps only the digits of its registry and
+// CNPJ. Returns an error (in Portuguese) for the first invalid field
+func NormalizeInsuranceCompany(company *models.InsuranceCompany) error {
+	company.Name = strings.TrimSpace(company.Name)
+	company.ANSRegistry = onlyDigits(company.ANSRegistry)
+	company.CNPJ = onlyDigits(company.CNPJ)
+	company.ProviderCode = strings.TrimSpace(company.ProviderCode)
+
+	switch {
+	case company.Name == "":
+		return errors.New("Nome do convênio é obrigatório")
+	case !tissANSPattern.MatchString(company.ANSRegistry):
+		return errors.New("Registro ANS deve ter 6 dígitos")
+	case company.CNPJ != "" && !tissCNPJPattern.MatchString(company.CNPJ):
+		return errors.New("CNPJ deve ter 14 dígitos")
+	case len(company.ProviderCode) > 14:
+		return errors.New("Código do prestador na operadora deve ter até 14 caracteres")
+	case company.PaymentTermDays < 0:
+		return errors.New("Prazo de pagamento não pode ser negativo")
+	}
+	return nil
+}
+
+// InsuranceItemReturn is what the insurer paid for a guide item in its payment return
+type InsuranceItemReturn struct {
+	ItemID             uint    `json:"item_id" binding:"required"`
+	PaidValue          float64 `json:"paid_value"`
+	DisallowanceCode   string  `json:"disallowance_code"`
+	DisallowanceReason string  `json:"disallowance_reason"`
+}
+
+// ApplyInsuranceGuideReturn records the payment return of a guide: the value paid for each item,
+// the disallowance (glosa) of the rest, and the guide totals and status. Items missing from the
+// return are considered fully disallowed. Returns an error (in Portuguese) for invalid values
+func ApplyInsuranceGuideReturn(guide *models.InsuranceGuide, returns []InsuranceItemReturn) error {
+	byItem := make(map[uint]InsuranceItemReturn, len(returns))
+	for _, r := range returns {
+		byItem[r.ItemID] = r
+	}
+
+	paid, disallowed := 0.0, 0.0
+	for i := range guide.Items {
+		item := &guide.Items[i]
+		r, ok := byItem[item.ID]
+		if !ok {
+			r = InsuranceItemReturn{ItemID: item.ID}
+		}
+		delete(byItem, item.ID)
+
+		r.PaidValue = roundMoney(r.PaidValue)
+		if r.PaidValue < 0 || r.PaidValue > roundMoney(item.Total) {
+			return fmt.Errorf("Valor pago do item %s deve estar entre 0 e %.2f", item.ProcedureCode, item.Total)
+		}
+		item.PaidValue = r.PaidValue
+		item.DisallowedValue = roundMoney(item.Total - r.PaidValue)
+		item.DisallowanceCode = ""
+		item.DisallowanceReason = ""
+		if item.DisallowedValue > 0 {
+			item.DisallowanceCode = strings.TrimSpace(r.DisallowanceCode)
+			item.DisallowanceReason = strings.TrimSpace(r.DisallowanceReason)
+			if item.DisallowanceCode == "" {
+				return fmt.Errorf("Informe o código de glosa do item %s", item.ProcedureCode)
+			}
+			if len(item.DisallowanceCode) > 10 {
+				return fmt.Errorf("Código de glosa do item %s deve ter até 10 caracteres", item.ProcedureCode)
+			}
+		}
+		paid += item.PaidValue
+		disallowed += item.DisallowedValue
+	}
+	for itemID := range byItem {
+		return fmt.Errorf("Item %d não pertence à guia %s", itemID, guide.GuideNumber)
+	}
+
+	guide.PaidValue = roundMoney(paid)
+	guide.DisallowedValue = roundMoney(disallowed)
+	switch {
+	case guide.PaidValue == 0:
+		guide.Status = models.InsuranceGuideStatusDenied
+	case guide.DisallowedValue > 0:
+		guide.Status = models.InsuranceGuideStatusPartiallyPaid
+	default:
+		guide.Status = models.InsuranceGuideStatusPaid
+	}
+	return nil
+}
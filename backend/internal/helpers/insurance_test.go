@@ -0,0 +1,96 @@
+package helpers
+
+import (
+	"drcrwell/backend/internal/models"
+	"testing"
+)
+
+func TestNormalizeInsuranceCompany(t *testing.T) {
+	company := models.InsuranceCompany{Name: " Odonto Saúde ", ANSRegistry: "12.345-6", CNPJ: "12.345.678/0001-90"}
+	if err := NormalizeInsuranceCompany(&company); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if company.Name != "Odonto Saúde" || company.ANSRegistry != "123456" || company.CNPJ != "12345678000190" {
+		t.Errorf("Unexpected normalization: %+v", company)
+	}
+
+	invalid := []models.InsuranceCompany{
+		{ANSRegistry: "123456"},
+		{Name: "Odonto", ANSRegistry: "12345"},
+		{Name: "Odonto", ANSRegistry: "123456", CNPJ: "123"},
+		{Name: "Odonto", ANSRegistry: "123456", PaymentTermDays: -1},
+	}
+	for _, company := range invalid {
+		if err := NormalizeInsuranceCompany(&company); err == nil {
+			t.Errorf("Expected error for %+v", company)
+		}
+	}
+}
+
+func TestIsValidTUSSCode(t *testing.T) {
+	if !IsValidTUSSCode("81000065") {
+		t.Error("Expected valid TUSS code")
+	}
+	for _, code := range []string{"", "8100006", "810000650", "8100006A"} {
+		if IsValidTUSSCode(code) {
+			t.Errorf("Expected %q to be invalid", code)
+		}
+	}
+}
+
+func TestApplyInsuranceGuideReturn(t *testing.T) {
+	newGuide := func() models.InsuranceGuide {
+		return models.InsuranceGuide{GuideNumber: "000001", Items: []models.InsuranceGuideItem{
+			{ID: 1, ProcedureCode: "81000065", Total: 60},
+			{ID: 2, ProcedureCode: "85100196", Total: 120.5},
+		}}
+	}
+
+	guide := newGuide()
+	if err := ApplyInsuranceGuideReturn(&guide, []InsuranceItemReturn{{ItemID: 1, PaidValue: 60}, {ItemID: 2, PaidValue: 120.5}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if guide.Status != models.InsuranceGuideStatusPaid || guide.PaidValue != 180.5 || guide.DisallowedValue != 0 {
+		t.Errorf("Expected paid guide, got %+v", guide)
+	}
+
+	guide = newGuide()
+	err := ApplyInsuranceGuideReturn(&guide, []InsuranceItemReturn{
+		{ItemID: 1, PaidValue: 60},
+		{ItemID: 2, PaidValue: 100, DisallowanceCode: "1801", DisallowanceReason: "Valor acima da tabela"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if guide.Status != models.InsuranceGuideStatusPartiallyPaid || guide.PaidValue != 160 || guide.DisallowedValue != 20.5 {
+		t.Errorf("Expected partially paid guide, got %+v", guide)
+	}
+	if guide.Items[1].DisallowedValue != 20.5 || guide.Items[1].DisallowanceCode != "1801" {
+		t.Errorf("Unexpected disallowance: %+v", guide.Items[1])
+	}
+
+	// Items missing from the return are disallowed and need a code
+	guide = newGuide()
+	if err := ApplyInsuranceGuideReturn(&guide, []InsuranceItemReturn{{ItemID: 1, PaidValue: 60}}); err == nil {
+		t.Error("Expected error for disallowance without code")
+	}
+	guide = newGuide()
+	err = ApplyInsuranceGuideReturn(&guide, []InsuranceItemReturn{
+		{ItemID: 1, DisallowanceCode: "3052"},
+		{ItemID: 2, DisallowanceCode: "3052"},
+	})
+	if err != nil || guide.Status != models.InsuranceGuideStatusDenied || guide.DisallowedValue != 180.5 {
+		t.Errorf("Expected denied guide, got %+v (%v)", guide, err)
+	}
+
+	for _, returns := range [][]InsuranceItemReturn{
+		{{ItemID: 1, PaidValue: 70}, {ItemID: 2, PaidValue: 120.5}},
+		{{ItemID: 1, PaidValue: -1, DisallowanceCode: "1801"}, {ItemID: 2, PaidValue: 120.5}},
+		{{ItemID: 1, PaidValue: 60}, {ItemID: 2, PaidValue: 120.5}, {ItemID: 3, PaidValue: 10}},
+	} {
+		guide = newGuide()
+		if err := ApplyInsuranceGuideReturn(&guide, returns); err == nil {
+			t.Errorf("Expected error for %+v", returns)
+		}
+	}
+}
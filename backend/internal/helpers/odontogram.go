@@ -0,0 +1,171 @@
+package helpers
+
+import (
+	"drcrwell/backend/internal/models"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OdontogramWholeTooth is the site key of conditions that apply to the whole tooth
+const OdontogramWholeTooth = "tooth"
+
+// OdontogramMark is the condition shown on a tooth site (the whole tooth or one surface)
+type OdontogramMark struct {
+	Condition       string    `json:"condition"`
+	EventID         uint      `json:"event_id,omitempty"` // Zero for marks read from a legacy record JSON
+	MedicalRecordID *uint     `json:"medical_record_id,omitempty"`
+	RecordedAt      time.Time `json:"recorded_at"`
+	Notes           string    `json:"notes,omitempty"`
+}
+
+// OdontogramTooth is the chart state of one tooth
+// Marks are keyed by surface letter (M, D, O, V, L) or OdontogramWholeTooth
+type OdontogramTooth struct {
+	Tooth     int                       `json:"tooth"`
+	Dentition string                    `json:"dentition"`
+	Existing  map[string]OdontogramMark `json:"existing"`
+	Planned   map[string]OdontogramMark `json:"planned"`
+}
+
+// toothSites returns the chart sites touched by an event
+func toothSites(surfaces string) []string {
+	if surfaces == "" {
+		return []string{OdontogramWholeTooth}
+	}
+	sites := make([]string, 0, len(surfaces))
+	for _, r := range surfaces {
+		sites = append(sites, string(r))
+	}
+	return sites
+}
+
+// realizesPlan reports whether an existing condition fulfils a planned one
+func realizesPlan(planned, existing string) bool {
+	return planned == existing ||
+		(planned == models.ToothConditionExtraction && existing == models.ToothConditionMissing)
+}
+
+// BuildOdontogram replays the tooth events recorded up to asOf and returns the resulting chart,
+// sorted by tooth number. Later events on the same site replace earlier ones; an existing
+// condition that matches a planned one marks the plan as done
+func BuildOdontogram(events []models.ToothEvent, asOf time.Time) []OdontogramTooth {
+	sorted := make([]models.ToothEvent, 0, len(events))
+	for _, event := range events {
+		if !event.RecordedAt.After(asOf) {
+			sorted = append(sorted, event)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].RecordedAt.Equal(sorted[j].RecordedAt) {
+			return sorted[i].RecordedAt.Before(sorted[j].RecordedAt)
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	teeth := make(map[int]*OdontogramTooth)
+	for _, event := range sorted {
+		tooth, ok := teeth[event.Tooth]
+		if !ok {
+			tooth = &OdontogramTooth{
+				Tooth:     event.Tooth,
+				Dentition: ToothDentition(event.Tooth),
+				Existing:  make(map[string]OdontogramMark),
+				Planned:   make(map[string]OdontogramMark),
+			}
+			teeth[event.Tooth] = tooth
+		}
+
+		mark := OdontogramMark{
+			Condition:       event.Condition,
+			EventID:         event.ID,
+			MedicalRecordID: event.MedicalRecordID,
+			RecordedAt:      event.RecordedAt,
+			Notes:           event.Notes,
+		}
+
+		if event.State == models.ToothStatePlanned {
+			for _, site := range toothSites(event.Surfaces) {
+				tooth.Planned[site] = mark
+			}
+			continue
+		}
+
+		// A lost or replaced tooth has no surfaces left to chart
+		if event.Surfaces == "" && (event.Condition == models.ToothConditionMissing || event.Condition == models.ToothConditionImplant) {
+			for site := range tooth.Existing {
+				delete(tooth.Existing, site)
+			}
+			for site, planned := range tooth.Planned {
+				if site != OdontogramWholeTooth || realizesPlan(planned.Condition, event.Condition) {
+					delete(tooth.Planned, site)
+				}
+			}
+		}
+
+		for _, site := range toothSites(event.Surfaces) {
+			if planned, ok := tooth.Planned[site]; ok && realizesPlan(planned.Condition, event.Condition) {
+				delete(tooth.Planned, site)
+			}
+			if event.Condition == models.ToothConditionHealthy {
+				// A sound tooth clears every finding; a sound surface only its own
+				if site == OdontogramWholeTooth {
+					for s := range tooth.Existing {
+						delete(tooth.Existing, s)
+					}
+				} else {
+					delete(tooth.Existing, site)
+				}
+				continue
+			}
+			tooth.Existing[site] = mark
+		}
+	}
+
+	chart := make([]OdontogramTooth, 0, len(teeth))
+	for _, tooth := range teeth {
+		if len(tooth.Existing) == 0 && len(tooth.Planned) == 0 {
+			continue
+		}
+		chart = append(chart, *tooth)
+	}
+	sort.Slice(chart, func(i, j int) bool { return chart[i].Tooth < chart[j].Tooth })
+	return chart
+}
+
+// LegacyOdontogramEvents converts the untyped odontogram JSON of a medical record
+// ({"16": {"status": "cavity"}, ...}) into whole-tooth events dated at the record creation
+// Unknown teeth and statuses are skipped
+func LegacyOdontogramEvents(record models.MedicalRecord) []models.ToothEvent {
+	if record.Odontogram == nil || strings.TrimSpace(*record.Odontogram) == "" {
+		return nil
+	}
+
+	var legacy map[string]struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal([]byte(*record.Odontogram), &legacy); err != nil {
+		return nil
+	}
+
+	recordID := record.ID
+	events := make([]models.ToothEvent, 0, len(legacy))
+	for key, data := range legacy {
+		tooth, err := strconv.Atoi(key)
+		if err != nil || !IsValidFDITooth(tooth) || !models.IsValidToothCondition(data.Status) {
+			continue
+		}
+		events = append(events, models.ToothEvent{
+			PatientID:       record.PatientID,
+			MedicalRecordID: &recordID,
+			DentistID:       record.DentistID,
+			Tooth:           tooth,
+			Condition:       data.Status,
+			State:           models.ToothStateExisting,
+			RecordedAt:      record.CreatedAt,
+		})
+	}
+	return events
+}
@@ -0,0 +1,111 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+
+	"drcrwell/backend/internal/models"
+)
+
+func TestNormalizeToothSurfaces(t *testing.T) {
+	cases := map[string]string{
+		"":     "",
+		"mod":  "MOD",
+		"DOM":  "MOD",
+		"ip":   "OL",
+		"bvV":  "V",
+		" lv ": "VL",
+	}
+	for input, expected := range cases {
+		got, ok := NormalizeToothSurfaces(input)
+		if !ok || got != expected {
+			t.Errorf("NormalizeToothSurfaces(%q): expected %q, got %q (ok=%v)", input, expected, got, ok)
+		}
+	}
+	if _, ok := NormalizeToothSurfaces("MX"); ok {
+		t.Error("NormalizeToothSurfaces should reject unknown surfaces")
+	}
+}
+
+func TestIsValidFDITooth(t *testing.T) {
+	for _, tooth := range []int{11, 18, 28, 38, 48, 51, 55, 65, 75, 85} {
+		if !IsValidFDITooth(tooth) {
+			t.Errorf("expected %d to be a valid tooth", tooth)
+		}
+	}
+	for _, tooth := range []int{0, 10, 19, 49, 56, 86, 91} {
+		if IsValidFDITooth(tooth) {
+			t.Errorf("expected %d to be an invalid tooth", tooth)
+		}
+	}
+}
+
+func TestBuildOdontogram(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 10, 0, 0, 0, time.UTC) }
+	events := []models.ToothEvent{
+		{ID: 1, Tooth: 16, Surfaces: "MO", Condition: models.ToothConditionCavity, State: models.ToothStateExisting, RecordedAt: day(1)},
+		{ID: 2, Tooth: 16, Surfaces: "MO", Condition: models.ToothConditionRestoration, State: models.ToothStatePlanned, RecordedAt: day(1)},
+		{ID: 3, Tooth: 36, Condition: models.ToothConditionExtraction, State: models.ToothStatePlanned, RecordedAt: day(1)},
+		{ID: 4, Tooth: 16, Surfaces: "MO", Condition: models.ToothConditionRestoration, State: models.ToothStateExisting, RecordedAt: day(10)},
+		{ID: 5, Tooth: 36, Condition: models.ToothConditionMissing, State: models.ToothStateExisting, RecordedAt: day(10)},
+	}
+
+	// Before the treatment: cavities and plans
+	chart := BuildOdontogram(events, day(5))
+	if len(chart) != 2 || chart[0].Tooth != 16 || chart[1].Tooth != 36 {
+		t.Fatalf("expected teeth 16 and 36, got %+v", chart)
+	}
+	if chart[0].Existing["M"].Condition != models.ToothConditionCavity || len(chart[0].Planned) != 2 {
+		t.Errorf("tooth 16 before treatment: unexpected chart %+v", chart[0])
+	}
+	if chart[1].Planned[OdontogramWholeTooth].Condition != models.ToothConditionExtraction {
+		t.Errorf("tooth 36 before treatment: expected planned extraction, got %+v", chart[1])
+	}
+
+	// After the treatment: the plans were carried out
+	chart = BuildOdontogram(events, day(10))
+	if chart[0].Existing["O"].Condition != models.ToothConditionRestoration || len(chart[0].Planned) != 0 {
+		t.Errorf("tooth 16 after treatment: unexpected chart %+v", chart[0])
+	}
+	if chart[1].Existing[OdontogramWholeTooth].Condition != models.ToothConditionMissing || len(chart[1].Planned) != 0 {
+		t.Errorf("tooth 36 after treatment: unexpected chart %+v", chart[1])
+	}
+
+	// Nothing was charted yet
+	if chart := BuildOdontogram(events, day(1).Add(-time.Hour)); len(chart) != 0 {
+		t.Errorf("expected an empty chart, got %+v", chart)
+	}
+}
+
+func TestBuildOdontogramHealthyClearsFindings(t *testing.T) {
+	at := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	events := []models.ToothEvent{
+		{ID: 1, Tooth: 21, Surfaces: "V", Condition: models.ToothConditionFracture, State: models.ToothStateExisting, RecordedAt: at},
+		{ID: 2, Tooth: 21, Condition: models.ToothConditionHealthy, State: models.ToothStateExisting, RecordedAt: at.Add(time.Hour)},
+	}
+	if chart := BuildOdontogram(events, at.Add(2*time.Hour)); len(chart) != 0 {
+		t.Errorf("expected a sound tooth to leave the chart, got %+v", chart)
+	}
+}
+
+func TestLegacyOdontogramEvents(t *testing.T) {
+	blob := `{"16": {"status": "cavity"}, "21": {"status": "crown"}, "99": {"status": "cavity"}, "11": {"status": "unknown"}}`
+	record := models.MedicalRecord{PatientID: 7, Odontogram: &blob}
+	record.ID = 3
+	record.CreatedAt = time.Date(2025, 5, 2, 9, 0, 0, 0, time.UTC)
+
+	events := LegacyOdontogramEvents(record)
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	for _, event := range events {
+		if event.PatientID != 7 || event.MedicalRecordID == nil || *event.MedicalRecordID != 3 || !event.RecordedAt.Equal(record.CreatedAt) {
+			t.Errorf("unexpected legacy event %+v", event)
+		}
+	}
+
+	chart := BuildOdontogram(events, time.Now())
+	if len(chart) != 2 || chart[1].Existing[OdontogramWholeTooth].Condition != models.ToothConditionCrown {
+		t.Errorf("unexpected chart from legacy record: %+v", chart)
+	}
+}
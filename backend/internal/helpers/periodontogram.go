@@ -0,0 +1,143 @@
+package helpers
+
+import (
+	"drcrwell/backend/internal/models"
+	"math"
+	"sort"
+)
+
+// PerioSignificantChange is the probing change (mm) considered clinically significant between exams
+const PerioSignificantChange = 2
+
+// PeriodontalIndices summarizes a periodontal exam. Missing teeth are not counted
+type PeriodontalIndices struct {
+	Teeth               int     `json:"teeth"`
+	Sites               int     `json:"sites"`
+	PlaquePercent       float64 `json:"plaque_percent"`
+	BleedingPercent     float64 `json:"bleeding_percent"`
+	MeanProbingDepth    float64 `json:"mean_probing_depth"`
+	MeanAttachmentLevel float64 `json:"mean_attachment_level"`
+	SitesPD4Plus        int     `json:"sites_pd_4_plus"` // Sites with probing depth >= 4mm
+	SitesPD6Plus        int     `json:"sites_pd_6_plus"` // Sites with probing depth >= 6mm
+}
+
+// roundTo1 rounds to one decimal place
+func roundTo1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+// ComputePeriodontalIndices calculates plaque %, bleeding on probing %, mean PD and mean CAL
+func ComputePeriodontalIndices(teeth []models.PeriodontalTooth) PeriodontalIndices {
+	var indices PeriodontalIndices
+	var plaque, bleeding, probing, attachment int
+
+	for _, tooth := range teeth {
+		if tooth.Missing {
+			continue
+		}
+		indices.Teeth++
+		indices.Sites += models.PerioSiteCount
+		plaque += tooth.Plaque.Count()
+		bleeding += tooth.Bleeding.Count()
+
+		cal := tooth.AttachmentLevel()
+		for i, pd := range tooth.ProbingDepth {
+			probing += pd
+			attachment += cal[i]
+			if pd >= 4 {
+				indices.SitesPD4Plus++
+			}
+			if pd >= 6 {
+				indices.SitesPD6Plus++
+			}
+		}
+	}
+
+	if indices.Sites > 0 {
+		sites := float64(indices.Sites)
+		indices.PlaquePercent = roundTo1(float64(plaque) / sites * 100)
+		indices.BleedingPercent = roundTo1(float64(bleeding) / sites * 100)
+		indices.MeanProbingDepth = roundTo1(float64(probing) / sites)
+		indices.MeanAttachmentLevel = roundTo1(float64(attachment) / sites)
+	}
+	return indices
+}
+
+// PeriodontalToothChange is how one tooth changed between two exams
+// Positive changes mean deeper pockets / attachment loss
+type PeriodontalToothChange struct {
+	Tooth           int                    `json:"tooth"`
+	ProbingDepth    models.PerioSiteValues `json:"probing_depth_change"`
+	AttachmentLevel models.PerioSiteValues `json:"attachment_level_change"`
+	ImprovedSites   int                    `json:"improved_sites"` // PD reduced by PerioSignificantChange or more
+	WorsenedSites   int                    `json:"worsened_sites"` // PD increased by PerioSignificantChange or more
+	BecameMissing   bool                   `json:"became_missing"`
+	MobilityChange  int                    `json:"mobility_change"`
+	FurcationChange int                    `json:"furcation_change"`
+	BleedingBefore  int                    `json:"bleeding_before"`
+	BleedingAfter   int                    `json:"bleeding_after"`
+}
+
+// PeriodontalComparison compares a later exam with an earlier one of the same patient
+type PeriodontalComparison struct {
+	Before        PeriodontalIndices       `json:"before"`
+	After         PeriodontalIndices       `json:"after"`
+	PlaqueDelta   float64                  `json:"plaque_delta"`
+	BleedingDelta float64                  `json:"bleeding_delta"`
+	ProbingDelta  float64                  `json:"probing_delta"`
+	CALDelta      float64                  `json:"cal_delta"`
+	ImprovedSites int                      `json:"improved_sites"`
+	WorsenedSites int                      `json:"worsened_sites"`
+	Teeth         []PeriodontalToothChange `json:"teeth"` // Teeth charted in both exams
+}
+
+// ComparePeriodontalExams compares the teeth charted in both exams
+func ComparePeriodontalExams(before, after []models.PeriodontalTooth) PeriodontalComparison {
+	comparison := PeriodontalComparison{
+		Before: ComputePeriodontalIndices(before),
+		After:  ComputePeriodontalIndices(after),
+		Teeth:  []PeriodontalToothChange{},
+	}
+	comparison.PlaqueDelta = roundTo1(comparison.After.PlaquePercent - comparison.Before.PlaquePercent)
+	comparison.BleedingDelta = roundTo1(comparison.After.BleedingPercent - comparison.Before.BleedingPercent)
+	comparison.ProbingDelta = roundTo1(comparison.After.MeanProbingDepth - comparison.Before.MeanProbingDepth)
+	comparison.CALDelta = roundTo1(comparison.After.MeanAttachmentLevel - comparison.Before.MeanAttachmentLevel)
+
+	previous := make(map[int]models.PeriodontalTooth, len(before))
+	for _, tooth := range before {
+		previous[tooth.Tooth] = tooth
+	}
+
+	for _, tooth := range after {
+		old, ok := previous[tooth.Tooth]
+		if !ok || old.Missing {
+			continue
+		}
+		change := PeriodontalToothChange{
+			Tooth:          tooth.Tooth,
+			BecameMissing:  tooth.Missing,
+			BleedingBefore: old.Bleeding.Count(),
+			BleedingAfter:  tooth.Bleeding.Count(),
+		}
+		if !tooth.Missing {
+			oldCAL, newCAL := old.AttachmentLevel(), tooth.AttachmentLevel()
+			for i := range tooth.ProbingDepth {
+				change.ProbingDepth[i] = tooth.ProbingDepth[i] - old.ProbingDepth[i]
+				change.AttachmentLevel[i] = newCAL[i] - oldCAL[i]
+				if change.ProbingDepth[i] <= -PerioSignificantChange {
+					change.ImprovedSites++
+				} else if change.ProbingDepth[i] >= PerioSignificantChange {
+					change.WorsenedSites++
+				}
+			}
+			change.MobilityChange = tooth.Mobility - old.Mobility
+			change.FurcationChange = tooth.Furcation - old.Furcation
+		}
+		comparison.ImprovedSites += change.ImprovedSites
+		comparison.WorsenedSites += change.WorsenedSites
+		comparison.Teeth = append(comparison.Teeth, change)
+	}
+
+	sort.Slice(comparison.Teeth, func(i, j int) bool { return comparison.Teeth[i].Tooth < comparison.Teeth[j].Tooth })
+	return comparison
+}
@@ -0,0 +1,92 @@
+package helpers
+
+import (
+	"testing"
+
+	"drcrwell/backend/internal/models"
+)
+
+func TestComputePeriodontalIndices(t *testing.T) {
+	teeth := []models.PeriodontalTooth{
+		{
+			Tooth:        16,
+			ProbingDepth: models.PerioSiteValues{3, 2, 5, 4, 2, 3},
+			Recession:    models.PerioSiteValues{1, 0, 1, 0, 0, 0},
+			Bleeding:     models.PerioSiteFlags{false, false, true, true, false, false},
+			Plaque:       models.PerioSiteFlags{true, true, true, false, false, false},
+		},
+		{
+			Tooth:        11,
+			ProbingDepth: models.PerioSiteValues{2, 1, 2, 2, 1, 6},
+			Bleeding:     models.PerioSiteFlags{false, false, false, false, false, true},
+		},
+		// Missing teeth are not counted
+		{Tooth: 26, Missing: true, ProbingDepth: models.PerioSiteValues{9, 9, 9, 9, 9, 9}},
+	}
+
+	indices := ComputePeriodontalIndices(teeth)
+	if indices.Teeth != 2 || indices.Sites != 12 {
+		t.Fatalf("expected 2 teeth / 12 sites, got %d / %d", indices.Teeth, indices.Sites)
+	}
+	if indices.PlaquePercent != 25 {
+		t.Errorf("expected plaque 25%%, got %.1f", indices.PlaquePercent)
+	}
+	if indices.BleedingPercent != 25 {
+		t.Errorf("expected bleeding 25%%, got %.1f", indices.BleedingPercent)
+	}
+	// (19 + 14) / 12 = 2.75
+	if indices.MeanProbingDepth != 2.8 {
+		t.Errorf("expected mean PD 2.8, got %.1f", indices.MeanProbingDepth)
+	}
+	// (33 + 2) / 12 = 2.92
+	if indices.MeanAttachmentLevel != 2.9 {
+		t.Errorf("expected mean CAL 2.9, got %.1f", indices.MeanAttachmentLevel)
+	}
+	if indices.SitesPD4Plus != 3 || indices.SitesPD6Plus != 1 {
+		t.Errorf("expected 3 sites >= 4mm and 1 site >= 6mm, got %d and %d", indices.SitesPD4Plus, indices.SitesPD6Plus)
+	}
+
+	if empty := ComputePeriodontalIndices(nil); empty.Sites != 0 || empty.MeanProbingDepth != 0 {
+		t.Errorf("expected empty indices, got %+v", empty)
+	}
+}
+
+func TestComparePeriodontalExams(t *testing.T) {
+	before := []models.PeriodontalTooth{
+		{Tooth: 16, ProbingDepth: models.PerioSiteValues{6, 3, 5, 4, 3, 3}, Bleeding: models.PerioSiteFlags{true, false, true, false, false, false}, Mobility: 1},
+		{Tooth: 36, ProbingDepth: models.PerioSiteValues{3, 2, 3, 3, 2, 3}},
+		{Tooth: 46, ProbingDepth: models.PerioSiteValues{3, 2, 3, 3, 2, 3}},
+	}
+	after := []models.PeriodontalTooth{
+		{Tooth: 16, ProbingDepth: models.PerioSiteValues{3, 3, 3, 4, 3, 3}, Recession: models.PerioSiteValues{1, 0, 1, 0, 0, 0}},
+		{Tooth: 36, ProbingDepth: models.PerioSiteValues{3, 2, 5, 3, 2, 3}},
+		{Tooth: 46, Missing: true},
+		// Not charted in the first exam
+		{Tooth: 11, ProbingDepth: models.PerioSiteValues{2, 2, 2, 2, 2, 2}},
+	}
+
+	comparison := ComparePeriodontalExams(before, after)
+	if len(comparison.Teeth) != 3 {
+		t.Fatalf("expected 3 compared teeth, got %d", len(comparison.Teeth))
+	}
+
+	upper := comparison.Teeth[0]
+	if upper.Tooth != 16 || upper.ImprovedSites != 2 || upper.ProbingDepth[0] != -3 || upper.AttachmentLevel[0] != -2 {
+		t.Errorf("tooth 16: unexpected change %+v", upper)
+	}
+	if upper.MobilityChange != -1 || upper.BleedingBefore != 2 || upper.BleedingAfter != 0 {
+		t.Errorf("tooth 16: unexpected mobility/bleeding change %+v", upper)
+	}
+	if comparison.Teeth[1].Tooth != 36 || comparison.Teeth[1].WorsenedSites != 1 {
+		t.Errorf("tooth 36: expected 1 worsened site, got %+v", comparison.Teeth[1])
+	}
+	if !comparison.Teeth[2].BecameMissing {
+		t.Errorf("tooth 46: expected to be reported as lost, got %+v", comparison.Teeth[2])
+	}
+	if comparison.ImprovedSites != 2 || comparison.WorsenedSites != 1 {
+		t.Errorf("expected 2 improved / 1 worsened sites, got %d / %d", comparison.ImprovedSites, comparison.WorsenedSites)
+	}
+	if comparison.BleedingDelta >= 0 {
+		t.Errorf("expected bleeding to decrease, got delta %.1f", comparison.BleedingDelta)
+	}
+}
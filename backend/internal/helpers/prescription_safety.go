@@ -0,0 +1,268 @@
+package helpers
+
+import (
+	"drcrwell/backend/internal/models"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Prescription warning types and severities
+const (
+	PrescriptionWarningAllergy     = "allergy"
+	PrescriptionWarningInteraction = "interaction"
+
+	WarningSeverityHigh     = "high"
+	WarningSeverityModerate = "moderate"
+	WarningSeverityLow      = "low"
+)
+
+// PrescriptionWarning is an allergy or interaction found in a prescription
+type PrescriptionWarning struct {
+	Type       string `json:"type"`
+	Severity   string `json:"severity"`
+	Medication string `json:"medication"` // Prescribed medication
+	With       string `json:"with"`       // Allergy, other prescribed medication or medication in use
+	Message    string `json:"message"`
+}
+
+// drugClassKeywords detects drug classes in free text (patient medications and allergies)
+// and in catalog entries. Keywords are lowercase and without accents
+var drugClassKeywords = map[string][]string{
+	"anticoagulant":      {"anticoagulante", "varfarina", "warfarin", "marevan", "coumadin", "rivaroxabana", "xarelto", "apixabana", "eliquis", "dabigatrana", "pradaxa", "heparina", "enoxaparina", "clexane"},
+	"antiplatelet":       {"antiagregante", "clopidogrel", "plavix", "ticagrelor", "prasugrel", "aas", "acido acetilsalicilico", "aspirina"},
+	"nsaid":              {"anti inflamatorio", "aine", "ibuprofeno", "diclofenaco", "nimesulida", "cetoprofeno", "naproxeno", "piroxicam", "meloxicam", "celecoxibe", "etoricoxibe", "aas", "acido acetilsalicilico", "aspirina"},
+	"antihypertensive":   {"anti hipertensivo", "losartana", "valsartana", "enalapril", "captopril", "hidroclorotiazida", "anlodipino", "atenolol", "propranolol", "metoprolol"},
+	"lithium":            {"litio", "carbolitium"},
+	"methotrexate":       {"metotrexato"},
+	"metronidazole":      {"metronidazol", "flagyl"},
+	"macrolide":          {"azitromicina", "claritromicina", "eritromicina"},
+	"statin":             {"sinvastatina", "atorvastatina", "rosuvastatina"},
+	"azole_antifungal":   {"fluconazol", "cetoconazol", "itraconazol"},
+	"benzodiazepine":     {"benzodiazepinico", "diazepam", "midazolam", "alprazolam", "clonazepam", "lorazepam", "rivotril"},
+	"opioid":             {"opioide", "codeina", "tramadol", "morfina", "oxicodona"},
+	"serotonergic":       {"tramadol", "fluoxetina", "sertralina", "paroxetina", "citalopram", "escitalopram", "venlafaxina"},
+	"penicillin":         {"penicilina", "amoxicilina", "ampicilina", "benzetacil"},
+	"oral_contraceptive": {"anticoncepcional", "contraceptivo"},
+	"tetracycline":       {"tetraciclina", "doxiciclina", "minociclina"},
+	"antacid":            {"antiacido", "hidroxido de aluminio", "hidroxido de magnesio"},
+	"corticosteroid":     {"corticoide", "dexametasona", "prednisona", "prednisolona", "betametasona"},
+	"dipyrone":           {"dipirona", "metamizol", "novalgina"},
+	"sulfonamide":        {"sulfa", "sulfametoxazol", "bactrim"},
+}
+
+// DrugClasses returns the therapeutic classes known by the interaction check, sorted
+func DrugClasses() []string {
+	classes := make([]string, 0, len(drugClassKeywords))
+	for class := range drugClassKeywords {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	return classes
+}
+
+// IsKnownDrugClass reports whether class is used by the interaction check ("" is accepted)
+func IsKnownDrugClass(class string) bool {
+	_, ok := drugClassKeywords[class]
+	return class == "" || ok
+}
+
+// drugInteraction is an entry of the bundled interaction table
+type drugInteraction struct {
+	classA, classB string
+	severity       string
+	message        string
+}
+
+// drugInteractions lists the interactions most relevant to dental prescribing
+var drugInteractions = []drugInteraction{
+	{"nsaid", "anticoagulant", WarningSeverityHigh, "Anti-inflamatório associado a anticoagulante aumenta o risco de sangramento"},
+	{"nsaid", "antiplatelet", WarningSeverityModerate, "Anti-inflamatório associado a antiagregante aumenta o risco de sangramento"},
+	{"nsaid", "lithium", WarningSeverityHigh, "Anti-inflamatório eleva o nível sérico de lítio"},
+	{"nsaid", "methotrexate", WarningSeverityHigh, "Anti-inflamatório reduz a eliminação do metotrexato (toxicidade)"},
+	{"nsaid", "antihypertensive", WarningSeverityModerate, "Anti-inflamatório reduz o efeito de anti-hipertensivos"},
+	{"nsaid", "corticosteroid", WarningSeverityModerate, "Anti-inflamatório associado a corticoide aumenta o risco de lesão gastrointestinal"},
+	{"nsaid", "nsaid", WarningSeverityModerate, "Associação de anti-inflamatórios aumenta o risco gastrointestinal e renal"},
+	{"metronidazole", "anticoagulant", WarningSeverityHigh, "Metronidazol potencializa o efeito anticoagulante"},
+	{"metronidazole", "lithium", WarningSeverityModerate, "Metronidazol pode elevar o nível sérico de lítio"},
+	{"macrolide", "anticoagulant", WarningSeverityModerate, "Macrolídeo pode potencializar o efeito anticoagulante"},
+	{"macrolide", "statin", WarningSeverityModerate, "Macrolídeo aumenta o risco de miopatia com estatinas"},
+	{"azole_antifungal", "anticoagulant", WarningSeverityHigh, "Antifúngico azólico potencializa o efeito anticoagulante"},
+	{"azole_antifungal", "benzodiazepine", WarningSeverityModerate, "Antifúngico azólico prolonga a sedação por benzodiazepínicos"},
+	{"benzodiazepine", "opioid", WarningSeverityHigh, "Benzodiazepínico associado a opioide pode causar depressão respiratória"},
+	{"opioid", "opioid", WarningSeverityHigh, "Associação de opioides pode causar depressão respiratória"},
+	{"serotonergic", "serotonergic", WarningSeverityHigh, "Risco de síndrome serotoninérgica"},
+	{"tetracycline", "antacid", WarningSeverityModerate, "Antiácidos reduzem a absorção de tetraciclinas"},
+	{"penicillin", "methotrexate", WarningSeverityModerate, "Penicilinas reduzem a eliminação do metotrexato"},
+	{"penicillin", "oral_contraceptive", WarningSeverityLow, "Antibiótico pode reduzir a eficácia do anticoncepcional oral"},
+	{"tetracycline", "oral_contraceptive", WarningSeverityLow, "Antibiótico pode reduzir a eficácia do anticoncepcional oral"},
+}
+
+// warningSeverityOrder sorts warnings with the most severe first
+var warningSeverityOrder = map[string]int{WarningSeverityHigh: 0, WarningSeverityModerate: 1, WarningSeverityLow: 2}
+
+var accentReplacer = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ã", "a", "é", "e", "ê", "e", "í", "i",
+	"ó", "o", "ô", "o", "õ", "o", "ú", "u", "ü", "u", "ç", "c",
+)
+
+// normalizeDrugText lowercases, removes accents and punctuation, and pads the text with
+// spaces so keywords can be matched as whole words (" aas ")
+func normalizeDrugText(text string) string {
+	text = accentReplacer.Replace(strings.ToLower(text))
+	text = strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return ' '
+	}, text)
+	return " " + strings.Join(strings.Fields(text), " ") + " "
+}
+
+// containsDrugTerm reports whether the normalized text contains term as whole words
+func containsDrugTerm(normalized, term string) bool {
+	term = strings.TrimSpace(normalizeDrugText(term))
+	return term != "" && strings.Contains(normalized, " "+term+" ")
+}
+
+// detectDrugClasses returns the drug classes mentioned in a text, with the term found
+func detectDrugClasses(text string) map[string]string {
+	normalized := normalizeDrugText(text)
+	classes := make(map[string]string)
+	for class, keywords := range drugClassKeywords {
+		for _, keyword := range keywords {
+			if containsDrugTerm(normalized, keyword) {
+				classes[class] = keyword
+				break
+			}
+		}
+	}
+	return classes
+}
+
+// medicationClasses returns the classes of a catalog entry: its therapeutic class and the
+// classes its active ingredient belongs to
+func medicationClasses(medication models.Medication) map[string]bool {
+	classes := make(map[string]bool)
+	if medication.TherapeuticClass != "" {
+		classes[medication.TherapeuticClass] = true
+	}
+	for class := range detectDrugClasses(medication.ActiveIngredient) {
+		classes[class] = true
+	}
+	return classes
+}
+
+// findDrugInteractions returns the table entries between two sets of classes
+func findDrugInteractions(a, b map[string]bool) []drugInteraction {
+	found := []drugInteraction{}
+	for _, interaction := range drugInteractions {
+		if (a[interaction.classA] && b[interaction.classB]) || (a[interaction.classB] && b[interaction.classA]) {
+			found = append(found, interaction)
+		}
+	}
+	return found
+}
+
+// CheckPrescriptionSafety checks the prescribed medications against the patient's allergies,
+// each other and the medications the patient already takes (free text from the patient record)
+func CheckPrescriptionSafety(medications []models.Medication, allergies, currentMedications string) []PrescriptionWarning {
+	warnings := []PrescriptionWarning{}
+	seen := make(map[string]bool)
+	add := func(warning PrescriptionWarning) {
+		key := warning.Type + "|" + warning.Medication + "|" + warning.With + "|" + warning.Message
+		if !seen[key] {
+			seen[key] = true
+			warnings = append(warnings, warning)
+		}
+	}
+
+	normalizedAllergies := normalizeDrugText(allergies)
+	allergyClasses := detectDrugClasses(allergies)
+	currentClasses := detectDrugClasses(currentMedications)
+
+	for i, medication := range medications {
+		classes := medicationClasses(medication)
+
+		// Allergies: ingredient, name or allergy group written in the patient's allergies,
+		// or an allergy to the whole class ("penicilina" for amoxicilina)
+		for _, term := range []string{medication.ActiveIngredient, medication.Name, medication.AllergyGroup} {
+			if containsDrugTerm(normalizedAllergies, term) {
+				add(PrescriptionWarning{
+					Type:       PrescriptionWarningAllergy,
+					Severity:   WarningSeverityHigh,
+					Medication: medication.Name,
+					With:       term,
+					Message:    fmt.Sprintf("Paciente com alergia registrada a %s", term),
+				})
+			}
+		}
+		for class, term := range allergyClasses {
+			if classes[class] {
+				add(PrescriptionWarning{
+					Type:       PrescriptionWarningAllergy,
+					Severity:   WarningSeverityHigh,
+					Medication: medication.Name,
+					With:       term,
+					Message:    fmt.Sprintf("Paciente com alergia registrada a %s", term),
+				})
+			}
+		}
+
+		// Interactions with the other prescribed medications
+		for _, other := range medications[i+1:] {
+			for _, interaction := range findDrugInteractions(classes, medicationClasses(other)) {
+				add(PrescriptionWarning{
+					Type:       PrescriptionWarningInteraction,
+					Severity:   interaction.severity,
+					Medication: medication.Name,
+					With:       other.Name,
+					Message:    interaction.message,
+				})
+			}
+		}
+
+		// Interactions with the medications in use
+		for class, term := range currentClasses {
+			for _, interaction := range findDrugInteractions(classes, map[string]bool{class: true}) {
+				add(PrescriptionWarning{
+					Type:       PrescriptionWarningInteraction,
+					Severity:   interaction.severity,
+					Medication: medication.Name,
+					With:       term + " (em uso)",
+					Message:    interaction.message,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(warnings, func(i, j int) bool {
+		return warningSeverityOrder[warnings[i].Severity] < warningSeverityOrder[warnings[j].Severity]
+	})
+	return warnings
+}
+
+// FormatPrescriptionItems writes the structured items as the prescription text
+//
+//  1. Amoxicilina - Cápsula 500 mg (Uso oral) ........ 21 cápsulas
+//     Tomar 1 cápsula de 8/8h por 7 dias
+func FormatPrescriptionItems(items []models.PrescriptionItem) string {
+	lines := make([]string, 0, len(items)*2)
+	for i, item := range items {
+		line := fmt.Sprintf("%d. %s", i+1, item.MedicationName)
+		if item.Presentation != "" {
+			line += " - " + item.Presentation
+		}
+		if item.Route != "" {
+			line += " (" + item.Route + ")"
+		}
+		if item.Quantity != "" {
+			line += " ........ " + item.Quantity
+		}
+		lines = append(lines, line)
+		if item.Dosage != "" {
+			lines = append(lines, "   "+item.Dosage)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
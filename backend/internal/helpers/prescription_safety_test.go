@@ -0,0 +1,64 @@
+package helpers
+
+import (
+	"testing"
+
+	"drcrwell/backend/internal/models"
+)
+
+func TestCheckPrescriptionSafety(t *testing.T) {
+	amoxicillin := models.Medication{Name: "Amoxil", ActiveIngredient: "Amoxicilina", TherapeuticClass: "penicillin", AllergyGroup: "penicilina"}
+	ibuprofen := models.Medication{Name: "Ibuprofeno", ActiveIngredient: "Ibuprofeno", TherapeuticClass: "nsaid"}
+	metronidazole := models.Medication{Name: "Flagyl", ActiveIngredient: "Metronidazol"}
+	paracetamol := models.Medication{Name: "Paracetamol", ActiveIngredient: "Paracetamol"}
+
+	// No allergies or medications in use
+	if warnings := CheckPrescriptionSafety([]models.Medication{amoxicillin, paracetamol}, "Nega alergias", ""); len(warnings) != 0 {
+		t.Errorf("Expected no warnings, got %+v", warnings)
+	}
+
+	// Class allergy and interaction with a medication in use (accents and case ignored)
+	warnings := CheckPrescriptionSafety([]models.Medication{amoxicillin, ibuprofen}, "ALERGIA À PENICILINA", "Marevan 5mg/dia; Losartana")
+	count := map[string]int{}
+	for _, warning := range warnings {
+		count[warning.Type]++
+	}
+	if count[PrescriptionWarningAllergy] != 1 {
+		t.Errorf("Expected 1 allergy warning, got %+v", warnings)
+	}
+	if count[PrescriptionWarningInteraction] != 2 {
+		t.Errorf("Expected interactions with anticoagulant and antihypertensive, got %+v", warnings)
+	}
+	for i := 1; i < len(warnings); i++ {
+		if warningSeverityOrder[warnings[i-1].Severity] > warningSeverityOrder[warnings[i].Severity] {
+			t.Errorf("Warnings not sorted by severity: %+v", warnings)
+		}
+	}
+
+	// Interaction between prescribed medications (class detected from the active ingredient)
+	warnings = CheckPrescriptionSafety([]models.Medication{metronidazole, {Name: "Varfarina", ActiveIngredient: "Varfarina"}}, "", "")
+	if len(warnings) != 1 || warnings[0].Severity != WarningSeverityHigh || warnings[0].With != "Varfarina" {
+		t.Errorf("Expected metronidazole x warfarin interaction, got %+v", warnings)
+	}
+
+	// Keywords match whole words only ("aas" is not in "faaso")
+	if classes := detectDrugClasses("Faaso 10 mg"); len(classes) != 0 {
+		t.Errorf("Expected no classes, got %v", classes)
+	}
+	if classes := detectDrugClasses("AAS 100mg"); classes["antiplatelet"] == "" || classes["nsaid"] == "" {
+		t.Errorf("Expected antiplatelet and nsaid, got %v", classes)
+	}
+}
+
+func TestFormatPrescriptionItems(t *testing.T) {
+	text := FormatPrescriptionItems([]models.PrescriptionItem{
+		{MedicationName: "Amoxicilina", Presentation: "Cápsula 500 mg", Route: "Uso oral", Quantity: "21 cápsulas", Dosage: "1 cápsula de 8/8h por 7 dias"},
+		{MedicationName: "Dipirona", Quantity: "10 comprimidos"},
+	})
+	expected := "1. Amoxicilina - Cápsula 500 mg (Uso oral) ........ 21 cápsulas\n" +
+		"   1 cápsula de 8/8h por 7 dias\n" +
+		"2. Dipirona ........ 10 comprimidos"
+	if text != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, text)
+	}
+}
@@ -0,0 +1,77 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// ErrConverterUnavailable is returned when the external tool needed to convert a file is not installed
+var ErrConverterUnavailable = errors.New("converter not installed")
+
+// converterTimeout bounds each external conversion
+const converterTimeout = 60 * time.Second
+
+// runConverter writes the input to a temporary directory, runs the tool and returns the bytes of
+// the first output found. args receives the input path and the output path (without extension
+// for tools that add it)
+func runConverter(tool, inputName string, input []byte, outputs []string, args func(in, out string) []string) ([]byte, error) {
+	path, err := exec.LookPath(tool)
+	if err != nil {
+		return nil, ErrConverterUnavailable
+	}
+
+	dir, err := os.MkdirTemp("", "preview-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	in := filepath.Join(dir, inputName)
+	if err := os.WriteFile(in, input, 0600); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), converterTimeout)
+	defer cancel()
+	out := filepath.Join(dir, "out")
+	if output, err := exec.CommandContext(ctx, path, args(in, out)...).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("%s failed: %v: %s", tool, err, output)
+	}
+
+	for _, name := range outputs {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err == nil {
+			return data, nil
+		}
+	}
+	return nil, fmt.Errorf("%s produced no output", tool)
+}
+
+// ConvertHEICToJPEG converts a HEIC/HEIF photo (primary image) to JPEG with heif-convert (libheif)
+func ConvertHEICToJPEG(data []byte) ([]byte, error) {
+	// Files with several images are written as out-1.jpg, out-2.jpg...
+	return runConverter("heif-convert", "in.heic", data, []string{"out.jpg", "out-1.jpg"}, func(in, out string) []string {
+		return []string{"-q", "90", in, out + ".jpg"}
+	})
+}
+
+// RenderPDFFirstPage renders the first page of a PDF as PNG with pdftoppm (poppler), already
+// scaled to the preview size
+func RenderPDFFirstPage(data []byte) ([]byte, error) {
+	return runConverter("pdftoppm", "in.pdf", data, []string{"out.png"}, func(in, out string) []string {
+		return []string{"-f", "1", "-l", "1", "-singlefile", "-png", "-scale-to", fmt.Sprint(ExamPreviewSize), in, out}
+	})
+}
+
+// StripHEICGPS removes the GPS location of a HEIC/HEIF photo with exiftool, keeping the image and
+// its other metadata (orientation). The file is rewritten in place, so it is returned even when
+// there was no location to remove
+func StripHEICGPS(data []byte) ([]byte, error) {
+	return runConverter("exiftool", "in.heic", data, []string{"in.heic"}, func(in, out string) []string {
+		return []string{"-q", "-overwrite_original", "-gps:all=", in}
+	})
+}
@@ -0,0 +1,303 @@
+package helpers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxRecurrenceOccurrences limits how many appointments a single series can generate
+// (two years of weekly visits covers even long orthodontic treatments)
+const MaxRecurrenceOccurrences = 104
+
+// Recurrence frequencies supported (subset of RFC 5545 FREQ values)
+const (
+	RecurrenceDaily   = "DAILY"
+	RecurrenceWeekly  = "WEEKLY"
+	RecurrenceMonthly = "MONTHLY"
+	RecurrenceYearly  = "YEARLY"
+)
+
+// RecurrenceWeekday is a BYDAY entry, optionally with an ordinal (e.g. 2TU, -1FR)
+type RecurrenceWeekday struct {
+	Weekday time.Weekday
+	Ordinal int // 0 means every occurrence of the weekday in the period
+}
+
+// RecurrenceRule is a parsed RFC 5545 RRULE
+// Example: "FREQ=WEEKLY;INTERVAL=2;COUNT=12;BYDAY=MO,WE"
+type RecurrenceRule struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      *time.Time
+	ByDay      []RecurrenceWeekday
+	ByMonthDay []int
+}
+
+var rruleWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// ParseRecurrenceRule parses an RRULE string (with or without the "RRULE:" prefix)
+// COUNT or UNTIL is required so the series can be fully materialized
+func ParseRecurrenceRule(rule string, loc *time.Location) (*RecurrenceRule, error) {
+	rule = strings.TrimSpace(rule)
+	rule = strings.TrimPrefix(strings.ToUpper(rule), "RRULE:")
+	if rule == "" {
+		return nil, fmt.Errorf("regra de recorrência vazia")
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+
+	r := &RecurrenceRule{Interval: 1}
+	for _, part := range strings.Split(rule, ";") {
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("parte inválida na regra de recorrência: %s", part)
+		}
+		key, value := kv[0], kv[1]
+
+		switch key {
+		case "FREQ":
+			switch value {
+			case RecurrenceDaily, RecurrenceWeekly, RecurrenceMonthly, RecurrenceYearly:
+				r.Freq = value
+			default:
+				return nil, fmt.Errorf("frequência não suportada: %s", value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("INTERVAL inválido: %s", value)
+			}
+			r.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("COUNT inválido: %s", value)
+			}
+			r.Count = n
+		case "UNTIL":
+			until, err := parseRRuleUntil(value, loc)
+			if err != nil {
+				return nil, err
+			}
+			r.Until = &until
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				wd, err := parseRRuleWeekday(day)
+				if err != nil {
+					return nil, err
+				}
+				r.ByDay = append(r.ByDay, wd)
+			}
+		case "BYMONTHDAY":
+			for _, d := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(d)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return nil, fmt.Errorf("BYMONTHDAY inválido: %s", d)
+				}
+				r.ByMonthDay = append(r.ByMonthDay, n)
+			}
+		case "WKST":
+			// Week start only affects WEEKLY rules with INTERVAL > 1; Monday is assumed
+		default:
+			return nil, fmt.Errorf("parâmetro de recorrência não suportado: %s", key)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, fmt.Errorf("FREQ é obrigatório na regra de recorrência")
+	}
+	if r.Count == 0 && r.Until == nil {
+		return nil, fmt.Errorf("informe COUNT ou UNTIL na regra de recorrência")
+	}
+	if r.Count > MaxRecurrenceOccurrences {
+		return nil, fmt.Errorf("a série pode ter no máximo %d ocorrências", MaxRecurrenceOccurrences)
+	}
+	if r.Freq != RecurrenceWeekly && r.Freq != RecurrenceMonthly && len(r.ByDay) > 0 {
+		return nil, fmt.Errorf("BYDAY só é suportado com FREQ=WEEKLY ou FREQ=MONTHLY")
+	}
+	for _, wd := range r.ByDay {
+		if wd.Ordinal != 0 && r.Freq != RecurrenceMonthly {
+			return nil, fmt.Errorf("BYDAY com ordinal só é suportado com FREQ=MONTHLY")
+		}
+	}
+
+	return r, nil
+}
+
+// parseRRuleUntil accepts the RFC 5545 DATE and DATE-TIME forms
+func parseRRuleUntil(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t.In(loc), nil
+	}
+	if t, err := time.ParseInLocation("20060102T150405", value, loc); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("20060102", value, loc); err == nil {
+		// A date-only UNTIL includes the whole day
+		return t.Add(24*time.Hour - time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("UNTIL inválido: %s", value)
+}
+
+// parseRRuleWeekday parses BYDAY entries such as "MO", "2TU" or "-1FR"
+func parseRRuleWeekday(value string) (RecurrenceWeekday, error) {
+	value = strings.TrimSpace(value)
+	if len(value) < 2 {
+		return RecurrenceWeekday{}, fmt.Errorf("BYDAY inválido: %s", value)
+	}
+	code := value[len(value)-2:]
+	wd, ok := rruleWeekdays[code]
+	if !ok {
+		return RecurrenceWeekday{}, fmt.Errorf("BYDAY inválido: %s", value)
+	}
+	ordinal := 0
+	if prefix := value[:len(value)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return RecurrenceWeekday{}, fmt.Errorf("BYDAY inválido: %s", value)
+		}
+		ordinal = n
+	}
+	return RecurrenceWeekday{Weekday: wd, Ordinal: ordinal}, nil
+}
+
+// Expand returns the start times of every occurrence, beginning with dtstart
+// The time of day and location of dtstart are kept for all occurrences
+func (r *RecurrenceRule) Expand(dtstart time.Time) ([]time.Time, error) {
+	var occurrences []time.Time
+
+	// Candidate periods are walked in order; each period yields its dates sorted
+	// Stop after a generous number of empty periods to avoid infinite loops
+	// (e.g. BYMONTHDAY=31 with INTERVAL=2 starting in an even month)
+	emptyPeriods := 0
+	for period := 0; emptyPeriods < 60; period++ {
+		dates := r.periodDates(dtstart, period)
+		if len(dates) == 0 {
+			emptyPeriods++
+			continue
+		}
+		emptyPeriods = 0
+
+		for _, d := range dates {
+			if d.Before(dtstart) {
+				continue
+			}
+			if r.Until != nil && d.After(*r.Until) {
+				return occurrences, nil
+			}
+			occurrences = append(occurrences, d)
+			if r.Count > 0 && len(occurrences) >= r.Count {
+				return occurrences, nil
+			}
+			if len(occurrences) > MaxRecurrenceOccurrences {
+				return nil, fmt.Errorf("a série pode ter no máximo %d ocorrências", MaxRecurrenceOccurrences)
+			}
+		}
+	}
+
+	return occurrences, nil
+}
+
+// periodDates returns the sorted candidate dates for the n-th period of the rule
+func (r *RecurrenceRule) periodDates(dtstart time.Time, n int) []time.Time {
+	loc := dtstart.Location()
+	hour, min, sec := dtstart.Clock()
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, hour, min, sec, 0, loc)
+	}
+
+	var dates []time.Time
+	switch r.Freq {
+	case RecurrenceDaily:
+		d := dtstart.AddDate(0, 0, n*r.Interval)
+		dates = append(dates, at(d.Year(), d.Month(), d.Day()))
+
+	case RecurrenceWeekly:
+		// Weeks start on Monday (RFC 5545 default WKST)
+		offset := (int(dtstart.Weekday()) + 6) % 7
+		weekStart := dtstart.AddDate(0, 0, -offset+n*7*r.Interval)
+		days := r.ByDay
+		if len(days) == 0 {
+			days = []RecurrenceWeekday{{Weekday: dtstart.Weekday()}}
+		}
+		for _, wd := range days {
+			d := weekStart.AddDate(0, 0, (int(wd.Weekday)+6)%7)
+			dates = append(dates, at(d.Year(), d.Month(), d.Day()))
+		}
+
+	case RecurrenceMonthly:
+		first := time.Date(dtstart.Year(), dtstart.Month(), 1, 0, 0, 0, 0, loc).AddDate(0, n*r.Interval, 0)
+		lastDay := first.AddDate(0, 1, -1).Day()
+		if len(r.ByDay) > 0 {
+			for _, wd := range r.ByDay {
+				for _, day := range monthWeekdays(first, lastDay, wd) {
+					dates = append(dates, at(first.Year(), first.Month(), day))
+				}
+			}
+		} else {
+			monthDays := r.ByMonthDay
+			if len(monthDays) == 0 {
+				monthDays = []int{dtstart.Day()}
+			}
+			for _, md := range monthDays {
+				day := md
+				if md < 0 {
+					day = lastDay + md + 1
+				}
+				// Months without that day are skipped, as RFC 5545 specifies
+				if day < 1 || day > lastDay {
+					continue
+				}
+				dates = append(dates, at(first.Year(), first.Month(), day))
+			}
+		}
+
+	case RecurrenceYearly:
+		year := dtstart.Year() + n*r.Interval
+		d := at(year, dtstart.Month(), dtstart.Day())
+		// Feb 29 only occurs on leap years
+		if d.Month() == dtstart.Month() {
+			dates = append(dates, d)
+		}
+	}
+
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	return dates
+}
+
+// monthWeekdays returns the days of month matching a BYDAY entry
+func monthWeekdays(first time.Time, lastDay int, wd RecurrenceWeekday) []int {
+	var days []int
+	for day := 1; day <= lastDay; day++ {
+		if first.AddDate(0, 0, day-1).Weekday() == wd.Weekday {
+			days = append(days, day)
+		}
+	}
+	if wd.Ordinal == 0 {
+		return days
+	}
+	idx := wd.Ordinal - 1
+	if wd.Ordinal < 0 {
+		idx = len(days) + wd.Ordinal
+	}
+	if idx < 0 || idx >= len(days) {
+		return nil
+	}
+	return []int{days[idx]}
+}
@@ -0,0 +1,100 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+)
+
+func mustParseRule(t *testing.T, rule string) *RecurrenceRule {
+	t.Helper()
+	r, err := ParseRecurrenceRule(rule, time.UTC)
+	if err != nil {
+		t.Fatalf("ParseRecurrenceRule(%q) returned error: %v", rule, err)
+	}
+	return r
+}
+
+func TestRecurrenceWeeklyCount(t *testing.T) {
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC) // Monday
+	occ, err := mustParseRule(t, "RRULE:FREQ=WEEKLY;COUNT=12").Expand(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(occ) != 12 {
+		t.Fatalf("Expected 12 occurrences, got %d", len(occ))
+	}
+	if !occ[11].Equal(start.AddDate(0, 0, 77)) {
+		t.Errorf("Expected last occurrence on %v, got %v", start.AddDate(0, 0, 77), occ[11])
+	}
+}
+
+func TestRecurrenceWeeklyByDay(t *testing.T) {
+	start := time.Date(2026, 3, 4, 14, 30, 0, 0, time.UTC) // Wednesday
+	occ, err := mustParseRule(t, "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=4").Expand(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []time.Time{
+		time.Date(2026, 3, 4, 14, 30, 0, 0, time.UTC),
+		time.Date(2026, 3, 9, 14, 30, 0, 0, time.UTC),
+		time.Date(2026, 3, 11, 14, 30, 0, 0, time.UTC),
+		time.Date(2026, 3, 16, 14, 30, 0, 0, time.UTC),
+	}
+	if len(occ) != len(expected) {
+		t.Fatalf("Expected %d occurrences, got %d", len(expected), len(occ))
+	}
+	for i := range expected {
+		if !occ[i].Equal(expected[i]) {
+			t.Errorf("Occurrence %d: expected %v, got %v", i, expected[i], occ[i])
+		}
+	}
+}
+
+func TestRecurrenceMonthlySkipsShortMonths(t *testing.T) {
+	start := time.Date(2026, 1, 31, 8, 0, 0, 0, time.UTC)
+	occ, err := mustParseRule(t, "FREQ=MONTHLY;COUNT=3").Expand(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// February, April and June have no day 31
+	expected := []time.Month{time.January, time.March, time.May}
+	for i, m := range expected {
+		if occ[i].Month() != m || occ[i].Day() != 31 {
+			t.Errorf("Occurrence %d: expected 31 %s, got %v", i, m, occ[i])
+		}
+	}
+}
+
+func TestRecurrenceMonthlyOrdinalWeekday(t *testing.T) {
+	start := time.Date(2026, 1, 13, 10, 0, 0, 0, time.UTC) // 2nd Tuesday
+	occ, err := mustParseRule(t, "FREQ=MONTHLY;BYDAY=2TU;UNTIL=20260430").Expand(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedDays := []int{13, 10, 10, 14}
+	if len(occ) != len(expectedDays) {
+		t.Fatalf("Expected %d occurrences, got %d", len(expectedDays), len(occ))
+	}
+	for i, d := range expectedDays {
+		if occ[i].Day() != d {
+			t.Errorf("Occurrence %d: expected day %d, got %v", i, d, occ[i])
+		}
+	}
+}
+
+func TestRecurrenceRuleValidation(t *testing.T) {
+	invalid := []string{
+		"",
+		"FREQ=WEEKLY",
+		"FREQ=HOURLY;COUNT=3",
+		"FREQ=WEEKLY;COUNT=500",
+		"FREQ=WEEKLY;BYDAY=XX;COUNT=3",
+		"FREQ=DAILY;BYDAY=MO;COUNT=3",
+		"FREQ=WEEKLY;BYSETPOS=1;COUNT=3",
+	}
+	for _, rule := range invalid {
+		if _, err := ParseRecurrenceRule(rule, time.UTC); err == nil {
+			t.Errorf("Expected error for rule %q", rule)
+		}
+	}
+}
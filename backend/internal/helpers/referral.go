@@ -0,0 +1,63 @@
+package helpers
+
+import (
+	"drcrwell/backend/internal/models"
+	"math"
+	"time"
+)
+
+// referralFollowUpDays is how long the patient has to see the specialist, by urgency
+var referralFollowUpDays = map[string]int{
+	models.ReferralUrgencyUrgent:   7,
+	models.ReferralUrgencyPriority: 15,
+	models.ReferralUrgencyRoutine:  30,
+}
+
+// ReferralReportGraceDays is how long after the specialist appointment the report is expected
+const ReferralReportGraceDays = 15
+
+// referralTransitions lists the statuses a referral can move to
+var referralTransitions = map[string][]string{
+	models.ReferralStatusSent:      {models.ReferralStatusScheduled, models.ReferralStatusReportReceived, models.ReferralStatusCancelled},
+	models.ReferralStatusScheduled: {models.ReferralStatusScheduled, models.ReferralStatusSent, models.ReferralStatusReportReceived, models.ReferralStatusCancelled},
+}
+
+// CanChangeReferralStatus reports whether a referral may move from one status to another.
+// Scheduled referrals may be rescheduled or go back to sent (appointment cancelled by the specialist);
+// referrals with the report received or cancelled are closed
+func CanChangeReferralStatus(from, to string) bool {
+	for _, allowed := range referralTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ReferralFollowUpDate returns the date after which the referral is overdue: the deadline of the
+// urgency counted from the letter, or the report grace period after the specialist appointment
+func ReferralFollowUpDate(urgency string, sentAt time.Time, scheduledFor *time.Time) time.Time {
+	if scheduledFor != nil {
+		return dateOnly(*scheduledFor).AddDate(0, 0, ReferralReportGraceDays)
+	}
+	days, ok := referralFollowUpDays[urgency]
+	if !ok {
+		days = referralFollowUpDays[models.ReferralUrgencyRoutine]
+	}
+	return dateOnly(sentAt).AddDate(0, 0, days)
+}
+
+// ReferralDaysOverdue returns how many days the follow-up date has passed (0 when not overdue)
+func ReferralDaysOverdue(followUp, now time.Time) int {
+	// Rounded: days around daylight saving changes do not have 24 hours
+	days := int(math.Round(dateOnly(now).Sub(dateOnly(followUp)).Hours() / 24))
+	if days < 0 {
+		return 0
+	}
+	return days
+}
+
+// dateOnly drops the time of day, keeping the location
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,66 @@
+package helpers
+
+import (
+	"drcrwell/backend/internal/models"
+	"testing"
+	"time"
+)
+
+func TestReferralFollowUpDate(t *testing.T) {
+	sent := time.Date(2024, 3, 10, 15, 30, 0, 0, time.UTC)
+
+	cases := []struct {
+		urgency string
+		want    string
+	}{
+		{models.ReferralUrgencyUrgent, "2024-03-17"},
+		{models.ReferralUrgencyPriority, "2024-03-25"},
+		{models.ReferralUrgencyRoutine, "2024-04-09"},
+		{"unknown", "2024-04-09"},
+	}
+	for _, tc := range cases {
+		if got := ReferralFollowUpDate(tc.urgency, sent, nil).Format("2006-01-02"); got != tc.want {
+			t.Errorf("%s: expected %s, got %s", tc.urgency, tc.want, got)
+		}
+	}
+
+	// Once scheduled, the report is expected after the appointment whatever the urgency
+	appointment := time.Date(2024, 5, 2, 9, 0, 0, 0, time.UTC)
+	if got := ReferralFollowUpDate(models.ReferralUrgencyUrgent, sent, &appointment).Format("2006-01-02"); got != "2024-05-17" {
+		t.Errorf("Expected report due 2024-05-17, got %s", got)
+	}
+}
+
+func TestReferralDaysOverdue(t *testing.T) {
+	followUp := time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC)
+
+	if d := ReferralDaysOverdue(followUp, time.Date(2024, 3, 17, 23, 0, 0, 0, time.UTC)); d != 0 {
+		t.Errorf("Follow-up day itself is not overdue, got %d", d)
+	}
+	if d := ReferralDaysOverdue(followUp, time.Date(2024, 3, 20, 8, 0, 0, 0, time.UTC)); d != 3 {
+		t.Errorf("Expected 3 days overdue, got %d", d)
+	}
+	if d := ReferralDaysOverdue(followUp, time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)); d != 0 {
+		t.Errorf("Future follow-up must not be overdue, got %d", d)
+	}
+}
+
+func TestCanChangeReferralStatus(t *testing.T) {
+	cases := []struct {
+		from, to string
+		want     bool
+	}{
+		{models.ReferralStatusSent, models.ReferralStatusScheduled, true},
+		{models.ReferralStatusSent, models.ReferralStatusReportReceived, true},
+		{models.ReferralStatusSent, models.ReferralStatusSent, false},
+		{models.ReferralStatusScheduled, models.ReferralStatusScheduled, true},
+		{models.ReferralStatusScheduled, models.ReferralStatusSent, true},
+		{models.ReferralStatusReportReceived, models.ReferralStatusScheduled, false},
+		{models.ReferralStatusCancelled, models.ReferralStatusSent, false},
+	}
+	for _, tc := range cases {
+		if got := CanChangeReferralStatus(tc.from, tc.to); got != tc.want {
+			t.Errorf("%s -> %s: expected %v, got %v", tc.from, tc.to, tc.want, got)
+		}
+	}
+}
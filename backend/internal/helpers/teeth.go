@@ -0,0 +1,91 @@
+package helpers
+
+import "strings"
+
+// Dentitions of a tooth in FDI notation
+const (
+	DentitionPermanent = "permanent"
+	DentitionDeciduous = "deciduous"
+)
+
+// Chart rows in FDI notation, in the order they are drawn (patient's right on the left)
+var (
+	PermanentUpperTeeth = []int{18, 17, 16, 15, 14, 13, 12, 11, 21, 22, 23, 24, 25, 26, 27, 28}
+	PermanentLowerTeeth = []int{48, 47, 46, 45, 44, 43, 42, 41, 31, 32, 33, 34, 35, 36, 37, 38}
+	DeciduousUpperTeeth = []int{55, 54, 53, 52, 51, 61, 62, 63, 64, 65}
+	DeciduousLowerTeeth = []int{85, 84, 83, 82, 81, 71, 72, 73, 74, 75}
+)
+
+// IsValidFDITooth reports whether n is a tooth in FDI (ISO 3950) notation
+// Quadrants 1-4 hold permanent teeth 1-8; quadrants 5-8 hold deciduous teeth 1-5
+func IsValidFDITooth(n int) bool {
+	quadrant, position := n/10, n%10
+	switch {
+	case quadrant >= 1 && quadrant <= 4:
+		return position >= 1 && position <= 8
+	case quadrant >= 5 && quadrant <= 8:
+		return position >= 1 && position <= 5
+	}
+	return false
+}
+
+// ToothDentition returns whether an FDI tooth is permanent or deciduous
+func ToothDentition(n int) string {
+	if n/10 >= 5 {
+		return DentitionDeciduous
+	}
+	return DentitionPermanent
+}
+
+// IsUpperTooth reports whether an FDI tooth belongs to the maxilla
+func IsUpperTooth(n int) bool {
+	switch n / 10 {
+	case 1, 2, 5, 6:
+		return true
+	}
+	return false
+}
+
+// IsPatientRightTooth reports whether an FDI tooth is on the patient's right side
+// (drawn on the left of a chart, with the mesial surface facing right)
+func IsPatientRightTooth(n int) bool {
+	switch n / 10 {
+	case 1, 4, 5, 8:
+		return true
+	}
+	return false
+}
+
+// Tooth surfaces in the order clinicians write them ("MOD"):
+// Mesial, Occlusal/Incisal, Distal, Vestibular, Lingual/Palatal
+const ToothSurfaces = "MODVL"
+
+// surfaceAliases accepts the Portuguese/English letters clinicians also use
+var surfaceAliases = map[rune]rune{
+	'I': 'O', // Incisal
+	'P': 'L', // Palatina
+	'B': 'V', // Buccal
+}
+
+// NormalizeToothSurfaces validates a surface combination ("mod", "OV", "ip") and returns it
+// uppercase, without duplicates and in ToothSurfaces order. Empty means the whole tooth
+func NormalizeToothSurfaces(surfaces string) (string, bool) {
+	present := make(map[rune]bool)
+	for _, r := range strings.ToUpper(strings.TrimSpace(surfaces)) {
+		if alias, ok := surfaceAliases[r]; ok {
+			r = alias
+		}
+		if !strings.ContainsRune(ToothSurfaces, r) {
+			return "", false
+		}
+		present[r] = true
+	}
+
+	var sb strings.Builder
+	for _, r := range ToothSurfaces {
+		if present[r] {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String(), true
+}
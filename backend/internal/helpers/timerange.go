@@ -0,0 +1,87 @@
+package helpers
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// TimeRange is a half-open interval [Start, End)
+type TimeRange struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Contains reports whether [start, end) lies entirely inside the range
+func (r TimeRange) Contains(start, end time.Time) bool {
+	return !start.Before(r.Start) && !end.After(r.End)
+}
+
+// Overlaps reports whether [start, end) intersects the range
+func (r TimeRange) Overlaps(start, end time.Time) bool {
+	return start.Before(r.End) && end.After(r.Start)
+}
+
+// ParseClock parses "HH:MM" and returns that time of day on the given date (in the date's location)
+func ParseClock(date time.Time, clock string) (time.Time, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("horário inválido: %s (use HH:MM)", clock)
+	}
+	return time.Date(date.Year(), date.Month(), date.Day(), t.Hour(), t.Minute(), 0, 0, date.Location()), nil
+}
+
+// MergeTimeRanges sorts the ranges and joins the ones that overlap or touch
+func MergeTimeRanges(ranges []TimeRange) []TimeRange {
+	sorted := make([]TimeRange, 0, len(ranges))
+	for _, r := range ranges {
+		if r.End.After(r.Start) {
+			sorted = append(sorted, r)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	var merged []TimeRange
+	for _, r := range sorted {
+		if n := len(merged); n > 0 && !r.Start.After(merged[n-1].End) {
+			if r.End.After(merged[n-1].End) {
+				merged[n-1].End = r.End
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// SubtractTimeRanges removes every range in remove from ranges
+func SubtractTimeRanges(ranges, remove []TimeRange) []TimeRange {
+	result := MergeTimeRanges(ranges)
+	for _, cut := range MergeTimeRanges(remove) {
+		var next []TimeRange
+		for _, r := range result {
+			if !r.Overlaps(cut.Start, cut.End) {
+				next = append(next, r)
+				continue
+			}
+			if r.Start.Before(cut.Start) {
+				next = append(next, TimeRange{Start: r.Start, End: cut.Start})
+			}
+			if r.End.After(cut.End) {
+				next = append(next, TimeRange{Start: cut.End, End: r.End})
+			}
+		}
+		result = next
+	}
+	return result
+}
+
+// TimeRangesContain reports whether [start, end) fits entirely inside one of the ranges
+func TimeRangesContain(ranges []TimeRange, start, end time.Time) bool {
+	for _, r := range MergeTimeRanges(ranges) {
+		if r.Contains(start, end) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+)
+
+func clock(h, m int) time.Time {
+	return time.Date(2026, 3, 2, h, m, 0, 0, time.UTC)
+}
+
+func TestSubtractTimeRanges(t *testing.T) {
+	working := []TimeRange{
+		{Start: clock(8, 0), End: clock(12, 0)},
+		{Start: clock(13, 0), End: clock(18, 0)},
+	}
+	absences := []TimeRange{
+		{Start: clock(10, 0), End: clock(14, 0)},
+	}
+
+	result := SubtractTimeRanges(working, absences)
+	expected := []TimeRange{
+		{Start: clock(8, 0), End: clock(10, 0)},
+		{Start: clock(14, 0), End: clock(18, 0)},
+	}
+	if len(result) != len(expected) {
+		t.Fatalf("Expected %d ranges, got %d: %v", len(expected), len(result), result)
+	}
+	for i := range expected {
+		if !result[i].Start.Equal(expected[i].Start) || !result[i].End.Equal(expected[i].End) {
+			t.Errorf("Range %d: expected %v, got %v", i, expected[i], result[i])
+		}
+	}
+}
+
+func TestSubtractTimeRangesWholeDay(t *testing.T) {
+	working := []TimeRange{{Start: clock(8, 0), End: clock(18, 0)}}
+	absences := []TimeRange{{Start: clock(0, 0), End: clock(23, 59)}}
+
+	if result := SubtractTimeRanges(working, absences); len(result) != 0 {
+		t.Errorf("Expected no ranges left, got %v", result)
+	}
+}
+
+func TestMergeTimeRanges(t *testing.T) {
+	merged := MergeTimeRanges([]TimeRange{
+		{Start: clock(13, 0), End: clock(15, 0)},
+		{Start: clock(8, 0), End: clock(10, 0)},
+		{Start: clock(10, 0), End: clock(12, 0)},
+		{Start: clock(14, 0), End: clock(16, 0)},
+	})
+	if len(merged) != 2 {
+		t.Fatalf("Expected 2 ranges, got %d: %v", len(merged), merged)
+	}
+	if !merged[0].End.Equal(clock(12, 0)) || !merged[1].End.Equal(clock(16, 0)) {
+		t.Errorf("Unexpected merge result: %v", merged)
+	}
+}
+
+func TestTimeRangesContain(t *testing.T) {
+	ranges := []TimeRange{
+		{Start: clock(8, 0), End: clock(12, 0)},
+		{Start: clock(13, 0), End: clock(18, 0)},
+	}
+
+	if !TimeRangesContain(ranges, clock(11, 30), clock(12, 0)) {
+		t.Error("Expected slot ending at range end to fit")
+	}
+	if TimeRangesContain(ranges, clock(11, 30), clock(13, 30)) {
+		t.Error("Expected slot spanning the gap not to fit")
+	}
+	if TimeRangesContain(ranges, clock(7, 30), clock(8, 30)) {
+		t.Error("Expected slot starting before the range not to fit")
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	date := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	got, err := ParseClock(date, "14:30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(clock(14, 30)) {
+		t.Errorf("Expected %v, got %v", clock(14, 30), got)
+	}
+	if _, err := ParseClock(date, "25:00"); err == nil {
+		t.Error("Expected error for invalid clock")
+	}
+}
@@ -0,0 +1,577 @@
+package helpers
+
+import (
+	"bytes"
+	"crypto/md5"
+	"drcrwell/backend/internal/models"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// TISSVersion is the version of the ANS TISS standard the lots are generated in
+const TISSVersion = "4.01.00"
+
+// TISSMaxGuidesPerLot is the most guides the standard accepts in a lot
+const TISSMaxGuidesPerLot = 100
+
+const (
+	tissNamespace       = "http://www.ans.gov.br/padroes/tiss/schemas"
+	tissTransactionType = "ENVIO_LOTE_GUIAS"
+	tissTUSSTable       = "22"     // TUSS - procedimentos e eventos em saúde
+	tissCouncilCRO      = "08"     // Conselho profissional: CRO
+	tissCBOSDentist     = "223208" // CBO-S: cirurgião-dentista clínico geral
+	tissServiceDental   = "1"      // Tipo de atendimento: tratamento odontológico
+	tissMaxNameLength   = 70
+)
+
+// tissUFCodes are the IBGE codes of the states, used by TISS for the council state
+var tissUFCodes = map[string]string{
+	"RO": "11", "AC": "12", "AM": "13", "RR": "14", "PA": "15", "AP": "16", "TO": "17",
+	"MA": "21", "PI": "22", "CE": "23", "RN": "24", "PB": "25", "PE": "26", "AL": "27", "SE": "28", "BA": "29",
+	"MG": "31", "ES": "32", "RJ": "33", "SP": "35",
+	"PR": "41", "SC": "42", "RS": "43",
+	"MS": "50", "MT": "51", "GO": "52", "DF": "53",
+}
+
+var (
+	tissDigitsPattern    = regexp.MustCompile(`^[0-9]+$`)
+	tissDatePattern      = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	tissTimePattern      = regexp.MustCompile(`^\d{2}:\d{2}:\d{2}$`)
+	tissMoneyPattern     = regexp.MustCompile(`^\d{1,8}\.\d{2}$`)
+	tissToothFacePattern = regexp.MustCompile(`^[MDOVLIP]{1,5}$`)
+	tissANSPattern       = regexp.MustCompile(`^\d{6}$`)
+	tissUFPattern        = regexp.MustCompile(`^\d{2}$`)
+	tissCBOSPattern      = regexp.MustCompile(`^\d{6}$`)
+	tissTUSSPattern      = regexp.MustCompile(`^\d{8}$`)
+	tissCNPJPattern      = regexp.MustCompile(`^\d{14}$`)
+)
+
+// TISSProvider identifies the clinic in the messages: by its code at the insurer or by its CNPJ
+type TISSProvider struct {
+	Code string
+	CNPJ string
+	Name string
+}
+
+// ParseCRO splits a CRO registration such as "CRO-SP 12345" or "12345/SP" into number and state
+func ParseCRO(cro string) (number, uf string) {
+	cro = strings.ReplaceAll(strings.ToUpper(cro), "CRO", "")
+	var digits, letters strings.Builder
+	for _, r := range cro {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r >= 'A' && r <= 'Z':
+			letters.WriteRune(r)
+		}
+	}
+	if _, ok := tissUFCodes[letters.String()]; ok {
+		uf = letters.String()
+	}
+	return digits.String(), uf
+}
+
+type tissProviderID struct {
+	ProviderCode string `xml:"ans:codigoPrestadorNaOperadora,omitempty"`
+	CNPJ         string `xml:"ans:CNPJ,omitempty"`
+}
+
+type tissMessage struct {
+	XMLName  xml.Name     `xml:"ans:mensagemTISS"`
+	XMLNS    string       `xml:"xmlns:ans,attr"`
+	Header   tissHeader   `xml:"ans:cabecalho"`
+	Body     tissLotBody  `xml:"ans:prestadorParaOperadora"`
+	Epilogue tissEpilogue `xml:"ans:epilogo"`
+}
+
+type tissHeader struct {
+	Transaction struct {
+		Type     string `xml:"ans:tipoTransacao"`
+		Sequence string `xml:"ans:sequencialTransacao"`
+		Date     string `xml:"ans:dataRegistroTransacao"`
+		Time     string `xml:"ans:horaRegistroTransacao"`
+	} `xml:"ans:identificacaoTransacao"`
+	Origin struct {
+		Provider tissProviderID `xml:"ans:identificacaoPrestador"`
+	} `xml:"ans:origem"`
+	Destination struct {
+		ANSRegistry string `xml:"ans:registroANS"`
+	} `xml:"ans:destino"`
+	Version string `xml:"ans:Padrao"`
+}
+
+type tissLotBody struct {
+	Lot struct {
+		Number string      `xml:"ans:numeroLote"`
+		Guides []tissGuide `xml:"ans:guiasTISS>ans:guiaOdontologia"`
+	} `xml:"ans:loteGuias"`
+}
+
+type tissGuide struct {
+	Header struct {
+		ANSRegistry string `xml:"ans:registroANS"`
+		GuideNumber string `xml:"ans:numeroGuiaPrestador"`
+	} `xml:"ans:cabecalhoGuia"`
+	AuthorizationNumber string `xml:"ans:senhaAutorizacao,omitempty"`
+	Beneficiary         struct {
+		CardNumber string `xml:"ans:numeroCarteira"`
+		Newborn    string `xml:"ans:atendimentoRN"`
+		Name       string `xml:"ans:nomeBeneficiario"`
+	} `xml:"ans:dadosBeneficiario"`
+	Professional struct {
+		Name          string `xml:"ans:nomeProfissional"`
+		Council       string `xml:"ans:conselhoProfissional"`
+		CouncilNumber string `xml:"ans:numeroConselhoProfissional"`
+		UF            string `xml:"ans:UF"`
+		CBOS          string `xml:"ans:CBOS"`
+	} `xml:"ans:dadosProfissionaisResponsaveis"`
+	Executor struct {
+		Provider tissProviderID `xml:"ans:codigoContratado"`
+		Name     string         `xml:"ans:nomeContratado"`
+	} `xml:"ans:contratadoExecutante"`
+	Procedures  []tissProcedure `xml:"ans:procedimentosExecutados>ans:procedimentoExecutado"`
+	EndDate     string          `xml:"ans:dataTerminoTrat,omitempty"`
+	ServiceType string          `xml:"ans:tipoAtendimento"`
+	Total       string          `xml:"ans:valorTotalProc"`
+	Notes       string          `xml:"ans:observacao,omitempty"`
+}
+
+type tissProcedure struct {
+	Sequence  int `xml:"ans:sequencialItem"`
+	Procedure struct {
+		Table       string `xml:"ans:codigoTabela"`
+		Code        string `xml:"ans:codigoProcedimento"`
+		Description string `xml:"ans:descricaoProcedimento"`
+	} `xml:"ans:procSolic"`
+	Tooth    *tissToothRegion `xml:"ans:denteRegiao,omitempty"`
+	Surfaces string           `xml:"ans:denteFace,omitempty"`
+	Quantity int              `xml:"ans:qtdProc"`
+	Value    string           `xml:"ans:valorProc"`
+	Date     string           `xml:"ans:dataRealizacao,omitempty"`
+}
+
+// tissToothRegion is left out of procedures not done on a tooth, as the schema requires a code inside it
+type tissToothRegion struct {
+	Tooth string `xml:"ans:codDente"`
+}
+
+type tissEpilogue struct {
+	Hash string `xml:"ans:hash"`
+}
+
+// tissText trims a value and cuts it to the size of the field
+func tissText(text string, max int) string {
+	runes := []rune(strings.TrimSpace(text))
+	if len(runes) > max {
+		runes = runes[:max]
+	}
+	return strings.TrimSpace(string(runes))
+}
+
+// tissMoney formats a value the way TISS expects (dot as decimal separator, two decimals)
+func tissMoney(value float64) string {
+	return strconv.FormatFloat(roundMoney(value), 'f', 2, 64)
+}
+
+// BuildTISSLot generates the TISS message sending a lot of dental treatment guides (GTO) to an
+// insurer, with its hash in the epilogue. The card numbers of the guides must be decrypted.
+// Returns the XML, in ISO-8859-1, and the hash
+func BuildTISSLot(insurance models.InsuranceCompany, provider TISSProvider, lot models.InsuranceLot, guides []models.InsuranceGuide, now time.Time) ([]byte, string, error) {
+	providerID := tissProviderID{ProviderCode: tissText(provider.Code, 14)}
+	if providerID.ProviderCode == "" {
+		providerID.CNPJ = onlyDigits(provider.CNPJ)
+	}
+
+	message := tissMessage{XMLNS: tissNamespace}
+	message.Header.Transaction.Type = tissTransactionType
+	message.Header.Transaction.Sequence = lot.LotNumber
+	message.Header.Transaction.Date = now.Format("2006-01-02")
+	message.Header.Transaction.Time = now.Format("15:04:05")
+	message.Header.Origin.Provider = providerID
+	message.Header.Destination.ANSRegistry = insurance.ANSRegistry
+	message.Header.Version = TISSVersion
+	message.Body.Lot.Number = lot.LotNumber
+
+	for _, guide := range guides {
+		var g tissGuide
+		g.Header.ANSRegistry = insurance.ANSRegistry
+		g.Header.GuideNumber = guide.GuideNumber
+		g.AuthorizationNumber = tissText(guide.AuthorizationNumber, 20)
+		g.Beneficiary.CardNumber = tissText(guide.CardNumber, 20)
+		g.Beneficiary.Newborn = "N"
+		g.Beneficiary.Name = tissText(guide.BeneficiaryName, tissMaxNameLength)
+
+		croNumber, croUF := ParseCRO(guide.DentistCRO)
+		g.Professional.Name = tissText(guide.DentistName, tissMaxNameLength)
+		g.Professional.Council = tissCouncilCRO
+		g.Professional.CouncilNumber = croNumber
+		g.Professional.UF = tissUFCodes[croUF]
+		g.Professional.CBOS = tissCBOSDentist
+		g.Executor.Provider = providerID
+		g.Executor.Name = tissText(provider.Name, tissMaxNameLength)
+
+		var endDate time.Time
+		total := 0.0
+		for i, item := range guide.Items {
+			var p tissProcedure
+			p.Sequence = i + 1
+			p.Procedure.Table = tissTUSSTable
+			p.Procedure.Code = item.ProcedureCode
+			p.Procedure.Description = tissText(item.Description, 150)
+			if item.Tooth != nil {
+				p.Tooth = &tissToothRegion{Tooth: strconv.Itoa(*item.Tooth)}
+			}
+			p.Surfaces = item.Surfaces
+			p.Quantity = item.Quantity
+			p.Value = tissMoney(item.Total)
+			if item.ExecutionDate != nil {
+				p.Date = item.ExecutionDate.Format("2006-01-02")
+				if item.ExecutionDate.After(endDate) {
+					endDate = *item.ExecutionDate
+				}
+			}
+			g.Procedures = append(g.Procedures, p)
+			total += item.Total
+		}
+		if !endDate.IsZero() {
+			g.EndDate = endDate.Format("2006-01-02")
+		}
+		g.ServiceType = tissServiceDental
+		g.Total = tissMoney(total)
+		g.Notes = tissText(guide.Notes, 500)
+		message.Body.Lot.Guides = append(message.Body.Lot.Guides, g)
+	}
+
+	data, err := marshalTISS(message)
+	if err != nil {
+		return nil, "", err
+	}
+	hash, err := TISSHash(data)
+	if err != nil {
+		return nil, "", err
+	}
+	message.Epilogue.Hash = hash
+	data, err = marshalTISS(message)
+	return data, hash, err
+}
+
+// marshalTISS renders a message in ISO-8859-1, the encoding of the TISS files
+func marshalTISS(message tissMessage) ([]byte, error) {
+	body, err := xml.MarshalIndent(message, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	doc := `<?xml version="1.0" encoding="ISO-8859-1"?>` + "\n" + string(body) + "\n"
+	return toLatin1(doc), nil
+}
+
+// toLatin1 encodes a text in ISO-8859-1, replacing the characters it does not have
+func toLatin1(text string) []byte {
+	out := make([]byte, 0, len(text))
+	for _, r := range text {
+		if r > unicode.MaxLatin1 {
+			r = '?'
+		}
+		out = append(out, byte(r))
+	}
+	return out
+}
+
+// newTISSDecoder reads TISS files in ISO-8859-1 or UTF-8
+func newTISSDecoder(data []byte) *xml.Decoder {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		switch strings.ToUpper(charset) {
+		case "ISO-8859-1", "ISO8859-1", "LATIN1":
+			raw, err := io.ReadAll(input)
+			if err != nil {
+				return nil, err
+			}
+			runes := make([]rune, len(raw))
+			for i, b := range raw {
+				runes[i] = rune(b)
+			}
+			return strings.NewReader(string(runes)), nil
+		case "UTF-8":
+			return input, nil
+		}
+		return nil, fmt.Errorf("unsupported charset: %s", charset)
+	}
+	return decoder
+}
+
+// TISSHash returns the hash of a TISS message: the MD5 of the contents of all its elements, except
+// the hash itself, concatenated in document order and encoded in ISO-8859-1
+func TISSHash(data []byte) (string, error) {
+	decoder := newTISSDecoder(data)
+	var content strings.Builder
+	inHash := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			inHash = t.Name.Local == "hash"
+		case xml.EndElement:
+			inHash = false
+		case xml.CharData:
+			if !inHash {
+				content.WriteString(strings.TrimSpace(string(t)))
+			}
+		}
+	}
+	sum := md5.Sum(toLatin1(content.String()))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// tissNode is an element of a parsed TISS message
+type tissNode struct {
+	name     string
+	text     string
+	children []*tissNode
+}
+
+// child returns the first child element with the name, or nil
+func (n *tissNode) child(name string) *tissNode {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// path walks down the first children with the names
+func (n *tissNode) path(names ...string) *tissNode {
+	for _, name := range names {
+		n = n.child(name)
+	}
+	return n
+}
+
+// value returns the text of the element at the path, or "" when it is missing
+func (n *tissNode) value(names ...string) string {
+	if node := n.path(names...); node != nil {
+		return node.text
+	}
+	return ""
+}
+
+// all returns the children elements with the name
+func (n *tissNode) all(name string) []*tissNode {
+	var nodes []*tissNode
+	if n == nil {
+		return nodes
+	}
+	for _, c := range n.children {
+		if c.name == name {
+			nodes = append(nodes, c)
+		}
+	}
+	return nodes
+}
+
+// parseTISSTree parses a TISS message into its elements, ignoring namespace prefixes
+func parseTISSTree(data []byte) (*tissNode, error) {
+	decoder := newTISSDecoder(data)
+	var root *tissNode
+	var stack []*tissNode
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &tissNode{name: t.Name.Local}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, node)
+			} else if root == nil {
+				root = node
+			}
+			stack = append(stack, node)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += strings.TrimSpace(string(t))
+			}
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("empty document")
+	}
+	return root, nil
+}
+
+// tissRequire adds a problem when a value is missing, longer than max or does not match the pattern
+func tissRequire(problems *[]string, label, value string, max int, pattern *regexp.Regexp) {
+	switch {
+	case value == "":
+		*problems = append(*problems, label+" é obrigatório")
+	case len([]rune(value)) > max:
+		*problems = append(*problems, fmt.Sprintf("%s excede %d caracteres", label, max))
+	case pattern != nil && !pattern.MatchString(value):
+		*problems = append(*problems, label+" em formato inválido: "+value)
+	}
+}
+
+// ValidateTISSLot checks a lot message against the bundled ANS XSD (see ValidateTISSSchema) and the
+// rules of the TISS standard for the elements the clinic sends, and verifies its hash. Returns the
+// problems found (in Portuguese), none when valid, or an error when the schema validation could not run
+func ValidateTISSLot(data []byte) ([]string, error) {
+	root, err := parseTISSTree(data)
+	if err != nil {
+		return []string{"XML inválido: " + err.Error()}, nil
+	}
+	if root.name != "mensagemTISS" {
+		return []string{"O elemento raiz deve ser mensagemTISS"}, nil
+	}
+	problems, err := ValidateTISSSchema(data)
+	if err != nil {
+		return nil, err
+	}
+	if problems == nil {
+		problems = []string{}
+	}
+
+	header := root.child("cabecalho")
+	if header == nil {
+		return append(problems, "Cabeçalho da mensagem ausente"), nil
+	}
+	if t := header.value("identificacaoTransacao", "tipoTransacao"); t != tissTransactionType {
+		problems = append(problems, "Tipo de transação deve ser "+tissTransactionType)
+	}
+	tissRequire(&problems, "Sequencial da transação", header.value("identificacaoTransacao", "sequencialTransacao"), 12, tissDigitsPattern)
+	tissRequire(&problems, "Data da transação", header.value("identificacaoTransacao", "dataRegistroTransacao"), 10, tissDatePattern)
+	tissRequire(&problems, "Hora da transação", header.value("identificacaoTransacao", "horaRegistroTransacao"), 8, tissTimePattern)
+	validateTISSProvider(&problems, "Prestador de origem", header.path("origem", "identificacaoPrestador"))
+	ansRegistry := header.value("destino", "registroANS")
+	tissRequire(&problems, "Registro ANS da operadora", ansRegistry, 6, tissANSPattern)
+	if version := header.value("Padrao"); version != TISSVersion {
+		problems = append(problems, fmt.Sprintf("Versão do padrão TISS deve ser %s (encontrada: %s)", TISSVersion, version))
+	}
+
+	lot := root.path("prestadorParaOperadora", "loteGuias")
+	if lot == nil {
+		return append(problems, "Lote de guias ausente"), nil
+	}
+	tissRequire(&problems, "Número do lote", lot.value("numeroLote"), 12, tissDigitsPattern)
+	guides := lot.child("guiasTISS").all("guiaOdontologia")
+	if len(guides) == 0 {
+		problems = append(problems, "O lote não tem guias")
+	}
+	if len(guides) > TISSMaxGuidesPerLot {
+		problems = append(problems, fmt.Sprintf("O lote excede %d guias", TISSMaxGuidesPerLot))
+	}
+
+	for i, guide := range guides {
+		var guideProblems []string
+		if guide.value("cabecalhoGuia", "registroANS") != ansRegistry {
+			guideProblems = append(guideProblems, "registro ANS difere do cabeçalho da mensagem")
+		}
+		tissRequire(&guideProblems, "número da guia", guide.value("cabecalhoGuia", "numeroGuiaPrestador"), 20, nil)
+		if password := guide.value("senhaAutorizacao"); len([]rune(password)) > 20 {
+			guideProblems = append(guideProblems, "senha de autorização excede 20 caracteres")
+		}
+		tissRequire(&guideProblems, "número da carteira", guide.value("dadosBeneficiario", "numeroCarteira"), 20, nil)
+		if rn := guide.value("dadosBeneficiario", "atendimentoRN"); rn != "S" && rn != "N" {
+			guideProblems = append(guideProblems, "atendimento RN deve ser S ou N")
+		}
+		tissRequire(&guideProblems, "nome do beneficiário", guide.value("dadosBeneficiario", "nomeBeneficiario"), tissMaxNameLength, nil)
+
+		professional := guide.child("dadosProfissionaisResponsaveis")
+		tissRequire(&guideProblems, "nome do profissional", professional.value("nomeProfissional"), tissMaxNameLength, nil)
+		if professional.value("conselhoProfissional") != tissCouncilCRO {
+			guideProblems = append(guideProblems, "conselho profissional deve ser CRO ("+tissCouncilCRO+")")
+		}
+		tissRequire(&guideProblems, "número do CRO", professional.value("numeroConselhoProfissional"), 15, tissDigitsPattern)
+		tissRequire(&guideProblems, "UF do CRO", professional.value("UF"), 2, tissUFPattern)
+		tissRequire(&guideProblems, "CBO-S", professional.value("CBOS"), 6, tissCBOSPattern)
+		validateTISSProvider(&guideProblems, "contratado executante", guide.path("contratadoExecutante", "codigoContratado"))
+
+		procedures := guide.child("procedimentosExecutados").all("procedimentoExecutado")
+		if len(procedures) == 0 {
+			guideProblems = append(guideProblems, "nenhum procedimento")
+		}
+		var total float64
+		for j, procedure := range procedures {
+			label := fmt.Sprintf("procedimento %d: ", j+1)
+			if procedure.value("procSolic", "codigoTabela") != tissTUSSTable {
+				guideProblems = append(guideProblems, label+"tabela deve ser TUSS ("+tissTUSSTable+")")
+			}
+			tissRequire(&guideProblems, label+"código TUSS", procedure.value("procSolic", "codigoProcedimento"), 8, tissTUSSPattern)
+			tissRequire(&guideProblems, label+"descrição", procedure.value("procSolic", "descricaoProcedimento"), 150, nil)
+			if tooth := procedure.value("denteRegiao", "codDente"); tooth != "" {
+				if n, err := strconv.Atoi(tooth); err != nil || !IsValidFDITooth(n) {
+					guideProblems = append(guideProblems, label+"dente inválido: "+tooth)
+				}
+			}
+			if face := procedure.value("denteFace"); face != "" && !tissToothFacePattern.MatchString(face) {
+				guideProblems = append(guideProblems, label+"face inválida: "+face)
+			}
+			if quantity, err := strconv.Atoi(procedure.value("qtdProc")); err != nil || quantity < 1 {
+				guideProblems = append(guideProblems, label+"quantidade inválida")
+			}
+			value := procedure.value("valorProc")
+			tissRequire(&guideProblems, label+"valor", value, 11, tissMoneyPattern)
+			parsed, _ := strconv.ParseFloat(value, 64)
+			total += parsed
+			if date := procedure.value("dataRealizacao"); date != "" && !tissDatePattern.MatchString(date) {
+				guideProblems = append(guideProblems, label+"data de realização inválida")
+			}
+		}
+		if guide.value("tipoAtendimento") == "" {
+			guideProblems = append(guideProblems, "tipo de atendimento é obrigatório")
+		}
+		if guide.value("valorTotalProc") != tissMoney(total) {
+			guideProblems = append(guideProblems, "valor total difere da soma dos procedimentos")
+		}
+
+		for _, problem := range guideProblems {
+			problems = append(problems, fmt.Sprintf("Guia %d: %s", i+1, problem))
+		}
+	}
+
+	hash := root.value("epilogo", "hash")
+	expected, err := TISSHash(data)
+	if err != nil || hash != expected {
+		problems = append(problems, "Hash do epílogo não confere com o conteúdo da mensagem")
+	}
+	return problems, nil
+}
+
+// validateTISSProvider checks that a provider is identified by its code at the insurer or its CNPJ
+func validateTISSProvider(problems *[]string, label string, provider *tissNode) {
+	code := provider.value("codigoPrestadorNaOperadora")
+	cnpj := provider.value("CNPJ")
+	switch {
+	case code != "":
+		tissRequire(problems, label+": código na operadora", code, 14, nil)
+	case cnpj != "":
+		tissRequire(problems, label+": CNPJ", cnpj, 14, tissCNPJPattern)
+	default:
+		*problems = append(*problems, label+": informe o código na operadora ou o CNPJ")
+	}
+}
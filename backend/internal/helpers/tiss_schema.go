@@ -0,0 +1,82 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// tissSchemaFile is the main XSD of the TISS version, which includes the complex and simple types
+// and the guides of the same ANS package
+const tissSchemaFile = "tissV4_01_00.xsd"
+
+// ErrTISSSchemaUnavailable is returned when the ANS XSD files are missing or xmllint is not installed.
+// Lots are never reported valid without the schema, so callers treat it as a server error
+var ErrTISSSchemaUnavailable = errors.New("TISS schema not available")
+
+// tissSchemaPath returns the main XSD in TISS_SCHEMA_DIR (./schemas/tiss by default)
+func tissSchemaPath() string {
+	dir := os.Getenv("TISS_SCHEMA_DIR")
+	if dir == "" {
+		dir = "./schemas/tiss"
+	}
+	return filepath.Join(dir, tissSchemaFile)
+}
+
+// ValidateTISSSchema validates a TISS message against the bundled ANS XSD with xmllint (libxml2).
+// Returns the schema violations (in Portuguese), none when valid, or an error when the schema
+// could not be loaded or xmllint failed
+func ValidateTISSSchema(data []byte) ([]string, error) {
+	schema := tissSchemaPath()
+	if _, err := os.Stat(schema); err != nil {
+		return nil, ErrTISSSchemaUnavailable
+	}
+	path, err := exec.LookPath("xmllint")
+	if err != nil {
+		return nil, ErrTISSSchemaUnavailable
+	}
+
+	dir, err := os.MkdirTemp("", "tiss-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	in := filepath.Join(dir, "lote.xml")
+	if err := os.WriteFile(in, data, 0600); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), converterTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, path, "--noout", "--nonet", "--schema", schema, in).CombinedOutput()
+	if err == nil {
+		return nil, nil
+	}
+	var exitErr *exec.ExitError
+	// Exit code 3 is a validation error; others mean the schema could not be loaded or the tool failed
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		return nil, fmt.Errorf("xmllint failed: %v: %s", err, output)
+	}
+
+	// Lines look like "[file]:12: Schemas validity error : Element '{namespace}name': message"
+	problems := []string{}
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(strings.TrimPrefix(line, in+":"))
+		if line == "" || strings.HasSuffix(line, "fails to validate") {
+			continue
+		}
+		line = strings.ReplaceAll(line, "{"+tissNamespace+"}", "ans:")
+		if number, message, found := strings.Cut(line, ": Schemas validity error : "); found {
+			line = fmt.Sprintf("linha %s: %s", number, message)
+		}
+		problems = append(problems, "Esquema TISS, "+line)
+	}
+	if len(problems) == 0 {
+		problems = append(problems, "Esquema TISS: a mensagem não é válida")
+	}
+	return problems, nil
+}
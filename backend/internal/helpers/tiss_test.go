@@ -0,0 +1,197 @@
+package helpers
+
+import (
+	"bytes"
+	"drcrwell/backend/internal/models"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func tissTestLot(t *testing.T, mutate func(guide *models.InsuranceGuide)) ([]byte, string) {
+	t.Helper()
+	tooth := 16
+	executed := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	guide := models.InsuranceGuide{
+		GuideNumber:     "000001",
+		CardNumber:      "0012345678901",
+		BeneficiaryName: "José da Silva",
+		DentistName:     "Dra. Ana Souza",
+		DentistCRO:      "CRO-SP 12345",
+		Items: []models.InsuranceGuideItem{
+			{ProcedureCode: "81000065", Description: "Consulta odontológica inicial", Quantity: 1, UnitPrice: 60, Total: 60, ExecutionDate: &executed},
+			{ProcedureCode: "85100196", Description: "Restauração de resina", Tooth: &tooth, Surfaces: "MO", Quantity: 1, UnitPrice: 120.5, Total: 120.5, ExecutionDate: &executed},
+		},
+	}
+	if mutate != nil {
+		mutate(&guide)
+	}
+	insurance := models.InsuranceCompany{Name: "Odonto Saúde", ANSRegistry: "123456"}
+	provider := TISSProvider{CNPJ: "12.345.678/0001-90", Name: "Clínica Sorriso"}
+	lot := models.InsuranceLot{LotNumber: "1"}
+
+	data, hash, err := BuildTISSLot(insurance, provider, lot, []models.InsuranceGuide{guide}, time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("BuildTISSLot failed: %v", err)
+	}
+	return data, hash
+}
+
+// useTISSSchema validates against the ANS XSD files bundled in backend/schemas/tiss
+func useTISSSchema(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("xmllint"); err != nil {
+		t.Skip("xmllint not installed")
+	}
+	t.Setenv("TISS_SCHEMA_DIR", filepath.Join("..", "..", "schemas", "tiss"))
+}
+
+func validateTISSLot(t *testing.T, data []byte) []string {
+	t.Helper()
+	problems, err := ValidateTISSLot(data)
+	if err != nil {
+		t.Fatalf("ValidateTISSLot failed: %v", err)
+	}
+	return problems
+}
+
+func TestBuildTISSLotIsValid(t *testing.T) {
+	useTISSSchema(t)
+	data, hash := tissTestLot(t, nil)
+
+	if problems := validateTISSLot(t, data); len(problems) != 0 {
+		t.Fatalf("Expected a valid lot, got %v", problems)
+	}
+	if len(hash) != 32 {
+		t.Errorf("Expected an MD5 hash, got %q", hash)
+	}
+	if !bytes.HasPrefix(data, []byte(`<?xml version="1.0" encoding="ISO-8859-1"?>`)) {
+		t.Errorf("Expected ISO-8859-1 declaration, got %q", data[:50])
+	}
+	// Accented characters are written in ISO-8859-1, not UTF-8
+	if !bytes.Contains(data, []byte("Jos\xe9 da Silva")) {
+		t.Error("Expected beneficiary name encoded in ISO-8859-1")
+	}
+	for _, want := range []string{"<ans:CNPJ>12345678000190</ans:CNPJ>", "<ans:UF>35</ans:UF>", "<ans:valorTotalProc>180.50</ans:valorTotalProc>", "<ans:codDente>16</ans:codDente>"} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("Expected %s in the message", want)
+		}
+	}
+}
+
+func TestValidateTISSLotDetectsTampering(t *testing.T) {
+	useTISSSchema(t)
+	data, _ := tissTestLot(t, nil)
+
+	tampered := bytes.Replace(data, []byte("<ans:valorProc>60.00</ans:valorProc>"), []byte("<ans:valorProc>90.00</ans:valorProc>"), 1)
+	problems := validateTISSLot(t, tampered)
+	if !containsProblem(problems, "Hash do epílogo") {
+		t.Errorf("Expected hash problem, got %v", problems)
+	}
+	if !containsProblem(problems, "valor total difere") {
+		t.Errorf("Expected total problem, got %v", problems)
+	}
+}
+
+func TestValidateTISSLotReportsGuideProblems(t *testing.T) {
+	useTISSSchema(t)
+	data, _ := tissTestLot(t, func(guide *models.InsuranceGuide) {
+		guide.CardNumber = ""
+		guide.DentistCRO = "12345"
+		guide.Items[0].ProcedureCode = "CONS01"
+		tooth := 19
+		guide.Items[1].Tooth = &tooth
+	})
+
+	problems := validateTISSLot(t, data)
+	for _, want := range []string{
+		"Guia 1: número da carteira é obrigatório",
+		"Guia 1: UF do CRO é obrigatório",
+		"Guia 1: procedimento 1: código TUSS em formato inválido",
+		"Guia 1: procedimento 2: dente inválido: 19",
+	} {
+		if !containsProblem(problems, want) {
+			t.Errorf("Expected %q, got %v", want, problems)
+		}
+	}
+	// The hash is still computed over the generated content
+	if containsProblem(problems, "Hash do epílogo") {
+		t.Errorf("Unexpected hash problem: %v", problems)
+	}
+}
+
+func TestValidateTISSLotRejectsInvalidXML(t *testing.T) {
+	if problems := validateTISSLot(t, []byte("<ans:mensagemTISS>")); len(problems) != 1 || !strings.HasPrefix(problems[0], "XML inválido") {
+		t.Errorf("Expected XML problem, got %v", problems)
+	}
+	if problems := validateTISSLot(t, []byte("<outro/>")); len(problems) != 1 {
+		t.Errorf("Expected root problem, got %v", problems)
+	}
+}
+
+func TestParseCRO(t *testing.T) {
+	cases := []struct {
+		cro, number, uf string
+	}{
+		{"CRO-SP 12345", "12345", "SP"},
+		{"12345/rj", "12345", "RJ"},
+		{"CRO 98765 - MG", "98765", "MG"},
+		{"12345", "12345", ""},
+		{"CRO-XX 1", "1", ""},
+	}
+	for _, tc := range cases {
+		number, uf := ParseCRO(tc.cro)
+		if number != tc.number || uf != tc.uf {
+			t.Errorf("ParseCRO(%q) = %q, %q; expected %q, %q", tc.cro, number, uf, tc.number, tc.uf)
+		}
+	}
+}
+
+func TestValidateTISSLotAgainstSchema(t *testing.T) {
+	useTISSSchema(t)
+	data, _ := tissTestLot(t, nil)
+
+	// An element out of the order of the XSD is only caught by the schema
+	swapped := bytes.Replace(data, []byte("<ans:Padrao>4.01.00</ans:Padrao>"), nil, 1)
+	swapped = bytes.Replace(swapped, []byte("<ans:origem>"), []byte("<ans:Padrao>4.01.00</ans:Padrao><ans:origem>"), 1)
+	problems := validateTISSLot(t, swapped)
+	if !containsProblem(problems, "Esquema TISS") || !containsProblem(problems, "ans:Padrao") {
+		t.Errorf("Expected a schema problem on Padrao, got %v", problems)
+	}
+}
+
+func TestValidateTISSLotRequiresSchema(t *testing.T) {
+	data, _ := tissTestLot(t, nil)
+
+	t.Setenv("TISS_SCHEMA_DIR", t.TempDir())
+	if problems, err := ValidateTISSLot(data); !errors.Is(err, ErrTISSSchemaUnavailable) || problems != nil {
+		t.Errorf("Expected ErrTISSSchemaUnavailable without the XSD, got %v, %v", problems, err)
+	}
+
+	if _, err := exec.LookPath("xmllint"); err != nil {
+		t.Skip("xmllint not installed")
+	}
+	// A schema that does not compile is a failure of the server, not a problem of the lot
+	dir := t.TempDir()
+	broken := `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"><xs:element name="mensagemTISS" type="ausente"/></xs:schema>`
+	if err := os.WriteFile(filepath.Join(dir, tissSchemaFile), []byte(broken), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TISS_SCHEMA_DIR", dir)
+	if problems, err := ValidateTISSLot(data); err == nil || problems != nil {
+		t.Errorf("Expected an error with a broken XSD, got %v, %v", problems, err)
+	}
+}
+
+func containsProblem(problems []string, text string) bool {
+	for _, problem := range problems {
+		if strings.Contains(problem, text) {
+			return true
+		}
+	}
+	return false
+}
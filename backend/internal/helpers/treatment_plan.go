@@ -0,0 +1,146 @@
+package helpers
+
+import (
+	"drcrwell/backend/internal/models"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// roundMoney rounds a value to cents
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// NormalizeTreatmentPlanItems validates the plan items of a budget and fills in their position,
+// quantity, total and status. Returns an error (in Portuguese) for the first invalid item
+func NormalizeTreatmentPlanItems(items []models.TreatmentPlanItem) error {
+	for i := range items {
+		item := &items[i]
+		line := i + 1
+
+		item.Procedure = strings.TrimSpace(item.Procedure)
+		item.ProcedureCode = strings.TrimSpace(item.ProcedureCode)
+		if item.Procedure == "" {
+			return fmt.Errorf("Item %d: procedimento é obrigatório", line)
+		}
+
+		if item.Tooth != nil {
+			if !IsValidFDITooth(*item.Tooth) {
+				return fmt.Errorf("Item %d: dente inválido: %d (use a notação FDI, ex: 16, 21, 55)", line, *item.Tooth)
+			}
+			surfaces, ok := NormalizeToothSurfaces(item.Surfaces)
+			if !ok {
+				return fmt.Errorf("Item %d: faces inválidas: %s (use M, D, O, V, L)", line, item.Surfaces)
+			}
+			item.Surfaces = surfaces
+		} else if strings.TrimSpace(item.Surfaces) != "" {
+			return fmt.Errorf("Item %d: informe o dente das faces %s", line, item.Surfaces)
+		}
+
+		if item.Condition != "" {
+			if item.Tooth == nil {
+				return fmt.Errorf("Item %d: a condição do odontograma exige um dente", line)
+			}
+			if !models.IsValidToothCondition(item.Condition) || item.Condition == models.ToothConditionHealthy {
+				return fmt.Errorf("Item %d: condição inválida: %s", line, item.Condition)
+			}
+			if item.Surfaces != "" && models.IsWholeToothCondition(item.Condition) {
+				return fmt.Errorf("Item %d: a condição '%s' se aplica ao dente inteiro, sem faces", line, models.ToothConditionLabels[item.Condition])
+			}
+		}
+
+		if item.Quantity <= 0 {
+			item.Quantity = 1
+		}
+		if item.UnitPrice < 0 {
+			return fmt.Errorf("Item %d: valor não pode ser negativo", line)
+		}
+		if item.LabCost < 0 {
+			return fmt.Errorf("Item %d: custo de laboratório não pode ser negativo", line)
+		}
+		item.UnitPrice = roundMoney(item.UnitPrice)
+		item.LabCost = roundMoney(item.LabCost)
+		item.Total = roundMoney(float64(item.Quantity) * item.UnitPrice)
+		item.Position = line
+		item.Status = models.PlanItemStatusPlanned
+	}
+	return nil
+}
+
+// TreatmentPlanItemLabel describes an item with its tooth and surfaces, e.g. "Restauração - dente 16 (MO)"
+func TreatmentPlanItemLabel(item models.TreatmentPlanItem) string {
+	if item.Tooth == nil {
+		return item.Procedure
+	}
+	label := fmt.Sprintf("%s - dente %d", item.Procedure, *item.Tooth)
+	if item.Surfaces != "" {
+		label += fmt.Sprintf(" (%s)", item.Surfaces)
+	}
+	return label
+}
+
+// PerformedToothCondition returns the odontogram condition charted when a planned procedure is
+// performed: an extraction leaves the tooth missing, the other procedures leave their own condition
+func PerformedToothCondition(condition string) string {
+	if condition == models.ToothConditionExtraction {
+		return models.ToothConditionMissing
+	}
+	return condition
+}
+
+// TreatmentPlanProgress is the clinical and financial progress of a treatment
+type TreatmentPlanProgress struct {
+	TotalItems      int     `json:"total_items"`
+	PlannedItems    int     `json:"planned_items"`
+	CompletedItems  int     `json:"completed_items"`
+	CancelledItems  int     `json:"cancelled_items"`
+	ClinicalPercent float64 `json:"clinical_percent"` // Completed over the items not cancelled
+
+	PlannedValue   float64 `json:"planned_value"`   // Items not cancelled
+	PerformedValue float64 `json:"performed_value"` // Items completed
+
+	TotalValue       float64 `json:"total_value"`
+	PaidValue        float64 `json:"paid_value"`
+	RemainingValue   float64 `json:"remaining_value"`
+	FinancialPercent float64 `json:"financial_percent"`
+
+	// Balance is what was paid minus what was performed: negative when procedures were
+	// performed ahead of the payments
+	Balance float64 `json:"balance"`
+}
+
+// BuildTreatmentPlanProgress summarizes the plan items and payments of a treatment
+func BuildTreatmentPlanProgress(items []models.TreatmentPlanItem, totalValue, paidValue float64) TreatmentPlanProgress {
+	progress := TreatmentPlanProgress{
+		TotalItems: len(items),
+		TotalValue: roundMoney(totalValue),
+		PaidValue:  roundMoney(paidValue),
+	}
+
+	for _, item := range items {
+		switch item.Status {
+		case models.PlanItemStatusCancelled:
+			progress.CancelledItems++
+			continue
+		case models.PlanItemStatusCompleted:
+			progress.CompletedItems++
+			progress.PerformedValue += item.Total
+		default:
+			progress.PlannedItems++
+		}
+		progress.PlannedValue += item.Total
+	}
+	progress.PlannedValue = roundMoney(progress.PlannedValue)
+	progress.PerformedValue = roundMoney(progress.PerformedValue)
+
+	if active := progress.TotalItems - progress.CancelledItems; active > 0 {
+		progress.ClinicalPercent = math.Round(float64(progress.CompletedItems)/float64(active)*1000) / 10
+	}
+	progress.RemainingValue = roundMoney(math.Max(progress.TotalValue-progress.PaidValue, 0))
+	if progress.TotalValue > 0 {
+		progress.FinancialPercent = math.Round(math.Min(progress.PaidValue/progress.TotalValue, 1)*1000) / 10
+	}
+	progress.Balance = roundMoney(progress.PaidValue - progress.PerformedValue)
+	return progress
+}
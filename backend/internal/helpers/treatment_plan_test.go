@@ -0,0 +1,99 @@
+package helpers
+
+import (
+	"testing"
+
+	"drcrwell/backend/internal/models"
+)
+
+func toothPtr(n int) *int {
+	return &n
+}
+
+func TestNormalizeTreatmentPlanItems(t *testing.T) {
+	items := []models.TreatmentPlanItem{
+		{Procedure: " Restauração resina ", Tooth: toothPtr(16), Surfaces: "om", Condition: models.ToothConditionRestoration, UnitPrice: 250},
+		{Procedure: "Profilaxia", Quantity: 2, UnitPrice: 99.999},
+		{Procedure: "Exodontia", Tooth: toothPtr(38), Condition: models.ToothConditionExtraction, UnitPrice: 300, Status: models.PlanItemStatusCompleted},
+	}
+	if err := NormalizeTreatmentPlanItems(items); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if items[0].Procedure != "Restauração resina" || items[0].Surfaces != "MO" || items[0].Quantity != 1 || items[0].Total != 250 {
+		t.Errorf("Item 1 not normalized: %+v", items[0])
+	}
+	if items[1].Total != 200 || items[1].Position != 2 {
+		t.Errorf("Item 2 total/position wrong: %+v", items[1])
+	}
+	if items[2].Status != models.PlanItemStatusPlanned {
+		t.Errorf("New items must start planned, got %s", items[2].Status)
+	}
+
+	invalid := []struct {
+		name string
+		item models.TreatmentPlanItem
+	}{
+		{"missing procedure", models.TreatmentPlanItem{UnitPrice: 10}},
+		{"invalid tooth", models.TreatmentPlanItem{Procedure: "Restauração", Tooth: toothPtr(19)}},
+		{"invalid surface", models.TreatmentPlanItem{Procedure: "Restauração", Tooth: toothPtr(16), Surfaces: "X"}},
+		{"surfaces without tooth", models.TreatmentPlanItem{Procedure: "Restauração", Surfaces: "O"}},
+		{"condition without tooth", models.TreatmentPlanItem{Procedure: "Coroa", Condition: models.ToothConditionCrown}},
+		{"whole-tooth condition on surfaces", models.TreatmentPlanItem{Procedure: "Coroa", Tooth: toothPtr(11), Surfaces: "V", Condition: models.ToothConditionCrown}},
+		{"healthy condition", models.TreatmentPlanItem{Procedure: "Avaliação", Tooth: toothPtr(11), Condition: models.ToothConditionHealthy}},
+		{"negative price", models.TreatmentPlanItem{Procedure: "Profilaxia", UnitPrice: -1}},
+	}
+	for _, tt := range invalid {
+		if err := NormalizeTreatmentPlanItems([]models.TreatmentPlanItem{tt.item}); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestTreatmentPlanItemLabel(t *testing.T) {
+	if label := TreatmentPlanItemLabel(models.TreatmentPlanItem{Procedure: "Restauração", Tooth: toothPtr(16), Surfaces: "MO"}); label != "Restauração - dente 16 (MO)" {
+		t.Errorf("Unexpected label: %s", label)
+	}
+	if label := TreatmentPlanItemLabel(models.TreatmentPlanItem{Procedure: "Profilaxia"}); label != "Profilaxia" {
+		t.Errorf("Unexpected label: %s", label)
+	}
+}
+
+func TestPerformedToothCondition(t *testing.T) {
+	if c := PerformedToothCondition(models.ToothConditionExtraction); c != models.ToothConditionMissing {
+		t.Errorf("Extraction should leave the tooth missing, got %s", c)
+	}
+	if c := PerformedToothCondition(models.ToothConditionRootCanal); c != models.ToothConditionRootCanal {
+		t.Errorf("Expected root_canal, got %s", c)
+	}
+}
+
+func TestBuildTreatmentPlanProgress(t *testing.T) {
+	items := []models.TreatmentPlanItem{
+		{Status: models.PlanItemStatusCompleted, Total: 300},
+		{Status: models.PlanItemStatusCompleted, Total: 200},
+		{Status: models.PlanItemStatusPlanned, Total: 500},
+		{Status: models.PlanItemStatusCancelled, Total: 1000},
+	}
+	progress := BuildTreatmentPlanProgress(items, 1000, 400)
+
+	if progress.TotalItems != 4 || progress.CompletedItems != 2 || progress.PlannedItems != 1 || progress.CancelledItems != 1 {
+		t.Errorf("Unexpected counts: %+v", progress)
+	}
+	if progress.ClinicalPercent != 66.7 {
+		t.Errorf("Expected clinical 66.7%%, got %v", progress.ClinicalPercent)
+	}
+	if progress.PlannedValue != 1000 || progress.PerformedValue != 500 {
+		t.Errorf("Unexpected values: %+v", progress)
+	}
+	if progress.RemainingValue != 600 || progress.FinancialPercent != 40 {
+		t.Errorf("Unexpected financial progress: %+v", progress)
+	}
+	if progress.Balance != -100 {
+		t.Errorf("Expected balance -100 (performed ahead of payments), got %v", progress.Balance)
+	}
+
+	empty := BuildTreatmentPlanProgress(nil, 0, 0)
+	if empty.ClinicalPercent != 0 || empty.FinancialPercent != 0 {
+		t.Errorf("Empty plan should have no progress: %+v", empty)
+	}
+}
@@ -26,16 +26,17 @@ const (
 	FileTypeImage    FileType = "image"
 	FileTypePDF      FileType = "pdf"
 	FileTypeDocument FileType = "document"
+	FileTypeDICOM    FileType = "dicom"
 	FileTypeUnknown  FileType = "unknown"
 )
 
 // ValidateFileMagicNumber reads the first bytes of a file and validates it against known magic numbers
 // Returns the detected file type and whether it's valid for the expected types
 func ValidateFileMagicNumber(file multipart.File, allowedTypes []FileType) (FileType, bool, error) {
-	// Read first 12 bytes for magic number detection
-	header := make([]byte, 12)
-	n, err := file.Read(header)
-	if err != nil && err != io.EOF {
+	// Read first 132 bytes for magic number detection (DICOM has "DICM" after a 128-byte preamble)
+	header := make([]byte, 132)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
 		return FileTypeUnknown, false, err
 	}
 	header = header[:n]
@@ -57,6 +58,11 @@ func ValidateFileMagicNumber(file multipart.File, allowedTypes []FileType) (File
 	return detectedType, false, nil
 }
 
+// DetectFileType determines the type of a file already read in memory
+func DetectFileType(data []byte) FileType {
+	return detectFileType(data[:min(len(data), 132)])
+}
+
 // detectFileType determines file type from magic bytes
 func detectFileType(header []byte) FileType {
 	if len(header) < 3 {
@@ -80,6 +86,11 @@ func detectFileType(header []byte) FileType {
 		}
 	}
 
+	// HEIC/HEIF photos (iPhone)
+	if IsHEIC(header) {
+		return FileTypeImage
+	}
+
 	// Check for PDF
 	if bytes.HasPrefix(header, magicPDF) {
 		return FileTypePDF
@@ -90,10 +101,15 @@ func detectFileType(header []byte) FileType {
 		return FileTypeDocument
 	}
 
+	// Check for DICOM (radiographs, CBCT)
+	if IsDICOM(header) {
+		return FileTypeDICOM
+	}
+
 	return FileTypeUnknown
 }
 
-// ValidateImageFile validates that a file is a valid image (JPEG, PNG, GIF, WebP)
+// ValidateImageFile validates that a file is a valid image (JPEG, PNG, GIF, WebP, HEIC)
 func ValidateImageFile(file multipart.File) (bool, error) {
 	_, valid, err := ValidateFileMagicNumber(file, []FileType{FileTypeImage})
 	return valid, err
@@ -105,9 +121,9 @@ func ValidatePDFFile(file multipart.File) (bool, error) {
 	return valid, err
 }
 
-// ValidateMedicalFile validates that a file is a valid medical document (PDF, image, or document)
+// ValidateMedicalFile validates that a file is a valid medical document (PDF, image, document or DICOM)
 func ValidateMedicalFile(file multipart.File) (bool, error) {
-	_, valid, err := ValidateFileMagicNumber(file, []FileType{FileTypeImage, FileTypePDF, FileTypeDocument})
+	_, valid, err := ValidateFileMagicNumber(file, []FileType{FileTypeImage, FileTypePDF, FileTypeDocument, FileTypeDICOM})
 	return valid, err
 }
 
@@ -0,0 +1,61 @@
+package helpers
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// preferredDateRange is a date range inside WaitingList.PreferredDates
+type preferredDateRange struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// PreferredDatesMatch reports whether day fits a waiting-list entry's preferred dates
+// preferred is a JSON array of "YYYY-MM-DD" strings and/or {"start": "...", "end": "..."} ranges
+// Empty or unreadable preferences accept any date
+func PreferredDatesMatch(preferred string, day time.Time) bool {
+	if strings.TrimSpace(preferred) == "" {
+		return true
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal([]byte(preferred), &items); err != nil || len(items) == 0 {
+		return true
+	}
+
+	date := day.Format("2006-01-02")
+	for _, item := range items {
+		var single string
+		if err := json.Unmarshal(item, &single); err == nil {
+			if single == date {
+				return true
+			}
+			continue
+		}
+
+		var r preferredDateRange
+		if err := json.Unmarshal(item, &r); err != nil {
+			continue
+		}
+		if (r.Start == "" || r.Start <= date) && (r.End == "" || date <= r.End) && (r.Start != "" || r.End != "") {
+			return true
+		}
+	}
+	return false
+}
+
+// ProcedureMatches reports whether a waiting-list procedure fits the procedure of a freed slot
+// Entries without a procedure accept any slot; otherwise names are compared case-insensitively
+func ProcedureMatches(wanted, offered string) bool {
+	wanted = strings.TrimSpace(wanted)
+	if wanted == "" {
+		return true
+	}
+	offered = strings.TrimSpace(offered)
+	if offered == "" {
+		return true
+	}
+	return strings.EqualFold(wanted, offered)
+}
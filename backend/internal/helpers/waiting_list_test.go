@@ -0,0 +1,43 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPreferredDatesMatch(t *testing.T) {
+	day := time.Date(2026, 3, 5, 14, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name      string
+		preferred string
+		expected  bool
+	}{
+		{"empty accepts any date", "", true},
+		{"empty array accepts any date", "[]", true},
+		{"single date match", `["2026-03-04", "2026-03-05"]`, true},
+		{"single date mismatch", `["2026-03-04"]`, false},
+		{"inside range", `[{"start": "2026-03-01", "end": "2026-03-10"}]`, true},
+		{"outside range", `[{"start": "2026-03-06", "end": "2026-03-10"}]`, false},
+		{"open-ended range", `[{"start": "2026-03-01"}]`, true},
+		{"mixed formats", `["2026-02-01", {"start": "2026-03-05", "end": "2026-03-05"}]`, true},
+	}
+
+	for _, tc := range cases {
+		if got := PreferredDatesMatch(tc.preferred, day); got != tc.expected {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestProcedureMatches(t *testing.T) {
+	if !ProcedureMatches("", "Limpeza") {
+		t.Error("Entry without procedure should accept any slot")
+	}
+	if !ProcedureMatches("limpeza", "Limpeza") {
+		t.Error("Procedure comparison should ignore case")
+	}
+	if ProcedureMatches("Canal", "Limpeza") {
+		t.Error("Different procedures should not match")
+	}
+}
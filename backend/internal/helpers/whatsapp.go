@@ -0,0 +1,316 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	MetaGraphAPIURL     = "https://graph.facebook.com/v18.0"
+	MetaGraphAPIVersion = "v18.0"
+)
+
+// WhatsApp Message Types
+type WhatsAppMessageType string
+
+const (
+	MessageTypeTemplate WhatsAppMessageType = "template"
+	MessageTypeText     WhatsAppMessageType = "text"
+)
+
+// Meta API Request/Response structures
+type MetaTemplateMessage struct {
+	MessagingProduct string                 `json:"messaging_product"`
+	RecipientType    string                 `json:"recipient_type"`
+	To               string                 `json:"to"`
+	Type             string                 `json:"type"`
+	Template         *MetaTemplateComponent `json:"template,omitempty"`
+}
+
+type MetaTemplateComponent struct {
+	Name       string                   `json:"name"`
+	Language   MetaTemplateLanguage     `json:"language"`
+	Components []MetaTemplateCompParams `json:"components,omitempty"`
+}
+
+type MetaTemplateLanguage struct {
+	Code string `json:"code"`
+}
+
+type MetaTemplateCompParams struct {
+	Type       string              `json:"type"`
+	Parameters []MetaTemplateParam `json:"parameters,omitempty"`
+}
+
+type MetaTemplateParam struct {
+	Type          string `json:"type"`
+	Text          string `json:"text,omitempty"`
+	ParameterName string `json:"parameter_name,omitempty"`
+}
+
+type MetaSendMessageResponse struct {
+	MessagingProduct string `json:"messaging_product"`
+	Contacts         []struct {
+		Input string `json:"input"`
+		WaID  string `json:"wa_id"`
+	} `json:"contacts"`
+	Messages []struct {
+		ID string `json:"id"`
+	} `json:"messages"`
+}
+
+type MetaErrorResponse struct {
+	Error struct {
+		Message   string `json:"message"`
+		Type      string `json:"type"`
+		Code      int    `json:"code"`
+		FBTraceID string `json:"fbtrace_id"`
+	} `json:"error"`
+}
+
+// Template structures from Meta API
+type MetaTemplate struct {
+	Name       string             `json:"name"`
+	Status     string             `json:"status"`
+	Category   string             `json:"category"`
+	Language   string             `json:"language"`
+	ID         string             `json:"id"`
+	Components []MetaTemplateComp `json:"components"`
+}
+
+type MetaTemplateComp struct {
+	Type    string `json:"type"`
+	Format  string `json:"format,omitempty"`
+	Text    string `json:"text,omitempty"`
+	Example *struct {
+		BodyText [][]string `json:"body_text,omitempty"`
+	} `json:"example,omitempty"`
+}
+
+type MetaTemplatesResponse struct {
+	Data   []MetaTemplate `json:"data"`
+	Paging struct {
+		Cursors struct {
+			Before string `json:"before"`
+			After  string `json:"after"`
+		} `json:"cursors"`
+	} `json:"paging"`
+}
+
+// GetMetaTemplateParameters fetches a template from Meta API and extracts its parameter names
+// Returns: parameter names slice, body text (for debugging), error
+func GetMetaTemplateParameters(businessAccountID, accessToken, templateName string) ([]string, string, error) {
+	url := fmt.Sprintf("%s/%s/message_templates?name=%s&fields=components",
+		MetaGraphAPIURL, businessAccountID, templateName)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("[WhatsApp] Template API error: %s", string(body))
+		return nil, "", fmt.Errorf("meta API returned %d", resp.StatusCode)
+	}
+
+	var templatesResp MetaTemplatesResponse
+	if err := json.Unmarshal(body, &templatesResp); err != nil {
+		return nil, "", err
+	}
+
+	if len(templatesResp.Data) == 0 {
+		return nil, "", fmt.Errorf("template not found")
+	}
+
+	// Extract parameter names from body component
+	// Match both numbered {{1}} and named {{name}} placeholders
+	for _, comp := range templatesResp.Data[0].Components {
+		if comp.Type == "BODY" && comp.Text != "" {
+			re := regexp.MustCompile(`\{\{([^}]+)\}\}`)
+			matches := re.FindAllStringSubmatch(comp.Text, -1)
+			paramNames := make([]string, len(matches))
+			for i, match := range matches {
+				paramNames[i] = match[1] // Extract the name inside {{...}}
+			}
+			return paramNames, comp.Text, nil
+		}
+	}
+
+	return nil, "", nil
+}
+
+// SendMetaMessage sends a message via Meta Graph API
+func SendMetaMessage(phoneNumberID, accessToken string, message MetaTemplateMessage) (*MetaSendMessageResponse, error) {
+	url := fmt.Sprintf("%s/%s/messages", MetaGraphAPIURL, phoneNumberID)
+
+	jsonData, err := json.Marshal(message)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao serializar mensagem: %v", err)
+	}
+
+	// Log the JSON being sent for debugging
+	log.Printf("[WhatsApp] Sending to URL: %s", url)
+	log.Printf("[WhatsApp] Request JSON: %s", string(jsonData))
+
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar requisição: %v", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao enviar mensagem: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	log.Printf("[WhatsApp] Response status: %d, body: %s", resp.StatusCode, string(body))
+
+	if resp.StatusCode != http.StatusOK {
+		var metaErr MetaErrorResponse
+		json.Unmarshal(body, &metaErr)
+		return nil, fmt.Errorf("erro da Meta API (%d): %s", metaErr.Error.Code, metaErr.Error.Message)
+	}
+
+	var result MetaSendMessageResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("erro ao processar resposta: %v", err)
+	}
+
+	return &result, nil
+}
+
+// AppointmentTemplateValues holds the values available to appointment templates
+type AppointmentTemplateValues struct {
+	PatientName string
+	Date        string
+	Time        string
+	DentistName string
+	ClinicName  string
+	Link        string // Optional action link (e.g. waiting-list claim page)
+}
+
+// BuildAppointmentTemplateMessage builds a Meta template message for an appointment
+// Parameter names are mapped to values, supporting common variations like paciente/nome, denista/dentista, etc.
+func BuildAppointmentTemplateMessage(phone, templateName string, paramNames []string, values AppointmentTemplateValues) MetaTemplateMessage {
+	// Prepare clinic name with fallback
+	clinicName := values.ClinicName
+	if clinicName == "" {
+		clinicName = "nossa clínica"
+	}
+
+	paramValues := map[string]string{
+		"paciente": values.PatientName,
+		"nome":     values.PatientName,
+		"data":     values.Date,
+		"hora":     values.Time,
+		"denista":  values.DentistName, // Common typo in template
+		"dentista": values.DentistName,
+		"clinica":  clinicName,
+		"link":     values.Link,
+		"1":        values.PatientName,
+		"2":        values.Date,
+		"3":        values.Time,
+		"4":        values.DentistName,
+		"5":        clinicName,
+		"6":        values.Link,
+	}
+
+	// Build parameters with names
+	params := make([]MetaTemplateParam, len(paramNames))
+	for i, name := range paramNames {
+		value, ok := paramValues[name]
+		if !ok {
+			value = "" // Default to empty if not found
+			log.Printf("[WhatsApp] Warning: Unknown parameter name '%s'", name)
+		}
+		params[i] = MetaTemplateParam{
+			Type:          "text",
+			Text:          value,
+			ParameterName: name,
+		}
+	}
+
+	log.Printf("[WhatsApp] Sending to: %s, Template: %s, Params count: %d", phone, templateName, len(params))
+
+	return MetaTemplateMessage{
+		MessagingProduct: "whatsapp",
+		RecipientType:    "individual",
+		To:               phone,
+		Type:             "template",
+		Template: &MetaTemplateComponent{
+			Name: templateName,
+			Language: MetaTemplateLanguage{
+				Code: "pt_BR",
+			},
+			Components: []MetaTemplateCompParams{
+				{
+					Type:       "body",
+					Parameters: params,
+				},
+			},
+		},
+	}
+}
+
+// NormalizeWhatsAppPhone normalizes a phone number for WhatsApp
+// Supports international numbers (starting with country code) and Brazilian numbers
+func NormalizeWhatsAppPhone(phone string) string {
+	// Remove all non-digit characters
+	re := regexp.MustCompile(`\D`)
+	phone = re.ReplaceAllString(phone, "")
+
+	// If starts with 0, remove it (Brazilian local format)
+	if strings.HasPrefix(phone, "0") {
+		phone = phone[1:]
+	}
+
+	// Check if it's already an international number (starts with common country codes)
+	// US/Canada: 1, UK: 44, Portugal: 351, etc.
+	internationalPrefixes := []string{"1", "44", "351", "34", "33", "49", "39", "81", "86", "91"}
+	isInternational := false
+	for _, prefix := range internationalPrefixes {
+		// Check if number starts with country code and has reasonable length
+		if strings.HasPrefix(phone, prefix) && len(phone) >= 10 {
+			// For US numbers (1xxx), make sure it's not a local Brazilian number
+			if prefix == "1" && len(phone) == 11 {
+				// Could be Brazilian (55 + 2-digit DDD + 9-digit number = 13 digits usually)
+				// US number would be 1 + 10 digits = 11 digits
+				isInternational = true
+			} else if prefix != "1" {
+				isInternational = true
+			}
+			break
+		}
+	}
+
+	// Only add Brazil country code if it's not an international number
+	// and doesn't already have 55 prefix
+	if !isInternational && !strings.HasPrefix(phone, "55") {
+		phone = "55" + phone
+	}
+
+	return phone
+}
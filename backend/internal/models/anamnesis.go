@@ -0,0 +1,197 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Anamnesis question types
+const (
+	AnamnesisQuestionYesNo          = "yes_no"
+	AnamnesisQuestionSingleChoice   = "single_choice"
+	AnamnesisQuestionMultipleChoice = "multiple_choice"
+	AnamnesisQuestionText           = "text"
+	AnamnesisQuestionDate           = "date"
+)
+
+// Yes/no answer values
+const (
+	AnamnesisAnswerYes = "yes"
+	AnamnesisAnswerNo  = "no"
+)
+
+// Clinical flags: answers that must reach the patient's medical information
+const (
+	ClinicalFlagAllergy         = "allergy"
+	ClinicalFlagMedication      = "medication"
+	ClinicalFlagAnticoagulant   = "anticoagulant"
+	ClinicalFlagSystemicDisease = "systemic_disease"
+	ClinicalFlagPregnancy       = "pregnancy"
+)
+
+// clinicalFlagPatientFields maps each clinical flag to the Patient column it syncs into
+var clinicalFlagPatientFields = map[string]string{
+	ClinicalFlagAllergy:         "allergies",
+	ClinicalFlagMedication:      "medications",
+	ClinicalFlagAnticoagulant:   "medications",
+	ClinicalFlagSystemicDisease: "systemic_diseases",
+	ClinicalFlagPregnancy:       "systemic_diseases",
+}
+
+// IsValidAnamnesisQuestionType reports whether t is a known question type
+func IsValidAnamnesisQuestionType(t string) bool {
+	switch t {
+	case AnamnesisQuestionYesNo, AnamnesisQuestionSingleChoice, AnamnesisQuestionMultipleChoice,
+		AnamnesisQuestionText, AnamnesisQuestionDate:
+		return true
+	}
+	return false
+}
+
+// ClinicalFlagPatientField returns the Patient column (allergies, medications, systemic_diseases)
+// a flagged answer syncs into, or "" for an unknown flag
+func ClinicalFlagPatientField(flag string) string {
+	return clinicalFlagPatientFields[flag]
+}
+
+// AnamnesisOptions is a list of choices (stored as a JSON array)
+type AnamnesisOptions []string
+
+// Value implements driver.Valuer for database storage
+func (o AnamnesisOptions) Value() (driver.Value, error) {
+	if o == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(o)
+	return string(data), err
+}
+
+// Scan implements sql.Scanner for database retrieval
+func (o *AnamnesisOptions) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*o = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, o)
+	case string:
+		return json.Unmarshal([]byte(v), o)
+	default:
+		return fmt.Errorf("cannot scan type %T into AnamnesisOptions", value)
+	}
+}
+
+// Contains reports whether value is one of the options
+func (o AnamnesisOptions) Contains(value string) bool {
+	for _, option := range o {
+		if option == value {
+			return true
+		}
+	}
+	return false
+}
+
+// AnamnesisTemplate is a tenant-defined anamnesis questionnaire
+type AnamnesisTemplate struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Title       string `gorm:"not null" json:"title"`
+	Description string `gorm:"type:text" json:"description"`
+	Active      bool   `gorm:"default:true" json:"active"`
+	IsDefault   bool   `gorm:"default:false" json:"is_default"`
+
+	Questions []AnamnesisQuestion `gorm:"foreignKey:TemplateID" json:"questions"`
+}
+
+// TableName specifies the table name
+func (AnamnesisTemplate) TableName() string {
+	return "anamnesis_templates"
+}
+
+// AnamnesisQuestion is one question of a template
+// A question with DependsOn is only asked when the answer to that question matches DependsValue
+type AnamnesisQuestion struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	TemplateID uint             `gorm:"not null;index" json:"template_id"`
+	Position   int              `gorm:"not null" json:"position"`
+	Key        string           `gorm:"size:50;not null" json:"key"` // Unique within the template, referenced by DependsOn
+	Text       string           `gorm:"type:text;not null" json:"text"`
+	Type       string           `gorm:"size:20;not null" json:"type"` // See AnamnesisQuestion* constants
+	Options    AnamnesisOptions `gorm:"type:jsonb" json:"options"`    // Choices of single/multiple choice questions
+	Required   bool             `gorm:"default:false" json:"required"`
+
+	// Conditional question
+	DependsOn    string `gorm:"size:50" json:"depends_on"`     // Key of an earlier question
+	DependsValue string `gorm:"size:255" json:"depends_value"` // e.g. "yes" or one of the options
+
+	// Free-text complement ("Qual?") shown with the answer
+	AskDetails   bool   `gorm:"default:false" json:"ask_details"`
+	DetailsLabel string `gorm:"size:255" json:"details_label"`
+
+	// Clinically relevant answers are copied to the patient's medical information
+	ClinicalFlag  string `gorm:"size:30" json:"clinical_flag"`   // See ClinicalFlag* constants
+	ClinicalLabel string `gorm:"size:255" json:"clinical_label"` // Text written on the patient, e.g. "Anticoagulante"
+}
+
+// TableName specifies the table name
+func (AnamnesisQuestion) TableName() string {
+	return "anamnesis_questions"
+}
+
+// AnamnesisResponse is a questionnaire filled for a patient
+type AnamnesisResponse struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	PatientID       uint      `gorm:"not null;index" json:"patient_id"`
+	Patient         *Patient  `gorm:"foreignKey:PatientID" json:"patient,omitempty"`
+	TemplateID      uint      `gorm:"not null;index" json:"template_id"`
+	TemplateTitle   string    `gorm:"not null" json:"template_title"` // Snapshot at filling time
+	MedicalRecordID *uint     `gorm:"index" json:"medical_record_id"`
+	FilledByID      uint      `json:"filled_by_id"`
+	FilledAt        time.Time `gorm:"not null" json:"filled_at"`
+	Notes           string    `gorm:"type:text" json:"notes"`
+
+	Answers []AnamnesisAnswer `gorm:"foreignKey:ResponseID" json:"answers"`
+}
+
+// TableName specifies the table name
+func (AnamnesisResponse) TableName() string {
+	return "anamnesis_responses"
+}
+
+// AnamnesisAnswer is the answer to one question, with a snapshot of the question
+type AnamnesisAnswer struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	ResponseID   uint             `gorm:"not null;index" json:"response_id"`
+	QuestionID   uint             `json:"question_id"`
+	Position     int              `json:"position"`
+	QuestionKey  string           `gorm:"size:50" json:"question_key"`
+	QuestionText string           `gorm:"type:text" json:"question_text"`
+	QuestionType string           `gorm:"size:20" json:"question_type"`
+	Value        string           `gorm:"type:text" json:"value"`     // yes/no, chosen option, text or YYYY-MM-DD date
+	Selected     AnamnesisOptions `gorm:"type:jsonb" json:"selected"` // Options of multiple choice questions
+	Details      string           `gorm:"type:text" json:"details"`
+
+	ClinicalFlag string `gorm:"size:30" json:"clinical_flag"`
+	Flagged      bool   `gorm:"default:false" json:"flagged"` // The answer triggered the clinical flag
+}
+
+// TableName specifies the table name
+func (AnamnesisAnswer) TableName() string {
+	return "anamnesis_answers"
+}
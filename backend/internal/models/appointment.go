@@ -27,21 +27,40 @@ type Appointment struct {
 	Procedure   string    `json:"procedure"`
 
 	// Status
-	Status      string    `gorm:"default:'scheduled'" json:"status"` // scheduled, confirmed, in_progress, completed, cancelled, no_show
+	Status      string    `gorm:"default:'scheduled'" json:"status"` // scheduled, confirmed, arrived, in_progress, completed, cancelled, no_show (see appointment_status.go)
 
 	// Confirmation
 	Confirmed   bool      `gorm:"default:false" json:"confirmed"`
 	ConfirmedAt *time.Time `json:"confirmed_at"`
 
+	// No-show policy - set on self-bookings of patients with repeated no-shows
+	StaffConfirmationRequired bool       `gorm:"default:false" json:"staff_confirmation_required"` // Cleared when the staff confirms
+	DepositRequired           bool       `gorm:"default:false" json:"deposit_required"`
+	DepositAmount             float64    `gorm:"default:0" json:"deposit_amount,omitempty"`
+	DepositPaidAt             *time.Time `json:"deposit_paid_at,omitempty"` // Set when the PIX charge of the deposit is paid
+
 	// Reminder sent
 	ReminderSent bool     `gorm:"default:false" json:"reminder_sent"`
 
 	Notes       string    `gorm:"type:text" json:"notes"`
 
-	// Room
+	// Room - free text kept for display; RoomID links the bookable resource (chair/room)
 	Room        string    `json:"room"`
+	RoomID      *uint     `gorm:"index" json:"room_id"`
+	Resource    *Room     `gorm:"foreignKey:RoomID" json:"resource,omitempty"`
 
 	// Recurrence
 	IsRecurring bool      `gorm:"default:false" json:"is_recurring"`
-	RecurrenceRule string `json:"recurrence_rule,omitempty"` // JSON with recurrence config
+	RecurrenceRule string `json:"recurrence_rule,omitempty"` // RFC 5545 RRULE, e.g. FREQ=WEEKLY;COUNT=12
+
+	// Series - all occurrences generated from the same rule share a SeriesID
+	SeriesID    *string   `gorm:"type:varchar(36);index" json:"series_id,omitempty"`
+	SeriesIndex int       `gorm:"default:0" json:"series_index,omitempty"` // 1-based position within the series
 }
+
+// Scopes for editing or cancelling an appointment that belongs to a series
+const (
+	SeriesScopeThis      = "this"
+	SeriesScopeFollowing = "following"
+	SeriesScopeAll       = "all"
+)
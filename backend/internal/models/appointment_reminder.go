@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Reminder delivery channels
+const (
+	ReminderChannelWhatsApp = "whatsapp"
+	ReminderChannelEmail    = "email"
+	ReminderChannelSMS      = "sms"
+)
+
+// Reminder delivery statuses
+const (
+	ReminderStatusSent    = "sent"
+	ReminderStatusFailed  = "failed"
+	ReminderStatusSkipped = "skipped"
+)
+
+// AppointmentReminder records each attempt to deliver an appointment reminder
+type AppointmentReminder struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	AppointmentID uint         `gorm:"not null;index" json:"appointment_id"`
+	Appointment   *Appointment `gorm:"foreignKey:AppointmentID" json:"appointment,omitempty"`
+
+	PatientID uint `gorm:"not null;index" json:"patient_id"`
+
+	Channel   string `gorm:"size:20" json:"channel"` // whatsapp, email, sms
+	Recipient string `json:"recipient"`              // phone number or email address
+
+	Status       string     `gorm:"size:20;index" json:"status"` // sent, failed, skipped
+	MessageID    string     `json:"message_id,omitempty"`        // Provider message ID (Meta wamid)
+	ErrorMessage string     `gorm:"type:text" json:"error_message,omitempty"`
+	SentAt       *time.Time `json:"sent_at"`
+}
@@ -0,0 +1,111 @@
+package models
+
+import "time"
+
+// Appointment lifecycle statuses
+const (
+	AppointmentStatusScheduled  = "scheduled"
+	AppointmentStatusConfirmed  = "confirmed"
+	AppointmentStatusArrived    = "arrived"     // Patient checked in at the reception
+	AppointmentStatusInProgress = "in_progress" // Patient seated in the chair
+	AppointmentStatusCompleted  = "completed"
+	AppointmentStatusNoShow     = "no_show"
+	AppointmentStatusCancelled  = "cancelled"
+)
+
+// appointmentStatusTransitions lists the statuses each status may move to
+// Clinics that skip check-in can complete straight from scheduled/confirmed;
+// cancelled and no-show appointments can be reopened, completed ones are final
+var appointmentStatusTransitions = map[string][]string{
+	AppointmentStatusScheduled:  {AppointmentStatusConfirmed, AppointmentStatusArrived, AppointmentStatusInProgress, AppointmentStatusCompleted, AppointmentStatusNoShow, AppointmentStatusCancelled},
+	AppointmentStatusConfirmed:  {AppointmentStatusScheduled, AppointmentStatusArrived, AppointmentStatusInProgress, AppointmentStatusCompleted, AppointmentStatusNoShow, AppointmentStatusCancelled},
+	AppointmentStatusArrived:    {AppointmentStatusInProgress, AppointmentStatusCompleted, AppointmentStatusCancelled},
+	AppointmentStatusInProgress: {AppointmentStatusCompleted},
+	AppointmentStatusCompleted:  {},
+	AppointmentStatusNoShow:     {AppointmentStatusScheduled},
+	AppointmentStatusCancelled:  {AppointmentStatusScheduled},
+}
+
+// IsValidAppointmentStatus reports whether status is part of the lifecycle
+func IsValidAppointmentStatus(status string) bool {
+	_, ok := appointmentStatusTransitions[status]
+	return ok
+}
+
+// CanTransitionAppointmentStatus reports whether an appointment may move from one status to another
+// Keeping the same status is always allowed
+func CanTransitionAppointmentStatus(from, to string) bool {
+	if from == to {
+		return IsValidAppointmentStatus(to)
+	}
+	for _, allowed := range appointmentStatusTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsActiveAppointmentStatus reports whether an appointment in this status occupies the agenda
+// Cancelled and no-show appointments free their slot
+func IsActiveAppointmentStatus(status string) bool {
+	return status != AppointmentStatusCancelled && status != AppointmentStatusNoShow
+}
+
+// AllowedAppointmentStatusTransitions returns the statuses an appointment may move to
+func AllowedAppointmentStatusTransitions(from string) []string {
+	return appointmentStatusTransitions[from]
+}
+
+// Cancellation reason codes
+const (
+	CancellationReasonPatientRequest = "patient_request" // Patient asked to cancel
+	CancellationReasonPatientIllness = "patient_illness" // Patient sick or unable to attend
+	CancellationReasonProfessional   = "professional"    // Professional unavailable
+	CancellationReasonClinic         = "clinic"          // Clinic closure, equipment, emergency
+	CancellationReasonRescheduled    = "rescheduled"     // Replaced by another appointment
+	CancellationReasonFinancial      = "financial"       // Payment or insurance issue
+	CancellationReasonDuplicate      = "duplicate"       // Booked twice by mistake
+	CancellationReasonOther          = "other"
+)
+
+// IsValidCancellationReason reports whether code is a known cancellation reason
+func IsValidCancellationReason(code string) bool {
+	switch code {
+	case CancellationReasonPatientRequest, CancellationReasonPatientIllness, CancellationReasonProfessional,
+		CancellationReasonClinic, CancellationReasonRescheduled, CancellationReasonFinancial,
+		CancellationReasonDuplicate, CancellationReasonOther:
+		return true
+	}
+	return false
+}
+
+// Where a status change came from
+const (
+	StatusSourceStaff    = "staff"
+	StatusSourcePortal   = "portal"
+	StatusSourceWhatsApp = "whatsapp"
+	StatusSourceSystem   = "system"
+)
+
+// AppointmentStatusHistory records every status change of an appointment
+// Check-in, chair and completion times (wait time, chair time) are derived from it
+type AppointmentStatusHistory struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	AppointmentID uint      `gorm:"not null;index" json:"appointment_id"`
+	FromStatus    string    `gorm:"size:20" json:"from_status"`
+	ToStatus      string    `gorm:"size:20;not null" json:"to_status"`
+	ChangedAt     time.Time `gorm:"not null" json:"changed_at"`
+	ChangedBy     *uint     `json:"changed_by"` // User ID; nil for patient (portal/WhatsApp) and system changes
+	ChangedByName string    `gorm:"-" json:"changed_by_name,omitempty"`
+	Source        string    `gorm:"size:20;default:'staff'" json:"source"` // staff, portal, whatsapp, system
+	ReasonCode    string    `gorm:"size:30" json:"reason_code,omitempty"`  // Cancellation reason code
+	Reason        string    `gorm:"type:text" json:"reason,omitempty"`
+}
+
+// TableName specifies the table name
+func (AppointmentStatusHistory) TableName() string {
+	return "appointment_status_history"
+}
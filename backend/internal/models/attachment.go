@@ -23,6 +23,12 @@ type Attachment struct {
 	MimeType    string `json:"mime_type"`
 	FileSize    int64  `json:"file_size"` // in bytes
 
+	// Renditions generated in the background for photos and PDFs (see ExamPreview* statuses)
+	ThumbnailPath string `json:"thumbnail_path,omitempty"`
+	PreviewPath   string `json:"preview_path,omitempty"`
+	PreviewStatus string `gorm:"size:20" json:"preview_status,omitempty"` // pending, ready, failed, unsupported
+	PreviewError  string `gorm:"size:255" json:"preview_error,omitempty"`
+
 	// Classification
 	Category    string `json:"category"` // photo, xray, exam, document, other
 	Description string `gorm:"type:text" json:"description"`
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CalendarFeed is a read-only ICS subscription to a professional's or room's agenda
+// Stored in the public schema so the token alone resolves the tenant (like Tenant.EmbedToken)
+type CalendarFeed struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	TenantID  uint   `gorm:"not null;index" json:"tenant_id"`
+	OwnerType string `gorm:"type:varchar(20);not null" json:"owner_type"` // dentist, room
+	OwnerID   uint   `gorm:"not null" json:"owner_id"`                    // User ID for dentists, Room ID for rooms
+	Name      string `json:"name"`                                        // Calendar name shown in the subscriber's app
+
+	Token          string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	CreatedBy      uint       `json:"created_by"`
+	LastAccessedAt *time.Time `json:"last_accessed_at"`
+	RevokedAt      *time.Time `json:"revoked_at"`
+}
+
+// TableName specifies the table name for CalendarFeed model
+// Feeds are stored in public schema, not tenant schemas
+func (CalendarFeed) TableName() string {
+	return "public.calendar_feeds"
+}
+
+// Calendar feed owners
+const (
+	CalendarFeedOwnerDentist = "dentist"
+	CalendarFeedOwnerRoom    = "room"
+)
+
+// Calendar feed privacy levels (TenantSettings.CalendarFeedPrivacy)
+const (
+	CalendarPrivacyFull     = "full"     // Patient name and procedure
+	CalendarPrivacyInitials = "initials" // Patient initials and procedure
+	CalendarPrivacyMinimal  = "minimal"  // Only "Consulta" - no patient data
+)
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ClinicClosure is a date when the whole clinic does not take appointments (holiday, closure)
+// Entries without StartTime/EndTime close the full day; otherwise only that period is blocked
+type ClinicClosure struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Date      time.Time `gorm:"type:date;not null;index" json:"date"`
+	StartTime string    `gorm:"size:5" json:"start_time"` // Format: "HH:MM" (empty for full-day closures)
+	EndTime   string    `gorm:"size:5" json:"end_time"`   // Format: "HH:MM" (empty for full-day closures)
+	Name      string    `gorm:"not null" json:"name"`
+	Type      string    `gorm:"size:20;default:'custom'" json:"type"` // national, municipal, custom
+
+	CreatedBy uint `json:"created_by"`
+}
+
+// FullDay reports whether the closure blocks the whole day
+func (c ClinicClosure) FullDay() bool {
+	return c.StartTime == "" && c.EndTime == ""
+}
+
+// Clinic closure types
+const (
+	ClosureTypeNational  = "national"  // Imported from the bundled national holiday dataset
+	ClosureTypeMunicipal = "municipal" // Municipal/state holidays added by the clinic
+	ClosureTypeCustom    = "custom"    // Any other closure (maintenance, collective vacation...)
+)
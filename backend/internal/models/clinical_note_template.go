@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ClinicalNoteTemplate is a reusable text for routine procedures (e.g. "Exodontia simples").
+// Placeholders such as {{paciente}}, {{dente}}, {{anestesico}} and {{data}} are filled when a
+// medical record is created from the template
+type ClinicalNoteTemplate struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Name       string `gorm:"size:150;not null" json:"name"`
+	Shortcut   string `gorm:"size:50;index" json:"shortcut"` // Typed for quick insertion, e.g. "exo"
+	Category   string `gorm:"size:50" json:"category"`       // e.g. endodontia, cirurgia
+	RecordType string `gorm:"size:50" json:"record_type"`    // Medical record type it fills (procedure, treatment...)
+
+	// nil: shared with the whole clinic; set: personal template of the professional
+	OwnerID *uint `gorm:"index" json:"owner_id"`
+
+	TreatmentProtocolID *uint              `gorm:"index" json:"treatment_protocol_id"`
+	TreatmentProtocol   *TreatmentProtocol `gorm:"foreignKey:TreatmentProtocolID" json:"treatment_protocol,omitempty"`
+
+	// Texts copied to the medical record fields of the same name
+	Diagnosis     string `gorm:"type:text" json:"diagnosis"`
+	TreatmentPlan string `gorm:"type:text" json:"treatment_plan"`
+	ProcedureDone string `gorm:"type:text" json:"procedure_done"`
+	Materials     string `gorm:"type:text" json:"materials"`
+	Evolution     string `gorm:"type:text" json:"evolution"`
+	Notes         string `gorm:"type:text" json:"notes"`
+
+	Active      bool `gorm:"default:true" json:"active"`
+	UsageCount  int  `gorm:"default:0" json:"usage_count"`
+	CreatedByID uint `json:"created_by_id"`
+}
+
+// TableName specifies the table name
+func (ClinicalNoteTemplate) TableName() string {
+	return "clinical_note_templates"
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Commission rule calculation types
+const (
+	CommissionTypePercentage = "percentage"
+	CommissionTypeFixed      = "fixed" // Amount per procedure
+)
+
+// Commission bases
+const (
+	CommissionBaseGross = "gross" // Amount received or procedure value
+	CommissionBaseNet   = "net"   // After card fees and lab costs
+)
+
+// Commission triggers
+const (
+	CommissionTriggerPayment    = "payment"    // When the patient pays
+	CommissionTriggerCompletion = "completion" // When the procedure is performed
+)
+
+// Commission sources
+const (
+	CommissionSourcePayment          = "payment"           // Income registered in the cash flow
+	CommissionSourceTreatmentPayment = "treatment_payment" // Installment of a treatment
+	CommissionSourceCompletion       = "completion"        // Performed plan item
+)
+
+// Commission statuses
+const (
+	CommissionStatusPending   = "pending"
+	CommissionStatusPaid      = "paid"
+	CommissionStatusCancelled = "cancelled"
+)
+
+// CommissionRule defines what professionals earn on procedures or payment categories.
+// Empty dentist, procedure and category match everything; the most specific active rule wins
+type CommissionRule struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Name      string `gorm:"size:100;not null" json:"name"`
+	DentistID *uint  `gorm:"index" json:"dentist_id"`   // Empty for every professional
+	Procedure string `gorm:"size:255" json:"procedure"` // Procedure name or code of the plan items
+	Category  string `gorm:"size:50" json:"category"`   // Payment category, e.g. treatment, orthodontics
+
+	Type    string  `gorm:"size:20;not null;default:'percentage'" json:"type"` // percentage, fixed
+	Value   float64 `gorm:"not null" json:"value"`                             // Percentage (0-100) or amount per procedure
+	Base    string  `gorm:"size:10;default:'gross'" json:"base"`               // gross, net
+	Trigger string  `gorm:"size:20;default:'payment'" json:"trigger"`          // payment, completion
+
+	Active bool   `gorm:"default:true" json:"active"`
+	Notes  string `gorm:"type:text" json:"notes"`
+}
+
+// TableName specifies the table name
+func (CommissionRule) TableName() string {
+	return "commission_rules"
+}
+
+// PaymentMethodFee is the fee the card operator charges on a payment method,
+// deducted from the base of net commissions
+type PaymentMethodFee struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	PaymentMethod string  `gorm:"size:30;not null;uniqueIndex" json:"payment_method"` // credit_card, debit_card...
+	FeePercent    float64 `json:"fee_percent"`
+}
+
+// TableName specifies the table name
+func (PaymentMethodFee) TableName() string {
+	return "payment_method_fees"
+}
+
+// CommissionPayout is a payment of the pending commissions of a professional for a period
+type CommissionPayout struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	DentistID   uint      `gorm:"not null;index" json:"dentist_id"`
+	DentistName string    `gorm:"size:255" json:"dentist_name"`
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+
+	Total           float64   `json:"total"`
+	CommissionCount int       `json:"commission_count"`
+	PaidAt          time.Time `json:"paid_at"`
+	PaymentMethod   string    `gorm:"size:30" json:"payment_method"`
+	ExpenseID       *uint     `json:"expense_id"` // Expense registered in the cash flow
+	PaidByID        uint      `json:"paid_by_id"`
+	Notes           string    `gorm:"type:text" json:"notes"`
+
+	Commissions []Commission `gorm:"foreignKey:PayoutID" json:"commissions,omitempty"`
+}
+
+// TableName specifies the table name
+func (CommissionPayout) TableName() string {
+	return "commission_payouts"
+}
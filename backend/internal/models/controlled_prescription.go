@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// ControlledPrescriptionBook keeps the sequential numbering of the controlled-substance
+// prescriptions (Receita de Controle Especial) of a prescriber
+type ControlledPrescriptionBook struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	PrescriberID uint `gorm:"not null;uniqueIndex" json:"prescriber_id"` // User who signs the prescriptions
+	LastNumber   int  `gorm:"not null;default:0" json:"last_number"`
+}
+
+// TableName specifies the table name
+func (ControlledPrescriptionBook) TableName() string {
+	return "controlled_prescription_books"
+}
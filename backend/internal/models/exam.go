@@ -29,9 +29,63 @@ type Exam struct {
 	FileType string `json:"file_type"`                 // MIME type (image/jpeg, application/pdf, etc)
 	FileSize int64  `json:"file_size"`                 // Tamanho do arquivo em bytes
 
+	// DICOM study (radiographs, CBCT): the files are kept as ExamInstance rows and
+	// FileURL/S3Key point to the first instance
+	IsDICOM          bool   `gorm:"default:false" json:"is_dicom"`
+	StudyInstanceUID string `gorm:"size:64;index" json:"study_instance_uid,omitempty"`
+	Modality         string `gorm:"size:16" json:"modality,omitempty"`            // DX, IO, PX, CT...
+	BodyPart         string `gorm:"size:64" json:"body_part,omitempty"`           // Body part examined
+	DicomPatientName string `gorm:"size:255" json:"dicom_patient_name,omitempty"` // Patient name in the header
+	DicomPatientID   string `gorm:"size:64" json:"dicom_patient_id,omitempty"`    // Patient ID in the header
+	PatientMismatch  bool   `gorm:"default:false" json:"patient_mismatch"`        // Header patient differs and the upload was confirmed
+	InstanceCount    int    `gorm:"default:0" json:"instance_count"`
+
+	// Renditions stored next to the original, generated in the background: a thumbnail for
+	// listings and a web-sized preview (JPEG; PNG rendering of the image for DICOM studies)
+	ThumbnailURL   string `json:"thumbnail_url,omitempty"`
+	ThumbnailS3Key string `json:"thumbnail_s3_key,omitempty"`
+	PreviewURL     string `json:"preview_url,omitempty"`
+	PreviewS3Key   string `json:"preview_s3_key,omitempty"`
+	PreviewStatus  string `gorm:"size:20" json:"preview_status,omitempty"` // pending, ready, failed, unsupported
+	PreviewError   string `gorm:"size:255" json:"preview_error,omitempty"`
+
 	// Upload info (foreign key only)
 	UploadedByID uint `gorm:"not null" json:"uploaded_by_id"`
 
 	// Additional notes
 	Notes       string `gorm:"type:text" json:"notes"`
 }
+
+// Exam preview statuses
+const (
+	ExamPreviewPending     = "pending"
+	ExamPreviewReady       = "ready"
+	ExamPreviewFailed      = "failed"
+	ExamPreviewUnsupported = "unsupported" // File type without preview, or converter not installed
+)
+
+// ExamInstance is one DICOM file (instance) of an exam study
+type ExamInstance struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	ExamID            uint   `gorm:"not null;index" json:"exam_id"`
+	SeriesInstanceUID string `gorm:"size:64" json:"series_instance_uid"`
+	SOPInstanceUID    string `gorm:"size:64;not null" json:"sop_instance_uid"`
+	SeriesNumber      int    `json:"series_number"`
+	InstanceNumber    int    `json:"instance_number"`
+	Modality          string `gorm:"size:16" json:"modality"`
+	NumberOfFrames    int    `json:"number_of_frames"`
+
+	FileURL  string `gorm:"not null" json:"file_url"`
+	S3Key    string `gorm:"not null" json:"s3_key"`
+	FileName string `json:"file_name"`
+	FileSize int64  `json:"file_size"`
+}
+
+// TableName specifies the table name
+func (ExamInstance) TableName() string {
+	return "exam_instances"
+}
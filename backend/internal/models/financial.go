@@ -33,6 +33,9 @@ type Budget struct {
 
 	Notes       string `gorm:"type:text" json:"notes"`
 
+	// Structured plan items (Items is generated from them)
+	PlanItems   []TreatmentPlanItem `gorm:"foreignKey:BudgetID" json:"plan_items,omitempty"`
+
 	// Relationships
 	Payments    []Payment `gorm:"foreignKey:BudgetID" json:"payments,omitempty"`
 }
@@ -83,6 +86,7 @@ type Payment struct {
 }
 
 // Commission represents professional commissions
+// Generated by the commission rules when a payment is received or a procedure is performed
 type Commission struct {
 	ID        uint           `gorm:"primarykey" json:"id"`
 	CreatedAt time.Time      `json:"created_at"`
@@ -92,14 +96,29 @@ type Commission struct {
 	DentistID uint    `gorm:"not null;index" json:"dentist_id"`
 	Dentist   *User   `gorm:"foreignKey:DentistID" json:"dentist,omitempty"`
 
-	PaymentID uint     `gorm:"not null;index" json:"payment_id"`
-	Payment   *Payment `gorm:"foreignKey:PaymentID" json:"payment,omitempty"`
-
-	Percentage float64 `json:"percentage"`
-	Amount     float64 `json:"amount"`
-
-	Status     string     `gorm:"default:'pending'" json:"status"` // pending, paid
-	PaidDate   *time.Time `json:"paid_date"`
+	// Origin: a payment, a treatment payment or a performed plan item
+	Source             string    `gorm:"size:20;index" json:"source"` // payment, treatment_payment, completion
+	PaymentID          *uint     `gorm:"index" json:"payment_id"`
+	Payment            *Payment  `gorm:"foreignKey:PaymentID" json:"payment,omitempty"`
+	TreatmentPaymentID *uint     `gorm:"index" json:"treatment_payment_id"`
+	PlanItemID         *uint     `gorm:"index" json:"plan_item_id"`
+	PatientID          *uint     `gorm:"index" json:"patient_id"`
+	RuleID             *uint     `json:"rule_id"`
+	Description        string    `gorm:"size:255" json:"description"`
+	ReferenceDate      time.Time `gorm:"index" json:"reference_date"` // Payment or completion date (statement period)
+
+	// Calculation
+	GrossAmount float64 `json:"gross_amount"`
+	CardFee     float64 `json:"card_fee"`
+	LabCost     float64 `json:"lab_cost"`
+	BaseAmount  float64 `json:"base_amount"`
+	Percentage  float64 `json:"percentage"` // 0 for fixed commissions
+	Amount      float64 `json:"amount"`     // Negative when offsetting a commission already paid
+
+	Status       string     `gorm:"default:'pending'" json:"status"` // pending, paid, cancelled
+	PaidDate     *time.Time `json:"paid_date"`
+	PayoutID     *uint      `gorm:"index" json:"payout_id"`
+	ReversalOfID *uint      `gorm:"index" json:"reversal_of_id"` // Commission offset by this one
 }
 
 // Treatment represents an approved budget being treated/paid
@@ -139,7 +158,8 @@ type Treatment struct {
 	Notes string `gorm:"type:text" json:"notes"`
 
 	// Relationships
-	TreatmentPayments []TreatmentPayment `gorm:"foreignKey:TreatmentID" json:"treatment_payments,omitempty"`
+	TreatmentPayments []TreatmentPayment  `gorm:"foreignKey:TreatmentID" json:"treatment_payments,omitempty"`
+	PlanItems         []TreatmentPlanItem `gorm:"foreignKey:TreatmentID" json:"plan_items,omitempty"`
 }
 
 // TreatmentPayment represents a payment entry for a treatment
@@ -0,0 +1,170 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Insurance guide statuses
+const (
+	InsuranceGuideStatusPending       = "pending"        // Not sent to the insurer yet
+	InsuranceGuideStatusSent          = "sent"           // Sent in a lot, waiting for the payment return
+	InsuranceGuideStatusPaid          = "paid"           // Paid in full
+	InsuranceGuideStatusPartiallyPaid = "partially_paid" // Paid with disallowances (glosa)
+	InsuranceGuideStatusDenied        = "denied"         // Fully disallowed
+)
+
+// Insurance lot statuses
+const (
+	InsuranceLotStatusGenerated = "generated" // XML generated, not sent yet
+	InsuranceLotStatusSent      = "sent"      // Delivered to the insurer
+	InsuranceLotStatusClosed    = "closed"    // Payment return reconciled
+)
+
+// InsuranceCompany is a dental insurer (convênio) the clinic bills through TISS
+type InsuranceCompany struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Name         string `gorm:"size:255;not null" json:"name"`
+	ANSRegistry  string `gorm:"size:6;not null" json:"ans_registry"` // Registro ANS of the insurer (6 digits)
+	CNPJ         string `gorm:"size:14" json:"cnpj"`
+	ProviderCode string `gorm:"size:14" json:"provider_code"` // Code of the clinic at the insurer (empty: clinic CNPJ)
+
+	PaymentTermDays int    `gorm:"default:30" json:"payment_term_days"`
+	Phone           string `gorm:"size:20" json:"phone"`
+	Email           string `gorm:"size:255" json:"email"`
+	Active          bool   `gorm:"default:true" json:"active"`
+	Notes           string `gorm:"type:text" json:"notes"`
+
+	LastLotNumber   int `gorm:"default:0" json:"last_lot_number"`
+	LastGuideNumber int `gorm:"default:0" json:"last_guide_number"`
+}
+
+// TableName specifies the table name
+func (InsuranceCompany) TableName() string {
+	return "insurance_companies"
+}
+
+// InsurancePrice is the price an insurer pays for a procedure (TUSS code)
+type InsurancePrice struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	InsuranceID   uint    `gorm:"not null;index" json:"insurance_id"`
+	ProcedureCode string  `gorm:"size:10;not null" json:"procedure_code"` // TUSS code (8 digits)
+	Description   string  `gorm:"size:255" json:"description"`
+	Price         float64 `gorm:"not null" json:"price"`
+	Active        bool    `gorm:"default:true" json:"active"`
+}
+
+// TableName specifies the table name
+func (InsurancePrice) TableName() string {
+	return "insurance_prices"
+}
+
+// InsuranceGuide is a dental treatment guide (GTO) billed to an insurer
+type InsuranceGuide struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	InsuranceID uint              `gorm:"not null;index" json:"insurance_id"`
+	Insurance   *InsuranceCompany `gorm:"foreignKey:InsuranceID" json:"insurance,omitempty"`
+	LotID       *uint             `gorm:"index" json:"lot_id"`
+
+	PatientID uint     `gorm:"not null;index" json:"patient_id"`
+	Patient   *Patient `gorm:"foreignKey:PatientID" json:"patient,omitempty"`
+	BudgetID  *uint    `gorm:"index" json:"budget_id"`
+
+	// Guide data, copied when the guide is created
+	GuideNumber         string    `gorm:"size:20;not null;index" json:"guide_number"` // numeroGuiaPrestador
+	AuthorizationNumber string    `gorm:"size:20" json:"authorization_number"`        // senha de autorização
+	CardNumber          string    `gorm:"size:255" json:"card_number"`                // Encrypted, like the patient insurance number
+	BeneficiaryName     string    `gorm:"size:255" json:"beneficiary_name"`
+	DentistID           uint      `gorm:"not null;index" json:"dentist_id"`
+	DentistName         string    `gorm:"size:255" json:"dentist_name"`
+	DentistCRO          string    `gorm:"size:50" json:"dentist_cro"`
+	ServiceDate         time.Time `json:"service_date"`
+
+	Status          string     `gorm:"size:20;default:'pending';index" json:"status"`
+	TotalValue      float64    `json:"total_value"`
+	PaidValue       float64    `json:"paid_value"`
+	DisallowedValue float64    `json:"disallowed_value"` // Glosa
+	PaidDate        *time.Time `json:"paid_date"`
+	PaymentID       *uint      `json:"payment_id"` // Income registered when the return is reconciled
+	Notes           string     `gorm:"type:text" json:"notes"`
+
+	Items []InsuranceGuideItem `gorm:"foreignKey:GuideID" json:"items,omitempty"`
+}
+
+// TableName specifies the table name
+func (InsuranceGuide) TableName() string {
+	return "insurance_guides"
+}
+
+// InsuranceGuideItem is a procedure billed in a guide
+type InsuranceGuideItem struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	GuideID       uint       `gorm:"not null;index" json:"guide_id"`
+	PlanItemID    *uint      `gorm:"index" json:"plan_item_id"`
+	ProcedureCode string     `gorm:"size:10;not null" json:"procedure_code"`
+	Description   string     `gorm:"size:255" json:"description"`
+	Tooth         *int       `json:"tooth"`
+	Surfaces      string     `gorm:"size:5" json:"surfaces"`
+	Quantity      int        `gorm:"default:1" json:"quantity"`
+	UnitPrice     float64    `json:"unit_price"`
+	Total         float64    `json:"total"`
+	ExecutionDate *time.Time `json:"execution_date"`
+
+	// Payment return
+	PaidValue          float64 `json:"paid_value"`
+	DisallowedValue    float64 `json:"disallowed_value"`
+	DisallowanceCode   string  `gorm:"size:10" json:"disallowance_code"` // Código de glosa (TISS table 38)
+	DisallowanceReason string  `gorm:"type:text" json:"disallowance_reason"`
+}
+
+// TableName specifies the table name
+func (InsuranceGuideItem) TableName() string {
+	return "insurance_guide_items"
+}
+
+// InsuranceLot is a batch of guides sent to an insurer in one TISS XML message
+type InsuranceLot struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	InsuranceID uint              `gorm:"not null;index" json:"insurance_id"`
+	Insurance   *InsuranceCompany `gorm:"foreignKey:InsuranceID" json:"insurance,omitempty"`
+	LotNumber   string            `gorm:"size:12;not null" json:"lot_number"`
+
+	Status      string     `gorm:"size:20;default:'generated';index" json:"status"`
+	TISSVersion string     `gorm:"size:10" json:"tiss_version"`
+	GuideCount  int        `json:"guide_count"`
+	TotalValue  float64    `json:"total_value"`
+	PaidValue   float64    `json:"paid_value"`
+	Hash        string     `gorm:"size:32" json:"hash"` // MD5 of the message contents (epílogo)
+	XML         string     `gorm:"type:text" json:"-"`
+	SentAt      *time.Time `json:"sent_at"`
+	ClosedAt    *time.Time `json:"closed_at"`
+	CreatedByID uint       `json:"created_by_id"`
+
+	Guides []InsuranceGuide `gorm:"foreignKey:LotID" json:"guides,omitempty"`
+}
+
+// TableName specifies the table name
+func (InsuranceLot) TableName() string {
+	return "insurance_lots"
+}
@@ -49,6 +49,10 @@ type MedicalRecord struct {
 
 	Notes         string `gorm:"type:text" json:"notes"`
 
+	// Clinical note template used to fill the record, and the placeholder values sent on creation
+	NoteTemplateID *uint             `gorm:"index" json:"note_template_id,omitempty"`
+	TemplateValues map[string]string `gorm:"-" json:"template_values,omitempty"`
+
 	// Digital Signature (ICP-Brasil A1)
 	IsSigned           bool       `gorm:"default:false" json:"is_signed"`
 	SignedAt           *time.Time `json:"signed_at,omitempty"`
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+)
+
+// MedicalRecordAddendum is an append-only correction or complement to a signed medical record
+// Signed records cannot be changed (CFO record-keeping rules); addenda are never edited or deleted
+type MedicalRecordAddendum struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	MedicalRecordID uint  `gorm:"not null;index" json:"medical_record_id"`
+	AuthorID        uint  `gorm:"not null" json:"author_id"`
+	Author          *User `gorm:"foreignKey:AuthorID" json:"author,omitempty"`
+
+	Reason  string `gorm:"size:255;not null" json:"reason"` // Why the record is being complemented
+	Content string `gorm:"type:text;not null" json:"content"`
+
+	// Digital Signature (ICP-Brasil A1), signed separately from the record
+	IsSigned              bool       `gorm:"default:false" json:"is_signed"`
+	SignedAt              *time.Time `json:"signed_at,omitempty"`
+	SignedByID            *uint      `json:"signed_by_id,omitempty"`
+	SignedByName          string     `json:"signed_by_name,omitempty"`
+	SignedByCRO           string     `json:"signed_by_cro,omitempty"`
+	CertificateID         *uint      `json:"certificate_id,omitempty"`
+	CertificateThumbprint string     `json:"certificate_thumbprint,omitempty"`
+	SignatureHash         string     `json:"signature_hash,omitempty"` // SHA-256 hash of signed content
+}
+
+// TableName specifies the table name
+func (MedicalRecordAddendum) TableName() string {
+	return "medical_record_addenda"
+}
+
+// MedicalRecordRevision keeps the content a medical record had before each change
+// Only unsigned records can change, so the revisions end when the record is signed
+type MedicalRecordRevision struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"` // When the change was made
+
+	MedicalRecordID uint   `gorm:"not null;index" json:"medical_record_id"`
+	Revision        int    `gorm:"not null" json:"revision"` // 1 for the first change
+	EditedByID      uint   `json:"edited_by_id"`
+	ChangedFields   string `gorm:"type:jsonb" json:"changed_fields"` // JSON array of field names
+	Snapshot        string `gorm:"type:jsonb" json:"snapshot"`       // JSON with the previous content
+}
+
+// TableName specifies the table name
+func (MedicalRecordRevision) TableName() string {
+	return "medical_record_revisions"
+}
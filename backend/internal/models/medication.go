@@ -0,0 +1,114 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Controlled-substance lists of Portaria SVS/MS 344/98
+var controlledClasses = map[string]bool{
+	"A1": true, "A2": true, "A3": true,
+	"B1": true, "B2": true,
+	"C1": true, "C2": true, "C3": true, "C4": true, "C5": true,
+}
+
+// IsValidControlledClass reports whether class is a Portaria 344 list ("" means not controlled)
+func IsValidControlledClass(class string) bool {
+	return class == "" || controlledClasses[class]
+}
+
+// StringList is a list of texts (stored as a JSON array)
+type StringList []string
+
+// Value implements driver.Valuer for database storage
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(l)
+	return string(data), err
+}
+
+// Scan implements sql.Scanner for database retrieval
+func (l *StringList) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, l)
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	default:
+		return fmt.Errorf("cannot scan type %T into StringList", value)
+	}
+}
+
+// Medication is an entry of the clinic's medication catalog
+type Medication struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Name             string     `gorm:"size:255;not null" json:"name"` // Commercial or usual name
+	ActiveIngredient string     `gorm:"size:255;not null;index" json:"active_ingredient"`
+	TherapeuticClass string     `gorm:"size:50" json:"therapeutic_class"` // e.g. nsaid, penicillin; used by the interaction check
+	AllergyGroup     string     `gorm:"size:100" json:"allergy_group"`    // e.g. "penicilina", "sulfa"
+	Presentations    StringList `gorm:"type:jsonb" json:"presentations"`  // e.g. "Cápsula 500 mg"
+	DentalDosages    StringList `gorm:"type:jsonb" json:"dental_dosages"` // e.g. "1 cápsula de 8/8h por 7 dias"
+	ControlledClass  string     `gorm:"size:5" json:"controlled_class"`   // Portaria 344 list (C1, B1...), empty when not controlled
+	Active           bool       `gorm:"default:true" json:"active"`
+}
+
+// TableName specifies the table name
+func (Medication) TableName() string {
+	return "medications"
+}
+
+// PrescriptionItem is a structured prescription line referencing the medication catalog
+// Medication data is copied so the prescription does not change with the catalog
+type PrescriptionItem struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	PrescriptionID   uint   `gorm:"not null;index" json:"prescription_id"`
+	Position         int    `json:"position"`
+	MedicationID     uint   `gorm:"not null;index" json:"medication_id"`
+	MedicationName   string `gorm:"size:255" json:"medication_name"`
+	ActiveIngredient string `gorm:"size:255" json:"active_ingredient"`
+	ControlledClass  string `gorm:"size:5" json:"controlled_class"`
+	Presentation     string `gorm:"size:255" json:"presentation"`
+	Route            string `gorm:"size:50" json:"route"`     // e.g. "Uso oral"
+	Quantity         string `gorm:"size:100" json:"quantity"` // e.g. "21 cápsulas"
+	Dosage           string `gorm:"type:text" json:"dosage"`  // Posology
+}
+
+// TableName specifies the table name
+func (PrescriptionItem) TableName() string {
+	return "prescription_items"
+}
+
+// PrescriptionSafetyCheck records the allergy/interaction check run on a prescription
+// and whether the professional went ahead despite the warnings
+type PrescriptionSafetyCheck struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	PrescriptionID uint   `gorm:"not null;index" json:"prescription_id"`
+	Stage          string `gorm:"size:20;not null" json:"stage"` // create, issue, sign
+	Warnings       string `gorm:"type:jsonb" json:"warnings"`    // JSON array of warnings
+	WarningCount   int    `json:"warning_count"`
+	Overridden     bool   `gorm:"default:false" json:"overridden"`
+	OverrideReason string `gorm:"type:text" json:"override_reason"`
+	CheckedByID    uint   `json:"checked_by_id"`
+}
+
+// TableName specifies the table name
+func (PrescriptionSafetyCheck) TableName() string {
+	return "prescription_safety_checks"
+}
@@ -0,0 +1,53 @@
+package models
+
+// Defaults of the no-show policy window
+const (
+	DefaultNoShowThreshold    = 3
+	DefaultNoShowWindowMonths = 6
+)
+
+// NoShowRestriction is what the tenant no-show policy imposes on a patient
+type NoShowRestriction struct {
+	Restricted          bool    `json:"restricted"`
+	RecentNoShows       int64   `json:"recent_no_shows"`
+	Threshold           int     `json:"threshold"`
+	WindowMonths        int     `json:"window_months"`
+	RequireConfirmation bool    `json:"require_confirmation"`
+	BlockSelfBooking    bool    `json:"block_self_booking"`
+	RequireDeposit      bool    `json:"require_deposit"`
+	DepositAmount       float64 `json:"deposit_amount,omitempty"`
+}
+
+// NoShowPolicyWindow returns the threshold and window (in months) of the no-show policy
+func (s TenantSettings) NoShowPolicyWindow() (threshold int, months int) {
+	threshold, months = s.NoShowThreshold, s.NoShowWindowMonths
+	if threshold < 1 {
+		threshold = DefaultNoShowThreshold
+	}
+	if months < 1 {
+		months = DefaultNoShowWindowMonths
+	}
+	return threshold, months
+}
+
+// EvaluateNoShowPolicy returns the restrictions for a patient with recentNoShows inside the policy window
+func (s TenantSettings) EvaluateNoShowPolicy(recentNoShows int64) NoShowRestriction {
+	threshold, months := s.NoShowPolicyWindow()
+	restriction := NoShowRestriction{
+		RecentNoShows: recentNoShows,
+		Threshold:     threshold,
+		WindowMonths:  months,
+	}
+	if !s.NoShowPolicyEnabled || recentNoShows < int64(threshold) {
+		return restriction
+	}
+
+	restriction.RequireConfirmation = s.NoShowRequireConfirmation
+	restriction.BlockSelfBooking = s.NoShowBlockSelfBooking
+	restriction.RequireDeposit = s.NoShowRequireDeposit
+	if restriction.RequireDeposit {
+		restriction.DepositAmount = s.NoShowDepositAmount
+	}
+	restriction.Restricted = restriction.RequireConfirmation || restriction.BlockSelfBooking || restriction.RequireDeposit
+	return restriction
+}
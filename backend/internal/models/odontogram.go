@@ -0,0 +1,87 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ToothEvent is a condition found (existing) or planned on a tooth or on some of its surfaces
+// The patient's odontogram is rebuilt from these events, so the chart can be seen as of any date
+type ToothEvent struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	PatientID       uint  `gorm:"not null;index" json:"patient_id"`
+	MedicalRecordID *uint `gorm:"index" json:"medical_record_id"`
+	DentistID       uint  `json:"dentist_id"`
+
+	Tooth      int       `gorm:"not null" json:"tooth"`                   // FDI notation: 11-48 permanent, 51-85 deciduous
+	Surfaces   string    `gorm:"size:5" json:"surfaces"`                  // Combination of M, D, O, V, L (empty for the whole tooth)
+	Condition  string    `gorm:"size:30;not null" json:"condition"`       // See ToothCondition* constants
+	State      string    `gorm:"size:20;default:'existing'" json:"state"` // existing, planned
+	Notes      string    `gorm:"type:text" json:"notes"`
+	RecordedAt time.Time `gorm:"not null;index" json:"recorded_at"` // When the condition was found or planned
+}
+
+// TableName specifies the table name
+func (ToothEvent) TableName() string {
+	return "tooth_events"
+}
+
+// Tooth event states
+const (
+	ToothStateExisting = "existing"
+	ToothStatePlanned  = "planned"
+)
+
+// Tooth conditions
+// The first seven are the statuses of the legacy MedicalRecord.Odontogram JSON
+const (
+	ToothConditionHealthy     = "healthy"
+	ToothConditionCavity      = "cavity"
+	ToothConditionRestoration = "restoration"
+	ToothConditionMissing     = "missing"
+	ToothConditionRootCanal   = "root_canal"
+	ToothConditionCrown       = "crown"
+	ToothConditionImplant     = "implant"
+	ToothConditionExtraction  = "extraction" // Indicated for extraction
+	ToothConditionSealant     = "sealant"
+	ToothConditionFracture    = "fracture"
+)
+
+// ToothConditionLabels are the Portuguese names of the conditions
+var ToothConditionLabels = map[string]string{
+	ToothConditionHealthy:     "Hígido",
+	ToothConditionCavity:      "Cárie",
+	ToothConditionRestoration: "Restauração",
+	ToothConditionMissing:     "Ausente",
+	ToothConditionRootCanal:   "Tratamento de canal",
+	ToothConditionCrown:       "Coroa",
+	ToothConditionImplant:     "Implante",
+	ToothConditionExtraction:  "Extração",
+	ToothConditionSealant:     "Selante",
+	ToothConditionFracture:    "Fratura",
+}
+
+// wholeToothConditions apply to the tooth as a whole and cannot be set per surface
+var wholeToothConditions = map[string]bool{
+	ToothConditionMissing:    true,
+	ToothConditionRootCanal:  true,
+	ToothConditionCrown:      true,
+	ToothConditionImplant:    true,
+	ToothConditionExtraction: true,
+}
+
+// IsValidToothCondition reports whether condition is a known odontogram condition
+func IsValidToothCondition(condition string) bool {
+	_, ok := ToothConditionLabels[condition]
+	return ok
+}
+
+// IsWholeToothCondition reports whether condition applies only to the whole tooth
+func IsWholeToothCondition(condition string) bool {
+	return wholeToothConditions[condition]
+}
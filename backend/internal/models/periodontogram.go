@@ -0,0 +1,136 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PerioSiteCount is the number of probing sites per tooth
+const PerioSiteCount = 6
+
+// Probing sites, in the order the per-site arrays are stored:
+// distovestibular, vestibular, mesiovestibular, distolingual, lingual, mesiolingual
+const (
+	PerioSiteDV = iota
+	PerioSiteV
+	PerioSiteMV
+	PerioSiteDL
+	PerioSiteL
+	PerioSiteML
+)
+
+// PerioSiteLabels are the short names of the sites, indexed by PerioSite* constants
+var PerioSiteLabels = [PerioSiteCount]string{"DV", "V", "MV", "DL", "L", "ML"}
+
+// PerioSiteValues holds one measurement in mm per site (stored as a JSON array)
+type PerioSiteValues [PerioSiteCount]int
+
+// Value implements driver.Valuer for database storage
+func (v PerioSiteValues) Value() (driver.Value, error) {
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+// Scan implements sql.Scanner for database retrieval
+func (v *PerioSiteValues) Scan(value interface{}) error {
+	return scanPerioSites(value, v)
+}
+
+// PerioSiteFlags holds a yes/no finding per site (stored as a JSON array)
+type PerioSiteFlags [PerioSiteCount]bool
+
+// Value implements driver.Valuer for database storage
+func (f PerioSiteFlags) Value() (driver.Value, error) {
+	data, err := json.Marshal(f)
+	return string(data), err
+}
+
+// Scan implements sql.Scanner for database retrieval
+func (f *PerioSiteFlags) Scan(value interface{}) error {
+	return scanPerioSites(value, f)
+}
+
+// Count returns how many sites are flagged
+func (f PerioSiteFlags) Count() int {
+	n := 0
+	for _, flagged := range f {
+		if flagged {
+			n++
+		}
+	}
+	return n
+}
+
+func scanPerioSites(value interface{}, dest interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, dest)
+	case string:
+		return json.Unmarshal([]byte(v), dest)
+	default:
+		return fmt.Errorf("cannot scan type %T into periodontal sites", value)
+	}
+}
+
+// PeriodontalExam is a periodontal chart (periodontogram) of a patient
+type PeriodontalExam struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	PatientID       uint     `gorm:"not null;index" json:"patient_id"`
+	Patient         *Patient `gorm:"foreignKey:PatientID" json:"patient,omitempty"`
+	MedicalRecordID *uint    `gorm:"index" json:"medical_record_id"`
+	DentistID       uint     `gorm:"not null" json:"dentist_id"`
+	Dentist         *User    `gorm:"foreignKey:DentistID" json:"dentist,omitempty"`
+
+	ExamDate time.Time `gorm:"not null" json:"exam_date"`
+	Notes    string    `gorm:"type:text" json:"notes"`
+
+	Teeth []PeriodontalTooth `gorm:"foreignKey:ExamID" json:"teeth"`
+}
+
+// TableName specifies the table name
+func (PeriodontalExam) TableName() string {
+	return "periodontal_exams"
+}
+
+// PeriodontalTooth holds the measurements of one tooth in a periodontal exam
+type PeriodontalTooth struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ExamID  uint `gorm:"not null;index" json:"exam_id"`
+	Tooth   int  `gorm:"not null" json:"tooth"` // FDI notation
+	Missing bool `gorm:"default:false" json:"missing"`
+
+	ProbingDepth PerioSiteValues `gorm:"type:jsonb" json:"probing_depth"` // mm, per site
+	Recession    PerioSiteValues `gorm:"type:jsonb" json:"recession"`     // mm, gingival margin below the CEJ (negative = hyperplasia)
+	Bleeding     PerioSiteFlags  `gorm:"type:jsonb" json:"bleeding"`      // Bleeding on probing
+	Plaque       PerioSiteFlags  `gorm:"type:jsonb" json:"plaque"`
+
+	Mobility  int `gorm:"default:0" json:"mobility"`  // Miller grade 0-3
+	Furcation int `gorm:"default:0" json:"furcation"` // Glickman grade 0-3 (multi-rooted teeth)
+}
+
+// TableName specifies the table name
+func (PeriodontalTooth) TableName() string {
+	return "periodontal_teeth"
+}
+
+// AttachmentLevel returns the clinical attachment level (probing depth + recession) of each site
+func (t PeriodontalTooth) AttachmentLevel() PerioSiteValues {
+	var cal PerioSiteValues
+	for i := range cal {
+		cal[i] = t.ProbingDepth[i] + t.Recession[i]
+	}
+	return cal
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PIX charge kinds
+const (
+	PixChargeStatic  = "static"  // BR Code with the clinic key, confirmed by the staff
+	PixChargeDynamic = "dynamic" // Charge created at the PSP, which confirms the payment
+)
+
+// PIX charge purposes - what is settled when the charge is paid
+const (
+	PixPurposePayment     = "payment"     // A pending Payment
+	PixPurposeInstallment = "installment" // An installment of a treatment (carnê)
+	PixPurposeBudget      = "budget"      // The total of a budget
+	PixPurposeDeposit     = "deposit"     // The deposit (sinal) of a self-booked appointment
+)
+
+// PIX charge statuses
+const (
+	PixChargeStatusActive    = "active"
+	PixChargeStatusPaid      = "paid"
+	PixChargeStatusExpired   = "expired"
+	PixChargeStatusCancelled = "cancelled"
+)
+
+// PixCharge is a PIX BR Code issued by the clinic, with what it pays for
+type PixCharge struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	TxID     string `gorm:"size:35;not null;uniqueIndex" json:"txid"`
+	Kind     string `gorm:"size:10;not null" json:"kind"`
+	Provider string `gorm:"size:30" json:"provider"` // PSP of dynamic charges
+	Purpose  string `gorm:"size:20;not null;index" json:"purpose"`
+
+	// What the charge pays for, depending on the purpose
+	PaymentID         *uint `gorm:"index" json:"payment_id"`
+	TreatmentID       *uint `gorm:"index" json:"treatment_id"`
+	InstallmentNumber int   `json:"installment_number"`
+	BudgetID          *uint `gorm:"index" json:"budget_id"`
+	AppointmentID     *uint `gorm:"index" json:"appointment_id"`
+	PatientID         *uint `gorm:"index" json:"patient_id"`
+
+	Amount      float64    `gorm:"not null" json:"amount"`
+	Description string     `gorm:"size:255" json:"description"`
+	Payload     string     `gorm:"type:text;not null" json:"payload"` // "Copia e cola" BR Code
+	Location    string     `gorm:"size:255" json:"location"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+
+	Status     string     `gorm:"size:20;default:'active';index" json:"status"`
+	PaidAt     *time.Time `json:"paid_at"`
+	PaidAmount float64    `json:"paid_amount"`
+	EndToEndID string     `gorm:"size:40" json:"end_to_end_id"` // Identifier of the PIX transfer
+	// Receipt registered when paid: the TreatmentPayment of installments, the Payment otherwise
+	TreatmentPaymentID *uint `json:"treatment_payment_id"`
+	SettledPaymentID   *uint `json:"settled_payment_id"`
+	ConfirmedByID      *uint `json:"confirmed_by_id"` // Staff member who confirmed a static charge
+	CreatedByID        *uint `json:"created_by_id"`
+}
+
+// TableName specifies the table name
+func (PixCharge) TableName() string {
+	return "pix_charges"
+}
@@ -6,6 +6,9 @@ import (
 	"gorm.io/gorm"
 )
 
+// PrescriptionTypeControlled is the Receita de Controle Especial (Portaria SVS/MS 344/98)
+const PrescriptionTypeControlled = "controlled"
+
 // Prescription represents medical prescriptions and reports (receituário)
 type Prescription struct {
 	ID        uint           `gorm:"primarykey" json:"id"`
@@ -21,7 +24,7 @@ type Prescription struct {
 	Dentist   *User `gorm:"foreignKey:DentistID" json:"dentist,omitempty"`
 
 	// Type of document
-	Type string `json:"type"` // prescription, medical_report, certificate, referral
+	Type string `json:"type"` // prescription, controlled, medical_report, certificate, referral
 
 	// Prescription content
 	Title       string `json:"title"`        // e.g., "Receita Médica", "Atestado Odontológico"
@@ -29,6 +32,14 @@ type Prescription struct {
 	Content     string `gorm:"type:text;not null" json:"content"` // Main content/instructions
 	Diagnosis   string `gorm:"type:text" json:"diagnosis"`
 
+	// Structured items from the medication catalog (Medications is generated from them)
+	Items        []PrescriptionItem        `gorm:"foreignKey:PrescriptionID" json:"items,omitempty"`
+	SafetyChecks []PrescriptionSafetyCheck `gorm:"foreignKey:PrescriptionID" json:"safety_checks,omitempty"`
+
+	// Controlled-substance prescription: number in the prescriber's book, assigned when issued
+	ControlledNumber       *int  `json:"controlled_number,omitempty"`
+	ControlledPrescriberID *uint `gorm:"index" json:"controlled_prescriber_id,omitempty"`
+
 	// Additional info
 	ValidUntil       *time.Time `json:"valid_until"` // Prescription expiration
 	Notes            string     `gorm:"type:text" json:"notes"`
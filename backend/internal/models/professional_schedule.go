@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ProfessionalSchedule is a weekly availability block for a professional
+// A dentist may have several blocks per weekday (e.g. 08:00-12:00 and 14:00-18:00)
+// Dentists without any block follow the clinic-wide working hours
+type ProfessionalSchedule struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	DentistID uint  `gorm:"not null;index" json:"dentist_id"`
+	Dentist   *User `gorm:"foreignKey:DentistID" json:"dentist,omitempty"`
+
+	Weekday   int    `gorm:"not null" json:"weekday"`           // 0 = Sunday ... 6 = Saturday
+	StartTime string `gorm:"size:5;not null" json:"start_time"` // Format: "HH:MM"
+	EndTime   string `gorm:"size:5;not null" json:"end_time"`   // Format: "HH:MM"
+}
+
+// ProfessionalScheduleOverride replaces the weekly schedule on a specific date
+// All overrides of the same date are combined; Closed means the professional does not work that day
+type ProfessionalScheduleOverride struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	DentistID uint  `gorm:"not null;index" json:"dentist_id"`
+	Dentist   *User `gorm:"foreignKey:DentistID" json:"dentist,omitempty"`
+
+	Date      time.Time `gorm:"type:date;not null;index" json:"date"`
+	StartTime string    `gorm:"size:5" json:"start_time"` // Format: "HH:MM" (empty when closed)
+	EndTime   string    `gorm:"size:5" json:"end_time"`   // Format: "HH:MM" (empty when closed)
+	Closed    bool      `gorm:"default:false" json:"closed"`
+	Reason    string    `json:"reason"`
+}
+
+// ProfessionalAbsence blocks a professional's agenda for a period (vacation, sick leave, course...)
+type ProfessionalAbsence struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	DentistID uint  `gorm:"not null;index" json:"dentist_id"`
+	Dentist   *User `gorm:"foreignKey:DentistID" json:"dentist,omitempty"`
+
+	StartAt LocalTime `gorm:"not null;index;type:timestamp" json:"start_at"`
+	EndAt   LocalTime `gorm:"not null;type:timestamp" json:"end_at"`
+	Type    string    `gorm:"default:'absence'" json:"type"` // vacation, sick_leave, course, absence
+	Reason  string    `gorm:"type:text" json:"reason"`
+
+	CreatedBy uint `json:"created_by"`
+}
+
+// Professional absence types
+const (
+	AbsenceTypeVacation  = "vacation"
+	AbsenceTypeSickLeave = "sick_leave"
+	AbsenceTypeCourse    = "course"
+	AbsenceTypeAbsence   = "absence"
+)
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Referral statuses
+const (
+	ReferralStatusSent           = "sent"            // Letter handed to the patient, waiting for the appointment
+	ReferralStatusScheduled      = "scheduled"       // Patient scheduled with the specialist
+	ReferralStatusReportReceived = "report_received" // Specialist report returned and attached
+	ReferralStatusCancelled      = "cancelled"
+)
+
+// Referral urgencies
+const (
+	ReferralUrgencyRoutine  = "routine"
+	ReferralUrgencyPriority = "priority"
+	ReferralUrgencyUrgent   = "urgent"
+)
+
+// IsValidReferralUrgency reports whether urgency is a known referral urgency
+func IsValidReferralUrgency(urgency string) bool {
+	switch urgency {
+	case ReferralUrgencyRoutine, ReferralUrgencyPriority, ReferralUrgencyUrgent:
+		return true
+	}
+	return false
+}
+
+// ExternalSpecialist is an outside professional of the clinic's referral directory
+type ExternalSpecialist struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Name          string `gorm:"size:255;not null" json:"name"`
+	Specialty     string `gorm:"size:100;index" json:"specialty"` // e.g. Endodontia, Cirurgia Bucomaxilofacial, Otorrinolaringologia
+	CouncilType   string `gorm:"size:10" json:"council_type"`     // CRO, CRM, CRFa...
+	CouncilNumber string `gorm:"size:30" json:"council_number"`   // Registration with state, e.g. "12345-SP"
+	ClinicName    string `gorm:"size:255" json:"clinic_name"`
+	Phone         string `gorm:"size:30" json:"phone"`
+	Email         string `gorm:"size:255" json:"email"`
+	Address       string `gorm:"size:255" json:"address"`
+	City          string `gorm:"size:100" json:"city"`
+	State         string `gorm:"size:2" json:"state"`
+	Notes         string `gorm:"type:text" json:"notes"`
+	Active        bool   `gorm:"default:true" json:"active"`
+}
+
+// TableName specifies the table name
+func (ExternalSpecialist) TableName() string {
+	return "external_specialists"
+}
+
+// Referral sends a patient to an external specialist and follows it until the report comes back.
+// Specialist and professional data are copied so the letter does not change with the directory
+type Referral struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	PatientID uint     `gorm:"not null;index" json:"patient_id"`
+	Patient   *Patient `gorm:"foreignKey:PatientID" json:"patient,omitempty"`
+
+	// Referring professional
+	DentistID   uint   `gorm:"not null;index" json:"dentist_id"`
+	DentistName string `json:"dentist_name"`
+	DentistCRO  string `json:"dentist_cro"`
+
+	SpecialistID        uint                `gorm:"not null;index" json:"specialist_id"`
+	Specialist          *ExternalSpecialist `gorm:"foreignKey:SpecialistID" json:"specialist,omitempty"`
+	SpecialistName      string              `json:"specialist_name"`
+	SpecialistSpecialty string              `json:"specialist_specialty"`
+	SpecialistCouncil   string              `json:"specialist_council"` // e.g. "CRO 12345-SP"
+
+	Reason          string `gorm:"type:text;not null" json:"reason"`         // What the specialist is asked to evaluate/treat
+	ClinicalSummary string `gorm:"type:text" json:"clinical_summary"`        // History, findings and exams relevant to the referral
+	Urgency         string `gorm:"size:20;default:'routine'" json:"urgency"` // routine, priority, urgent
+
+	Status           string     `gorm:"size:20;default:'sent';index" json:"status"` // sent, scheduled, report_received, cancelled
+	SentAt           time.Time  `json:"sent_at"`
+	ScheduledFor     *time.Time `json:"scheduled_for,omitempty"`     // Appointment with the specialist
+	FollowUpDate     *time.Time `gorm:"index" json:"follow_up_date"` // The referral is overdue after this date
+	ReportReceivedAt *time.Time `json:"report_received_at,omitempty"`
+	CancelledAt      *time.Time `json:"cancelled_at,omitempty"`
+	CancelReason     string     `gorm:"type:text" json:"cancel_reason,omitempty"`
+
+	// Report returned by the specialist, kept in the patient's exams
+	ReportExamID *uint  `gorm:"index" json:"report_exam_id,omitempty"`
+	ReportNotes  string `gorm:"type:text" json:"report_notes"`
+
+	Notes       string `gorm:"type:text" json:"notes"`
+	CreatedByID uint   `json:"created_by_id"`
+}
+
+// TableName specifies the table name
+func (Referral) TableName() string {
+	return "referrals"
+}
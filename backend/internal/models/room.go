@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Room is a bookable physical resource of the clinic (dental chair, surgery room, X-ray room...)
+// Appointments linked to a room cannot overlap, regardless of the professional
+type Room struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Name        string  `gorm:"not null" json:"name"`
+	Type        string  `gorm:"default:'chair'" json:"type"` // chair, room
+	Description string  `gorm:"type:text" json:"description"`
+	Equipment   *string `gorm:"type:jsonb" json:"equipment,omitempty"` // JSON array of equipment names, e.g. ["raio-x", "laser"]
+	Active      bool    `gorm:"default:true" json:"active"`
+}
+
+// Room types
+const (
+	RoomTypeChair = "chair"
+	RoomTypeRoom  = "room"
+)
@@ -28,6 +28,7 @@ type TenantSettings struct {
 	WorkingHoursStart          string `json:"working_hours_start"` // Format: "HH:MM"
 	WorkingHoursEnd            string `json:"working_hours_end"`   // Format: "HH:MM"
 	DefaultAppointmentDuration int    `json:"default_appointment_duration" gorm:"default:30"`
+	AppointmentBufferMinutes   int    `json:"appointment_buffer_minutes" gorm:"default:0"` // Cleanup time kept between appointments when offering slots
 
 	// Lunch Break
 	LunchBreakEnabled bool   `json:"lunch_break_enabled" gorm:"default:false"`
@@ -42,6 +43,13 @@ type TenantSettings struct {
 	PaymentTransferEnabled     bool `json:"payment_transfer_enabled" gorm:"default:false"`
 	PaymentInsuranceEnabled    bool `json:"payment_insurance_enabled" gorm:"default:false"`
 
+	// PIX - clinic key for the BR Codes and the PSP that confirms dynamic charges (empty: static codes only)
+	PixKeyType      string `json:"pix_key_type"` // cpf, cnpj, email, phone, random
+	PixKey          string `json:"pix_key"`
+	PixMerchantName string `json:"pix_merchant_name"` // Up to 25 characters, shown to the payer
+	PixMerchantCity string `json:"pix_merchant_city"` // Up to 15 characters
+	PixProvider     string `json:"pix_provider"`
+
 	// SMTP Settings for sending campaign emails
 	SMTPHost     string `json:"smtp_host"`
 	SMTPPort     int    `json:"smtp_port"`
@@ -64,6 +72,20 @@ type TenantSettings struct {
 	WhatsAppTemplateConfirmation string `json:"whatsapp_template_confirmation,omitempty" gorm:"column:whatsapp_template_confirmation"`
 	WhatsAppTemplateReminder     string `json:"whatsapp_template_reminder,omitempty" gorm:"column:whatsapp_template_reminder"`
 	WhatsAppTemplateReminderHours int   `json:"whatsapp_template_reminder_hours" gorm:"column:whatsapp_template_reminder_hours;default:24"`
+	WhatsAppTemplateWaitingList  string `json:"whatsapp_template_waiting_list,omitempty" gorm:"column:whatsapp_template_waiting_list"` // Slot offers to the waiting list (needs a "link" parameter)
+	WaitingListHoldMinutes       int    `json:"waiting_list_hold_minutes" gorm:"default:60"`                                           // How long a freed slot is held for each waiting patient
+
+	// Calendar feeds (ICS subscriptions and .ics attachments)
+	CalendarFeedPrivacy string `json:"calendar_feed_privacy" gorm:"default:'initials'"` // full, initials, minimal
+
+	// No-show policy - restrictions for patients with NoShowThreshold no-shows in the last NoShowWindowMonths months
+	NoShowPolicyEnabled       bool    `json:"no_show_policy_enabled" gorm:"default:false"`
+	NoShowThreshold           int     `json:"no_show_threshold" gorm:"default:3"`
+	NoShowWindowMonths        int     `json:"no_show_window_months" gorm:"default:6"`
+	NoShowRequireConfirmation bool    `json:"no_show_require_confirmation" gorm:"default:false"` // Self-bookings wait for staff confirmation
+	NoShowBlockSelfBooking    bool    `json:"no_show_block_self_booking" gorm:"default:false"`   // Portal and WhatsApp booking disabled
+	NoShowRequireDeposit      bool    `json:"no_show_require_deposit" gorm:"default:false"`      // Self-bookings require a deposit (sinal)
+	NoShowDepositAmount       float64 `json:"no_show_deposit_amount" gorm:"default:0"`
 
 	// SMS Settings (future use)
 	SMSAPIKey   string `json:"sms_api_key,omitempty"`
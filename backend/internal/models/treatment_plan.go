@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TreatmentPlanItem is a procedure of a budget, optionally on a tooth and some of its surfaces
+// The same item follows the plan: it is quoted in the Budget, carried by the Treatment once the
+// budget is approved, scheduled to an Appointment and ticked off when the appointment is completed
+type TreatmentPlanItem struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	BudgetID      uint  `gorm:"not null;index" json:"budget_id"`
+	TreatmentID   *uint `gorm:"index" json:"treatment_id"`   // Set when the budget is approved
+	AppointmentID *uint `gorm:"index" json:"appointment_id"` // Appointment where it is scheduled or was performed
+	PatientID     uint  `gorm:"not null;index" json:"patient_id"`
+	Position      int   `json:"position"`
+
+	Procedure     string `gorm:"size:255;not null" json:"procedure"`
+	ProcedureCode string `gorm:"size:20" json:"procedure_code"` // e.g. TUSS code
+	Tooth         *int   `json:"tooth"`                         // FDI notation, empty for procedures not tied to a tooth
+	Surfaces      string `gorm:"size:5" json:"surfaces"`        // Combination of M, D, O, V, L (empty for the whole tooth)
+	Condition     string `gorm:"size:30" json:"condition"`      // Odontogram condition the procedure results in (ToothCondition*)
+
+	Quantity  int     `gorm:"default:1" json:"quantity"`
+	UnitPrice float64 `json:"unit_price"`
+	Total     float64 `json:"total"`
+	LabCost   float64 `json:"lab_cost"` // Prosthesis lab cost, deducted from net commissions
+
+	Status           string     `gorm:"size:20;default:'planned';index" json:"status"` // planned, completed, cancelled
+	CompletedAt      *time.Time `json:"completed_at"`
+	PerformedByID    *uint      `json:"performed_by_id"`
+	PlannedEventID   *uint      `json:"planned_event_id"`   // Planned ToothEvent charted when the budget is approved
+	PerformedEventID *uint      `json:"performed_event_id"` // Existing ToothEvent charted when performed
+	Notes            string     `gorm:"type:text" json:"notes"`
+}
+
+// TableName specifies the table name
+func (TreatmentPlanItem) TableName() string {
+	return "treatment_plan_items"
+}
+
+// Treatment plan item statuses
+const (
+	PlanItemStatusPlanned   = "planned"
+	PlanItemStatusCompleted = "completed"
+	PlanItemStatusCancelled = "cancelled"
+)
@@ -16,6 +16,7 @@ type TreatmentProtocol struct {
 	Procedures  string         `gorm:"type:jsonb" json:"procedures"` // Array of procedure objects
 	Duration    int            `json:"duration"`                     // Estimated duration in minutes
 	Cost        float64        `json:"cost"`                         // Estimated cost
+	RequiredEquipment string   `gorm:"type:jsonb" json:"required_equipment"` // JSON array of equipment the room must have
 	Active      bool           `gorm:"default:true" json:"active"`
 	CreatedBy   uint           `gorm:"not null" json:"created_by"`
 }
@@ -15,7 +15,7 @@ type WaitingList struct {
 	DentistID      *uint          `gorm:"index" json:"dentist_id,omitempty"` // Optional - any dentist if null
 	Procedure      string         `gorm:"type:text" json:"procedure"`                    // Procedure needed
 	PreferredDates string         `gorm:"type:jsonb" json:"preferred_dates,omitempty"`   // JSONB array of date ranges
-	Priority       string         `gorm:"type:text;default:'normal'" json:"priority"`    // normal, urgent
+	Priority       string         `gorm:"type:text;default:'normal'" json:"priority"`    // urgent, high, normal, low
 	Status         string         `gorm:"type:text;default:'waiting'" json:"status"`     // waiting, contacted, scheduled, cancelled
 	ContactedAt    *time.Time     `json:"contacted_at,omitempty"`                        // When patient was contacted
 	ContactedBy    *uint          `json:"contacted_by,omitempty"`                        // User who contacted
@@ -29,3 +29,62 @@ type WaitingList struct {
 func (WaitingList) TableName() string {
 	return "waiting_lists"
 }
+
+// Waiting list priorities, from most to least urgent
+const (
+	WaitingListPriorityUrgent = "urgent"
+	WaitingListPriorityHigh   = "high"
+	WaitingListPriorityNormal = "normal"
+	WaitingListPriorityLow    = "low"
+)
+
+// WaitingListPriorityRank returns a SQL expression ranking the priority column on the full scale
+// (urgent = 0 ... low = 3); unknown values rank as normal
+func WaitingListPriorityRank(column string) string {
+	return "CASE " + column +
+		" WHEN '" + WaitingListPriorityUrgent + "' THEN 0" +
+		" WHEN '" + WaitingListPriorityHigh + "' THEN 1" +
+		" WHEN '" + WaitingListPriorityNormal + "' THEN 2" +
+		" WHEN '" + WaitingListPriorityLow + "' THEN 3" +
+		" ELSE 2 END"
+}
+
+// WaitingListOffer is a freed slot offered to a waiting-list patient
+// The slot is held for the patient until ExpiresAt; claiming it books the appointment
+type WaitingListOffer struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	WaitingListID       uint `gorm:"not null;index" json:"waiting_list_id"`
+	PatientID           uint `gorm:"not null;index" json:"patient_id"`
+	SourceAppointmentID uint `gorm:"not null;index" json:"source_appointment_id"` // Cancelled appointment that freed the slot
+
+	// Offered slot
+	DentistID uint      `gorm:"not null" json:"dentist_id"`
+	RoomID    *uint     `json:"room_id,omitempty"`
+	StartTime LocalTime `gorm:"not null;type:timestamp" json:"start_time"`
+	EndTime   LocalTime `gorm:"not null;type:timestamp" json:"end_time"`
+	Procedure string    `json:"procedure"`
+
+	// Delivery and hold
+	Token     string    `gorm:"size:80;uniqueIndex;not null" json:"-"`
+	Channel   string    `json:"channel"` // whatsapp, email
+	Recipient string    `json:"recipient"`
+	Status    string    `gorm:"default:'pending'" json:"status"` // pending, claimed, declined, expired, failed
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+
+	RespondedAt   *time.Time `json:"responded_at,omitempty"`
+	AppointmentID *uint      `json:"appointment_id,omitempty"` // Appointment booked when the offer was claimed
+	ErrorMessage  string     `gorm:"type:text" json:"error_message,omitempty"`
+}
+
+// Waiting list offer statuses
+const (
+	WaitingListOfferPending  = "pending"
+	WaitingListOfferClaimed  = "claimed"
+	WaitingListOfferDeclined = "declined"
+	WaitingListOfferExpired  = "expired"
+	WaitingListOfferFailed   = "failed"
+)
@@ -0,0 +1,251 @@
+// Package pix generates PIX charges in the BR Code format of the Central Bank (EMV QR Code
+// "copia e cola" payloads with CRC16), renders them as QR codes and confirms their payment
+// through a payment service provider (PSP). Static codes carry the clinic key and are confirmed
+// by the staff; dynamic codes point to a charge created at the PSP, which reports the payment.
+package pix
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// GUI is the globally unique identifier of PIX in the merchant account information
+const GUI = "br.gov.bcb.pix"
+
+// Sizes of the payload fields, from the BR Code manual
+const (
+	MaxMerchantNameLength = 25
+	MaxMerchantCityLength = 15
+	MaxStaticTxIDLength   = 25
+	MinDynamicTxIDLength  = 26
+	MaxDynamicTxIDLength  = 35
+	maxTemplateLength     = 99
+)
+
+// Key types accepted as the clinic key
+const (
+	KeyTypeCPF    = "cpf"
+	KeyTypeCNPJ   = "cnpj"
+	KeyTypeEmail  = "email"
+	KeyTypePhone  = "phone"
+	KeyTypeRandom = "random" // Chave aleatória (EVP)
+)
+
+var (
+	txIDPattern   = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
+	emailPattern  = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+	randomPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+	accents       = strings.NewReplacer(
+		"á", "a", "à", "a", "â", "a", "ã", "a", "é", "e", "ê", "e", "í", "i",
+		"ó", "o", "ô", "o", "õ", "o", "ú", "u", "ü", "u", "ç", "c",
+		"Á", "A", "À", "A", "Â", "A", "Ã", "A", "É", "E", "Ê", "E", "Í", "I",
+		"Ó", "O", "Ô", "O", "Õ", "O", "Ú", "U", "Ü", "U", "Ç", "C",
+	)
+)
+
+// Payload is the data of a PIX BR Code. A payload with Location is dynamic: the amount and
+// expiration are kept by the PSP at that URL. Otherwise it is static and carries the key
+type Payload struct {
+	Key          string
+	Description  string // Shown to the payer (static codes only)
+	MerchantName string
+	MerchantCity string
+	Amount       float64 // Zero lets the payer type the amount
+	TxID         string  // Identifies the charge in the payment; empty for "***"
+	Location     string  // URL of the charge at the PSP, without https://
+}
+
+// Dynamic reports whether the payload points to a charge at the PSP
+func (p Payload) Dynamic() bool {
+	return p.Location != ""
+}
+
+// emv formats a field as ID, two-digit length and value
+func emv(id, value string) string {
+	return fmt.Sprintf("%s%02d%s", id, len(value), value)
+}
+
+// Text keeps the characters a payer app shows correctly: no accents, letters, digits and
+// basic punctuation, cut to max characters
+func Text(text string, max int) string {
+	text = accents.Replace(strings.TrimSpace(text))
+	text = strings.Map(func(r rune) rune {
+		switch {
+		case r > unicode.MaxASCII:
+			return -1
+		case unicode.IsLetter(r), unicode.IsDigit(r), strings.ContainsRune(" .,-/&@", r):
+			return r
+		}
+		return -1
+	}, text)
+	text = strings.Join(strings.Fields(text), " ")
+	if len(text) > max {
+		text = strings.TrimSpace(text[:max])
+	}
+	return text
+}
+
+// Encode builds the "copia e cola" string of the payload, ending with its CRC16
+func (p Payload) Encode() (string, error) {
+	name := Text(p.MerchantName, MaxMerchantNameLength)
+	city := Text(p.MerchantCity, MaxMerchantCityLength)
+	if name == "" {
+		return "", errors.New("merchant name is required")
+	}
+	if city == "" {
+		return "", errors.New("merchant city is required")
+	}
+	if p.Amount < 0 {
+		return "", errors.New("amount cannot be negative")
+	}
+
+	var account string
+	txID := p.TxID
+	if p.Dynamic() {
+		if strings.HasPrefix(p.Location, "https://") || strings.HasPrefix(p.Location, "http://") {
+			return "", errors.New("location must not include the scheme")
+		}
+		account = emv("00", GUI) + emv("25", p.Location)
+		if txID == "" {
+			txID = "***"
+		}
+	} else {
+		if p.Key == "" {
+			return "", errors.New("key is required for static codes")
+		}
+		account = emv("00", GUI) + emv("01", p.Key)
+		if description := Text(p.Description, maxTemplateLength); description != "" {
+			// The description takes what is left of the 99 characters of the template
+			room := maxTemplateLength - len(account) - 4
+			if room > 0 {
+				if len(description) > room {
+					description = strings.TrimSpace(description[:room])
+				}
+				account += emv("02", description)
+			}
+		}
+		if txID == "" {
+			txID = "***"
+		}
+		if txID != "***" && (len(txID) > MaxStaticTxIDLength || !txIDPattern.MatchString(txID)) {
+			return "", fmt.Errorf("txid must have up to %d letters and digits", MaxStaticTxIDLength)
+		}
+	}
+	if len(account) > maxTemplateLength {
+		return "", errors.New("merchant account information is too long")
+	}
+
+	var b strings.Builder
+	b.WriteString(emv("00", "01"))
+	if p.Dynamic() || p.Amount > 0 {
+		// Point of initiation 12: the code is meant for a single payment
+		b.WriteString(emv("01", "12"))
+	}
+	b.WriteString(emv("26", account))
+	b.WriteString(emv("52", "0000"))
+	b.WriteString(emv("53", "986")) // BRL
+	if p.Amount > 0 && !p.Dynamic() {
+		b.WriteString(emv("54", strconv.FormatFloat(p.Amount, 'f', 2, 64)))
+	}
+	b.WriteString(emv("58", "BR"))
+	b.WriteString(emv("59", name))
+	b.WriteString(emv("60", city))
+	b.WriteString(emv("62", emv("05", txID)))
+	b.WriteString("6304")
+
+	payload := b.String()
+	return payload + CRC16(payload), nil
+}
+
+// CRC16 returns the CRC16-CCITT (polynomial 0x1021, initial value 0xFFFF) of the payload in the
+// four hexadecimal digits appended to the BR Code
+func CRC16(data string) string {
+	crc := uint16(0xFFFF)
+	for i := 0; i < len(data); i++ {
+		crc ^= uint16(data[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return fmt.Sprintf("%04X", crc)
+}
+
+// ValidCRC reports whether a BR Code ends with the CRC16 of its contents
+func ValidCRC(payload string) bool {
+	if len(payload) < 8 || payload[len(payload)-8:len(payload)-4] != "6304" {
+		return false
+	}
+	return strings.EqualFold(CRC16(payload[:len(payload)-4]), payload[len(payload)-4:])
+}
+
+// NewTxID generates a random charge identifier with n letters and digits. Static codes take up
+// to 25 characters and dynamic charges from 26 to 35
+func NewTxID(n int) string {
+	const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	b := make([]byte, n)
+	max := big.NewInt(int64(len(alphabet)))
+	for i := range b {
+		v, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			panic(err)
+		}
+		b[i] = alphabet[v.Int64()]
+	}
+	return string(b)
+}
+
+// digits keeps only the digits of a text
+func digits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// NormalizeKey validates a key of the type and returns it in the format of the DICT: CPF and
+// CNPJ as digits, e-mail in lowercase, phone as +55 with area code, random keys in lowercase
+func NormalizeKey(keyType, key string) (string, error) {
+	key = strings.TrimSpace(key)
+	switch keyType {
+	case KeyTypeCPF:
+		if key = digits(key); len(key) != 11 {
+			return "", errors.New("CPF key must have 11 digits")
+		}
+	case KeyTypeCNPJ:
+		if key = digits(key); len(key) != 14 {
+			return "", errors.New("CNPJ key must have 14 digits")
+		}
+	case KeyTypeEmail:
+		if key = strings.ToLower(key); !emailPattern.MatchString(key) || len(key) > 77 {
+			return "", errors.New("invalid e-mail key")
+		}
+	case KeyTypePhone:
+		key = digits(key)
+		if !strings.HasPrefix(key, "55") || len(key) < 12 {
+			key = "55" + key
+		}
+		if len(key) != 12 && len(key) != 13 {
+			return "", errors.New("phone key must have area code and number")
+		}
+		key = "+" + key
+	case KeyTypeRandom:
+		if key = strings.ToLower(key); !randomPattern.MatchString(key) {
+			return "", errors.New("invalid random key")
+		}
+	default:
+		return "", fmt.Errorf("unknown key type: %s", keyType)
+	}
+	return key, nil
+}
@@ -0,0 +1,149 @@
+package pix
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEncodeStaticMatchesManualExample(t *testing.T) {
+	// Example from the BR Code manual of the Central Bank
+	payload, err := Payload{
+		Key:          "123e4567-e12b-12d1-a456-426655440000",
+		MerchantName: "Fulano de Tal",
+		MerchantCity: "BRASILIA",
+	}.Encode()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := "00020126580014br.gov.bcb.pix0136123e4567-e12b-12d1-a456-4266554400005204000053039865802BR5913Fulano de Tal6008BRASILIA62070503***63041D3D"
+	if payload != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, payload)
+	}
+}
+
+func TestEncodeStaticWithAmountAndTxID(t *testing.T) {
+	payload, err := Payload{
+		Key:          "12345678000190",
+		Description:  "Orçamento #12",
+		MerchantName: "Clínica Sorriso Odontologia Integrada",
+		MerchantCity: "São Paulo",
+		Amount:       150.5,
+		TxID:         "ORC12",
+	}.Encode()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		"010212",
+		"0114" + "12345678000190",
+		"0212Orcamento 12",
+		"5406150.50",
+		"5925Clinica Sorriso Odontolo",
+		"6009Sao Paulo",
+		"62090505ORC12",
+	} {
+		if !strings.Contains(payload, want) {
+			t.Errorf("Expected %q in %s", want, payload)
+		}
+	}
+	if !ValidCRC(payload) {
+		t.Errorf("Invalid CRC: %s", payload)
+	}
+}
+
+func TestEncodeDynamic(t *testing.T) {
+	txID := NewTxID(MaxDynamicTxIDLength)
+	payload, err := Payload{
+		Key:          "ignored@clinica.com.br",
+		MerchantName: "Clinica",
+		MerchantCity: "Curitiba",
+		Amount:       80,
+		TxID:         txID,
+		Location:     "fake-psp.local/qr/v2/" + txID,
+	}.Encode()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(payload, "25"+"56"+"fake-psp.local/qr/v2/"+txID) {
+		t.Errorf("Expected location in %s", payload)
+	}
+	// The amount and the key are kept by the PSP
+	if strings.Contains(payload, "ignored@") || strings.Contains(payload, "540580.00") {
+		t.Errorf("Unexpected key or amount in dynamic payload %s", payload)
+	}
+	if !ValidCRC(payload) {
+		t.Errorf("Invalid CRC: %s", payload)
+	}
+}
+
+func TestEncodeRejectsInvalidPayloads(t *testing.T) {
+	invalid := []Payload{
+		{MerchantName: "Clinica", MerchantCity: "Curitiba"},
+		{Key: "a@b.com", MerchantCity: "Curitiba"},
+		{Key: "a@b.com", MerchantName: "Clinica"},
+		{Key: "a@b.com", MerchantName: "Clinica", MerchantCity: "Curitiba", Amount: -1},
+		{Key: "a@b.com", MerchantName: "Clinica", MerchantCity: "Curitiba", TxID: "has-dash"},
+		{Key: "a@b.com", MerchantName: "Clinica", MerchantCity: "Curitiba", TxID: strings.Repeat("A", 26)},
+		{MerchantName: "Clinica", MerchantCity: "Curitiba", Location: "https://psp.com/qr"},
+	}
+	for _, p := range invalid {
+		if _, err := p.Encode(); err == nil {
+			t.Errorf("Expected error for %+v", p)
+		}
+	}
+}
+
+func TestValidCRC(t *testing.T) {
+	payload := "00020126580014br.gov.bcb.pix0136123e4567-e12b-12d1-a456-4266554400005204000053039865802BR5913Fulano de Tal6008BRASILIA62070503***63041D3D"
+	if !ValidCRC(payload) {
+		t.Error("Expected valid CRC")
+	}
+	if !ValidCRC(payload[:len(payload)-4] + "1d3d") {
+		t.Error("Expected lowercase CRC to be accepted")
+	}
+	if ValidCRC(strings.Replace(payload, "Fulano", "Ciclano", 1)) {
+		t.Error("Expected tampered payload to fail")
+	}
+	if ValidCRC("123") {
+		t.Error("Expected short payload to fail")
+	}
+}
+
+func TestNewTxID(t *testing.T) {
+	a, b := NewTxID(25), NewTxID(25)
+	if len(a) != 25 || !txIDPattern.MatchString(a) || a == b {
+		t.Errorf("Unexpected txids %q and %q", a, b)
+	}
+}
+
+func TestNormalizeKey(t *testing.T) {
+	cases := []struct {
+		keyType, key, want string
+	}{
+		{KeyTypeCPF, "123.456.789-09", "12345678909"},
+		{KeyTypeCNPJ, "12.345.678/0001-90", "12345678000190"},
+		{KeyTypeEmail, " Financeiro@Clinica.com.br ", "financeiro@clinica.com.br"},
+		{KeyTypePhone, "(11) 98765-4321", "+5511987654321"},
+		{KeyTypePhone, "+55 11 98765-4321", "+5511987654321"},
+		{KeyTypeRandom, "123E4567-E12B-12D1-A456-426655440000", "123e4567-e12b-12d1-a456-426655440000"},
+	}
+	for _, tc := range cases {
+		got, err := NormalizeKey(tc.keyType, tc.key)
+		if err != nil || got != tc.want {
+			t.Errorf("NormalizeKey(%s, %q) = %q, %v; expected %q", tc.keyType, tc.key, got, err, tc.want)
+		}
+	}
+
+	for _, tc := range [][2]string{
+		{KeyTypeCPF, "123"},
+		{KeyTypeCNPJ, "123.456.789-09"},
+		{KeyTypeEmail, "clinica"},
+		{KeyTypePhone, "1234"},
+		{KeyTypeRandom, "not-a-uuid"},
+		{"bank", "123"},
+	} {
+		if _, err := NormalizeKey(tc[0], tc[1]); err == nil {
+			t.Errorf("Expected error for %s %q", tc[0], tc[1])
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package pix
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// FakeProviderName is the name the fake provider is registered under
+const FakeProviderName = "fake"
+
+// FakeProvider is an in-memory PSP for tests and local development. Charges are paid with MarkPaid
+type FakeProvider struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	charges map[string]*fakeCharge
+}
+
+type fakeCharge struct {
+	charge    Charge
+	expiresAt time.Time
+	status    PaymentStatus
+}
+
+// NewFakeProvider creates an empty fake provider
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{now: time.Now, charges: map[string]*fakeCharge{}}
+}
+
+// Name returns the name of the provider
+func (f *FakeProvider) Name() string {
+	return FakeProviderName
+}
+
+// CreateCharge records the charge and returns a location under fake-psp.local
+func (f *FakeProvider) CreateCharge(ctx context.Context, charge Charge) (ChargeResult, error) {
+	if len(charge.TxID) < MinDynamicTxIDLength || len(charge.TxID) > MaxDynamicTxIDLength || !txIDPattern.MatchString(charge.TxID) {
+		return ChargeResult{}, errors.New("invalid txid")
+	}
+	if charge.Amount <= 0 {
+		return ChargeResult{}, errors.New("amount must be positive")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, exists := f.charges[charge.TxID]; exists {
+		return ChargeResult{}, errors.New("txid already used")
+	}
+	expiration := charge.Expiration
+	if expiration <= 0 {
+		expiration = 24 * time.Hour
+	}
+	stored := &fakeCharge{charge: charge, expiresAt: f.now().Add(expiration)}
+	f.charges[charge.TxID] = stored
+	return ChargeResult{Location: "fake-psp.local/qr/v2/" + charge.TxID, ExpiresAt: stored.expiresAt}, nil
+}
+
+// CheckPayment returns the status of the charge
+func (f *FakeProvider) CheckPayment(ctx context.Context, txID string) (PaymentStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stored, ok := f.charges[txID]
+	if !ok {
+		return PaymentStatus{}, ErrChargeNotFound
+	}
+	return stored.status, nil
+}
+
+// MarkPaid simulates the payment of a charge, with the full amount when amount is zero
+func (f *FakeProvider) MarkPaid(txID string, amount float64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stored, ok := f.charges[txID]
+	if !ok {
+		return ErrChargeNotFound
+	}
+	if f.now().After(stored.expiresAt) {
+		return errors.New("charge expired")
+	}
+	if amount == 0 {
+		amount = stored.charge.Amount
+	}
+	stored.status = PaymentStatus{
+		Paid:       true,
+		PaidAt:     f.now(),
+		Amount:     amount,
+		EndToEndID: "E00000000" + f.now().Format("200601021504") + txID[:11],
+	}
+	return nil
+}
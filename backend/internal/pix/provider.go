@@ -0,0 +1,83 @@
+package pix
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrProviderNotFound is returned when the PSP configured for a clinic is not registered
+var ErrProviderNotFound = errors.New("pix provider not registered")
+
+// ErrChargeNotFound is returned by a provider that does not know the charge
+var ErrChargeNotFound = errors.New("pix charge not found")
+
+// Charge is a request for a dynamic charge at the PSP
+type Charge struct {
+	TxID          string
+	Key           string
+	Amount        float64
+	Description   string
+	PayerName     string
+	PayerDocument string // CPF of the payer, digits only
+	Expiration    time.Duration
+}
+
+// ChargeResult is the charge created by the PSP
+type ChargeResult struct {
+	Location  string // URL of the charge, without https://, for the dynamic BR Code
+	ExpiresAt time.Time
+}
+
+// PaymentStatus is what the PSP knows about the payment of a charge
+type PaymentStatus struct {
+	Paid       bool
+	PaidAt     time.Time
+	Amount     float64
+	EndToEndID string // Identifier of the PIX transfer
+}
+
+// Provider is a payment service provider that creates dynamic charges and confirms their payment.
+// Implementations are registered by name and chosen in the clinic settings
+type Provider interface {
+	Name() string
+	CreateCharge(ctx context.Context, charge Charge) (ChargeResult, error)
+	CheckPayment(ctx context.Context, txID string) (PaymentStatus, error)
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]Provider{}
+)
+
+// Register makes a provider available by its name, replacing one with the same name
+func Register(provider Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[provider.Name()] = provider
+}
+
+// GetProvider returns the registered provider with the name
+func GetProvider(name string) (Provider, error) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	provider, ok := providers[name]
+	if !ok {
+		return nil, ErrProviderNotFound
+	}
+	return provider, nil
+}
+
+// Providers lists the names of the registered providers
+func Providers() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,88 @@
+package pix
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFakeProviderFlow(t *testing.T) {
+	provider := NewFakeProvider()
+	Register(provider)
+	registered, err := GetProvider(FakeProviderName)
+	if err != nil || registered != provider {
+		t.Fatalf("Expected the fake provider to be registered, got %v", err)
+	}
+	if _, err := GetProvider("unknown"); err != ErrProviderNotFound {
+		t.Errorf("Expected ErrProviderNotFound, got %v", err)
+	}
+
+	ctx := context.Background()
+	txID := NewTxID(30)
+	result, err := registered.CreateCharge(ctx, Charge{TxID: txID, Key: "a@b.com", Amount: 120, Expiration: time.Hour})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(result.Location, txID) {
+		t.Errorf("Unexpected location %q", result.Location)
+	}
+	if _, err := registered.CreateCharge(ctx, Charge{TxID: txID, Amount: 120}); err == nil {
+		t.Error("Expected error for repeated txid")
+	}
+	if _, err := registered.CreateCharge(ctx, Charge{TxID: "short", Amount: 120}); err == nil {
+		t.Error("Expected error for static-sized txid")
+	}
+
+	status, err := registered.CheckPayment(ctx, txID)
+	if err != nil || status.Paid {
+		t.Errorf("Expected unpaid charge, got %+v, %v", status, err)
+	}
+	if err := provider.MarkPaid(txID, 0); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	status, _ = registered.CheckPayment(ctx, txID)
+	if !status.Paid || status.Amount != 120 || status.EndToEndID == "" {
+		t.Errorf("Expected paid charge, got %+v", status)
+	}
+	if _, err := registered.CheckPayment(ctx, "missing"); err != ErrChargeNotFound {
+		t.Errorf("Expected ErrChargeNotFound, got %v", err)
+	}
+}
+
+func TestFakeProviderExpiration(t *testing.T) {
+	provider := NewFakeProvider()
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	provider.now = func() time.Time { return now }
+
+	txID := NewTxID(26)
+	if _, err := provider.CreateCharge(context.Background(), Charge{TxID: txID, Amount: 50, Expiration: time.Hour}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	now = now.Add(2 * time.Hour)
+	if err := provider.MarkPaid(txID, 0); err == nil {
+		t.Error("Expected expired charge to refuse payment")
+	}
+}
+
+func TestQRCodePNG(t *testing.T) {
+	payload, _ := Payload{Key: "a@b.com", MerchantName: "Clinica", MerchantCity: "Curitiba", Amount: 10}.Encode()
+	data, err := QRCodePNG(payload, 200)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Expected a PNG: %v", err)
+	}
+	if bounds := img.Bounds(); bounds.Dx() != 200 || bounds.Dy() != 200 {
+		t.Errorf("Expected 200x200, got %v", bounds)
+	}
+
+	uri, err := QRCodeDataURI(payload)
+	if err != nil || !strings.HasPrefix(uri, "data:image/png;base64,") {
+		t.Errorf("Unexpected data URI %.40s, %v", uri, err)
+	}
+}
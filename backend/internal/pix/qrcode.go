@@ -0,0 +1,38 @@
+package pix
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image/png"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/qr"
+)
+
+// DefaultQRCodeSize is the side, in pixels, of the QR codes sent to the portal and WhatsApp
+const DefaultQRCodeSize = 300
+
+// QRCodePNG renders a BR Code as a square PNG with the side in pixels
+func QRCodePNG(payload string, size int) ([]byte, error) {
+	code, err := qr.Encode(payload, qr.M, qr.Auto)
+	if err != nil {
+		return nil, err
+	}
+	if code, err = barcode.Scale(code, size, size); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, code); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// QRCodeDataURI renders a BR Code as a PNG data URI, ready for an <img> tag
+func QRCodeDataURI(payload string) (string, error) {
+	data, err := QRCodePNG(payload, DefaultQRCodeSize)
+	if err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(data), nil
+}
@@ -0,0 +1,464 @@
+package scheduler
+
+import (
+	"drcrwell/backend/internal/cache"
+	"drcrwell/backend/internal/database"
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/models"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Reminder dispatcher settings
+const (
+	reminderInterval       = 10 * time.Minute
+	defaultReminderHours   = 24
+	maxReminderAttempts    = 3 // Attempts (failed or skipped) before giving up on an appointment
+	reminderSMSUnsupported = "Envio de SMS ainda não suportado pelo provedor configurado"
+)
+
+// ReminderCandidate is an appointment inside the tenant's reminder window
+// Channel and Recipient describe how the reminder would be delivered
+type ReminderCandidate struct {
+	AppointmentID uint      `json:"appointment_id"`
+	PatientID     uint      `json:"patient_id"`
+	PatientName   string    `json:"patient_name"`
+	PatientPhone  string    `json:"patient_phone"`
+	PatientEmail  string    `json:"patient_email"`
+	DentistName   string    `json:"dentist_name"`
+	StartTime     time.Time `json:"start_time"`
+	EndTime       time.Time `json:"end_time"`
+	Procedure     string    `json:"procedure"`
+	Attempts      int       `json:"attempts"`
+	Channel       string    `json:"channel"`             // whatsapp, email, sms or empty when no channel is available
+	Recipient     string    `json:"recipient,omitempty"` // phone number or email address
+	SkipReason    string    `json:"skip_reason,omitempty"`
+}
+
+// ReminderPreview is the dry-run result for a tenant
+type ReminderPreview struct {
+	WindowHours      int                 `json:"window_hours"`
+	WindowStart      time.Time           `json:"window_start"`
+	WindowEnd        time.Time           `json:"window_end"`
+	WhatsAppEnabled  bool                `json:"whatsapp_enabled"`
+	WhatsAppTemplate string              `json:"whatsapp_template"`
+	EmailEnabled     bool                `json:"email_enabled"`
+	SMSEnabled       bool                `json:"sms_enabled"`
+	Appointments     []ReminderCandidate `json:"appointments"`
+}
+
+// reminderChannels holds the delivery channels configured for a tenant
+type reminderChannels struct {
+	whatsApp    bool
+	email       bool
+	sms         bool
+	accessToken string
+	emailConfig helpers.TenantEmailConfig
+}
+
+// StartReminderDispatcher starts the appointment reminder dispatcher
+// Uses distributed lock to prevent duplicate reminders across multiple instances
+func StartReminderDispatcher() {
+	log.Println("Reminder Dispatcher started - checking for upcoming appointments every 10 minutes (with distributed lock)")
+
+	// Run immediately on start (with lock)
+	if cache.AcquireSchedulerLock(LockReminder, reminderInterval-time.Minute) {
+		dispatchAppointmentReminders()
+	}
+
+	ticker := time.NewTicker(reminderInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		// Lock TTL is slightly less than the interval
+		if cache.AcquireSchedulerLock(LockReminder, reminderInterval-time.Minute) {
+			dispatchAppointmentReminders()
+		} else {
+			log.Println("Reminder Dispatcher: Skipping - another instance holds the lock")
+		}
+	}
+}
+
+// dispatchAppointmentReminders sends reminders for all active tenants
+func dispatchAppointmentReminders() {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Reminder Dispatcher: Database not initialized")
+		return
+	}
+
+	// Only tenants whose schema already has the reminder log table
+	var tenantIDs []uint
+	err := db.Raw(`
+		SELECT DISTINCT t.id
+		FROM public.tenants t
+		WHERE t.active = true
+		AND EXISTS (
+			SELECT 1 FROM information_schema.tables
+			WHERE table_schema = 'tenant_' || t.id
+			AND table_name = 'appointment_reminders'
+		)
+	`).Scan(&tenantIDs).Error
+	if err != nil {
+		log.Printf("Reminder Dispatcher: Error finding tenants: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, tenantID := range tenantIDs {
+		processTenantReminders(db, tenantID, now)
+	}
+}
+
+// PreviewTenantReminders returns the reminders the dispatcher would send for a tenant
+// right now, without sending anything or changing any appointment (dry-run)
+func PreviewTenantReminders(tenantID uint) (*ReminderPreview, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("banco de dados não inicializado")
+	}
+
+	var settings models.TenantSettings
+	if err := db.Table("public.tenant_settings").Where("tenant_id = ?", tenantID).First(&settings).Error; err != nil {
+		return nil, fmt.Errorf("configurações não encontradas")
+	}
+
+	now := time.Now()
+	hours := reminderWindowHours(settings)
+	channels := loadReminderChannels(settings)
+
+	candidates, err := findReminderCandidates(db, tenantID, now, hours)
+	if err != nil {
+		return nil, err
+	}
+	for i := range candidates {
+		chooseReminderChannel(&candidates[i], channels)
+	}
+
+	return &ReminderPreview{
+		WindowHours:      hours,
+		WindowStart:      now,
+		WindowEnd:        now.Add(time.Duration(hours) * time.Hour),
+		WhatsAppEnabled:  channels.whatsApp,
+		WhatsAppTemplate: settings.WhatsAppTemplateReminder,
+		EmailEnabled:     channels.email,
+		SMSEnabled:       channels.sms,
+		Appointments:     candidates,
+	}, nil
+}
+
+// processTenantReminders sends reminders for appointments entering the tenant's reminder window
+func processTenantReminders(db *gorm.DB, tenantID uint, now time.Time) {
+	var settings models.TenantSettings
+	if err := db.Table("public.tenant_settings").Where("tenant_id = ?", tenantID).First(&settings).Error; err != nil {
+		return
+	}
+
+	candidates, err := findReminderCandidates(db, tenantID, now, reminderWindowHours(settings))
+	if err != nil {
+		log.Printf("Reminder Dispatcher: Error finding appointments for tenant %d: %v", tenantID, err)
+		return
+	}
+	if len(candidates) == 0 {
+		return
+	}
+
+	log.Printf("Reminder Dispatcher: Found %d appointment(s) to remind for tenant %d", len(candidates), tenantID)
+
+	channels := loadReminderChannels(settings)
+	schemaName := fmt.Sprintf("tenant_%d", tenantID)
+	tenantDB := db.Session(&gorm.Session{PrepareStmt: false})
+
+	// Template parameters are fetched once per tenant
+	var paramNames []string
+	if channels.whatsApp {
+		paramNames, _, err = helpers.GetMetaTemplateParameters(settings.WhatsAppBusinessAccountID, channels.accessToken, settings.WhatsAppTemplateReminder)
+		if err != nil {
+			log.Printf("Reminder Dispatcher: Could not fetch template params for tenant %d: %v", tenantID, err)
+			paramNames = []string{"paciente", "data", "hora", "dentista"}
+		}
+	}
+
+	sentCount := 0
+	for _, candidate := range candidates {
+		if sendAppointmentReminder(tenantDB, schemaName, settings, channels, paramNames, candidate) {
+			sentCount++
+		}
+	}
+
+	log.Printf("Reminder Dispatcher: Tenant %d completed: %d of %d reminders sent", tenantID, sentCount, len(candidates))
+}
+
+// sendAppointmentReminder delivers a single reminder, falling back from WhatsApp to email
+// Every attempt is recorded in appointment_reminders; returns true if any channel succeeded
+func sendAppointmentReminder(db *gorm.DB, schemaName string, settings models.TenantSettings, channels reminderChannels, paramNames []string, candidate ReminderCandidate) bool {
+	chooseReminderChannel(&candidate, channels)
+
+	loc := reminderLocation()
+	dateStr := candidate.StartTime.In(loc).Format("02/01/2006")
+	timeStr := candidate.StartTime.In(loc).Format("15:04")
+
+	sent := false
+	if candidate.Channel == models.ReminderChannelWhatsApp {
+		message := helpers.BuildAppointmentTemplateMessage(candidate.Recipient, settings.WhatsAppTemplateReminder, paramNames, helpers.AppointmentTemplateValues{
+			PatientName: candidate.PatientName,
+			Date:        dateStr,
+			Time:        timeStr,
+			DentistName: candidate.DentistName,
+			ClinicName:  settings.ClinicName,
+		})
+		result, err := helpers.SendMetaMessage(settings.WhatsAppPhoneNumberID, channels.accessToken, message)
+		if err != nil {
+			recordReminder(db, schemaName, candidate, models.ReminderChannelWhatsApp, candidate.Recipient, models.ReminderStatusFailed, "", err.Error())
+		} else {
+			messageID := ""
+			if len(result.Messages) > 0 {
+				messageID = result.Messages[0].ID
+			}
+			recordReminder(db, schemaName, candidate, models.ReminderChannelWhatsApp, candidate.Recipient, models.ReminderStatusSent, messageID, "")
+			sent = true
+		}
+	}
+
+	// Fall back to tenant SMTP when WhatsApp is unavailable or failed
+	if !sent && channels.email && candidate.PatientEmail != "" {
+		clinicName := settings.ClinicName
+		if clinicName == "" {
+			clinicName = "Clínica"
+		}
+		subject := fmt.Sprintf("Lembrete de consulta - %s", clinicName)
+		text := fmt.Sprintf("Lembramos que você tem uma consulta agendada para %s às %s", dateStr, timeStr)
+		if candidate.DentistName != "" {
+			text += fmt.Sprintf(" com %s", candidate.DentistName)
+		}
+		text += ".\n\nEm caso de imprevisto, entre em contato com a clínica para reagendar."
+
+		body := helpers.BuildCampaignEmailBody(clinicName, candidate.PatientName, text)
+		attachment := appointmentICSAttachment(settings, candidate)
+		if err := helpers.SendTenantEmailWithAttachments(channels.emailConfig, candidate.PatientEmail, subject, body, attachment); err != nil {
+			recordReminder(db, schemaName, candidate, models.ReminderChannelEmail, candidate.PatientEmail, models.ReminderStatusFailed, "", err.Error())
+		} else {
+			recordReminder(db, schemaName, candidate, models.ReminderChannelEmail, candidate.PatientEmail, models.ReminderStatusSent, "", "")
+			sent = true
+		}
+	}
+
+	if !sent && candidate.Channel == models.ReminderChannelSMS {
+		recordReminder(db, schemaName, candidate, models.ReminderChannelSMS, candidate.Recipient, models.ReminderStatusSkipped, "", reminderSMSUnsupported)
+	}
+
+	if !sent && candidate.Channel == "" {
+		recordReminder(db, schemaName, candidate, "", "", models.ReminderStatusSkipped, "", candidate.SkipReason)
+	}
+
+	if sent {
+		db.Exec(fmt.Sprintf(`
+			UPDATE %s.appointments
+			SET reminder_sent = true, updated_at = $1
+			WHERE id = $2 AND deleted_at IS NULL
+		`, schemaName), time.Now(), candidate.AppointmentID)
+	}
+
+	return sent
+}
+
+// appointmentICSAttachment builds the .ics file attached to patient appointment emails
+// The UID matches the professional's calendar feed, so re-sent files update the same event
+func appointmentICSAttachment(settings models.TenantSettings, candidate ReminderCandidate) helpers.EmailAttachment {
+	clinicName := settings.ClinicName
+	if clinicName == "" {
+		clinicName = "Clínica"
+	}
+
+	summary := "Consulta - " + clinicName
+	description := ""
+	if candidate.Procedure != "" {
+		description = "Procedimento: " + candidate.Procedure
+	}
+	if candidate.DentistName != "" {
+		if description != "" {
+			description += "\n"
+		}
+		description += "Profissional: " + candidate.DentistName
+	}
+
+	location := settings.ClinicAddress
+	if settings.ClinicCity != "" {
+		if location != "" {
+			location += ", "
+		}
+		location += settings.ClinicCity
+	}
+
+	end := candidate.EndTime
+	if !end.After(candidate.StartTime) {
+		end = candidate.StartTime.Add(30 * time.Minute)
+	}
+
+	ics := helpers.BuildICS(helpers.ICSCalendar{
+		Events: []helpers.ICSEvent{{
+			UID:         helpers.ICSAppointmentUID(settings.TenantID, candidate.AppointmentID),
+			Start:       candidate.StartTime,
+			End:         end,
+			Summary:     summary,
+			Description: description,
+			Location:    location,
+			Status:      helpers.ICSStatusConfirmed,
+		}},
+	})
+
+	return helpers.EmailAttachment{
+		Filename:    "consulta.ics",
+		ContentType: "text/calendar; method=PUBLISH",
+		Data:        []byte(ics),
+	}
+}
+
+// findReminderCandidates returns scheduled/confirmed appointments starting within the next
+// windowHours that have no reminder yet and have not exhausted their delivery attempts
+func findReminderCandidates(db *gorm.DB, tenantID uint, now time.Time, windowHours int) ([]ReminderCandidate, error) {
+	schemaName := fmt.Sprintf("tenant_%d", tenantID)
+	windowEnd := now.Add(time.Duration(windowHours) * time.Hour)
+
+	var candidates []ReminderCandidate
+	err := db.Session(&gorm.Session{PrepareStmt: false}).Raw(fmt.Sprintf(`
+		SELECT
+			a.id as appointment_id,
+			a.patient_id,
+			a.start_time,
+			a.end_time,
+			COALESCE(a.procedure, '') as procedure,
+			p.name as patient_name,
+			COALESCE(NULLIF(p.cell_phone, ''), NULLIF(p.phone, ''), '') as patient_phone,
+			COALESCE(p.email, '') as patient_email,
+			COALESCE(u.name, '') as dentist_name,
+			(SELECT COUNT(*) FROM %s.appointment_reminders r
+			 WHERE r.appointment_id = a.id AND r.deleted_at IS NULL) as attempts
+		FROM %s.appointments a
+		JOIN %s.patients p ON a.patient_id = p.id
+		LEFT JOIN public.users u ON a.dentist_id = u.id
+		WHERE a.deleted_at IS NULL
+		AND a.status IN ('scheduled', 'confirmed')
+		AND a.reminder_sent = false
+		AND a.start_time > ? AND a.start_time <= ?
+		ORDER BY a.start_time
+	`, schemaName, schemaName, schemaName), now, windowEnd).Scan(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Drop appointments that already failed too many times
+	pending := candidates[:0]
+	for _, candidate := range candidates {
+		if candidate.Attempts < maxReminderAttempts {
+			pending = append(pending, candidate)
+		}
+	}
+	return pending, nil
+}
+
+// chooseReminderChannel picks the first configured channel the patient can be reached on
+func chooseReminderChannel(candidate *ReminderCandidate, channels reminderChannels) {
+	switch {
+	case channels.whatsApp && candidate.PatientPhone != "":
+		candidate.Channel = models.ReminderChannelWhatsApp
+		candidate.Recipient = helpers.NormalizeWhatsAppPhone(candidate.PatientPhone)
+	case channels.email && candidate.PatientEmail != "":
+		candidate.Channel = models.ReminderChannelEmail
+		candidate.Recipient = candidate.PatientEmail
+	case channels.sms && candidate.PatientPhone != "":
+		candidate.Channel = models.ReminderChannelSMS
+		candidate.Recipient = candidate.PatientPhone
+		candidate.SkipReason = reminderSMSUnsupported
+	default:
+		candidate.Channel = ""
+		candidate.Recipient = ""
+		candidate.SkipReason = "Nenhum canal de envio configurado para o contato do paciente"
+	}
+}
+
+// loadReminderChannels checks which delivery channels are fully configured for the tenant
+func loadReminderChannels(settings models.TenantSettings) reminderChannels {
+	return loadDeliveryChannels(settings, settings.WhatsAppTemplateReminder)
+}
+
+// loadDeliveryChannels checks which channels can deliver a message using the given WhatsApp template
+func loadDeliveryChannels(settings models.TenantSettings, whatsAppTemplate string) reminderChannels {
+	var channels reminderChannels
+
+	if settings.WhatsAppEnabled && whatsAppTemplate != "" &&
+		settings.WhatsAppPhoneNumberID != "" && settings.WhatsAppAccessToken != "" {
+		token, err := helpers.DecryptIfNeeded(settings.WhatsAppAccessToken)
+		if err != nil {
+			log.Printf("Reminder Dispatcher: Error decrypting WhatsApp token for tenant %d: %v", settings.TenantID, err)
+		} else {
+			channels.whatsApp = true
+			channels.accessToken = token
+		}
+	}
+
+	if settings.SMTPHost != "" && settings.SMTPUsername != "" && settings.SMTPPassword != "" && settings.SMTPFromEmail != "" {
+		password, err := helpers.DecryptIfNeeded(settings.SMTPPassword)
+		if err != nil {
+			log.Printf("Reminder Dispatcher: Error decrypting SMTP password for tenant %d: %v", settings.TenantID, err)
+		} else {
+			channels.email = true
+			channels.emailConfig = helpers.TenantEmailConfig{
+				Host:      settings.SMTPHost,
+				Port:      settings.SMTPPort,
+				Username:  settings.SMTPUsername,
+				Password:  password,
+				FromName:  settings.SMTPFromName,
+				FromEmail: settings.SMTPFromEmail,
+				UseTLS:    settings.SMTPUseTLS,
+			}
+		}
+	}
+
+	channels.sms = settings.SMSProvider != "" && settings.SMSAPIKey != ""
+
+	return channels
+}
+
+// recordReminder stores the outcome of a delivery attempt
+func recordReminder(db *gorm.DB, schemaName string, candidate ReminderCandidate, channel, recipient, status, messageID, errorMessage string) {
+	now := time.Now()
+	var sentAt *time.Time
+	if status == models.ReminderStatusSent {
+		sentAt = &now
+	}
+
+	err := db.Exec(fmt.Sprintf(`
+		INSERT INTO %s.appointment_reminders
+			(created_at, updated_at, appointment_id, patient_id, channel, recipient, status, message_id, error_message, sent_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, schemaName), now, now, candidate.AppointmentID, candidate.PatientID, channel, recipient, status, messageID, errorMessage, sentAt).Error
+	if err != nil {
+		log.Printf("Reminder Dispatcher: Error recording reminder for appointment %d: %v", candidate.AppointmentID, err)
+	}
+}
+
+// reminderWindowHours returns how many hours before the appointment reminders are sent
+func reminderWindowHours(settings models.TenantSettings) int {
+	if settings.WhatsAppTemplateReminderHours <= 0 {
+		return defaultReminderHours
+	}
+	return settings.WhatsAppTemplateReminderHours
+}
+
+// reminderLocation returns the clinic timezone used to format dates in messages
+func reminderLocation() *time.Location {
+	tz := os.Getenv("TZ")
+	if tz == "" {
+		tz = "America/Sao_Paulo"
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
@@ -14,6 +14,8 @@ const (
 	LockRetention       = "retention"
 	LockSLA             = "sla_checker"
 	LockCampaign        = "campaign"
+	LockReminder        = "appointment_reminder"
+	LockWaitingList     = "waiting_list_offers"
 )
 
 // StartScheduler starts background jobs
@@ -28,6 +30,12 @@ func StartScheduler() {
 
 	// Start campaign scheduler for scheduled campaigns
 	go StartCampaignScheduler()
+
+	// Start appointment reminder dispatcher (WhatsApp template / email)
+	go StartReminderDispatcher()
+
+	// Start waiting list offer expirer (passes unclaimed freed slots to the next patient)
+	go StartWaitingListOfferExpirer()
 }
 
 // runTrialExpirationChecker runs every hour to check and deactivate expired trials
@@ -0,0 +1,369 @@
+package scheduler
+
+import (
+	"crypto/rand"
+	"drcrwell/backend/internal/cache"
+	"drcrwell/backend/internal/database"
+	"drcrwell/backend/internal/helpers"
+	"drcrwell/backend/internal/models"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Waiting list offer settings
+const (
+	waitingListInterval           = 5 * time.Minute
+	defaultWaitingListHoldMinutes = 60
+	minWaitingListOfferLead       = 30 * time.Minute // Slots starting sooner than this are not offered
+)
+
+// freedSlot is the period released by a cancelled appointment
+type freedSlot struct {
+	SourceAppointmentID uint
+	DentistID           uint
+	DentistName         string
+	RoomID              *uint
+	StartTime           time.Time
+	EndTime             time.Time
+	Procedure           string
+}
+
+// waitingListCandidate is a waiting-list entry that may receive an offer
+type waitingListCandidate struct {
+	ID             uint
+	PatientID      uint
+	Procedure      string
+	PreferredDates string
+	Priority       string
+	PatientName    string
+	PatientPhone   string
+	PatientEmail   string
+}
+
+// StartWaitingListOfferExpirer expires unanswered slot offers and passes the slot to the next patient
+// Uses distributed lock to prevent duplicate offers across multiple instances
+func StartWaitingListOfferExpirer() {
+	log.Println("Waiting List Offers started - checking expired holds every 5 minutes (with distributed lock)")
+
+	ticker := time.NewTicker(waitingListInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if cache.AcquireSchedulerLock(LockWaitingList, waitingListInterval-time.Minute) {
+			expireWaitingListOffers()
+		} else {
+			log.Println("Waiting List Offers: Skipping - another instance holds the lock")
+		}
+	}
+}
+
+// OfferFreedSlot offers the slot of a cancelled appointment to the waiting list
+// Entries are tried in priority order; the first patient reached gets a time-limited hold
+// Does nothing while another offer for the same slot is pending or was already claimed
+func OfferFreedSlot(tenantID, appointmentID uint) {
+	db := database.GetDB()
+	if db == nil {
+		return
+	}
+	schemaName := fmt.Sprintf("tenant_%d", tenantID)
+	tenantDB := db.Session(&gorm.Session{PrepareStmt: false})
+
+	var slot freedSlot
+	err := tenantDB.Raw(fmt.Sprintf(`
+		SELECT a.id as source_appointment_id, a.dentist_id, COALESCE(u.name, '') as dentist_name,
+			a.room_id, a.start_time, a.end_time, COALESCE(a.procedure, '') as procedure
+		FROM %s.appointments a
+		LEFT JOIN public.users u ON a.dentist_id = u.id
+		WHERE a.id = ? AND a.status = 'cancelled' AND a.deleted_at IS NULL
+	`, schemaName), appointmentID).Scan(&slot).Error
+	if err != nil || slot.SourceAppointmentID == 0 {
+		return
+	}
+
+	offerNextWaitingListCandidate(tenantDB, tenantID, schemaName, slot)
+}
+
+// offerNextWaitingListCandidate sends the slot to the best matching entry not yet offered this slot
+// Returns true when an offer was delivered
+func offerNextWaitingListCandidate(db *gorm.DB, tenantID uint, schemaName string, slot freedSlot) bool {
+	now := time.Now()
+	if slot.StartTime.Before(now.Add(minWaitingListOfferLead)) {
+		return false
+	}
+
+	// One live offer per slot
+	var active int64
+	db.Raw(fmt.Sprintf(`
+		SELECT COUNT(*) FROM %s.waiting_list_offers
+		WHERE source_appointment_id = ? AND status IN (?, ?) AND deleted_at IS NULL
+	`, schemaName), slot.SourceAppointmentID, models.WaitingListOfferPending, models.WaitingListOfferClaimed).Scan(&active)
+	if active > 0 {
+		return false
+	}
+
+	// The slot may have been booked again in the meantime
+	var taken int64
+	db.Raw(fmt.Sprintf(`
+		SELECT COUNT(*) FROM %s.appointments
+		WHERE dentist_id = ? AND status NOT IN ('cancelled', 'no_show') AND deleted_at IS NULL
+		AND start_time < ? AND end_time > ?
+	`, schemaName), slot.DentistID, slot.EndTime, slot.StartTime).Scan(&taken)
+	if taken > 0 {
+		return false
+	}
+
+	var settings models.TenantSettings
+	if err := db.Table("public.tenant_settings").Where("tenant_id = ?", tenantID).First(&settings).Error; err != nil {
+		return false
+	}
+	channels := loadDeliveryChannels(settings, settings.WhatsAppTemplateWaitingList)
+	if !channels.whatsApp && !channels.email {
+		log.Printf("Waiting List Offers: Tenant %d has no delivery channel configured", tenantID)
+		return false
+	}
+
+	candidates, err := findWaitingListCandidates(db, schemaName, slot)
+	if err != nil {
+		log.Printf("Waiting List Offers: Error finding candidates for tenant %d: %v", tenantID, err)
+		return false
+	}
+
+	for _, candidate := range candidates {
+		if sendWaitingListOffer(db, tenantID, schemaName, settings, channels, slot, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+// findWaitingListCandidates returns the waiting entries that fit the slot, by priority (urgent, high, normal, low),
+// then oldest first
+func findWaitingListCandidates(db *gorm.DB, schemaName string, slot freedSlot) ([]waitingListCandidate, error) {
+	var entries []waitingListCandidate
+	err := db.Raw(fmt.Sprintf(`
+		SELECT w.id, w.patient_id, COALESCE(w.procedure, '') as procedure,
+			COALESCE(w.preferred_dates::text, '') as preferred_dates, w.priority,
+			p.name as patient_name,
+			COALESCE(NULLIF(p.cell_phone, ''), NULLIF(p.phone, ''), '') as patient_phone,
+			COALESCE(p.email, '') as patient_email
+		FROM %s.waiting_lists w
+		JOIN %s.patients p ON w.patient_id = p.id AND p.deleted_at IS NULL
+		WHERE w.status = 'waiting' AND w.deleted_at IS NULL
+		AND (w.dentist_id IS NULL OR w.dentist_id = ?)
+		AND NOT EXISTS (
+			SELECT 1 FROM %s.waiting_list_offers o
+			WHERE o.waiting_list_id = w.id AND o.source_appointment_id = ? AND o.deleted_at IS NULL
+		)
+		ORDER BY %s, w.created_at ASC
+	`, schemaName, schemaName, schemaName, models.WaitingListPriorityRank("w.priority")), slot.DentistID, slot.SourceAppointmentID).Scan(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+
+	loc := reminderLocation()
+	matches := make([]waitingListCandidate, 0, len(entries))
+	for _, entry := range entries {
+		if !helpers.ProcedureMatches(entry.Procedure, slot.Procedure) {
+			continue
+		}
+		if !helpers.PreferredDatesMatch(entry.PreferredDates, slot.StartTime.In(loc)) {
+			continue
+		}
+		matches = append(matches, entry)
+	}
+	return matches, nil
+}
+
+// sendWaitingListOffer records a hold for the candidate and delivers the claim link
+// WhatsApp is tried first, then email; failed deliveries are recorded so the next entry is tried
+func sendWaitingListOffer(db *gorm.DB, tenantID uint, schemaName string, settings models.TenantSettings, channels reminderChannels, slot freedSlot, candidate waitingListCandidate) bool {
+	token, err := generateOfferToken(tenantID)
+	if err != nil {
+		log.Printf("Waiting List Offers: Error generating token: %v", err)
+		return false
+	}
+
+	hold := settings.WaitingListHoldMinutes
+	if hold <= 0 {
+		hold = defaultWaitingListHoldMinutes
+	}
+	now := time.Now()
+	expiresAt := now.Add(time.Duration(hold) * time.Minute)
+	// Never hold past the start of the appointment
+	if latest := slot.StartTime.Add(-minWaitingListOfferLead); expiresAt.After(latest) {
+		expiresAt = latest
+	}
+
+	loc := reminderLocation()
+	dateStr := slot.StartTime.In(loc).Format("02/01/2006")
+	timeStr := slot.StartTime.In(loc).Format("15:04")
+	link := WaitingListClaimURL(token)
+
+	channel, recipient := "", ""
+	sendErr := fmt.Errorf("paciente sem telefone ou email para contato")
+
+	if channels.whatsApp && candidate.PatientPhone != "" {
+		channel = models.ReminderChannelWhatsApp
+		recipient = helpers.NormalizeWhatsAppPhone(candidate.PatientPhone)
+
+		paramNames, _, err := helpers.GetMetaTemplateParameters(settings.WhatsAppBusinessAccountID, channels.accessToken, settings.WhatsAppTemplateWaitingList)
+		if err != nil {
+			paramNames = []string{"paciente", "data", "hora", "dentista", "link"}
+		}
+		message := helpers.BuildAppointmentTemplateMessage(recipient, settings.WhatsAppTemplateWaitingList, paramNames, helpers.AppointmentTemplateValues{
+			PatientName: candidate.PatientName,
+			Date:        dateStr,
+			Time:        timeStr,
+			DentistName: slot.DentistName,
+			ClinicName:  settings.ClinicName,
+			Link:        link,
+		})
+		_, sendErr = helpers.SendMetaMessage(settings.WhatsAppPhoneNumberID, channels.accessToken, message)
+	}
+
+	if sendErr != nil && channels.email && candidate.PatientEmail != "" {
+		channel = models.ReminderChannelEmail
+		recipient = candidate.PatientEmail
+
+		clinicName := settings.ClinicName
+		if clinicName == "" {
+			clinicName = "Clínica"
+		}
+		subject := fmt.Sprintf("Horário disponível - %s", clinicName)
+		text := fmt.Sprintf("Surgiu um horário para você em %s às %s", dateStr, timeStr)
+		if slot.DentistName != "" {
+			text += fmt.Sprintf(" com %s", slot.DentistName)
+		}
+		text += fmt.Sprintf(".\n\nO horário ficará reservado para você até %s. Para confirmar, acesse:\n%s",
+			expiresAt.In(loc).Format("02/01/2006 15:04"), link)
+
+		body := helpers.BuildCampaignEmailBody(clinicName, candidate.PatientName, text)
+		sendErr = helpers.SendTenantEmail(channels.emailConfig, candidate.PatientEmail, subject, body)
+	}
+
+	status := models.WaitingListOfferPending
+	errorMessage := ""
+	if sendErr != nil {
+		status = models.WaitingListOfferFailed
+		errorMessage = sendErr.Error()
+	}
+
+	err = db.Exec(fmt.Sprintf(`
+		INSERT INTO %s.waiting_list_offers
+			(created_at, updated_at, waiting_list_id, patient_id, source_appointment_id, dentist_id, room_id,
+			 start_time, end_time, procedure, token, channel, recipient, status, expires_at, error_message)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+	`, schemaName), now, now, candidate.ID, candidate.PatientID, slot.SourceAppointmentID, slot.DentistID, slot.RoomID,
+		models.LocalTime{Time: slot.StartTime}, models.LocalTime{Time: slot.EndTime}, slot.Procedure,
+		token, channel, recipient, status, expiresAt, errorMessage).Error
+	if err != nil {
+		log.Printf("Waiting List Offers: Error recording offer for entry %d: %v", candidate.ID, err)
+		return false
+	}
+
+	if sendErr != nil {
+		log.Printf("Waiting List Offers: Could not reach entry %d: %v", candidate.ID, sendErr)
+		return false
+	}
+
+	db.Exec(fmt.Sprintf(`
+		UPDATE %s.waiting_lists
+		SET status = 'contacted', contacted_at = $1, updated_at = $1
+		WHERE id = $2 AND status = 'waiting'
+	`, schemaName), now, candidate.ID)
+
+	log.Printf("Waiting List Offers: Slot of appointment %d offered to entry %d via %s (tenant %d)", slot.SourceAppointmentID, candidate.ID, channel, tenantID)
+	return true
+}
+
+// expireWaitingListOffers closes holds that ran out and offers each slot to the next entry
+func expireWaitingListOffers() {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Waiting List Offers: Database not initialized")
+		return
+	}
+
+	var tenantIDs []uint
+	err := db.Raw(`
+		SELECT DISTINCT t.id
+		FROM public.tenants t
+		WHERE t.active = true
+		AND EXISTS (
+			SELECT 1 FROM information_schema.tables
+			WHERE table_schema = 'tenant_' || t.id
+			AND table_name = 'waiting_list_offers'
+		)
+	`).Scan(&tenantIDs).Error
+	if err != nil {
+		log.Printf("Waiting List Offers: Error finding tenants: %v", err)
+		return
+	}
+
+	for _, tenantID := range tenantIDs {
+		schemaName := fmt.Sprintf("tenant_%d", tenantID)
+		tenantDB := db.Session(&gorm.Session{PrepareStmt: false})
+
+		var expired []models.WaitingListOffer
+		if err := tenantDB.Raw(fmt.Sprintf(`
+			UPDATE %s.waiting_list_offers
+			SET status = ?, updated_at = NOW()
+			WHERE status = ? AND expires_at <= NOW() AND deleted_at IS NULL
+			RETURNING id, waiting_list_id, source_appointment_id
+		`, schemaName), models.WaitingListOfferExpired, models.WaitingListOfferPending).Scan(&expired).Error; err != nil {
+			log.Printf("Waiting List Offers: Error expiring offers for tenant %d: %v", tenantID, err)
+			continue
+		}
+
+		for _, offer := range expired {
+			ReleaseWaitingListEntry(tenantDB, schemaName, offer.WaitingListID)
+			OfferFreedSlot(tenantID, offer.SourceAppointmentID)
+		}
+	}
+}
+
+// ReleaseWaitingListEntry puts an entry whose offer was declined or expired back in the queue
+func ReleaseWaitingListEntry(db *gorm.DB, schemaName string, waitingListID uint) {
+	db.Exec(fmt.Sprintf(`
+		UPDATE %s.waiting_lists SET status = 'waiting', updated_at = NOW()
+		WHERE id = ? AND status = 'contacted'
+	`, schemaName), waitingListID)
+}
+
+// WaitingListClaimURL returns the page where the patient accepts or declines an offer
+func WaitingListClaimURL(token string) string {
+	baseURL := os.Getenv("FRONTEND_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:3000"
+	}
+	return fmt.Sprintf("%s/waiting-list/claim?token=%s", baseURL, token)
+}
+
+// ParseOfferToken extracts the tenant ID embedded in an offer token
+func ParseOfferToken(token string) (uint, bool) {
+	prefix, _, found := strings.Cut(token, ".")
+	if !found {
+		return 0, false
+	}
+	tenantID, err := strconv.ParseUint(prefix, 10, 32)
+	if err != nil || tenantID == 0 {
+		return 0, false
+	}
+	return uint(tenantID), true
+}
+
+// generateOfferToken builds "<tenant>.<random>" so public claim links can find the tenant schema
+func generateOfferToken(tenantID uint) (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d.%s", tenantID, hex.EncodeToString(b)), nil
+}
@@ -59,6 +59,7 @@ import TaskForm from './pages/tasks/TaskForm';
 import TaskDetails from './pages/tasks/TaskDetails';
 import WaitingList from './pages/waiting-list/WaitingList';
 import WaitingListForm from './pages/waiting-list/WaitingListForm';
+import WaitingListClaim from './pages/waiting-list/WaitingListClaim';
 import { Leads, LeadForm } from './pages/leads';
 import ConsentTemplates from './pages/consents/ConsentTemplates';
 import Treatments from './pages/treatments/Treatments';
@@ -271,6 +272,9 @@ function App() {
       {/* Patient Portal Login (for clinic subdomains) */}
       <Route path="/portal-login" element={<PatientPortalLogin />} />
 
+      {/* Waiting list offer link sent to the patient (public) */}
+      <Route path="/waiting-list/claim" element={<WaitingListClaim />} />
+
       {/* Legal pages (public) */}
       <Route path="/termos-de-uso" element={<TermsOfService />} />
       <Route path="/politica-de-privacidade" element={<PrivacyPolicy />} />
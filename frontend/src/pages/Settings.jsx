@@ -11,6 +11,7 @@ const { Panel } = Collapse;
 
 const Settings = () => {
   const [form] = Form.useForm();
+  const pixKey = Form.useWatch('pix_key', form);
   const [loading, setLoading] = useState(false);
   const [fetchingSettings, setFetchingSettings] = useState(true);
   const [isMobile, setIsMobile] = useState(window.innerWidth <= 768);
@@ -61,6 +62,7 @@ const Settings = () => {
   const [whatsappTemplateConfirmation, setWhatsappTemplateConfirmation] = useState('');
   const [whatsappTemplateReminder, setWhatsappTemplateReminder] = useState('');
   const [whatsappReminderHours, setWhatsappReminderHours] = useState(24);
+  const [whatsappTemplateWaitingList, setWhatsappTemplateWaitingList] = useState('');
 
   useEffect(() => {
     const handleResize = () => setIsMobile(window.innerWidth <= 768);
@@ -103,6 +105,16 @@ const Settings = () => {
         lunch_break_enabled: settings.lunch_break_enabled ?? false,
         lunch_break_start: settings.lunch_break_start ? dayjs(settings.lunch_break_start, 'HH:mm') : null,
         lunch_break_end: settings.lunch_break_end ? dayjs(settings.lunch_break_end, 'HH:mm') : null,
+        // Waiting list offers
+        waiting_list_hold_minutes: settings.waiting_list_hold_minutes || 60,
+        // No-show policy
+        no_show_policy_enabled: settings.no_show_policy_enabled ?? false,
+        no_show_threshold: settings.no_show_threshold || 3,
+        no_show_window_months: settings.no_show_window_months || 6,
+        no_show_require_confirmation: settings.no_show_require_confirmation ?? false,
+        no_show_block_self_booking: settings.no_show_block_self_booking ?? false,
+        no_show_require_deposit: settings.no_show_require_deposit ?? false,
+        no_show_deposit_amount: settings.no_show_deposit_amount || 0,
         // Payment fields
         payment_cash_enabled: settings.payment_cash_enabled ?? true,
         payment_credit_card_enabled: settings.payment_credit_card_enabled ?? true,
@@ -110,6 +122,12 @@ const Settings = () => {
         payment_pix_enabled: settings.payment_pix_enabled ?? true,
         payment_transfer_enabled: settings.payment_transfer_enabled ?? false,
         payment_insurance_enabled: settings.payment_insurance_enabled ?? false,
+        // PIX key and provider
+        pix_key_type: settings.pix_key_type || undefined,
+        pix_key: settings.pix_key || '',
+        pix_merchant_name: settings.pix_merchant_name || '',
+        pix_merchant_city: settings.pix_merchant_city || '',
+        pix_provider: settings.pix_provider || '',
         // SMTP fields
         smtp_host: settings.smtp_host || '',
         smtp_port: settings.smtp_port || 587,
@@ -126,6 +144,7 @@ const Settings = () => {
       setWhatsappTemplateConfirmation(settings.whatsapp_template_confirmation || '');
       setWhatsappTemplateReminder(settings.whatsapp_template_reminder || '');
       setWhatsappReminderHours(settings.whatsapp_template_reminder_hours || 24);
+      setWhatsappTemplateWaitingList(settings.whatsapp_template_waiting_list || '');
       // Access token is masked, so we don't load it
 
       form.setFieldsValue(formValues);
@@ -331,6 +350,7 @@ const Settings = () => {
         whatsapp_template_confirmation: whatsappTemplateConfirmation,
         whatsapp_template_reminder: whatsappTemplateReminder,
         whatsapp_template_reminder_hours: whatsappReminderHours,
+        whatsapp_template_waiting_list: whatsappTemplateWaitingList,
       };
       // Only include access token if it was changed (not empty)
       if (whatsappAccessToken) {
@@ -445,7 +465,7 @@ const Settings = () => {
 
       message.success('Configurações salvas com sucesso');
     } catch (error) {
-      message.error('Erro ao salvar configurações');
+      message.error(error.response?.data?.error || 'Erro ao salvar configurações');
     } finally {
       setLoading(false);
     }
@@ -649,6 +669,94 @@ const Settings = () => {
           <TimePicker format="HH:mm" style={{ width: '100%' }} />
         </Form.Item>
       </Col>
+
+      <Col xs={24}>
+        <Divider orientation="left">Lista de Espera</Divider>
+      </Col>
+
+      <Col xs={24} md={12}>
+        <Form.Item
+          label="Reserva do Horário Oferecido (minutos)"
+          name="waiting_list_hold_minutes"
+          extra="Tempo que o paciente da lista de espera tem para confirmar um horário liberado antes que ele seja oferecido ao próximo"
+        >
+          <InputNumber min={5} max={1440} step={5} style={{ width: '100%' }} />
+        </Form.Item>
+      </Col>
+
+      <Col xs={24}>
+        <Divider orientation="left">Política de Faltas</Divider>
+      </Col>
+
+      <Col xs={24} md={8}>
+        <Form.Item
+          label="Aplicar Política de Faltas"
+          name="no_show_policy_enabled"
+          valuePropName="checked"
+        >
+          <Switch />
+        </Form.Item>
+      </Col>
+
+      <Col xs={24} md={8}>
+        <Form.Item
+          label="Número de Faltas"
+          name="no_show_threshold"
+          extra="Faltas que colocam o paciente na política"
+        >
+          <InputNumber min={1} max={20} style={{ width: '100%' }} />
+        </Form.Item>
+      </Col>
+
+      <Col xs={24} md={8}>
+        <Form.Item
+          label="Período Considerado (meses)"
+          name="no_show_window_months"
+        >
+          <InputNumber min={1} max={36} style={{ width: '100%' }} />
+        </Form.Item>
+      </Col>
+
+      <Col xs={24} md={8}>
+        <Form.Item
+          label="Exigir Confirmação da Equipe"
+          name="no_show_require_confirmation"
+          valuePropName="checked"
+          extra="Agendamentos feitos pelo paciente aguardam confirmação da clínica"
+        >
+          <Switch />
+        </Form.Item>
+      </Col>
+
+      <Col xs={24} md={8}>
+        <Form.Item
+          label="Bloquear Autoagendamento"
+          name="no_show_block_self_booking"
+          valuePropName="checked"
+          extra="Portal do paciente e WhatsApp deixam de aceitar agendamentos"
+        >
+          <Switch />
+        </Form.Item>
+      </Col>
+
+      <Col xs={24} md={8}>
+        <Form.Item
+          label="Exigir Sinal"
+          name="no_show_require_deposit"
+          valuePropName="checked"
+        >
+          <Switch />
+        </Form.Item>
+      </Col>
+
+      <Col xs={24} md={8}>
+        <Form.Item
+          label="Valor do Sinal (R$)"
+          name="no_show_deposit_amount"
+        >
+          <InputNumber min={0} step={10} precision={2} style={{ width: '100%' }} />
+        </Form.Item>
+      </Col>
     </Row>
   );
 
@@ -719,6 +827,66 @@ const Settings = () => {
           <Switch />
         </Form.Item>
       </Col>
+
+      <Col xs={24}>
+        <Divider orientation="left">Chave PIX</Divider>
+      </Col>
+
+      <Col xs={24} md={8}>
+        <Form.Item
+          label="Tipo de Chave"
+          name="pix_key_type"
+          rules={[{ required: !!pixKey, message: 'Selecione o tipo da chave' }]}
+        >
+          <Select placeholder="Selecione" allowClear>
+            <Select.Option value="cpf">CPF</Select.Option>
+            <Select.Option value="cnpj">CNPJ</Select.Option>
+            <Select.Option value="email">E-mail</Select.Option>
+            <Select.Option value="phone">Telefone</Select.Option>
+            <Select.Option value="random">Chave Aleatória</Select.Option>
+          </Select>
+        </Form.Item>
+      </Col>
+
+      <Col xs={24} md={16}>
+        <Form.Item
+          label="Chave PIX"
+          name="pix_key"
+          extra="Chave da clínica usada nos QR Codes de cobrança"
+        >
+          <Input placeholder="Ex: 12.345.678/0001-90" />
+        </Form.Item>
+      </Col>
+
+      <Col xs={24} md={12}>
+        <Form.Item
+          label="Nome do Recebedor"
+          name="pix_merchant_name"
+          extra="Exibido ao pagador (até 25 caracteres)"
+        >
+          <Input maxLength={25} placeholder="Ex: Clínica Sorriso" />
+        </Form.Item>
+      </Col>
+
+      <Col xs={24} md={12}>
+        <Form.Item
+          label="Cidade do Recebedor"
+          name="pix_merchant_city"
+          extra="Até 15 caracteres"
+        >
+          <Input maxLength={15} placeholder="Ex: São Paulo" />
+        </Form.Item>
+      </Col>
+
+      <Col xs={24} md={12}>
+        <Form.Item
+          label="Provedor PIX (PSP)"
+          name="pix_provider"
+          extra="Provedor que gera cobranças dinâmicas e confirma os pagamentos. Deixe em branco para usar apenas QR Codes estáticos"
+        >
+          <Input placeholder="Nome do provedor" />
+        </Form.Item>
+      </Col>
     </Row>
   );
 
@@ -1314,6 +1482,28 @@ const Settings = () => {
             </div>
           </Col>
 
+          <Col xs={24} md={12}>
+            <div style={{ marginBottom: 24 }}>
+              <label style={{ display: 'block', marginBottom: 8, fontWeight: 500 }}>Template de Oferta da Lista de Espera</label>
+              <Select
+                style={{ width: '100%' }}
+                value={whatsappTemplateWaitingList}
+                onChange={setWhatsappTemplateWaitingList}
+                placeholder="Selecione um template"
+                allowClear
+              >
+                {whatsappTemplates.map((t) => (
+                  <Select.Option key={t.name} value={t.name}>
+                    {t.name} ({t.language})
+                  </Select.Option>
+                ))}
+              </Select>
+              <div style={{ marginTop: 4, fontSize: 12, color: '#888' }}>
+                Usado para oferecer horários liberados a pacientes da lista de espera
+              </div>
+            </div>
+          </Col>
+
           <Col xs={24} md={8}>
             <div style={{ marginBottom: 24 }}>
               <label style={{ display: 'block', marginBottom: 8, fontWeight: 500 }}>Enviar Lembrete (horas antes)</label>
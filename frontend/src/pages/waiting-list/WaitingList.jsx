@@ -125,6 +125,16 @@ const WaitingList = () => {
     });
   };
 
+  const getPriorityConfig = (priority) => {
+    const priorityMap = {
+      urgent: { color: statusColors.error, label: 'Urgente' },
+      high: { color: statusColors.pending, label: 'Alta' },
+      normal: { color: statusColors.success, label: 'Normal' },
+      low: { color: statusColors.cancelled, label: 'Baixa' }
+    };
+    return priorityMap[priority] || priorityMap.normal;
+  };
+
   const getStatusConfig = (status) => {
     const statusMap = {
       waiting: { color: statusColors.pending, label: 'Aguardando' },
@@ -143,20 +153,21 @@ const WaitingList = () => {
         {entries.map((record) => {
           const statusConfig = getStatusConfig(record.status);
           const isUrgent = record.priority === 'urgent';
+          const priorityConfig = getPriorityConfig(record.priority);
           return (
             <Card
               key={record.id}
               size="small"
-              style={{ borderLeft: `4px solid ${isUrgent ? statusColors.error : statusColors.success}` }}
+              style={{ borderLeft: `4px solid ${priorityConfig.color}` }}
               bodyStyle={{ padding: '12px' }}
             >
               <div style={{ display: 'flex', justifyContent: 'space-between', alignItems: 'flex-start', marginBottom: '8px' }}>
                 <div style={{ fontWeight: 600, fontSize: '15px', flex: 1 }}>{record.patient?.name}</div>
                 <Tag
-                  color={isUrgent ? statusColors.error : statusColors.success}
+                  color={priorityConfig.color}
                   icon={isUrgent ? <ExclamationCircleOutlined /> : null}
                 >
-                  {isUrgent ? 'Urgente' : 'Normal'}
+                  {priorityConfig.label}
                 </Tag>
               </div>
               <div style={{ display: 'grid', gridTemplateColumns: '1fr 1fr', gap: '6px', fontSize: '13px', color: '#555' }}>
@@ -222,11 +233,11 @@ const WaitingList = () => {
       width: 130,
       render: (priority) => (
         <Tag
-          color={priority === 'urgent' ? statusColors.error : statusColors.success}
+          color={getPriorityConfig(priority).color}
           icon={priority === 'urgent' ? <ExclamationCircleOutlined /> : null}
           style={{ margin: 0, whiteSpace: 'nowrap' }}
         >
-          {priority === 'urgent' ? 'Urgente' : 'Normal'}
+          {getPriorityConfig(priority).label}
         </Tag>
       )
     },
@@ -409,8 +420,10 @@ const WaitingList = () => {
                 style={{ width: '100%' }}
                 allowClear
               >
-                <Option value="normal">Normal</Option>
                 <Option value="urgent">Urgente</Option>
+                <Option value="high">Alta</Option>
+                <Option value="normal">Normal</Option>
+                <Option value="low">Baixa</Option>
               </Select>
             </div>
             <div style={{ display: 'grid', gridTemplateColumns: '1fr 1fr', gap: '8px' }}>
@@ -460,8 +473,10 @@ const WaitingList = () => {
                 style={{ width: 150 }}
                 allowClear
               >
-                <Option value="normal">Normal</Option>
                 <Option value="urgent">Urgente</Option>
+                <Option value="high">Alta</Option>
+                <Option value="normal">Normal</Option>
+                <Option value="low">Baixa</Option>
               </Select>
               <Button onClick={fetchWaitingList}>Filtrar</Button>
             </Space>
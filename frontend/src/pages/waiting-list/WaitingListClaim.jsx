@@ -0,0 +1,182 @@
+import React, { useEffect, useState } from 'react';
+import { useSearchParams } from 'react-router-dom';
+import { Card, Typography, Spin, Result, Button, Descriptions, Space } from 'antd';
+import { CheckCircleOutlined, CloseCircleOutlined, ClockCircleOutlined } from '@ant-design/icons';
+import dayjs from 'dayjs';
+import { waitingListOfferPublicAPI } from '../../services/api';
+
+const { Title, Text } = Typography;
+
+const unavailableMessages = {
+  claimed: 'Este horário já foi confirmado.',
+  declined: 'Esta oferta foi recusada. Você continua na lista de espera.',
+  expired: 'O prazo para confirmar este horário expirou. Você continua na lista de espera.',
+  failed: 'Este horário não está mais disponível. Você continua na lista de espera.',
+};
+
+const WaitingListClaim = () => {
+  const [searchParams] = useSearchParams();
+  const [status, setStatus] = useState('loading'); // loading, offer, unavailable, claimed, declined, error
+  const [offer, setOffer] = useState(null);
+  const [submitting, setSubmitting] = useState(null); // claim, decline
+  const [message, setMessage] = useState('');
+
+  const token = searchParams.get('token');
+
+  useEffect(() => {
+    const loadOffer = async () => {
+      if (!token) {
+        setStatus('error');
+        setMessage('Link da oferta inválido');
+        return;
+      }
+
+      try {
+        const response = await waitingListOfferPublicAPI.getOffer(token);
+        setOffer(response.data);
+        if (response.data.status === 'pending') {
+          setStatus('offer');
+        } else {
+          setStatus('unavailable');
+          setMessage(unavailableMessages[response.data.status] || unavailableMessages.failed);
+        }
+      } catch (error) {
+        setStatus('error');
+        setMessage(error.response?.data?.error || 'Erro ao carregar a oferta');
+      }
+    };
+
+    loadOffer();
+  }, [token]);
+
+  const handleClaim = async () => {
+    setSubmitting('claim');
+    try {
+      const response = await waitingListOfferPublicAPI.claim(token);
+      setStatus('claimed');
+      setMessage(response.data.message || 'Agendamento confirmado com sucesso');
+    } catch (error) {
+      setStatus('error');
+      setMessage(error.response?.data?.error || 'Erro ao confirmar agendamento');
+    } finally {
+      setSubmitting(null);
+    }
+  };
+
+  const handleDecline = async () => {
+    setSubmitting('decline');
+    try {
+      const response = await waitingListOfferPublicAPI.decline(token);
+      setStatus('declined');
+      setMessage(response.data.message || 'Oferta recusada. Você continua na lista de espera.');
+    } catch (error) {
+      setStatus('error');
+      setMessage(error.response?.data?.error || 'Erro ao recusar oferta');
+    } finally {
+      setSubmitting(null);
+    }
+  };
+
+  const offerDetails = offer && (
+    <Descriptions column={1} size="small" bordered style={{ marginBottom: 16, textAlign: 'left' }}>
+      {offer.clinic_name && <Descriptions.Item label="Clínica">{offer.clinic_name}</Descriptions.Item>}
+      {offer.dentist_name && <Descriptions.Item label="Profissional">{offer.dentist_name}</Descriptions.Item>}
+      {offer.procedure && <Descriptions.Item label="Procedimento">{offer.procedure}</Descriptions.Item>}
+      <Descriptions.Item label="Data">{dayjs(offer.start_time).format('DD/MM/YYYY')}</Descriptions.Item>
+      <Descriptions.Item label="Horário">
+        {dayjs(offer.start_time).format('HH:mm')} - {dayjs(offer.end_time).format('HH:mm')}
+      </Descriptions.Item>
+    </Descriptions>
+  );
+
+  return (
+    <div style={{
+      display: 'flex',
+      justifyContent: 'center',
+      alignItems: 'center',
+      minHeight: '100vh',
+      background: 'linear-gradient(135deg, #81C784 0%, #66BB6A 100%)'
+    }}>
+      <Card style={{ width: 450, boxShadow: '0 8px 32px rgba(0,0,0,0.1)', borderRadius: 12 }}>
+        <div style={{ textAlign: 'center', marginBottom: 24 }}>
+          <div style={{
+            width: 80,
+            height: 80,
+            margin: '0 auto 16px',
+            background: 'linear-gradient(135deg, #66BB6A 0%, #4CAF50 100%)',
+            borderRadius: 16,
+            display: 'flex',
+            alignItems: 'center',
+            justifyContent: 'center',
+            boxShadow: '0 4px 12px rgba(102, 187, 106, 0.3)',
+          }}>
+            <span style={{ fontSize: 48, filter: 'brightness(0) invert(1)' }}>🦷</span>
+          </div>
+          <Title level={2} style={{ color: '#4CAF50', marginTop: 0 }}>OdoWell</Title>
+        </div>
+
+        {status === 'loading' && (
+          <div style={{ textAlign: 'center', padding: '40px 0' }}>
+            <Spin size="large" />
+            <Text style={{ display: 'block', marginTop: 16 }}>
+              Carregando oferta...
+            </Text>
+          </div>
+        )}
+
+        {status === 'offer' && (
+          <div style={{ textAlign: 'center' }}>
+            <Title level={4}>Um horário ficou disponível para você</Title>
+            {offerDetails}
+            <Text type="secondary" style={{ display: 'block', marginBottom: 16 }}>
+              <ClockCircleOutlined /> Confirme até {dayjs(offer.expires_at).format('DD/MM/YYYY [às] HH:mm')}
+            </Text>
+            <Space>
+              <Button
+                type="primary"
+                onClick={handleClaim}
+                loading={submitting === 'claim'}
+                disabled={submitting !== null}
+              >
+                Confirmar horário
+              </Button>
+              <Button
+                danger
+                onClick={handleDecline}
+                loading={submitting === 'decline'}
+                disabled={submitting !== null}
+              >
+                Recusar
+              </Button>
+            </Space>
+          </div>
+        )}
+
+        {status === 'claimed' && (
+          <Result
+            icon={<CheckCircleOutlined style={{ color: '#52c41a' }} />}
+            title={message}
+            subTitle={offerDetails}
+          />
+        )}
+
+        {(status === 'declined' || status === 'unavailable') && (
+          <Result
+            icon={<ClockCircleOutlined style={{ color: '#faad14' }} />}
+            title={message}
+          />
+        )}
+
+        {status === 'error' && (
+          <Result
+            icon={<CloseCircleOutlined style={{ color: '#ff4d4f' }} />}
+            title="Não foi possível concluir"
+            subTitle={message}
+          />
+        )}
+      </Card>
+    </div>
+  );
+};
+
+export default WaitingListClaim;
@@ -171,8 +171,10 @@ const WaitingListForm = () => {
             rules={[{ required: true, message: 'Selecione a prioridade' }]}
           >
             <Select>
-              <Option value="normal">Normal</Option>
               <Option value="urgent">Urgente</Option>
+              <Option value="high">Alta</Option>
+              <Option value="normal">Normal</Option>
+              <Option value="low">Baixa</Option>
             </Select>
           </Form.Item>
 
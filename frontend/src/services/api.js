@@ -392,6 +392,13 @@ export const waitingListAPI = {
   getStats: () => api.get('/waiting-list/stats'),
 };
 
+// Waiting List Offer Public API (no auth required - link sent to the patient)
+export const waitingListOfferPublicAPI = {
+  getOffer: (token) => api.get(`/waiting-list/offers/${token}`),
+  claim: (token) => api.post(`/waiting-list/offers/${token}/claim`),
+  decline: (token) => api.post(`/waiting-list/offers/${token}/decline`),
+};
+
 // Leads API (CRM para WhatsApp)
 export const leadsAPI = {
   getAll: (params) => api.get('/leads', { params }),